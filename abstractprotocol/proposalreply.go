@@ -16,6 +16,8 @@ type ProposalReply interface {
 	DeviceId() string
 	AcceptProposal()
 	DoNotAcceptProposal()
+	RejectProposal(reason uint)
+	RejectReason() uint
 }
 
 // A concrete ProposalReply object that implements all the functions of a ProposalReply interface. This represents the base protocol
@@ -24,6 +26,7 @@ type BaseProposalReply struct {
 	*BaseProtocolMessage
 	Decision bool   `json:"decision"`
 	Deviceid string `json:"deviceId"`
+	Reason   uint   `json:"reason,omitempty"` // The protocol specific reason code for a rejection, when the producer has one
 }
 
 func (bp *BaseProposalReply) IsValid() bool {
@@ -31,11 +34,11 @@ func (bp *BaseProposalReply) IsValid() bool {
 }
 
 func (bp *BaseProposalReply) String() string {
-	return bp.BaseProtocolMessage.String() + fmt.Sprintf(", Decision: %v, DeviceId: %v", bp.Decision, bp.Deviceid)
+	return bp.BaseProtocolMessage.String() + fmt.Sprintf(", Decision: %v, DeviceId: %v, Reason: %v", bp.Decision, bp.Deviceid, bp.Reason)
 }
 
 func (bp *BaseProposalReply) ShortString() string {
-	return bp.BaseProtocolMessage.ShortString() + fmt.Sprintf(", Decision: %v, DeviceId: %v", bp.Decision, bp.Deviceid)
+	return bp.BaseProtocolMessage.ShortString() + fmt.Sprintf(", Decision: %v, DeviceId: %v, Reason: %v", bp.Decision, bp.Deviceid, bp.Reason)
 }
 
 func (bp *BaseProposalReply) ProposalAccepted() bool {
@@ -54,6 +57,15 @@ func (bp *BaseProposalReply) DoNotAcceptProposal() {
 	bp.Decision = false
 }
 
+func (bp *BaseProposalReply) RejectProposal(reason uint) {
+	bp.Decision = false
+	bp.Reason = reason
+}
+
+func (bp *BaseProposalReply) RejectReason() uint {
+	return bp.Reason
+}
+
 func NewProposalReply(name string, version int, id string, deviceId string) *BaseProposalReply {
 	return &BaseProposalReply{
 		BaseProtocolMessage: &BaseProtocolMessage{
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/abstractprotocol"
 	"github.com/open-horizon/anax/config"
@@ -30,7 +29,7 @@ const HEARTBEAT = "HeartBeat"
 // must be safely-constructed!!
 type AgreementWorker struct {
 	worker.BaseWorker        // embedded field
-	db                       *bolt.DB
+	db                       persistence.EdgeDatabase
 	devicePattern            string
 	protocols                map[string]bool
 	pm                       *policy.PolicyManager
@@ -41,7 +40,7 @@ type AgreementWorker struct {
 	heartBeatFailed          bool
 }
 
-func NewAgreementWorker(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager) *AgreementWorker {
+func NewAgreementWorker(name string, cfg *config.HorizonConfig, db persistence.EdgeDatabase, pm *policy.PolicyManager) *AgreementWorker {
 
 	var ec *worker.BaseExchangeContext
 	pattern := ""
@@ -75,14 +74,14 @@ func (w *AgreementWorker) NewEvent(incoming events.Message) {
 	switch incoming.(type) {
 	case *events.EdgeRegisteredExchangeMessage:
 		msg, _ := incoming.(*events.EdgeRegisteredExchangeMessage)
-		w.Commands <- NewDeviceRegisteredCommand(msg)
+		w.Commands <- w.TraceCommand(incoming, NewDeviceRegisteredCommand(msg))
 
 	case *events.PolicyCreatedMessage:
 		msg, _ := incoming.(*events.PolicyCreatedMessage)
 
 		switch msg.Event().Id {
 		case events.NEW_POLICY:
-			w.Commands <- NewAdvertisePolicyCommand(msg.PolicyFile())
+			w.Commands <- w.TraceCommand(incoming, NewAdvertisePolicyCommand(msg.PolicyFile()))
 		default:
 			glog.Errorf("AgreementWorker received Unsupported event: %v", incoming.Event().Id)
 		}
@@ -92,7 +91,7 @@ func (w *AgreementWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.BC_CLIENT_INITIALIZED:
 			cmd := producer.NewBCInitializedCommand(msg)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 	case *events.BlockchainClientStoppingMessage:
@@ -100,7 +99,7 @@ func (w *AgreementWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.BC_CLIENT_STOPPING:
 			cmd := producer.NewBCStoppingCommand(msg)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 	case *events.AccountFundedMessage:
@@ -108,14 +107,14 @@ func (w *AgreementWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.ACCOUNT_FUNDED:
 			cmd := producer.NewBCWritableCommand(msg)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 	case *events.ExchangeDeviceMessage:
 		msg, _ := incoming.(*events.ExchangeDeviceMessage)
 		switch msg.Event().Id {
 		case events.RECEIVED_EXCHANGE_DEV_MSG:
-			w.Commands <- producer.NewExchangeMessageCommand(*msg)
+			w.Commands <- w.TraceCommand(incoming, producer.NewExchangeMessageCommand(*msg))
 		}
 
 	case *events.DeviceContainersSyncedMessage:
@@ -130,21 +129,21 @@ func (w *AgreementWorker) NewEvent(incoming events.Message) {
 		msg, _ := incoming.(*events.EdgeConfigCompleteMessage)
 		switch msg.Event().Id {
 		case events.NEW_DEVICE_CONFIG_COMPLETE:
-			w.Commands <- NewEdgeConfigCompleteCommand(msg)
+			w.Commands <- w.TraceCommand(incoming, NewEdgeConfigCompleteCommand(msg))
 		}
 
 	case *events.NodeShutdownMessage:
 		msg, _ := incoming.(*events.NodeShutdownMessage)
 		switch msg.Event().Id {
 		case events.START_UNCONFIGURE:
-			w.Commands <- worker.NewBeginShutdownCommand()
+			w.Commands <- w.TraceCommand(incoming, worker.NewBeginShutdownCommand())
 		}
 
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
 		case events.UNCONFIGURE_COMPLETE:
-			w.Commands <- worker.NewTerminateCommand("shutdown")
+			w.Commands <- w.TraceCommand(incoming, worker.NewTerminateCommand("shutdown"))
 		}
 
 	default: //nothing
@@ -0,0 +1,157 @@
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/agreementbot/persistence/bolt"
+	"github.com/open-horizon/anax/basicprotocol"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/policy"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_GetTimeoutTerminationCodes(t *testing.T) {
+
+	codes := GetTimeoutTerminationCodes()
+
+	// The basic protocol does not support the not finalized timeout.
+	if basic, ok := codes[policy.BasicProtocol]; !ok {
+		t.Errorf("no timeout codes for the basic protocol: %v", codes)
+	} else if len(basic) != 2 || basic[0] != basicprotocol.AB_CANCEL_NO_REPLY || basic[1] != basicprotocol.AB_CANCEL_NO_DATA_RECEIVED {
+		t.Errorf("wrong timeout codes for the basic protocol: %v", basic)
+	}
+}
+
+func Test_agreement_stats(t *testing.T) {
+
+	acc := persistence.NewAgreementStatsAccumulator(GetTimeoutTerminationCodes())
+
+	add := func(org string, pol string, dev string, inception uint64, finalized uint64, reason uint) {
+		ag := &persistence.Agreement{Org: org, PolicyName: pol, DeviceId: dev, AgreementProtocol: policy.BasicProtocol,
+			AgreementInceptionTime: inception, AgreementFinalizedTime: finalized}
+		if reason != 0 {
+			ag.AgreementTimedout = finalized + 100
+			ag.Archived = true
+			ag.TerminatedReason = reason
+			ag.TerminatedDescription = basicprotocol.DecodeReasonCode(uint64(reason))
+		}
+		acc.Add(ag)
+	}
+
+	// Active agreements, finalized after 10, 20 and 30 seconds.
+	add("org1", "pol1", "dev1", 1000, 1010, 0)
+	add("org1", "pol1", "dev2", 1000, 1020, 0)
+	add("org1", "pol2", "dev3", 1000, 1030, 0)
+
+	// Terminated agreements, 2 of them timed out before being finalized.
+	add("org1", "pol1", "dev1", 900, 0, basicprotocol.AB_CANCEL_NO_REPLY)
+	add("org1", "pol1", "dev1", 800, 0, basicprotocol.AB_CANCEL_NO_REPLY)
+	add("org1", "pol1", "dev2", 700, 740, basicprotocol.AB_CANCEL_POLICY_CHANGED)
+	add("org2", "pol1", "dev4", 700, 0, basicprotocol.AB_CANCEL_NEGATIVE_REPLY)
+
+	report := acc.Report()
+
+	if len(report.Orgs) != 2 || report.Orgs[0].Org != "org1" || report.Orgs[1].Org != "org2" {
+		t.Fatalf("wrong orgs in report: %v", report.Orgs)
+	} else if len(report.Policies) != 3 || report.Policies[0].PolicyName != "pol1" || report.Policies[1].PolicyName != "pol2" || report.Policies[2].Org != "org2" {
+		t.Fatalf("wrong policies in report: %v", report.Policies)
+	}
+
+	org1 := report.Orgs[0]
+	if org1.Agreements != 6 || org1.Active != 3 || org1.Terminated != 3 || org1.Finalized != 4 {
+		t.Errorf("wrong counts for org1: %v", org1)
+	} else if org1.TimedOut != 2 || org1.TimeoutRate != float64(2)/float64(6) {
+		t.Errorf("wrong timeouts for org1: %v", org1)
+	} else if org1.Devices != 3 || org1.MaxAgreementsPerDevice != 3 || org1.AgreementsPerDevice != 2 {
+		t.Errorf("wrong device counts for org1: %v", org1)
+	}
+
+	// The most frequent reason comes first.
+	if len(org1.TerminationReasons) != 2 {
+		t.Errorf("wrong termination reasons for org1: %v", org1.TerminationReasons)
+	} else if r := org1.TerminationReasons[0]; r.Reason != basicprotocol.AB_CANCEL_NO_REPLY || r.Count != 2 || r.Description == "" {
+		t.Errorf("wrong first termination reason for org1: %v", r)
+	}
+
+	if f := org1.TimeToFinalize; f.Count != 4 || f.Min != 10 || f.P50 != 20 || f.P90 != 40 || f.P99 != 40 || f.Max != 40 {
+		t.Errorf("wrong time to finalize for org1: %v", f)
+	}
+
+	pol1 := report.Policies[0]
+	if pol1.Agreements != 5 || pol1.TimedOut != 2 || pol1.Devices != 2 {
+		t.Errorf("wrong counts for org1/pol1: %v", pol1)
+	} else if f := pol1.TimeToFinalize; f.Count != 3 || f.P50 != 20 || f.Max != 40 {
+		t.Errorf("wrong time to finalize for org1/pol1: %v", f)
+	}
+
+	// A policy with no finalized agreements has no finalize times.
+	org2 := report.Policies[2]
+	if org2.TimedOut != 0 || org2.TimeToFinalize.Count != 0 || org2.TerminationReasons[0].Reason != basicprotocol.AB_CANCEL_NEGATIVE_REPLY {
+		t.Errorf("wrong stats for org2/pol1: %v", org2)
+	}
+}
+
+func Test_OrgPolAFilter(t *testing.T) {
+
+	ag := persistence.Agreement{Org: "org1", PolicyName: "pol1"}
+
+	if !persistence.OrgPolAFilter("", "")(ag) || !persistence.OrgPolAFilter("org1", "")(ag) || !persistence.OrgPolAFilter("", "pol1")(ag) || !persistence.OrgPolAFilter("org1", "pol1")(ag) {
+		t.Errorf("agreement %v should pass the filter", ag)
+	} else if persistence.OrgPolAFilter("org2", "")(ag) || persistence.OrgPolAFilter("org1", "pol2")(ag) {
+		t.Errorf("agreement %v should not pass the filter", ag)
+	}
+}
+
+func Test_GetAgreementStats_bolt(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "agbot-stats-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := new(bolt.AgbotBoltDB)
+	if err := db.Initialize(&config.HorizonConfig{AgreementBot: config.AGConfig{DBPath: dir}}); err != nil {
+		t.Fatalf("unable to initialize database, error: %v", err)
+	}
+	defer db.Close()
+
+	// An active agreement and an archived one that timed out in org1, and an active agreement in org2.
+	for _, ag := range [][]string{{"ag1", "org1", "org1/dev1", "pol1"}, {"ag2", "org1", "org1/dev2", "pol2"}, {"ag3", "org2", "org2/dev3", "pol1"}} {
+		if err := db.AgreementAttempt(ag[0], ag[1], ag[2], ag[3], "", "", "", policy.BasicProtocol, "", policy.NodeHealth{}); err != nil {
+			t.Fatalf("unable to create agreement %v, error: %v", ag[0], err)
+		}
+	}
+	if _, err := persistence.ArchiveAgreement(db, "ag2", policy.BasicProtocol, basicprotocol.AB_CANCEL_NO_REPLY, "no reply"); err != nil {
+		t.Fatalf("unable to archive agreement, error: %v", err)
+	}
+
+	report, err := db.GetAgreementStats("", "", GetTimeoutTerminationCodes())
+	if err != nil {
+		t.Fatalf("unable to get agreement stats, error: %v", err)
+	} else if len(report.Orgs) != 2 || len(report.Policies) != 3 {
+		t.Fatalf("wrong orgs or policies in report: %v", report)
+	} else if org1 := report.Orgs[0]; org1.Agreements != 2 || org1.Active != 1 || org1.Terminated != 1 || org1.TimedOut != 1 {
+		t.Errorf("wrong counts for org1: %v", org1)
+	}
+
+	// The org and policy limit the agreements counted.
+	report, err = db.GetAgreementStats("org1", "pol2", GetTimeoutTerminationCodes())
+	if err != nil {
+		t.Fatalf("unable to get agreement stats, error: %v", err)
+	} else if len(report.Orgs) != 1 || len(report.Policies) != 1 || report.Policies[0].PolicyName != "pol2" {
+		t.Fatalf("wrong orgs or policies in report: %v", report)
+	} else if pol2 := report.Policies[0]; pol2.Agreements != 1 || pol2.Active != 0 || pol2.Terminated != 1 {
+		t.Errorf("wrong counts for org1/pol2: %v", pol2)
+	}
+
+	report, err = db.GetAgreementStats("", "pol1", GetTimeoutTerminationCodes())
+	if err != nil {
+		t.Fatalf("unable to get agreement stats, error: %v", err)
+	} else if len(report.Orgs) != 2 || len(report.Policies) != 2 || report.Orgs[0].Active != 1 || report.Orgs[1].Active != 1 {
+		t.Errorf("wrong report for pol1: %v", report)
+	}
+}
@@ -31,6 +31,7 @@ const GOVERN_BC_NEEDS = "AgBotGovernBlockchain"
 const POLICY_WATCHER = "AgBotPolicyWatcher"
 const GENERATE_POLICY = "AgBotPolicyGenerator"
 const STALE_PARTITIONS = "AgbotStaleDatabasePartition"
+const GOVERN_ROLLOUTS = "AgBotGovernRollouts"
 
 // Agreement governance timing state. Used in the GovernAgreements subworker.
 type DVState struct {
@@ -48,6 +49,7 @@ type AgreementBotWorker struct {
 	ready             bool
 	PatternManager    *PatternManager
 	NHManager         *NodeHealthManager
+	SearchCache       *NodeSearchCache
 	GovTiming         DVState
 	lastExchVerCheck  int64
 	shutdownStarted   bool
@@ -66,6 +68,7 @@ func NewAgreementBotWorker(name string, cfg *config.HorizonConfig, db persistenc
 		ready:            false,
 		PatternManager:   NewPatternManager(),
 		NHManager:        NewNodeHealthManager(),
+		SearchCache:      NewNodeSearchCache(cfg.AgreementBot.FullSearchIntervalS),
 		GovTiming:        DVState{},
 		lastExchVerCheck: 0,
 		shutdownStarted:  false,
@@ -96,7 +99,7 @@ func (w *AgreementBotWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.ACCOUNT_FUNDED:
 			cmd := NewAccountFundedCommand(msg)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 	case *events.BlockchainClientInitializedMessage:
@@ -104,7 +107,7 @@ func (w *AgreementBotWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.BC_CLIENT_INITIALIZED:
 			cmd := NewClientInitializedCommand(msg)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 	case *events.BlockchainClientStoppingMessage:
@@ -112,7 +115,7 @@ func (w *AgreementBotWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.BC_CLIENT_STOPPING:
 			cmd := NewClientStoppingCommand(msg)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 	case *events.EthBlockchainEventMessage:
@@ -121,7 +124,7 @@ func (w *AgreementBotWorker) NewEvent(incoming events.Message) {
 			switch msg.Event().Id {
 			case events.BC_EVENT:
 				agCmd := NewBlockchainEventCommand(*msg)
-				w.Commands <- agCmd
+				w.Commands <- w.TraceCommand(incoming, agCmd)
 			}
 		}
 
@@ -131,7 +134,7 @@ func (w *AgreementBotWorker) NewEvent(incoming events.Message) {
 			switch msg.Event().Id {
 			case events.AGREEMENT_ENDED:
 				agCmd := NewAgreementTimeoutCommand(msg.AgreementId, msg.AgreementProtocol, w.consumerPH[msg.AgreementProtocol].GetTerminationCode(TERM_REASON_USER_REQUESTED))
-				w.Commands <- agCmd
+				w.Commands <- w.TraceCommand(incoming, agCmd)
 			}
 		}
 
@@ -141,7 +144,7 @@ func (w *AgreementBotWorker) NewEvent(incoming events.Message) {
 			switch msg.Event().Id {
 			case events.CHANGED_POLICY:
 				pcCmd := NewPolicyChangedCommand(*msg)
-				w.Commands <- pcCmd
+				w.Commands <- w.TraceCommand(incoming, pcCmd)
 			}
 		}
 
@@ -151,7 +154,7 @@ func (w *AgreementBotWorker) NewEvent(incoming events.Message) {
 			switch msg.Event().Id {
 			case events.DELETED_POLICY:
 				pdCmd := NewPolicyDeletedCommand(*msg)
-				w.Commands <- pdCmd
+				w.Commands <- w.TraceCommand(incoming, pdCmd)
 			}
 		}
 
@@ -161,7 +164,7 @@ func (w *AgreementBotWorker) NewEvent(incoming events.Message) {
 			switch msg.Event().Id {
 			case events.WORKLOAD_UPGRADE:
 				wuCmd := NewWorkloadUpgradeCommand(*msg)
-				w.Commands <- wuCmd
+				w.Commands <- w.TraceCommand(incoming, wuCmd)
 			}
 		}
 
@@ -169,17 +172,17 @@ func (w *AgreementBotWorker) NewEvent(incoming events.Message) {
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
 		case events.UNCONFIGURE_COMPLETE:
-			w.Commands <- worker.NewBeginShutdownCommand()
-			w.Commands <- worker.NewTerminateCommand("shutdown")
+			w.Commands <- w.TraceCommand(incoming, worker.NewBeginShutdownCommand())
+			w.Commands <- w.TraceCommand(incoming, worker.NewTerminateCommand("shutdown"))
 		case events.AGBOT_QUIESCE_COMPLETE:
-			w.Commands <- worker.NewTerminateCommand("shutdown")
+			w.Commands <- w.TraceCommand(incoming, worker.NewTerminateCommand("shutdown"))
 		}
 
 	case *events.NodeShutdownMessage:
 		msg, _ := incoming.(*events.NodeShutdownMessage)
 		switch msg.Event().Id {
 		case events.START_AGBOT_QUIESCE:
-			w.Commands <- NewAgbotShutdownCommand(msg)
+			w.Commands <- w.TraceCommand(incoming, NewAgbotShutdownCommand(msg))
 		}
 
 	default: //nothing
@@ -291,6 +294,7 @@ func (w *AgreementBotWorker) Initialize() bool {
 	w.DispatchSubworker(GOVERN_AGREEMENTS, w.GovernAgreements, int(w.BaseWorker.Manager.Config.AgreementBot.ProcessGovernanceIntervalS))
 	w.DispatchSubworker(GOVERN_ARCHIVED_AGREEMENTS, w.GovernArchivedAgreements, 1800)
 	w.DispatchSubworker(GOVERN_BC_NEEDS, w.GovernBlockchainNeeds, 60)
+	w.DispatchSubworker(GOVERN_ROLLOUTS, w.GovernRollouts, int(w.BaseWorker.Manager.Config.AgreementBot.ProcessGovernanceIntervalS))
 	if w.Config.AgreementBot.CheckUpdatedPolicyS != 0 {
 		// Use custom subworker APIs for the policy watcher because it is stateful and already does its own time management.
 		ch := w.AddSubworker(POLICY_WATCHER)
@@ -333,6 +337,9 @@ func (w *AgreementBotWorker) CommandHandler(command worker.Command) bool {
 			w.pm.UpdatePolicy(cmd.Msg.Org(), pol)
 			glog.V(5).Infof("AgreementBotWorker updated policy in PM.")
 
+			// Nodes that were skipped under the old policy need to be looked at again.
+			w.SearchCache.Invalidate(cmd.Msg.Org(), pol.Header.Name)
+
 			for _, agp := range pol.AgreementProtocols {
 				// Update the protocol handler map and make sure there are workers available if the policy has a new protocol in it.
 				if _, ok := w.consumerPH[agp.Name]; !ok {
@@ -365,6 +372,7 @@ func (w *AgreementBotWorker) CommandHandler(command worker.Command) bool {
 			// Update the policy in the policy manager.
 			w.pm.DeletePolicy(cmd.Msg.Org(), pol)
 			glog.V(5).Infof("AgreementBotWorker deleted policy from PM.")
+			w.SearchCache.Invalidate(cmd.Msg.Org(), pol.Header.Name)
 
 			// Queue the command to the correct protocol worker pool(s) for further processing. The deleted policy
 			// might not contain a supported protocol, so we need to check that first.
@@ -542,27 +550,16 @@ func (w *AgreementBotWorker) findAndMakeAgreements() {
 		// Get a copy of all policies in the policy manager so that we can safely iterate the list
 		policies := w.pm.GetAllAvailablePolicies(org)
 		for _, consumerPolicy := range policies {
-			if devices, err := w.searchExchange(&consumerPolicy, org); err != nil {
+			changedSince, searchTime := w.SearchCache.StartSearch(org, consumerPolicy.Header.Name)
+			if devices, err := w.searchExchange(&consumerPolicy, org, changedSince); err != nil {
 				glog.Errorf("AgreementBotWorker received error searching for %v, error: %v", &consumerPolicy, err)
 			} else {
 
-				for _, dev := range *devices {
-
-					glog.V(3).Infof("AgreementBotWorker picked up %v for policy %v.", dev.ShortString(), consumerPolicy.Header.Name)
-					glog.V(5).Infof("AgreementBotWorker picked up %v", dev)
+				w.SearchCache.EndSearch(org, consumerPolicy.Header.Name, changedSince, searchTime)
 
-					// Check for agreements already in progress with this device
-					if found, err := w.alreadyMakingAgreementWith(&dev, &consumerPolicy); err != nil {
-						glog.Errorf("AgreementBotWorker received error trying to find pending agreements: %v", err)
-						continue
-					} else if found {
-						glog.V(5).Infof("AgreementBotWorker skipping device id %v, agreement attempt already in progress with %v", dev.Id, consumerPolicy.Header.Name)
-						continue
-					}
+				for _, dev := range w.SearchCache.AddInProgress(org, consumerPolicy.Header.Name, *devices) {
 
-					// If the device is not ready to make agreements yet, then skip it.
-					if len(dev.PublicKey) == 0 || string(dev.PublicKey) == "" {
-						glog.V(5).Infof("AgreementBotWorker skipping device id %v, node is not ready to exchange messages", dev.Id)
+					if w.skipDevice(org, &consumerPolicy, &dev) {
 						continue
 					}
 
@@ -583,15 +580,18 @@ func (w *AgreementBotWorker) findAndMakeAgreements() {
 						// then merge them all together.
 						if producerPolicy, err = w.MergeAllProducerPolicies(&dev); err != nil {
 							glog.Errorf("AgreementBotWorker unable to merge service policies, error: %v", err)
+							w.SearchCache.Handled(org, consumerPolicy.Header.Name, &dev)
 							continue
 						} else if producerPolicy == nil {
 							glog.Errorf("AgreementBotWorker unable to create merged policy from producer %v", dev)
+							w.SearchCache.Handled(org, consumerPolicy.Header.Name, &dev)
 							continue
 						}
 
 						// Check to see if the device's merged policy is compatible with the consumer
 						if err := policy.Are_Compatible(producerPolicy, &consumerPolicy); err != nil {
 							glog.Errorf("AgreementBotWorker received error comparing %v and %v, error: %v", *producerPolicy, consumerPolicy, err)
+							w.SearchCache.Handled(org, consumerPolicy.Header.Name, &dev)
 							continue
 						}
 
@@ -614,6 +614,7 @@ func (w *AgreementBotWorker) findAndMakeAgreements() {
 						glog.Errorf("AgreementBotWorker protocol handler for %v not accepting new agreement commands.", protocol)
 					} else {
 						w.consumerPH[protocol].HandleMakeAgreement(cmd, w.consumerPH[protocol])
+						w.SearchCache.InProgress(org, consumerPolicy.Header.Name, &dev)
 						glog.V(5).Infof("AgreementBoWorker queued agreement attempt for policy %v and protocol %v", consumerPolicy.Header.Name, protocol)
 					}
 
@@ -624,6 +625,41 @@ func (w *AgreementBotWorker) findAndMakeAgreements() {
 	}
 }
 
+// Returns true when the device does not have to be considered for the policy on this pass. A device that was handled
+// for the policy and has not changed since is skipped. A device with an agreement in progress is skipped and remembered
+// as in progress in the node search cache, so that it is considered again on the next pass, instead of at the next full
+// search, even when the exchange search does not return it.
+func (w *AgreementBotWorker) skipDevice(org string, consumerPolicy *policy.Policy, dev *exchange.SearchResultDevice) bool {
+
+	// Skip the devices that were already handled for this policy and have not changed since.
+	if w.SearchCache.Unchanged(org, consumerPolicy.Header.Name, dev) {
+		glog.V(5).Infof("AgreementBotWorker skipping device id %v, unchanged since it was last handled for %v", dev.Id, consumerPolicy.Header.Name)
+		return true
+	}
+
+	glog.V(3).Infof("AgreementBotWorker picked up %v for policy %v.", dev.ShortString(), consumerPolicy.Header.Name)
+	glog.V(5).Infof("AgreementBotWorker picked up %v", dev)
+
+	// Check for agreements already in progress with this device
+	if found, err := w.alreadyMakingAgreementWith(dev, consumerPolicy); err != nil {
+		glog.Errorf("AgreementBotWorker received error trying to find pending agreements: %v", err)
+		return true
+	} else if found {
+		glog.V(5).Infof("AgreementBotWorker skipping device id %v, agreement attempt already in progress with %v", dev.Id, consumerPolicy.Header.Name)
+		w.SearchCache.InProgress(org, consumerPolicy.Header.Name, dev)
+		return true
+	}
+
+	// If the device is not ready to make agreements yet, then skip it. It will be picked up again when it
+	// updates its public key.
+	if len(dev.PublicKey) == 0 || string(dev.PublicKey) == "" {
+		glog.V(5).Infof("AgreementBotWorker skipping device id %v, node is not ready to exchange messages", dev.Id)
+		w.SearchCache.Handled(org, consumerPolicy.Header.Name, dev)
+		return true
+	}
+	return false
+}
+
 // Check all agreement protocol buckets to see if there are any agreements with this device.
 func (w *AgreementBotWorker) alreadyMakingAgreementWith(dev *exchange.SearchResultDevice, consumerPolicy *policy.Policy) (bool, error) {
 
@@ -643,11 +679,34 @@ func (w *AgreementBotWorker) alreadyMakingAgreementWith(dev *exchange.SearchResu
 		} else if len(agreements) != 0 {
 			return true, nil
 		}
+
+		// A node that recently failed its pre-flight checks for this policy is left alone until the retry interval has passed.
+		if cph, ok := w.consumerPH[agp]; !ok {
+			continue
+		} else if agreements, err := w.db.FindAgreements([]persistence.AFilter{persistence.ArchivedAFilter(), persistence.DevPolAFilter(dev.Id, consumerPolicy.Header.Name), w.preflightFailedFilter(cph)}, agp); err != nil {
+			glog.Errorf("AgreementBotWorker received error trying to find agreements rejected by pre-flight checks for protocol %v: %v", agp, err)
+		} else if len(agreements) != 0 {
+			glog.V(5).Infof("AgreementBotWorker skipping device %v for policy %v, it failed its pre-flight checks: %v", dev.Id, consumerPolicy.Header.Name, agreements[0].TerminatedDescription)
+			return true, nil
+		}
 	}
 	return false, nil
 
 }
 
+// A filter for the agreements that were rejected by the node's pre-flight checks within the retry interval.
+func (w *AgreementBotWorker) preflightFailedFilter(cph ConsumerProtocolHandler) persistence.AFilter {
+	reasons := map[uint]bool{
+		cph.GetTerminationCode(TERM_REASON_NODE_LACKS_DISK):   true,
+		cph.GetTerminationCode(TERM_REASON_NODE_LACKS_MEMORY): true,
+		cph.GetTerminationCode(TERM_REASON_NODE_LACKS_DEVICE): true,
+	}
+	since := uint64(time.Now().Unix()) - uint64(w.Config.AgreementBot.PreflightRetryS)
+	return func(a persistence.Agreement) bool {
+		return reasons[a.TerminatedReason] && a.AgreementTimedout > since
+	}
+}
+
 // Merge all the producer policies into 1 so that they can collectively be checked for compatibility against a consumer policy.
 // The list of microservices in a device object that comes back in a search only includes the microservices that we
 // searched for.
@@ -810,7 +869,9 @@ func DeleteMessage(msgId int, agbotId, agbotToken, exchangeURL string, httpClien
 // microservices. If the agbot is working with a policy file that was generated from a pattern, then it will do searches
 // by pattern. If the agbot is working with a manually created policy file, then it will do searches by list of
 // microservices.
-func (w *AgreementBotWorker) searchExchange(pol *policy.Policy, polOrg string) (*[]exchange.SearchResultDevice, error) {
+//
+// When changedSince is not zero, the exchange only returns the devices that have changed since that time.
+func (w *AgreementBotWorker) searchExchange(pol *policy.Policy, polOrg string, changedSince int64) (*[]exchange.SearchResultDevice, error) {
 
 	// If it is a pattern based policy, search by worload URL and pattern.
 	if pol.PatternId != "" {
@@ -827,6 +888,7 @@ func (w *AgreementBotWorker) searchExchange(pol *policy.Policy, polOrg string) (
 		ser.SecondsStale = w.Config.AgreementBot.ActiveDeviceTimeoutS
 		ser.NodeOrgIds = nodeOrgs
 		ser.ServiceURL = cutil.FormOrgSpecUrl(pol.Workloads[0].WorkloadURL, pol.Workloads[0].Org)
+		ser.ChangedSince = changedSince
 
 		// Invoke the exchange
 		var resp interface{}
@@ -893,6 +955,7 @@ func (w *AgreementBotWorker) searchExchange(pol *policy.Policy, polOrg string) (
 		ser := exchange.CreateSearchMSRequest()
 		ser.SecondsStale = w.Config.AgreementBot.ActiveDeviceTimeoutS
 		ser.DesiredServices = desiredMS
+		ser.ChangedSince = changedSince
 
 		// Invoke the exchange
 		var resp interface{}
@@ -984,7 +1047,7 @@ func (w *AgreementBotWorker) syncOnInit() error {
 							glog.Errorf(AWlogString(fmt.Sprintf("error marking agreement %v terminated: %v", ag.CurrentAgreementId, err)))
 						}
 						w.consumerPH[agp].HandleAgreementTimeout(NewAgreementTimeoutCommand(ag.CurrentAgreementId, ag.AgreementProtocol, w.consumerPH[agp].GetTerminationCode(TERM_REASON_POLICY_CHANGED)), w.consumerPH[agp])
-					} else if err := w.pm.MatchesMine(ag.Org, pol); err != nil {
+					} else if err := w.pm.MatchesMine(ag.Org, pol); err != nil && !w.rolloutActive(ag.Org, pol.Header.Name) {
 						glog.Warningf(AWlogString(fmt.Sprintf("agreement %v has a policy %v that has changed: %v", ag.CurrentAgreementId, pol.Header.Name, err)))
 
 						// Remove any workload usage records (non-HA) or mark for pending upgrade (HA). There might not be a workload usage record
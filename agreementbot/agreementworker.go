@@ -545,7 +545,13 @@ func (b *BaseAgreementWorker) HandleAgreementReply(cph ConsumerProtocolHandler,
 	} else {
 		glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("received rejection from producer %v", reply)))
 
-		b.CancelAgreement(cph, reply.AgreementId(), cph.GetTerminationCode(TERM_REASON_NEGATIVE_REPLY), workerId)
+		// A producer that failed its pre-flight checks says why, and the reason is kept so that the node is not
+		// proposed to again until it has had time to free up its resources.
+		reason := cph.GetTerminationCode(TERM_REASON_NEGATIVE_REPLY)
+		if reply.RejectReason() != 0 {
+			reason = reply.RejectReason()
+		}
+		b.CancelAgreement(cph, reply.AgreementId(), reason, workerId)
 	}
 
 	// Get rid of the lock
@@ -611,6 +617,7 @@ func (b *BaseAgreementWorker) HandleWorkloadUpgrade(cph ConsumerProtocolHandler,
 	// If there is no agreement id specified then find one for the current device and policy name. If we find one,
 	// grab the agreement id lock, cancel the agreement and delete the workload usage record.
 
+	deferred := false
 	if wi.AgreementId == "" {
 		if ags, err := b.db.FindAgreements([]persistence.AFilter{persistence.DevPolAFilter(wi.Device, wi.PolicyName)}, cph.Name()); err != nil {
 			glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error finding agreement for device %v and policyName %v, error: %v", wi.Device, wi.PolicyName, err)))
@@ -621,15 +628,33 @@ func (b *BaseAgreementWorker) HandleWorkloadUpgrade(cph ConsumerProtocolHandler,
 			// highest priority workload is being used when creating a new workload usage record.
 			glog.V(5).Infof(BAWlogstring(workerId, fmt.Sprintf("forced workload upgrade found no current agreement for device %v and policy name %v", wi.Device, wi.PolicyName)))
 		} else {
-			// Cancel all agreements
+			// Cancel all agreements, unless a maintenance window says the workload cannot be disrupted yet.
 			for _, ag := range ags {
+				if !ag.Archived && deferDisruption(b.db, b.pm, &ag, cph, TERM_REASON_CANCEL_FORCED_UPGRADE) {
+					deferred = true
+					continue
+				}
 				// Terminate the agreement
 				b.CancelAgreementWithLock(cph, ag.CurrentAgreementId, cph.GetTerminationCode(TERM_REASON_CANCEL_FORCED_UPGRADE), workerId)
 			}
 		}
 	} else {
+		if ag, err := b.db.FindSingleAgreementByAgreementId(wi.AgreementId, cph.Name(), []persistence.AFilter{persistence.UnarchivedAFilter()}); err != nil {
+			glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error finding agreement %v, error: %v", wi.AgreementId, err)))
+		} else if ag != nil {
+			deferred = deferDisruption(b.db, b.pm, ag, cph, TERM_REASON_CANCEL_FORCED_UPGRADE)
+		}
+
 		// Terminate the agreement
-		b.CancelAgreementWithLock(cph, wi.AgreementId, cph.GetTerminationCode(TERM_REASON_CANCEL_FORCED_UPGRADE), workerId)
+		if !deferred {
+			b.CancelAgreementWithLock(cph, wi.AgreementId, cph.GetTerminationCode(TERM_REASON_CANCEL_FORCED_UPGRADE), workerId)
+		}
+	}
+
+	// A deferred upgrade is queued again by governance when the maintenance window opens. Until then the device keeps
+	// running its current workload, so the workload usage record is left as it is.
+	if deferred {
+		return
 	}
 
 	// Find the workload usage record and delete it. This will cause any new agreement negotiations to start with the highest priority
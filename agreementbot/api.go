@@ -10,15 +10,20 @@ import (
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/worker"
 	"io/ioutil"
 	"net/http"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+var agbotAgreementCount = metrics.NewGaugeVec("anax_agbot_agreements", "Number of agreements in the agbot database, by partition and state.", "partition", "state")
+
 type API struct {
 	worker.Manager // embedded field
 	name           string
@@ -46,6 +51,10 @@ func NewAPIListener(name string, config *config.HorizonConfig, db persistence.Ag
 		em:   events.NewEventStateManager(),
 	}
 
+	if db != nil {
+		metrics.GetDefaultRegistry().AddCollector("agbot_agreements", listener.collectAgreementMetrics)
+	}
+
 	listener.listen(config.AgreementBot.APIListen)
 	return listener
 }
@@ -172,16 +181,24 @@ func (a *API) listen(apiListen string) {
 		router := mux.NewRouter()
 
 		router.HandleFunc("/agreement", a.agreement).Methods("GET", "OPTIONS")
+		router.HandleFunc("/agreement/stats", a.agreementstats).Methods("GET", "OPTIONS")
 		router.HandleFunc("/agreement/{id}", a.agreement).Methods("GET", "DELETE", "OPTIONS")
 		router.HandleFunc("/partition", a.partition).Methods("GET", "OPTIONS")
 		router.HandleFunc("/policy", a.policy).Methods("GET", "OPTIONS")
+		router.HandleFunc("/policy/evaluate", a.policyevaluate).Methods("POST", "OPTIONS")
 		router.HandleFunc("/policy/{org}", a.policy).Methods("GET", "OPTIONS")
-		router.HandleFunc("/policy/{org}/{name}", a.policy).Methods("GET", "OPTIONS")
+		router.HandleFunc("/policy/{org}/{name}", a.policy).Methods("GET", "PUT", "DELETE", "OPTIONS")
 		router.HandleFunc("/policy/{name}/upgrade", a.policy).Methods("POST", "OPTIONS")
+		router.HandleFunc("/policy/{org}/{name}/{action}", a.policyaction).Methods("POST", "OPTIONS")
 		router.HandleFunc("/workloadusage", a.workloadusage).Methods("GET", "OPTIONS")
+		router.HandleFunc("/rollout", a.rollout).Methods("GET", "POST", "OPTIONS")
+		router.HandleFunc("/rollout/{id}", a.rollout).Methods("GET", "DELETE", "OPTIONS")
+		router.HandleFunc("/rollout/{id}/{action}", a.rolloutaction).Methods("POST", "OPTIONS")
 		router.HandleFunc("/status", a.status).Methods("GET", "OPTIONS")
 		router.HandleFunc("/status/workers", a.workerstatus).Methods("GET", "OPTIONS")
+		router.HandleFunc("/status/events", a.eventstatus).Methods("GET", "OPTIONS")
 		router.HandleFunc("/node", a.node).Methods("GET", "DELETE", "OPTIONS")
+		router.HandleFunc("/metrics", metrics.Handler).Methods("GET", "OPTIONS")
 
 		http.ListenAndServe(apiListen, nocache(router))
 	}()
@@ -277,6 +294,30 @@ func (a *API) agreement(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Returns agreement statistics computed from the active and archived agreements, grouped by org and by policy. The
+// statistics can be limited to a single org or policy with the org and policy query parameters.
+func (a *API) agreementstats(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case "GET":
+		org := r.URL.Query().Get("org")
+		policyName := r.URL.Query().Get("policy")
+
+		if stats, err := a.db.GetAgreementStats(org, policyName, GetTimeoutTerminationCodes()); err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error computing agreement stats, error: %v", err)))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		} else {
+			writeResponse(w, stats, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (a *API) policy(w http.ResponseWriter, r *http.Request) {
 
 	serviceResolver := func(wURL string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, error) {
@@ -417,6 +458,311 @@ func (a *API) policy(w http.ResponseWriter, r *http.Request) {
 		a.Messages() <- events.NewABApiWorkloadUpgradeMessage(events.WORKLOAD_UPGRADE, protocol, upgrade.AgreementId, upgrade.Device, policyName)
 		w.WriteHeader(http.StatusOK)
 
+	case "PUT":
+		pathVars := mux.Vars(r)
+		org := pathVars["org"]
+		name := pathVars["name"]
+
+		if !a.authenticateOrgAdmin(w, r, org) || !a.watchingPolicyFiles(w) {
+			return
+		} else if !isValidPolicyFileName(name) {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "name", Error: fmt.Sprintf("policy name %v cannot contain a / or begin with a .", name)})
+			return
+		}
+		glog.V(3).Infof(APIlogString(fmt.Sprintf("handling PUT of policy %v/%v", org, name)))
+
+		// Demarshal the input body and verify it.
+		var pol policy.Policy
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &pol); err != nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "body", Error: fmt.Sprintf("user submitted data couldn't be deserialized to struct: %v. Error: %v", string(body), err)})
+			return
+		}
+
+		if pol.Header.Name == "" {
+			pol.Header.Name = name
+		} else if pol.Header.Name != name {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "header.name", Error: fmt.Sprintf("policy header name %v does not match %v", pol.Header.Name, name)})
+			return
+		}
+		if pol.Header.Version == "" {
+			pol.Header.Version = policy.CurrentVersion
+		}
+
+		if pol.PatternId != "" {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "patternId", Error: "policies for patterns are generated from the exchange, patternId cannot be set"})
+			return
+		} else if err := pol.Is_Self_Consistent(nil, serviceResolver); err != nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "body", Error: fmt.Sprintf("policy is not self consistent, error: %v", err)})
+			return
+		}
+
+		pm, err := policy.Initialize(a.Config.AgreementBot.PolicyPath, a.Config.ArchSynonyms, serviceResolver, false, false)
+		if err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error initializing policy manager, error: %v", err)))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// An existing policy is rewritten in its own file, so that the policy file watcher sees it as changed.
+		fileName, existing := getLocalPolicyFile(pm, org, name)
+		if existing != nil && existing.PatternId != "" {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "name", Error: fmt.Sprintf("policy %v is generated from pattern %v and cannot be changed", name, existing.PatternId)})
+			return
+		} else if existing != nil {
+			pol.Paused = existing.Paused
+		}
+
+		if fileName == "" {
+			fileName = name
+		}
+		if _, err := policy.CreatePolicyFile(a.Config.AgreementBot.PolicyPath, org, fileName, &pol); err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error writing policy %v/%v, error: %v", org, name, err)))
+			w.WriteHeader(http.StatusInternalServerError)
+		} else if existing != nil {
+			glog.V(3).Infof(APIlogString(fmt.Sprintf("updated policy %v/%v", org, name)))
+			writeResponse(w, pol, http.StatusOK)
+		} else {
+			glog.V(3).Infof(APIlogString(fmt.Sprintf("created policy %v/%v", org, name)))
+			writeResponse(w, pol, http.StatusCreated)
+		}
+
+	case "DELETE":
+		pathVars := mux.Vars(r)
+		org := pathVars["org"]
+		name := pathVars["name"]
+
+		if !a.authenticateOrgAdmin(w, r, org) || !a.watchingPolicyFiles(w) {
+			return
+		}
+		glog.V(3).Infof(APIlogString(fmt.Sprintf("handling DELETE of policy %v/%v", org, name)))
+
+		pm, err := policy.Initialize(a.Config.AgreementBot.PolicyPath, a.Config.ArchSynonyms, serviceResolver, false, false)
+		if err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error initializing policy manager, error: %v", err)))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// The agreements made with the policy are cancelled once the policy file watcher sees the file is gone.
+		if fileName, existing := getLocalPolicyFile(pm, org, name); existing == nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "name", Error: "policy not found."})
+		} else if existing.PatternId != "" {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "name", Error: fmt.Sprintf("policy %v is generated from pattern %v and cannot be deleted", name, existing.PatternId)})
+		} else if err := policy.DeletePolicyFile(fmt.Sprintf("%v%v/%v.policy", a.Config.AgreementBot.PolicyPath, org, fileName)); err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error deleting policy %v/%v, error: %v", org, name, err)))
+			w.WriteHeader(http.StatusInternalServerError)
+		} else {
+			glog.V(3).Infof(APIlogString(fmt.Sprintf("deleted policy %v/%v", org, name)))
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, POST, PUT, DELETE, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Pause or resume a local policy. A paused policy stays in the policy file, and the agreements already made with it
+// carry on, but no new agreements are made with it until it is resumed.
+func (a *API) policyaction(w http.ResponseWriter, r *http.Request) {
+
+	serviceResolver := func(wURL string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, error) {
+		asl, _, err := exchange.GetHTTPServiceResolverHandler(a)(wURL, wOrg, wVersion, wArch)
+		if err != nil {
+			glog.Errorf(APIlogString(fmt.Sprintf("unable to resolve %v %v, error %v", wURL, wOrg, err)))
+		}
+		return asl, err
+	}
+
+	switch r.Method {
+	case "POST":
+		pathVars := mux.Vars(r)
+		org := pathVars["org"]
+		name := pathVars["name"]
+		action := pathVars["action"]
+
+		if action != POLICY_ACTION_PAUSE && action != POLICY_ACTION_RESUME {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "action", Error: fmt.Sprintf("action %v must be one of %v or %v", action, POLICY_ACTION_PAUSE, POLICY_ACTION_RESUME)})
+			return
+		} else if !a.authenticateOrgAdmin(w, r, org) || !a.watchingPolicyFiles(w) {
+			return
+		}
+
+		pm, err := policy.Initialize(a.Config.AgreementBot.PolicyPath, a.Config.ArchSynonyms, serviceResolver, false, false)
+		if err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error initializing policy manager, error: %v", err)))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		fileName, existing := getLocalPolicyFile(pm, org, name)
+		if existing == nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "name", Error: "policy not found."})
+			return
+		} else if existing.PatternId != "" {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "name", Error: fmt.Sprintf("policy %v is generated from pattern %v and cannot be paused or resumed", name, existing.PatternId)})
+			return
+		}
+
+		// Rewriting the file only when the state changes avoids a needless policy change event.
+		paused := action == POLICY_ACTION_PAUSE
+		if existing.Paused != paused {
+			existing.Paused = paused
+			if _, err := policy.CreatePolicyFile(a.Config.AgreementBot.PolicyPath, org, fileName, existing); err != nil {
+				glog.Error(APIlogString(fmt.Sprintf("error writing policy %v/%v, error: %v", org, name, err)))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			glog.V(3).Infof(APIlogString(fmt.Sprintf("policy %v/%v %v requested", org, name, action)))
+		}
+		writeResponse(w, *existing, http.StatusOK)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Find the file holding a policy, by the name in its header or by its file name. The file name is returned without
+// the .policy suffix. Returns nil when the policy is not found.
+func getLocalPolicyFile(pm *policy.PolicyManager, org string, name string) (string, *policy.Policy) {
+	if !pm.WatcherContent.HasOrg(org) {
+		return "", nil
+	}
+	for fileName, we := range pm.WatcherContent.AllWatches[org] {
+		if we.Pol.Header.Name == name || fileName == name+".policy" {
+			return strings.TrimSuffix(fileName, ".policy"), we.Pol
+		}
+	}
+	return "", nil
+}
+
+// A policy name is used as the name of its file in the org's directory.
+func isValidPolicyFileName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "/\\") && !strings.HasPrefix(name, ".")
+}
+
+// Local policies are changed by writing their files, the change takes effect when the policy file watcher sees it. When the
+// watcher is turned off the change would never take effect, so the error response is written and false is returned.
+func (a *API) watchingPolicyFiles(w http.ResponseWriter) bool {
+	if a.Config.AgreementBot.CheckUpdatedPolicyS == 0 {
+		writeInputErr(w, http.StatusServiceUnavailable, &APIUserInputError{Input: "policy", Error: "the agbot is not watching its policy files, set CheckUpdatedPolicyS in the agbot configuration to change local policies"})
+		return false
+	}
+	return true
+}
+
+// Verify that the request carries the credentials of an admin user in the given org, in the form org/user:password.
+// The credentials are checked by the exchange. When they are not valid, the error response is written and false is
+// returned.
+func (a *API) authenticateOrgAdmin(w http.ResponseWriter, r *http.Request, org string) bool {
+
+	user, pw, ok := r.BasicAuth()
+	if !ok || exchange.GetId(user) == "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="agbot"`)
+		writeInputErr(w, http.StatusUnauthorized, &APIUserInputError{Input: "credentials", Error: "exchange user credentials are required, in the form org/user:password"})
+		return false
+	} else if exchange.GetOrg(user) != org {
+		writeInputErr(w, http.StatusForbidden, &APIUserInputError{Input: "credentials", Error: fmt.Sprintf("user %v is not in organization %v", user, org)})
+		return false
+	}
+
+	if u, err := exchange.GetUser(a.GetHTTPFactory(), org, exchange.GetId(user), a.GetExchangeURL(), user, pw); err != nil {
+		glog.Warningf(APIlogString(fmt.Sprintf("unable to verify user %v, error: %v", user, err)))
+		w.Header().Set("WWW-Authenticate", `Basic realm="agbot"`)
+		writeInputErr(w, http.StatusUnauthorized, &APIUserInputError{Input: "credentials", Error: fmt.Sprintf("unable to verify user %v with the exchange", user)})
+		return false
+	} else if !u.Admin {
+		writeInputErr(w, http.StatusForbidden, &APIUserInputError{Input: "credentials", Error: fmt.Sprintf("user %v is not an admin of organization %v", user, org)})
+		return false
+	}
+	return true
+}
+
+// Explain whether a consumer policy is compatible with a node, without making an agreement.
+func (a *API) policyevaluate(w http.ResponseWriter, r *http.Request) {
+
+	resource := "policy/evaluate"
+
+	serviceResolver := func(wURL string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, error) {
+		asl, _, err := exchange.GetHTTPServiceResolverHandler(a)(wURL, wOrg, wVersion, wArch)
+		if err != nil {
+			glog.Errorf(APIlogString(fmt.Sprintf("unable to resolve %v %v, error %v", wURL, wOrg, err)))
+		}
+		return asl, err
+	}
+
+	switch r.Method {
+	case "POST":
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		var input PolicyEvaluationRequest
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &input); err != nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "body", Error: fmt.Sprintf("user submitted data couldn't be deserialized to struct: %v. Error: %v", string(body), err)})
+			return
+		} else if ok, msg := input.IsValid(); !ok {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "body", Error: msg})
+			return
+		}
+
+		pm, err := policy.Initialize(a.Config.AgreementBot.PolicyPath, a.Config.ArchSynonyms, serviceResolver, false, false)
+		if err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error initializing policy manager, error: %v", err)))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// The consumer policy is either in the input or served by this agbot. A served policy can be named by the name
+		// in its header or by its file name.
+		consumer := input.ConsumerPolicy
+		if consumer == nil {
+			if pol := pm.GetPolicy(input.PolicyOrg, input.PolicyName); pol != nil {
+				consumer = pol
+			} else if name := pm.WatcherContent.GetPolicyName(input.PolicyOrg, input.PolicyName); name != "" {
+				consumer = pm.GetPolicy(input.PolicyOrg, name)
+			}
+			if consumer == nil {
+				writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "policyName", Error: fmt.Sprintf("policy %v is not served by this agbot in org %v", input.PolicyName, input.PolicyOrg)})
+				return
+			}
+		}
+
+		// The producer policies are either in the input or come from the node's registered services.
+		producers := input.ProducerPolicies
+		var nodeStep *PolicyEvaluationStep
+		if input.NodeId != "" {
+			dev, err := GetDevice(a.GetHTTPFactory().NewHTTPClient(nil), input.NodeId, a.GetExchangeURL(), a.GetExchangeId(), a.GetExchangeToken())
+			if err != nil {
+				writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "nodeId", Error: fmt.Sprintf("unable to get node %v from the exchange, error: %v", input.NodeId, err)})
+				return
+			}
+
+			required := append(policy.APISpecList{}, consumer.APISpecs...)
+			for _, workload := range consumer.Workloads {
+				if asl, err := serviceResolver(workload.WorkloadURL, workload.Org, workload.Version, workload.Arch); err == nil {
+					required = append(required, *asl...)
+				}
+			}
+
+			pols, step := NodeProducerPolicies(dev, required)
+			producers = pols
+			nodeStep = &step
+		}
+
+		eval := EvaluatePolicies(pm, producers, consumer, a.Config.ArchSynonyms, serviceResolver)
+		if nodeStep != nil {
+			eval.AddInputStep(*nodeStep)
+		}
+
+		glog.V(5).Infof(APIlogString(fmt.Sprintf("Evaluated policy %v, compatible: %v, failed checks: %v", consumer.Header.Name, eval.Compatible, eval.FailedChecks)))
+		writeResponse(w, eval, http.StatusOK)
+
 	case "OPTIONS":
 		w.Header().Set("Allow", "POST, OPTIONS")
 		w.WriteHeader(http.StatusOK)
@@ -449,6 +795,200 @@ func (a *API) workloadusage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (a *API) rollout(w http.ResponseWriter, r *http.Request) {
+
+	serviceResolver := func(wURL string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, error) {
+		asl, _, err := exchange.GetHTTPServiceResolverHandler(a)(wURL, wOrg, wVersion, wArch)
+		if err != nil {
+			glog.Errorf(APIlogString(fmt.Sprintf("unable to resolve %v %v, error %v", wURL, wOrg, err)))
+		}
+		return asl, err
+	}
+
+	pathVars := mux.Vars(r)
+	id := pathVars["id"]
+
+	switch r.Method {
+	case "GET":
+		if id != "" {
+			if rid, err := strconv.ParseUint(id, 10, 64); err != nil {
+				writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "id", Error: fmt.Sprintf("rollout id %v is not a number", id)})
+			} else if ro, err := a.db.FindSingleRolloutById(rid); err != nil {
+				glog.Error(APIlogString(fmt.Sprintf("error finding rollout %v, error: %v", id, err)))
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			} else if ro == nil {
+				writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "id", Error: "rollout id not found"})
+			} else {
+				writeResponse(w, *ro, http.StatusOK)
+			}
+		} else {
+			if rollouts, err := a.db.FindRollouts([]persistence.RFilter{}); err != nil {
+				glog.Error(APIlogString(fmt.Sprintf("error finding all rollouts, error: %v", err)))
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			} else {
+				sort.Sort(RolloutsById(rollouts))
+				writeResponse(w, rollouts, http.StatusOK)
+			}
+		}
+
+	case "POST":
+		var input RolloutRequest
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &input); err != nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "body", Error: fmt.Sprintf("user submitted data couldn't be deserialized to struct: %v. Error: %v", string(body), err)})
+			return
+		} else if ok, msg := input.IsValid(); !ok {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "body", Error: msg})
+			return
+		} else if !a.authenticateOrgAdmin(w, r, input.Org) {
+			return
+		}
+
+		pm, err := policy.Initialize(a.Config.AgreementBot.PolicyPath, a.Config.ArchSynonyms, serviceResolver, false, false)
+		if err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error initializing policy manager, error: %v", err)))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// The policy can be named by the name in its header or by its file name. Agreements record the header name.
+		pol := pm.GetPolicy(input.Org, input.PolicyName)
+		if pol == nil {
+			if name := pm.WatcherContent.GetPolicyName(input.Org, input.PolicyName); name != "" {
+				pol = pm.GetPolicy(input.Org, name)
+			}
+		}
+		if pol == nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "policyName", Error: fmt.Sprintf("policy %v is not served by this agbot in org %v", input.PolicyName, input.Org)})
+			return
+		}
+
+		if active, err := persistence.FindActiveRollout(a.db, input.Org, pol.Header.Name); err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error finding active rollout for policy %v, error: %v", pol.Header.Name, err)))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		} else if active != nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "policyName", Error: fmt.Sprintf("rollout %v is already %v for policy %v", active.Id, active.State, pol.Header.Name)})
+			return
+		}
+
+		if ro, err := a.db.NewRollout(input.Org, pol.Header.Name, input.Version, input.BatchPercent, input.BatchCount, input.FailureThreshold, input.OnFailure, input.TimeoutS); err != nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "body", Error: err.Error()})
+		} else {
+			glog.V(3).Infof(APIlogString(fmt.Sprintf("started rollout %v", ro)))
+			writeResponse(w, *ro, http.StatusCreated)
+		}
+
+	case "DELETE":
+		if id == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// An active rollout has to be finished or rolled back before it can be removed, otherwise the devices it
+		// moved would be left between versions.
+		if rid, err := strconv.ParseUint(id, 10, 64); err != nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "id", Error: fmt.Sprintf("rollout id %v is not a number", id)})
+		} else if ro, err := a.db.FindSingleRolloutById(rid); err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error finding rollout %v, error: %v", id, err)))
+			w.WriteHeader(http.StatusInternalServerError)
+		} else if ro == nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "id", Error: "rollout id not found"})
+		} else if !a.authenticateOrgAdmin(w, r, ro.Org) {
+			return
+		} else if ro.State == persistence.ROLLOUT_RUNNING {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "id", Error: fmt.Sprintf("rollout %v is %v, pause it first", id, ro.State)})
+		} else if ro.State == persistence.ROLLOUT_ROLLING_BACK {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "id", Error: fmt.Sprintf("rollout %v is %v, wait for it to finish", id, ro.State)})
+		} else if err := a.db.DeleteRollout(rid); err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error deleting rollout %v, error: %v", id, err)))
+			w.WriteHeader(http.StatusInternalServerError)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, POST, DELETE, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Pause, resume or roll back a rollout.
+func (a *API) rolloutaction(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case "POST":
+		pathVars := mux.Vars(r)
+		id := pathVars["id"]
+		action := pathVars["action"]
+
+		// The states the rollout can be in for each action.
+		from := map[string][]string{
+			ROLLOUT_ACTION_PAUSE:    []string{persistence.ROLLOUT_RUNNING},
+			ROLLOUT_ACTION_RESUME:   []string{persistence.ROLLOUT_PAUSED},
+			ROLLOUT_ACTION_ROLLBACK: []string{persistence.ROLLOUT_RUNNING, persistence.ROLLOUT_PAUSED},
+		}
+
+		rid, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "id", Error: fmt.Sprintf("rollout id %v is not a number", id)})
+			return
+		} else if _, ok := from[action]; !ok {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "action", Error: fmt.Sprintf("action %v must be one of %v, %v or %v", action, ROLLOUT_ACTION_PAUSE, ROLLOUT_ACTION_RESUME, ROLLOUT_ACTION_ROLLBACK)})
+			return
+		}
+
+		ro, err := a.db.FindSingleRolloutById(rid)
+		if err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error finding rollout %v, error: %v", id, err)))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		} else if ro == nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "id", Error: "rollout id not found"})
+			return
+		} else if !a.authenticateOrgAdmin(w, r, ro.Org) {
+			return
+		}
+
+		allowed := false
+		for _, s := range from[action] {
+			if ro.State == s {
+				allowed = true
+			}
+		}
+		if !allowed {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "action", Error: fmt.Sprintf("rollout %v cannot %v, it is %v", id, action, ro.State)})
+			return
+		}
+
+		var updated *persistence.Rollout
+		switch action {
+		case ROLLOUT_ACTION_PAUSE:
+			updated, err = persistence.UpdateRolloutState(a.db, rid, persistence.ROLLOUT_PAUSED, "paused by the user")
+		case ROLLOUT_ACTION_RESUME:
+			updated, err = persistence.ResumeRollout(a.db, rid)
+		case ROLLOUT_ACTION_ROLLBACK:
+			updated, err = persistence.UpdateRolloutState(a.db, rid, persistence.ROLLOUT_ROLLING_BACK, "rollback requested by the user")
+		}
+
+		if err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error updating rollout %v, error: %v", id, err)))
+			w.WriteHeader(http.StatusInternalServerError)
+		} else {
+			glog.V(3).Infof(APIlogString(fmt.Sprintf("rollout %v %v requested", id, action)))
+			writeResponse(w, *updated, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (a *API) status(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
@@ -481,6 +1021,23 @@ func (a *API) workerstatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (a *API) eventstatus(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		traces, input, err := apicommon.GetEventTraces(r.URL.Query())
+		if err != nil {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: input, Error: err.Error()})
+			return
+		}
+		writeResponse(w, traces, http.StatusOK)
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (a *API) node(w http.ResponseWriter, r *http.Request) {
 
 	resource := "node"
@@ -616,6 +1173,26 @@ func (a *API) partition(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// A metrics collector that counts the active and archived agreements in each partition of the agbot database. It is
+// called each time the metrics are scraped.
+func (a *API) collectAgreementMetrics() {
+	partitions, err := a.db.FindPartitions()
+	if err != nil {
+		glog.Error(APIlogString(fmt.Sprintf("error finding all partitions for metrics, error: %v", err)))
+		return
+	}
+
+	agbotAgreementCount.Reset()
+	for _, p := range partitions {
+		if active, archived, err := a.db.GetAgreementCount(p); err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error finding agreement count in partition %v for metrics, error: %v", p, err)))
+		} else {
+			agbotAgreementCount.Set(float64(active), p, "active")
+			agbotAgreementCount.Set(float64(archived), p, "archived")
+		}
+	}
+}
+
 // ==========================================================================================
 // Utility functions used by many of the API endpoints.
 //
@@ -698,6 +1275,21 @@ func (s WorkloadUsagesByDeviceId) Less(i, j int) bool {
 	return s[i].DeviceId < s[j].DeviceId
 }
 
+// Helper functions for sorting rollouts
+type RolloutsById []persistence.Rollout
+
+func (s RolloutsById) Len() int {
+	return len(s)
+}
+
+func (s RolloutsById) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s RolloutsById) Less(i, j int) bool {
+	return s[i].Id < s[j].Id
+}
+
 // Log string prefix api
 var APIlogString = func(v interface{}) string {
 	return fmt.Sprintf("AgreementBotWorker API %v", v)
@@ -716,6 +1308,10 @@ func (b *UpgradeDevice) IsValid() (bool, string) {
 	return true, ""
 }
 
+// The actions of the POST /policy/{org}/{name}/{action} API.
+const POLICY_ACTION_PAUSE = "pause"
+const POLICY_ACTION_RESUME = "resume"
+
 // Utility functions used by all the http handlers for each API path.
 func serializeResponse(w http.ResponseWriter, payload interface{}) ([]byte, bool) {
 	glog.V(6).Infof(APIlogString(fmt.Sprintf("response payload before serialization (%T): %v", payload, payload)))
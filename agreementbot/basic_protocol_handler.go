@@ -155,8 +155,14 @@ func (c *BasicProtocolHandler) GetTerminationCode(reason string) uint {
 		return basicprotocol.AB_CANCEL_NODE_HEARTBEAT
 	case TERM_REASON_AG_MISSING:
 		return basicprotocol.AB_CANCEL_AG_MISSING
+	case TERM_REASON_NODE_LACKS_DISK:
+		return basicprotocol.CANCEL_PREFLIGHT_DISK
+	case TERM_REASON_NODE_LACKS_MEMORY:
+		return basicprotocol.CANCEL_PREFLIGHT_MEMORY
+	case TERM_REASON_NODE_LACKS_DEVICE:
+		return basicprotocol.CANCEL_PREFLIGHT_DEVICE
 	default:
-		return 999
+		return TERM_REASON_UNKNOWN_CODE
 	}
 }
 
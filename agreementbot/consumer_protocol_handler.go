@@ -38,6 +38,7 @@ type ConsumerProtocolHandler interface {
 	HandlePolicyChanged(cmd *PolicyChangedCommand, cph ConsumerProtocolHandler)
 	HandlePolicyDeleted(cmd *PolicyDeletedCommand, cph ConsumerProtocolHandler)
 	HandleWorkloadUpgrade(cmd *WorkloadUpgradeCommand, cph ConsumerProtocolHandler)
+	HandleDeferredAction(ag *persistence.Agreement, cph ConsumerProtocolHandler)
 	HandleMakeAgreement(cmd *MakeAgreementCommand, cph ConsumerProtocolHandler)
 	HandleStopProtocol(cph ConsumerProtocolHandler)
 	GetTerminationCode(reason string) uint
@@ -246,6 +247,13 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChanged(cmd *PolicyChangedComm
 			return func(e persistence.Agreement) bool { return e.AgreementCreationTime != 0 && e.AgreementTimedout == 0 }
 		}
 
+		// When a rollout is moving the devices using this policy to a new workload version, the rollout decides when each
+		// agreement is re-made.
+		rollout, err := persistence.FindActiveRollout(b.db, cmd.Msg.Org(), eventPol.Header.Name)
+		if err != nil {
+			glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("error searching database for rollouts of policy %v, error: %v", eventPol.Header.Name, err)))
+		}
+
 		if agreements, err := b.db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter(), InProgress()}, cph.Name()); err == nil {
 			for _, ag := range agreements {
 
@@ -256,48 +264,12 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChanged(cmd *PolicyChangedComm
 					// This agreement is using a policy different from the one that changed.
 					glog.V(5).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("policy change handler skipping agreement %v because it is using a policy that did not change.", ag.CurrentAgreementId)))
 					continue
+				} else if rollout != nil {
+					glog.V(5).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("policy change handler skipping agreement %v because rollout %v is upgrading the devices using the policy.", ag.CurrentAgreementId, rollout.Id)))
+					continue
 				} else if err := b.pm.MatchesMine(cmd.Msg.Org(), pol); err != nil {
 					glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("agreement %v has a policy %v that has changed: %v", ag.CurrentAgreementId, pol.Header.Name, err)))
-
-					// Remove any workload usage records (non-HA) or mark for pending upgrade (HA). There might not be a workload usage record
-					// if the consumer policy does not specify the workload priority section.
-					if wlUsage, err := b.db.FindSingleWorkloadUsageByDeviceAndPolicyName(ag.DeviceId, ag.PolicyName); err != nil {
-						glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("error retreiving workload usage for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
-					} else if wlUsage != nil && len(wlUsage.HAPartners) != 0 && wlUsage.PendingUpgradeTime != 0 {
-						// Skip this agreement, it is part of an HA group where another member is upgrading
-						continue
-					} else if wlUsage != nil && len(wlUsage.HAPartners) != 0 && wlUsage.PendingUpgradeTime == 0 {
-						for _, partnerId := range wlUsage.HAPartners {
-							if _, err := b.db.UpdatePendingUpgrade(partnerId, ag.PolicyName); err != nil {
-								glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("could not update pending workload upgrade for %v using policy %v, error: %v", partnerId, ag.PolicyName, err)))
-							}
-						}
-						// Choose this device's agreement within the HA group to start upgrading.
-						// Delete this workload usage record so that a new agreement will be made starting from the highest priority workload
-						if err := b.db.DeleteWorkloadUsage(ag.DeviceId, ag.PolicyName); err != nil {
-							glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("error deleting workload usage for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
-						}
-						agreementWork := CancelAgreement{
-							workType:    CANCEL,
-							AgreementId: ag.CurrentAgreementId,
-							Protocol:    ag.AgreementProtocol,
-							Reason:      cph.GetTerminationCode(TERM_REASON_POLICY_CHANGED),
-						}
-						cph.WorkQueue() <- agreementWork
-					} else {
-						// Non-HA device or agreement without workload priority in the policy, re-make the agreement.
-						// Delete this workload usage record so that a new agreement will be made starting from the highest priority workload
-						if err := b.db.DeleteWorkloadUsage(ag.DeviceId, ag.PolicyName); err != nil {
-							glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("error deleting workload usage for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
-						}
-						agreementWork := CancelAgreement{
-							workType:    CANCEL,
-							AgreementId: ag.CurrentAgreementId,
-							Protocol:    ag.AgreementProtocol,
-							Reason:      cph.GetTerminationCode(TERM_REASON_POLICY_CHANGED),
-						}
-						cph.WorkQueue() <- agreementWork
-					}
+					b.cancelChangedAgreement(&ag, cph)
 				} else {
 					glog.V(5).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("for agreement %v, no policy content differences detected", ag.CurrentAgreementId)))
 				}
@@ -309,6 +281,91 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChanged(cmd *PolicyChangedComm
 	}
 }
 
+// Cancel an agreement whose policy has changed, so that a new agreement is made with the new policy. The cancellation
+// waits if the agreement's maintenance windows do not allow the workload to be disrupted right now.
+func (b *BaseConsumerProtocolHandler) cancelChangedAgreement(ag *persistence.Agreement, cph ConsumerProtocolHandler) {
+
+	if deferDisruption(b.db, b.pm, ag, cph, TERM_REASON_POLICY_CHANGED) {
+		return
+	}
+
+	// Remove any workload usage records (non-HA) or mark for pending upgrade (HA). There might not be a workload usage record
+	// if the consumer policy does not specify the workload priority section.
+	if wlUsage, err := b.db.FindSingleWorkloadUsageByDeviceAndPolicyName(ag.DeviceId, ag.PolicyName); err != nil {
+		glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("error retreiving workload usage for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
+	} else if wlUsage != nil && len(wlUsage.HAPartners) != 0 && wlUsage.PendingUpgradeTime != 0 {
+		// Skip this agreement, it is part of an HA group where another member is upgrading
+		return
+	} else if wlUsage != nil && len(wlUsage.HAPartners) != 0 && wlUsage.PendingUpgradeTime == 0 {
+		for _, partnerId := range wlUsage.HAPartners {
+			if _, err := b.db.UpdatePendingUpgrade(partnerId, ag.PolicyName); err != nil {
+				glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("could not update pending workload upgrade for %v using policy %v, error: %v", partnerId, ag.PolicyName, err)))
+			}
+		}
+		// Choose this device's agreement within the HA group to start upgrading.
+		// Delete this workload usage record so that a new agreement will be made starting from the highest priority workload
+		if err := b.db.DeleteWorkloadUsage(ag.DeviceId, ag.PolicyName); err != nil {
+			glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("error deleting workload usage for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
+		}
+		agreementWork := CancelAgreement{
+			workType:    CANCEL,
+			AgreementId: ag.CurrentAgreementId,
+			Protocol:    ag.AgreementProtocol,
+			Reason:      cph.GetTerminationCode(TERM_REASON_POLICY_CHANGED),
+		}
+		cph.WorkQueue() <- agreementWork
+	} else {
+		// Non-HA device or agreement without workload priority in the policy, re-make the agreement.
+		// Delete this workload usage record so that a new agreement will be made starting from the highest priority workload
+		if err := b.db.DeleteWorkloadUsage(ag.DeviceId, ag.PolicyName); err != nil {
+			glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("error deleting workload usage for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
+		}
+		agreementWork := CancelAgreement{
+			workType:    CANCEL,
+			AgreementId: ag.CurrentAgreementId,
+			Protocol:    ag.AgreementProtocol,
+			Reason:      cph.GetTerminationCode(TERM_REASON_POLICY_CHANGED),
+		}
+		cph.WorkQueue() <- agreementWork
+	}
+}
+
+// Take the action that was deferred until the agreement's maintenance windows allowed it.
+func (b *BaseConsumerProtocolHandler) HandleDeferredAction(ag *persistence.Agreement, cph ConsumerProtocolHandler) {
+
+	glog.V(3).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("maintenance window reached for %v of agreement %v", ag.DeferredReason, ag.CurrentAgreementId)))
+
+	if _, err := b.db.AgreementDeferralDone(ag.CurrentAgreementId, ag.AgreementProtocol); err != nil {
+		glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("unable to clear deferral of agreement %v, error: %v", ag.CurrentAgreementId, err)))
+		return
+	}
+
+	switch ag.DeferredReason {
+	case TERM_REASON_POLICY_CHANGED:
+		// The policy might have been changed back while the agreement was waiting.
+		if pol, err := policy.DemarshalPolicy(ag.Policy); err != nil {
+			glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("unable to demarshal policy for agreement %v, error %v", ag.CurrentAgreementId, err)))
+		} else if err := b.pm.MatchesMine(ag.Org, pol); err == nil {
+			glog.V(3).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("policy %v of agreement %v no longer differs, nothing to do", pol.Header.Name, ag.CurrentAgreementId)))
+		} else {
+			b.cancelChangedAgreement(ag, cph)
+		}
+
+	case TERM_REASON_CANCEL_FORCED_UPGRADE:
+		upgradeWork := HandleWorkloadUpgrade{
+			workType:    WORKLOAD_UPGRADE,
+			AgreementId: ag.CurrentAgreementId,
+			Device:      ag.DeviceId,
+			Protocol:    ag.AgreementProtocol,
+			PolicyName:  ag.PolicyName,
+		}
+		cph.WorkQueue() <- upgradeWork
+
+	default:
+		glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("agreement %v has unknown deferred action %v", ag.CurrentAgreementId, ag.DeferredReason)))
+	}
+}
+
 func (b *BaseConsumerProtocolHandler) HandlePolicyDeleted(cmd *PolicyDeletedCommand, cph ConsumerProtocolHandler) {
 	glog.V(5).Infof(BCPHlogstring(b.Name(), "received policy deleted command."))
 
@@ -559,6 +616,38 @@ const TERM_REASON_CANCEL_FORCED_UPGRADE = "ForceUpgrade"
 const TERM_REASON_CANCEL_BC_WRITE_FAILED = "WriteFailed"
 const TERM_REASON_NODE_HEARTBEAT = "NodeHeartbeat"
 const TERM_REASON_AG_MISSING = "AgreementMissing"
+const TERM_REASON_NODE_LACKS_DISK = "NodeLacksDisk"
+const TERM_REASON_NODE_LACKS_MEMORY = "NodeLacksMemory"
+const TERM_REASON_NODE_LACKS_DEVICE = "NodeLacksDevice"
+
+// The reason code returned by GetTerminationCode for a termination reason that the protocol does not support.
+const TERM_REASON_UNKNOWN_CODE = 999
+
+// The termination reasons which mean that the agbot gave up waiting for the node. These are counted as timeouts in the
+// agreement statistics.
+var TimeoutTerminationReasons = []string{TERM_REASON_NO_REPLY, TERM_REASON_NOT_FINALIZED_TIMEOUT, TERM_REASON_NO_DATA_RECEIVED}
+
+// Returns the protocol specific reason codes of the timeout termination reasons, keyed by agreement protocol. Reasons that
+// a protocol does not support are left out.
+func GetTimeoutTerminationCodes() map[string][]uint {
+	codes := make(map[string][]uint)
+	for _, protocol := range policy.AllAgreementProtocols() {
+		var cph ConsumerProtocolHandler
+		if protocol == policy.BasicProtocol {
+			cph = new(BasicProtocolHandler)
+		} else { // Add new consumer side protocol handlers here
+			continue
+		}
+
+		codes[protocol] = make([]uint, 0, len(TimeoutTerminationReasons))
+		for _, reason := range TimeoutTerminationReasons {
+			if code := cph.GetTerminationCode(reason); code != TERM_REASON_UNKNOWN_CODE {
+				codes[protocol] = append(codes[protocol], code)
+			}
+		}
+	}
+	return codes
+}
 
 var BCPHlogstring = func(p string, v interface{}) string {
 	return fmt.Sprintf("Base Consumer Protocol Handler (%v) %v", p, v)
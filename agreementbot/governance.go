@@ -57,6 +57,11 @@ func (w *AgreementBotWorker) GovernAgreements() int {
 
 			for _, ag := range agreements {
 
+				// Take the action that was waiting for the agreement's maintenance window, once the window opens.
+				if ag.IsDeferred() && ag.DeferredUntil <= uint64(time.Now().Unix()) {
+					protocolHandler.HandleDeferredAction(&ag, protocolHandler)
+				}
+
 				// Govern agreements that have seen a reply from the device
 				if protocolHandler.AlreadyReceivedReply(&ag) {
 
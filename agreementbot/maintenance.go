@@ -0,0 +1,68 @@
+package agreementbot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/policy"
+	"time"
+)
+
+// Returns the maintenance windows that govern disruptive actions on an agreement. The terms and conditions of the
+// agreement hold the windows from the node and the windows the policy had when the agreement was made. The windows
+// in the current version of the policy are added so that changing a policy's windows applies to existing agreements.
+func agreementMaintenanceWindows(ag *persistence.Agreement, cph ConsumerProtocolHandler, pm *policy.PolicyManager) (policy.MaintenanceWindowList, error) {
+
+	windows := make(policy.MaintenanceWindowList, 0, 5)
+	if ag.Proposal != "" {
+		if proposal, err := cph.AgreementProtocolHandler("", "", "").DemarshalProposal(ag.Proposal); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to demarshal proposal for agreement %v, error: %v", ag.CurrentAgreementId, err))
+		} else if tcPolicy, err := policy.DemarshalPolicy(proposal.TsAndCs()); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to demarshal terms and conditions for agreement %v, error: %v", ag.CurrentAgreementId, err))
+		} else {
+			(&windows).Concatenate(&tcPolicy.MaintenanceWindows)
+		}
+	}
+
+	if pol := pm.GetPolicy(ag.Org, ag.PolicyName); pol != nil {
+		(&windows).Concatenate(&pol.MaintenanceWindows)
+	}
+
+	return windows, nil
+}
+
+// Check whether a disruptive action on an agreement has to wait for a maintenance window. If it does, the deferral is
+// recorded in the agreement, governance takes the action once the window opens, and true is returned. When the windows
+// cannot be evaluated the action is deferred for a while and checked again, so that a change to the windows can fix them.
+// False is returned when the deferral cannot be recorded, because governance would never take the action.
+func deferDisruption(db persistence.AgbotDatabase, pm *policy.PolicyManager, ag *persistence.Agreement, cph ConsumerProtocolHandler, reason string) bool {
+
+	windows, err := agreementMaintenanceWindows(ag, cph, pm)
+	if err != nil {
+		glog.Errorf(MWlogString(fmt.Sprintf("unable to get maintenance windows, not deferring %v, error: %v", reason, err)))
+		return false
+	} else if len(windows) == 0 {
+		return false
+	}
+
+	now := time.Now()
+	next, err := windows.NextAllowed(now)
+	if err != nil {
+		next = now.Add(policy.MaintenanceWindowRetry)
+		glog.Errorf(MWlogString(fmt.Sprintf("unable to evaluate maintenance windows of agreement %v, deferring %v until %v, error: %v", ag.CurrentAgreementId, reason, next, err)))
+	} else if !next.After(now) {
+		return false
+	}
+
+	if _, err := db.AgreementDeferred(ag.CurrentAgreementId, ag.AgreementProtocol, uint64(next.Unix()), reason); err != nil {
+		glog.Errorf(MWlogString(fmt.Sprintf("unable to record deferral of agreement %v, not deferring %v, error: %v", ag.CurrentAgreementId, reason, err)))
+		return false
+	}
+	glog.V(3).Infof(MWlogString(fmt.Sprintf("deferred %v of agreement %v until %v", reason, ag.CurrentAgreementId, next)))
+	return true
+}
+
+var MWlogString = func(v interface{}) string {
+	return fmt.Sprintf("AgreementBot Maintenance Windows: %v", v)
+}
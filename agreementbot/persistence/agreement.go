@@ -49,6 +49,8 @@ type Agreement struct {
 	NHMissingHBInterval            int      `json:"missing_heartbeat_interval"`        // How long a heartbeat can be missing until it is considered missing (in seconds)
 	NHCheckAgreementStatus         int      `json:"check_agreement_status"`            // How often to check that the node agreement entry still exists in the exchange (in seconds)
 	Pattern                        string   `json:"pattern"`                           // The pattern used to make the agreement
+	DeferredUntil                  uint64   `json:"deferred_until"`                    // The time at which a maintenance window allows the deferred action to be taken
+	DeferredReason                 string   `json:"deferred_reason"`                   // The action (a termination reason) waiting for a maintenance window, empty when nothing is deferred
 
 }
 
@@ -90,7 +92,9 @@ func (a Agreement) String() string {
 		"BCUpdateAckTime: %v, "+
 		"NHMissingHBInterval: %v, "+
 		"NHCheckAgreementStatus: %v, "+
-		"Pattern: %v",
+		"Pattern: %v, "+
+		"DeferredUntil: %v, "+
+		"DeferredReason: %v",
 		a.Archived, a.CurrentAgreementId, a.Org, a.AgreementProtocol, a.AgreementProtocolVersion, a.DeviceId, a.HAPartners,
 		a.AgreementInceptionTime, a.AgreementCreationTime, a.AgreementFinalizedTime,
 		a.AgreementTimedout, a.ProposalSig, a.ProposalHash, a.ConsumerProposalSig, a.PolicyName, a.CounterPartyAddress,
@@ -98,7 +102,7 @@ func (a Agreement) String() string {
 		a.DisableDataVerificationChecks, a.DataVerifiedTime, a.DataNotificationSent,
 		a.MeteringTokens, a.MeteringPerTimeUnit, a.MeteringNotificationInterval, a.MeteringNotificationSent, a.MeteringNotificationMsgs,
 		a.TerminatedReason, a.TerminatedDescription, a.BlockchainType, a.BlockchainName, a.BlockchainOrg, a.BCUpdateAckTime,
-		a.NHMissingHBInterval, a.NHCheckAgreementStatus, a.Pattern)
+		a.NHMissingHBInterval, a.NHCheckAgreementStatus, a.Pattern, a.DeferredUntil, a.DeferredReason)
 }
 
 // Factory method for agreement w/out persistence safety.
@@ -147,6 +151,8 @@ func NewAgreement(agreementid string, org string, deviceid string, policyName st
 			NHMissingHBInterval:            nhPolicy.MissingHBInterval,
 			NHCheckAgreementStatus:         nhPolicy.CheckAgreementStatus,
 			Pattern:                        pattern,
+			DeferredUntil:                  0,
+			DeferredReason:                 "",
 		}, nil
 	}
 }
@@ -155,6 +161,10 @@ func (a *Agreement) NodeHealthInUse() bool {
 	return a.NHMissingHBInterval != 0 || a.NHCheckAgreementStatus != 0
 }
 
+func (a *Agreement) IsDeferred() bool {
+	return a.DeferredReason != ""
+}
+
 func (a *Agreement) FinalizedWithinTolerance(tolerance uint64) bool {
 	tolerate := uint64(time.Now().Unix()) - tolerance
 	return a.AgreementFinalizedTime > tolerate
@@ -306,6 +316,29 @@ func MeteringNotification(db AgbotDatabase, agreementid string, protocol string,
 	}
 }
 
+func AgreementDeferred(db AgbotDatabase, agreementid string, protocol string, until uint64, reason string) (*Agreement, error) {
+	if agreement, err := db.SingleAgreementUpdate(agreementid, protocol, func(a Agreement) *Agreement {
+		a.DeferredUntil = until
+		a.DeferredReason = reason
+		return &a
+	}); err != nil {
+		return nil, err
+	} else {
+		return agreement, nil
+	}
+}
+
+func AgreementDeferralDone(db AgbotDatabase, agreementid string, protocol string) (*Agreement, error) {
+	if agreement, err := db.SingleAgreementUpdate(agreementid, protocol, func(a Agreement) *Agreement {
+		a.DeferredReason = ""
+		return &a
+	}); err != nil {
+		return nil, err
+	} else {
+		return agreement, nil
+	}
+}
+
 func ArchiveAgreement(db AgbotDatabase, agreementid string, protocol string, reason uint, desc string) (*Agreement, error) {
 	if agreement, err := db.SingleAgreementUpdate(agreementid, protocol, func(a Agreement) *Agreement {
 		a.Archived = true
@@ -419,6 +452,12 @@ func ValidateStateTransition(mod *Agreement, update *Agreement) {
 	if mod.BCUpdateAckTime == 0 { // 1 transition from zero to non-zero
 		mod.BCUpdateAckTime = update.BCUpdateAckTime
 	}
+	if mod.DeferredUntil < update.DeferredUntil { // Valid transitions must move forward, a new deferral brings its reason
+		mod.DeferredUntil = update.DeferredUntil
+		mod.DeferredReason = update.DeferredReason
+	} else if mod.DeferredUntil == update.DeferredUntil && update.DeferredReason == "" { // the deferred action was taken
+		mod.DeferredReason = ""
+	}
 }
 
 // Filters used by the caller to control what comes back from the database.
@@ -440,6 +479,13 @@ func DevPolAFilter(deviceId string, policyName string) AFilter {
 	return func(a Agreement) bool { return a.DeviceId == deviceId && a.PolicyName == policyName }
 }
 
+// An empty org or policy name matches every org or policy.
+func OrgPolAFilter(org string, policyName string) AFilter {
+	return func(a Agreement) bool {
+		return (org == "" || a.Org == org) && (policyName == "" || a.PolicyName == policyName)
+	}
+}
+
 func RunFilters(ag *Agreement, filters []AFilter) *Agreement {
 	for _, filterFn := range filters {
 		if !filterFn(*ag) {
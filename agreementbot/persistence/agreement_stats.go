@@ -0,0 +1,278 @@
+package persistence
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Agreement statistics are an aggregated view of the agreement records (active and archived) held by the agbot, grouped
+// by org and by org and policy. The database implementations stream their agreement records through an accumulator so
+// that the agreements never have to be held in memory all at once.
+
+type AgreementStatsReport struct {
+	Orgs     []AgreementStats `json:"orgs"`     // statistics for each org, sorted by org
+	Policies []AgreementStats `json:"policies"` // statistics for each policy, sorted by org and policy name
+}
+
+type AgreementStats struct {
+	Org                    string                   `json:"org"`                       // the org of the policies counted
+	PolicyName             string                   `json:"policy_name,omitempty"`     // the policy counted, empty for org statistics
+	Agreements             int                      `json:"agreements"`                // the number of agreements attempted, active or archived
+	Active                 int                      `json:"active"`                    // the number of agreements that are not terminated
+	Finalized              int                      `json:"finalized"`                 // the number of agreements that were finalized, including those since terminated
+	Terminated             int                      `json:"terminated"`                // the number of agreements that are archived or being terminated
+	TimedOut               int                      `json:"timed_out"`                 // the number of agreements terminated because the node did not respond in time
+	TimeoutRate            float64                  `json:"timeout_rate"`              // the fraction of attempted agreements that timed out
+	TerminationReasons     []TerminationReasonCount `json:"termination_reasons"`       // the number of archived agreements by reason, most frequent first
+	TimeToFinalize         FinalizeTimes            `json:"time_to_finalize"`          // the time from the agreement attempt to finalization
+	Devices                int                      `json:"devices"`                   // the number of different devices that agreements were attempted with
+	AgreementsPerDevice    float64                  `json:"agreements_per_device"`     // the average number of agreements attempted with each device
+	MaxAgreementsPerDevice int                      `json:"max_agreements_per_device"` // the most agreements attempted with a single device
+}
+
+func (s AgreementStats) String() string {
+	return fmt.Sprintf("Org: %v, "+
+		"PolicyName: %v, "+
+		"Agreements: %v, "+
+		"Active: %v, "+
+		"Finalized: %v, "+
+		"Terminated: %v, "+
+		"TimedOut: %v, "+
+		"TimeoutRate: %v, "+
+		"TerminationReasons: %v, "+
+		"TimeToFinalize: %v, "+
+		"Devices: %v, "+
+		"AgreementsPerDevice: %v, "+
+		"MaxAgreementsPerDevice: %v",
+		s.Org, s.PolicyName, s.Agreements, s.Active, s.Finalized, s.Terminated, s.TimedOut, s.TimeoutRate,
+		s.TerminationReasons, s.TimeToFinalize, s.Devices, s.AgreementsPerDevice, s.MaxAgreementsPerDevice)
+}
+
+type TerminationReasonCount struct {
+	Protocol    string `json:"protocol"`    // the agreement protocol that defines the reason code
+	Reason      uint   `json:"reason"`      // the termination reason code
+	Description string `json:"description"` // the description of the reason code
+	Count       int    `json:"count"`       // the number of agreements terminated for this reason
+}
+
+// Time to finalize percentiles, in seconds. All values are 0 when no agreement has been finalized.
+type FinalizeTimes struct {
+	Count int    `json:"count"` // the number of finalized agreements the times are computed from
+	Min   uint64 `json:"min"`
+	P50   uint64 `json:"p50"`
+	P90   uint64 `json:"p90"`
+	P99   uint64 `json:"p99"`
+	Max   uint64 `json:"max"`
+}
+
+// The counts for a single org or policy as they are accumulated.
+type agreementStatsCounter struct {
+	stats    AgreementStats
+	reasons  map[string]*TerminationReasonCount // keyed by protocol and reason code
+	finalize []uint64                           // the time to finalize of each finalized agreement
+	devices  map[string]int                     // the number of agreements with each device
+}
+
+func newAgreementStatsCounter(org string, policyName string) *agreementStatsCounter {
+	return &agreementStatsCounter{
+		stats:    AgreementStats{Org: org, PolicyName: policyName},
+		reasons:  make(map[string]*TerminationReasonCount),
+		finalize: make([]uint64, 0, 10),
+		devices:  make(map[string]int),
+	}
+}
+
+type AgreementStatsAccumulator struct {
+	timeoutCodes map[string][]uint                 // the termination reasons that mean a timeout, by agreement protocol
+	orgs         map[string]*agreementStatsCounter // keyed by org
+	policies     map[string]*agreementStatsCounter // keyed by org and policy name
+}
+
+func NewAgreementStatsAccumulator(timeoutCodes map[string][]uint) *AgreementStatsAccumulator {
+	return &AgreementStatsAccumulator{
+		timeoutCodes: timeoutCodes,
+		orgs:         make(map[string]*agreementStatsCounter),
+		policies:     make(map[string]*agreementStatsCounter),
+	}
+}
+
+// Count an agreement. Only the org, policy name, device id, protocol, timestamps, archived flag and termination reason
+// of the agreement are used, so the database implementations can avoid reading the rest of the record.
+func (a *AgreementStatsAccumulator) Add(ag *Agreement) {
+
+	org, ok := a.orgs[ag.Org]
+	if !ok {
+		org = newAgreementStatsCounter(ag.Org, "")
+		a.orgs[ag.Org] = org
+	}
+
+	key := ag.Org + "/" + ag.PolicyName
+	pol, ok := a.policies[key]
+	if !ok {
+		pol = newAgreementStatsCounter(ag.Org, ag.PolicyName)
+		a.policies[key] = pol
+	}
+
+	timedOut := false
+	if ag.Archived {
+		for _, code := range a.timeoutCodes[ag.AgreementProtocol] {
+			if ag.TerminatedReason == code {
+				timedOut = true
+				break
+			}
+		}
+	}
+
+	for _, c := range []*agreementStatsCounter{org, pol} {
+		c.stats.Agreements += 1
+		if ag.Archived || ag.AgreementTimedout != 0 {
+			c.stats.Terminated += 1
+		} else {
+			c.stats.Active += 1
+		}
+
+		if ag.AgreementFinalizedTime != 0 {
+			c.stats.Finalized += 1
+			if ag.AgreementInceptionTime != 0 && ag.AgreementFinalizedTime >= ag.AgreementInceptionTime {
+				c.finalize = append(c.finalize, ag.AgreementFinalizedTime-ag.AgreementInceptionTime)
+			}
+		}
+
+		if ag.Archived {
+			reasonKey := fmt.Sprintf("%v/%v", ag.AgreementProtocol, ag.TerminatedReason)
+			if r, ok := c.reasons[reasonKey]; ok {
+				r.Count += 1
+			} else {
+				c.reasons[reasonKey] = &TerminationReasonCount{Protocol: ag.AgreementProtocol, Reason: ag.TerminatedReason, Description: ag.TerminatedDescription, Count: 1}
+			}
+		}
+
+		if timedOut {
+			c.stats.TimedOut += 1
+		}
+
+		c.devices[ag.DeviceId] += 1
+	}
+}
+
+// Compute the statistics from the agreements counted so far.
+func (a *AgreementStatsAccumulator) Report() *AgreementStatsReport {
+
+	report := &AgreementStatsReport{
+		Orgs:     make([]AgreementStats, 0, len(a.orgs)),
+		Policies: make([]AgreementStats, 0, len(a.policies)),
+	}
+
+	for _, c := range a.orgs {
+		report.Orgs = append(report.Orgs, c.compute())
+	}
+	for _, c := range a.policies {
+		report.Policies = append(report.Policies, c.compute())
+	}
+
+	sort.Sort(AgreementStatsByOrgPolicy(report.Orgs))
+	sort.Sort(AgreementStatsByOrgPolicy(report.Policies))
+
+	return report
+}
+
+func (c *agreementStatsCounter) compute() AgreementStats {
+
+	s := c.stats
+
+	if s.Agreements != 0 {
+		s.TimeoutRate = float64(s.TimedOut) / float64(s.Agreements)
+	}
+
+	s.TerminationReasons = make([]TerminationReasonCount, 0, len(c.reasons))
+	for _, r := range c.reasons {
+		s.TerminationReasons = append(s.TerminationReasons, *r)
+	}
+	sort.Sort(TerminationReasonsByCount(s.TerminationReasons))
+
+	if len(c.finalize) != 0 {
+		sort.Sort(finalizeTimes(c.finalize))
+		s.TimeToFinalize = FinalizeTimes{
+			Count: len(c.finalize),
+			Min:   c.finalize[0],
+			P50:   percentile(c.finalize, 50),
+			P90:   percentile(c.finalize, 90),
+			P99:   percentile(c.finalize, 99),
+			Max:   c.finalize[len(c.finalize)-1],
+		}
+	}
+
+	s.Devices = len(c.devices)
+	for _, count := range c.devices {
+		if count > s.MaxAgreementsPerDevice {
+			s.MaxAgreementsPerDevice = count
+		}
+	}
+	if s.Devices != 0 {
+		s.AgreementsPerDevice = float64(s.Agreements) / float64(s.Devices)
+	}
+
+	return s
+}
+
+// Nearest rank percentile of a sorted, non-empty list of values.
+func percentile(sorted []uint64, p float64) uint64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Sorting helpers for the statistics.
+type AgreementStatsByOrgPolicy []AgreementStats
+
+func (s AgreementStatsByOrgPolicy) Len() int {
+	return len(s)
+}
+
+func (s AgreementStatsByOrgPolicy) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s AgreementStatsByOrgPolicy) Less(i, j int) bool {
+	if s[i].Org != s[j].Org {
+		return s[i].Org < s[j].Org
+	}
+	return s[i].PolicyName < s[j].PolicyName
+}
+
+// The most frequent reasons come first.
+type TerminationReasonsByCount []TerminationReasonCount
+
+func (s TerminationReasonsByCount) Len() int {
+	return len(s)
+}
+
+func (s TerminationReasonsByCount) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s TerminationReasonsByCount) Less(i, j int) bool {
+	if s[i].Count != s[j].Count {
+		return s[i].Count > s[j].Count
+	} else if s[i].Protocol != s[j].Protocol {
+		return s[i].Protocol < s[j].Protocol
+	}
+	return s[i].Reason < s[j].Reason
+}
+
+type finalizeTimes []uint64
+
+func (s finalizeTimes) Len() int {
+	return len(s)
+}
+
+func (s finalizeTimes) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s finalizeTimes) Less(i, j int) bool {
+	return s[i] < s[j]
+}
@@ -43,6 +43,40 @@ func (db *AgbotBoltDB) GetAgreementCount(partition string) (int64, int64, error)
 	return activeNum, archivedNum, nil
 }
 
+// Stream all the agreements, active and archived, through the statistics accumulator in a single read transaction. An
+// empty org or policy name matches all of them.
+func (db *AgbotBoltDB) GetAgreementStats(org string, policyName string, timeoutCodes map[string][]uint) (*persistence.AgreementStatsReport, error) {
+	acc := persistence.NewAgreementStatsAccumulator(timeoutCodes)
+	filters := []persistence.AFilter{persistence.OrgPolAFilter(org, policyName)}
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+
+		for _, protocol := range policy.AllAgreementProtocols() {
+			if b := tx.Bucket([]byte(bucketName(protocol))); b != nil {
+				b.ForEach(func(k, v []byte) error {
+
+					var a persistence.Agreement
+
+					if err := json.Unmarshal(v, &a); err != nil {
+						glog.Errorf("Unable to deserialize db record: %v", v)
+					} else if persistence.RunFilters(&a, filters) != nil {
+						acc.Add(&a)
+					}
+					return nil
+				})
+			}
+		}
+
+		return nil // end the transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	} else {
+		return acc.Report(), nil
+	}
+}
+
 func (db *AgbotBoltDB) FindAgreements(filters []persistence.AFilter, protocol string) ([]persistence.Agreement, error) {
 	agreements := make([]persistence.Agreement, 0)
 
@@ -117,6 +151,14 @@ func (db *AgbotBoltDB) AgreementTimedout(agreementid string, protocol string) (*
 	return persistence.AgreementTimedout(db, agreementid, protocol)
 }
 
+func (db *AgbotBoltDB) AgreementDeferred(agreementid string, protocol string, until uint64, reason string) (*persistence.Agreement, error) {
+	return persistence.AgreementDeferred(db, agreementid, protocol, until, reason)
+}
+
+func (db *AgbotBoltDB) AgreementDeferralDone(agreementid string, protocol string) (*persistence.Agreement, error) {
+	return persistence.AgreementDeferralDone(db, agreementid, protocol)
+}
+
 func (db *AgbotBoltDB) DataVerified(agreementid string, protocol string) (*persistence.Agreement, error) {
 	return persistence.DataVerified(db, agreementid, protocol)
 }
@@ -9,6 +9,14 @@ func (db *AgbotBoltDB) FindPartitions() ([]string, error) {
 
 }
 
+func (db *AgbotBoltDB) PrimaryPartition() string {
+	return "global"
+}
+
+func (db *AgbotBoltDB) AllPartitions() []string {
+	return []string{"global"}
+}
+
 func (db *AgbotBoltDB) ClaimPartition(timeout uint64) (string, error) {
 	return "global", nil
 }
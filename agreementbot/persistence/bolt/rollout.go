@@ -0,0 +1,161 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"strconv"
+	"time"
+)
+
+const ROLLOUTS = "rollouts"
+
+func (db *AgbotBoltDB) NewRollout(org string, policyName string, version string, batchPercent int, batchCount int, failureThreshold int, onFailure string, timeoutS int) (*persistence.Rollout, error) {
+	if rollout, err := persistence.NewRollout(org, policyName, version, batchPercent, batchCount, failureThreshold, onFailure, timeoutS); err != nil {
+		return nil, err
+	} else if err := db.rolloutPersistNew(rollout); err != nil {
+		return nil, err
+	} else {
+		return rollout, nil
+	}
+}
+
+func (db *AgbotBoltDB) FindSingleRolloutById(id uint64) (*persistence.Rollout, error) {
+	var rollout *persistence.Rollout
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(ROLLOUTS)); b != nil {
+			if v := b.Get([]byte(strconv.FormatUint(id, 10))); v != nil {
+				rollout = new(persistence.Rollout)
+				if err := json.Unmarshal(v, rollout); err != nil {
+					return fmt.Errorf("Unable to deserialize rollout record %v: %v", string(v), err)
+				}
+			}
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return rollout, nil
+}
+
+func (db *AgbotBoltDB) FindRollouts(filters []persistence.RFilter) ([]persistence.Rollout, error) {
+	rollouts := make([]persistence.Rollout, 0)
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+
+		if b := tx.Bucket([]byte(ROLLOUTS)); b != nil {
+			b.ForEach(func(k, v []byte) error {
+
+				var r persistence.Rollout
+
+				if err := json.Unmarshal(v, &r); err != nil {
+					glog.Errorf("Unable to deserialize db record: %v", v)
+				} else {
+					exclude := false
+					for _, filterFn := range filters {
+						if !filterFn(r) {
+							exclude = true
+						}
+					}
+					if !exclude {
+						rollouts = append(rollouts, r)
+					}
+				}
+				return nil
+			})
+		}
+
+		return nil // end the transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	} else {
+		return rollouts, nil
+	}
+}
+
+// The rollout is read, updated and written in the same transaction, so that concurrent updates from the API and the
+// rollout controller are not lost.
+func (db *AgbotBoltDB) SingleRolloutUpdate(id uint64, fn func(persistence.Rollout) *persistence.Rollout) (*persistence.Rollout, error) {
+	var updated *persistence.Rollout
+
+	writeErr := db.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ROLLOUTS))
+		pKey := strconv.FormatUint(id, 10)
+		if b == nil {
+			return fmt.Errorf("No rollout with id %v available to update", pKey)
+		}
+
+		current := b.Get([]byte(pKey))
+		var mod persistence.Rollout
+
+		if current == nil {
+			return fmt.Errorf("No rollout with id %v available to update", pKey)
+		} else if err := json.Unmarshal(current, &mod); err != nil {
+			return fmt.Errorf("Failed to unmarshal rollout DB data: %v", string(current))
+		}
+
+		updated = fn(mod)
+		updated.Id = id
+		updated.UpdateTime = uint64(time.Now().Unix())
+
+		if serialized, err := json.Marshal(updated); err != nil {
+			return fmt.Errorf("Failed to serialize rollout record: %v", updated)
+		} else if err := b.Put([]byte(pKey), serialized); err != nil {
+			return fmt.Errorf("Failed to write rollout record with key: %v", pKey)
+		} else {
+			glog.V(2).Infof("Succeeded updating rollout record to %v", updated)
+		}
+		return nil
+	})
+
+	if writeErr != nil {
+		return nil, writeErr
+	}
+	return updated, nil
+}
+
+func (db *AgbotBoltDB) DeleteRollout(id uint64) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		pKey := strconv.FormatUint(id, 10)
+		if b := tx.Bucket([]byte(ROLLOUTS)); b == nil {
+			return fmt.Errorf("Unknown bucket: %v", ROLLOUTS)
+		} else if existing := b.Get([]byte(pKey)); existing == nil {
+			glog.Errorf("Warning: record deletion requested, but record does not exist: %v", pKey)
+			return nil // handle already-deleted rollout as success
+		} else {
+			glog.V(3).Infof("Deleting rollout record %v", pKey)
+			return b.Delete([]byte(pKey))
+		}
+	})
+}
+
+// This function allocates the record's primary key from the DB's internal sequence counter, the same way it is done
+// for workload usages.
+func (db *AgbotBoltDB) rolloutPersistNew(record *persistence.Rollout) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+
+		if b, err := tx.CreateBucketIfNotExists([]byte(ROLLOUTS)); err != nil {
+			return err
+		} else if nextKey, err := b.NextSequence(); err != nil {
+			return fmt.Errorf("Unable to get sequence key for new record %v. Error: %v", record, err)
+		} else {
+			strKey := strconv.FormatUint(nextKey, 10)
+			record.Id = nextKey
+			if bytes, err := json.Marshal(record); err != nil {
+				return fmt.Errorf("Unable to serialize record %v. Error: %v", record, err)
+			} else if err := b.Put([]byte(strKey), bytes); err != nil {
+				return fmt.Errorf("Unable to write record to bucket %v. Primary key of record: %v", ROLLOUTS, strKey)
+			} else {
+				glog.V(2).Infof("Succeeded writing rollout record identified by key %v, record %v", strKey, *record)
+				return nil
+			}
+		}
+	})
+}
@@ -18,6 +18,8 @@ type AgbotDatabase interface {
 
 	// Database partition related functions.
 	FindPartitions() ([]string, error)
+	PrimaryPartition() string
+	AllPartitions() []string
 	ClaimPartition(timeout uint64) (string, error)
 	HeartbeatPartition() error
 	QuiescePartition() error
@@ -30,6 +32,7 @@ type AgbotDatabase interface {
 	FindSingleAgreementByAgreementIdAllProtocols(agreementid string, protocols []string, filters []AFilter) (*Agreement, error)
 
 	GetAgreementCount(partition string) (int64, int64, error)
+	GetAgreementStats(org string, policyName string, timeoutCodes map[string][]uint) (*AgreementStatsReport, error)
 
 	SingleAgreementUpdate(agreementid string, protocol string, fn func(Agreement) *Agreement) (*Agreement, error)
 
@@ -40,6 +43,8 @@ type AgbotDatabase interface {
 	AgreementBlockchainUpdate(agreementId string, consumerSig string, hash string, counterParty string, signature string, protocol string) (*Agreement, error)
 	AgreementBlockchainUpdateAck(agreementId string, protocol string) (*Agreement, error)
 	AgreementTimedout(agreementid string, protocol string) (*Agreement, error)
+	AgreementDeferred(agreementid string, protocol string, until uint64, reason string) (*Agreement, error)
+	AgreementDeferralDone(agreementid string, protocol string) (*Agreement, error)
 
 	DataNotification(agreementid string, protocol string) (*Agreement, error)
 	DataVerified(agreementid string, protocol string) (*Agreement, error)
@@ -66,4 +71,14 @@ type AgbotDatabase interface {
 	DisableRollbackChecking(deviceid string, policyName string) (*WorkloadUsage, error)
 
 	DeleteWorkloadUsage(deviceid string, policyName string) error
+
+	// Rollout related functions. The update function is called within the database transaction that writes the
+	// updated rollout, so it must not call back into the database.
+	NewRollout(org string, policyName string, version string, batchPercent int, batchCount int, failureThreshold int, onFailure string, timeoutS int) (*Rollout, error)
+	FindSingleRolloutById(id uint64) (*Rollout, error)
+	FindRollouts(filters []RFilter) ([]Rollout, error)
+
+	SingleRolloutUpdate(id uint64, fn func(Rollout) *Rollout) (*Rollout, error)
+
+	DeleteRollout(id uint64) error
 }
@@ -106,6 +106,20 @@ const AGREEMENT_PARTITION_EMPTY = `SELECT agreement_id FROM "agreements_;`
 
 const AGREEMENT_COUNT = `SELECT agreement FROM "agreements_;`
 
+// Only the parts of the agreement that are needed for the agreement statistics are extracted from the JSON blob.
+const AGREEMENT_STATS_QUERY = `SELECT protocol,
+	COALESCE(agreement->>'org', ''),
+	COALESCE(agreement->>'policy_name', ''),
+	COALESCE(agreement->>'device_id', ''),
+	COALESCE((agreement->>'agreement_inception_time')::bigint, 0),
+	COALESCE((agreement->>'agreement_finalized_time')::bigint, 0),
+	COALESCE((agreement->>'agreement_timeout')::bigint, 0),
+	COALESCE((agreement->>'archived')::boolean, false),
+	COALESCE((agreement->>'terminated_reason')::bigint, 0),
+	COALESCE(agreement->>'terminated_description', '')
+	FROM "agreements_
+	WHERE ($1::text = '' OR agreement->>'org' = $1::text) AND ($2::text = '' OR agreement->>'policy_name' = $2::text);`
+
 const AGREEMENT_INSERT = `INSERT INTO "agreements_ (agreement_id, protocol, partition, agreement) VALUES ($1, $2, $3, $4);`
 const AGREEMENT_UPDATE = `UPDATE "agreements_ SET agreement = $3, updated = current_timestamp WHERE agreement_id = $1 AND protocol = $2;`
 const AGREEMENT_DELETE = `DELETE FROM "agreements_ WHERE agreement_id = $1;`
@@ -252,6 +266,46 @@ func (db *AgbotPostgresqlDB) GetAgreementCount(partition string) (int64, int64,
 	return activeNum, archivedNum, nil
 }
 
+// Stream the agreements, active and archived, in all partitions through the statistics accumulator. The query returns only the
+// fields used by the statistics, of the agreements in the org and policy, so that the whole agreement blob does not have to be
+// read and demarshalled. An empty org or policy name matches all of them.
+func (db *AgbotPostgresqlDB) GetAgreementStats(org string, policyName string, timeoutCodes map[string][]uint) (*persistence.AgreementStatsReport, error) {
+
+	acc := persistence.NewAgreementStatsAccumulator(timeoutCodes)
+
+	for _, currentPartition := range db.AllPartitions() {
+		sql := strings.Replace(AGREEMENT_STATS_QUERY, AGREEMENT_TABLE_NAME_ROOT, db.GetAgreementPartitionTableName(currentPartition), 1)
+		glog.V(5).Infof("Get agreement stats using SQL: %v for partition %v", sql, currentPartition)
+		rows, err := db.db.Query(sql, org, policyName)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error querying for agreement stats error: %v", err))
+		}
+
+		// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+		defer rows.Close()
+		for rows.Next() {
+			ag := new(persistence.Agreement)
+			var inception, finalized, timedout, reason int64
+			if err := rows.Scan(&ag.AgreementProtocol, &ag.Org, &ag.PolicyName, &ag.DeviceId, &inception, &finalized, &timedout, &ag.Archived, &reason, &ag.TerminatedDescription); err != nil {
+				return nil, errors.New(fmt.Sprintf("error scanning row for agreement stats: %v", err))
+			}
+			ag.AgreementInceptionTime = uint64(inception)
+			ag.AgreementFinalizedTime = uint64(finalized)
+			ag.AgreementTimedout = uint64(timedout)
+			ag.TerminatedReason = uint(reason)
+			acc.Add(ag)
+		}
+
+		// The rows.Next() function will exit with false when done or an error occurred. Get any error encountered during iteration.
+		if err = rows.Err(); err != nil {
+			return nil, errors.New(fmt.Sprintf("error iterating rows for agreement stats: %v", err))
+		}
+	}
+
+	return acc.Report(), nil
+
+}
+
 // Retrieve all agreements from the database and filter them out based on the input filters.
 func (db *AgbotPostgresqlDB) FindAgreements(filters []persistence.AFilter, protocol string) ([]persistence.Agreement, error) {
 
@@ -380,6 +434,14 @@ func (db *AgbotPostgresqlDB) AgreementTimedout(agreementid string, protocol stri
 	return persistence.AgreementTimedout(db, agreementid, protocol)
 }
 
+func (db *AgbotPostgresqlDB) AgreementDeferred(agreementid string, protocol string, until uint64, reason string) (*persistence.Agreement, error) {
+	return persistence.AgreementDeferred(db, agreementid, protocol, until, reason)
+}
+
+func (db *AgbotPostgresqlDB) AgreementDeferralDone(agreementid string, protocol string) (*persistence.Agreement, error) {
+	return persistence.AgreementDeferralDone(db, agreementid, protocol)
+}
+
 func (db *AgbotPostgresqlDB) AgreementBlockchainUpdate(agreementId string, consumerSig string, hash string, counterParty string, signature string, protocol string) (*persistence.Agreement, error) {
 	return persistence.AgreementBlockchainUpdate(db, agreementId, consumerSig, hash, counterParty, signature, protocol)
 }
@@ -0,0 +1,174 @@
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"time"
+)
+
+// Constants for the SQL statements that are used to work with rollouts. A rollout moves the devices using a policy to a new
+// workload version a batch at a time. Rollouts are not partitioned. Every agbot instance moves the devices whose agreements are
+// in its own partitions, and records them in the same rollout row. The row is locked while it is updated so that the instances
+// do not overwrite each other's updates.
+
+// rollouts schema:
+// id:          The rollout id, serially incremented by the database when a new rollout is created.
+// org:         The org of the policy being rolled out.
+// policy_name: The name of the policy being rolled out.
+// rollout:     The rollout object which is a JSON blob. The blob schema is defined by the Rollout struct in the persistence package.
+// updated:     A timestamp to record last updated time.
+//
+const ROLLOUT_CREATE_TABLE = `CREATE TABLE IF NOT EXISTS rollouts (
+	id bigserial PRIMARY KEY,
+	org text NOT NULL,
+	policy_name text NOT NULL,
+	rollout jsonb NOT NULL,
+	updated timestamp with time zone DEFAULT current_timestamp
+);`
+
+const ROLLOUT_QUERY = `SELECT rollout FROM rollouts WHERE id = $1;`
+const ROLLOUT_QUERY_FOR_UPDATE = `SELECT rollout FROM rollouts WHERE id = $1 FOR UPDATE;`
+const ALL_ROLLOUT_QUERY = `SELECT rollout FROM rollouts;`
+
+const ROLLOUT_INSERT = `INSERT INTO rollouts (org, policy_name, rollout) VALUES ($1, $2, '{}') RETURNING id;`
+const ROLLOUT_UPDATE = `UPDATE rollouts SET rollout = $2, updated = current_timestamp WHERE id = $1;`
+const ROLLOUT_DELETE = `DELETE FROM rollouts WHERE id = $1;`
+
+func (db *AgbotPostgresqlDB) NewRollout(org string, policyName string, version string, batchPercent int, batchCount int, failureThreshold int, onFailure string, timeoutS int) (*persistence.Rollout, error) {
+	rollout, err := persistence.NewRollout(org, policyName, version, batchPercent, batchCount, failureThreshold, onFailure, timeoutS)
+	if err != nil {
+		return nil, err
+	}
+
+	// The id is allocated by the database when the row is inserted, and then written into the rollout object.
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow(ROLLOUT_INSERT, org, policyName).Scan(&rollout.Id); err != nil {
+		return nil, errors.New(fmt.Sprintf("error inserting rollout %v, error: %v", rollout, err))
+	} else if err := db.updateRollout(tx, rollout); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	glog.V(2).Infof("Succeeded creating rollout record %v", *rollout)
+	return rollout, nil
+}
+
+func (db *AgbotPostgresqlDB) FindSingleRolloutById(id uint64) (*persistence.Rollout, error) {
+	return db.internalFindSingleRollout(nil, id)
+}
+
+func (db *AgbotPostgresqlDB) FindRollouts(filters []persistence.RFilter) ([]persistence.Rollout, error) {
+	rollouts := make([]persistence.Rollout, 0, 10)
+
+	rows, err := db.db.Query(ALL_ROLLOUT_QUERY)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying for rollouts, error: %v", err))
+	}
+
+	// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+	defer rows.Close()
+	for rows.Next() {
+		rBytes := make([]byte, 0, 2048)
+		r := new(persistence.Rollout)
+		if err := rows.Scan(&rBytes); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row: %v", err))
+		} else if err := json.Unmarshal(rBytes, r); err != nil {
+			return nil, errors.New(fmt.Sprintf("error demarshalling row: %v, error: %v", string(rBytes), err))
+		} else {
+			exclude := false
+			for _, filterFn := range filters {
+				if !filterFn(*r) {
+					exclude = true
+				}
+			}
+			if !exclude {
+				rollouts = append(rollouts, *r)
+			}
+		}
+	}
+
+	// The rows.Next() function will exit with false when done or an error occurred. Get any error encountered during iteration.
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error iterating: %v", err))
+	}
+
+	return rollouts, nil
+}
+
+// The rollout row is locked when it is read, and stays locked until the updated rollout is written and the transaction
+// is committed.
+func (db *AgbotPostgresqlDB) SingleRolloutUpdate(id uint64, fn func(persistence.Rollout) *persistence.Rollout) (*persistence.Rollout, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if mod, err := db.internalFindSingleRollout(tx, id); err != nil {
+		return nil, err
+	} else if mod == nil {
+		return nil, errors.New(fmt.Sprintf("No rollout with id %v available to update.", id))
+	} else {
+		updated := fn(*mod)
+		updated.Id = id
+		updated.UpdateTime = uint64(time.Now().Unix())
+		if err := db.updateRollout(tx, updated); err != nil {
+			return nil, err
+		} else if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+}
+
+func (db *AgbotPostgresqlDB) DeleteRollout(id uint64) error {
+	if _, err := db.db.Exec(ROLLOUT_DELETE, id); err != nil {
+		return err
+	}
+	glog.V(5).Infof("Succeeded deleting rollout %v from database.", id)
+	return nil
+}
+
+// Find the rollout record. When running in a transaction, the row is locked for update.
+func (db *AgbotPostgresqlDB) internalFindSingleRollout(tx *sql.Tx, id uint64) (*persistence.Rollout, error) {
+
+	rBytes := make([]byte, 0, 2048)
+	r := new(persistence.Rollout)
+
+	var qerr error
+	if tx == nil {
+		qerr = db.db.QueryRow(ROLLOUT_QUERY, id).Scan(&rBytes)
+	} else {
+		qerr = tx.QueryRow(ROLLOUT_QUERY_FOR_UPDATE, id).Scan(&rBytes)
+	}
+
+	if qerr == sql.ErrNoRows {
+		return nil, nil
+	} else if qerr != nil {
+		return nil, errors.New(fmt.Sprintf("error scanning row for rollout %v, error: %v", id, qerr))
+	} else if err := json.Unmarshal(rBytes, r); err != nil {
+		return nil, errors.New(fmt.Sprintf("error demarshalling row: %v, error: %v", string(rBytes), err))
+	}
+	return r, nil
+}
+
+func (db *AgbotPostgresqlDB) updateRollout(tx *sql.Tx, r *persistence.Rollout) error {
+	if rm, err := json.Marshal(r); err != nil {
+		return err
+	} else if _, err = tx.Exec(ROLLOUT_UPDATE, r.Id, rm); err != nil {
+		return err
+	} else {
+		glog.V(2).Infof("Succeeded writing rollout record %v", *r)
+	}
+	return nil
+}
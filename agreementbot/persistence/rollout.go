@@ -0,0 +1,198 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The states of a rollout. A rollout is created in the running state, and ends in the completed or rolled back state.
+// A rollout that is rolling back is on its way to the rolled back state.
+const ROLLOUT_RUNNING = "running"
+const ROLLOUT_PAUSED = "paused"
+const ROLLOUT_ROLLING_BACK = "rollingback"
+const ROLLOUT_ROLLED_BACK = "rolledback"
+const ROLLOUT_COMPLETED = "completed"
+
+// The states of a device in a rollout.
+const ROLLOUT_DEVICE_UPGRADING = "upgrading"
+const ROLLOUT_DEVICE_UPGRADED = "upgraded"
+const ROLLOUT_DEVICE_FAILED = "failed"
+const ROLLOUT_DEVICE_ROLLED_BACK = "rolledback"
+
+// What to do when the failure threshold is crossed.
+const ROLLOUT_ON_FAILURE_PAUSE = "pause"
+const ROLLOUT_ON_FAILURE_ROLLBACK = "rollback"
+
+// The number of seconds a device has to reach the new workload version before it is counted as a failure.
+const ROLLOUT_DEFAULT_TIMEOUT_S = 1800
+
+type Rollout struct {
+	Id               uint64                   `json:"record_id"`                     // unique primary key for records
+	Org              string                   `json:"org"`                           // the org of the policy being rolled out
+	PolicyName       string                   `json:"policy_name"`                   // the name of the policy being rolled out
+	Version          string                   `json:"version"`                       // the workload version the devices are moved to
+	BatchPercent     int                      `json:"batch_percent"`                 // the percentage of the devices to move in each batch
+	BatchCount       int                      `json:"batch_count"`                   // the number of devices to move in each batch, used instead of the percentage when set
+	FailureThreshold int                      `json:"failure_threshold"`             // the percentage of failed devices at which the rollout stops, 0 stops it at the first failure
+	OnFailure        string                   `json:"on_failure"`                    // pause or rollback, when the failure threshold is crossed
+	TimeoutS         int                      `json:"timeout"`                       // the number of seconds a device has to reach the new version
+	State            string                   `json:"state"`                         // the current state of the rollout
+	Message          string                   `json:"message"`                       // why the rollout is in its current state, if it is not running
+	Devices          map[string]RolloutDevice `json:"devices"`                       // the devices that have been moved, by device id
+	Finished         []string                 `json:"finished_partitions,omitempty"` // the partitions with no devices left to move
+	CreationTime     uint64                   `json:"creation_time"`                 // time when the rollout was created
+	ResumeTime       uint64                   `json:"resume_time"`                   // time when the rollout was last resumed
+	UpdateTime       uint64                   `json:"update_time"`                   // time when the rollout was last updated
+}
+
+type RolloutDevice struct {
+	State        string `json:"state"`               // the state of the device in the rollout
+	FromVersion  string `json:"from_version"`        // the workload version the device was running before it was moved
+	FromPriority int    `json:"from_priority"`       // the workload priority the device was running before it was moved, 0 if there was none
+	StartTime    uint64 `json:"start_time"`          // time when the device was moved
+	EndTime      uint64 `json:"end_time"`            // time when the device reached its final state
+	Reason       string `json:"reason"`              // why the device failed
+	Partition    string `json:"partition,omitempty"` // the primary partition of the agbot that last updated the device
+}
+
+func (r Rollout) String() string {
+	return fmt.Sprintf("Id: %v, "+
+		"Org: %v, "+
+		"PolicyName: %v, "+
+		"Version: %v, "+
+		"BatchPercent: %v, "+
+		"BatchCount: %v, "+
+		"FailureThreshold: %v, "+
+		"OnFailure: %v, "+
+		"TimeoutS: %v, "+
+		"State: %v, "+
+		"Message: %v, "+
+		"Devices: %v, "+
+		"Finished: %v, "+
+		"CreationTime: %v, "+
+		"ResumeTime: %v, "+
+		"UpdateTime: %v",
+		r.Id, r.Org, r.PolicyName, r.Version, r.BatchPercent, r.BatchCount, r.FailureThreshold, r.OnFailure,
+		r.TimeoutS, r.State, r.Message, len(r.Devices), r.Finished, r.CreationTime, r.ResumeTime, r.UpdateTime)
+}
+
+// A rollout that is running, paused or rolling back still owns the upgrades of its policy.
+func (r Rollout) IsActive() bool {
+	return r.State == ROLLOUT_RUNNING || r.State == ROLLOUT_PAUSED || r.State == ROLLOUT_ROLLING_BACK
+}
+
+// Returns the number of devices in each state.
+func (r Rollout) DeviceCounts() map[string]int {
+	counts := map[string]int{
+		ROLLOUT_DEVICE_UPGRADING:   0,
+		ROLLOUT_DEVICE_UPGRADED:    0,
+		ROLLOUT_DEVICE_FAILED:      0,
+		ROLLOUT_DEVICE_ROLLED_BACK: 0,
+	}
+	for _, d := range r.Devices {
+		counts[d.State] += 1
+	}
+	return counts
+}
+
+// Returns the number of devices that failed and the number of devices that finished moving, upgraded or failed. Devices
+// moved before the rollout was last resumed are not counted, so that a resumed rollout is not stopped again by the
+// failures that stopped it.
+func (r Rollout) Failures() (int, int) {
+	failed, finished := 0, 0
+	for _, d := range r.Devices {
+		if d.StartTime < r.ResumeTime {
+			continue
+		} else if d.State == ROLLOUT_DEVICE_FAILED {
+			failed += 1
+			finished += 1
+		} else if d.State == ROLLOUT_DEVICE_UPGRADED {
+			finished += 1
+		}
+	}
+	return failed, finished
+}
+
+// factory method for rollouts w/out persistence safety:
+func NewRollout(org string, policyName string, version string, batchPercent int, batchCount int, failureThreshold int, onFailure string, timeoutS int) (*Rollout, error) {
+
+	if org == "" || policyName == "" || version == "" {
+		return nil, errors.New("Illegal input: one of org, policyName or version is empty")
+	} else if batchPercent == 0 && batchCount == 0 {
+		return nil, errors.New("Illegal input: one of batchPercent or batchCount must be set")
+	} else if batchPercent < 0 || batchPercent > 100 || batchCount < 0 {
+		return nil, errors.New(fmt.Sprintf("Illegal input: batchPercent %v must be between 0 and 100 and batchCount %v must not be negative", batchPercent, batchCount))
+	} else if failureThreshold < 0 || failureThreshold > 100 {
+		return nil, errors.New(fmt.Sprintf("Illegal input: failureThreshold %v must be between 0 and 100", failureThreshold))
+	} else if onFailure != "" && onFailure != ROLLOUT_ON_FAILURE_PAUSE && onFailure != ROLLOUT_ON_FAILURE_ROLLBACK {
+		return nil, errors.New(fmt.Sprintf("Illegal input: onFailure %v must be %v or %v", onFailure, ROLLOUT_ON_FAILURE_PAUSE, ROLLOUT_ON_FAILURE_ROLLBACK))
+	} else if timeoutS < 0 {
+		return nil, errors.New(fmt.Sprintf("Illegal input: timeoutS %v must not be negative", timeoutS))
+	}
+
+	if onFailure == "" {
+		onFailure = ROLLOUT_ON_FAILURE_PAUSE
+	}
+	if timeoutS == 0 {
+		timeoutS = ROLLOUT_DEFAULT_TIMEOUT_S
+	}
+
+	now := uint64(time.Now().Unix())
+	return &Rollout{
+		Org:              org,
+		PolicyName:       policyName,
+		Version:          version,
+		BatchPercent:     batchPercent,
+		BatchCount:       batchCount,
+		FailureThreshold: failureThreshold,
+		OnFailure:        onFailure,
+		TimeoutS:         timeoutS,
+		State:            ROLLOUT_RUNNING,
+		Devices:          make(map[string]RolloutDevice),
+		CreationTime:     now,
+		UpdateTime:       now,
+	}, nil
+}
+
+// Set the state of a rollout, e.g. to pause it.
+func UpdateRolloutState(db AgbotDatabase, id uint64, state string, message string) (*Rollout, error) {
+	return db.SingleRolloutUpdate(id, func(r Rollout) *Rollout {
+		r.State = state
+		r.Message = message
+		return &r
+	})
+}
+
+// Resume a paused rollout.
+func ResumeRollout(db AgbotDatabase, id uint64) (*Rollout, error) {
+	return db.SingleRolloutUpdate(id, func(r Rollout) *Rollout {
+		r.State = ROLLOUT_RUNNING
+		r.Message = ""
+		r.ResumeTime = uint64(time.Now().Unix())
+		return &r
+	})
+}
+
+// Returns the rollout that is moving the devices using the policy, or nil if there is none. There is at most one active
+// rollout for a policy.
+func FindActiveRollout(db AgbotDatabase, org string, policyName string) (*Rollout, error) {
+	if rollouts, err := db.FindRollouts([]RFilter{ActiveRFilter(), PolRFilter(org, policyName)}); err != nil {
+		return nil, err
+	} else if len(rollouts) == 0 {
+		return nil, nil
+	} else {
+		return &rollouts[0], nil
+	}
+}
+
+// Filters
+func ActiveRFilter() RFilter {
+	return func(r Rollout) bool { return r.IsActive() }
+}
+
+func PolRFilter(org string, policyName string) RFilter {
+	return func(r Rollout) bool { return r.Org == org && r.PolicyName == policyName }
+}
+
+type RFilter func(Rollout) bool
@@ -0,0 +1,333 @@
+package agreementbot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/policy"
+	"strings"
+)
+
+// The /policy/evaluate API runs the same checks that the agbot runs before it makes an agreement with a node, without
+// making an agreement, and explains the result of each one. It is used to find out why a node is not getting an
+// agreement for a policy.
+
+// The input to the /policy/evaluate API. The consumer policy is given in full, or by the org and name of a policy
+// that this agbot serves. The producer side is either a node in the exchange or a list of producer policies.
+type PolicyEvaluationRequest struct {
+	ConsumerPolicy   *policy.Policy  `json:"consumerPolicy,omitempty"`
+	PolicyOrg        string          `json:"policyOrg,omitempty"`
+	PolicyName       string          `json:"policyName,omitempty"`
+	NodeId           string          `json:"nodeId,omitempty"` // org/id of the node in the exchange
+	ProducerPolicies []policy.Policy `json:"producerPolicies,omitempty"`
+}
+
+func (r *PolicyEvaluationRequest) IsValid() (bool, string) {
+	if r.ConsumerPolicy == nil && r.PolicyName == "" {
+		return false, "must specify either consumerPolicy or policyOrg and policyName"
+	} else if r.ConsumerPolicy != nil && r.PolicyName != "" {
+		return false, "must specify only one of consumerPolicy or policyName"
+	} else if r.PolicyName != "" && r.PolicyOrg == "" {
+		return false, "must specify policyOrg with policyName"
+	} else if r.NodeId == "" && len(r.ProducerPolicies) == 0 {
+		return false, "must specify either nodeId or producerPolicies"
+	} else if r.NodeId != "" && len(r.ProducerPolicies) != 0 {
+		return false, "must specify only one of nodeId or producerPolicies"
+	} else if r.NodeId != "" && (exchange.GetOrg(r.NodeId) == "" || exchange.GetId(r.NodeId) == "") {
+		return false, fmt.Sprintf("nodeId %v must be in the form org/id", r.NodeId)
+	}
+	return true, ""
+}
+
+// The names of the checks, in the order they are made.
+const (
+	EVAL_NODE_SERVICES      = "node services"
+	EVAL_MERGE_PRODUCERS    = "merge producer policies"
+	EVAL_SCHEMA_VERSION     = "policy schema version"
+	EVAL_API_SPECS          = "api specs"
+	EVAL_WORKLOAD           = "workload"
+	EVAL_CONSUMER_PROPS     = "consumer property requirements"
+	EVAL_PRODUCER_PROPS     = "producer property requirements"
+	EVAL_AGREEMENT_PROTOCOL = "agreement protocols"
+	EVAL_RESOURCE_LIMITS    = "resource limits"
+	EVAL_DATA_VERIFICATION  = "data verification"
+	EVAL_COMPATIBILITY      = "compatibility"
+)
+
+type PolicyEvaluationStep struct {
+	Check   string   `json:"check"`
+	Passed  bool     `json:"passed"`
+	Details string   `json:"details"`
+	Reasons []string `json:"reasons,omitempty"` // the individual reasons a check failed
+}
+
+type PolicyEvaluation struct {
+	Compatible     bool                   `json:"compatible"`
+	ConsumerPolicy string                 `json:"consumerPolicy"`
+	ProducerPolicy string                 `json:"producerPolicy"` // the name of the merged producer policy
+	FailedChecks   []string               `json:"failedChecks"`
+	Steps          []PolicyEvaluationStep `json:"steps"`
+}
+
+func NewPolicyEvaluation(consumerName string) *PolicyEvaluation {
+	return &PolicyEvaluation{
+		ConsumerPolicy: consumerName,
+		FailedChecks:   make([]string, 0),
+		Steps:          make([]PolicyEvaluationStep, 0, 10),
+	}
+}
+
+func (e *PolicyEvaluation) addStep(check string, err error, details string, reasons []string) {
+	step := PolicyEvaluationStep{
+		Check:   check,
+		Passed:  err == nil,
+		Details: details,
+		Reasons: reasons,
+	}
+	if err != nil {
+		step.Details = err.Error()
+		if !e.hasFailed(check) {
+			e.FailedChecks = append(e.FailedChecks, check)
+		}
+	}
+	e.Steps = append(e.Steps, step)
+}
+
+// Add a step about the input to the evaluation, before the other steps.
+func (e *PolicyEvaluation) AddInputStep(step PolicyEvaluationStep) {
+	e.Steps = append([]PolicyEvaluationStep{step}, e.Steps...)
+	if !step.Passed {
+		e.FailedChecks = append([]string{step.Check}, e.FailedChecks...)
+		e.Compatible = false
+	}
+}
+
+func (e *PolicyEvaluation) hasFailed(check string) bool {
+	for _, c := range e.FailedChecks {
+		if c == check {
+			return true
+		}
+	}
+	return false
+}
+
+// Choose the policies of the node's registered services that the consumer policy needs, the same way that the
+// agreement worker does. If none of the node's services are needed, all of them are used so that the evaluation
+// can say what is missing.
+func NodeProducerPolicies(dev *exchange.Device, required policy.APISpecList) ([]policy.Policy, PolicyEvaluationStep) {
+
+	step := PolicyEvaluationStep{Check: EVAL_NODE_SERVICES, Passed: true, Reasons: make([]string, 0)}
+
+	needed := func(url string) bool {
+		for _, apiSpec := range required {
+			if url == cutil.FormOrgSpecUrl(apiSpec.SpecRef, apiSpec.Org) || url == apiSpec.SpecRef {
+				return true
+			}
+		}
+		return false
+	}
+
+	services := make([]exchange.Microservice, 0)
+	for _, devMS := range dev.RegisteredServices {
+		if needed(devMS.Url) {
+			services = append(services, devMS)
+		}
+	}
+	if len(services) == 0 {
+		services = dev.RegisteredServices
+	}
+
+	used := make([]string, 0)
+	pols := make([]policy.Policy, 0)
+	for _, devMS := range services {
+		if devMS.Policy == "" {
+			step.Reasons = append(step.Reasons, fmt.Sprintf("service %v has no policy", devMS.Url))
+		} else if pol, err := policy.DemarshalPolicy(devMS.Policy); err != nil {
+			step.Reasons = append(step.Reasons, fmt.Sprintf("service %v has a policy that can not be demarshalled, error: %v", devMS.Url, err))
+		} else {
+			pols = append(pols, *pol)
+			used = append(used, devMS.Url)
+		}
+		if devMS.ConfigState == exchange.SERVICE_CONFIGSTATE_SUSPENDED {
+			step.Reasons = append(step.Reasons, fmt.Sprintf("service %v is suspended on the node", devMS.Url))
+		}
+	}
+
+	if len(step.Reasons) != 0 {
+		step.Passed = false
+		step.Details = fmt.Sprintf("node %v has problems with %v of its registered services", dev.Name, len(step.Reasons))
+	} else if len(pols) == 0 {
+		step.Passed = false
+		step.Details = fmt.Sprintf("node %v has no registered services", dev.Name)
+	} else {
+		step.Details = fmt.Sprintf("using the policies of services %v", strings.Join(used, ", "))
+	}
+	return pols, step
+}
+
+// Evaluate whether the producer policies, once merged, are compatible with the consumer policy. Each of the checks
+// in policy.Are_Compatible is run on its own so that all of the failures are reported, not just the first one. If a
+// resolver is given, the services required by each workload in the consumer policy are also checked against the
+// merged producer policy, the same way the agreement worker does before it makes a proposal.
+func EvaluatePolicies(pm *policy.PolicyManager, producers []policy.Policy, consumer *policy.Policy, arch config.ArchSynonyms,
+	resolver func(wURL string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, error)) *PolicyEvaluation {
+
+	eval := NewPolicyEvaluation(consumer.Header.Name)
+
+	// Merge the producer policies into one.
+	var merged *policy.Policy
+	if len(producers) == 0 {
+		eval.addStep(EVAL_MERGE_PRODUCERS, errors.New("there are no producer policies to evaluate"), "", nil)
+		return eval
+	} else if mp, err := pm.MergeAllProducers(&producers, consumer); err != nil {
+		eval.addStep(EVAL_MERGE_PRODUCERS, err, "", nil)
+		return eval
+	} else {
+		merged = mp
+		eval.ProducerPolicy = merged.Header.Name
+		eval.addStep(EVAL_MERGE_PRODUCERS, nil, fmt.Sprintf("merged %v producer policies into %v", len(producers), merged.Header.Name), nil)
+	}
+
+	// The same checks as policy.Are_Compatible.
+	if !consumer.Is_Version(merged.Header.Version) {
+		eval.addStep(EVAL_SCHEMA_VERSION, errors.New(fmt.Sprintf("consumer policy schema version %v is not the same as producer policy schema version %v", consumer.Header.Version, merged.Header.Version)), "", nil)
+	} else {
+		eval.addStep(EVAL_SCHEMA_VERSION, nil, fmt.Sprintf("both policies are at schema version %v", consumer.Header.Version), nil)
+	}
+
+	if err := merged.APISpecs.Supports(consumer.APISpecs); err != nil {
+		eval.addStep(EVAL_API_SPECS, err, "", ExplainAPISpecs(merged.APISpecs, consumer.APISpecs))
+	} else {
+		eval.addStep(EVAL_API_SPECS, nil, fmt.Sprintf("producer api specs %v support the consumer api specs %v", merged.APISpecs, consumer.APISpecs), nil)
+	}
+
+	if err := (&consumer.CounterPartyProperties).IsSatisfiedBy(merged.Properties); err != nil {
+		eval.addStep(EVAL_CONSUMER_PROPS, errors.New(strings.TrimSpace(err.Error())), "", []string{fmt.Sprintf("consumer requires %v, producer properties are %v", consumer.CounterPartyProperties, merged.Properties)})
+	} else {
+		eval.addStep(EVAL_CONSUMER_PROPS, nil, fmt.Sprintf("producer properties satisfy %v", consumer.CounterPartyProperties), nil)
+	}
+
+	if err := (&merged.CounterPartyProperties).IsSatisfiedBy(consumer.Properties); err != nil {
+		eval.addStep(EVAL_PRODUCER_PROPS, errors.New(strings.TrimSpace(err.Error())), "", []string{fmt.Sprintf("producer requires %v, consumer properties are %v", merged.CounterPartyProperties, consumer.Properties)})
+	} else {
+		eval.addStep(EVAL_PRODUCER_PROPS, nil, fmt.Sprintf("consumer properties satisfy %v", merged.CounterPartyProperties), nil)
+	}
+
+	if agps, err := (&merged.AgreementProtocols).Intersects_With(&consumer.AgreementProtocols); err != nil {
+		eval.addStep(EVAL_AGREEMENT_PROTOCOL, err, "", []string{fmt.Sprintf("producer protocols %v, consumer protocols %v", merged.AgreementProtocols, consumer.AgreementProtocols)})
+	} else {
+		eval.addStep(EVAL_AGREEMENT_PROTOCOL, nil, fmt.Sprintf("common agreement protocols %v", *agps), nil)
+	}
+
+	if !(&consumer.ResourceLimits).IsSatisfiedBy(&merged.ResourceLimits) {
+		eval.addStep(EVAL_RESOURCE_LIMITS, errors.New(fmt.Sprintf("producer resource limits %v do not satisfy consumer resource requirements %v", merged.ResourceLimits, consumer.ResourceLimits)), "", nil)
+	} else {
+		eval.addStep(EVAL_RESOURCE_LIMITS, nil, fmt.Sprintf("producer resource limits %v satisfy consumer resource requirements %v", merged.ResourceLimits, consumer.ResourceLimits), nil)
+	}
+
+	if !merged.DataVerify.IsCompatibleWith(consumer.DataVerify) {
+		eval.addStep(EVAL_DATA_VERIFICATION, errors.New(fmt.Sprintf("producer data verification %v is not compatible with consumer data verification %v", merged.DataVerify, consumer.DataVerify)), "", nil)
+	} else {
+		eval.addStep(EVAL_DATA_VERIFICATION, nil, "data verification is compatible", nil)
+	}
+
+	// The agbot uses the highest priority workload that the node can support, so a workload that can't be used is
+	// only a failure when none of them can be used.
+	if resolver != nil && len(consumer.Workloads) != 0 {
+		workloadSteps := make([]PolicyEvaluationStep, 0, len(consumer.Workloads))
+		workloadOk := false
+		for _, workload := range consumer.Workloads {
+			step := PolicyEvaluationStep{Check: fmt.Sprintf("%v %v/%v %v %v", EVAL_WORKLOAD, workload.Org, workload.WorkloadURL, workload.Version, workload.Arch)}
+			if asl, err := resolver(workload.WorkloadURL, workload.Org, workload.Version, workload.Arch); err != nil {
+				step.Details = fmt.Sprintf("unable to resolve the services required by the workload, error: %v", err)
+			} else {
+				for ix, apiSpec := range *asl {
+					if apiSpec.Arch != "" && arch.GetCanonicalArch(apiSpec.Arch) != "" {
+						(*asl)[ix].Arch = arch.GetCanonicalArch(apiSpec.Arch)
+					}
+				}
+				if err := merged.APISpecs.Supports(*asl); err != nil {
+					step.Details = err.Error()
+					step.Reasons = ExplainAPISpecs(merged.APISpecs, *asl)
+				} else {
+					step.Passed = true
+					step.Details = fmt.Sprintf("producer api specs %v support the required services %v", merged.APISpecs, *asl)
+					workloadOk = true
+				}
+			}
+			workloadSteps = append(workloadSteps, step)
+		}
+
+		eval.Steps = append(eval.Steps, workloadSteps...)
+		if !workloadOk {
+			for _, step := range workloadSteps {
+				eval.FailedChecks = append(eval.FailedChecks, step.Check)
+			}
+		}
+	}
+
+	// The individual checks should agree with the real one.
+	if err := policy.Are_Compatible(merged, consumer); err != nil && len(eval.FailedChecks) == 0 {
+		eval.addStep(EVAL_COMPATIBILITY, err, "", nil)
+	}
+
+	eval.Compatible = len(eval.FailedChecks) == 0
+	return eval
+}
+
+// Explain why the producer api specs do not support the required api specs. This follows the logic of
+// APISpecList.Supports, which requires every producer api spec to be within the range of one of the required ones.
+func ExplainAPISpecs(producer policy.APISpecList, required policy.APISpecList) []string {
+
+	reasons := make([]string, 0)
+	if len(required) == 0 {
+		return reasons
+	}
+
+	if len(producer) < len(required) {
+		missing := make([]string, 0)
+		for _, req := range required {
+			found := false
+			for _, p := range producer {
+				if p.SpecRef == req.SpecRef && p.Org == req.Org {
+					found = true
+				}
+			}
+			if !found {
+				missing = append(missing, fmt.Sprintf("%v/%v", req.Org, req.SpecRef))
+			}
+		}
+		reasons = append(reasons, fmt.Sprintf("producer provides %v services but %v are required, missing %v", len(producer), len(required), missing))
+	}
+
+	for _, p := range producer {
+		candidates := 0
+		found := false
+		explain := make([]string, 0)
+		for _, req := range required {
+			if p.SpecRef != req.SpecRef || p.Org != req.Org {
+				continue
+			}
+			candidates++
+			if p.Arch != req.Arch {
+				explain = append(explain, fmt.Sprintf("service %v/%v is for arch %v, the required arch is %v", p.Org, p.SpecRef, p.Arch, req.Arch))
+			} else if reqVer, err := policy.Version_Expression_Factory(req.Version); err != nil {
+				explain = append(explain, fmt.Sprintf("required version %v of service %v/%v is not a valid version range, error: %v", req.Version, req.Org, req.SpecRef, err))
+			} else if ok, err := reqVer.Is_within_range(p.Version); err != nil {
+				explain = append(explain, fmt.Sprintf("version %v of service %v/%v can not be compared with the required version range %v, error: %v", p.Version, p.Org, p.SpecRef, req.Version, err))
+			} else if !ok {
+				explain = append(explain, fmt.Sprintf("version %v of service %v/%v is not within the required version range %v", p.Version, p.Org, p.SpecRef, req.Version))
+			} else {
+				found = true
+			}
+		}
+		if candidates == 0 {
+			reasons = append(reasons, fmt.Sprintf("service %v/%v version %v provided by the producer is not required", p.Org, p.SpecRef, p.Version))
+		} else if !found {
+			reasons = append(reasons, explain...)
+		}
+	}
+	return reasons
+}
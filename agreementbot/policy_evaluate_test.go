@@ -0,0 +1,179 @@
+// +build unit
+
+package agreementbot
+
+import (
+	"errors"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/policy"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func evalProducer(name string, ref string, vers string, color string) policy.Policy {
+	pol := policy.Policy_Factory(name)
+	pol.Add_API_Spec(policy.APISpecification_Factory(ref, "myorg", vers, "amd64"))
+	pol.Add_Agreement_Protocol(policy.AgreementProtocol_Factory(policy.BasicProtocol))
+	pol.Add_Property(policy.Property_Factory("color", color))
+	return *pol
+}
+
+func evalConsumer(vers string, color string) *policy.Policy {
+	pol := policy.Policy_Factory("consumer")
+	pol.Add_API_Spec(policy.APISpecification_Factory("gps", "myorg", vers, "amd64"))
+	pol.Add_Agreement_Protocol(policy.AgreementProtocol_Factory(policy.BasicProtocol))
+	pol.Add_CounterPartyProperties(&policy.RequiredProperty{"and": []interface{}{map[string]interface{}{"name": "color", "value": color}}})
+	return pol
+}
+
+func Test_EvaluatePolicies_compatible(t *testing.T) {
+
+	pm := policy.PolicyManager_Factory(false, false)
+	producers := []policy.Policy{evalProducer("gps producer", "gps", "1.0.0", "red")}
+
+	eval := EvaluatePolicies(pm, producers, evalConsumer("1.0.0", "red"), config.ArchSynonyms{}, nil)
+	assert.True(t, eval.Compatible, eval.FailedChecks)
+	assert.Equal(t, 0, len(eval.FailedChecks))
+	assert.Equal(t, "consumer", eval.ConsumerPolicy)
+	for _, step := range eval.Steps {
+		assert.True(t, step.Passed, step.Check)
+	}
+}
+
+func Test_EvaluatePolicies_incompatible(t *testing.T) {
+
+	pm := policy.PolicyManager_Factory(false, false)
+	producers := []policy.Policy{evalProducer("gps producer", "gps", "1.0.0", "red")}
+
+	// Both the version range and the property requirement fail, and both are reported.
+	eval := EvaluatePolicies(pm, producers, evalConsumer("[2.0.0,3.0.0)", "blue"), config.ArchSynonyms{}, nil)
+	assert.False(t, eval.Compatible)
+	assert.Equal(t, []string{EVAL_API_SPECS, EVAL_CONSUMER_PROPS}, eval.FailedChecks)
+	for _, step := range eval.Steps {
+		if step.Check == EVAL_API_SPECS {
+			assert.Equal(t, []string{"version 1.0.0 of service myorg/gps is not within the required version range [2.0.0,3.0.0)"}, step.Reasons)
+		}
+	}
+
+	// No producers at all.
+	eval = EvaluatePolicies(pm, []policy.Policy{}, evalConsumer("1.0.0", "red"), config.ArchSynonyms{}, nil)
+	assert.False(t, eval.Compatible)
+	assert.Equal(t, []string{EVAL_MERGE_PRODUCERS}, eval.FailedChecks)
+}
+
+func Test_EvaluatePolicies_workloads(t *testing.T) {
+
+	pm := policy.PolicyManager_Factory(false, false)
+	producers := []policy.Policy{evalProducer("gps producer", "gps", "1.0.0", "red")}
+
+	consumer := evalConsumer("1.0.0", "red")
+	consumer.Add_Workload(&policy.Workload{WorkloadURL: "new", Org: "myorg", Version: "2.0.0", Arch: "amd64", Priority: policy.WorkloadPriority{PriorityValue: 1}})
+	consumer.Add_Workload(&policy.Workload{WorkloadURL: "old", Org: "myorg", Version: "1.0.0", Arch: "amd64", Priority: policy.WorkloadPriority{PriorityValue: 2}})
+
+	resolver := func(wURL string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, error) {
+		asl := new(policy.APISpecList)
+		switch wURL {
+		case "new":
+			(*asl) = append((*asl), *policy.APISpecification_Factory("gps", "myorg", "2.0.0", "x86_64"))
+		case "old":
+			(*asl) = append((*asl), *policy.APISpecification_Factory("gps", "myorg", "1.0.0", "x86_64"))
+		default:
+			return nil, errors.New("not found")
+		}
+		return asl, nil
+	}
+	arch := config.ArchSynonyms{"x86_64": "amd64"}
+
+	// The lower priority workload can be used, so the node is compatible even though the other one can't.
+	eval := EvaluatePolicies(pm, producers, consumer, arch, resolver)
+	assert.True(t, eval.Compatible, eval.FailedChecks)
+	failed := 0
+	for _, step := range eval.Steps {
+		if !step.Passed {
+			failed++
+			assert.Equal(t, "workload myorg/new 2.0.0 amd64", step.Check)
+		}
+	}
+	assert.Equal(t, 1, failed)
+
+	// When none of the workloads can be used they are all failures.
+	consumer.Workloads = consumer.Workloads[:1]
+	eval = EvaluatePolicies(pm, producers, consumer, arch, resolver)
+	assert.False(t, eval.Compatible)
+	assert.Equal(t, []string{"workload myorg/new 2.0.0 amd64"}, eval.FailedChecks)
+}
+
+func Test_ExplainAPISpecs(t *testing.T) {
+
+	producer := policy.APISpecList{
+		*policy.APISpecification_Factory("gps", "myorg", "1.0.0", "arm"),
+		*policy.APISpecification_Factory("cpu", "myorg", "1.0.0", "amd64"),
+	}
+	required := policy.APISpecList{
+		*policy.APISpecification_Factory("gps", "myorg", "1.0.0", "amd64"),
+		*policy.APISpecification_Factory("net", "myorg", "1.0.0", "amd64"),
+		*policy.APISpecification_Factory("camera", "myorg", "1.0.0", "amd64"),
+	}
+
+	reasons := ExplainAPISpecs(producer, required)
+	assert.Equal(t, []string{
+		"producer provides 2 services but 3 are required, missing [myorg/net myorg/camera]",
+		"service myorg/gps is for arch arm, the required arch is amd64",
+		"service myorg/cpu version 1.0.0 provided by the producer is not required",
+	}, reasons)
+
+	assert.Equal(t, 0, len(ExplainAPISpecs(producer, policy.APISpecList{})))
+}
+
+func Test_NodeProducerPolicies(t *testing.T) {
+
+	gps := evalProducer("gps producer", "gps", "1.0.0", "red")
+	serial, _ := policy.MarshalPolicy(&gps)
+
+	dev := &exchange.Device{
+		Name: "node1",
+		RegisteredServices: []exchange.Microservice{
+			{Url: "myorg/gps", Policy: serial, ConfigState: exchange.SERVICE_CONFIGSTATE_ACTIVE},
+			{Url: "myorg/cpu", Policy: ""},
+		},
+	}
+
+	// Only the service that is needed is used.
+	required := policy.APISpecList{*policy.APISpecification_Factory("gps", "myorg", "1.0.0", "amd64")}
+	pols, step := NodeProducerPolicies(dev, required)
+	assert.True(t, step.Passed, step.Reasons)
+	assert.Equal(t, 1, len(pols))
+
+	// A suspended service is reported.
+	dev.RegisteredServices[0].ConfigState = exchange.SERVICE_CONFIGSTATE_SUSPENDED
+	pols, step = NodeProducerPolicies(dev, required)
+	assert.False(t, step.Passed)
+	assert.Equal(t, []string{"service myorg/gps is suspended on the node"}, step.Reasons)
+}
+
+func Test_PolicyEvaluationRequest_IsValid(t *testing.T) {
+
+	consumer := evalConsumer("1.0.0", "red")
+	producers := []policy.Policy{evalProducer("gps producer", "gps", "1.0.0", "red")}
+
+	for _, r := range []PolicyEvaluationRequest{
+		{ConsumerPolicy: consumer, ProducerPolicies: producers},
+		{PolicyOrg: "myorg", PolicyName: "consumer", NodeId: "myorg/node1"},
+	} {
+		ok, msg := r.IsValid()
+		assert.True(t, ok, msg)
+	}
+
+	for _, r := range []PolicyEvaluationRequest{
+		{NodeId: "myorg/node1"},
+		{ConsumerPolicy: consumer, PolicyOrg: "myorg", PolicyName: "consumer", NodeId: "myorg/node1"},
+		{PolicyName: "consumer", NodeId: "myorg/node1"},
+		{ConsumerPolicy: consumer},
+		{ConsumerPolicy: consumer, NodeId: "myorg/node1", ProducerPolicies: producers},
+		{ConsumerPolicy: consumer, NodeId: "node1"},
+	} {
+		ok, _ := r.IsValid()
+		assert.False(t, ok, r)
+	}
+}
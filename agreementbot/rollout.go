@@ -0,0 +1,564 @@
+package agreementbot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/policy"
+	"sort"
+	"time"
+)
+
+// A rollout moves the devices using a policy to a new workload version a batch at a time, instead of all at once when the
+// policy file changes. The new version has to be the highest priority workload in the policy. Each device in a batch is
+// given a forced workload upgrade, the same one the /policy/{name}/upgrade API does, and the next batch is started when
+// every device in the batch is running the new version, i.e. its data was verified or, when the policy does not verify
+// data, its agreement was finalized. A device fails when its workload usage retries fall back to a lower priority version,
+// or when it does not reach the new version in time. When the failure rate crosses the threshold, the rollout is paused,
+// or it is rolled back by setting the workload usage of each moved device to its previous priority and cancelling its
+// agreement.
+//
+// While a rollout is active, a change to the policy file does not cancel the agreements of the policy.
+//
+// When several agbots share a postgresql database, each agbot only sees the agreements in the partitions it owns, so each
+// one moves its own devices in batches. A device is checked by the agbot that sees its agreement, or when no agbot does,
+// by the agbot whose partition the device record was last updated from. An agbot with no devices left to move marks its
+// partitions finished, and the rollout completes when every partition in the database is finished.
+
+// The input to the POST /rollout API.
+type RolloutRequest struct {
+	Org              string `json:"org"`
+	PolicyName       string `json:"policyName"`
+	Version          string `json:"version"`
+	BatchPercent     int    `json:"batchPercent,omitempty"`
+	BatchCount       int    `json:"batchCount,omitempty"`
+	FailureThreshold int    `json:"failureThreshold,omitempty"`
+	OnFailure        string `json:"onFailure,omitempty"` // pause or rollback
+	TimeoutS         int    `json:"timeout,omitempty"`
+}
+
+func (r *RolloutRequest) IsValid() (bool, string) {
+	if r.Org == "" || r.PolicyName == "" || r.Version == "" {
+		return false, "must specify org, policyName and version"
+	} else if r.BatchPercent == 0 && r.BatchCount == 0 {
+		return false, "must specify either batchPercent or batchCount"
+	} else if r.BatchPercent != 0 && r.BatchCount != 0 {
+		return false, "must specify only one of batchPercent or batchCount"
+	}
+	return true, ""
+}
+
+// The actions of the POST /rollout/{id}/{action} API.
+const ROLLOUT_ACTION_PAUSE = "pause"
+const ROLLOUT_ACTION_RESUME = "resume"
+const ROLLOUT_ACTION_ROLLBACK = "rollback"
+
+// The agreement a device has for the policy being rolled out, as seen by this agbot.
+type rolloutAgreement struct {
+	AgreementId   string
+	Protocol      string
+	Version       string // the workload version in the agreement
+	Priority      int    // the workload priority in the agreement, 0 if the policy has no priorities
+	InceptionTime uint64 // when the agreement was started
+	Running       bool   // the agreement's data was verified, or it was finalized when data is not verified
+	DeferredUntil uint64 // when a maintenance window lets the agreement be upgraded, 0 if it is not waiting for one
+}
+
+// The partitions of the agreement database, as seen by this agbot. The bolt database has a single global partition.
+type rolloutPartitions struct {
+	Primary string   // the partition this agbot makes new agreements in
+	Owned   []string // the partitions this agbot maintains
+	All     []string // the partitions of every agbot
+}
+
+func (p rolloutPartitions) owns(partition string) bool {
+	for _, owned := range p.Owned {
+		if owned == partition {
+			return true
+		}
+	}
+	return false
+}
+
+// A device record from a partition that no longer exists, because it was moved to another agbot, or from before
+// partitions were recorded, is checked by the agbot with the lowest primary partition.
+func (p rolloutPartitions) adopts(partition string) bool {
+	lowest := ""
+	for _, part := range p.All {
+		if part == partition {
+			return false
+		} else if lowest == "" || part < lowest {
+			lowest = part
+		}
+	}
+	return p.Primary == lowest
+}
+
+// The changes one pass of the rollout controller makes to a rollout. The plan is made from a copy of the rollout, and
+// only applied if the rollout has not been changed since, see applyTo.
+type rolloutPlan struct {
+	From     string                                // the state of the rollout the plan was made from
+	State    string                                // the new state of the rollout, empty if unchanged
+	Message  string                                // why the rollout is in its state
+	Devices  map[string]persistence.RolloutDevice  // the device records that changed or were added
+	Before   map[string]*persistence.RolloutDevice // the device records as they were when the plan was made, nil when added
+	Upgrade  []string                              // the devices to move to the new version
+	Rollback []string                              // the devices to move back to their previous version
+	Finished map[string]bool                       // the partitions that are now finished, or no longer finished
+}
+
+func newRolloutPlan(r persistence.Rollout) *rolloutPlan {
+	return &rolloutPlan{
+		From:     r.State,
+		Message:  r.Message,
+		Devices:  make(map[string]persistence.RolloutDevice),
+		Before:   make(map[string]*persistence.RolloutDevice),
+		Upgrade:  make([]string, 0),
+		Rollback: make([]string, 0),
+		Finished: make(map[string]bool),
+	}
+}
+
+func (p *rolloutPlan) setDevice(r persistence.Rollout, deviceId string, d persistence.RolloutDevice) {
+	if before, ok := r.Devices[deviceId]; ok {
+		p.Before[deviceId] = &before
+	} else {
+		p.Before[deviceId] = nil
+	}
+	p.Devices[deviceId] = d
+}
+
+// Apply the plan to the current rollout record, and return the devices that should be upgraded and rolled back. Nothing is
+// applied when the state of the rollout changed since the plan was made, e.g. it was paused. A device record is only changed
+// if it is still the same as it was when the plan was made, so that another agbot's changes are not overwritten.
+func (p *rolloutPlan) applyTo(r *persistence.Rollout) ([]string, []string) {
+	upgrades := make([]string, 0)
+	rollbacks := make([]string, 0)
+	if r.State != p.From {
+		return upgrades, rollbacks
+	}
+
+	if r.Devices == nil {
+		r.Devices = make(map[string]persistence.RolloutDevice)
+	}
+	for id, d := range p.Devices {
+		current, ok := r.Devices[id]
+		before := p.Before[id]
+		if (before == nil && ok) || (before != nil && (!ok || current != *before)) {
+			continue
+		}
+		r.Devices[id] = d
+		if before == nil && d.State == persistence.ROLLOUT_DEVICE_UPGRADING {
+			upgrades = append(upgrades, id)
+		}
+	}
+	for _, id := range p.Rollback {
+		if d, ok := r.Devices[id]; ok && d == p.Devices[id] {
+			rollbacks = append(rollbacks, id)
+		}
+	}
+
+	for partition, finished := range p.Finished {
+		r.Finished = setPartitionFinished(r.Finished, partition, finished)
+	}
+
+	if p.State != "" {
+		r.State = p.State
+	}
+	r.Message = p.Message
+	return upgrades, rollbacks
+}
+
+// Add a partition to, or remove it from, the finished partitions of a rollout.
+func setPartitionFinished(finished []string, partition string, isFinished bool) []string {
+	res := make([]string, 0, len(finished)+1)
+	for _, p := range finished {
+		if p != partition {
+			res = append(res, p)
+		}
+	}
+	if isFinished {
+		res = append(res, partition)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// The number of devices to move in each batch.
+func rolloutBatchSize(r persistence.Rollout, total int) int {
+	if r.BatchCount > 0 {
+		return r.BatchCount
+	}
+	size := (total*r.BatchPercent + 99) / 100
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// Plan the next step of a running or paused rollout. The devices that are upgrading are checked first. Then, if the rollout
+// is running, the failure threshold is checked, and when the whole batch is done, the next batch is chosen. A new batch
+// is not started when startBatch is false. Only the devices this agbot is responsible for are checked and moved, see
+// rolloutPartitions.
+func planRollout(r persistence.Rollout, ags map[string]rolloutAgreement, parts rolloutPartitions, startBatch bool, now uint64) *rolloutPlan {
+	plan := newRolloutPlan(r)
+
+	// Check the devices that are upgrading.
+	devices := make(map[string]persistence.RolloutDevice, len(r.Devices))
+	upgrading, owned := 0, 0
+	for id, d := range r.Devices {
+		devices[id] = d
+
+		ag, ok := ags[id]
+		if !ok && !parts.owns(d.Partition) && !parts.adopts(d.Partition) {
+			continue
+		}
+		owned += 1
+		if d.State != persistence.ROLLOUT_DEVICE_UPGRADING {
+			continue
+		}
+
+		d.Partition = parts.Primary
+		if ok && ag.InceptionTime >= d.StartTime && ag.Version == r.Version && ag.Running {
+			d.State = persistence.ROLLOUT_DEVICE_UPGRADED
+		} else if ok && ag.InceptionTime >= d.StartTime && ag.Version != r.Version {
+			d.State = persistence.ROLLOUT_DEVICE_FAILED
+			d.Reason = fmt.Sprintf("the workload retries fell back to version %v", ag.Version)
+		} else if ok && ag.InceptionTime < d.StartTime && ag.DeferredUntil > d.StartTime {
+			// The upgrade is waiting for the device's maintenance window, so the timeout starts when the window opens.
+			d.StartTime = ag.DeferredUntil
+			devices[id] = d
+			plan.setDevice(r, id, d)
+			upgrading += 1
+			continue
+		} else if now >= d.StartTime+uint64(r.TimeoutS) {
+			d.State = persistence.ROLLOUT_DEVICE_FAILED
+			d.Reason = fmt.Sprintf("not running version %v after %v seconds", r.Version, r.TimeoutS)
+		} else {
+			upgrading += 1
+			continue
+		}
+		d.EndTime = now
+		devices[id] = d
+		plan.setDevice(r, id, d)
+	}
+
+	if r.State != persistence.ROLLOUT_RUNNING {
+		return plan
+	}
+
+	// Stop the rollout when too many devices have failed.
+	checked := r
+	checked.Devices = devices
+	if failed, finished := checked.Failures(); failed != 0 && failed*100 >= r.FailureThreshold*finished {
+		plan.Message = fmt.Sprintf("%v of %v devices failed to upgrade, the failure threshold is %v%%", failed, finished, r.FailureThreshold)
+		if r.OnFailure == persistence.ROLLOUT_ON_FAILURE_ROLLBACK {
+			plan.State = persistence.ROLLOUT_ROLLING_BACK
+		} else {
+			plan.State = persistence.ROLLOUT_PAUSED
+		}
+		return plan
+	}
+
+	// Wait for the current batch to finish.
+	if upgrading != 0 || !startBatch {
+		return plan
+	}
+
+	// The devices that are not running the new version and have not been moved yet are the candidates for the next batch.
+	candidates := make([]string, 0)
+	for id, ag := range ags {
+		if _, ok := devices[id]; !ok && ag.Version != r.Version {
+			candidates = append(candidates, id)
+		}
+	}
+
+	// With no devices left to move, this agbot's partitions are finished. The rollout is complete when every partition is
+	// finished and no other agbot is still waiting for a device.
+	finished := make(map[string]bool)
+	for _, p := range r.Finished {
+		finished[p] = true
+	}
+	if len(candidates) == 0 {
+		for _, p := range parts.Owned {
+			if !finished[p] {
+				plan.Finished[p] = true
+				finished[p] = true
+			}
+		}
+		for _, p := range parts.All {
+			if !finished[p] {
+				return plan
+			}
+		}
+		for _, d := range devices {
+			if d.State == persistence.ROLLOUT_DEVICE_UPGRADING {
+				return plan
+			}
+		}
+		plan.State = persistence.ROLLOUT_COMPLETED
+		return plan
+	}
+	sort.Strings(candidates)
+
+	// New agreements were made in a partition that was already finished.
+	for _, p := range parts.Owned {
+		if finished[p] {
+			plan.Finished[p] = false
+		}
+	}
+
+	total := owned
+	for id := range ags {
+		if _, ok := devices[id]; !ok {
+			total += 1
+		}
+	}
+	size := rolloutBatchSize(r, total)
+	if size > len(candidates) {
+		size = len(candidates)
+	}
+
+	for _, id := range candidates[:size] {
+		plan.setDevice(r, id, persistence.RolloutDevice{
+			State:        persistence.ROLLOUT_DEVICE_UPGRADING,
+			FromVersion:  ags[id].Version,
+			FromPriority: ags[id].Priority,
+			StartTime:    now,
+			Partition:    parts.Primary,
+		})
+		plan.Upgrade = append(plan.Upgrade, id)
+	}
+	return plan
+}
+
+// Plan the rollback of a rollout. Every device that was moved, and did not fail, is moved back to the workload priority it
+// was running before. A device can only be moved back when the policy has workload priorities. The rollout is rolled back
+// once no agbot has a device left to move back.
+func planRollback(r persistence.Rollout, ags map[string]rolloutAgreement, parts rolloutPartitions, now uint64) *rolloutPlan {
+	plan := newRolloutPlan(r)
+
+	pending := false
+	for id, d := range r.Devices {
+		if d.State != persistence.ROLLOUT_DEVICE_UPGRADED && d.State != persistence.ROLLOUT_DEVICE_UPGRADING {
+			continue
+		} else if _, ok := ags[id]; !ok && !parts.owns(d.Partition) && !parts.adopts(d.Partition) {
+			pending = true
+			continue
+		}
+		if d.FromPriority == 0 {
+			d.State = persistence.ROLLOUT_DEVICE_FAILED
+			d.Reason = fmt.Sprintf("unable to roll back, version %v does not have a workload priority", d.FromVersion)
+		} else {
+			d.State = persistence.ROLLOUT_DEVICE_ROLLED_BACK
+			plan.Rollback = append(plan.Rollback, id)
+		}
+		d.EndTime = now
+		d.Partition = parts.Primary
+		plan.setDevice(r, id, d)
+	}
+	sort.Strings(plan.Rollback)
+
+	if !pending {
+		plan.State = persistence.ROLLOUT_ROLLED_BACK
+	}
+	return plan
+}
+
+// Returns the workload in the policy with the given priority.
+func workloadAtPriority(pol *policy.Policy, priority int) *policy.Workload {
+	for ix, wl := range pol.Workloads {
+		if wl.Priority.PriorityValue == priority {
+			return &pol.Workloads[ix]
+		}
+	}
+	return nil
+}
+
+// Returns true if there is an active rollout for the policy. A database error is logged and treated as no rollout.
+func (w *AgreementBotWorker) rolloutActive(org string, policyName string) bool {
+	if rollout, err := persistence.FindActiveRollout(w.db, org, policyName); err != nil {
+		glog.Errorf(RolloutlogString(fmt.Sprintf("error searching database for rollouts of policy %v/%v, error: %v", org, policyName, err)))
+		return false
+	} else {
+		return rollout != nil
+	}
+}
+
+// This function is called by the rollout governance subworker.
+func (w *AgreementBotWorker) GovernRollouts() int {
+
+	if rollouts, err := w.db.FindRollouts([]persistence.RFilter{persistence.ActiveRFilter()}); err != nil {
+		glog.Errorf(RolloutlogString(fmt.Sprintf("unable to read rollouts from database, error: %v", err)))
+	} else {
+		for _, r := range rollouts {
+			w.governRollout(r)
+		}
+	}
+	return 0
+}
+
+func (w *AgreementBotWorker) governRollout(r persistence.Rollout) {
+
+	glog.V(5).Infof(RolloutlogString(fmt.Sprintf("governing rollout %v", r)))
+
+	pol := w.pm.GetPolicy(r.Org, r.PolicyName)
+	if pol == nil {
+		if r.State == persistence.ROLLOUT_RUNNING {
+			if _, err := persistence.UpdateRolloutState(w.db, r.Id, persistence.ROLLOUT_PAUSED, fmt.Sprintf("policy %v/%v is not served by this agbot", r.Org, r.PolicyName)); err != nil {
+				glog.Errorf(RolloutlogString(fmt.Sprintf("unable to pause rollout %v, error: %v", r.Id, err)))
+			}
+		}
+		return
+	}
+
+	ags, err := w.rolloutAgreements(r)
+	if err != nil {
+		glog.Errorf(RolloutlogString(fmt.Sprintf("unable to find the agreements of rollout %v, error: %v", r.Id, err)))
+		return
+	}
+
+	parts := rolloutPartitions{Primary: w.db.PrimaryPartition(), Owned: w.db.AllPartitions()}
+	if parts.All, err = w.db.FindPartitions(); err != nil {
+		glog.Errorf(RolloutlogString(fmt.Sprintf("unable to find the database partitions for rollout %v, error: %v", r.Id, err)))
+		return
+	}
+
+	now := uint64(time.Now().Unix())
+	var plan *rolloutPlan
+
+	switch r.State {
+	case persistence.ROLLOUT_ROLLING_BACK:
+		plan = planRollback(r, ags, parts, now)
+
+	default:
+		// New devices are only moved when the new version is the workload that a forced upgrade chooses.
+		startBatch := true
+		if top := pol.NextHighestPriorityWorkload(0, 0, 0); top == nil || top.Version != r.Version {
+			startBatch = false
+		}
+		plan = planRollout(r, ags, parts, startBatch, now)
+		if r.State == persistence.ROLLOUT_RUNNING && plan.State == "" {
+			if startBatch {
+				plan.Message = ""
+			} else {
+				plan.Message = fmt.Sprintf("waiting for version %v to be the highest priority workload in policy %v", r.Version, r.PolicyName)
+			}
+		}
+	}
+
+	// Record the plan, then upgrade the devices that were added to the rollout and roll back the devices that were moved
+	// back, so that no other agbot acts on the same devices.
+	var upgrades, rollbacks []string
+	if _, err := w.db.SingleRolloutUpdate(r.Id, func(cur persistence.Rollout) *persistence.Rollout {
+		upgrades, rollbacks = plan.applyTo(&cur)
+		return &cur
+	}); err != nil {
+		glog.Errorf(RolloutlogString(fmt.Sprintf("unable to update rollout %v, error: %v", r.Id, err)))
+		return
+	}
+
+	if plan.State != "" {
+		glog.V(3).Infof(RolloutlogString(fmt.Sprintf("rollout %v of policy %v/%v changed from %v to %v. %v", r.Id, r.Org, r.PolicyName, r.State, plan.State, plan.Message)))
+	}
+
+	for _, id := range upgrades {
+		ag := ags[id]
+		glog.V(3).Infof(RolloutlogString(fmt.Sprintf("rollout %v upgrading device %v from version %v to %v", r.Id, id, ag.Version, r.Version)))
+		w.Messages() <- events.NewABApiWorkloadUpgradeMessage(events.WORKLOAD_UPGRADE, ag.Protocol, ag.AgreementId, id, r.PolicyName)
+	}
+
+	for _, id := range rollbacks {
+		if err := w.rollbackDevice(r, pol, id, plan.Devices[id], ags); err != nil {
+			glog.Errorf(RolloutlogString(fmt.Sprintf("unable to roll back device %v in rollout %v, error: %v", id, r.Id, err)))
+			w.failRollback(r.Id, id, plan.Devices[id], err)
+		}
+	}
+}
+
+// Record that a device which was recorded as rolled back could not be moved back.
+func (w *AgreementBotWorker) failRollback(rolloutId uint64, deviceId string, rolledBack persistence.RolloutDevice, rollbackErr error) {
+	if _, err := w.db.SingleRolloutUpdate(rolloutId, func(cur persistence.Rollout) *persistence.Rollout {
+		if d, ok := cur.Devices[deviceId]; ok && d == rolledBack {
+			d.State = persistence.ROLLOUT_DEVICE_FAILED
+			d.Reason = fmt.Sprintf("unable to roll back, error: %v", rollbackErr)
+			cur.Devices[deviceId] = d
+		}
+		return &cur
+	}); err != nil {
+		glog.Errorf(RolloutlogString(fmt.Sprintf("unable to record the failed rollback of device %v in rollout %v, error: %v", deviceId, rolloutId, err)))
+	}
+}
+
+// Find the current agreements of the devices using the rollout's policy, in the partitions owned by this agbot.
+func (w *AgreementBotWorker) rolloutAgreements(r persistence.Rollout) (map[string]rolloutAgreement, error) {
+
+	policyFilter := func(a persistence.Agreement) bool {
+		return a.Org == r.Org && a.PolicyName == r.PolicyName && a.AgreementCreationTime != 0 && a.AgreementTimedout == 0
+	}
+
+	ags := make(map[string]rolloutAgreement)
+	for agp, cph := range w.consumerPH {
+		agreements, err := w.db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter(), policyFilter}, agp)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ag := range agreements {
+			if proposal, err := cph.AgreementProtocolHandler("", "", "").DemarshalProposal(ag.Proposal); err != nil {
+				glog.Errorf(RolloutlogString(fmt.Sprintf("unable to demarshal proposal for agreement %v, error: %v", ag.CurrentAgreementId, err)))
+			} else if tcPolicy, err := policy.DemarshalPolicy(proposal.TsAndCs()); err != nil {
+				glog.Errorf(RolloutlogString(fmt.Sprintf("unable to demarshal terms and conditions for agreement %v, error: %v", ag.CurrentAgreementId, err)))
+			} else if len(tcPolicy.Workloads) == 0 {
+				glog.Errorf(RolloutlogString(fmt.Sprintf("agreement %v has no workload", ag.CurrentAgreementId)))
+			} else if existing, ok := ags[ag.DeviceId]; !ok || existing.InceptionTime < ag.AgreementInceptionTime {
+				ags[ag.DeviceId] = rolloutAgreement{
+					AgreementId:   ag.CurrentAgreementId,
+					Protocol:      agp,
+					Version:       tcPolicy.Workloads[0].Version,
+					Priority:      tcPolicy.Workloads[0].Priority.PriorityValue,
+					InceptionTime: ag.AgreementInceptionTime,
+					Running:       ag.AgreementFinalizedTime != 0 && (ag.DisableDataVerificationChecks || ag.DataVerifiedTime > ag.AgreementCreationTime),
+					DeferredUntil: ag.DeferredUntil,
+				}
+			}
+		}
+	}
+	return ags, nil
+}
+
+// Move a device back to the workload priority it was running before it was moved. The workload usage record is set to the
+// previous priority so that the next agreement is made with the previous version, and then the current agreement is cancelled.
+func (w *AgreementBotWorker) rollbackDevice(r persistence.Rollout, pol *policy.Policy, deviceId string, d persistence.RolloutDevice, ags map[string]rolloutAgreement) error {
+
+	wl := workloadAtPriority(pol, d.FromPriority)
+	if wl == nil {
+		return errors.New(fmt.Sprintf("policy %v does not have a workload at priority %v", r.PolicyName, d.FromPriority))
+	}
+
+	ag, hasAgreement := ags[deviceId]
+
+	if wlUsage, err := w.db.FindSingleWorkloadUsageByDeviceAndPolicyName(deviceId, r.PolicyName); err != nil {
+		return err
+	} else if wlUsage != nil {
+		if _, err := w.db.UpdatePriority(deviceId, r.PolicyName, wl.Priority.PriorityValue, wl.Priority.RetryDurationS, wl.Priority.VerifiedDurationS, ag.AgreementId); err != nil {
+			return err
+		}
+	} else if !hasAgreement {
+		return errors.New(fmt.Sprintf("device %v has neither an agreement nor a workload usage record", deviceId))
+	} else if err := w.db.NewWorkloadUsage(deviceId, []string{}, "", r.PolicyName, wl.Priority.PriorityValue, wl.Priority.RetryDurationS, wl.Priority.VerifiedDurationS, false, ag.AgreementId); err != nil {
+		return err
+	}
+
+	if hasAgreement {
+		glog.V(3).Infof(RolloutlogString(fmt.Sprintf("rollout %v rolling back device %v to version %v", r.Id, deviceId, wl.Version)))
+		w.Commands <- NewAgreementTimeoutCommand(ag.AgreementId, ag.Protocol, w.consumerPH[ag.Protocol].GetTerminationCode(TERM_REASON_CANCEL_FORCED_UPGRADE))
+	}
+	return nil
+}
+
+var RolloutlogString = func(v interface{}) string {
+	return fmt.Sprintf("AgreementBotWorker Rollout %v", v)
+}
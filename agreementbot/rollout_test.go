@@ -0,0 +1,319 @@
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"testing"
+)
+
+func newTestRollout(t *testing.T, batchPercent int, batchCount int, failureThreshold int, onFailure string) persistence.Rollout {
+	r, err := persistence.NewRollout("myorg", "mypolicy", "2.0.0", batchPercent, batchCount, failureThreshold, onFailure, 100)
+	if err != nil {
+		t.Fatalf("unexpected error creating rollout: %v", err)
+	}
+	r.Id = 1
+	return *r
+}
+
+// The partitions of an agbot using the bolt database.
+var globalPartitions = rolloutPartitions{Primary: "global", Owned: []string{"global"}, All: []string{"global"}}
+
+func runningAgreement(version string, priority int, inception uint64) rolloutAgreement {
+	return rolloutAgreement{AgreementId: "ag-" + version, Protocol: "Basic", Version: version, Priority: priority, InceptionTime: inception, Running: true}
+}
+
+func Test_NewRollout_invalid(t *testing.T) {
+	if _, err := persistence.NewRollout("", "mypolicy", "2.0.0", 10, 0, 0, "", 0); err == nil {
+		t.Errorf("expected error for missing org")
+	} else if _, err := persistence.NewRollout("myorg", "mypolicy", "2.0.0", 0, 0, 0, "", 0); err == nil {
+		t.Errorf("expected error for missing batch size")
+	} else if _, err := persistence.NewRollout("myorg", "mypolicy", "2.0.0", 101, 0, 0, "", 0); err == nil {
+		t.Errorf("expected error for batch percent over 100")
+	} else if _, err := persistence.NewRollout("myorg", "mypolicy", "2.0.0", 10, 0, 0, "retry", 0); err == nil {
+		t.Errorf("expected error for unknown onFailure")
+	} else if r, err := persistence.NewRollout("myorg", "mypolicy", "2.0.0", 10, 0, 0, "", 0); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if r.OnFailure != persistence.ROLLOUT_ON_FAILURE_PAUSE || r.TimeoutS != persistence.ROLLOUT_DEFAULT_TIMEOUT_S || r.State != persistence.ROLLOUT_RUNNING {
+		t.Errorf("expected defaults to be set, was %v", r)
+	}
+}
+
+func Test_rolloutBatchSize(t *testing.T) {
+	if size := rolloutBatchSize(newTestRollout(t, 10, 0, 0, ""), 25); size != 3 {
+		t.Errorf("expected batch size 3, was %v", size)
+	} else if size := rolloutBatchSize(newTestRollout(t, 10, 0, 0, ""), 5); size != 1 {
+		t.Errorf("expected batch size 1, was %v", size)
+	} else if size := rolloutBatchSize(newTestRollout(t, 0, 4, 0, ""), 25); size != 4 {
+		t.Errorf("expected batch size 4, was %v", size)
+	}
+}
+
+// The first batch is chosen from the devices that are not running the new version, in device id order.
+func Test_planRollout_first_batch(t *testing.T) {
+	r := newTestRollout(t, 50, 0, 0, "")
+	ags := map[string]rolloutAgreement{
+		"myorg/d3": runningAgreement("1.0.0", 2, 10),
+		"myorg/d1": runningAgreement("1.0.0", 2, 10),
+		"myorg/d2": runningAgreement("1.0.0", 2, 10),
+		"myorg/d4": runningAgreement("2.0.0", 1, 10),
+	}
+
+	plan := planRollout(r, ags, globalPartitions, true, 100)
+	if plan.State != "" {
+		t.Errorf("expected no state change, was %v", plan.State)
+	} else if len(plan.Upgrade) != 2 || plan.Upgrade[0] != "myorg/d1" || plan.Upgrade[1] != "myorg/d2" {
+		t.Errorf("expected d1 and d2 to be upgraded, was %v", plan.Upgrade)
+	} else if d := plan.Devices["myorg/d1"]; d.State != persistence.ROLLOUT_DEVICE_UPGRADING || d.FromVersion != "1.0.0" || d.FromPriority != 2 || d.StartTime != 100 {
+		t.Errorf("unexpected device record %v", d)
+	}
+
+	// Nothing is started when the new version is not the highest priority workload yet.
+	if plan := planRollout(r, ags, globalPartitions, false, 100); len(plan.Upgrade) != 0 {
+		t.Errorf("expected no upgrades, was %v", plan.Upgrade)
+	}
+}
+
+// The next batch waits until the devices that are upgrading are running the new version.
+func Test_planRollout_wait_for_batch(t *testing.T) {
+	r := newTestRollout(t, 0, 1, 0, "")
+	r.Devices["myorg/d1"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADING, FromVersion: "1.0.0", FromPriority: 2, StartTime: 100}
+	ags := map[string]rolloutAgreement{
+		"myorg/d1": runningAgreement("1.0.0", 2, 50),
+		"myorg/d2": runningAgreement("1.0.0", 2, 50),
+	}
+
+	// d1 still has its old agreement.
+	plan := planRollout(r, ags, globalPartitions, true, 150)
+	if len(plan.Devices) != 0 || len(plan.Upgrade) != 0 {
+		t.Errorf("expected no changes, was %v and %v", plan.Devices, plan.Upgrade)
+	}
+
+	// d1 has a new agreement with the new version, but its data is not verified yet.
+	ag := runningAgreement("2.0.0", 1, 120)
+	ag.Running = false
+	ags["myorg/d1"] = ag
+	plan = planRollout(r, ags, globalPartitions, true, 150)
+	if len(plan.Devices) != 0 || len(plan.Upgrade) != 0 {
+		t.Errorf("expected no changes, was %v and %v", plan.Devices, plan.Upgrade)
+	}
+
+	// d1 is running the new version, so d2 is next.
+	ags["myorg/d1"] = runningAgreement("2.0.0", 1, 120)
+	plan = planRollout(r, ags, globalPartitions, true, 150)
+	if d := plan.Devices["myorg/d1"]; d.State != persistence.ROLLOUT_DEVICE_UPGRADED || d.EndTime != 150 {
+		t.Errorf("expected d1 to be upgraded, was %v", d)
+	} else if len(plan.Upgrade) != 1 || plan.Upgrade[0] != "myorg/d2" {
+		t.Errorf("expected d2 to be upgraded, was %v", plan.Upgrade)
+	}
+
+	// Once every device was moved, the rollout is complete.
+	r.Devices["myorg/d1"] = plan.Devices["myorg/d1"]
+	r.Devices["myorg/d2"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADED, StartTime: 150, EndTime: 200}
+	ags["myorg/d2"] = runningAgreement("2.0.0", 1, 160)
+	if plan := planRollout(r, ags, globalPartitions, true, 250); plan.State != persistence.ROLLOUT_COMPLETED {
+		t.Errorf("expected the rollout to complete, was %v", plan.State)
+	}
+}
+
+// A device fails when its retries fall back to the old version or it times out, and the rollout stops at the threshold.
+func Test_planRollout_failures(t *testing.T) {
+	r := newTestRollout(t, 0, 2, 50, persistence.ROLLOUT_ON_FAILURE_ROLLBACK)
+	r.Devices["myorg/d1"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADING, FromVersion: "1.0.0", FromPriority: 2, StartTime: 100}
+	r.Devices["myorg/d2"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADING, FromVersion: "1.0.0", FromPriority: 2, StartTime: 100}
+	ags := map[string]rolloutAgreement{
+		"myorg/d1": runningAgreement("1.0.0", 2, 150),
+		"myorg/d2": runningAgreement("2.0.0", 1, 120),
+		"myorg/d3": runningAgreement("1.0.0", 2, 50),
+	}
+
+	// d1 fell back to the old version, 1 of 2 devices failed, which is at the threshold.
+	plan := planRollout(r, ags, globalPartitions, true, 160)
+	if d := plan.Devices["myorg/d1"]; d.State != persistence.ROLLOUT_DEVICE_FAILED || d.Reason == "" {
+		t.Errorf("expected d1 to fail, was %v", d)
+	} else if plan.State != persistence.ROLLOUT_ROLLING_BACK {
+		t.Errorf("expected the rollout to roll back, was %v", plan.State)
+	} else if len(plan.Upgrade) != 0 {
+		t.Errorf("expected no upgrades, was %v", plan.Upgrade)
+	}
+
+	// A device that does not reach the new version in time fails, and the rollout pauses.
+	r = newTestRollout(t, 0, 1, 0, "")
+	r.Devices["myorg/d1"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADING, FromVersion: "1.0.0", FromPriority: 2, StartTime: 100}
+	ags = map[string]rolloutAgreement{"myorg/d1": runningAgreement("1.0.0", 2, 50)}
+	if plan := planRollout(r, ags, globalPartitions, true, 199); len(plan.Devices) != 0 {
+		t.Errorf("expected d1 to still be upgrading, was %v", plan.Devices)
+	} else if plan := planRollout(r, ags, globalPartitions, true, 200); plan.Devices["myorg/d1"].State != persistence.ROLLOUT_DEVICE_FAILED || plan.State != persistence.ROLLOUT_PAUSED {
+		t.Errorf("expected d1 to time out and the rollout to pause, was %v and %v", plan.Devices, plan.State)
+	}
+
+	// Once resumed, the failures from before are not counted again.
+	r.Devices["myorg/d1"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_FAILED, StartTime: 100, EndTime: 200}
+	r.ResumeTime = 300
+	ags["myorg/d2"] = runningAgreement("1.0.0", 2, 50)
+	if plan := planRollout(r, ags, globalPartitions, true, 300); plan.State != "" || len(plan.Upgrade) != 1 || plan.Upgrade[0] != "myorg/d2" {
+		t.Errorf("expected the rollout to continue with d2, was %v and %v", plan.State, plan.Upgrade)
+	}
+}
+
+// A device whose upgrade is waiting for a maintenance window does not time out until the window has been open for the timeout.
+func Test_planRollout_deferred(t *testing.T) {
+	r := newTestRollout(t, 0, 1, 0, "")
+	r.Devices["myorg/d1"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADING, FromVersion: "1.0.0", FromPriority: 2, StartTime: 100}
+	ag := runningAgreement("1.0.0", 2, 50)
+	ag.DeferredUntil = 500
+	ags := map[string]rolloutAgreement{"myorg/d1": ag}
+
+	plan := planRollout(r, ags, globalPartitions, true, 200)
+	if d := plan.Devices["myorg/d1"]; d.State != persistence.ROLLOUT_DEVICE_UPGRADING || d.StartTime != 500 {
+		t.Errorf("expected d1 to be upgrading from 500, was %v", d)
+	} else if plan.State != "" || len(plan.Upgrade) != 0 {
+		t.Errorf("expected the rollout to wait for d1, was %v and %v", plan.State, plan.Upgrade)
+	}
+
+	r.Devices["myorg/d1"] = plan.Devices["myorg/d1"]
+	ags["myorg/d1"] = runningAgreement("1.0.0", 2, 50)
+	if plan := planRollout(r, ags, globalPartitions, true, 599); len(plan.Devices) != 0 {
+		t.Errorf("expected d1 to still be upgrading, was %v", plan.Devices)
+	} else if plan := planRollout(r, ags, globalPartitions, true, 600); plan.Devices["myorg/d1"].State != persistence.ROLLOUT_DEVICE_FAILED {
+		t.Errorf("expected d1 to time out, was %v", plan.Devices)
+	}
+}
+
+// A paused rollout keeps checking the devices that are upgrading, but does not start a new batch.
+func Test_planRollout_paused(t *testing.T) {
+	r := newTestRollout(t, 0, 1, 0, "")
+	r.State = persistence.ROLLOUT_PAUSED
+	r.Devices["myorg/d1"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADING, FromVersion: "1.0.0", FromPriority: 2, StartTime: 100}
+	ags := map[string]rolloutAgreement{
+		"myorg/d1": runningAgreement("2.0.0", 1, 120),
+		"myorg/d2": runningAgreement("1.0.0", 2, 50),
+	}
+
+	plan := planRollout(r, ags, globalPartitions, true, 150)
+	if plan.Devices["myorg/d1"].State != persistence.ROLLOUT_DEVICE_UPGRADED {
+		t.Errorf("expected d1 to be upgraded, was %v", plan.Devices["myorg/d1"])
+	} else if plan.State != "" || len(plan.Upgrade) != 0 {
+		t.Errorf("expected no state change and no upgrades, was %v and %v", plan.State, plan.Upgrade)
+	}
+}
+
+func Test_planRollback(t *testing.T) {
+	r := newTestRollout(t, 0, 1, 0, persistence.ROLLOUT_ON_FAILURE_ROLLBACK)
+	r.State = persistence.ROLLOUT_ROLLING_BACK
+	r.Devices["myorg/d1"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADED, FromVersion: "1.0.0", FromPriority: 2}
+	r.Devices["myorg/d2"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_FAILED, FromVersion: "1.0.0", FromPriority: 2}
+	r.Devices["myorg/d3"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADING, FromVersion: "1.0.0"}
+
+	plan := planRollback(r, map[string]rolloutAgreement{}, globalPartitions, 500)
+	if plan.State != persistence.ROLLOUT_ROLLED_BACK {
+		t.Errorf("expected the rollout to be rolled back, was %v", plan.State)
+	} else if len(plan.Rollback) != 1 || plan.Rollback[0] != "myorg/d1" {
+		t.Errorf("expected d1 to be rolled back, was %v", plan.Rollback)
+	} else if _, ok := plan.Devices["myorg/d2"]; ok {
+		t.Errorf("expected failed device d2 to be left alone")
+	} else if d := plan.Devices["myorg/d3"]; d.State != persistence.ROLLOUT_DEVICE_FAILED {
+		t.Errorf("expected d3 without a priority to fail, was %v", d)
+	}
+}
+
+// A plan is only applied when the rollout and its devices have not changed since it was made.
+func Test_rolloutPlan_applyTo(t *testing.T) {
+	r := newTestRollout(t, 0, 2, 0, "")
+	ags := map[string]rolloutAgreement{
+		"myorg/d1": runningAgreement("1.0.0", 2, 50),
+		"myorg/d2": runningAgreement("1.0.0", 2, 50),
+	}
+	plan := planRollout(r, ags, globalPartitions, true, 100)
+
+	// Another agbot already moved d2.
+	cur := r
+	cur.Devices = map[string]persistence.RolloutDevice{"myorg/d2": {State: persistence.ROLLOUT_DEVICE_UPGRADING, StartTime: 90}}
+	if upgrades, _ := plan.applyTo(&cur); len(upgrades) != 1 || upgrades[0] != "myorg/d1" {
+		t.Errorf("expected only d1 to be upgraded, was %v", upgrades)
+	} else if cur.Devices["myorg/d2"].StartTime != 90 {
+		t.Errorf("expected d2 to be unchanged, was %v", cur.Devices["myorg/d2"])
+	}
+
+	// The rollout was paused after the plan was made.
+	cur = r
+	cur.Devices = map[string]persistence.RolloutDevice{}
+	cur.State = persistence.ROLLOUT_PAUSED
+	if upgrades, _ := plan.applyTo(&cur); len(upgrades) != 0 || len(cur.Devices) != 0 {
+		t.Errorf("expected nothing to be applied, was %v and %v", upgrades, cur.Devices)
+	}
+
+	// A device is only rolled back when its record was applied, so that two agbots do not roll back the same device.
+	r = newTestRollout(t, 0, 1, 0, persistence.ROLLOUT_ON_FAILURE_ROLLBACK)
+	r.State = persistence.ROLLOUT_ROLLING_BACK
+	r.Devices["myorg/d1"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADED, FromVersion: "1.0.0", FromPriority: 2}
+	r.Devices["myorg/d2"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADED, FromVersion: "1.0.0", FromPriority: 2}
+	plan = planRollback(r, map[string]rolloutAgreement{}, globalPartitions, 500)
+
+	cur = r
+	cur.Devices = map[string]persistence.RolloutDevice{
+		"myorg/d1": r.Devices["myorg/d1"],
+		"myorg/d2": {State: persistence.ROLLOUT_DEVICE_ROLLED_BACK, FromVersion: "1.0.0", FromPriority: 2, EndTime: 450},
+	}
+	if _, rollbacks := plan.applyTo(&cur); len(rollbacks) != 1 || rollbacks[0] != "myorg/d1" {
+		t.Errorf("expected only d1 to be rolled back, was %v", rollbacks)
+	} else if cur.Devices["myorg/d2"].EndTime != 450 {
+		t.Errorf("expected d2 to be unchanged, was %v", cur.Devices["myorg/d2"])
+	}
+}
+
+// With several agbots, each one only checks and moves the devices it is responsible for, and the rollout completes when
+// every partition is finished.
+func Test_planRollout_partitions(t *testing.T) {
+	r := newTestRollout(t, 0, 1, 0, "")
+	parts := rolloutPartitions{Primary: "p1", Owned: []string{"p1"}, All: []string{"p1", "p2"}}
+
+	// d2 was moved by the other agbot, which sees its agreement, so it does not time out here.
+	r.Devices["myorg/d2"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADING, FromVersion: "1.0.0", FromPriority: 2, StartTime: 100, Partition: "p2"}
+	ags := map[string]rolloutAgreement{"myorg/d1": runningAgreement("1.0.0", 2, 50)}
+
+	plan := planRollout(r, ags, parts, true, 500)
+	if _, ok := plan.Devices["myorg/d2"]; ok {
+		t.Errorf("expected d2 to be left to the other agbot, was %v", plan.Devices["myorg/d2"])
+	} else if len(plan.Upgrade) != 1 || plan.Upgrade[0] != "myorg/d1" || plan.Devices["myorg/d1"].Partition != "p1" {
+		t.Errorf("expected d1 to be upgraded from p1, was %v and %v", plan.Upgrade, plan.Devices)
+	}
+
+	// d1 is running the new version. This agbot is finished, but the other one is not.
+	r.Devices["myorg/d1"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADED, StartTime: 500, EndTime: 600, Partition: "p1"}
+	ags["myorg/d1"] = runningAgreement("2.0.0", 1, 550)
+	plan = planRollout(r, ags, parts, true, 700)
+	if plan.State != "" || !plan.Finished["p1"] {
+		t.Errorf("expected p1 to be finished and the rollout to continue, was %v and %v", plan.State, plan.Finished)
+	}
+	cur := r
+	plan.applyTo(&cur)
+	if len(cur.Finished) != 1 || cur.Finished[0] != "p1" {
+		t.Errorf("expected p1 to be recorded as finished, was %v", cur.Finished)
+	}
+
+	// The other agbot finished d2, and has no devices left, so the rollout is complete.
+	cur.Devices["myorg/d2"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADED, StartTime: 100, EndTime: 300, Partition: "p2"}
+	other := rolloutPartitions{Primary: "p2", Owned: []string{"p2"}, All: []string{"p1", "p2"}}
+	if plan := planRollout(cur, map[string]rolloutAgreement{}, other, true, 800); plan.State != persistence.ROLLOUT_COMPLETED {
+		t.Errorf("expected the rollout to complete, was %v", plan.State)
+	}
+
+	// A device from a partition that no longer exists is checked by the agbot with the lowest partition.
+	r = newTestRollout(t, 0, 1, 0, "")
+	r.Devices["myorg/d3"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADING, FromVersion: "1.0.0", FromPriority: 2, StartTime: 100, Partition: "p3"}
+	if plan := planRollout(r, map[string]rolloutAgreement{}, other, true, 500); len(plan.Devices) != 0 {
+		t.Errorf("expected d3 to be left to p1, was %v", plan.Devices)
+	} else if plan := planRollout(r, map[string]rolloutAgreement{}, parts, true, 500); plan.Devices["myorg/d3"].State != persistence.ROLLOUT_DEVICE_FAILED {
+		t.Errorf("expected d3 to time out, was %v", plan.Devices)
+	}
+
+	// A rollback waits for the other agbot to move its devices back.
+	r = newTestRollout(t, 0, 1, 0, persistence.ROLLOUT_ON_FAILURE_ROLLBACK)
+	r.State = persistence.ROLLOUT_ROLLING_BACK
+	r.Devices["myorg/d1"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADED, FromVersion: "1.0.0", FromPriority: 2, Partition: "p1"}
+	r.Devices["myorg/d2"] = persistence.RolloutDevice{State: persistence.ROLLOUT_DEVICE_UPGRADED, FromVersion: "1.0.0", FromPriority: 2, Partition: "p2"}
+	if plan := planRollback(r, map[string]rolloutAgreement{}, parts, 900); plan.State != "" || len(plan.Rollback) != 1 || plan.Rollback[0] != "myorg/d1" {
+		t.Errorf("expected only d1 to be rolled back, was %v and %v", plan.State, plan.Rollback)
+	}
+}
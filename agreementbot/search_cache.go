@@ -0,0 +1,226 @@
+package agreementbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/metrics"
+	"golang.org/x/crypto/sha3"
+	"time"
+)
+
+// The Node Search Cache's job is to remember which nodes the agbot has already considered for each policy, so that
+// the agbot does not have to re-evaluate every node returned by the exchange search on every new contract interval.
+//
+// The cache is a map of policies (keyed by org and policy name), each of which holds the time of the last search
+// (the changedSince watermark) and a map of the nodes that were handled, along with a hash of what the search returned
+// for the node. The watermark is sent to the exchange so that it can return only the nodes that have changed since the
+// last search. A node returned by the search whose hash has not changed is skipped. Nodes that the agbot has an
+// agreement, or an agreement attempt, with are not remembered as handled. Instead they are kept in a map of nodes in
+// progress, which are added to the results of every incremental search, because the exchange does not return a node
+// when its agreement ends. That way a node is considered again as soon as its agreement ends. Every so often a full
+// search is made without the watermark and the node maps are cleared, so that nodes whose changes were missed are
+// picked up again.
+
+var (
+	nodeSearches     = metrics.NewCounterVec("anax_agbot_node_searches_total", "Number of node searches made in the exchange, by type of search.", "type")
+	nodeSearchCache  = metrics.NewCounterVec("anax_agbot_node_search_cache_total", "Number of nodes returned by node searches, by whether they were skipped (hit) or evaluated (miss).", "result")
+	nodeSearchCached = metrics.NewGaugeVec("anax_agbot_node_search_cache_nodes", "Number of nodes in the node search cache, by policy.", "org", "policy")
+)
+
+const (
+	SEARCH_FULL        = "full"
+	SEARCH_INCREMENTAL = "incremental"
+)
+
+type SearchCacheEntry struct {
+	LastSearchTime int64                                  // The time of the last search, nodes that changed after this time are returned by the next search
+	LastFullSearch int64                                  // The time of the last full search
+	Nodes          map[string][]byte                      // The hash of the search result for each node that has been handled, keyed by node id
+	InProgress     map[string]exchange.SearchResultDevice // The nodes with an agreement in progress, keyed by node id
+}
+
+func (s *SearchCacheEntry) String() string {
+	return fmt.Sprintf("Search Cache Entry LastSearchTime: %v, "+
+		"LastFullSearch: %v, "+
+		"Nodes: %v, "+
+		"InProgress: %v",
+		s.LastSearchTime, s.LastFullSearch, len(s.Nodes), len(s.InProgress))
+}
+
+func NewSearchCacheEntry() *SearchCacheEntry {
+	se := &SearchCacheEntry{
+		LastSearchTime: 0,
+		LastFullSearch: 0,
+		Nodes:          make(map[string][]byte),
+		InProgress:     make(map[string]exchange.SearchResultDevice),
+	}
+	return se
+}
+
+type NodeSearchCache struct {
+	Policies      map[string]*SearchCacheEntry // A map of the policies that the agbot has searched for
+	FullSearchSec int64                        // How often to make a full search, 0 turns off the cache
+}
+
+func (n *NodeSearchCache) String() string {
+	return fmt.Sprintf("FullSearchSec: %v, Policies: %v",
+		n.FullSearchSec, n.Policies)
+}
+
+func NewNodeSearchCache(fullSearchSec int) *NodeSearchCache {
+	sc := &NodeSearchCache{
+		Policies:      make(map[string]*SearchCacheEntry),
+		FullSearchSec: int64(fullSearchSec),
+	}
+	return sc
+}
+
+// Start a search for the given policy. The returned time is the changedSince watermark to send to the exchange, 0 when
+// a full search is needed. The current time is returned so that it can be passed to EndSearch once the search succeeds.
+func (c *NodeSearchCache) StartSearch(org string, policyName string) (int64, int64) {
+
+	now := time.Now().Unix()
+	key := getSearchKey(org, policyName)
+
+	se, ok := c.Policies[key]
+	if !ok || c.FullSearchSec <= 0 || se.LastSearchTime == 0 || now-se.LastFullSearch >= c.FullSearchSec {
+		nodeSearches.Inc(SEARCH_FULL)
+		return 0, now
+	}
+
+	nodeSearches.Inc(SEARCH_INCREMENTAL)
+	return se.LastSearchTime, now
+
+}
+
+// Record the completion of a search that was started at searchTime with the given changedSince watermark. A full search
+// clears the nodes that were remembered from earlier searches, it returns all of them again.
+func (c *NodeSearchCache) EndSearch(org string, policyName string, changedSince int64, searchTime int64) {
+
+	key := getSearchKey(org, policyName)
+
+	se, ok := c.Policies[key]
+	if !ok {
+		se = NewSearchCacheEntry()
+		c.Policies[key] = se
+	}
+
+	if changedSince == 0 {
+		se.Nodes = make(map[string][]byte)
+		se.InProgress = make(map[string]exchange.SearchResultDevice)
+		se.LastFullSearch = searchTime
+	}
+	se.LastSearchTime = searchTime
+
+	nodeSearchCached.Set(float64(len(se.Nodes)), org, policyName)
+}
+
+// Returns true when the node has already been handled for the policy and has not changed since.
+func (c *NodeSearchCache) Unchanged(org string, policyName string, dev *exchange.SearchResultDevice) bool {
+
+	if c.FullSearchSec <= 0 {
+		return false
+	}
+
+	key := getSearchKey(org, policyName)
+	if se, ok := c.Policies[key]; !ok {
+		nodeSearchCache.Inc("miss")
+		return false
+	} else if cached, ok := se.Nodes[dev.Id]; !ok {
+		nodeSearchCache.Inc("miss")
+		return false
+	} else if hash, err := hashSearchResult(dev); err != nil || string(hash) != string(cached) {
+		nodeSearchCache.Inc("miss")
+		return false
+	}
+
+	nodeSearchCache.Inc("hit")
+	return true
+}
+
+// Remember that the node has been handled for the policy, so that it is skipped until it changes or until the next full search.
+func (c *NodeSearchCache) Handled(org string, policyName string, dev *exchange.SearchResultDevice) {
+
+	if c.FullSearchSec <= 0 {
+		return
+	}
+
+	key := getSearchKey(org, policyName)
+	if se, ok := c.Policies[key]; !ok {
+		return
+	} else if hash, err := hashSearchResult(dev); err != nil {
+		glog.Errorf(SClogString(fmt.Sprintf("unable to cache node %v for policy %v, error: %v", dev.Id, key, err)))
+	} else {
+		se.Nodes[dev.Id] = hash
+		delete(se.InProgress, dev.Id)
+		nodeSearchCached.Set(float64(len(se.Nodes)), org, policyName)
+	}
+}
+
+// Remember that the node has an agreement in progress for the policy, so that it is considered again on the next searches
+// even when the exchange does not return it.
+func (c *NodeSearchCache) InProgress(org string, policyName string, dev *exchange.SearchResultDevice) {
+
+	if c.FullSearchSec <= 0 {
+		return
+	}
+
+	key := getSearchKey(org, policyName)
+	if se, ok := c.Policies[key]; ok {
+		se.InProgress[dev.Id] = *dev
+	}
+}
+
+// Returns the search results with the nodes that have an agreement in progress for the policy added to them. A node that
+// is in the search results is not added again, the search result is more recent.
+func (c *NodeSearchCache) AddInProgress(org string, policyName string, devices []exchange.SearchResultDevice) []exchange.SearchResultDevice {
+
+	key := getSearchKey(org, policyName)
+	se, ok := c.Policies[key]
+	if !ok || len(se.InProgress) == 0 {
+		return devices
+	}
+
+	found := make(map[string]bool, len(devices))
+	for _, dev := range devices {
+		found[dev.Id] = true
+	}
+
+	all := devices
+	for id, dev := range se.InProgress {
+		if !found[id] {
+			all = append(all, dev)
+		}
+	}
+	return all
+}
+
+// Forget everything about the policy, the next search for it will be a full search. This is used when the policy
+// changes or is deleted, because nodes that were not compatible before might be now.
+func (c *NodeSearchCache) Invalidate(org string, policyName string) {
+	key := getSearchKey(org, policyName)
+	if _, ok := c.Policies[key]; ok {
+		glog.V(5).Infof(SClogString(fmt.Sprintf("invalidating cached search results for %v", key)))
+		delete(c.Policies, key)
+		nodeSearchCached.Set(0, org, policyName)
+	}
+}
+
+func hashSearchResult(dev *exchange.SearchResultDevice) ([]byte, error) {
+	if ds, err := json.Marshal(dev); err != nil {
+		return nil, err
+	} else {
+		hash := sha3.Sum256(ds)
+		return hash[:], nil
+	}
+}
+
+func getSearchKey(org string, policyName string) string {
+	return org + "/" + policyName
+}
+
+var SClogString = func(v interface{}) string {
+	return fmt.Sprintf("AgreementBot Node Search Cache: %v", v)
+}
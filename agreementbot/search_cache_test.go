@@ -0,0 +1,191 @@
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/agreementbot/persistence/bolt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/policy"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_search_cache_full_then_incremental(t *testing.T) {
+
+	sc := NewNodeSearchCache(600)
+
+	// The first search for a policy is always a full search.
+	if changedSince, searchTime := sc.StartSearch("myorg", "pol1"); changedSince != 0 {
+		t.Errorf("first search should be full, changedSince is %v", changedSince)
+	} else {
+		sc.EndSearch("myorg", "pol1", changedSince, searchTime)
+	}
+
+	dev := exchange.SearchResultDevice{Id: "myorg/node1", PublicKey: []byte("key")}
+	if sc.Unchanged("myorg", "pol1", &dev) {
+		t.Errorf("node should not be cached yet")
+	}
+	sc.Handled("myorg", "pol1", &dev)
+	if !sc.Unchanged("myorg", "pol1", &dev) {
+		t.Errorf("node should be cached")
+	}
+
+	// A node that changed is not skipped.
+	changed := exchange.SearchResultDevice{Id: "myorg/node1", PublicKey: []byte("newkey")}
+	if sc.Unchanged("myorg", "pol1", &changed) {
+		t.Errorf("changed node should not be skipped")
+	}
+
+	// The node is only cached for the policy it was handled for.
+	if sc.Unchanged("myorg", "pol2", &dev) {
+		t.Errorf("node should not be cached for another policy")
+	}
+
+	// The next search is incremental and keeps the cached nodes.
+	if changedSince, searchTime := sc.StartSearch("myorg", "pol1"); changedSince == 0 {
+		t.Errorf("second search should be incremental")
+	} else {
+		sc.EndSearch("myorg", "pol1", changedSince, searchTime)
+	}
+	if !sc.Unchanged("myorg", "pol1", &dev) {
+		t.Errorf("node should still be cached after an incremental search")
+	}
+
+}
+
+func Test_search_cache_resync(t *testing.T) {
+
+	sc := NewNodeSearchCache(600)
+	changedSince, searchTime := sc.StartSearch("myorg", "pol1")
+	sc.EndSearch("myorg", "pol1", changedSince, searchTime)
+
+	dev := exchange.SearchResultDevice{Id: "myorg/node1"}
+	sc.Handled("myorg", "pol1", &dev)
+
+	// Pretend the last full search was long ago.
+	sc.Policies[getSearchKey("myorg", "pol1")].LastFullSearch -= 600
+	if changedSince, searchTime := sc.StartSearch("myorg", "pol1"); changedSince != 0 {
+		t.Errorf("search should be a full resync, changedSince is %v", changedSince)
+	} else {
+		sc.EndSearch("myorg", "pol1", changedSince, searchTime)
+	}
+	if sc.Unchanged("myorg", "pol1", &dev) {
+		t.Errorf("full resync should clear the cached nodes")
+	}
+
+}
+
+func Test_search_cache_invalidate(t *testing.T) {
+
+	sc := NewNodeSearchCache(600)
+	changedSince, searchTime := sc.StartSearch("myorg", "pol1")
+	sc.EndSearch("myorg", "pol1", changedSince, searchTime)
+
+	dev := exchange.SearchResultDevice{Id: "myorg/node1"}
+	sc.Handled("myorg", "pol1", &dev)
+
+	sc.Invalidate("myorg", "pol1")
+	if sc.Unchanged("myorg", "pol1", &dev) {
+		t.Errorf("node should not be cached after the policy is invalidated")
+	} else if changedSince, _ := sc.StartSearch("myorg", "pol1"); changedSince != 0 {
+		t.Errorf("search after invalidation should be full, changedSince is %v", changedSince)
+	}
+
+}
+
+func Test_search_cache_disabled(t *testing.T) {
+
+	sc := NewNodeSearchCache(-1)
+	for i := 0; i < 2; i++ {
+		if changedSince, searchTime := sc.StartSearch("myorg", "pol1"); changedSince != 0 {
+			t.Errorf("searches should always be full when the cache is off")
+		} else {
+			sc.EndSearch("myorg", "pol1", changedSince, searchTime)
+		}
+	}
+
+	dev := exchange.SearchResultDevice{Id: "myorg/node1"}
+	sc.Handled("myorg", "pol1", &dev)
+	if sc.Unchanged("myorg", "pol1", &dev) {
+		t.Errorf("nodes should never be skipped when the cache is off")
+	}
+
+}
+
+// A node with an agreement in progress is skipped, but not cached, so that it is considered again once the agreement
+// is cancelled.
+func Test_skipDevice_agreement_in_progress(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "agbot-search-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := new(bolt.AgbotBoltDB)
+	if err := db.Initialize(&config.HorizonConfig{AgreementBot: config.AGConfig{DBPath: dir}}); err != nil {
+		t.Fatalf("unable to initialize database, error: %v", err)
+	}
+	defer db.Close()
+
+	w := &AgreementBotWorker{db: db, SearchCache: NewNodeSearchCache(600)}
+	pol := policy.Policy_Factory("pol1")
+	changedSince, searchTime := w.SearchCache.StartSearch("myorg", "pol1")
+	w.SearchCache.EndSearch("myorg", "pol1", changedSince, searchTime)
+
+	dev := exchange.SearchResultDevice{Id: "myorg/node1", PublicKey: []byte("key")}
+	if w.skipDevice("myorg", pol, &dev) {
+		t.Errorf("node without an agreement should not be skipped")
+	}
+
+	if err := db.AgreementAttempt("ag1", "myorg", dev.Id, "pol1", "", "", "", policy.BasicProtocol, "", policy.NodeHealth{}); err != nil {
+		t.Fatalf("unable to create agreement, error: %v", err)
+	} else if !w.skipDevice("myorg", pol, &dev) {
+		t.Errorf("node with an agreement in progress should be skipped")
+	} else if w.SearchCache.Unchanged("myorg", "pol1", &dev) {
+		t.Errorf("node with an agreement in progress should not be cached")
+	}
+
+	// The next search is incremental, and the exchange does not return the node because it has not changed. The node
+	// is still considered because its agreement is in progress.
+	changedSince, searchTime = w.SearchCache.StartSearch("myorg", "pol1")
+	if changedSince == 0 {
+		t.Fatalf("second search should be incremental")
+	}
+	w.SearchCache.EndSearch("myorg", "pol1", changedSince, searchTime)
+	if devices := w.SearchCache.AddInProgress("myorg", "pol1", []exchange.SearchResultDevice{}); len(devices) != 1 || devices[0].Id != dev.Id {
+		t.Fatalf("node with an agreement in progress should be added to the search results, returned %v", devices)
+	} else if !w.skipDevice("myorg", pol, &devices[0]) {
+		t.Errorf("node with an agreement in progress should be skipped")
+	}
+
+	// Once the agreement is cancelled, the next incremental search makes the node available for a new agreement.
+	if _, err := persistence.ArchiveAgreement(db, "ag1", policy.BasicProtocol, 1, "cancelled"); err != nil {
+		t.Fatalf("unable to archive agreement, error: %v", err)
+	}
+	changedSince, searchTime = w.SearchCache.StartSearch("myorg", "pol1")
+	w.SearchCache.EndSearch("myorg", "pol1", changedSince, searchTime)
+	if devices := w.SearchCache.AddInProgress("myorg", "pol1", []exchange.SearchResultDevice{}); len(devices) != 1 {
+		t.Fatalf("node should be in the search results, returned %v", devices)
+	} else if w.skipDevice("myorg", pol, &devices[0]) {
+		t.Errorf("node should be considered again once its agreement is cancelled")
+	}
+
+	// When the node is handled, it is no longer added to the search results.
+	w.SearchCache.Handled("myorg", "pol1", &dev)
+	if devices := w.SearchCache.AddInProgress("myorg", "pol1", []exchange.SearchResultDevice{}); len(devices) != 0 {
+		t.Errorf("handled node should not be added to the search results, returned %v", devices)
+	}
+
+	// A node that is not ready is cached until it changes.
+	notReady := exchange.SearchResultDevice{Id: "myorg/node2"}
+	if !w.skipDevice("myorg", pol, &notReady) {
+		t.Errorf("node without a public key should be skipped")
+	} else if !w.SearchCache.Unchanged("myorg", "pol1", &notReady) {
+		t.Errorf("node without a public key should be cached")
+	}
+
+}
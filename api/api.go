@@ -6,12 +6,12 @@ import (
 	"net/http"
 	"sync"
 
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/gorilla/mux"
 	"github.com/open-horizon/anax/apicommon"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/worker"
@@ -20,7 +20,7 @@ import (
 type API struct {
 	worker.Manager // embedded field
 	name           string
-	db             *bolt.DB
+	db             persistence.EdgeDatabase
 	pm             *policy.PolicyManager
 	em             *events.EventStateManager
 	bcState        map[string]map[string]apicommon.BlockchainState
@@ -36,7 +36,7 @@ type BlockchainState struct {
 	servicePort string // the network port of the container
 }
 
-func NewAPIListener(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager) *API {
+func NewAPIListener(name string, cfg *config.HorizonConfig, db persistence.EdgeDatabase, pm *policy.PolicyManager) *API {
 	messages := make(chan events.Message)
 
 	listener := &API{
@@ -54,6 +54,10 @@ func NewAPIListener(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *pol
 		EC:          nil,
 	}
 
+	if db != nil {
+		metrics.GetDefaultRegistry().AddCollector("agreements", listener.collectAgreementMetrics)
+	}
+
 	listener.listen(cfg)
 	return listener
 }
@@ -74,10 +78,15 @@ func (a *API) router(includeStaticRedirects bool) *mux.Router {
 	router.HandleFunc("/service/config", a.serviceconfig).Methods("GET", "POST", "OPTIONS")
 	router.HandleFunc("/service/configstate", a.service_configstate).Methods("GET", "POST", "OPTIONS")
 	router.HandleFunc("/service/policy", a.servicepolicy).Methods("GET", "OPTIONS")
+	router.HandleFunc("/service/{name}/logs", a.servicelogs).Methods("GET", "OPTIONS")
 
 	// Connectivity and blockchain status info
 	router.HandleFunc("/status", a.status).Methods("GET", "OPTIONS")
 	router.HandleFunc("/status/workers", a.workerstatus).Methods("GET", "OPTIONS")
+	router.HandleFunc("/status/events", a.eventstatus).Methods("GET", "OPTIONS")
+
+	// Prometheus metrics for the node
+	router.HandleFunc("/metrics", metrics.Handler).Methods("GET", "OPTIONS")
 
 	// Used by the Registration UI to obtain a random token string
 	router.HandleFunc("/token/random", tokenRandom).Methods("GET", "OPTIONS")
@@ -86,11 +95,24 @@ func (a *API) router(includeStaticRedirects bool) *mux.Router {
 	router.HandleFunc("/node", a.node).Methods("GET", "HEAD", "POST", "PATCH", "DELETE", "OPTIONS")
 	router.HandleFunc("/node/configstate", a.nodeconfigstate).Methods("GET", "HEAD", "PUT", "OPTIONS")
 
+	// Used to back up, restore and check the node's database
+	router.HandleFunc("/node/backup", a.nodebackup).Methods("GET", "OPTIONS")
+	router.HandleFunc("/node/restore", a.noderestore).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/node/fsck", a.nodefsck).Methods("GET", "OPTIONS")
+
+	// Used to remove the images of superseded service versions
+	router.HandleFunc("/node/images/prune", a.nodeimageprune).Methods("GET", "POST", "OPTIONS")
+
+	// Used to add an image archive to the local image cache, for nodes that can't reach a registry
+	router.HandleFunc("/node/images/import", a.nodeimageimport).Methods("POST", "OPTIONS")
+
 	// Used to get the event logs on this node.
 	// get the eventlogs for current registration.
 	router.HandleFunc("/eventlog", a.eventlog).Methods("GET", "OPTIONS")
 	// get the eventlogs for all registrations.
 	router.HandleFunc("/eventlog/all", a.eventlog).Methods("GET", "OPTIONS")
+	// get the result of the last prune, or prune the eventlogs.
+	router.HandleFunc("/eventlog/prune", a.eventlogprune).Methods("GET", "POST", "OPTIONS")
 
 	// For importing workload public signing keys (RSA-PSS key pair public key)
 	router.HandleFunc("/{p:(?:publickey|trust)}", a.publickey).Methods("GET", "OPTIONS")
@@ -1,9 +1,15 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/eventlog"
+	"github.com/open-horizon/anax/persistence"
+	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -26,9 +32,23 @@ func (a *API) eventlog(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v with selection %v", r.Method, resource, r.Form)))
+		// follow is not a selector, it asks for the new event logs to be streamed after the existing ones.
+		follow := false
+		if f := r.Form.Get("follow"); f != "" {
+			if b, err := strconv.ParseBool(f); err != nil {
+				errorHandler(NewAPIUserInputError(fmt.Sprintf("Error parsing follow value %v, must be true or false. %v", f, err), "follow"))
+				return
+			} else {
+				follow = b
+			}
+			r.Form.Del("follow")
+		}
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v with selection %v, follow %v", r.Method, resource, r.Form, follow)))
 
-		if out, err := FindEventLogsForOutput(a.db, all_loags, r.Form); err != nil {
+		if follow {
+			a.streamEventLogs(w, r, all_loags, r.Form)
+		} else if out, err := FindEventLogsForOutput(a.db, all_loags, r.Form); err != nil {
 			errorHandler(NewSystemError(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
 		} else {
 			writeResponse(w, out, http.StatusOK)
@@ -42,3 +62,123 @@ func (a *API) eventlog(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+// Write the event logs that match the selections as JSON lines, the existing ones first, and then each new event log
+// as it is saved. The response ends when the client goes away.
+func (a *API) streamEventLogs(w http.ResponseWriter, r *http.Request, all_logs bool, selections map[string][]string) {
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		errorHandler(NewSystemError("Streaming event logs is not supported by the http server."))
+		return
+	}
+
+	selectors, err := persistence.ConvertToSelectors(selections)
+	if err != nil {
+		errorHandler(NewAPIUserInputError(fmt.Sprintf("Error converting the selections into Selectors: %v", err), "selection"))
+		return
+	}
+
+	// Subscribe before reading the existing event logs so that nothing is missed in between. The event logs that
+	// are in both are skipped based on the record id.
+	sub := eventlog.Subscribe()
+	defer eventlog.Unsubscribe(sub)
+
+	existing, err := FindEventLogsForOutput(a.db, all_logs, selections)
+	if err != nil {
+		errorHandler(NewSystemError(fmt.Sprintf("Error getting eventlog for output, error %v", err)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	last := uint64(0)
+	for _, el := range existing {
+		if err := enc.Encode(el); err != nil {
+			glog.V(3).Infof(apiLogString(fmt.Sprintf("Event log stream closed, error %v", err)))
+			return
+		}
+		if id, err := strconv.ParseUint(el.Id, 10, 64); err == nil && id > last {
+			last = id
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case el, ok := <-sub.Logs:
+			if !ok {
+				glog.Warningf(apiLogString("Event log stream ended because the client is not reading fast enough."))
+				return
+			}
+			if id, err := strconv.ParseUint(el.Id, 10, 64); err == nil && id <= last {
+				continue
+			} else if !el.Matches(selectors) {
+				continue
+			} else if err := enc.Encode(el); err != nil {
+				glog.V(3).Infof(apiLogString(fmt.Sprintf("Event log stream closed, error %v", err)))
+				return
+			}
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			glog.V(5).Infof(apiLogString("Event log stream closed by the client."))
+			return
+		}
+	}
+}
+
+// get the result of the last prune, or prune the eventlogs now.
+func (a *API) eventlogprune(w http.ResponseWriter, r *http.Request) {
+
+	resource := "eventlog/prune"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		if out, err := persistence.GetEventLogPruneStatus(a.db); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "POST":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		// The body can override the configured retention limits for this prune. Without a body the configured
+		// limits are used.
+		retention := a.Config.Edge.EventLogRetention
+		body, _ := ioutil.ReadAll(r.Body)
+		if len(strings.TrimSpace(string(body))) != 0 {
+			retention = config.EventLogRetentionConfig{}
+			if err := json.Unmarshal(body, &retention); err != nil {
+				errorHandler(NewAPIUserInputError(fmt.Sprintf("Input body couldn't be deserialized to %v object: %v, error: %v", resource, string(body), err), "retention"))
+				return
+			}
+		}
+
+		if !retention.IsEnabled() {
+			errorHandler(NewAPIUserInputError("no event log retention limits are configured or specified", "retention"))
+			return
+		}
+
+		if out, err := eventlog.PruneEventLogs(a.db, retention); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Error pruning event logs, error %v", err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
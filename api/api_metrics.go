@@ -0,0 +1,56 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/metrics"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+)
+
+// The states that an agreement on this node can be in, as reported by the agreement metrics.
+const (
+	AG_STATE_PROPOSED    = "proposed"
+	AG_STATE_ACCEPTED    = "accepted"
+	AG_STATE_FINALIZED   = "finalized"
+	AG_STATE_EXECUTING   = "execution_started"
+	AG_STATE_TERMINATING = "terminating"
+	AG_STATE_ARCHIVED    = "archived"
+)
+
+var agreementCount = metrics.NewGaugeVec("anax_agreements", "Number of agreements on this node, by state.", "state")
+
+// Return the state of the agreement, based on the lifecycle timestamps that have been recorded in it.
+func agreementState(ag persistence.EstablishedAgreement) string {
+	if ag.Archived {
+		return AG_STATE_ARCHIVED
+	} else if ag.AgreementTerminatedTime != 0 {
+		return AG_STATE_TERMINATING
+	} else if ag.AgreementExecutionStartTime != 0 {
+		return AG_STATE_EXECUTING
+	} else if ag.AgreementFinalizedTime != 0 {
+		return AG_STATE_FINALIZED
+	} else if ag.AgreementAcceptedTime != 0 {
+		return AG_STATE_ACCEPTED
+	}
+	return AG_STATE_PROPOSED
+}
+
+// A metrics collector that counts the agreements in the local database by state. It is called each time the
+// metrics are scraped.
+func (a *API) collectAgreementMetrics() {
+	agreements, err := persistence.FindEstablishedAgreementsAllProtocols(a.db, policy.AllAgreementProtocols(), []persistence.EAFilter{})
+	if err != nil {
+		glog.Errorf(apiLogString(fmt.Sprintf("unable to read agreements for metrics, error %v", err)))
+		return
+	}
+
+	agreementCount.Reset()
+	for _, state := range []string{AG_STATE_PROPOSED, AG_STATE_ACCEPTED, AG_STATE_FINALIZED, AG_STATE_EXECUTING, AG_STATE_TERMINATING, AG_STATE_ARCHIVED} {
+		agreementCount.Set(0, state)
+	}
+	for _, ag := range agreements {
+		agreementCount.Add(1, agreementState(ag))
+	}
+}
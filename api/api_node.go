@@ -8,10 +8,13 @@ import (
 	"strconv"
 
 	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/container"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/torrent"
 	"github.com/open-horizon/anax/version"
 	"github.com/open-horizon/anax/worker"
 )
@@ -216,3 +219,166 @@ func (a *API) nodeconfigstate(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
+
+func (a *API) nodebackup(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/backup"
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		// The backup is streamed, so once the first bytes are written an error can only be logged.
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%v\"", config.EDGE_BOLT_DB_FILE))
+		if n, err := persistence.BackupDatabase(a.db, w); err != nil {
+			LogDeviceEvent(a.db, persistence.SEVERITY_ERROR, fmt.Sprintf("Error backing up the node database. %v", err), persistence.EC_DATABASE_ERROR, nil)
+			if n == 0 {
+				w.Header().Del("Content-Disposition")
+				GetHTTPErrorHandler(w)(NewSystemError(fmt.Sprintf("Error backing up database, error %v", err)))
+			} else {
+				glog.Errorf(apiLogString(fmt.Sprintf("Error streaming database backup after %v bytes, error %v", n, err)))
+			}
+		} else {
+			glog.V(3).Infof(apiLogString(fmt.Sprintf("Wrote database backup of %v bytes", n)))
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) noderestore(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/restore"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "PUT":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		// The body is a database backup. It is validated and staged here, and swapped in when anax restarts.
+		report, err := persistence.StageRestore(a.Config, r.Body)
+		if err != nil {
+			LogDeviceEvent(a.db, persistence.SEVERITY_ERROR, fmt.Sprintf("Error staging database restore. %v", err), persistence.EC_DATABASE_RESTORE_REJECTED, nil)
+			if _, ok := err.(*persistence.InvalidBackupError); ok {
+				errorHandler(NewAPIUserInputError(err.Error(), "body"))
+			} else {
+				errorHandler(NewSystemError(fmt.Sprintf("Error staging database restore, error %v", err)))
+			}
+			return
+		}
+
+		writeResponse(w, NewRestoreStaged(report), http.StatusAccepted)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "PUT, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) nodefsck(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/fsck"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		if report, err := persistence.CheckDatabase(a.db); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Error checking database, error %v", err)))
+		} else {
+			writeResponse(w, report, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// GET returns the images of superseded service versions that a prune would remove, POST removes them.
+func (a *API) nodeimageprune(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/images/prune"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET", "POST":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		client, err := newDockerClient(a.Config)
+		if err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Unable to create docker client, error %v", err)))
+			return
+		}
+
+		dryRun := r.Method == "GET"
+		if out, err := container.PruneImages(a.db, client, a.Config.Edge.ImageGC, dryRun, "requested"); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Error pruning images, error %v", err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// POST adds the image archive in the body, from docker save or in an OCI layout, to the local image cache and loads its
+// images into docker.
+func (a *API) nodeimageimport(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/images/import"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "POST":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		dir := a.Config.GetImageCacheDir()
+		if dir == "" {
+			errorHandler(NewBadRequestError("The local image cache is disabled in the ImageCache config."))
+			return
+		}
+
+		client, err := newDockerClient(a.Config)
+		if err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Unable to create docker client, error %v", err)))
+			return
+		}
+
+		if images, err := torrent.ImportImageArchive(client, dir, r.Body); err != nil {
+			switch err.(type) {
+			case torrent.ImageArchiveError:
+				errorHandler(NewAPIUserInputError(err.Error(), "body"))
+			default:
+				errorHandler(NewSystemError(fmt.Sprintf("Error importing image archive, error %v", err)))
+			}
+		} else {
+			glog.V(3).Infof(apiLogString(fmt.Sprintf("Imported images %v", images)))
+			writeResponse(w, images, http.StatusCreated)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
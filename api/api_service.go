@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/gorilla/mux"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/containerruntime"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/persistence"
 	"io/ioutil"
 	"net/http"
+	"time"
 )
 
 func (a *API) service(w http.ResponseWriter, r *http.Request) {
@@ -237,3 +240,64 @@ func (a *API) servicepolicy(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+// Read the logs of a service container, whichever log driver it uses. The logs are written as they are read, and
+// when following, as they are logged until the client goes away.
+func (a *API) servicelogs(w http.ResponseWriter, r *http.Request) {
+
+	resource := "service/logs"
+	errorhandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		name := mux.Vars(r)["name"]
+
+		if err := r.ParseForm(); err != nil {
+			errorhandler(NewAPIUserInputError(fmt.Sprintf("Error parsing the selections %v. %v", r.Form, err), "selection"))
+			return
+		}
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v for service %v with selection %v", r.Method, resource, name, r.Form)))
+
+		opts, err := ParseLogOptions(r.Form, time.Now())
+		if errorhandler(err) {
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			errorhandler(NewSystemError("Streaming logs is not supported by the http server."))
+			return
+		}
+
+		rt, err := containerruntime.NewRuntime(a.Config)
+		if err != nil {
+			errorhandler(NewSystemError(fmt.Sprintf("Unable to create %v container runtime, error %v", a.Config.GetContainerRuntime(), err)))
+			return
+		}
+		client := containerruntime.DockerClient(rt)
+		if client == nil {
+			errorhandler(NewSystemError(fmt.Sprintf("Reading logs is not supported by the %v container runtime.", rt.Name())))
+			return
+		}
+
+		c, err := FindServiceContainer(a.db, rt, name, r.Form.Get("agreement"))
+		if errorhandler(err) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		// The status has been sent, so an error can only be logged.
+		if err := container.ContainerLogs(r.Context(), client, c.ID, *opts, a.Config.Edge.ContainerLog.SyslogFile, flushWriter{w: w, f: flusher}); err != nil {
+			glog.Errorf(apiLogString(fmt.Sprintf("Error reading the logs of service %v, error %v", name, err)))
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -40,3 +40,20 @@ func (a *API) workerstatus(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
+
+func (a *API) eventstatus(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		traces, input, err := apicommon.GetEventTraces(r.URL.Query())
+		if err != nil {
+			writeInputErr(w, http.StatusBadRequest, NewAPIUserInputError(err.Error(), input))
+			return
+		}
+		writeResponse(w, traces, http.StatusOK)
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
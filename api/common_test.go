@@ -2,14 +2,13 @@ package api
 
 import (
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
 	"io/ioutil"
 	"os"
 	"path"
-	"time"
 )
 
 // ========================================================================================
@@ -145,13 +144,13 @@ func getVariableServiceResolver(mUrl, mOrg, mVersion, mArch string, ui *exchange
 	}
 }
 
-func utsetup() (string, *bolt.DB, error) {
+func utsetup() (string, persistence.EdgeDatabase, error) {
 	dir, err := ioutil.TempDir("", "utdb-")
 	if err != nil {
 		return "", nil, err
 	}
 
-	db, err := bolt.Open(path.Join(dir, "anax-int.db"), 0600, &bolt.Options{Timeout: 10 * time.Second})
+	db, err := persistence.OpenBoltDatabase(path.Join(dir, "anax-int.db"))
 	if err != nil {
 		return dir, nil, err
 	}
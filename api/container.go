@@ -4,21 +4,24 @@ import (
 	"errors"
 	"fmt"
 	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/containerruntime"
 	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"net/http"
+	"strconv"
+	"time"
 )
 
-// Get docker container metadata from the docker API for workload containers
-func GetWorkloadContainers(dockerEndpoint string, agreementId string) ([]dockerclient.APIContainers, error) {
-	if client, err := dockerclient.NewClient(dockerEndpoint); err != nil {
-		return nil, errors.New(fmt.Sprintf("unable to create docker client from %v, error %v", dockerEndpoint, err))
+// Get docker container metadata from the container runtime for workload containers
+func GetWorkloadContainers(cfg *config.HorizonConfig, agreementId string) ([]dockerclient.APIContainers, error) {
+	if rt, err := containerruntime.NewRuntime(cfg); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create %v container runtime, error %v", cfg.GetContainerRuntime(), err))
 	} else {
-		opts := dockerclient.ListContainersOptions{
-			All: true,
-		}
-
-		if containers, err := client.ListContainers(opts); err != nil {
-			return nil, errors.New(fmt.Sprintf("unable to list docker containers from %v, error %v", dockerEndpoint, err))
+		if containers, err := rt.ListContainers(true, nil); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to list containers from %v, error %v", rt.Endpoint(), err))
 		} else {
 			ret := make([]dockerclient.APIContainers, 0, 10)
 
@@ -38,17 +41,13 @@ func GetWorkloadContainers(dockerEndpoint string, agreementId string) ([]dockerc
 	}
 }
 
-// Get docker container metadata from the docker API for microservice containers
-func GetMicroserviceContainer(dockerEndpoint string, mURL string, mOrg string, mVersion string, mInstanceId string) ([]dockerclient.APIContainers, error) {
-	if client, err := dockerclient.NewClient(dockerEndpoint); err != nil {
-		return nil, errors.New(fmt.Sprintf("unable to create docker client from %v, error %v", dockerEndpoint, err))
+// Get docker container metadata from the container runtime for microservice containers
+func GetMicroserviceContainer(cfg *config.HorizonConfig, mURL string, mOrg string, mVersion string, mInstanceId string) ([]dockerclient.APIContainers, error) {
+	if rt, err := containerruntime.NewRuntime(cfg); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create %v container runtime, error %v", cfg.GetContainerRuntime(), err))
 	} else {
-		opts := dockerclient.ListContainersOptions{
-			All: true,
-		}
-
-		if containers, err := client.ListContainers(opts); err != nil {
-			return nil, errors.New(fmt.Sprintf("unable to list docker containers from %v, error %v", dockerEndpoint, err))
+		if containers, err := rt.ListContainers(true, nil); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to list containers from %v, error %v", rt.Endpoint(), err))
 		} else {
 			ret := make([]dockerclient.APIContainers, 0, 10)
 
@@ -68,3 +67,108 @@ func GetMicroserviceContainer(dockerEndpoint string, mURL string, mOrg string, m
 		}
 	}
 }
+
+// Parse the selection of the log lines for the /service/{name}/logs API. The tail is a number of lines or "all", and
+// since is an RFC3339 time, a unix time in seconds, or a duration before now such as "10m".
+func ParseLogOptions(form map[string][]string, now time.Time) (*container.LogOptions, error) {
+	opts := new(container.LogOptions)
+
+	get := func(key string) string {
+		if v, ok := form[key]; ok && len(v) != 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	if tail := get("tail"); tail != "" && tail != "all" {
+		if n, err := strconv.Atoi(tail); err != nil || n < 0 {
+			return nil, NewAPIUserInputError(fmt.Sprintf("tail %v must be a number of lines or all", tail), "tail")
+		} else {
+			opts.Tail = n
+		}
+	}
+
+	if since := get("since"); since != "" {
+		if t, err := time.Parse(time.RFC3339, since); err == nil {
+			opts.Since = t
+		} else if secs, err := strconv.ParseInt(since, 10, 64); err == nil {
+			opts.Since = time.Unix(secs, 0)
+		} else if d, err := time.ParseDuration(since); err == nil && d >= 0 {
+			opts.Since = now.Add(-d)
+		} else {
+			return nil, NewAPIUserInputError(fmt.Sprintf("since %v must be an RFC3339 time, a unix time in seconds or a duration such as 10m", since), "since")
+		}
+	}
+
+	if follow := get("follow"); follow != "" {
+		if b, err := strconv.ParseBool(follow); err != nil {
+			return nil, NewAPIUserInputError(fmt.Sprintf("follow %v must be true or false", follow), "follow")
+		} else {
+			opts.Follow = b
+		}
+	}
+
+	return opts, nil
+}
+
+// Get the docker client of the node's container runtime, for the functions that need the docker API.
+func newDockerClient(cfg *config.HorizonConfig) (*dockerclient.Client, error) {
+	if rt, err := containerruntime.NewRuntime(cfg); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create %v container runtime, error %v", cfg.GetContainerRuntime(), err))
+	} else if client := containerruntime.DockerClient(rt); client == nil {
+		return nil, errors.New(fmt.Sprintf("the %v container runtime does not have a docker compatible API", rt.Name()))
+	} else {
+		return client, nil
+	}
+}
+
+// Find the container of a service by its name in the deployment description. The service can be in an agreement or
+// be a dependent service, the agreement id or service instance key is needed when more than one container has the name.
+func FindServiceContainer(db persistence.EdgeDatabase, rt containerruntime.Runtime, name string, agreementId string) (*dockerclient.APIContainers, error) {
+
+	ids := make([]string, 0)
+	if agreementId != "" {
+		ids = append(ids, agreementId)
+	} else if ags, err := persistence.FindEstablishedAgreementsAllProtocols(db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter()}); err != nil {
+		return nil, NewSystemError(fmt.Sprintf("unable to read agreements, error %v", err))
+	} else if msInsts, err := persistence.FindMicroserviceInstances(db, []persistence.MIFilter{persistence.UnarchivedMIFilter()}); err != nil {
+		return nil, NewSystemError(fmt.Sprintf("unable to read service instances, error %v", err))
+	} else {
+		for _, ag := range ags {
+			ids = append(ids, ag.CurrentAgreementId)
+		}
+		for _, msi := range msInsts {
+			ids = append(ids, msi.GetKey())
+		}
+	}
+
+	containers, err := container.FindServiceContainers(rt, ids, name)
+	if err != nil {
+		return nil, NewSystemError(fmt.Sprintf("unable to find the containers for service %v, error %v", name, err))
+	} else if len(containers) == 0 {
+		return nil, NewNotFoundError(fmt.Sprintf("no container found for service %v", name), "name")
+	} else if len(containers) > 1 {
+		choices := make([]string, 0, len(containers))
+		for _, c := range containers {
+			if ag := c.Labels[container.LABEL_PREFIX+".agreement_id"]; ag != "" {
+				choices = append(choices, ag)
+			} else {
+				choices = append(choices, fmt.Sprintf("%v (shared)", c.Names))
+			}
+		}
+		return nil, NewAPIUserInputError(fmt.Sprintf("%v containers found for service %v, use agreement to choose one of %v", len(containers), name, choices), "agreement")
+	}
+	return &containers[0], nil
+}
+
+// Flush each write to the client so that followed logs are seen as they are logged.
+type flushWriter struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	fw.f.Flush()
+	return n, err
+}
@@ -0,0 +1,48 @@
+// +build unit
+
+package api
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_ParseLogOptions(t *testing.T) {
+
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	opts, err := ParseLogOptions(map[string][]string{}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, opts.Tail)
+	assert.True(t, opts.Since.IsZero())
+	assert.False(t, opts.Follow)
+
+	opts, err = ParseLogOptions(map[string][]string{"tail": {"50"}, "since": {"10m"}, "follow": {"true"}}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 50, opts.Tail)
+	assert.Equal(t, now.Add(-10*time.Minute), opts.Since)
+	assert.True(t, opts.Follow)
+
+	opts, err = ParseLogOptions(map[string][]string{"tail": {"all"}, "since": {"2018-06-01T11:00:00Z"}}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, opts.Tail)
+	assert.Equal(t, now.Add(-time.Hour), opts.Since.UTC())
+
+	opts, err = ParseLogOptions(map[string][]string{"since": {"1527850800"}}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, now.Add(-time.Hour).Unix(), opts.Since.Unix())
+
+	for _, form := range []map[string][]string{
+		{"tail": {"-1"}},
+		{"tail": {"some"}},
+		{"since": {"yesterday"}},
+		{"since": {"-5m"}},
+		{"follow": {"maybe"}},
+	} {
+		_, err := ParseLogOptions(form, now)
+		assert.NotNil(t, err, form)
+		_, ok := err.(*APIUserInputError)
+		assert.True(t, ok, form)
+	}
+}
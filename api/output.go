@@ -95,6 +95,19 @@ func NewWorkloadOutput() *AllWorkloads {
 	return &AllWorkloads{}
 }
 
+// The output format for PUT node/restore
+type RestoreStaged struct {
+	Message string                  `json:"message"`
+	Report  *persistence.FsckReport `json:"fsck"` // the result of checking the backup
+}
+
+func NewRestoreStaged(report *persistence.FsckReport) *RestoreStaged {
+	return &RestoreStaged{
+		Message: "The database backup has been staged. Restart the Horizon agent to restore the database from it.",
+		Report:  report,
+	}
+}
+
 // The output format for GET service
 type AllServices struct {
 	Config      []MicroserviceConfig     `json:"config"`      // the service configurations
@@ -3,7 +3,6 @@ package api
 import (
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/persistence"
@@ -11,7 +10,7 @@ import (
 	"sort"
 )
 
-func FindAgreementsForOutput(db *bolt.DB) (map[string]map[string][]persistence.EstablishedAgreement, error) {
+func FindAgreementsForOutput(db persistence.EdgeDatabase) (map[string]map[string][]persistence.EstablishedAgreement, error) {
 
 	agreements, err := persistence.FindEstablishedAgreementsAllProtocols(db, policy.AllAgreementProtocols(), []persistence.EAFilter{})
 	if err != nil {
@@ -44,7 +43,7 @@ func FindAgreementsForOutput(db *bolt.DB) (map[string]map[string][]persistence.E
 	return wrap, nil
 }
 
-func DeleteAgreement(errorhandler ErrorHandler, agreementId string, db *bolt.DB) (bool, *events.ApiAgreementCancelationMessage) {
+func DeleteAgreement(errorhandler ErrorHandler, agreementId string, db persistence.EdgeDatabase) (bool, *events.ApiAgreementCancelationMessage) {
 
 	glog.V(3).Infof(apiLogString(fmt.Sprintf("Handling DELETE of agreement: %v", agreementId)))
 
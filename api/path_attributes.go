@@ -8,7 +8,6 @@ import (
 	"io/ioutil"
 	"reflect"
 
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/persistence"
@@ -465,6 +464,36 @@ func parseAgreementProtocol(errorhandler ErrorHandler, permitEmpty bool, given *
 	}
 }
 
+func parseMaintenance(errorhandler ErrorHandler, permitEmpty bool, given *Attribute) (*persistence.MaintenanceAttributes, bool, error) {
+	if permitEmpty {
+		return nil, errorhandler(NewAPIUserInputError("partial update unsupported", "maintenance.mappings")), nil
+	}
+
+	w, exists := (*given.Mappings)["windows"]
+	if !exists {
+		return nil, errorhandler(NewAPIUserInputError("missing key", "maintenance.mappings.windows")), nil
+	} else if _, ok := w.([]interface{}); !ok {
+		return nil, errorhandler(NewAPIUserInputError(fmt.Sprintf("expected []interface{} received %T", w), "maintenance.mappings.windows")), nil
+	} else if windows, err := policy.ConvertToMaintenanceWindowList(w); err != nil {
+		return nil, errorhandler(NewAPIUserInputError(err.Error(), "maintenance.mappings.windows")), nil
+	} else if len(windows) == 0 {
+		return nil, errorhandler(NewAPIUserInputError("array value is empty", "maintenance.mappings.windows")), nil
+	} else if err := windows.IsValid(); err != nil {
+		return nil, errorhandler(NewAPIUserInputError(err.Error(), "maintenance.mappings.windows")), nil
+	} else {
+		sps := new(persistence.ServiceSpecs)
+		if given.ServiceSpecs != nil {
+			sps = given.ServiceSpecs
+		}
+
+		return &persistence.MaintenanceAttributes{
+			Meta:         generateAttributeMetadata(*given, reflect.TypeOf(persistence.MaintenanceAttributes{}).Name()),
+			ServiceSpecs: sps,
+			Windows:      windows,
+		}, false, nil
+	}
+}
+
 // AttributeVerifier returns true if there is a handled inputError (one that caused a write to the http responsewriter) and error if there is a system processing problem
 type AttributeVerifier func(attr persistence.Attribute) (bool, error)
 
@@ -608,6 +637,13 @@ func ValidateAndConvertAPIAttribute(errorhandler ErrorHandler, permitEmpty bool,
 			}
 			attribute = attr
 
+		case reflect.TypeOf(persistence.MaintenanceAttributes{}).Name():
+			attr, inputErr, err := parseMaintenance(errorhandler, permitEmpty, &given)
+			if err != nil || inputErr {
+				return attribute, inputErr, err
+			}
+			attribute = attr
+
 		case reflect.TypeOf(persistence.HTTPSBasicAuthAttributes{}).Name():
 			attr, inputErr, err := parseHTTPSBasicAuth(errorhandler, permitEmpty, &given)
 			if err != nil || inputErr {
@@ -779,7 +815,7 @@ func payloadToAttributes(errorhandler ErrorHandler, body io.Reader, permitPartia
 // serializeAttributeForOutput retrieves attributes by url from the DB and then
 // serializes then as JSON, returning a byte array for convenient writing to an
 // HTTP response.
-func FindAndWrapAttributesForOutput(db *bolt.DB, id string) (map[string][]Attribute, error) {
+func FindAndWrapAttributesForOutput(db persistence.EdgeDatabase, id string) (map[string][]Attribute, error) {
 
 	attributes, err := persistence.FindApplicableAttributes(db, "", "")
 	if err != nil {
@@ -2,7 +2,6 @@ package api
 
 import (
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/persistence"
@@ -10,7 +9,7 @@ import (
 )
 
 // This API returns the event logs saved on the db.
-func FindEventLogsForOutput(db *bolt.DB, all_logs bool, selections map[string][]string) ([]persistence.EventLog, error) {
+func FindEventLogsForOutput(db persistence.EdgeDatabase, all_logs bool, selections map[string][]string) ([]persistence.EventLog, error) {
 
 	glog.V(5).Infof(apiLogString(fmt.Sprintf("Getting event logs from the db. The selectors are: %v.", selections)))
 
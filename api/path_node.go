@@ -3,7 +3,6 @@ package api
 import (
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
@@ -18,7 +17,7 @@ import (
 // object because it eventually gets deleted at the end of unconfiguration.
 var Unconfiguring bool
 
-func LogDeviceEvent(db *bolt.DB, severity string, message string, event_code string, device interface{}) {
+func LogDeviceEvent(db persistence.EdgeDatabase, severity string, message string, event_code string, device interface{}) {
 	id := ""
 	org := ""
 	pattern := ""
@@ -52,7 +51,7 @@ func LogDeviceEvent(db *bolt.DB, severity string, message string, event_code str
 	eventlog.LogNodeEvent(db, severity, message, event_code, id, org, pattern, state)
 }
 
-func FindHorizonDeviceForOutput(db *bolt.DB) (*HorizonDevice, error) {
+func FindHorizonDeviceForOutput(db persistence.EdgeDatabase) (*HorizonDevice, error) {
 
 	var device *HorizonDevice
 
@@ -88,7 +87,7 @@ func CreateHorizonDevice(device *HorizonDevice,
 	getPatterns exchange.PatternHandlerWithContext,
 	getExchangeVersion exchange.ExchangeVersionHandler,
 	em *events.EventStateManager,
-	db *bolt.DB) (bool, *HorizonDevice, *HorizonDevice) {
+	db persistence.EdgeDatabase) (bool, *HorizonDevice, *HorizonDevice) {
 
 	// Reject the call if the node is restarting.
 	se := events.NewNodeShutdownCompleteMessage(events.UNCONFIGURE_COMPLETE, "")
@@ -202,7 +201,7 @@ func CreateHorizonDevice(device *HorizonDevice,
 func UpdateHorizonDevice(device *HorizonDevice,
 	errorhandler ErrorHandler,
 	getExchangeVersion exchange.ExchangeVersionHandler,
-	db *bolt.DB) (bool, *HorizonDevice, *HorizonDevice) {
+	db persistence.EdgeDatabase) (bool, *HorizonDevice, *HorizonDevice) {
 
 	LogDeviceEvent(db, persistence.SEVERITY_INFO, fmt.Sprintf("Start updating node %v.", *device.Id), persistence.EC_START_NODE_UPDATE, device)
 
@@ -265,7 +264,7 @@ func DeleteHorizonDevice(removeNode string,
 	em *events.EventStateManager,
 	msgQueue chan events.Message,
 	errorhandler ErrorHandler,
-	db *bolt.DB) bool {
+	db persistence.EdgeDatabase) bool {
 
 	LogDeviceEvent(db, persistence.SEVERITY_INFO, fmt.Sprintf("Start node unregistration."), persistence.EC_START_NODE_UNREG, nil)
 
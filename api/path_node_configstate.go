@@ -3,7 +3,6 @@ package api
 import (
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/cutil"
@@ -29,7 +28,7 @@ func ValidStateChange(from string, to string) bool {
 	return false
 }
 
-func FindConfigstateForOutput(db *bolt.DB) (*Configstate, error) {
+func FindConfigstateForOutput(db persistence.EdgeDatabase) (*Configstate, error) {
 
 	var device *HorizonDevice
 
@@ -59,7 +58,7 @@ func UpdateConfigstate(cfg *Configstate,
 	getPatterns exchange.PatternHandler,
 	resolveService exchange.ServiceResolverHandler,
 	getService exchange.ServiceHandler,
-	db *bolt.DB,
+	db persistence.EdgeDatabase,
 	config *config.HorizonConfig) (bool, *Configstate, []*events.PolicyCreatedMessage) {
 
 	// Check for the device in the local database. If there are errors, they will be written
@@ -165,7 +164,7 @@ func configureService(service *Service,
 	getService exchange.ServiceHandler,
 	errorhandler ErrorHandler,
 	msgs *[]*events.PolicyCreatedMessage,
-	db *bolt.DB,
+	db persistence.EdgeDatabase,
 	config *config.HorizonConfig) bool {
 
 	var createServiceError error
@@ -208,7 +207,7 @@ func configureService(service *Service,
 
 // This function verifies that if the given workload needs variable configuration, that there is a workloadconfig
 // object holding that config.
-func workloadConfigPresent(sd *exchange.ServiceDefinition, wUrl string, wOrg, wVersion string, db *bolt.DB) (bool, error) {
+func workloadConfigPresent(sd *exchange.ServiceDefinition, wUrl string, wOrg, wVersion string, db persistence.EdgeDatabase) (bool, error) {
 
 	// If the definition needs no config, exit early.
 	if !sd.NeedsUserInput() {
@@ -241,7 +240,7 @@ func getSpecRefsForPattern(patName string,
 	patOrg string,
 	getPatterns exchange.PatternHandler,
 	resolveService exchange.ServiceResolverHandler,
-	db *bolt.DB,
+	db persistence.EdgeDatabase,
 	config *config.HorizonConfig,
 	checkWorkloadConfig bool) (*policy.APISpecList, *exchange.Pattern, error) {
 
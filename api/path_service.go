@@ -3,7 +3,6 @@ package api
 import (
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
@@ -15,7 +14,7 @@ import (
 // userInput variable config for each service, running containers for each service,
 // and the state of each service as it is being managed by anax.
 func FindServicesForOutput(pm *policy.PolicyManager,
-	db *bolt.DB,
+	db persistence.EdgeDatabase,
 	config *config.HorizonConfig) (*AllServices, error) {
 
 	// Get all the service instances that we know about from the dependent service database.
@@ -53,7 +52,7 @@ func FindServicesForOutput(pm *policy.PolicyManager,
 		if msinst.Archived {
 			wrap.Instances[archivedKey] = append(wrap.Instances[archivedKey], *NewMicroserviceInstanceOutput(msinst, nil))
 		} else {
-			containers, err := GetMicroserviceContainer(config.Edge.DockerEndpoint, msinst.SpecRef, msinst.Org, msinst.Version, msinst.InstanceId)
+			containers, err := GetMicroserviceContainer(config, msinst.SpecRef, msinst.Org, msinst.Version, msinst.InstanceId)
 			if err != nil {
 				return nil, errors.New(fmt.Sprintf("unable to get docker container info, error %v", err))
 			}
@@ -66,7 +65,7 @@ func FindServicesForOutput(pm *policy.PolicyManager,
 		if agInst.Archived {
 			wrap.Instances[archivedKey] = append(wrap.Instances[archivedKey], *NewAgreementServiceInstanceOutput(&agInst, nil))
 		} else {
-			containers, err := GetWorkloadContainers(config.Edge.DockerEndpoint, agInst.CurrentAgreementId)
+			containers, err := GetWorkloadContainers(config, agInst.CurrentAgreementId)
 			if err != nil {
 				return nil, errors.New(fmt.Sprintf("unable to get docker container info, error %v", err))
 			}
@@ -3,7 +3,6 @@ package api
 import (
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/cutil"
@@ -17,7 +16,7 @@ import (
 	"strings"
 )
 
-func LogServiceEvent(db *bolt.DB, severity string, message string, event_code string, service *Service) {
+func LogServiceEvent(db persistence.EdgeDatabase, severity string, message string, event_code string, service *Service) {
 	surl := ""
 	org := ""
 	version := "[0.0.0,INFINITY)"
@@ -40,7 +39,7 @@ func LogServiceEvent(db *bolt.DB, severity string, message string, event_code st
 	eventlog.LogServiceEvent2(db, severity, message, event_code, "", surl, org, version, arch, []string{})
 }
 
-func findPoliciesForOutput(pm *policy.PolicyManager, db *bolt.DB) (map[string]policy.Policy, error) {
+func findPoliciesForOutput(pm *policy.PolicyManager, db persistence.EdgeDatabase) (map[string]policy.Policy, error) {
 
 	out := make(map[string]policy.Policy)
 
@@ -70,7 +69,7 @@ func findPoliciesForOutput(pm *policy.PolicyManager, db *bolt.DB) (map[string]po
 	return out, nil
 }
 
-func FindServiceConfigForOutput(pm *policy.PolicyManager, db *bolt.DB) (map[string][]MicroserviceConfig, error) {
+func FindServiceConfigForOutput(pm *policy.PolicyManager, db persistence.EdgeDatabase) (map[string][]MicroserviceConfig, error) {
 
 	outConfig := make([]MicroserviceConfig, 0, 10)
 
@@ -124,7 +123,7 @@ func CreateService(service *Service,
 	getPatterns exchange.PatternHandler,
 	resolveService exchange.ServiceResolverHandler,
 	getService exchange.ServiceHandler,
-	db *bolt.DB,
+	db persistence.EdgeDatabase,
 	config *config.HorizonConfig,
 	from_user bool) (bool, *Service, *events.PolicyCreatedMessage) {
 
@@ -348,6 +347,7 @@ func CreateService(service *Service,
 	var counterPartyProperties policy.RequiredProperty
 	var properties map[string]interface{}
 	var globalAgreementProtocols []interface{}
+	var globalWindows interface{}
 
 	props := make(map[string]interface{})
 
@@ -379,6 +379,12 @@ func CreateService(service *Service,
 			hasAA = true
 		}
 
+		// Extract global maintenance windows. These apply whether or not the node is using a pattern.
+		if attr.GetMeta().Type == "MaintenanceAttributes" && apply_to_all {
+			globalWindows = attr.(persistence.MaintenanceAttributes).Windows
+			glog.V(5).Infof(apiLogString(fmt.Sprintf("Found default global maintenance attribute %v", attr)))
+		}
+
 		// Global policy attributes are ignored for devices that are using a pattern. All policy is controlled
 		// by the pattern definition.
 		if pDevice.Pattern == "" {
@@ -423,6 +429,7 @@ func CreateService(service *Service,
 	// Persist all attributes on this service, and while we're at it, fetch the attribute values we need for the node side policy file.
 	// Any policy attributes we find will overwrite values set in a global attribute of the same type.
 	var serviceAgreementProtocols []policy.AgreementProtocol
+	var serviceWindows policy.MaintenanceWindowList
 	for _, attr := range attributes {
 
 		// there may be multiple ArchitectureAttributes, we only take the first one.
@@ -462,6 +469,9 @@ func CreateService(service *Service,
 			agpl := attr.(*persistence.AgreementProtocolAttributes).Protocols
 			serviceAgreementProtocols = agpl.([]policy.AgreementProtocol)
 
+		case *persistence.MaintenanceAttributes:
+			serviceWindows = attr.(*persistence.MaintenanceAttributes).Windows.(policy.MaintenanceWindowList)
+
 		default:
 			glog.V(4).Infof(apiLogString(fmt.Sprintf("Unhandled attr type (%T): %v", attr, attr)))
 		}
@@ -488,6 +498,16 @@ func CreateService(service *Service,
 		agpList = list
 	}
 
+	// The maintenance windows on this service override any global windows that might exist.
+	windows := serviceWindows
+	if len(windows) == 0 {
+		if list, err := policy.ConvertToMaintenanceWindowList(globalWindows); err != nil {
+			return errorhandler(NewSystemError(fmt.Sprintf("Error converting global maintenance attribute %v to maintenance windows, error: %v", globalWindows, err))), nil, nil
+		} else {
+			windows = list
+		}
+	}
+
 	// Save the service definition in the local database.
 	if err := persistence.SaveOrUpdateMicroserviceDef(db, msdef); err != nil {
 		return errorhandler(NewSystemError(fmt.Sprintf("Error saving service definition %v into db: %v", *msdef, err))), nil, nil
@@ -506,7 +526,7 @@ func CreateService(service *Service,
 	glog.V(5).Infof(apiLogString(fmt.Sprintf("Create service: %v", service)))
 
 	// Generate a policy based on all the attributes and the service definition.
-	if msg, genErr := policy.GeneratePolicy(*service.Url, *service.Org, *service.Name, *service.VersionRange, *service.Arch, &props, haPartner, meterPolicy, counterPartyProperties, *agpList, maxAgreements, config.Edge.PolicyPath, pDevice.Org); genErr != nil {
+	if msg, genErr := policy.GeneratePolicy(*service.Url, *service.Org, *service.Name, *service.VersionRange, *service.Arch, &props, haPartner, meterPolicy, counterPartyProperties, windows, *agpList, maxAgreements, config.Edge.PolicyPath, pDevice.Org); genErr != nil {
 		return errorhandler(NewSystemError(fmt.Sprintf("Error generating policy, error: %v", genErr))), nil, nil
 	} else {
 		if from_user {
@@ -0,0 +1,37 @@
+package apicommon
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/open-horizon/anax/worker"
+)
+
+// Return the event traces selected by the query parameters of a /status/events request. The supported parameters
+// are correlation_id, event (an event id or message type), worker and limit. If a parameter is invalid, the name
+// of the parameter is returned along with the error.
+func GetEventTraces(query url.Values) (*worker.EventTraceOutput, string, error) {
+
+	filters := make([]worker.EventTraceFilter, 0)
+	if id := query.Get("correlation_id"); id != "" {
+		filters = append(filters, worker.CorrelationIdETFilter(id))
+	}
+	if ev := query.Get("event"); ev != "" {
+		filters = append(filters, worker.EventETFilter(ev))
+	}
+	if w := query.Get("worker"); w != "" {
+		filters = append(filters, worker.WorkerETFilter(w))
+	}
+
+	limit := 0
+	if l := query.Get("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err != nil || n < 0 {
+			return nil, "limit", fmt.Errorf("limit must be a non-negative integer, was %v", l)
+		} else {
+			limit = n
+		}
+	}
+
+	return worker.GetEventTracer().GetTraces(filters, limit), "", nil
+}
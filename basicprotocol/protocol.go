@@ -348,6 +348,10 @@ const CANCEL_NODE_SHUTDOWN = 116 // x74
 const CANCEL_MS_IMAGE_FETCH_FAILURE = 117
 const CANCEL_MS_DOWNGRADE_REQUIRED = 118
 const CANCEL_SERVICE_SUSPENDED = 119
+const CANCEL_PREFLIGHT_DISK = 120 // x78
+const CANCEL_PREFLIGHT_MEMORY = 121
+const CANCEL_PREFLIGHT_DEVICE = 122
+const CANCEL_IMAGE_DIGEST_MISMATCH = 123
 
 // These constants represent consumer cancellation reason codes
 // const AB_CANCEL_NOT_FINALIZED_TIMEOUT = 200  // xc8
@@ -384,6 +388,10 @@ func DecodeReasonCode(code uint64) string {
 		CANCEL_IMAGE_SIG_VERIF_FAILURE:  "image signature verification failed",
 		CANCEL_NODE_SHUTDOWN:            "node was unconfigured",
 		CANCEL_SERVICE_SUSPENDED:        "service suspended",
+		CANCEL_PREFLIGHT_DISK:           "node does not have enough free disk space",
+		CANCEL_PREFLIGHT_MEMORY:         "node does not have enough free memory",
+		CANCEL_PREFLIGHT_DEVICE:         "node does not have a device the service needs",
+		CANCEL_IMAGE_DIGEST_MISMATCH:    "image does not match the digest in the deployment",
 		// AB_CANCEL_NOT_FINALIZED_TIMEOUT: "agreement bot never detected agreement on the blockchain",
 		AB_CANCEL_NO_REPLY:         "agreement bot never received reply to proposal",
 		AB_CANCEL_NEGATIVE_REPLY:   "agreement bot received negative reply",
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	agbot "github.com/open-horizon/anax/agreementbot/persistence"
 	"github.com/open-horizon/anax/cli/cliutils"
+	"net/url"
 	"os"
 )
 
@@ -20,6 +21,8 @@ type ActiveAgreement struct {
 	DataNotificationSent   string `json:"data_notification_sent"`   // The timestamp for when data notification was sent to the device
 	PolicyName             string `json:"policy_name"`              // The name of the policy for this agreement, policy names are unique
 	Pattern                string `json:"pattern"`                  // The pattern used to make the agreement
+	Deferred               string `json:"deferred,omitempty"`       // The action waiting for a maintenance window to open
+	DeferredUntil          string `json:"deferred_until,omitempty"` // When the maintenance window allows the deferred action
 }
 
 // create an ActiveAgreement object
@@ -39,6 +42,11 @@ func NewActiveAgreement(agreement agbot.Agreement) *ActiveAgreement {
 	a.PolicyName = agreement.PolicyName
 	a.Pattern = agreement.Pattern
 
+	if agreement.IsDeferred() {
+		a.Deferred = agreement.DeferredReason
+		a.DeferredUntil = cliutils.ConvertTime(agreement.DeferredUntil)
+	}
+
 	return &a
 }
 
@@ -137,3 +145,26 @@ func AgreementCancel(agreementId string, allAgreements bool) {
 		cliutils.HorizonDelete("agreement/"+id, []int{200, 204})
 	}
 }
+
+// Show the agreement statistics of the agbot, optionally for just one org or policy.
+func AgreementStats(org string, policyName string) {
+	// set env to call agbot url
+	os.Setenv("HORIZON_URL", cliutils.AGBOT_HZN_API)
+
+	query := url.Values{}
+	if org != "" {
+		query.Set("org", org)
+	}
+	if policyName != "" {
+		query.Set("policy", policyName)
+	}
+
+	resource := "agreement/stats"
+	if len(query) != 0 {
+		resource += "?" + query.Encode()
+	}
+
+	var stats agbot.AgreementStatsReport
+	cliutils.HorizonGet(resource, []int{200}, &stats)
+	fmt.Println(cliutils.MarshalIndent(stats, "agbot agreement stats"))
+}
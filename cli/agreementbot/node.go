@@ -3,12 +3,17 @@ package agreementbot
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/open-horizon/anax/agreementbot"
 	"github.com/open-horizon/anax/apicommon"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"os"
 )
 
+// A copy of agreementbot.HorizonAgbot, the cli does not import the agbot so that it is not linked into hzn.
+type HorizonAgbot struct {
+	Id  string `json:"agbot_id"`
+	Org string `json:"organization"`
+}
+
 // This is a combo of anax's HorizonDevice and Info (status) structs
 type AgbotAndStatus struct {
 	// from agreementbot.HorizonAgbot
@@ -20,7 +25,7 @@ type AgbotAndStatus struct {
 }
 
 // CopyNodeInto copies the node info into our output struct
-func (n *AgbotAndStatus) CopyNodeInto(horDevice *agreementbot.HorizonAgbot) {
+func (n *AgbotAndStatus) CopyNodeInto(horDevice *HorizonAgbot) {
 	//todo: I don't like having to repeat all of these fields, hard to maintain. Maybe use reflection?
 	n.Id = horDevice.Id
 	n.Org = horDevice.Org
@@ -38,7 +43,7 @@ func List() {
 	os.Setenv("HORIZON_URL", cliutils.AGBOT_HZN_API)
 
 	// Get the agbot info
-	horDevice := agreementbot.HorizonAgbot{}
+	horDevice := HorizonAgbot{}
 	cliutils.HorizonGet("node", []int{200}, &horDevice)
 	nodeInfo := AgbotAndStatus{} // the structure we will output
 	nodeInfo.CopyNodeInto(&horDevice)
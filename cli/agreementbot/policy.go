@@ -5,9 +5,34 @@ import (
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/policy"
+	"net/http"
 	"os"
 )
 
+// Copies of the agbot's /policy/evaluate API structs. The agbot validates the request.
+type PolicyEvaluationRequest struct {
+	ConsumerPolicy   *policy.Policy  `json:"consumerPolicy,omitempty"`
+	PolicyOrg        string          `json:"policyOrg,omitempty"`
+	PolicyName       string          `json:"policyName,omitempty"`
+	NodeId           string          `json:"nodeId,omitempty"` // org/id of the node in the exchange
+	ProducerPolicies []policy.Policy `json:"producerPolicies,omitempty"`
+}
+
+type PolicyEvaluationStep struct {
+	Check   string   `json:"check"`
+	Passed  bool     `json:"passed"`
+	Details string   `json:"details"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+type PolicyEvaluation struct {
+	Compatible     bool                   `json:"compatible"`
+	ConsumerPolicy string                 `json:"consumerPolicy"`
+	ProducerPolicy string                 `json:"producerPolicy"`
+	FailedChecks   []string               `json:"failedChecks"`
+	Steps          []PolicyEvaluationStep `json:"steps"`
+}
+
 // get the policy names that the agbot hosts
 func getPolicyNames(org string) (map[string][]string, int) {
 	// set env to call agbot url
@@ -65,3 +90,105 @@ func PolicyList(org string, name string) {
 		}
 	}
 }
+
+// Explain whether a consumer policy is compatible with a node or with a set of producer policies. The consumer
+// policy is either a policy that this agbot hosts or a policy file.
+func PolicyEvaluate(org string, name string, consumerFile string, nodeId string, producerFiles []string) {
+	// set env to call agbot url
+	os.Setenv("HORIZON_URL", cliutils.AGBOT_HZN_API)
+
+	input := PolicyEvaluationRequest{NodeId: nodeId}
+
+	if consumerFile != "" {
+		if name != "" {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "specify either a consumer policy file or the name of a hosted policy, not both.")
+		}
+		input.ConsumerPolicy = new(policy.Policy)
+		cliutils.Unmarshal(cliutils.ReadJsonFile(consumerFile), input.ConsumerPolicy, consumerFile)
+	} else if name != "" {
+		input.PolicyOrg = org
+		input.PolicyName = name
+	} else {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "specify either a consumer policy file or the org and name of a hosted policy.")
+	}
+
+	if nodeId == "" && len(producerFiles) == 0 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "specify either a node id or producer policy files.")
+	} else if nodeId != "" && len(producerFiles) != 0 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "specify either a node id or producer policy files, not both.")
+	}
+
+	for _, f := range producerFiles {
+		var pol policy.Policy
+		cliutils.Unmarshal(cliutils.ReadJsonFile(f), &pol, f)
+		input.ProducerPolicies = append(input.ProducerPolicies, pol)
+	}
+
+	httpCode, respBody := cliutils.HorizonPutPost(http.MethodPost, "policy/evaluate", []int{200, 400}, input)
+	if httpCode == 400 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "%v", respBody)
+	}
+
+	var eval PolicyEvaluation
+	cliutils.Unmarshal([]byte(respBody), &eval, "policy/evaluate")
+	fmt.Println(cliutils.MarshalIndent(eval, "agbot policy evaluate"))
+}
+
+// Create or update a local policy file on the agbot. The credentials are those of an admin user in the policy's org.
+func PolicyAdd(org string, name string, userPw string, policyFile string) {
+	// set env to call agbot url
+	os.Setenv("HORIZON_URL", cliutils.AGBOT_HZN_API)
+
+	var pol policy.Policy
+	cliutils.Unmarshal(cliutils.ReadJsonFile(policyFile), &pol, policyFile)
+
+	httpCode, respBody := cliutils.HorizonPutPostWithCreds(http.MethodPut, fmt.Sprintf("policy/%v/%v", org, name), cliutils.OrgAndCreds(org, userPw), []int{200, 201, 400, 401, 403, 503}, pol)
+	if httpCode == 400 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "%v", respBody)
+	} else if httpCode == 401 || httpCode == 403 || httpCode == 503 {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "%v", respBody)
+	} else if httpCode == 201 {
+		fmt.Printf("Policy %v/%v added.\n", org, name)
+	} else {
+		fmt.Printf("Policy %v/%v updated.\n", org, name)
+	}
+}
+
+// Remove a local policy file from the agbot. The agreements made with the policy are cancelled.
+func PolicyRemove(org string, name string, userPw string, force bool) {
+	// set env to call agbot url
+	os.Setenv("HORIZON_URL", cliutils.AGBOT_HZN_API)
+
+	if !force {
+		cliutils.ConfirmRemove("Are you sure you want to remove policy " + org + "/" + name + " and cancel the agreements made with it?")
+	}
+
+	httpCode, respBody := cliutils.HorizonDeleteWithCreds(fmt.Sprintf("policy/%v/%v", org, name), cliutils.OrgAndCreds(org, userPw), []int{204, 400, 401, 403, 503})
+	if httpCode == 400 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "%v", respBody)
+	} else if httpCode == 401 || httpCode == 403 || httpCode == 503 {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "%v", respBody)
+	}
+	fmt.Printf("Policy %v/%v removed.\n", org, name)
+}
+
+// Pause or resume a local policy on the agbot.
+func PolicyAction(org string, name string, userPw string, action string) {
+	// set env to call agbot url
+	os.Setenv("HORIZON_URL", cliutils.AGBOT_HZN_API)
+
+	httpCode, respBody := cliutils.HorizonPutPostWithCreds(http.MethodPost, fmt.Sprintf("policy/%v/%v/%v", org, name, action), cliutils.OrgAndCreds(org, userPw), []int{200, 400, 401, 403, 503}, []byte{})
+	if httpCode == 400 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "%v", respBody)
+	} else if httpCode == 401 || httpCode == 403 || httpCode == 503 {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "%v", respBody)
+	}
+
+	var pol policy.Policy
+	cliutils.Unmarshal([]byte(respBody), &pol, "policy")
+	if pol.Paused {
+		fmt.Printf("Policy %v/%v is paused.\n", org, name)
+	} else {
+		fmt.Printf("Policy %v/%v is active.\n", org, name)
+	}
+}
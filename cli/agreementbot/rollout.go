@@ -0,0 +1,112 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"net/http"
+	"os"
+)
+
+// List all the rollouts, or just the one with the given id.
+func RolloutList(id string) {
+	// set env to call agbot url
+	os.Setenv("HORIZON_URL", cliutils.AGBOT_HZN_API)
+
+	if id == "" {
+		rollouts := make([]persistence.Rollout, 0)
+		cliutils.HorizonGet("rollout", []int{200}, &rollouts)
+		fmt.Println(cliutils.MarshalIndent(rollouts, "agbot rollout list"))
+	} else {
+		var rollout persistence.Rollout
+		if httpCode := cliutils.HorizonGet("rollout/"+id, []int{200, 400}, &rollout); httpCode == 400 {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "rollout %v not found.", id)
+		}
+		fmt.Println(cliutils.MarshalIndent(rollout, "agbot rollout list"))
+	}
+}
+
+// A copy of the agbot's POST /rollout input, the agbot validates the request.
+type RolloutRequest struct {
+	Org              string `json:"org"`
+	PolicyName       string `json:"policyName"`
+	Version          string `json:"version"`
+	BatchPercent     int    `json:"batchPercent,omitempty"`
+	BatchCount       int    `json:"batchCount,omitempty"`
+	FailureThreshold int    `json:"failureThreshold,omitempty"`
+	OnFailure        string `json:"onFailure,omitempty"` // pause or rollback
+	TimeoutS         int    `json:"timeout,omitempty"`
+}
+
+// Start moving the devices using a policy to a new workload version.
+func RolloutStart(org string, name string, userPw string, version string, batchPercent int, batchCount int, failureThreshold int, onFailure string, timeoutS int) {
+	// set env to call agbot url
+	os.Setenv("HORIZON_URL", cliutils.AGBOT_HZN_API)
+
+	input := RolloutRequest{
+		Org:              org,
+		PolicyName:       name,
+		Version:          version,
+		BatchPercent:     batchPercent,
+		BatchCount:       batchCount,
+		FailureThreshold: failureThreshold,
+		OnFailure:        onFailure,
+		TimeoutS:         timeoutS,
+	}
+	if batchPercent == 0 && batchCount == 0 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "specify either a batch percent or a batch count.")
+	} else if batchPercent != 0 && batchCount != 0 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "specify either a batch percent or a batch count, not both.")
+	}
+
+	httpCode, respBody := cliutils.HorizonPutPostWithCreds(http.MethodPost, "rollout", cliutils.OrgAndCreds(org, userPw), []int{201, 400, 401, 403}, input)
+	if httpCode == 400 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "%v", respBody)
+	} else if httpCode == 401 || httpCode == 403 {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "%v", respBody)
+	}
+
+	var rollout persistence.Rollout
+	cliutils.Unmarshal([]byte(respBody), &rollout, "rollout")
+	fmt.Printf("Rollout %v started, moving the devices using policy %v to version %v.\n", rollout.Id, rollout.PolicyName, rollout.Version)
+}
+
+// Returns the org of a rollout, which the credentials of the user managing the rollout have to be in.
+func rolloutOrg(id string) string {
+	var rollout persistence.Rollout
+	if httpCode := cliutils.HorizonGet("rollout/"+id, []int{200, 400}, &rollout); httpCode == 400 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "rollout %v not found.", id)
+	}
+	return rollout.Org
+}
+
+// Pause, resume or roll back a rollout.
+func RolloutAction(id string, userPw string, action string) {
+	// set env to call agbot url
+	os.Setenv("HORIZON_URL", cliutils.AGBOT_HZN_API)
+
+	httpCode, respBody := cliutils.HorizonPutPostWithCreds(http.MethodPost, fmt.Sprintf("rollout/%v/%v", id, action), cliutils.OrgAndCreds(rolloutOrg(id), userPw), []int{200, 400, 401, 403}, []byte{})
+	if httpCode == 400 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "%v", respBody)
+	} else if httpCode == 401 || httpCode == 403 {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "%v", respBody)
+	}
+
+	var rollout persistence.Rollout
+	cliutils.Unmarshal([]byte(respBody), &rollout, "rollout")
+	fmt.Printf("Rollout %v is %v.\n", rollout.Id, rollout.State)
+}
+
+// Remove a rollout that is paused or finished.
+func RolloutRemove(id string, userPw string) {
+	// set env to call agbot url
+	os.Setenv("HORIZON_URL", cliutils.AGBOT_HZN_API)
+
+	httpCode, respBody := cliutils.HorizonDeleteWithCreds("rollout/"+id, cliutils.OrgAndCreds(rolloutOrg(id), userPw), []int{204, 400, 401, 403})
+	if httpCode == 400 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "rollout %v does not exist, or is running or rolling back.", id)
+	} else if httpCode == 401 || httpCode == 403 {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "%v", respBody)
+	}
+	fmt.Printf("Rollout %v removed.\n", id)
+}
@@ -431,20 +431,49 @@ func HorizonGet(urlSuffix string, goodHttpCodes []int, structure interface{}) (h
 	return
 }
 
+// HorizonGetStream runs a GET on the anax api for a response that is streamed, and returns the response body for the
+// caller to read from and close. If the list of goodHttpCodes is not empty and none match the actual http code, it will exit with an error.
+func HorizonGetStream(urlSuffix string, goodHttpCodes []int) (httpCode int, body io.ReadCloser) {
+	url := GetHorizonUrlBase() + "/" + urlSuffix
+	apiMsg := http.MethodGet + " " + url
+	Verbose(apiMsg)
+	resp, err := http.Get(url)
+	if err != nil {
+		printHorizonRestError(apiMsg, err)
+	}
+	httpCode = resp.StatusCode
+	Verbose("HTTP code: %d", httpCode)
+	if !isGoodCode(httpCode, goodHttpCodes) {
+		defer resp.Body.Close()
+		Fatal(HTTP_ERROR, "bad HTTP code %d from %s: %s", httpCode, apiMsg, GetRespBodyAsString(resp.Body))
+	}
+	return httpCode, resp.Body
+}
+
 // HorizonDelete runs a DELETE on the anax api.
 // If the list of goodHttpCodes is not empty and none match the actual http code, it will exit with an error. Otherwise the actual code is returned.
 func HorizonDelete(urlSuffix string, goodHttpCodes []int) (httpCode int) {
+	httpCode, _ = HorizonDeleteWithCreds(urlSuffix, "", goodHttpCodes)
+	return
+}
+
+// HorizonDeleteWithCreds runs a DELETE on the anax api, passing the given credentials (user:pw) when they are not empty.
+// The response body is returned so that the error messages of the api can be shown.
+func HorizonDeleteWithCreds(urlSuffix string, credentials string, goodHttpCodes []int) (httpCode int, resp_body string) {
 	url := GetHorizonUrlBase() + "/" + urlSuffix
 	apiMsg := http.MethodDelete + " " + url
 	Verbose(apiMsg)
 	if IsDryRun() {
-		return 204
+		return 204, ""
 	}
 	httpClient := &http.Client{}
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
 		Fatal(HTTP_ERROR, "%s new request failed: %v", apiMsg, err)
 	}
+	if credentials != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Basic %v", base64.StdEncoding.EncodeToString([]byte(credentials))))
+	}
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		printHorizonRestError(apiMsg, err)
@@ -452,8 +481,9 @@ func HorizonDelete(urlSuffix string, goodHttpCodes []int) (httpCode int) {
 	defer resp.Body.Close()
 	httpCode = resp.StatusCode
 	Verbose("HTTP code: %d", httpCode)
+	resp_body = GetRespBodyAsString(resp.Body)
 	if !isGoodCode(httpCode, goodHttpCodes) {
-		Fatal(HTTP_ERROR, "bad HTTP code %d from %s: %s", httpCode, apiMsg, GetRespBodyAsString(resp.Body))
+		Fatal(HTTP_ERROR, "bad HTTP code %d from %s: %s", httpCode, apiMsg, resp_body)
 	}
 	return
 }
@@ -461,6 +491,11 @@ func HorizonDelete(urlSuffix string, goodHttpCodes []int) (httpCode int) {
 // HorizonPutPost runs a PUT or POST to the anax api to create or update a resource.
 // If the list of goodHttpCodes is not empty and none match the actual http code, it will exit with an error. Otherwise the actual code is returned.
 func HorizonPutPost(method string, urlSuffix string, goodHttpCodes []int, body interface{}) (httpCode int, resp_body string) {
+	return HorizonPutPostWithCreds(method, urlSuffix, "", goodHttpCodes, body)
+}
+
+// HorizonPutPostWithCreds runs a PUT or POST to the anax api, passing the given credentials (user:pw) when they are not empty.
+func HorizonPutPostWithCreds(method string, urlSuffix string, credentials string, goodHttpCodes []int, body interface{}) (httpCode int, resp_body string) {
 	url := GetHorizonUrlBase() + "/" + urlSuffix
 	apiMsg := method + " " + url
 	Verbose(apiMsg)
@@ -501,6 +536,9 @@ func HorizonPutPost(method string, urlSuffix string, goodHttpCodes []int, body i
 	} else {
 		req.Header.Add("Content-Type", "application/json")
 	}
+	if credentials != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Basic %v", base64.StdEncoding.EncodeToString([]byte(credentials))))
+	}
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		printHorizonRestError(apiMsg, err)
@@ -518,6 +556,39 @@ func HorizonPutPost(method string, urlSuffix string, goodHttpCodes []int, body i
 	return
 }
 
+// HorizonPostStream runs a POST to the anax api with a body that is streamed from a reader, such as a large file.
+// If the list of goodHttpCodes is not empty and none match the actual http code, it will exit with an error. Otherwise the actual code is returned.
+func HorizonPostStream(urlSuffix string, goodHttpCodes []int, body io.Reader, contentLength int64) (httpCode int, resp_body string) {
+	url := GetHorizonUrlBase() + "/" + urlSuffix
+	apiMsg := http.MethodPost + " " + url
+	Verbose(apiMsg)
+	if IsDryRun() {
+		return 201, ""
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		Fatal(HTTP_ERROR, "%s new request failed: %v", apiMsg, err)
+	}
+	req.ContentLength = contentLength
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		printHorizonRestError(apiMsg, err)
+	}
+
+	defer resp.Body.Close()
+	httpCode = resp.StatusCode
+	Verbose("HTTP code: %d", httpCode)
+
+	resp_body = GetRespBodyAsString(resp.Body)
+	if !isGoodCode(httpCode, goodHttpCodes) {
+		Fatal(HTTP_ERROR, "bad HTTP code %d from %s: %s", httpCode, apiMsg, resp_body)
+	}
+	return
+}
+
 // GetExchangeUrl returns the exchange url from the env var or anax api
 func GetExchangeUrl() string {
 	exchUrl := os.Getenv("HZN_EXCHANGE_URL")
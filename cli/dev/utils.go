@@ -13,6 +13,7 @@ import (
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/container"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
@@ -415,16 +416,7 @@ func CommonExecutionSetup(homeDirectory string, userInputFile string, projectTyp
 }
 
 func findContainers(serviceName string, cw *container.ContainerWorker) ([]docker.APIContainers, error) {
-	dcService := docker.ListContainersOptions{
-		All: true,
-		Filters: map[string][]string{
-			"label": []string{
-				fmt.Sprintf("%v.service_name=%v", container.LABEL_PREFIX, serviceName),
-			},
-		},
-	}
-
-	containers, err := cw.GetClient().ListContainers(dcService)
+	containers, err := cw.GetRuntime().ListContainers(true, []string{fmt.Sprintf("%v.service_name=%v", container.LABEL_PREFIX, serviceName)})
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("unable to list containers, %v", err))
 	}
@@ -584,7 +576,7 @@ func StartContainers(deployment *containermessage.DeploymentDescription,
 	fmt.Printf("Start %v: %v with instance id prefix %v\n", logName, dc.CLIString(), id)
 
 	// Start the dependent service container.
-	_, startErr := cw.ResourcesCreate(id, "", nil, deployment, []byte(""), environmentAdditions, msNetworks, cutil.FormOrgSpecUrl(cutil.NormalizeURL(specRef), org))
+	_, startErr := cw.ResourcesCreate(id, "", nil, deployment, []byte(""), environmentAdditions, msNetworks, cutil.FormOrgSpecUrl(cutil.NormalizeURL(specRef), org), nil)
 	if startErr != nil {
 		return nil, errors.New(fmt.Sprintf("unable to start container using %v, error: %v", dc.CLIString(), startErr))
 	}
@@ -696,9 +688,8 @@ func getContainerImages(containerConfig *events.ContainerConfig, pemFiles []stri
 	col, _ := config.NewCollaborators(*cfg)
 	cfg.Collaborators = *col
 
-	// Create a docker client so that we can convert the downloaded images into docker images.
-	dockerEP := "unix:///var/run/docker.sock"
-	client, derr := docker.NewClient(dockerEP)
+	// Create a container runtime so that we can pull the images, or convert the downloaded images into docker images.
+	rt, derr := containerruntime.NewDockerRuntime(containerruntime.DOCKER_DEFAULT_ENDPOINT)
 	if derr != nil {
 		return errors.New(fmt.Sprintf("failed to create docker client, error: %v", derr))
 	}
@@ -722,7 +713,7 @@ func getContainerImages(containerConfig *events.ContainerConfig, pemFiles []stri
 	cliutils.Verbose("Using HTTPS Basic authorization: %v", httpAuthAttrs)
 
 	fmt.Printf("getting container images into docker.\n")
-	if err := torrent.ProcessImageFetch(cfg, client, containerConfig, httpAuthAttrs, dockerAuthConfigurations, pemFiles); err != nil {
+	if err := torrent.ProcessImageFetch(cfg, rt, containerConfig, httpAuthAttrs, dockerAuthConfigurations, pemFiles); err != nil {
 		return errors.New(fmt.Sprintf("failed to get container images, error: %v", err))
 	}
 
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/persistence"
+	"io"
+	"net/http"
 	"regexp"
 	"strings"
 	"time"
@@ -62,7 +65,7 @@ func getSelectionString(selections []string) (string, error) {
 	return strings.Join(sels, "&"), nil
 }
 
-func List(all bool, detail bool, selections []string) {
+func List(all bool, detail bool, selections []string, follow bool) {
 
 	// format the eventlog api string
 	url_s := "eventlog"
@@ -77,6 +80,11 @@ func List(all bool, detail bool, selections []string) {
 		}
 	}
 
+	if follow {
+		followEventLogs(url_s, detail)
+		return
+	}
+
 	// get the eventlog from anax
 	apiOutput := make([]persistence.EventLogRaw, 0)
 	cliutils.HorizonGet(url_s, []int{200}, &apiOutput)
@@ -85,13 +93,7 @@ func List(all bool, detail bool, selections []string) {
 	if detail {
 		long_output := make([]EventLog, len(apiOutput))
 		for i, v := range apiOutput {
-			long_output[i].Id = v.Id
-			long_output[i].Timestamp = cliutils.ConvertTime(v.Timestamp)
-			long_output[i].Severity = v.Severity
-			long_output[i].Message = v.Message
-			long_output[i].EventCode = v.EventCode
-			long_output[i].SourceType = v.SourceType
-			long_output[i].Source = v.Source
+			long_output[i] = convertEventLog(v)
 		}
 
 		jsonBytes, err := json.MarshalIndent(long_output, "", cliutils.JSON_INDENT)
@@ -102,8 +104,7 @@ func List(all bool, detail bool, selections []string) {
 	} else {
 		short_output := make([]string, len(apiOutput))
 		for i, v := range apiOutput {
-			t := time.Unix(int64(v.Timestamp), 0)
-			short_output[i] = fmt.Sprintf("%v:   %v", t.Format("2006-01-02 15:04:05"), v.Message)
+			short_output[i] = shortEventLog(v)
 		}
 		jsonBytes, err := json.MarshalIndent(short_output, "", cliutils.JSON_INDENT)
 		if err != nil {
@@ -112,3 +113,98 @@ func List(all bool, detail bool, selections []string) {
 		fmt.Printf("%s\n", jsonBytes)
 	}
 }
+
+// Print the event logs as anax streams them, until anax ends the stream or the user interrupts the command. Each
+// event log is printed as soon as it arrives, so the output is not a json array.
+func followEventLogs(url_s string, detail bool) {
+
+	if strings.Contains(url_s, "?") {
+		url_s = fmt.Sprintf("%v&follow=true", url_s)
+	} else {
+		url_s = fmt.Sprintf("%v?follow=true", url_s)
+	}
+
+	_, body := cliutils.HorizonGetStream(url_s, []int{200})
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	for {
+		var v persistence.EventLogRaw
+		if err := dec.Decode(&v); err == io.EOF {
+			cliutils.Warning("the Horizon agent ended the event log stream.")
+			return
+		} else if err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, "failed to read the event log stream: %v", err)
+		}
+
+		if detail {
+			jsonBytes, err := json.MarshalIndent(convertEventLog(v), "", cliutils.JSON_INDENT)
+			if err != nil {
+				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, "failed to marshal 'hzn eventlog list' output: %v", err)
+			}
+			fmt.Printf("%s\n", jsonBytes)
+		} else {
+			fmt.Println(shortEventLog(v))
+		}
+	}
+}
+
+func convertEventLog(v persistence.EventLogRaw) EventLog {
+	return EventLog{
+		Id:         v.Id,
+		Timestamp:  cliutils.ConvertTime(v.Timestamp),
+		Severity:   v.Severity,
+		Message:    v.Message,
+		EventCode:  v.EventCode,
+		SourceType: v.SourceType,
+		Source:     v.Source,
+	}
+}
+
+func shortEventLog(v persistence.EventLogRaw) string {
+	t := time.Unix(int64(v.Timestamp), 0)
+	return fmt.Sprintf("%v:   %v", t.Format("2006-01-02 15:04:05"), v.Message)
+}
+
+// Prune removes the event logs that are outside of the retention limits, or shows the result of the last prune.
+// If no limits are specified, the limits configured for the Horizon agent are used.
+func Prune(maxAgeDays int, maxCount int, statusOnly bool) {
+
+	status := persistence.EventLogPruneStatus{}
+	if statusOnly {
+		cliutils.HorizonGet("eventlog/prune", []int{200}, &status)
+	} else {
+		if maxAgeDays < 0 || maxCount < 0 {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "the --max-age-days and --max-count values must not be negative.")
+		}
+
+		var body interface{}
+		body = ""
+		if maxAgeDays != 0 || maxCount != 0 {
+			body = config.EventLogRetentionConfig{MaxAgeS: uint64(maxAgeDays) * 24 * 3600, MaxCount: maxCount}
+		}
+
+		httpCode, respBody := cliutils.HorizonPutPost(http.MethodPost, "eventlog/prune", []int{200, 400}, body)
+		if httpCode == 400 {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "%v. Specify --max-age-days or --max-count, or configure EventLogRetention for the Horizon agent.", respBody)
+		} else if err := json.Unmarshal([]byte(respBody), &status); err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, "failed to unmarshal 'hzn eventlog prune' response: %v", err)
+		}
+	}
+
+	output := struct {
+		persistence.EventLogPruneStatus
+		LastPruneTime    string `json:"last_prune_time"`
+		NewestPrunedTime string `json:"newest_pruned_time"`
+	}{
+		EventLogPruneStatus: status,
+		LastPruneTime:       cliutils.ConvertTime(status.LastPruneTime),
+		NewestPrunedTime:    cliutils.ConvertTime(status.NewestPrunedTime),
+	}
+
+	jsonBytes, err := json.MarshalIndent(output, "", cliutils.JSON_INDENT)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, "failed to marshal 'hzn eventlog prune' output: %v", err)
+	}
+	fmt.Printf("%s\n", jsonBytes)
+}
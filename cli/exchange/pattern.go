@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/rsapss-tool/sign"
 	"github.com/open-horizon/rsapss-tool/verify"
 	"net/http"
@@ -27,6 +28,7 @@ type PatternOutput struct {
 	Public             bool                         `json:"public"`
 	Services           []ServiceReference           `json:"services"`
 	AgreementProtocols []exchange.AgreementProtocol `json:"agreementProtocols"`
+	MaintenanceWindows []exchange.MaintenanceWindow `json:"maintenanceWindows,omitempty"`
 	LastUpdated        string                       `json:"lastUpdated"`
 }
 
@@ -60,6 +62,7 @@ type PatternFile struct {
 	Public             bool                         `json:"public"`
 	Services           []ServiceReferenceFile       `json:"services"`
 	AgreementProtocols []exchange.AgreementProtocol `json:"agreementProtocols"`
+	MaintenanceWindows []exchange.MaintenanceWindow `json:"maintenanceWindows,omitempty"`
 }
 
 type ServiceChoice struct {
@@ -84,6 +87,7 @@ type PatternInput struct {
 	Public             bool                         `json:"public"`
 	Services           []ServiceReference           `json:"services"`
 	AgreementProtocols []exchange.AgreementProtocol `json:"agreementProtocols"`
+	MaintenanceWindows []exchange.MaintenanceWindow `json:"maintenanceWindows,omitempty"`
 }
 
 // List the pattern resources for the given org.
@@ -170,7 +174,12 @@ func PatternPublish(org, userPw, jsonFilePath, keyFilePath, pubKeyFilePath, patN
 	if patFile.Org != "" && patFile.Org != org {
 		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "the org specified in the input file (%s) must match the org specified on the command line (%s)", patFile.Org, org)
 	}
-	patInput := PatternInput{Label: patFile.Label, Description: patFile.Description, Public: patFile.Public, AgreementProtocols: patFile.AgreementProtocols}
+	if windows, err := policy.ConvertToMaintenanceWindowList(patFile.MaintenanceWindows); err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "failed to convert the maintenance windows in %s: %v", jsonFilePath, err)
+	} else if err := windows.IsValid(); err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "the maintenance windows in %s are not valid: %v", jsonFilePath, err)
+	}
+	patInput := PatternInput{Label: patFile.Label, Description: patFile.Description, Public: patFile.Public, AgreementProtocols: patFile.AgreementProtocols, MaintenanceWindows: patFile.MaintenanceWindows}
 
 	// Loop thru the services array and the servicesVersions array and sign the deployment_overrides fields
 	if patFile.Services != nil && len(patFile.Services) > 0 {
@@ -213,6 +213,15 @@ Environment Variables:
 
 	nodeCmd := app.Command("node", "List and manage general information about this Horizon edge node.")
 	nodeListCmd := nodeCmd.Command("list", "Display general information about this Horizon edge node.")
+	nodeBackupCmd := nodeCmd.Command("backup", "Write a consistent snapshot of this Horizon edge node's database to a file.")
+	nodeBackupFile := nodeBackupCmd.Arg("file", "The file to write the backup to.").Required().String()
+	nodeRestoreCmd := nodeCmd.Command("restore", "Restore this Horizon edge node's database from a backup. The backup is checked and staged, and the database is restored from it when the Horizon agent is restarted.")
+	nodeRestoreFile := nodeRestoreCmd.Arg("file", "The backup file created by 'hzn node backup'. Specify - to read from stdin.").Required().String()
+	nodeFsckCmd := nodeCmd.Command("fsck", "Check this Horizon edge node's database for damaged and orphaned records.")
+	nodeImageCmd := nodeCmd.Command("image", "Manage the images in the local image cache of this Horizon edge node.")
+	nodeImageImportCmd := nodeImageCmd.Command("import", "Add an image archive, made by 'docker save' or in an OCI image layout, to the local image cache of this Horizon edge node and load its images. The agent uses the cached images instead of pulling them from their registries.")
+	nodeImageImportArchive := nodeImageImportCmd.Arg("tarball", "The tar file of the image archive, which may be gzipped.").Required().String()
+	nodePruneImagesCmd := nodeCmd.Command("prune-images", "Remove the images of superseded service versions from this Horizon edge node. The images of the versions kept for rollback, and of the versions that are running, are not removed. Use the --dry-run flag to see the images that would be removed.")
 
 	agreementCmd := app.Command("agreement", "List or manage the active or archived agreements this edge node has made with a Horizon agreement bot.")
 	agreementListCmd := agreementCmd.Command("list", "List the active or archived agreements this edge node has made with a Horizon agreement bot.")
@@ -243,6 +252,12 @@ Environment Variables:
 	resumeAllServices := serviceConfigStateActiveCmd.Flag("all", "Resume all registerd services.").Short('a').Bool()
 	resumeServiceOrg := serviceConfigStateActiveCmd.Arg("serviceorg", "The organization of the service that should be resumed.").String()
 	resumeServiceName := serviceConfigStateActiveCmd.Arg("service", "The name of the service that should be resumed.").String()
+	serviceLogCmd := serviceCmd.Command("log", "Show the container logs for a service that is running on this Horizon edge node.")
+	logServiceName := serviceLogCmd.Arg("service", "The name of the service in its deployment configuration, which is also the name of its container.").Required().String()
+	logAgreementId := serviceLogCmd.Flag("agreement", "The agreement id, or dependent service instance id, of the container. Needed when more than one container has the service name.").Short('a').String()
+	logTail := serviceLogCmd.Flag("tail", "The number of lines to show from the end of the log, or 'all'.").Short('n').Default("all").String()
+	logSince := serviceLogCmd.Flag("since", "Only show the lines logged since this time. An RFC3339 time, a unix time in seconds, or a duration such as 10m.").Short('s').String()
+	logFollow := serviceLogCmd.Flag("follow", "Keep showing the lines as they are logged, until interrupted.").Short('f').Bool()
 
 	unregisterCmd := app.Command("unregister", "Unregister and reset this Horizon edge node so that it is ready to be registered again. Warning: this will stop all the Horizon services running on this edge node, and restart the Horizon agent.")
 
@@ -251,12 +266,23 @@ Environment Variables:
 
 	statusCmd := app.Command("status", "Display the current horizon internal status for the node.")
 	statusLong := statusCmd.Flag("long", "Show detailed status").Short('l').Bool()
+	statusShowCmd := statusCmd.Command("show", "Display the current horizon internal status for the node.").Default().Hidden()
+	statusEventsCmd := statusCmd.Command("events", "Display the traces of the events and commands that flowed through the node's workers. Event tracing must be enabled in the anax configuration file.")
+	statusEventsCorrelation := statusEventsCmd.Flag("correlation", "Only show the events with this correlation id.").Short('c').String()
+	statusEventsEvent := statusEventsCmd.Flag("event", "Only show the events with this event id or message type.").Short('e').String()
+	statusEventsWorker := statusEventsCmd.Flag("worker", "Only show the events emitted or handled by this worker.").Short('w').String()
+	statusEventsLimit := statusEventsCmd.Flag("limit", "Only show this many of the most recent events.").Short('n').Int()
 
 	eventlogCmd := app.Command("eventlog", "List the event logs for the current or all registrations.")
 	eventlogListCmd := eventlogCmd.Command("list", "List the event logs for the current or all registrations.")
 	listAllEventlogs := eventlogListCmd.Flag("all", "List all the event logs including the previous registrations.").Short('a').Bool()
 	listDetailedEventlogs := eventlogListCmd.Flag("long", "List event logs with details.").Short('l').Bool()
 	listSelectedEventlogs := eventlogListCmd.Flag("select", "Selection string. This flag can be repeated which means 'AND'. Each flag should be in the format of attribute=value, attribute~value, \"attribute>value\" or \"attribute<value\", where '~' means contains. The common attribute names are timestamp, severity, message, event_code, source_type, agreement_id, service_url etc. Use the '-l' flag to see all the attribute names.").Short('s').Strings()
+	listFollowEventlogs := eventlogListCmd.Flag("follow", "Keep listing new event logs as they are created, until the command is interrupted. The event logs are printed one at a time instead of as a json array.").Short('f').Bool()
+	eventlogPruneCmd := eventlogCmd.Command("prune", "Remove the event logs that are outside of the retention limits. Without flags, the limits configured for the Horizon agent are used.")
+	pruneEventlogsMaxAge := eventlogPruneCmd.Flag("max-age-days", "Remove the event logs older than this number of days. This and --max-count replace the configured limits for this prune.").Int()
+	pruneEventlogsMaxCount := eventlogPruneCmd.Flag("max-count", "Keep only this number of the most recent event logs.").Int()
+	pruneEventlogsStatus := eventlogPruneCmd.Flag("status", "Show the result of the last prune instead of pruning.").Bool()
 
 	devCmd := app.Command("dev", "Development tools for creation of services.")
 	devHomeDirectory := devCmd.Flag("directory", "Directory containing Horizon project metadata.").Short('d').String()
@@ -297,12 +323,63 @@ Environment Variables:
 	agbotAgreementCancelCmd := agbotAgreementCmd.Command("cancel", "Cancel 1 or all of the active agreements this Horizon agreement bot has with edge nodes. Usually an agbot will immediately negotiated a new agreement. ")
 	agbotCancelAllAgreements := agbotAgreementCancelCmd.Flag("all", "Cancel all of the current agreements.").Short('a').Bool()
 	agbotCancelAgreementId := agbotAgreementCancelCmd.Arg("agreement", "The active agreement to cancel.").String()
-	agbotPolicyCmd := agbotCmd.Command("policy", "List the policies this Horizon agreement bot hosts.")
+	agbotAgreementStatsCmd := agbotAgreementCmd.Command("stats", "Show statistics of the active and archived agreements this Horizon agreement bot has made, by organization and by policy: counts by termination reason, time to finalize percentiles, timeout rates and agreements per edge node.")
+	agbotAgreementStatsOrg := agbotAgreementStatsCmd.Flag("org", "Only show the statistics of this organization.").Short('o').String()
+	agbotAgreementStatsPolicy := agbotAgreementStatsCmd.Flag("policy", "Only show the statistics of this policy.").Short('p').String()
+	agbotPolicyCmd := agbotCmd.Command("policy", "List and manage the policies this Horizon agreement bot hosts.")
+	agbotPolicyUserPw := agbotPolicyCmd.Flag("user-pw", "Horizon Exchange credentials of an admin user in the policy's organization, needed to add, remove, pause or resume a policy. If not specified, HZN_EXCHANGE_USER_AUTH will be used as a default. If you don't prepend it with the user's org, it will automatically be prepended with the policy's org.").Short('u').PlaceHolder("USER:PW").String()
 	agbotPolicyListCmd := agbotPolicyCmd.Command("list", "List policies this Horizon agreement bot hosts.")
 	agbotPolicyOrg := agbotPolicyListCmd.Arg("org", "The organization the policy belongs to.").String()
 	agbotPolicyName := agbotPolicyListCmd.Arg("name", "The policy name.").String()
+	agbotPolicyEvaluateCmd := agbotPolicyCmd.Command("evaluate", "Explain whether a policy is compatible with an edge node, without making an agreement. Every check the agreement bot makes is shown, with the reasons it failed.")
+	agbotPolicyEvaluateOrg := agbotPolicyEvaluateCmd.Arg("org", "The organization the hosted policy belongs to.").String()
+	agbotPolicyEvaluateName := agbotPolicyEvaluateCmd.Arg("name", "The name of a policy this agreement bot hosts.").String()
+	agbotPolicyEvaluateFile := agbotPolicyEvaluateCmd.Flag("consumer-policy", "A consumer policy file to evaluate instead of a hosted policy.").Short('c').ExistingFile()
+	agbotPolicyEvaluateNode := agbotPolicyEvaluateCmd.Flag("node", "The edge node to evaluate the policy against, in the form org/id.").Short('n').String()
+	agbotPolicyEvaluateProducers := agbotPolicyEvaluateCmd.Flag("producer-policy", "A producer policy file to evaluate the policy against, instead of a node. Can be repeated, the policies are merged the same way as the policies of a node's services.").Short('p').ExistingFiles()
+	agbotPolicyAddCmd := agbotPolicyCmd.Command("add", "Add a policy file to this Horizon agreement bot, or replace the policy with the same name. The agreement bot picks it up the next time it checks its policy files.")
+	agbotPolicyAddOrg := agbotPolicyAddCmd.Arg("org", "The organization the policy belongs to.").Required().String()
+	agbotPolicyAddName := agbotPolicyAddCmd.Arg("name", "The policy name.").Required().String()
+	agbotPolicyAddFile := agbotPolicyAddCmd.Flag("json-file", "The path of a JSON file containing the policy.").Short('f').Required().ExistingFile()
+	agbotPolicyRemoveCmd := agbotPolicyCmd.Command("remove", "Remove a policy from this Horizon agreement bot. The agreements made with the policy are cancelled.")
+	agbotPolicyRemoveOrg := agbotPolicyRemoveCmd.Arg("org", "The organization the policy belongs to.").Required().String()
+	agbotPolicyRemoveName := agbotPolicyRemoveCmd.Arg("name", "The policy name.").Required().String()
+	agbotPolicyRemoveForce := agbotPolicyRemoveCmd.Flag("force", "Skip the 'are you sure?' prompt.").Short('f').Bool()
+	agbotPolicyPauseCmd := agbotPolicyCmd.Command("pause", "Stop making new agreements with a policy. The agreements already made with it are kept.")
+	agbotPolicyPauseOrg := agbotPolicyPauseCmd.Arg("org", "The organization the policy belongs to.").Required().String()
+	agbotPolicyPauseName := agbotPolicyPauseCmd.Arg("name", "The policy name.").Required().String()
+	agbotPolicyResumeCmd := agbotPolicyCmd.Command("resume", "Start making new agreements with a paused policy again.")
+	agbotPolicyResumeOrg := agbotPolicyResumeCmd.Arg("org", "The organization the policy belongs to.").Required().String()
+	agbotPolicyResumeName := agbotPolicyResumeCmd.Arg("name", "The policy name.").Required().String()
+	agbotRolloutCmd := agbotCmd.Command("rollout", "List and manage the rollouts that move the edge nodes using a policy to a new workload version a batch at a time.")
+	agbotRolloutUserPw := agbotRolloutCmd.Flag("user-pw", "Horizon Exchange credentials of an admin user in the rollout's organization, needed to start, pause, resume, roll back or remove a rollout. If not specified, HZN_EXCHANGE_USER_AUTH will be used as a default. If you don't prepend it with the user's org, it will automatically be prepended with the rollout's org.").Short('u').PlaceHolder("USER:PW").String()
+	agbotRolloutListCmd := agbotRolloutCmd.Command("list", "List the rollouts of this Horizon agreement bot.")
+	agbotRolloutListId := agbotRolloutListCmd.Arg("id", "List just this one rollout.").String()
+	agbotRolloutStartCmd := agbotRolloutCmd.Command("start", "Start moving the edge nodes using a policy this agreement bot hosts to a new workload version. The version must be, or be about to become, the highest priority workload in the policy.")
+	agbotRolloutStartOrg := agbotRolloutStartCmd.Arg("org", "The organization the policy belongs to.").Required().String()
+	agbotRolloutStartName := agbotRolloutStartCmd.Arg("name", "The name of the policy.").Required().String()
+	agbotRolloutStartVersion := agbotRolloutStartCmd.Arg("version", "The workload version to move the edge nodes to.").Required().String()
+	agbotRolloutStartPercent := agbotRolloutStartCmd.Flag("percent", "The percentage of the edge nodes to move in each batch.").Short('p').Int()
+	agbotRolloutStartCount := agbotRolloutStartCmd.Flag("count", "The number of edge nodes to move in each batch. Mutually exclusive with --percent.").Short('c').Int()
+	agbotRolloutStartThreshold := agbotRolloutStartCmd.Flag("failure-threshold", "The percentage of moved edge nodes that can fail before the rollout is stopped. The default is 0, the rollout stops at the first failure.").Short('f').Int()
+	agbotRolloutStartOnFailure := agbotRolloutStartCmd.Flag("on-failure", "What to do when the failure threshold is crossed, pause or rollback.").Default("pause").Enum("pause", "rollback")
+	agbotRolloutStartTimeout := agbotRolloutStartCmd.Flag("timeout", "The number of seconds an edge node has to run the new version before it is counted as a failure. The default is 1800.").Short('t').Int()
+	agbotRolloutPauseCmd := agbotRolloutCmd.Command("pause", "Pause a running rollout. Edge nodes that are being moved finish moving, but no new batch is started.")
+	agbotRolloutPauseId := agbotRolloutPauseCmd.Arg("id", "The rollout to pause.").Required().String()
+	agbotRolloutResumeCmd := agbotRolloutCmd.Command("resume", "Resume a paused rollout. The edge nodes that failed before the rollout was resumed are not counted against the failure threshold.")
+	agbotRolloutResumeId := agbotRolloutResumeCmd.Arg("id", "The rollout to resume.").Required().String()
+	agbotRolloutRollbackCmd := agbotRolloutCmd.Command("rollback", "Move the edge nodes a rollout moved back to the workload version they were running before.")
+	agbotRolloutRollbackId := agbotRolloutRollbackCmd.Arg("id", "The rollout to roll back.").Required().String()
+	agbotRolloutRemoveCmd := agbotRolloutCmd.Command("remove", "Remove a paused or finished rollout.")
+	agbotRolloutRemoveId := agbotRolloutRemoveCmd.Arg("id", "The rollout to remove.").Required().String()
 	agbotStatusCmd := agbotCmd.Command("status", "Display the current horizon internal status for the Horizon agreement bot.")
 	agbotStatusLong := agbotStatusCmd.Flag("long", "Show detailed status").Short('l').Bool()
+	agbotStatusShowCmd := agbotStatusCmd.Command("show", "Display the current horizon internal status for the Horizon agreement bot.").Default().Hidden()
+	agbotStatusEventsCmd := agbotStatusCmd.Command("events", "Display the traces of the events and commands that flowed through the agreement bot's workers. Event tracing must be enabled in the anax configuration file.")
+	agbotStatusEventsCorrelation := agbotStatusEventsCmd.Flag("correlation", "Only show the events with this correlation id.").Short('c').String()
+	agbotStatusEventsEvent := agbotStatusEventsCmd.Flag("event", "Only show the events with this event id or message type.").Short('e').String()
+	agbotStatusEventsWorker := agbotStatusEventsCmd.Flag("worker", "Only show the events emitted or handled by this worker.").Short('w').String()
+	agbotStatusEventsLimit := agbotStatusEventsCmd.Flag("limit", "Only show this many of the most recent events.").Short('n').Int()
 
 	utilCmd := app.Command("util", "Utility commands.")
 	utilSignCmd := utilCmd.Command("sign", "Sign the text in stdin. The signature is sent to stdout.")
@@ -360,6 +437,12 @@ Environment Variables:
 	if strings.HasPrefix(fullCmd, "register") {
 		userPw = cliutils.WithDefaultEnvVar(userPw, "HZN_EXCHANGE_USER_AUTH")
 	}
+	switch fullCmd {
+	case agbotPolicyAddCmd.FullCommand(), agbotPolicyRemoveCmd.FullCommand(), agbotPolicyPauseCmd.FullCommand(), agbotPolicyResumeCmd.FullCommand():
+		agbotPolicyUserPw = cliutils.RequiredWithDefaultEnvVar(agbotPolicyUserPw, "HZN_EXCHANGE_USER_AUTH", "exchange user authentication must be specified with either the -u flag or HZN_EXCHANGE_USER_AUTH")
+	case agbotRolloutStartCmd.FullCommand(), agbotRolloutPauseCmd.FullCommand(), agbotRolloutResumeCmd.FullCommand(), agbotRolloutRollbackCmd.FullCommand(), agbotRolloutRemoveCmd.FullCommand():
+		agbotRolloutUserPw = cliutils.RequiredWithDefaultEnvVar(agbotRolloutUserPw, "HZN_EXCHANGE_USER_AUTH", "exchange user authentication must be specified with either the -u flag or HZN_EXCHANGE_USER_AUTH")
+	}
 
 	// Decide which command to run
 	switch fullCmd {
@@ -437,6 +520,16 @@ Environment Variables:
 		key.Remove(*keyDelName)
 	case nodeListCmd.FullCommand():
 		node.List()
+	case nodeBackupCmd.FullCommand():
+		node.Backup(*nodeBackupFile)
+	case nodeRestoreCmd.FullCommand():
+		node.Restore(*nodeRestoreFile)
+	case nodeFsckCmd.FullCommand():
+		node.Fsck()
+	case nodePruneImagesCmd.FullCommand():
+		node.PruneImages()
+	case nodeImageImportCmd.FullCommand():
+		node.ImportImage(*nodeImageImportArchive)
 	case agreementListCmd.FullCommand():
 		agreement.List(*listArchivedAgreements, *listAgreementId)
 	case agreementCancelCmd.FullCommand():
@@ -455,12 +548,18 @@ Environment Variables:
 		service.Suspend(*forceSuspendService, *suspendAllServices, *suspendServiceOrg, *suspendServiceName)
 	case serviceConfigStateActiveCmd.FullCommand():
 		service.Resume(*resumeAllServices, *resumeServiceOrg, *resumeServiceName)
+	case serviceLogCmd.FullCommand():
+		service.Log(*logServiceName, *logAgreementId, *logTail, *logSince, *logFollow)
 	case unregisterCmd.FullCommand():
 		unregister.DoIt(*forceUnregister, *removeNodeUnregister)
-	case statusCmd.FullCommand():
+	case statusShowCmd.FullCommand():
 		status.DisplayStatus(*statusLong, false)
+	case statusEventsCmd.FullCommand():
+		status.DisplayEvents(*statusLong, false, *statusEventsCorrelation, *statusEventsEvent, *statusEventsWorker, *statusEventsLimit)
 	case eventlogListCmd.FullCommand():
-		eventlog.List(*listAllEventlogs, *listDetailedEventlogs, *listSelectedEventlogs)
+		eventlog.List(*listAllEventlogs, *listDetailedEventlogs, *listSelectedEventlogs, *listFollowEventlogs)
+	case eventlogPruneCmd.FullCommand():
+		eventlog.Prune(*pruneEventlogsMaxAge, *pruneEventlogsMaxCount, *pruneEventlogsStatus)
 	case devServiceNewCmd.FullCommand():
 		dev.ServiceNew(*devHomeDirectory, *devServiceNewCmdOrg, *devServiceNewCmdCfg)
 	case devServiceStartTestCmd.FullCommand():
@@ -479,15 +578,41 @@ Environment Variables:
 		agreementbot.AgreementList(*agbotlistArchivedAgreements, *agbotAgreement)
 	case agbotAgreementCancelCmd.FullCommand():
 		agreementbot.AgreementCancel(*agbotCancelAgreementId, *agbotCancelAllAgreements)
+	case agbotAgreementStatsCmd.FullCommand():
+		agreementbot.AgreementStats(*agbotAgreementStatsOrg, *agbotAgreementStatsPolicy)
 	case agbotListCmd.FullCommand():
 		agreementbot.List()
 	case agbotPolicyListCmd.FullCommand():
 		agreementbot.PolicyList(*agbotPolicyOrg, *agbotPolicyName)
+	case agbotPolicyAddCmd.FullCommand():
+		agreementbot.PolicyAdd(*agbotPolicyAddOrg, *agbotPolicyAddName, *agbotPolicyUserPw, *agbotPolicyAddFile)
+	case agbotPolicyRemoveCmd.FullCommand():
+		agreementbot.PolicyRemove(*agbotPolicyRemoveOrg, *agbotPolicyRemoveName, *agbotPolicyUserPw, *agbotPolicyRemoveForce)
+	case agbotPolicyPauseCmd.FullCommand():
+		agreementbot.PolicyAction(*agbotPolicyPauseOrg, *agbotPolicyPauseName, *agbotPolicyUserPw, "pause")
+	case agbotPolicyResumeCmd.FullCommand():
+		agreementbot.PolicyAction(*agbotPolicyResumeOrg, *agbotPolicyResumeName, *agbotPolicyUserPw, "resume")
+	case agbotPolicyEvaluateCmd.FullCommand():
+		agreementbot.PolicyEvaluate(*agbotPolicyEvaluateOrg, *agbotPolicyEvaluateName, *agbotPolicyEvaluateFile, *agbotPolicyEvaluateNode, *agbotPolicyEvaluateProducers)
+	case agbotRolloutListCmd.FullCommand():
+		agreementbot.RolloutList(*agbotRolloutListId)
+	case agbotRolloutStartCmd.FullCommand():
+		agreementbot.RolloutStart(*agbotRolloutStartOrg, *agbotRolloutStartName, *agbotRolloutUserPw, *agbotRolloutStartVersion, *agbotRolloutStartPercent, *agbotRolloutStartCount, *agbotRolloutStartThreshold, *agbotRolloutStartOnFailure, *agbotRolloutStartTimeout)
+	case agbotRolloutPauseCmd.FullCommand():
+		agreementbot.RolloutAction(*agbotRolloutPauseId, *agbotRolloutUserPw, "pause")
+	case agbotRolloutResumeCmd.FullCommand():
+		agreementbot.RolloutAction(*agbotRolloutResumeId, *agbotRolloutUserPw, "resume")
+	case agbotRolloutRollbackCmd.FullCommand():
+		agreementbot.RolloutAction(*agbotRolloutRollbackId, *agbotRolloutUserPw, "rollback")
+	case agbotRolloutRemoveCmd.FullCommand():
+		agreementbot.RolloutRemove(*agbotRolloutRemoveId, *agbotRolloutUserPw)
 	case utilSignCmd.FullCommand():
 		utilcmds.Sign(*utilSignPrivKeyFile)
 	case utilVerifyCmd.FullCommand():
 		utilcmds.Verify(*utilVerifyPubKeyFile, *utilVerifySig)
-	case agbotStatusCmd.FullCommand():
+	case agbotStatusShowCmd.FullCommand():
 		status.DisplayStatus(*agbotStatusLong, true)
+	case agbotStatusEventsCmd.FullCommand():
+		status.DisplayEvents(*agbotStatusLong, true, *agbotStatusEventsCorrelation, *agbotStatusEventsEvent, *agbotStatusEventsWorker, *agbotStatusEventsLimit)
 	}
 }
@@ -136,7 +136,7 @@ func (p *NativeDeploymentConfigPlugin) Validate(dep interface{}) (bool, error) {
 }
 
 // This can't be a const because a map literal isn't a const in go
-var VALID_DEPLOYMENT_FIELDS = map[string]int8{"image": 1, "privileged": 1, "cap_add": 1, "environment": 1, "devices": 1, "binds": 1, "specific_ports": 1, "command": 1, "ports": 1, "ephemeral_ports": 1}
+var VALID_DEPLOYMENT_FIELDS = map[string]int8{"image": 1, "privileged": 1, "cap_add": 1, "environment": 1, "devices": 1, "binds": 1, "specific_ports": 1, "command": 1, "ports": 1, "ephemeral_ports": 1, "healthcheck": 1, "resources": 1, "cap_drop": 1, "read_only": 1, "security_opt": 1, "user": 1, "tmpfs": 1}
 
 // CheckDeploymentService verifies it has the required 'image' key, and checks for keys we don't recognize.
 // For now it only prints a warning for unrecognized keys, in case we recently added a key to anax and haven't updated hzn yet.
@@ -145,6 +145,39 @@ func CheckDeploymentService(svcName string, depSvc map[string]interface{}) error
 		return errors.New(fmt.Sprintf("service '%s' defined under 'deployment.services' does not have mandatory 'image' field", svcName))
 	}
 
+	// The health check is checked the same way the agent checks it before it starts the container.
+	if hc, ok := depSvc["healthcheck"]; ok {
+		var healthCheck containermessage.HealthCheck
+		if hcBytes, err := json.Marshal(hc); err != nil {
+			return errors.New(fmt.Sprintf("service '%s' defined under 'deployment.services' has a 'healthcheck' that can not be marshaled: %v", svcName, err))
+		} else if err := json.Unmarshal(hcBytes, &healthCheck); err != nil {
+			return errors.New(fmt.Sprintf("service '%s' defined under 'deployment.services' has a 'healthcheck' that is not valid: %v", svcName, err))
+		} else if err := healthCheck.Validate(); err != nil {
+			return errors.New(fmt.Sprintf("service '%s' defined under 'deployment.services' has a 'healthcheck' that is not valid: %v", svcName, err))
+		}
+	}
+
+	if res, ok := depSvc["resources"]; ok {
+		var resources containermessage.Resources
+		if resBytes, err := json.Marshal(res); err != nil {
+			return errors.New(fmt.Sprintf("service '%s' defined under 'deployment.services' has 'resources' that can not be marshaled: %v", svcName, err))
+		} else if err := json.Unmarshal(resBytes, &resources); err != nil {
+			return errors.New(fmt.Sprintf("service '%s' defined under 'deployment.services' has 'resources' that are not valid: %v", svcName, err))
+		} else if err := resources.Validate(); err != nil {
+			return errors.New(fmt.Sprintf("service '%s' defined under 'deployment.services' has 'resources' that are not valid: %v", svcName, err))
+		}
+	}
+
+	// The hardening settings are checked together, they are signed with the rest of the deployment.
+	var service containermessage.Service
+	if svcBytes, err := json.Marshal(depSvc); err != nil {
+		return errors.New(fmt.Sprintf("service '%s' defined under 'deployment.services' can not be marshaled: %v", svcName, err))
+	} else if err := json.Unmarshal(svcBytes, &service); err != nil {
+		return errors.New(fmt.Sprintf("service '%s' defined under 'deployment.services' is not valid: %v", svcName, err))
+	} else if err := service.ValidateSecurity(); err != nil {
+		return errors.New(fmt.Sprintf("service '%s' defined under 'deployment.services' has security settings that are not valid: %v", svcName, err))
+	}
+
 	// Check the rest of the keys for unrecognized ones
 	for k := range depSvc {
 		if _, ok := VALID_DEPLOYMENT_FIELDS[k]; !ok {
@@ -6,7 +6,12 @@ import (
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/apicommon"
 	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/torrent"
 	"github.com/open-horizon/anax/version"
+	"io/ioutil"
+	"os"
 )
 
 type Configstate struct {
@@ -86,3 +91,113 @@ func Version() {
 	cliutils.HorizonGet("status", []int{200}, &status)
 	fmt.Printf("Horizon Agent version: %s\n", status.Configuration.HorizonVersion)
 }
+
+// Backup writes a snapshot of the agent's database to the file.
+func Backup(filePath string) {
+	var backup string
+	cliutils.HorizonGet("node/backup", []int{200}, &backup)
+	if err := ioutil.WriteFile(filePath, []byte(backup), 0600); err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, "writing %s failed: %v", filePath, err)
+	}
+	fmt.Printf("Node database backed up to %v (%v bytes).\n", filePath, len(backup))
+}
+
+// Restore sends a database backup to the agent, which checks it and restores from it the next time it is started.
+func Restore(filePath string) {
+	backup := cliutils.ReadFile(filePath)
+	httpCode, respBody := cliutils.HorizonPutPost("PUT", "node/restore", []int{202, 400}, backup)
+	if httpCode == 400 {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "the Horizon agent rejected the backup in %s: %s", filePath, respBody)
+	}
+
+	staged := api.RestoreStaged{}
+	if err := json.Unmarshal([]byte(respBody), &staged); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, "failed to unmarshal 'hzn node restore' response: %v", err)
+	}
+	if staged.Report != nil && len(staged.Report.Orphans) != 0 {
+		cliutils.Warning("the backup has %v orphaned records, run 'hzn node fsck' after the restore to see them.", len(staged.Report.Orphans))
+	}
+	fmt.Println(staged.Message)
+}
+
+// Fsck displays the result of checking the agent's database.
+func Fsck() {
+	report := persistence.FsckReport{}
+	cliutils.HorizonGet("node/fsck", []int{200}, &report)
+
+	jsonBytes, err := json.MarshalIndent(report, "", cliutils.JSON_INDENT)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, "failed to marshal 'hzn node fsck' output: %v", err)
+	}
+	fmt.Printf("%s\n", jsonBytes)
+
+	if !report.IsUsable() {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "the node database is damaged, restore it from a backup with 'hzn node restore'.")
+	} else if !report.IsClean() {
+		cliutils.Warning("the node database has %v orphaned records.", len(report.Orphans))
+	}
+}
+
+// PruneImages removes the images of superseded service versions from the node. With --dry-run the images that would
+// be removed are displayed, and nothing is removed.
+func PruneImages() {
+	result := container.ImagePruneResult{}
+	if cliutils.IsDryRun() {
+		cliutils.HorizonGet("node/images/prune", []int{200}, &result)
+	} else {
+		_, respBody := cliutils.HorizonPutPost("POST", "node/images/prune", []int{200}, "")
+		if err := json.Unmarshal([]byte(respBody), &result); err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, "failed to unmarshal 'hzn node prune-images' response: %v", err)
+		}
+	}
+
+	jsonBytes, err := json.MarshalIndent(result, "", cliutils.JSON_INDENT)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, "failed to marshal 'hzn node prune-images' output: %v", err)
+	}
+	fmt.Printf("%s\n", jsonBytes)
+
+	for _, image := range result.Removed {
+		if image.Error != "" {
+			cliutils.Warning("unable to remove image %v: %v", image.Image, image.Error)
+		}
+	}
+}
+
+// ImportImage checks an image archive, made by docker save or in an OCI layout, and sends it to the agent, which adds
+// it to the local image cache and loads its images.
+func ImportImage(archive string) {
+	fi, err := os.Stat(archive)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "unable to read %v: %v", archive, err)
+	} else if fi.IsDir() {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "%v is a directory, create a tar file of the OCI layout or copy it into the image cache directory", archive)
+	}
+
+	images, err := torrent.ReadImageArchive(archive)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "%v", err)
+	}
+	for _, image := range images {
+		cliutils.Verbose("%v has image %v %v", archive, image.ImageId, image.RepoTags)
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "unable to open %v: %v", archive, err)
+	}
+	defer f.Close()
+
+	_, respBody := cliutils.HorizonPostStream("node/images/import", []int{201}, f, fi.Size())
+	if cliutils.IsDryRun() {
+		return
+	} else if err := json.Unmarshal([]byte(respBody), &images); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, "failed to unmarshal 'hzn node image import' response: %v", err)
+	}
+
+	jsonBytes, err := json.MarshalIndent(images, "", cliutils.JSON_INDENT)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, "failed to marshal 'hzn node image import' output: %v", err)
+	}
+	fmt.Printf("%s\n", jsonBytes)
+}
@@ -9,7 +9,10 @@ import (
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
+	"io"
 	"net/http"
+	"net/url"
+	"os"
 )
 
 type APIServices struct {
@@ -170,3 +173,37 @@ func Resume(applyAll bool, serviceOrg string, serviceUrl string) {
 
 	fmt.Println("Service resuming request sucessfully sent, please use 'hzn agreement' and 'docker ps' to make sure the related agreements and service containers are started. It may take a couple of minutes.")
 }
+
+// Print the logs of a service container. The name is the name of the service in its deployment configuration, the
+// agreement id (or dependent service instance id) picks the container when there is more than one with that name.
+func Log(serviceName string, agreementId string, tail string, since string, follow bool) {
+	if serviceName == "" {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "Please specify the name of the service.")
+	}
+
+	params := url.Values{}
+	if agreementId != "" {
+		params.Set("agreement", agreementId)
+	}
+	if tail != "" {
+		params.Set("tail", tail)
+	}
+	if since != "" {
+		params.Set("since", since)
+	}
+	if follow {
+		params.Set("follow", "true")
+	}
+
+	url_s := "service/" + url.PathEscape(serviceName) + "/logs"
+	if len(params) != 0 {
+		url_s = fmt.Sprintf("%v?%v", url_s, params.Encode())
+	}
+
+	_, body := cliutils.HorizonGetStream(url_s, []int{200})
+	defer body.Close()
+
+	if _, err := io.Copy(os.Stdout, body); err != nil {
+		cliutils.Fatal(cliutils.HTTP_ERROR, "failed to read the logs of service %v: %v", serviceName, err)
+	}
+}
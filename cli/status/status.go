@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/worker"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 )
 
 func getStatus(agbot bool) (apiOutput *worker.WorkerStatusManager) {
@@ -43,3 +46,74 @@ func DisplayStatus(details bool, agbot bool) {
 		fmt.Printf("%s\n", jsonBytes)
 	}
 }
+
+// The summary of an event trace shown when the long output is not requested.
+type EventTraceSummary struct {
+	EventId       string   `json:"event_id"`
+	CorrelationId string   `json:"correlation_id"`
+	Time          string   `json:"time"`
+	MessageType   string   `json:"message_type"`
+	Description   string   `json:"description"`
+	Source        string   `json:"source,omitempty"`
+	Consumers     []string `json:"consumers"`
+}
+
+// Display the event traces for node or agbot
+func DisplayEvents(details bool, agbot bool, correlationId string, event string, workerName string, limit int) {
+
+	if agbot {
+		// set env to call agbot url
+		os.Setenv("HORIZON_URL", cliutils.AGBOT_HZN_API)
+	}
+
+	query := url.Values{}
+	if correlationId != "" {
+		query.Set("correlation_id", correlationId)
+	}
+	if event != "" {
+		query.Set("event", event)
+	}
+	if workerName != "" {
+		query.Set("worker", workerName)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	url_s := "status/events"
+	if len(query) > 0 {
+		url_s = fmt.Sprintf("%v?%v", url_s, query.Encode())
+	}
+
+	apiOutput := worker.EventTraceOutput{}
+	cliutils.HorizonGet(url_s, []int{200}, &apiOutput)
+
+	if !apiOutput.Enabled {
+		cliutils.Warning("Event tracing is not enabled. Set EventTrace.Enabled to true in the anax configuration file to turn it on.")
+	}
+
+	var output interface{}
+	if details {
+		output = apiOutput.Traces
+	} else {
+		summaries := make([]EventTraceSummary, 0, len(apiOutput.Traces))
+		for _, t := range apiOutput.Traces {
+			summaries = append(summaries, EventTraceSummary{
+				EventId:       t.EventId,
+				CorrelationId: t.CorrelationId,
+				Time:          time.Unix(0, t.Time).Format("2006-01-02 15:04:05.000"),
+				MessageType:   t.MessageType,
+				Description:   t.Description,
+				Source:        t.Source,
+				Consumers:     t.Consumers,
+			})
+		}
+		output = summaries
+	}
+
+	jsonBytes, err := json.MarshalIndent(output, "", cliutils.JSON_INDENT)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, "failed to marshal 'hzn status events' output: %v", err)
+	}
+	fmt.Printf("%s\n", jsonBytes)
+}
@@ -17,6 +17,143 @@ type HorizonConfig struct {
 	AgreementBot  AGConfig
 	Collaborators Collaborators
 	ArchSynonyms  ArchSynonyms
+	EventTrace    EventTraceConfig
+}
+
+// Configuration for tracing the flow of events and commands through the workers. Tracing is off by default.
+type EventTraceConfig struct {
+	Enabled    bool // Turn on event tracing, the traces are available from the /status/events API.
+	BufferSize int  // The number of the most recent event traces to keep. Zero means use the default.
+}
+
+// Configuration for how long event logs are kept on the node. A zero limit means there is no limit, so by default
+// the event logs are kept forever.
+type EventLogRetentionConfig struct {
+	MaxAgeS        uint64                               // Event logs older than this number of seconds are pruned.
+	MaxCount       int                                  // Only this number of the most recent event logs are kept.
+	Severity       map[string]EventLogSeverityRetention // Tighter limits for the event logs of a severity, keyed by "info", "warning" or "error".
+	PruneIntervalS int                                  // The number of seconds between prunes. The default is 3600.
+}
+
+type EventLogSeverityRetention struct {
+	MaxAgeS  uint64 // Event logs of the severity older than this number of seconds are pruned.
+	MaxCount int    // Only this number of the most recent event logs of the severity are kept.
+}
+
+// Returns true if any retention limit is set.
+func (c EventLogRetentionConfig) IsEnabled() bool {
+	if c.MaxAgeS != 0 || c.MaxCount != 0 {
+		return true
+	}
+	for _, sev := range c.Severity {
+		if sev.MaxAgeS != 0 || sev.MaxCount != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Configuration for exporting the event logs from the node as they are created. An event log that can't be sent to
+// a sink is kept in a queue on disk and sent again later.
+type EventLogExportConfig struct {
+	Sinks          []EventLogSinkConfig // The places to send the event logs to.
+	QueueDir       string               // The directory for the retry queues. The default is the eventlog_export directory in the Edge DBPath.
+	QueueMaxCount  int                  // The number of event logs kept for a sink that is not reachable, the oldest are dropped. The default is 1000.
+	RetryIntervalS int                  // The number of seconds between retries for a sink that is not reachable. The default is 60.
+}
+
+const EVENTLOG_SINK_SYSLOG = "syslog"
+const EVENTLOG_SINK_FILE = "file"
+const EVENTLOG_SINK_HTTP = "http"
+
+type EventLogSinkConfig struct {
+	Name        string // A unique name for the sink, used for the retry queue and in messages.
+	Type        string // syslog, file or http
+	MinSeverity string // Only event logs of this severity or higher are sent, "info", "warning" or "error". The default is info.
+
+	// syslog, RFC5424 messages
+	Network  string // udp or tcp, the default is udp.
+	Address  string // host:port of the syslog server.
+	Facility int    // The syslog facility code, the default is 1 (user).
+
+	// file, one json object per line
+	File       string // The path of the file.
+	MaxSizeMB  int    // The file is rotated when it reaches this size. The default is 10.
+	MaxBackups int    // The number of rotated files to keep. The default is 5.
+
+	// http, each event log is POSTed as a json object
+	URL      string            // The URL to POST to.
+	Headers  map[string]string // Extra headers for the request, for example an Authorization header.
+	TimeoutS int               // The request timeout. The default is 10 seconds.
+}
+
+// The docker log driver for the service containers. The logs can be read with the /service/{name}/logs API whichever
+// driver is used.
+type ContainerLogConfig struct {
+	Driver     string            // syslog, json-file or journald. The default is syslog.
+	MaxSizeMB  int               // json-file, the log is rotated when it reaches this size. The default is 10.
+	MaxFiles   int               // json-file, the number of log files kept for a container. The default is 3.
+	Options    map[string]string // Extra options for the driver, the same as the docker --log-opt flags.
+	SyslogFile string            // The file that the host's syslog writes to, used to read the logs of containers with the syslog driver. The default is /var/log/syslog, or /var/log/messages when that does not exist.
+}
+
+const CONTAINER_LOG_SYSLOG = "syslog"
+const CONTAINER_LOG_JSON_FILE = "json-file"
+const CONTAINER_LOG_JOURNALD = "journald"
+
+// The removal of the images of superseded service versions. The images of the newest KeepVersions versions of each
+// service are kept, so that a service can be rolled back, and so are the images of any version that is running.
+type ImageGCConfig struct {
+	Disabled            bool // Never remove the images of superseded service versions.
+	KeepVersions        int  // The number of versions of each service whose images are kept. The default is 2, the current version and the one before it.
+	IntervalS           int  // How often the images are pruned. The default is 86400 seconds.
+	CheckIntervalS      int  // How often the disk usage of the docker root is checked. The default is 300 seconds.
+	DiskPressurePercent int  // The images are pruned as soon as the file system of the docker root is this full. The default is 85.
+}
+
+// The local image cache holds image archives, from docker save or in an OCI layout, that the node loads instead of
+// pulling the images from their registries, for nodes that can't reach a registry.
+type ImageCacheConfig struct {
+	Disabled bool   // Always pull the images from their registries.
+	Dir      string // The directory of image archives. The default is the image_cache directory in the Edge DBPath.
+}
+
+// The checks that a node makes before it accepts a proposal, so that it does not agree to run a service that can't be
+// deployed. The memory a service needs is its RAM attribute, or DefaultServiceRegistrationRAM when it has none.
+type PreflightConfig struct {
+	Disabled      bool  // Accept proposals without checking the node's resources.
+	MinFreeDiskMB int64 // The free space, in MB, that the service storage and docker root file systems must have. The default is 500.
+}
+
+// The node owner's rules for the service containers that are allowed to run on the node. A deployment that breaks a
+// rule is not started, and an event log says why.
+type DeploymentSecurityConfig struct {
+	DenyPrivileged   bool     // Reject services that ask to run privileged.
+	DenyCapabilities []string // Reject services that add any of these capabilities to their containers, "ALL" rejects any cap_add. Privileged services have every capability.
+	RequireDigests   bool     // Reject services whose images are referenced by tag instead of by digest.
+}
+
+// Returns the reason that a service with these settings is not allowed to run on the node, or an empty string.
+func (c DeploymentSecurityConfig) Violation(privileged bool, capAdd []string) string {
+	if c.DenyPrivileged && privileged {
+		return "privileged mode is not allowed on this node"
+	}
+	// Docker accepts capabilities with or without the CAP_ prefix, in any case.
+	normalize := func(capability string) string {
+		return strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+	}
+	// A privileged container has every capability, and so does a container that adds "ALL".
+	if privileged && len(c.DenyCapabilities) != 0 {
+		return fmt.Sprintf("privileged mode grants capability %v, which is not allowed on this node", c.DenyCapabilities[0])
+	}
+	for _, denied := range c.DenyCapabilities {
+		for _, added := range capAdd {
+			if normalize(denied) == "ALL" || normalize(added) == "ALL" || normalize(denied) == normalize(added) {
+				return fmt.Sprintf("capability %v is not allowed on this node", added)
+			}
+		}
+	}
+	return ""
 }
 
 // This is the configuration options for Edge component flavor of Anax
@@ -25,7 +162,9 @@ type Config struct {
 	TorrentDir                       string
 	APIListen                        string
 	DBPath                           string
+	DBType                           string // The edge database implementation, bolt or sqlite. The default is bolt.
 	DockerEndpoint                   string
+	ContainerRuntime                 string // The container runtime, docker or podman. The default is docker.
 	DockerCredFilePath               string
 	DefaultCPUSet                    string
 	DefaultServiceRegistrationRAM    int64
@@ -54,6 +193,27 @@ type Config struct {
 	ServiceConfigStateCheckIntervalS int       // the service configuration state check interval. The default is 30 seconds.
 	FileSyncService                  FSSConfig // The config for the embedded ESS sync service.
 
+	// the retention policy for the event logs
+	EventLogRetention EventLogRetentionConfig
+
+	// the sinks that the event logs are exported to
+	EventLogExport EventLogExportConfig
+
+	// the rules for the service containers that can run on the node
+	DeploymentSecurity DeploymentSecurityConfig
+
+	// the log driver for the service containers
+	ContainerLog ContainerLogConfig
+
+	// the removal of the images of superseded service versions
+	ImageGC ImageGCConfig
+
+	// the archives of images that are loaded instead of pulled
+	ImageCache ImageCacheConfig
+
+	// the resource checks that are made before a proposal is accepted
+	Preflight PreflightConfig
+
 	// these Ids could be provided in config or discovered after startup by the system
 	BlockchainAccountId        string
 	BlockchainDirectoryAddress string
@@ -89,6 +249,8 @@ type AGConfig struct {
 	APIListen                     string           // Host and port for the API to listen on
 	PurgeArchivedAgreementHours   int              // Number of hours to leave an archived agreement in the database before automatically deleting it
 	CheckUpdatedPolicyS           int              // The number of seconds to wait between checks for an updated policy file. Zero means auto checking is turned off.
+	PreflightRetryS               int              // The number of seconds to wait before proposing again to a node that failed its pre-flight checks. The default is 3600, bounded by PurgeArchivedAgreementHours.
+	FullSearchIntervalS           int              // The number of seconds between full node searches, searches in between only return changed nodes. The default is 600, negative turns off the node search cache.
 }
 
 func (c *HorizonConfig) UserPublicKeyPath() string {
@@ -103,6 +265,40 @@ func (c *HorizonConfig) UserPublicKeyPath() string {
 	return c.Edge.UserPublicKeyPath
 }
 
+func (c *HorizonConfig) GetEdgeDBType() string {
+	if c.Edge.DBType == "" {
+		return EDGE_DB_TYPE_BOLT
+	}
+	return c.Edge.DBType
+}
+
+func (c *HorizonConfig) GetContainerRuntime() string {
+	if c.Edge.ContainerRuntime == "" {
+		return CONTAINER_RUNTIME_DOCKER
+	}
+	return c.Edge.ContainerRuntime
+}
+
+// The full path of the edge database file for the configured database implementation.
+func (c *HorizonConfig) GetEdgeDBFile() string {
+	if c.GetEdgeDBType() == EDGE_DB_TYPE_SQLITE {
+		return path.Join(c.Edge.DBPath, EDGE_SQLITE_DB_FILE)
+	}
+	return path.Join(c.Edge.DBPath, EDGE_BOLT_DB_FILE)
+}
+
+// The directory of the local image cache, or an empty string when the node has no image cache.
+func (c *HorizonConfig) GetImageCacheDir() string {
+	if c.Edge.ImageCache.Disabled {
+		return ""
+	} else if c.Edge.ImageCache.Dir != "" {
+		return c.Edge.ImageCache.Dir
+	} else if c.Edge.DBPath != "" {
+		return path.Join(c.Edge.DBPath, IMAGE_CACHE_DIR)
+	}
+	return ""
+}
+
 func (c *HorizonConfig) IsBoltDBConfigured() bool {
 	return len(c.AgreementBot.DBPath) != 0
 }
@@ -188,6 +384,62 @@ func Read(file string) (*HorizonConfig, error) {
 			config.Edge.ServiceConfigStateCheckIntervalS = 30
 		}
 
+		if config.Edge.EventLogRetention.PruneIntervalS == 0 {
+			config.Edge.EventLogRetention.PruneIntervalS = 3600
+		}
+
+		if config.Edge.EventLogExport.QueueMaxCount == 0 {
+			config.Edge.EventLogExport.QueueMaxCount = 1000
+		}
+		if config.Edge.EventLogExport.RetryIntervalS == 0 {
+			config.Edge.EventLogExport.RetryIntervalS = 60
+		}
+
+		if config.Edge.ContainerLog.Driver == "" {
+			config.Edge.ContainerLog.Driver = CONTAINER_LOG_SYSLOG
+		} else if d := config.Edge.ContainerLog.Driver; d != CONTAINER_LOG_SYSLOG && d != CONTAINER_LOG_JSON_FILE && d != CONTAINER_LOG_JOURNALD {
+			return nil, fmt.Errorf("Unsupported ContainerLog Driver %v in config file, must be %v, %v or %v", d, CONTAINER_LOG_SYSLOG, CONTAINER_LOG_JSON_FILE, CONTAINER_LOG_JOURNALD)
+		}
+		if config.Edge.ContainerLog.MaxSizeMB == 0 {
+			config.Edge.ContainerLog.MaxSizeMB = 10
+		}
+		if config.Edge.ContainerLog.MaxFiles == 0 {
+			config.Edge.ContainerLog.MaxFiles = 3
+		}
+
+		if config.Edge.ImageGC.KeepVersions == 0 {
+			config.Edge.ImageGC.KeepVersions = 2
+		} else if config.Edge.ImageGC.KeepVersions < 0 {
+			return nil, fmt.Errorf("ImageGC KeepVersions %v in config file must not be negative", config.Edge.ImageGC.KeepVersions)
+		}
+		if config.Edge.ImageGC.IntervalS == 0 {
+			config.Edge.ImageGC.IntervalS = 86400
+		}
+		if config.Edge.ImageGC.CheckIntervalS == 0 {
+			config.Edge.ImageGC.CheckIntervalS = 300
+		}
+		if config.Edge.ImageGC.DiskPressurePercent == 0 {
+			config.Edge.ImageGC.DiskPressurePercent = 85
+		}
+
+		if config.Edge.Preflight.MinFreeDiskMB == 0 {
+			config.Edge.Preflight.MinFreeDiskMB = 500
+		} else if config.Edge.Preflight.MinFreeDiskMB < 0 {
+			return nil, fmt.Errorf("Preflight MinFreeDiskMB %v in config file must not be negative", config.Edge.Preflight.MinFreeDiskMB)
+		}
+
+		if r := config.GetContainerRuntime(); r != CONTAINER_RUNTIME_DOCKER && r != CONTAINER_RUNTIME_PODMAN {
+			return nil, fmt.Errorf("Unsupported ContainerRuntime %v in config file, must be %v or %v", r, CONTAINER_RUNTIME_DOCKER, CONTAINER_RUNTIME_PODMAN)
+		}
+
+		if config.AgreementBot.PreflightRetryS == 0 {
+			config.AgreementBot.PreflightRetryS = 3600
+		}
+
+		if config.AgreementBot.FullSearchIntervalS == 0 {
+			config.AgreementBot.FullSearchIntervalS = 600
+		}
+
 		// set default retry parameters
 		// the default DefaultServiceRetryCount is 2. It means 2 tries including the original one.
 		// so it is actually 1 retry.
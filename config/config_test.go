@@ -65,3 +65,44 @@ func Test_enrichFromEnvvars_success(t *testing.T) {
 	}
 
 }
+
+func Test_DeploymentSecurityConfig_Violation(t *testing.T) {
+
+	// Nothing is denied by default.
+	if reason := (DeploymentSecurityConfig{}).Violation(true, []string{"SYS_ADMIN"}); reason != "" {
+		t.Errorf("The default config should not deny anything, but it returned %v", reason)
+	}
+
+	c := DeploymentSecurityConfig{DenyPrivileged: true, DenyCapabilities: []string{"CAP_SYS_ADMIN", "net_admin"}}
+	if reason := c.Violation(true, nil); reason == "" {
+		t.Errorf("Privileged mode should have been denied.")
+	} else if reason := c.Violation(false, []string{"sys_admin"}); reason == "" {
+		t.Errorf("SYS_ADMIN should have been denied.")
+	} else if reason := c.Violation(false, []string{"CAP_NET_ADMIN"}); reason == "" {
+		t.Errorf("NET_ADMIN should have been denied.")
+	} else if reason := c.Violation(false, []string{"NET_RAW"}); reason != "" {
+		t.Errorf("NET_RAW should have been allowed, but it returned %v", reason)
+	}
+
+	c = DeploymentSecurityConfig{DenyCapabilities: []string{"ALL"}}
+	if reason := c.Violation(false, []string{"CHOWN"}); reason == "" {
+		t.Errorf("Any capability should have been denied.")
+	} else if reason := c.Violation(false, nil); reason != "" {
+		t.Errorf("No capabilities should have been allowed, but it returned %v", reason)
+	}
+
+	// Adding ALL adds every denied capability.
+	c = DeploymentSecurityConfig{DenyCapabilities: []string{"SYS_ADMIN"}}
+	if reason := c.Violation(false, []string{"all"}); reason == "" {
+		t.Errorf("ALL should have been denied, because it includes SYS_ADMIN.")
+	} else if reason := c.Violation(false, []string{"CHOWN"}); reason != "" {
+		t.Errorf("CHOWN should have been allowed, but it returned %v", reason)
+	}
+
+	// Privileged mode grants every capability, so it is denied when any capability is denied.
+	if reason := c.Violation(true, nil); reason == "" {
+		t.Errorf("Privileged mode should have been denied, because it grants SYS_ADMIN.")
+	} else if reason := (DeploymentSecurityConfig{RequireDigests: true}).Violation(true, nil); reason != "" {
+		t.Errorf("Privileged mode should have been allowed, but it returned %v", reason)
+	}
+}
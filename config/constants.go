@@ -50,3 +50,18 @@ const HZN_FSS_CERT_KEY_FILE = "key.pem"
 
 // The number of seconds between polls to the CSS for updates.
 const HZN_FSS_POLLING_RATE = 60
+
+// The edge database implementations that can be configured, the default is bolt.
+const EDGE_DB_TYPE_BOLT = "bolt"
+const EDGE_DB_TYPE_SQLITE = "sqlite"
+
+// The name of the database file, within the Edge DBPath, used by each database implementation.
+const EDGE_BOLT_DB_FILE = "anax.db"
+const EDGE_SQLITE_DB_FILE = "anax.sqlite"
+
+// The container runtimes that can be configured, the default is docker. Podman is used through its docker compatible API.
+const CONTAINER_RUNTIME_DOCKER = "docker"
+const CONTAINER_RUNTIME_PODMAN = "podman"
+
+// The directory, within the Edge DBPath, of the local image cache when ImageCache Dir is not configured.
+const IMAGE_CACHE_DIR = "image_cache"
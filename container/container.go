@@ -7,18 +7,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/coreos/go-iptables/iptables"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/glog"
+	"github.com/open-horizon/anax/abstractprotocol"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/resource"
+	"github.com/open-horizon/anax/torrent"
 	"github.com/open-horizon/anax/worker"
 	"golang.org/x/sys/unix"
 	"io"
@@ -29,11 +32,23 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const LABEL_PREFIX = "openhorizon.anax"
 const IPT_COLONUS_ISOLATED_CHAIN = "OPENHORIZON-ANAX-ISOLATION"
 
+// The health of a container that has a health check, as docker shows it at the end of the container status.
+const (
+	HEALTH_STARTING  = "starting"
+	HEALTH_HEALTHY   = "healthy"
+	HEALTH_UNHEALTHY = "unhealthy"
+)
+
+// The number of times that containers could not be started, labelled by whether they were for a workload (agreement)
+// or for a service.
+var containerStartFailures = metrics.NewCounterVec("anax_container_start_failures_total", "Number of times that the containers for a workload or service failed to start.", "type")
+
 /*
  *
  * The external representations of the service deployment string; once processed, the data is stored in a persistence.MicroserviceDefinition object for a service
@@ -162,22 +177,20 @@ func removeDuplicateVariable(existingArray *[]string, newVar string) {
 
 }
 
-func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *containermessage.DeploymentDescription, environmentAdditions map[string]string, workloadRWStorageDir string, cpuSet string, uds string) (map[string]servicePair, error) {
+func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *containermessage.DeploymentDescription, environmentAdditions map[string]string, workloadRWStorageDir string, cpuSet string, limits *policy.ResourceLimit, uds string) (map[string]servicePair, error) {
 
 	// final structure
 	services := make(map[string]servicePair, 0)
 
-	var ramBytes int64
+	var ramMB int64
 
 	// we know that RAM is in MB
 	if ram, exists := (environmentAdditions)[config.ENVVAR_PREFIX+"RAM"]; !exists {
 		return nil, fmt.Errorf("Missing required environment var *RAM for agreement: %v", agreementId)
+	} else if mb, err := strconv.ParseInt(ram, 10, 64); err != nil {
+		return nil, err
 	} else {
-		ramMB, err := strconv.ParseInt(ram, 10, 64)
-		if err != nil {
-			return nil, err
-		}
-		ramBytes = ramMB * 1024 * 1024
+		ramMB = mb
 	}
 
 	if len(deployment.Services) == 0 {
@@ -190,6 +203,10 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			return nil, err
 		}
 
+		if err := service.ValidateSecurity(); err != nil {
+			return nil, fmt.Errorf("Illegal security settings specified in deployment description for service %v: %v", serviceName, err)
+		}
+
 		// If the FSS is using a unix domain socket listener, add a filesystem binding for it.
 		if uds != "" {
 			service.Binds = append(service.Binds, fmt.Sprintf("%v:%v", uds, uds))
@@ -221,29 +238,20 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 
 		if !deployment.ServicePattern.IsShared("singleton", serviceName) {
 			labels[LABEL_PREFIX+".agreement_id"] = agreementId
-			logConfig = docker.LogConfig{
-				Type: "syslog",
-				Config: map[string]string{
-					"tag": fmt.Sprintf("workload-%v_%v", strings.ToLower(agreementId), serviceName),
-				},
-			}
+			logConfig = containerLogConfig(w.Config.Edge.ContainerLog, fmt.Sprintf("workload-%v_%v", strings.ToLower(agreementId), serviceName))
 		} else {
 			logName := serviceName
 			if service.VariationLabel != "" {
 				logName = fmt.Sprintf("%v-%v", serviceName, service.VariationLabel)
 			}
 
-			logConfig = docker.LogConfig{
-				Type: "syslog",
-				Config: map[string]string{
-					"tag": fmt.Sprintf("workload-%v_%v", "singleton", logName),
-				},
-			}
+			logConfig = containerLogConfig(w.Config.Edge.ContainerLog, fmt.Sprintf("workload-%v_%v", "singleton", logName))
 		}
 
 		serviceConfig := &persistence.ServiceConfig{
 			Config: docker.Config{
 				Image:        service.Image,
+				User:         service.User,
 				Env:          []string{},
 				Cmd:          service.Command,
 				CPUSet:       cpuSet,
@@ -254,11 +262,14 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			HostConfig: docker.HostConfig{
 				Privileged:      service.Privileged,
 				CapAdd:          service.CapAdd,
+				CapDrop:         service.CapDrop,
+				ReadonlyRootfs:  service.ReadOnly,
+				SecurityOpt:     service.SecurityOpt,
+				Tmpfs:           service.Tmpfs,
 				PublishAllPorts: false,
 				PortBindings:    map[docker.Port][]docker.PortBinding{},
 				Links:           nil, // do not allow any
 				RestartPolicy:   docker.AlwaysRestart(),
-				Memory:          ramBytes,
 				MemorySwap:      0,
 				Devices:         []docker.Device{},
 				LogConfig:       logConfig,
@@ -266,6 +277,19 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			},
 		}
 
+		// Docker runs the health check, the maintenance commands look for containers that it found to be unhealthy.
+		if service.HealthCheck != nil {
+			if hc, err := service.HealthCheck.HealthConfig(); err != nil {
+				return nil, fmt.Errorf("Illegal healthcheck specified in deployment description for service %v: %v", serviceName, err)
+			} else {
+				serviceConfig.Config.Healthcheck = hc
+			}
+		}
+
+		if err := setResources(serviceConfig, service.Resources, limits, ramMB); err != nil {
+			return nil, fmt.Errorf("Illegal resources specified in deployment description for service %v: %v", serviceName, err)
+		}
+
 		// Mark each container as infrastructure if the deployment description indicates infrastructure
 		if deployment.Infrastructure {
 			serviceConfig.Config.Labels[LABEL_PREFIX+".infrastructure"] = ""
@@ -390,14 +414,21 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 
 type ContainerWorker struct {
 	worker.BaseWorker // embedded field
-	db                *bolt.DB
-	client            *docker.Client
+	db                persistence.EdgeDatabase
+	runtime           containerruntime.Runtime
 	iptables          *iptables.IPTables
 	authMgr           *resource.AuthenticationManager
+	shaper            TrafficShaper
+	lastImageGC       time.Time
+}
+
+func (cw *ContainerWorker) GetRuntime() containerruntime.Runtime {
+	return cw.runtime
 }
 
+// Returns the docker client of the container runtime, or nil if the runtime is not docker compatible.
 func (cw *ContainerWorker) GetClient() *docker.Client {
-	return cw.client
+	return containerruntime.DockerClient(cw.runtime)
 }
 
 func (cw *ContainerWorker) GetAuthenticationManager() *resource.AuthenticationManager {
@@ -405,8 +436,7 @@ func (cw *ContainerWorker) GetAuthenticationManager() *resource.AuthenticationMa
 }
 
 func CreateCLIContainerWorker(config *config.HorizonConfig) (*ContainerWorker, error) {
-	dockerEP := "unix:///var/run/docker.sock"
-	client, derr := docker.NewClient(dockerEP)
+	rt, derr := containerruntime.NewDockerRuntime(containerruntime.DOCKER_DEFAULT_ENDPOINT)
 	if derr != nil {
 		return nil, derr
 	}
@@ -414,13 +444,14 @@ func CreateCLIContainerWorker(config *config.HorizonConfig) (*ContainerWorker, e
 	return &ContainerWorker{
 		BaseWorker: worker.NewBaseWorker("mock", config, nil),
 		db:         nil,
-		client:     client,
+		runtime:    rt,
 		iptables:   nil,
 		authMgr:    resource.NewAuthenticationManager(config.GetFileSyncServiceAuthPath()),
+		shaper:     NewTrafficShaper(),
 	}, nil
 }
 
-func NewContainerWorker(name string, config *config.HorizonConfig, db *bolt.DB, am *resource.AuthenticationManager) *ContainerWorker {
+func NewContainerWorker(name string, config *config.HorizonConfig, db persistence.EdgeDatabase, am *resource.AuthenticationManager) *ContainerWorker {
 
 	// if config.Edge.ServiceStorage is not empty, then we assume that the local file system directory will
 	// be used for the storage of the service container.
@@ -435,16 +466,17 @@ func NewContainerWorker(name string, config *config.HorizonConfig, db *bolt.DB,
 	if ipt, err := iptables.New(); err != nil {
 		glog.Errorf("Failed to instantiate iptables Client: %v", err)
 		panic("Unable to instantiate iptables Client")
-	} else if client, err := docker.NewClient(config.Edge.DockerEndpoint); err != nil {
-		glog.Errorf("Failed to instantiate docker Client: %v", err)
-		panic("Unable to instantiate docker Client")
+	} else if rt, err := containerruntime.NewRuntime(config); err != nil {
+		glog.Errorf("Failed to instantiate %v container runtime: %v", config.GetContainerRuntime(), err)
+		panic("Unable to instantiate container runtime")
 	} else {
 		worker := &ContainerWorker{
 			BaseWorker: worker.NewBaseWorker(name, config, nil),
 			db:         db,
-			client:     client,
+			runtime:    rt,
 			iptables:   ipt,
 			authMgr:    am,
+			shaper:     NewTrafficShaper(),
 		}
 		worker.SetDeferredDelay(15)
 
@@ -469,12 +501,12 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 			case *events.AgreementLaunchContext:
 				lc := msg.LaunchContext.(*events.AgreementLaunchContext)
 				cCmd := w.NewWorkloadConfigureCommand(msg.DeploymentDescription, lc)
-				w.Commands <- cCmd
+				w.Commands <- w.TraceCommand(incoming, cCmd)
 
 			case *events.ContainerLaunchContext:
 				lc := msg.LaunchContext.(*events.ContainerLaunchContext)
 				cCmd := w.NewContainerConfigureCommand(msg.DeploymentDescription, lc)
-				w.Commands <- cCmd
+				w.Commands <- w.TraceCommand(incoming, cCmd)
 			}
 		}
 
@@ -484,7 +516,7 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.CONTAINER_MAINTAIN:
 			containerCmd := w.NewContainerMaintenanceCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
-			w.Commands <- containerCmd
+			w.Commands <- w.TraceCommand(incoming, containerCmd)
 		}
 
 	case *events.GovernanceWorkloadCancelationMessage:
@@ -493,7 +525,7 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.AGREEMENT_ENDED:
 			containerCmd := w.NewWorkloadShutdownCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment, []string{})
-			w.Commands <- containerCmd
+			w.Commands <- w.TraceCommand(incoming, containerCmd)
 		}
 
 	case *events.ContainerStopMessage:
@@ -502,7 +534,7 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.CONTAINER_STOPPING:
 			containerCmd := w.NewContainerStopCommand(msg)
-			w.Commands <- containerCmd
+			w.Commands <- w.TraceCommand(incoming, containerCmd)
 		}
 
 	case *events.MicroserviceMaintenanceMessage:
@@ -511,7 +543,7 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.CONTAINER_MAINTAIN:
 			containerCmd := w.NewMaintainMicroserviceCommand(msg.MsInstKey)
-			w.Commands <- containerCmd
+			w.Commands <- w.TraceCommand(incoming, containerCmd)
 		}
 
 	case *events.MicroserviceCancellationMessage:
@@ -520,14 +552,14 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.CANCEL_MICROSERVICE:
 			containerCmd := w.NewShutdownMicroserviceCommand(msg.MsInstKey)
-			w.Commands <- containerCmd
+			w.Commands <- w.TraceCommand(incoming, containerCmd)
 		}
 
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
 		case events.UNCONFIGURE_COMPLETE:
-			w.Commands <- NewNodeUnconfigCommand(msg)
+			w.Commands <- w.TraceCommand(incoming, NewNodeUnconfigCommand(msg))
 		}
 
 	default: // nothing
@@ -537,7 +569,7 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 	return
 }
 
-func mkBridge(client *docker.Client, name string, infrastructure bool, sharedPattern bool) (*docker.Network, error) {
+func mkBridge(rt containerruntime.Runtime, name string, infrastructure bool, sharedPattern bool) (*docker.Network, error) {
 
 	// Labels on the docker network indicate attributes about the network.
 	labels := make(map[string]string)
@@ -567,14 +599,14 @@ func mkBridge(client *docker.Client, name string, infrastructure bool, sharedPat
 		Labels: labels,
 	}
 
-	bridge, err := client.CreateNetwork(bridgeOpts)
+	bridge, err := rt.CreateNetwork(bridgeOpts)
 	if err != nil {
 		return nil, err
 	}
 	return bridge, nil
 }
 
-func serviceStart(client *docker.Client,
+func serviceStart(rt containerruntime.Runtime,
 	agreementId string,
 	serviceName string,
 	shareLabel string,
@@ -583,7 +615,7 @@ func serviceStart(client *docker.Client,
 	sharedEndpoints map[string]*docker.EndpointConfig,
 	postCreateContainers *[]interface{},
 	fail func(container *docker.Container, name string, err error) error,
-	useSyslog bool) error {
+	useLogDriver bool) error {
 
 	var namePrefix string
 	if shareLabel != "" {
@@ -601,14 +633,14 @@ func serviceStart(client *docker.Client,
 		},
 	}
 
-	// this for the retry after log driver using syslog failed.
-	if !useSyslog {
+	// this for the retry after the configured log driver failed.
+	if !useLogDriver {
 		containerOpts.HostConfig.LogConfig = docker.LogConfig{}
 	}
 
 	glog.V(5).Infof("CreateContainer options: Config: %v, HostConfig: %v, EndpointsConfig: %v", serviceConfig.Config, serviceConfig.HostConfig, endpointsConfig)
 
-	container, cErr := client.CreateContainer(containerOpts)
+	container, cErr := rt.CreateContainer(containerOpts)
 	if cErr != nil {
 		if cErr == docker.ErrContainerAlreadyExists {
 			return cErr
@@ -617,22 +649,21 @@ func serviceStart(client *docker.Client,
 		}
 	}
 
-	// second arg just a backwards compat feature, will go away someday
-	err := client.StartContainer(container.ID, nil)
+	err := rt.StartContainer(container.ID)
 	if err != nil {
-		if strings.Contains(err.Error(), "logging driver") && strings.Contains(err.Error(), "syslog") {
+		if strings.Contains(err.Error(), "logging driver") {
 			// prevent infinit loop, just in case
-			if !useSyslog {
+			if !useLogDriver {
 				return fail(container, serviceName, err)
 			}
 
-			// if the error is related to syslog, use the default for logconfig and retry
-			glog.V(3).Infof("StartContainer logconfig cannot use syslog: %v. Switching to default. You can use 'docker logs -f <container_name> to view the logs.", err)
+			// if the error is related to the log driver, use the docker default for logconfig and retry
+			glog.Warningf("StartContainer logconfig cannot use the %v log driver: %v. Switching to the docker default. The logs can still be read with 'hzn service log %v'.", serviceConfig.HostConfig.LogConfig.Type, err, serviceName)
 
-			if err_r := client.RemoveContainer(docker.RemoveContainerOptions{ID: container.ID, RemoveVolumes: false, Force: true}); err_r != nil {
+			if err_r := rt.RemoveContainer(container.ID, false); err_r != nil {
 				return fail(container, serviceName, err_r)
 			} else {
-				return serviceStart(client, agreementId, serviceName, shareLabel, serviceConfig, endpointsConfig,
+				return serviceStart(rt, agreementId, serviceName, shareLabel, serviceConfig, endpointsConfig,
 					sharedEndpoints, postCreateContainers, fail, false)
 			}
 		} else {
@@ -641,11 +672,7 @@ func serviceStart(client *docker.Client,
 	}
 	for _, cfg := range sharedEndpoints {
 		glog.V(5).Infof("Connecting network: %v to container id: %v", cfg.NetworkID, container.ID)
-		err := client.ConnectNetwork(cfg.NetworkID, docker.NetworkConnectionOptions{
-			Container:      container.ID,
-			EndpointConfig: cfg,
-			Force:          true,
-		})
+		err := rt.ConnectNetwork(cfg.NetworkID, container.ID, cfg)
 		if err != nil {
 			return fail(container, serviceName, err)
 		}
@@ -657,9 +684,9 @@ func serviceStart(client *docker.Client,
 	return nil
 }
 
-func serviceDestroy(client *docker.Client, agreementId string, containerId string) (bool, error) {
+func serviceDestroy(rt containerruntime.Runtime, agreementId string, containerId string) (bool, error) {
 	glog.V(3).Infof("Attempting to stop container %v from agreement: %v.", containerId, agreementId)
-	err := client.KillContainer(docker.KillContainerOptions{ID: containerId})
+	err := rt.KillContainer(containerId)
 
 	if err != nil {
 		if _, ok := err.(*docker.NoSuchContainer); ok {
@@ -670,13 +697,13 @@ func serviceDestroy(client *docker.Client, agreementId string, containerId strin
 	}
 
 	glog.V(3).Infof("Attempting to remove container %v from agreement: %v.", containerId, agreementId)
-	return true, client.RemoveContainer(docker.RemoveContainerOptions{ID: containerId, RemoveVolumes: true, Force: true})
+	return true, rt.RemoveContainer(containerId, true)
 }
 
-func existingShared(client *docker.Client, serviceName string, servicePair *servicePair, bridgeName string, shareLabel string) (*docker.Network, *docker.APIContainers, error) {
+func existingShared(rt containerruntime.Runtime, serviceName string, servicePair *servicePair, bridgeName string, shareLabel string) (*docker.Network, *docker.APIContainers, error) {
 
 	var sBridge docker.Network
-	networks, err := client.ListNetworks()
+	networks, err := rt.ListNetworks()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -689,19 +716,14 @@ func existingShared(client *docker.Client, serviceName string, servicePair *serv
 	}
 
 	// some of the facts in the labels that are compared will also be in the hash
-	sharedOnly := docker.ListContainersOptions{
-		All: true,
-		Filters: map[string][]string{
-			"label": []string{
-				fmt.Sprintf("%v.service_name=%v", LABEL_PREFIX, serviceName),
-				fmt.Sprintf("%v.variation=%v", LABEL_PREFIX, servicePair.service.VariationLabel),
-				fmt.Sprintf("%v.deployment_description_hash=%v", LABEL_PREFIX, servicePair.serviceConfig.Config.Labels[fmt.Sprintf("%v.deployment_description_hash", LABEL_PREFIX)]),
-				fmt.Sprintf("%v.service_pattern.shared=%v", LABEL_PREFIX, shareLabel),
-			},
-		},
-	}
-	glog.V(5).Infof("Searching for containers with filter %v", sharedOnly)
-	containers, err := client.ListContainers(sharedOnly)
+	sharedOnly := []string{
+		fmt.Sprintf("%v.service_name=%v", LABEL_PREFIX, serviceName),
+		fmt.Sprintf("%v.variation=%v", LABEL_PREFIX, servicePair.service.VariationLabel),
+		fmt.Sprintf("%v.deployment_description_hash=%v", LABEL_PREFIX, servicePair.serviceConfig.Config.Labels[fmt.Sprintf("%v.deployment_description_hash", LABEL_PREFIX)]),
+		fmt.Sprintf("%v.service_pattern.shared=%v", LABEL_PREFIX, shareLabel),
+	}
+	glog.V(5).Infof("Searching for containers with labels %v", sharedOnly)
+	containers, err := rt.ListContainers(true, sharedOnly)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -714,7 +736,7 @@ func existingShared(client *docker.Client, serviceName string, servicePair *serv
 			for _, name := range con.Names {
 				if strings.TrimLeft(name, "/") == bridgeName {
 					// We found the shared container, but it is not running
-					if err := client.RemoveContainer(docker.RemoveContainerOptions{ID: con.ID, RemoveVolumes: true, Force: true}); err != nil {
+					if err := rt.RemoveContainer(con.ID, true); err != nil {
 						glog.Errorf("Error removing stopped shared container %v %v, error %v", con.Names, con.ID, err)
 						return nil, nil, err
 					}
@@ -722,7 +744,7 @@ func existingShared(client *docker.Client, serviceName string, servicePair *serv
 				}
 			}
 			// Do the container search again so that we are working with an updated list
-			containers, err = client.ListContainers(sharedOnly)
+			containers, err = rt.ListContainers(true, sharedOnly)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -802,7 +824,7 @@ func generatePermittedString(isolation *containermessage.NetworkIsolation, netwo
 	return fmt.Sprintf("%v%v/%v", permittedString, network.IPAddress, network.IPPrefixLen), nil
 }
 
-func processPostCreate(ipt *iptables.IPTables, client *docker.Client, agreementId string, deployment containermessage.DeploymentDescription, configureRaw []byte, hasSpecifiedEthAccount bool, containers []interface{}, fail func(container *docker.Container, name string, err error) error) error {
+func processPostCreate(ipt *iptables.IPTables, rt containerruntime.Runtime, agreementId string, deployment containermessage.DeploymentDescription, configureRaw []byte, hasSpecifiedEthAccount bool, containers []interface{}, fail func(container *docker.Container, name string, err error) error) error {
 
 	if ipt != nil {
 		rules, err := ipt.List("filter", IPT_COLONUS_ISOLATED_CHAIN)
@@ -867,7 +889,7 @@ func processPostCreate(ipt *iptables.IPTables, client *docker.Client, agreementI
 			container := con.(*docker.Container)
 
 			// incoming "container" type does not have Config member
-			conDetail, err := client.InspectContainer(container.ID)
+			conDetail, err := rt.InspectContainer(container.ID)
 			if err != nil {
 				return fail(nil, container.Name, fmt.Errorf("Unable to find container detail for container during post-creation step: Error: %v", err))
 			}
@@ -956,7 +978,7 @@ func (b *ContainerWorker) workloadStorageDir(agreementId string) (string, bool)
 }
 
 // This function creates the containers, volumes, networks for the given agreement or service.
-func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol string, configure *events.ContainerConfig, deployment *containermessage.DeploymentDescription, configureRaw []byte, environmentAdditions map[string]string, ms_networks map[string]docker.ContainerNetwork, serviceURL string) (persistence.DeploymentConfig, error) {
+func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol string, configure *events.ContainerConfig, deployment *containermessage.DeploymentDescription, configureRaw []byte, environmentAdditions map[string]string, ms_networks map[string]docker.ContainerNetwork, serviceURL string, limits *policy.ResourceLimit) (persistence.DeploymentConfig, error) {
 
 	// local helpers
 	fail := func(container *docker.Container, name string, err error) error {
@@ -1035,7 +1057,7 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 		glog.Errorf("Failed to create FSS Authentication credential file for %v, error %v", agreementId, err)
 	}
 
-	servicePairs, err := b.finalizeDeployment(agreementId, deployment, environmentAdditions, workloadRWStorageDir, b.Config.Edge.DefaultCPUSet, b.Config.GetFileSyncServiceAPIUnixDomainSocketPath())
+	servicePairs, err := b.finalizeDeployment(agreementId, deployment, environmentAdditions, workloadRWStorageDir, b.Config.Edge.DefaultCPUSet, limits, b.Config.GetFileSyncServiceAPIUnixDomainSocketPath())
 	if err != nil {
 		return nil, err
 	}
@@ -1050,10 +1072,15 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 	}
 
 	for serviceName, servicePair := range servicePairs {
-		if image, err := b.client.InspectImage(servicePair.serviceConfig.Config.Image); err != nil {
+		if image, cached, err := b.inspectServiceImage(servicePair.serviceConfig.Config.Image); err != nil {
 			return nil, fail(nil, serviceName, fmt.Errorf("Failed to inspect image: %v. Original error: %v", servicePair.serviceConfig.Config.Image, err))
 		} else if image == nil {
 			return nil, fail(nil, serviceName, fmt.Errorf("Unable to find Docker image: %v", servicePair.serviceConfig.Config.Image))
+		} else if err := verifyImageDigest(b.Config.Edge.DeploymentSecurity, servicePair.serviceConfig.Config.Image, image, torrent.CachedImageId(b.Config.GetImageCacheDir(), servicePair.serviceConfig.Config.Image)); err != nil {
+			return nil, fail(nil, serviceName, err)
+		} else if cached {
+			glog.V(3).Infof("Running service %v from image %v, loaded from the image cache for %v", serviceName, image.ID, servicePair.serviceConfig.Config.Image)
+			servicePair.serviceConfig.Config.Image = image.ID
 		}
 
 		// need to examine original deploymentDescription to determine which containers are "shared" or in other special patterns
@@ -1110,13 +1137,13 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 		var existingNetwork *docker.Network
 		var existingContainer *docker.APIContainers
 
-		existingNetwork, existingContainer, err = existingShared(b.client, serviceName, &servicePair, bridgeName, shareLabel)
+		existingNetwork, existingContainer, err = existingShared(b.runtime, serviceName, &servicePair, bridgeName, shareLabel)
 		if err != nil {
 			return nil, fail(nil, containerName, fmt.Errorf("Failed to discover and use existing shared containers. Original error: %v", err))
 		}
 
 		if existingNetwork == nil {
-			existingNetwork, err = mkBridge(b.client, bridgeName, deployment.Infrastructure, true)
+			existingNetwork, err = mkBridge(b.runtime, bridgeName, deployment.Infrastructure, true)
 			glog.V(2).Infof("Created new network for shared container: %v. Network: %v", containerName, existingNetwork)
 			if err != nil {
 				return nil, fail(nil, containerName, fmt.Errorf("Unable to create bridge for shared container. Original error: %v", err))
@@ -1132,7 +1159,7 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 		if existingContainer == nil {
 			// only create container if there wasn't one
 			servicePair.serviceConfig.HostConfig.NetworkMode = bridgeName
-			if err := serviceStart(b.client, agreementId, containerName, shareLabel, servicePair.serviceConfig, eps, ms_sharedendpoints, &postCreateContainers, fail, true); err != nil {
+			if err := serviceStart(b.runtime, agreementId, containerName, shareLabel, servicePair.serviceConfig, eps, ms_sharedendpoints, &postCreateContainers, fail, true); err != nil {
 				return nil, err
 			}
 		} else {
@@ -1145,7 +1172,7 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 
 	// If the network we want already exists, just use it.
 	var agBridge *docker.Network
-	if networks, err := b.client.ListNetworks(); err != nil {
+	if networks, err := b.runtime.ListNetworks(); err != nil {
 		glog.Errorf("Unable to list networks: %v", err)
 		return nil, err
 	} else {
@@ -1157,7 +1184,7 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 			}
 		}
 		if agBridge == nil {
-			newBridge, err := mkBridge(b.client, agreementId, deployment.Infrastructure, false)
+			newBridge, err := mkBridge(b.runtime, agreementId, deployment.Infrastructure, false)
 			if err != nil {
 				return nil, err
 			}
@@ -1165,6 +1192,11 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 		}
 	}
 
+	// The workload containers are on the agreement bridge, so limiting it limits the workload's network bandwidth.
+	if err := shapeBridge(b.shaper, agBridge, limits); err != nil {
+		return nil, fail(nil, agreementId, err)
+	}
+
 	// add ms endpoints to the sharedEndpoints
 	if ms_sharedendpoints != nil {
 		recordEndpoints(sharedEndpoints, ms_sharedendpoints)
@@ -1173,7 +1205,7 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 	// every one of these gets wired to both the agBridge and every shared bridge from this agreement
 	for serviceName, servicePair := range private {
 		servicePair.serviceConfig.HostConfig.NetworkMode = agreementId // custom bridge has agreementId as name, same as endpoint key
-		if err := serviceStart(b.client, agreementId, serviceName, "", servicePair.serviceConfig, mkEndpoints(agBridge, serviceName), sharedEndpoints, &postCreateContainers, fail, true); err != nil {
+		if err := serviceStart(b.runtime, agreementId, serviceName, "", servicePair.serviceConfig, mkEndpoints(agBridge, serviceName), sharedEndpoints, &postCreateContainers, fail, true); err != nil {
 			if err != docker.ErrContainerAlreadyExists {
 				return nil, err
 			}
@@ -1183,7 +1215,7 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 	// check environmentAdditions for MTN_ETHEREUM_ACCOUNT
 	_, hasSpecifiedEthAccount := environmentAdditions[config.ENVVAR_PREFIX+"ETHEREUM_ACCOUNT"]
 
-	if err := processPostCreate(b.iptables, b.client, agreementId, *deployment, configureRaw, hasSpecifiedEthAccount, postCreateContainers, fail); err != nil {
+	if err := processPostCreate(b.iptables, b.runtime, agreementId, *deployment, configureRaw, hasSpecifiedEthAccount, postCreateContainers, fail); err != nil {
 		return nil, err
 	}
 
@@ -1195,6 +1227,12 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 
 func (b *ContainerWorker) Initialize() bool {
 	b.syncupResources()
+
+	// Fire up the image GC, the first scheduled prune is one interval from now.
+	if !b.Config.Edge.ImageGC.Disabled && b.Config.Edge.ImageGC.CheckIntervalS > 0 {
+		b.lastImageGC = time.Now()
+		b.DispatchSubworker(IMAGE_GC, b.imageGC, b.Config.Edge.ImageGC.CheckIntervalS)
+	}
 	return true
 }
 
@@ -1254,6 +1292,16 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 				b.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementLaunchContext.AgreementProtocol, agreementId, nil)
 			}
 
+			// The node owner can refuse to run containers with more privileges than they want to allow.
+			if err := checkDeploymentSecurity(b.Config.Edge.DeploymentSecurity, deploymentDesc); err != nil {
+				eventlog.LogAgreementEvent(b.db, persistence.SEVERITY_ERROR,
+					fmt.Sprintf("Deployment config for agreement %v is denied by the node policy: %v", agreementId, err),
+					persistence.EC_DEPLOYMENT_DENIED_BY_NODE, ags[0])
+				glog.Errorf("Deployment config for agreement %v is denied by the node policy: %v", agreementId, err)
+				b.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementLaunchContext.AgreementProtocol, agreementId, nil)
+				return true
+			}
+
 			// Add the deployment overrides to the deployment description, if there are any
 			if len(cmd.AgreementLaunchContext.Configure.Overrides) != 0 {
 				overrideDD := new(containermessage.DeploymentDescription)
@@ -1283,16 +1331,29 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			// authenticate a service to an API that is hosted by Anax.
 			serviceIdentity := cutil.FormOrgSpecUrl(cutil.NormalizeURL(ags[0].RunningWorkload.URL), ags[0].RunningWorkload.Org)
 
-			// Create the docker configuration and launch the containers.
-			if deploymentConfig, err := b.ResourcesCreate(agreementId, cmd.AgreementLaunchContext.AgreementProtocol, &cmd.AgreementLaunchContext.Configure, deploymentDesc, cmd.AgreementLaunchContext.ConfigureRaw, *cmd.AgreementLaunchContext.EnvironmentAdditions, ms_children_networks, serviceIdentity); err != nil {
+			// Create the docker configuration and launch the containers. The containers are not allowed to use more than
+			// the resource limits in the agreement.
+			if limits, err := agreedResourceLimits(&ags[0]); err != nil {
 				eventlog.LogAgreementEvent(b.db, persistence.SEVERITY_ERROR, fmt.Sprintf("Error starting containers: %v", err), persistence.EC_ERROR_START_CONTAINER, ags[0])
 				glog.Errorf("Error starting containers: %v", err)
+				containerStartFailures.Inc("workload")
+				b.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementLaunchContext.AgreementProtocol, agreementId, nil)
+
+			} else if deploymentConfig, err := b.ResourcesCreate(agreementId, cmd.AgreementLaunchContext.AgreementProtocol, &cmd.AgreementLaunchContext.Configure, deploymentDesc, cmd.AgreementLaunchContext.ConfigureRaw, *cmd.AgreementLaunchContext.EnvironmentAdditions, ms_children_networks, serviceIdentity, limits); isImageVerificationError(err) {
+				eventlog.LogAgreementEvent(b.db, persistence.SEVERITY_ERROR, fmt.Sprintf("Image verification failed for agreement %v: %v", agreementId, err), persistence.EC_IMAGE_DIGEST_MISMATCH, ags[0])
+				glog.Errorf("Image verification failed for agreement %v: %v", agreementId, err)
+				containerStartFailures.Inc("workload")
+				b.Messages() <- events.NewWorkloadMessage(events.IMAGE_NOT_VERIFIED, cmd.AgreementLaunchContext.AgreementProtocol, agreementId, deploymentConfig)
+
+			} else if err != nil {
+				eventlog.LogAgreementEvent(b.db, persistence.SEVERITY_ERROR, fmt.Sprintf("Error starting containers: %v", err), persistence.EC_ERROR_START_CONTAINER, ags[0])
+				glog.Errorf("Error starting containers: %v", err)
+				containerStartFailures.Inc("workload")
 				b.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementLaunchContext.AgreementProtocol, agreementId, deploymentConfig) // still using deployment here, need it to shutdown containers
 
 			} else {
 				glog.Infof("Success starting pattern for agreement: %v, protocol: %v, serviceNames: %v", agreementId, cmd.AgreementLaunchContext.AgreementProtocol, deploymentConfig.ToString())
 
-				// perhaps add the tc info to the container message so it can be enforced
 				b.Messages() <- events.NewWorkloadMessage(events.EXECUTION_BEGUN, cmd.AgreementLaunchContext.AgreementProtocol, agreementId, deploymentConfig)
 			}
 		}
@@ -1372,6 +1433,20 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			return true
 		}
 
+		// The node owner can refuse to run containers with more privileges than they want to allow. The blockchain
+		// client containers are part of the agent, so they are not checked.
+		if lc.Blockchain.Name == "" {
+			if err := checkDeploymentSecurity(b.Config.Edge.DeploymentSecurity, deploymentDesc); err != nil {
+				eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_ERROR,
+					fmt.Sprintf("Deployment config for service %v is denied by the node policy: %v", lc.ServicePathElement.URL, err),
+					persistence.EC_DEPLOYMENT_DENIED_BY_NODE,
+					"", lc.ServicePathElement.URL, "", lc.ServicePathElement.Version, "", lc.AgreementIds)
+				glog.Errorf("Deployment config for service %v is denied by the node policy: %v", lc.ServicePathElement.URL, err)
+				b.Messages() <- events.NewContainerMessage(events.EXECUTION_FAILED, *cmd.ContainerLaunchContext, "", "")
+				return true
+			}
+		}
+
 		serviceNames := deploymentDesc.ServiceNames()
 
 		for serviceName, service := range deploymentDesc.Services {
@@ -1413,8 +1488,34 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 		// authenticate a service to an API that is hosted by Anax.
 		serviceIdentity := cutil.FormOrgSpecUrl(cutil.NormalizeURL(lc.ServicePathElement.URL), lc.ServicePathElement.Org)
 
+		// A dependent service is not allowed to use more than the resource limits in the agreements it is started for. The
+		// blockchain client containers are part of the agent, so they are not limited.
+		var limits *policy.ResourceLimit
+		if lc.Blockchain.Name == "" {
+			if agLimits, err := b.dependentServiceLimits(lc.AgreementIds); err != nil {
+				eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_ERROR,
+					fmt.Sprintf("Error starting containers for service %v: %v", lc.ServicePathElement.URL, err),
+					persistence.EC_ERROR_START_CONTAINER,
+					"", lc.ServicePathElement.URL, "", lc.ServicePathElement.Version, "", lc.AgreementIds)
+				glog.Errorf("Error starting containers for service %v: %v", lc.ServicePathElement.URL, err)
+				containerStartFailures.Inc("service")
+				b.Messages() <- events.NewContainerMessage(events.EXECUTION_FAILED, *cmd.ContainerLaunchContext, "", "")
+				return true
+			} else {
+				limits = agLimits
+			}
+		}
+
 		// Get the container started.
-		if deployment, err := b.ResourcesCreate(lc.Name, "", &lc.Configure, deploymentDesc, []byte(""), *lc.EnvironmentAdditions, ms_children_networks, serviceIdentity); err != nil {
+		if deployment, err := b.ResourcesCreate(lc.Name, "", &lc.Configure, deploymentDesc, []byte(""), *lc.EnvironmentAdditions, ms_children_networks, serviceIdentity, limits); isImageVerificationError(err) {
+			eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_ERROR,
+				fmt.Sprintf("Image verification failed for service %v: %v", lc.ServicePathElement.URL, err), persistence.EC_IMAGE_DIGEST_MISMATCH, "",
+				lc.ServicePathElement.URL, "", lc.ServicePathElement.Version, "", lc.AgreementIds)
+			glog.Errorf("Image verification failed for service %v: %v", lc.ServicePathElement.URL, err)
+			containerStartFailures.Inc("service")
+			b.Messages() <- events.NewContainerMessage(events.IMAGE_NOT_VERIFIED, *cmd.ContainerLaunchContext, "", "")
+
+		} else if err != nil {
 			log_str := fmt.Sprintf("Error starting containers for agreement %v: %v", lc.AgreementIds, err)
 			if lc.IsRetry {
 				log_str = fmt.Sprintf("Error restarting containers for agreements %v: %v", lc.AgreementIds, err)
@@ -1424,6 +1525,7 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 				log_str, persistence.EC_ERROR_START_CONTAINER, "",
 				lc.ServicePathElement.URL, "", lc.ServicePathElement.Version, "", lc.AgreementIds)
 			glog.Errorf("Error starting containers: %v", err)
+			containerStartFailures.Inc("service")
 			b.Messages() <- events.NewContainerMessage(events.EXECUTION_FAILED, *cmd.ContainerLaunchContext, "", "")
 
 		} else {
@@ -1473,8 +1575,12 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 
 				for _, name := range serviceNames {
 					if container.Labels[LABEL_PREFIX+".service_name"] == name && container.State == "running" {
-						cMatches = append(cMatches, *container)
-						glog.V(4).Infof("Matching container instance for agreement %v: %v", agreementId, container)
+						if ContainerHealth(container) == HEALTH_UNHEALTHY {
+							glog.Errorf("Container %v for agreement %v is unhealthy.", container.Names, agreementId)
+						} else {
+							cMatches = append(cMatches, *container)
+							glog.V(4).Infof("Matching container instance for agreement %v: %v", agreementId, container)
+						}
 					}
 				}
 				return nil
@@ -1547,6 +1653,8 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 					if container.Labels[LABEL_PREFIX+".service_name"] == name {
 						if container.State != "running" {
 							glog.Errorf("Service container for %v is not in the running state.", instance_key)
+						} else if ContainerHealth(container) == HEALTH_UNHEALTHY {
+							glog.Errorf("Service container %v for %v is unhealthy.", container.Names, instance_key)
 						} else {
 							cMatches = append(cMatches, *container)
 							glog.V(4).Infof("Matching container instance for service instance %v: %v", instance_key, container)
@@ -1590,6 +1698,7 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 		if err := b.GetAuthenticationManager().RemoveAll(); err != nil {
 			glog.Errorf("Error handling node unconfig command: %v", err)
 		}
+		b.TerminateSubworkers()
 		b.Commands <- worker.NewTerminateCommand("shutdown")
 
 	default:
@@ -1654,7 +1763,7 @@ func (b *ContainerWorker) syncupResources() {
 
 		// Second, run through each container (active or inactive) looking for containers that are leftover from old agreements. Be aware that there
 		// could be other non-Horizon containers on this host, so we have to be careful to NOT terminate them.
-		if containers, err := b.client.ListContainers(docker.ListContainersOptions{All: true}); err != nil {
+		if containers, err := b.runtime.ListContainers(true, nil); err != nil {
 			fail(fmt.Sprintf("ContainerWorker unable to get list of containers: %v", err))
 		} else {
 
@@ -1679,7 +1788,7 @@ func (b *ContainerWorker) syncupResources() {
 
 		// Third, run through each network looking for networks that are leftover from old agreements. Be aware that there
 		// could be other non-Horizon networks on this host, so we have to be careful to NOT terminate them.
-		if networks, err := b.client.ListNetworks(); err != nil {
+		if networks, err := b.runtime.ListNetworks(); err != nil {
 			fail(fmt.Sprintf("ContainerWorker unable to get list of networks: %v", err))
 		} else {
 			for _, net := range networks {
@@ -1688,11 +1797,11 @@ func (b *ContainerWorker) syncupResources() {
 					continue
 				} else if val, exists := net.Labels[LABEL_PREFIX+".service_pattern.shared"]; exists && val == "singleton" {
 
-					if netInfo, err := b.client.NetworkInfo(net.ID); err != nil {
+					if netInfo, err := b.runtime.NetworkInfo(net.ID); err != nil {
 						glog.Errorf("Failure getting network info for %v. Error: %v", net.Name, err)
 					} else if len(netInfo.Containers) != 0 {
 						glog.V(3).Infof("Shared network %v has containers %v, so leave it alone", net.Name, netInfo.Containers)
-					} else if err := b.client.RemoveNetwork(net.ID); err != nil {
+					} else if err := b.runtime.RemoveNetwork(net.ID); err != nil {
 						glog.Errorf("Failure removing network: %v. Error: %v", net, err)
 					} else {
 						glog.Infof("Succeeded removing unused shared network: %v", net)
@@ -1746,7 +1855,7 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 	glog.V(5).Infof("Killing and removing resources in agreements: %v", agreements)
 
 	// Remove networks
-	networks, err := b.client.ListNetworks()
+	networks, err := b.runtime.ListNetworks()
 	if err != nil {
 		return fmt.Errorf("Unable to list networks: %v", err)
 	}
@@ -1776,7 +1885,7 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 
 			if sharedNet.ID == "" {
 				glog.Warningf("Did not find existing network for shared container: %v", container)
-			} else if netInfo, err := b.client.NetworkInfo(sharedNet.ID); err != nil {
+			} else if netInfo, err := b.runtime.NetworkInfo(sharedNet.ID); err != nil {
 				glog.Errorf("Failure getting network info for %v. Error: %v", sharedNet, err)
 			} else {
 
@@ -1785,7 +1894,7 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 				for conId, _ := range netInfo.Containers {
 					// do container lookup
 
-					allContainers, err := b.client.ListContainers(docker.ListContainersOptions{})
+					allContainers, err := b.runtime.ListContainers(false, nil)
 					if err != nil {
 						return err
 					}
@@ -1822,7 +1931,7 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 
 		serviceName := container.Labels[LABEL_PREFIX+".service_name"]
 		// if we made it this far, we're hosing the container
-		if destroyed, err := serviceDestroy(b.client, agreementId, container.ID); err != nil {
+		if destroyed, err := serviceDestroy(b.runtime, agreementId, container.ID); err != nil {
 			glog.Errorf("Service %v in agreement %v could not be removed. Error: %v", serviceName, agreementId, err)
 		} else if destroyed {
 			glog.V(1).Infof("Service %v in agreement %v stopped and removed", serviceName, agreementId)
@@ -1848,7 +1957,7 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 			}
 		} else {
 			// remove the docker volume
-			if err := b.client.RemoveVolume(workloadRWStorageDir); err != nil {
+			if err := b.runtime.RemoveVolume(workloadRWStorageDir); err != nil {
 				if err != docker.ErrNoSuchVolume {
 					glog.Errorf("Failed to remove workloadStorageDir docker volume: %v. Error: %v", workloadRWStorageDir, err)
 				}
@@ -1867,16 +1976,12 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 		for _, agreementId := range agreements {
 			if net.Name == agreementId {
 				// disconnect the network from the containers if they are still connected to it.
-				if netInfo, err := b.client.NetworkInfo(net.ID); err != nil {
+				if netInfo, err := b.runtime.NetworkInfo(net.ID); err != nil {
 					glog.Errorf("Failure getting network info for %v. Error: %v", net.Name, err)
 				} else {
 					for conID, container := range netInfo.Containers {
 						glog.V(5).Infof("Disconnecting network %v from container %v.", netInfo.Name, container.Name)
-						err := b.client.DisconnectNetwork(netInfo.ID, docker.NetworkConnectionOptions{
-							Container:      conID,
-							EndpointConfig: nil,
-							Force:          true,
-						})
+						err := b.runtime.DisconnectNetwork(netInfo.ID, conID, true)
 						if err != nil {
 							glog.Errorf("Failure disconnecting network: %v from container %v. Error: %v", netInfo.Name, container.Name, err)
 						} else {
@@ -1894,7 +1999,7 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 
 	// free networks
 	for _, net := range freeNets {
-		if err := b.client.RemoveNetwork(net.ID); err != nil {
+		if err := b.runtime.RemoveNetwork(net.ID); err != nil {
 			glog.Errorf("Failure removing network: %v. Error: %v", net.ID, err)
 		} else {
 			glog.V(1).Infof("Succeeded removing unused network: %v", net.ID)
@@ -1935,10 +2040,14 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 }
 
 func (b *ContainerWorker) ContainersMatchingAgreement(agreements []string, includeShared bool, fn func(*docker.APIContainers, string) error) error {
+	return matchAgreementContainers(b.runtime, agreements, includeShared, fn)
+}
+
+func matchAgreementContainers(rt containerruntime.Runtime, agreements []string, includeShared bool, fn func(*docker.APIContainers, string) error) error {
 	var processingErr error
 
 	// get all containers including the inactive ones.
-	containers, err := b.client.ListContainers(docker.ListContainersOptions{All: true})
+	containers, err := rt.ListContainers(true, nil)
 	if err != nil {
 		glog.Errorf("Unable to get list of running containers: %v", err)
 	} else {
@@ -1962,6 +2071,206 @@ func (b *ContainerWorker) ContainersMatchingAgreement(agreements []string, inclu
 	return processingErr
 }
 
+// The CPU period that docker uses when the service does not set one.
+const DEFAULT_CPU_PERIOD = 100000
+
+// Set the resources that a service container can use. Memory is in MB. The container never gets more memory than the
+// RAM given to the node, or more memory or CPUs than the resource limits in the agreement, whatever the service asks
+// for. A limit of 0 means there is no limit.
+func setResources(serviceConfig *persistence.ServiceConfig, res *containermessage.Resources, limits *policy.ResourceLimit, ramMB int64) error {
+
+	if res == nil {
+		res = new(containermessage.Resources)
+	} else if err := res.Validate(); err != nil {
+		return err
+	}
+
+	// The smaller of 2 limits, where 0 is no limit.
+	bound := func(a, b int64) int64 {
+		if a == 0 || (b != 0 && b < a) {
+			return b
+		}
+		return a
+	}
+
+	memoryMB := ramMB
+	if limits != nil {
+		memoryMB = bound(memoryMB, int64(limits.Memory))
+	}
+	if res.Memory != 0 && memoryMB != 0 && res.Memory > memoryMB {
+		glog.Warningf("Service memory %vMB is more than the %vMB allowed, using %vMB", res.Memory, memoryMB, memoryMB)
+	}
+	memoryMB = bound(memoryMB, res.Memory)
+	serviceConfig.HostConfig.Memory = memoryMB * 1024 * 1024
+
+	if res.MemoryReservation != 0 {
+		serviceConfig.HostConfig.MemoryReservation = bound(res.MemoryReservation, memoryMB) * 1024 * 1024
+	}
+
+	// The CPUs in the agreement are converted to a quota of CPU time in each period.
+	quota := res.CPUQuota
+	if limits != nil && limits.CPUs != 0 {
+		period := res.CPUPeriod
+		if period == 0 {
+			period = DEFAULT_CPU_PERIOD
+		}
+		if quota != 0 && quota > int64(limits.CPUs)*period {
+			glog.Warningf("Service cpu_quota %v is more than the %v CPUs allowed, using %v", quota, limits.CPUs, int64(limits.CPUs)*period)
+		}
+		quota = bound(quota, int64(limits.CPUs)*period)
+	}
+	if quota != 0 {
+		serviceConfig.HostConfig.CPUQuota = quota
+		serviceConfig.HostConfig.CPUPeriod = res.CPUPeriod
+	}
+
+	serviceConfig.HostConfig.CPUShares = res.CPUShares
+	serviceConfig.HostConfig.PidsLimit = res.PidsLimit
+	serviceConfig.HostConfig.ShmSize = res.ShmSize * 1024 * 1024
+
+	if len(res.Ulimits) != 0 {
+		serviceConfig.HostConfig.Ulimits = res.DockerUlimits()
+	}
+
+	// The service's cpuset replaces the node's default.
+	if res.CPUSet != "" {
+		serviceConfig.Config.CPUSet = res.CPUSet
+		serviceConfig.HostConfig.CPUSetCPUs = res.CPUSet
+	}
+
+	return nil
+}
+
+// Check the services in a deployment against the node owner's rules for the containers that can run on the node.
+func checkDeploymentSecurity(cfg config.DeploymentSecurityConfig, deployment *containermessage.DeploymentDescription) error {
+	for serviceName, service := range deployment.Services {
+		if reason := cfg.Violation(service.Privileged, service.CapAdd); reason != "" {
+			return fmt.Errorf("service %v: %v", serviceName, reason)
+		}
+	}
+	return nil
+}
+
+// The image of a service is not the one its deployment is pinned to, or is not pinned when the node requires it.
+type ImageVerificationError struct {
+	Msg string
+}
+
+func (e ImageVerificationError) Error() string {
+	return e.Msg
+}
+
+func isImageVerificationError(err error) bool {
+	_, ok := err.(ImageVerificationError)
+	return ok
+}
+
+// Check that the local image that a service will run from is the one its deployment refers to. A reference with a
+// digest must match one of the image's repo digests, a tag can be moved to another image so a reference without a
+// digest is only allowed when the node does not require digests. An image loaded from the local image cache has no
+// repo digests, the cache checked the digest against the archive instead, so the image is accepted when it is the
+// cached image, cachedImageId, that has the digest.
+func verifyImageDigest(cfg config.DeploymentSecurityConfig, ref string, image *docker.Image, cachedImageId string) error {
+	_, _, _, digest := cutil.ParseDockerImagePath(ref)
+	if digest == "" {
+		if cfg.RequireDigests {
+			return ImageVerificationError{fmt.Sprintf("image %v is not referenced by digest, this node only runs images that are", ref)}
+		}
+		return nil
+	}
+	if cachedImageId != "" && image.ID == cachedImageId {
+		return nil
+	}
+	for _, repoDigest := range image.RepoDigests {
+		if strings.HasSuffix(repoDigest, "@"+digest) {
+			return nil
+		}
+	}
+	return ImageVerificationError{fmt.Sprintf("image %v (%v) has digests %v, none of them is %v", ref, image.ID, image.RepoDigests, digest)}
+}
+
+// Find the local image of a service. Docker does not know the digest of an image that was loaded from the local image
+// cache, so an image that is pinned by digest is also looked up by the id of the image that the cache has for the digest.
+// Returns true when the image was found that way, the service's containers then have to be created from the image id.
+func (b *ContainerWorker) inspectServiceImage(ref string) (*docker.Image, bool, error) {
+	image, err := b.runtime.InspectImage(ref)
+	if err == nil && image != nil {
+		return image, false, nil
+	}
+	if id := torrent.CachedImageId(b.Config.GetImageCacheDir(), ref); id != "" {
+		if cachedImage, cerr := b.runtime.InspectImage(id); cerr == nil && cachedImage != nil && cachedImage.ID == id {
+			return cachedImage, true, nil
+		}
+	}
+	return image, false, err
+}
+
+// Get the resource limits in the terms and conditions of an agreement.
+func agreedResourceLimits(ag *persistence.EstablishedAgreement) (*policy.ResourceLimit, error) {
+	if proposal, err := abstractprotocol.DemarshalProposal(ag.Proposal); err != nil {
+		return nil, fmt.Errorf("unable to demarshal proposal for agreement %v, error %v", ag.CurrentAgreementId, err)
+	} else if tcPolicy, err := policy.DemarshalPolicy(proposal.TsAndCs()); err != nil {
+		return nil, fmt.Errorf("unable to demarshal TsAndCs policy for agreement %v, error %v", ag.CurrentAgreementId, err)
+	} else {
+		return &tcPolicy.ResourceLimits, nil
+	}
+}
+
+// Get the resource limits for a dependent service from the agreements it is started for. A service that is shared by
+// several agreements gets the smallest of each of their limits, so that it stays within the terms of all of them. A
+// service that is not started for an agreement is not limited.
+func (b *ContainerWorker) dependentServiceLimits(agreementIds []string) (*policy.ResourceLimit, error) {
+	allLimits := make([]*policy.ResourceLimit, 0, len(agreementIds))
+	for _, agreementId := range agreementIds {
+		if ags, err := persistence.FindEstablishedAgreementsAllProtocols(b.db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter(), persistence.IdEAFilter(agreementId)}); err != nil {
+			return nil, fmt.Errorf("unable to retrieve agreement %v from database, error %v", agreementId, err)
+		} else if len(ags) != 1 {
+			glog.Warningf("Unable to find agreement %v to get the resource limits of its dependent services.", agreementId)
+		} else if limits, err := agreedResourceLimits(&ags[0]); err != nil {
+			return nil, err
+		} else {
+			allLimits = append(allLimits, limits)
+		}
+	}
+	return smallestResourceLimits(allLimits), nil
+}
+
+// Returns the smallest of each of the resource limits, where 0 is no limit, or nil if there are no limits.
+func smallestResourceLimits(allLimits []*policy.ResourceLimit) *policy.ResourceLimit {
+	if len(allLimits) == 0 {
+		return nil
+	}
+
+	smallest := func(a int, b int) int {
+		if a == 0 || (b != 0 && b < a) {
+			return b
+		}
+		return a
+	}
+
+	result := *allLimits[0]
+	for _, limits := range allLimits[1:] {
+		result.NetworkUpload = smallest(result.NetworkUpload, limits.NetworkUpload)
+		result.NetworkDownload = smallest(result.NetworkDownload, limits.NetworkDownload)
+		result.Memory = smallest(result.Memory, limits.Memory)
+		result.CPUs = smallest(result.CPUs, limits.CPUs)
+	}
+	return &result
+}
+
+// Returns the health of the container from its status, e.g. "Up 5 minutes (unhealthy)", or an empty string if the
+// container does not have a health check.
+func ContainerHealth(container *docker.APIContainers) string {
+	if strings.Contains(container.Status, "(health: starting)") {
+		return HEALTH_STARTING
+	} else if strings.Contains(container.Status, "(unhealthy)") {
+		return HEALTH_UNHEALTHY
+	} else if strings.Contains(container.Status, "(healthy)") {
+		return HEALTH_HEALTHY
+	}
+	return ""
+}
+
 // find the microservice definition from the db
 func (b *ContainerWorker) findMicroserviceDefContainerNames(api_spec string, org string, version string, msdef_key string) ([]string, error) {
 
@@ -2003,7 +2312,7 @@ func (b *ContainerWorker) findMicroserviceDefContainerNames(api_spec string, org
 // It only includes the containers with "running" state.
 func (b *ContainerWorker) findDependencyContainersForService(parent *persistence.ServiceInstancePathElement, agreementIds []string, microservices []events.MicroserviceSpec) ([]docker.APIContainers, error) {
 	ms_containers := make([]docker.APIContainers, 0)
-	if containers, err := b.client.ListContainers(docker.ListContainersOptions{}); err != nil {
+	if containers, err := b.runtime.ListContainers(false, nil); err != nil {
 		return nil, fmt.Errorf("Unable to get list of running containers: %v", err)
 	} else {
 		for _, api_spec := range microservices {
@@ -2110,7 +2419,7 @@ func (b *ContainerWorker) findParentContainersForService(msinst_key string) ([]d
 		}
 	}
 
-	if containers, err := b.client.ListContainers(docker.ListContainersOptions{}); err != nil {
+	if containers, err := b.runtime.ListContainers(false, nil); err != nil {
 		return nil, fmt.Errorf("Unable to get list of running containers: %v", err)
 	} else {
 		for _, api_spec := range parents {
@@ -2204,7 +2513,7 @@ func (b *ContainerWorker) connectContainers(netname string, containers []docker.
 	//find the network
 	var net_to_connect docker.Network
 	found := false
-	if networks, err := b.client.ListNetworks(); err != nil {
+	if networks, err := b.runtime.ListNetworks(); err != nil {
 		return fmt.Errorf("Unable to get list of docker networks: %v", err)
 	} else {
 		for _, net := range networks {
@@ -2226,11 +2535,7 @@ func (b *ContainerWorker) connectContainers(netname string, containers []docker.
 			Links:     nil,
 			NetworkID: net_to_connect.ID,
 		}
-		err := b.client.ConnectNetwork(net_to_connect.ID, docker.NetworkConnectionOptions{
-			Container:      container.ID,
-			EndpointConfig: &epc,
-			Force:          true,
-		})
+		err := b.runtime.ConnectNetwork(net_to_connect.ID, container.ID, &epc)
 		if err != nil {
 			return fmt.Errorf("Failed to connect container %v to network %v. %v", container.ID, netname, err)
 		}
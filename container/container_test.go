@@ -3,10 +3,22 @@
 package container
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/torrent"
+	"io/ioutil"
 	"net/url"
+	"os"
+	"path"
+	"strings"
 	"testing"
 )
 
@@ -210,3 +222,241 @@ func Test_RemoveEnvVar_nothing3(t *testing.T) {
 	}
 
 }
+
+func Test_ContainerHealth(t *testing.T) {
+
+	for status, health := range map[string]string{
+		"Up 5 minutes":                    "",
+		"Up 5 seconds (health: starting)": HEALTH_STARTING,
+		"Up 5 minutes (healthy)":          HEALTH_HEALTHY,
+		"Up 10 minutes (unhealthy)":       HEALTH_UNHEALTHY,
+		"Exited (1) 2 minutes ago":        "",
+	} {
+		c := docker.APIContainers{State: "running", Status: status}
+		if h := ContainerHealth(&c); h != health {
+			t.Errorf("Health of container with status %v should be %v, but is %v\n", status, health, h)
+		}
+	}
+}
+
+func Test_setResources(t *testing.T) {
+
+	// Without resources or limits the container gets the node's RAM.
+	sc := &persistence.ServiceConfig{Config: docker.Config{CPUSet: "0-3"}}
+	if err := setResources(sc, nil, nil, 1024); err != nil {
+		t.Errorf("setResources should not have returned an error: %v", err)
+	} else if sc.HostConfig.Memory != 1024*1024*1024 || sc.HostConfig.CPUQuota != 0 || sc.Config.CPUSet != "0-3" {
+		t.Errorf("Resources are not correct: %v", sc.HostConfig)
+	}
+
+	// The service's resources are used when they are within the agreed limits.
+	res := &containermessage.Resources{Memory: 256, MemoryReservation: 128, CPUQuota: 50000, CPUShares: 512, CPUSet: "1", PidsLimit: 100, ShmSize: 64,
+		Ulimits: []containermessage.Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}}}
+	sc = &persistence.ServiceConfig{Config: docker.Config{CPUSet: "0-3"}}
+	if err := setResources(sc, res, &policy.ResourceLimit{Memory: 512, CPUs: 1}, 1024); err != nil {
+		t.Errorf("setResources should not have returned an error: %v", err)
+	} else if hc := sc.HostConfig; hc.Memory != 256*1024*1024 || hc.MemoryReservation != 128*1024*1024 || hc.CPUQuota != 50000 || hc.CPUShares != 512 ||
+		hc.CPUSetCPUs != "1" || sc.Config.CPUSet != "1" || hc.PidsLimit != 100 || hc.ShmSize != 64*1024*1024 || len(hc.Ulimits) != 1 {
+		t.Errorf("Resources are not correct: %v", hc)
+	}
+
+	// The agreed limits are an upper bound on what the service asks for.
+	res = &containermessage.Resources{Memory: 2048, CPUQuota: 300000, CPUPeriod: 50000}
+	sc = &persistence.ServiceConfig{}
+	if err := setResources(sc, res, &policy.ResourceLimit{Memory: 512, CPUs: 2}, 1024); err != nil {
+		t.Errorf("setResources should not have returned an error: %v", err)
+	} else if sc.HostConfig.Memory != 512*1024*1024 || sc.HostConfig.CPUQuota != 100000 || sc.HostConfig.CPUPeriod != 50000 {
+		t.Errorf("Resources are not correct: %v", sc.HostConfig)
+	}
+
+	// The agreed CPUs are enforced even when the service does not ask for a quota.
+	sc = &persistence.ServiceConfig{}
+	if err := setResources(sc, nil, &policy.ResourceLimit{CPUs: 1}, 0); err != nil {
+		t.Errorf("setResources should not have returned an error: %v", err)
+	} else if sc.HostConfig.Memory != 0 || sc.HostConfig.CPUQuota != DEFAULT_CPU_PERIOD {
+		t.Errorf("Resources are not correct: %v", sc.HostConfig)
+	}
+
+	if err := setResources(&persistence.ServiceConfig{}, &containermessage.Resources{PidsLimit: -1}, nil, 1024); err == nil {
+		t.Errorf("setResources should have returned an error for a negative pids_limit.")
+	}
+}
+
+func Test_smallestResourceLimits(t *testing.T) {
+
+	if limits := smallestResourceLimits(nil); limits != nil {
+		t.Errorf("a service without agreements should not be limited: %v", limits)
+	}
+
+	// A service shared by several agreements gets the smallest of each limit, an agreement without a limit does not
+	// remove the limit of another.
+	limits := smallestResourceLimits([]*policy.ResourceLimit{
+		&policy.ResourceLimit{Memory: 512, CPUs: 2, NetworkUpload: 100},
+		&policy.ResourceLimit{Memory: 256, NetworkDownload: 200},
+		&policy.ResourceLimit{CPUs: 1},
+	})
+	if limits == nil || *limits != (policy.ResourceLimit{Memory: 256, CPUs: 1, NetworkUpload: 100, NetworkDownload: 200}) {
+		t.Errorf("wrong limits: %v", limits)
+	}
+}
+
+func Test_checkDeploymentSecurity(t *testing.T) {
+
+	dd := &containermessage.DeploymentDescription{
+		Services: map[string]*containermessage.Service{
+			"s1": &containermessage.Service{Image: "s1", CapAdd: []string{"NET_RAW"}},
+			"s2": &containermessage.Service{Image: "s2", CapDrop: []string{"ALL"}, ReadOnly: true},
+		},
+	}
+
+	if err := checkDeploymentSecurity(config.DeploymentSecurityConfig{DenyPrivileged: true, DenyCapabilities: []string{"SYS_ADMIN"}}, dd); err != nil {
+		t.Errorf("checkDeploymentSecurity should not have returned an error: %v", err)
+	}
+
+	dd.Services["s2"].Privileged = true
+	if err := checkDeploymentSecurity(config.DeploymentSecurityConfig{DenyPrivileged: true}, dd); err == nil || !strings.Contains(err.Error(), "s2") {
+		t.Errorf("checkDeploymentSecurity should have returned an error for s2, but returned %v", err)
+	} else if err := checkDeploymentSecurity(config.DeploymentSecurityConfig{DenyCapabilities: []string{"SYS_ADMIN"}}, dd); err == nil || !strings.Contains(err.Error(), "s2") {
+		t.Errorf("checkDeploymentSecurity should have returned an error for s2, privileged mode grants every capability, but returned %v", err)
+	}
+
+	dd.Services["s2"].Privileged = false
+	if err := checkDeploymentSecurity(config.DeploymentSecurityConfig{DenyCapabilities: []string{"NET_RAW"}}, dd); err == nil || !strings.Contains(err.Error(), "s1") {
+		t.Errorf("checkDeploymentSecurity should have returned an error for s1, but returned %v", err)
+	}
+}
+
+func Test_verifyImageDigest(t *testing.T) {
+
+	digest := "sha256:b5d7a7f9b2c1a8c6fbd8c8a6e0b1f0e4f9f3d3c2a1b0a9f8e7d6c5b4a3f2e1d0"
+	image := &docker.Image{ID: "sha256:1234", RepoDigests: []string{"mydomain.com/myorg/gps@" + digest}}
+
+	if err := verifyImageDigest(config.DeploymentSecurityConfig{}, "mydomain.com/myorg/gps:1.0.0@"+digest, image, ""); err != nil {
+		t.Errorf("verifyImageDigest should not have returned an error: %v", err)
+	} else if err := verifyImageDigest(config.DeploymentSecurityConfig{}, "mydomain.com/myorg/gps:1.0.0", image, ""); err != nil {
+		t.Errorf("verifyImageDigest should allow a tag when digests are not required: %v", err)
+	}
+
+	if err := verifyImageDigest(config.DeploymentSecurityConfig{RequireDigests: true}, "mydomain.com/myorg/gps:1.0.0", image, ""); !isImageVerificationError(err) {
+		t.Errorf("verifyImageDigest should have rejected a tag, but returned %v", err)
+	}
+
+	other := "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	if err := verifyImageDigest(config.DeploymentSecurityConfig{}, "mydomain.com/myorg/gps@"+other, image, ""); !isImageVerificationError(err) {
+		t.Errorf("verifyImageDigest should have rejected a different digest, but returned %v", err)
+	}
+
+	// An image loaded from the image cache has no repo digests, it is accepted when the cache has it for the digest.
+	loaded := &docker.Image{ID: "sha256:5678"}
+	if err := verifyImageDigest(config.DeploymentSecurityConfig{RequireDigests: true}, "mydomain.com/myorg/gps@"+digest, loaded, "sha256:5678"); err != nil {
+		t.Errorf("verifyImageDigest should have accepted the cached image: %v", err)
+	} else if err := verifyImageDigest(config.DeploymentSecurityConfig{}, "mydomain.com/myorg/gps@"+digest, loaded, "sha256:1234"); !isImageVerificationError(err) {
+		t.Errorf("verifyImageDigest should have rejected an image that is not the cached image, but returned %v", err)
+	} else if err := verifyImageDigest(config.DeploymentSecurityConfig{}, "mydomain.com/myorg/gps@"+digest, loaded, ""); !isImageVerificationError(err) {
+		t.Errorf("verifyImageDigest should have rejected an image without repo digests, but returned %v", err)
+	}
+}
+
+// Write an OCI layout with one image to a directory in the image cache. Returns the image id and manifest digest.
+func writeCachedOCIImage(t *testing.T, dir string) (string, string) {
+	digestOf := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	config := `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":"%v"},"layers":[]}`, digestOf(config))
+	index := fmt.Sprintf(`{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"%v"}]}`, digestOf(manifest))
+
+	for name, content := range map[string]string{
+		"oci-layout": `{"imageLayoutVersion":"1.0.0"}`,
+		"index.json": index,
+		"blobs/sha256/" + strings.TrimPrefix(digestOf(config), "sha256:"):   config,
+		"blobs/sha256/" + strings.TrimPrefix(digestOf(manifest), "sha256:"): manifest,
+	} {
+		file := path.Join(dir, "gps", name)
+		if err := os.MkdirAll(path.Dir(file), 0700); err != nil {
+			t.Fatalf("unable to create %v: %v", path.Dir(file), err)
+		} else if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatalf("unable to write %v: %v", file, err)
+		}
+	}
+	return digestOf(config), digestOf(manifest)
+}
+
+func Test_inspectServiceImage_cached(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "imagecache-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	imageId, manifestDigest := writeCachedOCIImage(t, dir)
+
+	// Docker has the image that was loaded from the cache under its id, but not under its digest.
+	rt := containerruntime.NewFakeRuntime()
+	rt.AddImage("gps-loaded", &docker.Image{ID: imageId})
+
+	cfg := &config.HorizonConfig{Edge: config.Config{ImageCache: config.ImageCacheConfig{Dir: dir}, DeploymentSecurity: config.DeploymentSecurityConfig{RequireDigests: true}}}
+	b := &ContainerWorker{runtime: rt}
+	b.Config = cfg
+
+	ref := "mydomain.com/myorg/gps@" + manifestDigest
+	if image, cached, err := b.inspectServiceImage(ref); err != nil || image == nil || !cached || image.ID != imageId {
+		t.Fatalf("inspectServiceImage should have found the cached image %v, found %v %v %v", imageId, image, cached, err)
+	} else if err := verifyImageDigest(cfg.Edge.DeploymentSecurity, ref, image, torrent.CachedImageId(cfg.GetImageCacheDir(), ref)); err != nil {
+		t.Errorf("verifyImageDigest should have accepted the cached image: %v", err)
+	}
+
+	// Without the cache, the image pinned by digest can't be found.
+	cfg.Edge.ImageCache.Disabled = true
+	if image, cached, err := b.inspectServiceImage(ref); err == nil || cached {
+		t.Errorf("inspectServiceImage should not have found %v without the image cache, found %v %v", ref, image, cached)
+	}
+}
+
+func Test_serviceStartDestroy(t *testing.T) {
+
+	rt := containerruntime.NewFakeRuntime()
+	rt.AddImage("myorg/gps:1.0.0", &docker.Image{ID: "sha256:1234"})
+
+	bridge, err := mkBridge(rt, "ag1", false, false)
+	if err != nil {
+		t.Fatalf("mkBridge returned an error: %v", err)
+	}
+
+	serviceConfig := &persistence.ServiceConfig{
+		Config: docker.Config{
+			Image:  "myorg/gps:1.0.0",
+			Labels: map[string]string{LABEL_PREFIX + ".agreement_id": "ag1", LABEL_PREFIX + ".service_name": "gps"},
+		},
+	}
+	eps := map[string]*docker.EndpointConfig{"ag1": &docker.EndpointConfig{Aliases: []string{"gps"}, NetworkID: bridge.ID}}
+	fail := func(container *docker.Container, name string, err error) error { return err }
+
+	postCreate := make([]interface{}, 0)
+	if err := serviceStart(rt, "ag1", "gps", "", serviceConfig, eps, nil, &postCreate, fail, true); err != nil {
+		t.Fatalf("serviceStart returned an error: %v", err)
+	} else if len(postCreate) != 1 {
+		t.Errorf("serviceStart should have created 1 container, created %v", len(postCreate))
+	} else if err := serviceStart(rt, "ag1", "gps", "", serviceConfig, eps, nil, &postCreate, fail, true); err != docker.ErrContainerAlreadyExists {
+		t.Errorf("serviceStart should have returned ErrContainerAlreadyExists, returned %v", err)
+	}
+
+	containers, err := FindServiceContainers(rt, []string{"ag1"}, "gps")
+	if err != nil {
+		t.Fatalf("FindServiceContainers returned an error: %v", err)
+	} else if len(containers) != 1 || containers[0].State != "running" {
+		t.Fatalf("FindServiceContainers should have found the running container, found %v", containers)
+	} else if _, ok := containers[0].Networks.Networks["ag1"]; !ok {
+		t.Errorf("the container should be on the agreement network: %v", containers[0].Networks)
+	}
+
+	if destroyed, err := serviceDestroy(rt, "ag1", containers[0].ID); !destroyed || err != nil {
+		t.Errorf("serviceDestroy should have destroyed the container: %v %v", destroyed, err)
+	} else if destroyed, err := serviceDestroy(rt, "ag1", containers[0].ID); destroyed || err != nil {
+		t.Errorf("serviceDestroy of a missing container should do nothing: %v %v", destroyed, err)
+	} else if err := rt.RemoveNetwork(bridge.ID); err != nil {
+		t.Errorf("the agreement network should have no containers left: %v", err)
+	}
+}
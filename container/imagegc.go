@@ -0,0 +1,321 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"golang.org/x/sys/unix"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The images that anax pulls are never removed by docker, so each upgrade of a service leaves the images of the old
+// version behind. The image GC removes them. An image belongs to a version of a service when the deployment of an
+// agreement, or of a dependent service definition, uses it. The GC works out which services and versions each image
+// belongs to from the deployments each time it runs, nothing is recorded on the images themselves, and it reports them
+// as "org/url version" labels. Images that no deployment uses are not anax's, so they are never touched. The images of the newest
+// versions of each service, and of any version that is in use, are kept, as are the images of existing containers.
+
+const IMAGE_GC = "ImageGC"
+
+// Only one prune runs at a time, the scheduled one or one requested through the API.
+var imageGCLock sync.Mutex
+
+// An image that was, or would be, removed.
+type PrunedImage struct {
+	Image  string   `json:"image"`           // the repo:tag or repo@digest that is removed
+	Labels []string `json:"labels"`          // the service versions the image belongs to, "org/url version"
+	Size   int64    `json:"size"`            // the bytes that are freed, 0 when the image has other names that are kept
+	Error  string   `json:"error,omitempty"` // why the image could not be removed
+}
+
+type ImagePruneResult struct {
+	DryRun          bool          `json:"dry_run"`
+	Reason          string        `json:"reason"`
+	DiskUsedPercent int           `json:"disk_used_percent"`
+	KeepVersions    int           `json:"keep_versions"`
+	Removed         []PrunedImage `json:"removed"`
+	ReclaimedBytes  int64         `json:"reclaimed_bytes"`
+}
+
+func (r ImagePruneResult) String() string {
+	return fmt.Sprintf("DryRun: %v, Reason: %v, DiskUsedPercent: %v, KeepVersions: %v, Removed: %v, ReclaimedBytes: %v",
+		r.DryRun, r.Reason, r.DiskUsedPercent, r.KeepVersions, r.Removed, r.ReclaimedBytes)
+}
+
+// The images used by one version of a service.
+type versionImages struct {
+	images map[string]bool
+	inUse  bool
+}
+
+// The images used by each version of each service, keyed by org/url and then by version.
+type imageInventory map[string]map[string]*versionImages
+
+func (inv imageInventory) add(service string, version string, images []string, inUse bool) {
+	if _, ok := inv[service]; !ok {
+		inv[service] = make(map[string]*versionImages)
+	}
+	vi, ok := inv[service][version]
+	if !ok {
+		vi = &versionImages{images: make(map[string]bool)}
+		inv[service][version] = vi
+	}
+	for _, image := range images {
+		vi.images[normalizeImageRef(image)] = true
+	}
+	vi.inUse = vi.inUse || inUse
+}
+
+// Build the inventory from the service definitions and agreements in the database, archived ones included, because
+// those are the versions that the node has run before.
+func buildImageInventory(db persistence.EdgeDatabase) (imageInventory, error) {
+	inv := make(imageInventory)
+
+	msdefs, err := persistence.FindMicroserviceDefs(db, []persistence.MSFilter{})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read service definitions, error %v", err))
+	}
+	for _, msdef := range msdefs {
+		deployment, _, _ := msdef.GetDeployment()
+		if deployment == "" {
+			continue
+		}
+		dd := new(containermessage.DeploymentDescription)
+		if err := json.Unmarshal([]byte(deployment), dd); err != nil {
+			glog.Warningf("Image GC skipping service definition %v, unable to demarshal deployment, error %v", msdef.Id, err)
+			continue
+		}
+		images := make([]string, 0, len(dd.Services))
+		for _, service := range dd.Services {
+			images = append(images, service.Image)
+		}
+		inv.add(fmt.Sprintf("%v/%v", msdef.Org, msdef.SpecRef), msdef.Version, images, !msdef.Archived)
+	}
+
+	ags, err := persistence.FindEstablishedAgreementsAllProtocols(db, policy.AllAgreementProtocols(), []persistence.EAFilter{})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read agreements, error %v", err))
+	}
+	for _, ag := range ags {
+		if ag.RunningWorkload.URL == "" || len(ag.CurrentDeployment) == 0 {
+			continue
+		}
+		images := make([]string, 0, len(ag.CurrentDeployment))
+		for _, sc := range ag.CurrentDeployment {
+			images = append(images, sc.Config.Image)
+		}
+		inUse := !ag.Archived && ag.AgreementTerminatedTime == 0
+		inv.add(fmt.Sprintf("%v/%v", ag.RunningWorkload.Org, ag.RunningWorkload.URL), ag.RunningWorkload.Version, images, inUse)
+	}
+
+	return inv, nil
+}
+
+// Returns the images that can be removed, with the labels of the versions they belong to. The newest keep versions of
+// each service are kept, and an image that any kept version uses is kept even when a superseded version uses it too.
+func (inv imageInventory) prunable(keep int) map[string][]string {
+	kept := make(map[string]bool)
+	superseded := make(map[string][]string)
+
+	for service, versions := range inv {
+		ordered := make([]string, 0, len(versions))
+		for version := range versions {
+			ordered = append(ordered, version)
+		}
+		sort.Sort(newestFirst(ordered))
+
+		for i, version := range ordered {
+			vi := versions[version]
+			for image := range vi.images {
+				if i < keep || vi.inUse {
+					kept[image] = true
+				} else {
+					superseded[image] = append(superseded[image], fmt.Sprintf("%v %v", service, version))
+				}
+			}
+		}
+	}
+
+	for image := range kept {
+		delete(superseded, image)
+	}
+	for _, labels := range superseded {
+		sort.Strings(labels)
+	}
+	return superseded
+}
+
+// Sorts versions from the newest to the oldest. Versions that can't be compared are ordered by their string.
+type newestFirst []string
+
+func (v newestFirst) Len() int      { return len(v) }
+func (v newestFirst) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v newestFirst) Less(i, j int) bool {
+	if c, err := policy.CompareVersions(v[i], v[j]); err == nil {
+		return c > 0
+	}
+	return v[i] > v[j]
+}
+
+// Docker names images from docker hub without the registry, and an image without a tag is the latest one.
+func normalizeImageRef(ref string) string {
+	ref = strings.TrimPrefix(ref, "docker.io/library/")
+	ref = strings.TrimPrefix(ref, "docker.io/")
+	if strings.Contains(ref, "@") {
+		return ref
+	} else if i := strings.LastIndex(ref, "/"); !strings.Contains(ref[i+1:], ":") {
+		return ref + ":latest"
+	}
+	return ref
+}
+
+// Returns how full, in percent, the file system of the docker root dir is.
+func DockerDiskUsage(client *docker.Client) (int, error) {
+	info, err := client.Info()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("unable to get docker info, error %v", err))
+	}
+
+	var fs unix.Statfs_t
+	if err := unix.Statfs(info.DockerRootDir, &fs); err != nil {
+		return 0, errors.New(fmt.Sprintf("unable to get the file system usage of %v, error %v", info.DockerRootDir, err))
+	} else if fs.Blocks == 0 {
+		return 0, nil
+	}
+	return int(100 * (fs.Blocks - fs.Bavail) / fs.Blocks), nil
+}
+
+// Remove the images of superseded service versions. When dryRun is set the images that would be removed are
+// returned, and nothing is removed.
+func PruneImages(db persistence.EdgeDatabase, client *docker.Client, cfg config.ImageGCConfig, dryRun bool, reason string) (*ImagePruneResult, error) {
+
+	imageGCLock.Lock()
+	defer imageGCLock.Unlock()
+
+	result := &ImagePruneResult{
+		DryRun:       dryRun,
+		Reason:       reason,
+		KeepVersions: cfg.KeepVersions,
+		Removed:      make([]PrunedImage, 0),
+	}
+	if used, err := DockerDiskUsage(client); err != nil {
+		glog.Warningf("Image GC unable to check disk usage: %v", err)
+	} else {
+		result.DiskUsedPercent = used
+	}
+
+	inv, err := buildImageInventory(db)
+	if err != nil {
+		return nil, err
+	}
+	candidates := inv.prunable(cfg.KeepVersions)
+	if len(candidates) == 0 {
+		return result, nil
+	}
+
+	images, err := client.ListImages(docker.ListImagesOptions{})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to list images, error %v", err))
+	}
+	containers, err := client.ListContainers(docker.ListContainersOptions{All: true})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to list containers, error %v", err))
+	}
+
+	// A container names its image by reference or by id.
+	used := make(map[string]bool)
+	for _, c := range containers {
+		used[normalizeImageRef(c.Image)] = true
+		used[strings.TrimPrefix(c.Image, "sha256:")] = true
+	}
+
+	for _, image := range images {
+		if used[image.ID] || used[strings.TrimPrefix(image.ID, "sha256:")] {
+			continue
+		}
+
+		refs := make([]string, 0, len(image.RepoTags)+len(image.RepoDigests))
+		for _, ref := range append(append([]string{}, image.RepoTags...), image.RepoDigests...) {
+			if !strings.Contains(ref, "<none>") {
+				refs = append(refs, ref)
+			}
+		}
+
+		remove := make([]string, 0)
+		inUse := false
+		for _, ref := range refs {
+			if used[normalizeImageRef(ref)] {
+				inUse = true
+			} else if _, ok := candidates[normalizeImageRef(ref)]; ok {
+				remove = append(remove, ref)
+			}
+		}
+		if inUse || len(remove) == 0 {
+			continue
+		}
+
+		// The image is only deleted when all of its names are removed, otherwise the names are just untagged.
+		for i, ref := range remove {
+			pruned := PrunedImage{Image: ref, Labels: candidates[normalizeImageRef(ref)]}
+			if i == len(remove)-1 && len(remove) == len(refs) {
+				pruned.Size = image.Size
+			}
+
+			if !dryRun {
+				if err := client.RemoveImageExtended(ref, docker.RemoveImageOptions{}); err != nil {
+					pruned.Error = err.Error()
+					pruned.Size = 0
+					glog.Warningf("Image GC unable to remove image %v, error %v", ref, err)
+				} else {
+					glog.V(3).Infof("Image GC removed image %v of %v", ref, pruned.Labels)
+				}
+			}
+			result.Removed = append(result.Removed, pruned)
+			result.ReclaimedBytes += pruned.Size
+		}
+	}
+
+	return result, nil
+}
+
+// The image GC subworker checks the disk usage often, and prunes when the disk is too full or when the prune
+// interval has passed.
+func (b *ContainerWorker) imageGC() int {
+	cfg := b.Config.Edge.ImageGC
+
+	// The images are pruned with the docker API.
+	client := b.GetClient()
+	if client == nil {
+		return 0
+	}
+
+	reason := ""
+	if used, err := DockerDiskUsage(client); err != nil {
+		glog.Warningf("Image GC unable to check disk usage: %v", err)
+	} else if used >= cfg.DiskPressurePercent {
+		reason = fmt.Sprintf("the docker root file system is %v%% full", used)
+	}
+	if reason == "" && time.Since(b.lastImageGC) >= time.Duration(cfg.IntervalS)*time.Second {
+		reason = "scheduled"
+	}
+	if reason == "" {
+		return 0
+	}
+
+	b.lastImageGC = time.Now()
+	if result, err := PruneImages(b.db, client, cfg, false, reason); err != nil {
+		glog.Errorf("Image GC failed: %v", err)
+	} else if len(result.Removed) != 0 {
+		glog.Infof("Image GC removed %v images, reclaimed %v bytes, because %v", len(result.Removed), result.ReclaimedBytes, reason)
+	}
+	return 0
+}
@@ -0,0 +1,110 @@
+// +build unit
+
+package container
+
+import (
+	"github.com/open-horizon/anax/persistence"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_normalizeImageRef(t *testing.T) {
+
+	for ref, expected := range map[string]string{
+		"ubuntu":                              "ubuntu:latest",
+		"docker.io/library/ubuntu:18.04":      "ubuntu:18.04",
+		"docker.io/openhorizon/gps":           "openhorizon/gps:latest",
+		"myregistry:5000/myorg/gps":           "myregistry:5000/myorg/gps:latest",
+		"myregistry:5000/myorg/gps:1.0.0":     "myregistry:5000/myorg/gps:1.0.0",
+		"openhorizon/gps@sha256:0123456789ab": "openhorizon/gps@sha256:0123456789ab",
+	} {
+		if n := normalizeImageRef(ref); n != expected {
+			t.Errorf("normalizeImageRef(%v) returned %v, expected %v", ref, n, expected)
+		}
+	}
+}
+
+func Test_newestFirst(t *testing.T) {
+
+	versions := []string{"1.0.0", "1.10.0", "1.2", "2.0.0"}
+	sort.Sort(newestFirst(versions))
+	if !reflect.DeepEqual(versions, []string{"2.0.0", "1.10.0", "1.2", "1.0.0"}) {
+		t.Errorf("versions are not sorted newest first: %v", versions)
+	}
+}
+
+func Test_prunable(t *testing.T) {
+
+	inv := make(imageInventory)
+	inv.add("myorg/gps", "1.0.0", []string{"myorg/gps:1.0.0", "myorg/base:1"}, false)
+	inv.add("myorg/gps", "1.1.0", []string{"myorg/gps:1.1.0", "myorg/base:1"}, false)
+	inv.add("myorg/gps", "1.2.0", []string{"myorg/gps:1.2.0", "myorg/base:2"}, true)
+	inv.add("myorg/cpu", "2.0.0", []string{"myorg/cpu:2.0.0"}, false)
+	inv.add("myorg/cpu", "3.0.0", []string{"myorg/cpu:3.0.0"}, false)
+
+	// The newest 2 versions of each service are kept. The base image of 1.0.0 is kept because 1.1.0 uses it.
+	expected := map[string][]string{
+		"myorg/gps:1.0.0": {"myorg/gps 1.0.0"},
+	}
+	if p := inv.prunable(2); !reflect.DeepEqual(p, expected) {
+		t.Errorf("prunable returned %v, expected %v", p, expected)
+	}
+
+	expected = map[string][]string{
+		"myorg/gps:1.0.0": {"myorg/gps 1.0.0"},
+		"myorg/gps:1.1.0": {"myorg/gps 1.1.0"},
+		"myorg/base:1":    {"myorg/gps 1.0.0", "myorg/gps 1.1.0"},
+		"myorg/cpu:2.0.0": {"myorg/cpu 2.0.0"},
+	}
+	if p := inv.prunable(1); !reflect.DeepEqual(p, expected) {
+		t.Errorf("prunable returned %v, expected %v", p, expected)
+	}
+
+	// An old version that is in use keeps its images.
+	inv.add("myorg/cpu", "2.0.0", nil, true)
+	if p := inv.prunable(1); len(p) != 3 {
+		t.Errorf("prunable should have returned 3 images: %v", p)
+	} else if _, ok := p["myorg/cpu:2.0.0"]; ok {
+		t.Errorf("prunable should not have returned the image of a version in use: %v", p)
+	}
+}
+
+func Test_buildImageInventory(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "imagegc-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := persistence.OpenBoltDatabase(path.Join(dir, "anax-int.db"))
+	if err != nil {
+		t.Fatalf("unable to open db: %v", err)
+	}
+	defer db.Close()
+
+	for _, msdef := range []persistence.MicroserviceDefinition{
+		{SpecRef: "gps", Org: "myorg", Version: "1.0.0", Archived: true, Deployment: `{"services":{"gps":{"image":"myorg/gps:1.0.0"}}}`},
+		{SpecRef: "gps", Org: "myorg", Version: "1.1.0", Deployment: `{"services":{"gps":{"image":"docker.io/myorg/gps:1.1.0"}}}`},
+		{SpecRef: "bad", Org: "myorg", Version: "1.0.0", Deployment: `{"services":`},
+	} {
+		if err := persistence.SaveOrUpdateMicroserviceDef(db, &msdef); err != nil {
+			t.Fatalf("unable to save service definition: %v", err)
+		}
+	}
+
+	inv, err := buildImageInventory(db)
+	if err != nil {
+		t.Errorf("buildImageInventory returned an error: %v", err)
+	} else if len(inv) != 1 || len(inv["myorg/gps"]) != 2 {
+		t.Errorf("the inventory is not correct: %v", inv)
+	} else if vi := inv["myorg/gps"]["1.1.0"]; !vi.inUse || !vi.images["myorg/gps:1.1.0"] {
+		t.Errorf("the current version is not correct: %v", vi)
+	} else if vi := inv["myorg/gps"]["1.0.0"]; vi.inUse || !vi.images["myorg/gps:1.0.0"] {
+		t.Errorf("the archived version is not correct: %v", vi)
+	}
+}
@@ -0,0 +1,254 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containerruntime"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The service containers log with the driver in the node's ContainerLog config. Docker can read back the logs of
+// json-file and journald containers, the logs of syslog containers are read from the host's syslog file, where the
+// tag that anax gives each container identifies its lines.
+
+// How often a syslog file that is being followed is checked for new lines.
+const SYSLOG_FOLLOW_INTERVAL = time.Second
+
+// Which log lines to return.
+type LogOptions struct {
+	Tail   int       // The number of lines from the end of the log, 0 is all of them.
+	Since  time.Time // Only the lines logged after this time, the zero time is all of them.
+	Follow bool      // Keep writing the lines as they are logged.
+}
+
+func (o LogOptions) String() string {
+	return fmt.Sprintf("Tail: %v, Since: %v, Follow: %v", o.Tail, o.Since, o.Follow)
+}
+
+// The docker log config for a service container. The tag identifies the container's lines in syslog and journald.
+func containerLogConfig(cfg config.ContainerLogConfig, tag string) docker.LogConfig {
+	lc := docker.LogConfig{
+		Type:   cfg.Driver,
+		Config: map[string]string{},
+	}
+	if lc.Type == "" {
+		lc.Type = config.CONTAINER_LOG_SYSLOG
+	}
+
+	switch lc.Type {
+	case config.CONTAINER_LOG_JSON_FILE:
+		lc.Config["max-size"] = fmt.Sprintf("%vm", cfg.MaxSizeMB)
+		lc.Config["max-file"] = strconv.Itoa(cfg.MaxFiles)
+	default:
+		lc.Config["tag"] = tag
+	}
+
+	for k, v := range cfg.Options {
+		lc.Config[k] = v
+	}
+	return lc
+}
+
+// Find the containers, running or not, of the service with the given name in the deployment description, in any of
+// the agreements or dependent service instances.
+func FindServiceContainers(rt containerruntime.Runtime, agreements []string, serviceName string) ([]docker.APIContainers, error) {
+	found := make(map[string]bool)
+	containers := make([]docker.APIContainers, 0)
+
+	err := matchAgreementContainers(rt, agreements, true, func(c *docker.APIContainers, agreementId string) error {
+		if c.Labels[LABEL_PREFIX+".service_name"] == serviceName && !found[c.ID] {
+			found[c.ID] = true
+			containers = append(containers, *c)
+		}
+		return nil
+	})
+	return containers, err
+}
+
+// Write the logs of a container to out. When following the log, the lines are written as they are logged until the
+// context is done.
+func ContainerLogs(ctx context.Context, client *docker.Client, containerId string, opts LogOptions, syslogFile string, out io.Writer) error {
+
+	c, err := client.InspectContainer(containerId)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to inspect container %v, error %v", containerId, err))
+	}
+
+	if c.HostConfig != nil && c.HostConfig.LogConfig.Type == config.CONTAINER_LOG_SYSLOG {
+		tag := c.HostConfig.LogConfig.Config["tag"]
+		if tag == "" {
+			return errors.New(fmt.Sprintf("container %v uses the syslog log driver without a tag, its lines can not be found", containerId))
+		}
+		return readSyslog(ctx, syslogPath(syslogFile), tag, opts, out)
+	}
+
+	lo := docker.LogsOptions{
+		Context:      ctx,
+		Container:    containerId,
+		OutputStream: out,
+		ErrorStream:  out,
+		Tail:         "all",
+		Follow:       opts.Follow,
+		Stdout:       true,
+		Stderr:       true,
+	}
+	if opts.Tail > 0 {
+		lo.Tail = strconv.Itoa(opts.Tail)
+	}
+	if !opts.Since.IsZero() {
+		lo.Since = opts.Since.Unix()
+	}
+
+	if err := client.Logs(lo); err != nil && ctx.Err() == nil {
+		return errors.New(fmt.Sprintf("unable to read the logs of container %v, error %v", containerId, err))
+	}
+	return nil
+}
+
+// The configured syslog file, or the one the host's syslog is most likely to write to.
+func syslogPath(configured string) string {
+	if configured != "" {
+		return configured
+	} else if _, err := os.Stat("/var/log/syslog"); err == nil {
+		return "/var/log/syslog"
+	}
+	return "/var/log/messages"
+}
+
+// Write the message of each line in the syslog file that has the tag. The whole file is read first so that only the
+// last lines are written when there is a tail.
+func readSyslog(ctx context.Context, file string, tag string, opts LogOptions, out io.Writer) error {
+
+	lines := make([]string, 0)
+	now := time.Now()
+	offset, err := scanSyslog(file, 0, func(line string) error {
+		if t, msg, ok := parseSyslogLine(line, tag, now); ok && (opts.Since.IsZero() || !t.Before(opts.Since)) {
+			lines = append(lines, msg)
+			if opts.Tail > 0 && len(lines) > opts.Tail {
+				lines = lines[1:]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range lines {
+		if _, err := io.WriteString(out, msg); err != nil {
+			return err
+		}
+	}
+
+	if !opts.Follow {
+		return nil
+	}
+
+	// Write the new lines as they are added to the file. When the file gets smaller it has been rotated, so the
+	// lines are read from the start of the file that now has the name.
+	ticker := time.NewTicker(SYSLOG_FOLLOW_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if info, err := os.Stat(file); err != nil || info.Size() == offset {
+			continue
+		} else if info.Size() < offset {
+			glog.V(3).Infof("Syslog file %v was rotated, reading it from the start", file)
+			offset = 0
+		}
+
+		offset, err = scanSyslog(file, offset, func(line string) error {
+			if _, msg, ok := parseSyslogLine(line, tag, time.Now()); ok {
+				_, err := io.WriteString(out, msg)
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Call fn for each complete line in the file after the offset, and return the offset after the last one. A partial
+// line at the end of the file is left to be read when it is complete.
+func scanSyslog(file string, offset int64, fn func(line string) error) (int64, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return offset, errors.New(fmt.Sprintf("unable to open syslog file %v, error %v", file, err))
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, errors.New(fmt.Sprintf("unable to read syslog file %v, error %v", file, err))
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return offset, nil
+		}
+		offset += int64(len(line))
+		if err := fn(line); err != nil {
+			return offset, err
+		}
+	}
+}
+
+// Parse a syslog file line, in either the traditional "Jan _2 15:04:05 host tag[pid]: message" form or with an
+// RFC3339 timestamp. The traditional form has no year, so it is the year that puts the time closest before now.
+// Returns false when the line is not for the tag.
+func parseSyslogLine(line string, tag string, now time.Time) (time.Time, string, bool) {
+
+	var t time.Time
+	var rest string
+	if fields := strings.SplitN(line, " ", 2); len(fields) == 2 {
+		if rt, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+			t, rest = rt, fields[1]
+		}
+	}
+	if rest == "" {
+		if len(line) < 16 {
+			return t, "", false
+		} else if st, err := time.ParseInLocation(time.Stamp, line[:15], now.Location()); err != nil {
+			return t, "", false
+		} else {
+			t = st.AddDate(now.Year(), 0, 0)
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			rest = line[16:]
+		}
+	}
+
+	// Skip the host name, then the tag is followed by the pid in brackets, or just the colon.
+	fields := strings.SplitN(rest, " ", 2)
+	if len(fields) != 2 {
+		return t, "", false
+	}
+	rest = fields[1]
+	if strings.HasPrefix(rest, tag+"[") {
+		if i := strings.Index(rest, "]: "); i != -1 {
+			return t, rest[i+3:], true
+		}
+	} else if strings.HasPrefix(rest, tag+": ") {
+		return t, rest[len(tag)+2:], true
+	}
+	return t, "", false
+}
@@ -0,0 +1,118 @@
+// +build unit
+
+package container
+
+import (
+	"bytes"
+	"context"
+	"github.com/open-horizon/anax/config"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func Test_containerLogConfig(t *testing.T) {
+
+	lc := containerLogConfig(config.ContainerLogConfig{}, "workload-ag1_svc")
+	if lc.Type != config.CONTAINER_LOG_SYSLOG || lc.Config["tag"] != "workload-ag1_svc" {
+		t.Errorf("The default log config is not correct: %v", lc)
+	}
+
+	lc = containerLogConfig(config.ContainerLogConfig{Driver: config.CONTAINER_LOG_JSON_FILE, MaxSizeMB: 10, MaxFiles: 3}, "workload-ag1_svc")
+	if lc.Type != config.CONTAINER_LOG_JSON_FILE || lc.Config["max-size"] != "10m" || lc.Config["max-file"] != "3" || lc.Config["tag"] != "" {
+		t.Errorf("The json-file log config is not correct: %v", lc)
+	}
+
+	// The options are passed to the driver as they are.
+	lc = containerLogConfig(config.ContainerLogConfig{Driver: config.CONTAINER_LOG_JOURNALD, Options: map[string]string{"labels": "openhorizon.anax.service_name"}}, "workload-ag1_svc")
+	if lc.Type != config.CONTAINER_LOG_JOURNALD || lc.Config["tag"] != "workload-ag1_svc" || lc.Config["labels"] != "openhorizon.anax.service_name" {
+		t.Errorf("The journald log config is not correct: %v", lc)
+	}
+}
+
+func Test_parseSyslogLine(t *testing.T) {
+
+	now := time.Date(2018, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	if ts, msg, ok := parseSyslogLine("Jan  2 09:30:00 node1 workload-ag1_svc[123]: hello world\n", "workload-ag1_svc", now); !ok {
+		t.Errorf("The line should have matched the tag.")
+	} else if msg != "hello world\n" || !ts.Equal(time.Date(2018, 1, 2, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("The line was not parsed correctly: %v %v", ts, msg)
+	}
+
+	// A time late in the year is from last year.
+	if ts, _, ok := parseSyslogLine("Dec 31 23:00:00 node1 workload-ag1_svc: bye\n", "workload-ag1_svc", now); !ok || ts.Year() != 2017 {
+		t.Errorf("The line was not parsed correctly: %v %v", ts, ok)
+	}
+
+	if ts, msg, ok := parseSyslogLine("2018-01-02T09:45:00.123+00:00 node1 workload-ag1_svc[9]: rfc\n", "workload-ag1_svc", now); !ok || msg != "rfc\n" || ts.Minute() != 45 {
+		t.Errorf("The line was not parsed correctly: %v %v %v", ts, msg, ok)
+	}
+
+	for _, line := range []string{
+		"Jan  2 09:30:00 node1 workload-ag2_svc[123]: other container\n",
+		"Jan  2 09:30:00 node1 workload-ag1_svc2[123]: similar tag\n",
+		"Jan  2 09:30:00 node1\n",
+		"garbage\n",
+	} {
+		if _, _, ok := parseSyslogLine(line, "workload-ag1_svc", now); ok {
+			t.Errorf("The line should not have matched: %v", line)
+		}
+	}
+}
+
+func Test_readSyslog(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "syslog")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "syslog")
+	content := "2018-01-02T09:00:00Z node1 workload-ag1_svc[1]: one\n" +
+		"2018-01-02T09:10:00Z node1 kernel: something else\n" +
+		"2018-01-02T09:20:00Z node1 workload-ag1_svc[1]: two\n" +
+		"2018-01-02T09:30:00Z node1 workload-ag1_svc[1]: three\n"
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("unable to write syslog file: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := readSyslog(context.Background(), file, "workload-ag1_svc", LogOptions{}, &out); err != nil {
+		t.Errorf("readSyslog returned an error: %v", err)
+	} else if out.String() != "one\ntwo\nthree\n" {
+		t.Errorf("The wrong lines were read: %v", out.String())
+	}
+
+	out.Reset()
+	if err := readSyslog(context.Background(), file, "workload-ag1_svc", LogOptions{Tail: 2}, &out); err != nil {
+		t.Errorf("readSyslog returned an error: %v", err)
+	} else if out.String() != "two\nthree\n" {
+		t.Errorf("The wrong lines were read: %v", out.String())
+	}
+
+	out.Reset()
+	since := time.Date(2018, 1, 2, 9, 15, 0, 0, time.UTC)
+	if err := readSyslog(context.Background(), file, "workload-ag1_svc", LogOptions{Tail: 1, Since: since}, &out); err != nil {
+		t.Errorf("readSyslog returned an error: %v", err)
+	} else if out.String() != "three\n" {
+		t.Errorf("The wrong lines were read: %v", out.String())
+	}
+
+	// Following ends when the context is done.
+	out.Reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := readSyslog(ctx, file, "workload-ag1_svc", LogOptions{Since: since, Follow: true}, &out); err != nil {
+		t.Errorf("readSyslog returned an error: %v", err)
+	} else if out.String() != "two\nthree\n" {
+		t.Errorf("The wrong lines were read: %v", out.String())
+	}
+
+	if err := readSyslog(context.Background(), path.Join(dir, "missing"), "workload-ag1_svc", LogOptions{}, &out); err == nil {
+		t.Errorf("readSyslog should have returned an error for a missing file.")
+	}
+}
@@ -0,0 +1,111 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/policy"
+	"os/exec"
+	"strings"
+)
+
+// The network bandwidth of a workload is limited by shaping the traffic on the agreement's bridge. The traffic that
+// the bridge sends to the containers is the workload's download, and the traffic that it receives from them is the
+// workload's upload. Download is shaped with an HTB qdisc, upload is policed on the ingress qdisc because a device
+// can only queue the traffic it sends. Traffic between the containers on the bridge is not limited, and the qdiscs
+// are removed with the bridge when the agreement ends.
+
+// The traffic shaper interface that we use, regardless of how its implemented under the covers. The rates are in
+// Kbps, a rate of 0 is not limited.
+type TrafficShaper interface {
+	Shape(device string, uploadKbps int, downloadKbps int) error
+}
+
+func NewTrafficShaper() TrafficShaper {
+	return NewTCShaper()
+}
+
+// The name of the host network device for a docker bridge network.
+func bridgeDevice(network *docker.Network) string {
+	if name, ok := network.Options["com.docker.network.bridge.name"]; ok && name != "" {
+		return name
+	} else if len(network.ID) > 12 {
+		return "br-" + network.ID[:12]
+	}
+	return "br-" + network.ID
+}
+
+// Limit the bandwidth of the containers on an agreement bridge to the network limits in the agreement.
+func shapeBridge(shaper TrafficShaper, network *docker.Network, limits *policy.ResourceLimit) error {
+	if limits == nil || (limits.NetworkUpload == 0 && limits.NetworkDownload == 0) {
+		return nil
+	} else if limits.NetworkUpload < 0 || limits.NetworkDownload < 0 {
+		return errors.New(fmt.Sprintf("network limits must not be negative, upload %v, download %v", limits.NetworkUpload, limits.NetworkDownload))
+	}
+
+	device := bridgeDevice(network)
+	if err := shaper.Shape(device, limits.NetworkUpload, limits.NetworkDownload); err != nil {
+		return errors.New(fmt.Sprintf("unable to limit the bandwidth of network %v on %v, error: %v", network.Name, device, err))
+	}
+	glog.V(3).Infof("Limited network %v on %v to upload %vKbps, download %vKbps", network.Name, device, limits.NetworkUpload, limits.NetworkDownload)
+	return nil
+}
+
+// ========================================================================================
+// This shaper uses the tc command.
+
+type TCShaper struct {
+	run func(args ...string) error
+}
+
+func NewTCShaper() *TCShaper {
+	return &TCShaper{run: runTC}
+}
+
+func runTC(args ...string) error {
+	glog.V(5).Infof("Running tc %v", strings.Join(args, " "))
+	if out, err := exec.Command("tc", args...).CombinedOutput(); err != nil {
+		return errors.New(fmt.Sprintf("tc %v failed: %v, output: %v", strings.Join(args, " "), err, strings.TrimSpace(string(out))))
+	}
+	return nil
+}
+
+// Replace any shaping that is already on the device, so that shaping a bridge that is reused is not an error.
+func (s *TCShaper) Shape(device string, uploadKbps int, downloadKbps int) error {
+	s.clear(device)
+
+	if downloadKbps != 0 {
+		rate := fmt.Sprintf("%vkbit", downloadKbps)
+		if err := s.run("qdisc", "add", "dev", device, "root", "handle", "1:", "htb", "default", "10"); err != nil {
+			return err
+		} else if err := s.run("class", "add", "dev", device, "parent", "1:", "classid", "1:10", "htb", "rate", rate, "ceil", rate); err != nil {
+			return err
+		}
+	}
+
+	if uploadKbps != 0 {
+		if err := s.run("qdisc", "add", "dev", device, "handle", "ffff:", "ingress"); err != nil {
+			return err
+		} else if err := s.run("filter", "add", "dev", device, "parent", "ffff:", "protocol", "all", "u32", "match", "u32", "0", "0",
+			"police", "rate", fmt.Sprintf("%vkbit", uploadKbps), "burst", policeBurst(uploadKbps), "drop", "flowid", ":1"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The qdiscs might not be there, so errors are ignored.
+func (s *TCShaper) clear(device string) {
+	s.run("qdisc", "del", "dev", device, "root")
+	s.run("qdisc", "del", "dev", device, "ingress")
+}
+
+// The policer allows bursts of 100ms of traffic at the rate, but no less than 10KB.
+func policeBurst(kbps int) string {
+	kb := kbps / 80
+	if kb < 10 {
+		kb = 10
+	}
+	return fmt.Sprintf("%vk", kb)
+}
@@ -0,0 +1,107 @@
+// +build unit
+
+package container
+
+import (
+	"errors"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/policy"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A stand-in for the tc shaper. It remembers the rates for each device.
+type stubShaper struct {
+	lock    sync.Mutex
+	Devices map[string][2]int // upload, download
+	Err     error             // returned by Shape when it is set
+}
+
+func newStubShaper() *stubShaper {
+	return &stubShaper{Devices: make(map[string][2]int)}
+}
+
+func (s *stubShaper) Shape(device string, uploadKbps int, downloadKbps int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.Err != nil {
+		return s.Err
+	}
+	s.Devices[device] = [2]int{uploadKbps, downloadKbps}
+	return nil
+}
+
+func Test_TCShaper(t *testing.T) {
+
+	cmds := make([]string, 0)
+	s := &TCShaper{run: func(args ...string) error {
+		cmds = append(cmds, strings.Join(args, " "))
+		if args[1] == "del" {
+			return errors.New("no qdisc")
+		}
+		return nil
+	}}
+
+	if err := s.Shape("br-123", 800, 2000); err != nil {
+		t.Errorf("Shape should not have returned an error: %v", err)
+	}
+
+	expected := []string{
+		"qdisc del dev br-123 root",
+		"qdisc del dev br-123 ingress",
+		"qdisc add dev br-123 root handle 1: htb default 10",
+		"class add dev br-123 parent 1: classid 1:10 htb rate 2000kbit ceil 2000kbit",
+		"qdisc add dev br-123 handle ffff: ingress",
+		"filter add dev br-123 parent ffff: protocol all u32 match u32 0 0 police rate 800kbit burst 10k drop flowid :1",
+	}
+	if strings.Join(cmds, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("tc commands are not correct: %v", cmds)
+	}
+
+	// Only the direction that has a limit is shaped.
+	cmds = cmds[:0]
+	if err := s.Shape("br-123", 8000, 0); err != nil {
+		t.Errorf("Shape should not have returned an error: %v", err)
+	} else if len(cmds) != 4 || !strings.Contains(cmds[3], "rate 8000kbit burst 100k") {
+		t.Errorf("tc commands are not correct: %v", cmds)
+	}
+}
+
+func Test_shapeBridge(t *testing.T) {
+
+	shaper := newStubShaper()
+	network := &docker.Network{Name: "agreement1", ID: "0123456789abcdef"}
+
+	// Nothing is shaped without network limits.
+	for _, limits := range []*policy.ResourceLimit{nil, &policy.ResourceLimit{Memory: 256, CPUs: 1}} {
+		if err := shapeBridge(shaper, network, limits); err != nil {
+			t.Errorf("shapeBridge should not have returned an error: %v", err)
+		} else if len(shaper.Devices) != 0 {
+			t.Errorf("No devices should have been shaped: %v", shaper.Devices)
+		}
+	}
+
+	if err := shapeBridge(shaper, network, &policy.ResourceLimit{NetworkUpload: 100, NetworkDownload: 500}); err != nil {
+		t.Errorf("shapeBridge should not have returned an error: %v", err)
+	} else if rates, ok := shaper.Devices["br-0123456789ab"]; !ok || rates != [2]int{100, 500} {
+		t.Errorf("The bridge device was not shaped correctly: %v", shaper.Devices)
+	}
+
+	// A bridge with its own device name.
+	network.Options = map[string]string{"com.docker.network.bridge.name": "hzn0"}
+	if err := shapeBridge(shaper, network, &policy.ResourceLimit{NetworkDownload: 500}); err != nil {
+		t.Errorf("shapeBridge should not have returned an error: %v", err)
+	} else if rates, ok := shaper.Devices["hzn0"]; !ok || rates != [2]int{0, 500} {
+		t.Errorf("The bridge device was not shaped correctly: %v", shaper.Devices)
+	}
+
+	shaper.Err = errors.New("tc failed")
+	if err := shapeBridge(shaper, network, &policy.ResourceLimit{NetworkUpload: 100}); err == nil {
+		t.Errorf("shapeBridge should have returned an error.")
+	}
+	if err := shapeBridge(newStubShaper(), network, &policy.ResourceLimit{NetworkUpload: -1}); err == nil {
+		t.Errorf("shapeBridge should have returned an error for a negative limit.")
+	}
+}
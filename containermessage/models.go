@@ -7,7 +7,9 @@ import (
 	"fmt"
 	docker "github.com/fsouza/go-dockerclient"
 	"reflect"
+	"regexp"
 	"strings"
+	"time"
 )
 
 /*
@@ -35,7 +37,32 @@ import (
  *           "HostPort":"5200:6414/tcp",
  *           "HostIP": "0.0.0.0"
  *         }
- *       ]
+ *       ],
+ *       "healthcheck": {
+ *         "command": ["CMD-SHELL", "curl -f http://localhost:6414/health"],
+ *         "interval": "30s",
+ *         "timeout": "5s",
+ *         "retries": 3,
+ *         "start_period": "1m"
+ *       },
+ *       "resources": {
+ *         "memory": 256,
+ *         "memory_reservation": 128,
+ *         "cpu_quota": 50000,
+ *         "cpu_shares": 512,
+ *         "pids_limit": 100,
+ *         "ulimits": [
+ *           {"name": "nofile", "soft": 1024, "hard": 2048}
+ *         ],
+ *         "shm_size": 64
+ *       },
+ *       "cap_drop": ["ALL"],
+ *       "read_only": true,
+ *       "security_opt": ["no-new-privileges", "apparmor=docker-default"],
+ *       "user": "1000:1000",
+ *       "tmpfs": {
+ *         "/tmp": "size=64m"
+ *       }
  *     },
  *     "service_b": {
  *       "image": "...",
@@ -149,6 +176,13 @@ type Service struct {
 	Ports            []docker.PortBinding `json:"ports,omitempty"`
 	EphemeralPorts   []Port               `json:"ephemeral_ports,omitempty"`
 	SpecificPorts    []docker.PortBinding `json:"specific_ports,omitempty"` // obselete. for backward compatibility only, new way should use ports instead.
+	HealthCheck      *HealthCheck         `json:"healthcheck,omitempty"`
+	Resources        *Resources           `json:"resources,omitempty"`
+	CapDrop          []string             `json:"cap_drop,omitempty"`
+	ReadOnly         bool                 `json:"read_only,omitempty"`    // Mount the container's root filesystem read only
+	SecurityOpt      []string             `json:"security_opt,omitempty"` // e.g. "no-new-privileges", "seccomp=<profile json>", "apparmor=<profile name>"
+	User             string               `json:"user,omitempty"`         // The user, and optionally the group, that the container runs as, "uid[:gid]" or "name[:group]"
+	Tmpfs            map[string]string    `json:"tmpfs,omitempty"`        // Container path to the mount options, e.g. "size=64m", for a tmpfs mount
 }
 
 func (s *Service) AddFilesystemBinding(bind string) {
@@ -221,6 +255,219 @@ func (s *Service) AddSpecificPortBinding(b docker.PortBinding) {
 	s.Ports = append(s.Ports, b)
 }
 
+// The security options that docker accepts, some of them take a value after "=" or ":".
+var validSecurityOpts = map[string]bool{
+	"no-new-privileges": true,
+	"seccomp":           true,
+	"apparmor":          true,
+	"label":             true,
+}
+
+// Checks the hardening settings of the service, the same way docker would when it creates the container.
+func (s *Service) ValidateSecurity() error {
+	for _, c := range s.CapDrop {
+		if strings.TrimSpace(c) == "" {
+			return errors.New(fmt.Sprintf("cap_drop %v must not contain an empty capability", s.CapDrop))
+		}
+	}
+
+	for _, opt := range s.SecurityOpt {
+		name := opt
+		if i := strings.IndexAny(opt, "=:"); i != -1 {
+			name = opt[:i]
+			if i == len(opt)-1 {
+				return errors.New(fmt.Sprintf("security_opt %v has no value after the %v", opt, string(opt[i])))
+			}
+		}
+		if !validSecurityOpts[name] {
+			return errors.New(fmt.Sprintf("security_opt %v is not supported, it must start with one of no-new-privileges, seccomp, apparmor or label", opt))
+		}
+	}
+
+	if s.User != "" {
+		if parts := strings.Split(s.User, ":"); len(parts) > 2 || parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+			return errors.New(fmt.Sprintf("user %v must be in the form user or user:group", s.User))
+		}
+	}
+
+	for p := range s.Tmpfs {
+		if !strings.HasPrefix(p, "/") {
+			return errors.New(fmt.Sprintf("tmpfs path %v must be an absolute path in the container", p))
+		}
+	}
+	return nil
+}
+
+// A command that docker runs inside the container to check that the service is working. A container that fails the
+// check the given number of times in a row is unhealthy, and is treated the same way as a container that has stopped.
+// The durations are in the form "30s" or "1m30s", docker's defaults are used for the ones that are omitted.
+type HealthCheck struct {
+	Command     []string `json:"command"`                // ["CMD", "arg", ...], ["CMD-SHELL", "command"], ["NONE"] or just the args to exec
+	Interval    string   `json:"interval,omitempty"`     // The time between checks
+	Timeout     string   `json:"timeout,omitempty"`      // The time a check can run before it is a failure
+	Retries     int      `json:"retries,omitempty"`      // The number of failures in a row that make the container unhealthy
+	StartPeriod string   `json:"start_period,omitempty"` // The time the service has to start before failures are counted
+}
+
+const (
+	HEALTHCHECK_CMD       = "CMD"
+	HEALTHCHECK_CMD_SHELL = "CMD-SHELL"
+	HEALTHCHECK_NONE      = "NONE"
+)
+
+func (h HealthCheck) String() string {
+	return fmt.Sprintf("Command: %v, Interval: %v, Timeout: %v, Retries: %v, StartPeriod: %v", h.Command, h.Interval, h.Timeout, h.Retries, h.StartPeriod)
+}
+
+// Returns the command in the form docker expects, where the first element says how to run the rest.
+func (h *HealthCheck) test() []string {
+	if len(h.Command) != 0 {
+		switch h.Command[0] {
+		case HEALTHCHECK_CMD, HEALTHCHECK_CMD_SHELL, HEALTHCHECK_NONE:
+			return h.Command
+		}
+	}
+	return append([]string{HEALTHCHECK_CMD}, h.Command...)
+}
+
+func (h *HealthCheck) Validate() error {
+	test := h.test()
+	switch test[0] {
+	case HEALTHCHECK_CMD:
+		if len(test) < 2 || test[1] == "" {
+			return errors.New(fmt.Sprintf("healthcheck command %v has nothing to run", h.Command))
+		}
+	case HEALTHCHECK_CMD_SHELL:
+		if len(test) != 2 || test[1] == "" {
+			return errors.New(fmt.Sprintf("healthcheck command %v must have exactly one shell command after %v", h.Command, HEALTHCHECK_CMD_SHELL))
+		}
+	case HEALTHCHECK_NONE:
+		if len(test) != 1 {
+			return errors.New(fmt.Sprintf("healthcheck command %v must not have anything after %v", h.Command, HEALTHCHECK_NONE))
+		}
+	}
+
+	for name, d := range map[string]string{"interval": h.Interval, "timeout": h.Timeout, "start_period": h.StartPeriod} {
+		if _, err := parseHealthCheckDuration(name, d); err != nil {
+			return err
+		}
+	}
+
+	if h.Retries < 0 {
+		return errors.New(fmt.Sprintf("healthcheck retries %v must not be negative", h.Retries))
+	}
+	return nil
+}
+
+// Convert the health check into the docker container config.
+func (h *HealthCheck) HealthConfig() (*docker.HealthConfig, error) {
+	if err := h.Validate(); err != nil {
+		return nil, err
+	}
+
+	// The durations have been validated.
+	interval, _ := parseHealthCheckDuration("interval", h.Interval)
+	timeout, _ := parseHealthCheckDuration("timeout", h.Timeout)
+	startPeriod, _ := parseHealthCheckDuration("start_period", h.StartPeriod)
+
+	return &docker.HealthConfig{
+		Test:        h.test(),
+		Interval:    interval,
+		Timeout:     timeout,
+		StartPeriod: startPeriod,
+		Retries:     h.Retries,
+	}, nil
+}
+
+// An omitted duration is 0, which tells docker to use its default. Docker does not accept durations less than 1ms.
+func parseHealthCheckDuration(name string, d string) (time.Duration, error) {
+	if d == "" {
+		return 0, nil
+	} else if dur, err := time.ParseDuration(d); err != nil {
+		return 0, errors.New(fmt.Sprintf("healthcheck %v %v is not a valid duration, error: %v", name, d, err))
+	} else if dur < time.Millisecond {
+		return 0, errors.New(fmt.Sprintf("healthcheck %v %v must be at least 1ms", name, d))
+	} else {
+		return dur, nil
+	}
+}
+
+// The resources that the service's container is allowed to use. Memory sizes are in MB and CPU times in microseconds,
+// the limits that are omitted are not set, except for memory which defaults to the RAM given to the node.
+type Resources struct {
+	Memory            int64    `json:"memory,omitempty"`             // The hard memory limit
+	MemoryReservation int64    `json:"memory_reservation,omitempty"` // The soft memory limit, used when the host is short of memory
+	CPUQuota          int64    `json:"cpu_quota,omitempty"`          // The CPU time the container can use in each CPU period
+	CPUPeriod         int64    `json:"cpu_period,omitempty"`         // The length of the CPU period, docker defaults it to 100000
+	CPUShares         int64    `json:"cpu_shares,omitempty"`         // The relative weight of the container when the CPUs are busy, docker defaults it to 1024
+	CPUSet            string   `json:"cpuset,omitempty"`             // The CPUs the container can run on, e.g. "0-2,4", instead of the node's DefaultCPUSet
+	PidsLimit         int64    `json:"pids_limit,omitempty"`         // The number of processes the container can have
+	Ulimits           []Ulimit `json:"ulimits,omitempty"`
+	ShmSize           int64    `json:"shm_size,omitempty"` // The size of /dev/shm
+}
+
+type Ulimit struct {
+	Name string `json:"name"`
+	Soft int64  `json:"soft"`
+	Hard int64  `json:"hard"`
+}
+
+// The ulimits that docker can set on a container.
+var validUlimits = map[string]bool{
+	"core": true, "cpu": true, "data": true, "fsize": true, "locks": true, "memlock": true, "msgqueue": true, "nice": true,
+	"nofile": true, "nproc": true, "rss": true, "rtprio": true, "rttime": true, "sigpending": true, "stack": true,
+}
+
+var cpuSetRegex = regexp.MustCompile(`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`)
+
+// The smallest CPU period and quota that the kernel accepts.
+const MIN_CPU_PERIOD = 1000
+
+func (r Resources) String() string {
+	return fmt.Sprintf("Memory: %v, MemoryReservation: %v, CPUQuota: %v, CPUPeriod: %v, CPUShares: %v, CPUSet: %v, PidsLimit: %v, Ulimits: %v, ShmSize: %v",
+		r.Memory, r.MemoryReservation, r.CPUQuota, r.CPUPeriod, r.CPUShares, r.CPUSet, r.PidsLimit, r.Ulimits, r.ShmSize)
+}
+
+func (r *Resources) Validate() error {
+	for name, v := range map[string]int64{"memory": r.Memory, "memory_reservation": r.MemoryReservation, "cpu_quota": r.CPUQuota, "cpu_period": r.CPUPeriod, "cpu_shares": r.CPUShares, "pids_limit": r.PidsLimit, "shm_size": r.ShmSize} {
+		if v < 0 {
+			return errors.New(fmt.Sprintf("resources %v %v must not be negative", name, v))
+		}
+	}
+
+	if r.Memory != 0 && r.MemoryReservation > r.Memory {
+		return errors.New(fmt.Sprintf("resources memory_reservation %v must not be more than memory %v", r.MemoryReservation, r.Memory))
+	} else if r.CPUQuota != 0 && r.CPUQuota < MIN_CPU_PERIOD {
+		return errors.New(fmt.Sprintf("resources cpu_quota %v must be at least %v", r.CPUQuota, MIN_CPU_PERIOD))
+	} else if r.CPUPeriod != 0 && (r.CPUPeriod < MIN_CPU_PERIOD || r.CPUPeriod > 1000000) {
+		return errors.New(fmt.Sprintf("resources cpu_period %v must be between %v and 1000000", r.CPUPeriod, MIN_CPU_PERIOD))
+	} else if r.CPUSet != "" && !cpuSetRegex.MatchString(r.CPUSet) {
+		return errors.New(fmt.Sprintf("resources cpuset %v must be a list of CPU numbers or ranges, e.g. 0-2,4", r.CPUSet))
+	}
+
+	names := make(map[string]bool)
+	for _, u := range r.Ulimits {
+		if !validUlimits[u.Name] {
+			return errors.New(fmt.Sprintf("resources ulimit %v is not supported", u.Name))
+		} else if names[u.Name] {
+			return errors.New(fmt.Sprintf("resources ulimit %v is specified more than once", u.Name))
+		} else if u.Soft > u.Hard {
+			return errors.New(fmt.Sprintf("resources ulimit %v soft limit %v must not be more than the hard limit %v", u.Name, u.Soft, u.Hard))
+		}
+		names[u.Name] = true
+	}
+	return nil
+}
+
+// Convert the ulimits into the docker host config.
+func (r *Resources) DockerUlimits() []docker.ULimit {
+	ulimits := make([]docker.ULimit, 0, len(r.Ulimits))
+	for _, u := range r.Ulimits {
+		ulimits = append(ulimits, docker.ULimit{Name: u.Name, Soft: u.Soft, Hard: u.Hard})
+	}
+	return ulimits
+}
+
 type Port struct {
 	LocalhostOnly   bool   `json:"localhost_only,omitempty"`
 	PortAndProtocol string `json:"port_and_protocol"`
@@ -3,8 +3,10 @@
 package containermessage
 
 import (
+	"encoding/json"
 	docker "github.com/fsouza/go-dockerclient"
 	"testing"
+	"time"
 )
 
 func Test_HasSpecificPortBinding(t *testing.T) {
@@ -178,3 +180,99 @@ func Test_AddSpecificPortBinding(t *testing.T) {
 		t.Errorf("Service should have 2 specific port bindings but not.")
 	}
 }
+
+func Test_HealthCheck(t *testing.T) {
+	hc := HealthCheck{
+		Command:     []string{"CMD-SHELL", "curl -f http://localhost:8080/health"},
+		Interval:    "30s",
+		Timeout:     "5s",
+		Retries:     3,
+		StartPeriod: "1m",
+	}
+
+	if dhc, err := hc.HealthConfig(); err != nil {
+		t.Errorf("HealthConfig for %v should not have returned an error: %v", hc, err)
+	} else if len(dhc.Test) != 2 || dhc.Test[0] != HEALTHCHECK_CMD_SHELL || dhc.Interval != 30*time.Second || dhc.Timeout != 5*time.Second || dhc.StartPeriod != time.Minute || dhc.Retries != 3 {
+		t.Errorf("HealthConfig for %v is not correct: %v", hc, dhc)
+	}
+
+	// A command without a prefix is run directly, and the omitted durations are left to docker.
+	hc = HealthCheck{Command: []string{"/bin/check", "--quick"}}
+	if dhc, err := hc.HealthConfig(); err != nil {
+		t.Errorf("HealthConfig for %v should not have returned an error: %v", hc, err)
+	} else if len(dhc.Test) != 3 || dhc.Test[0] != HEALTHCHECK_CMD || dhc.Interval != 0 || dhc.Retries != 0 {
+		t.Errorf("HealthConfig for %v is not correct: %v", hc, dhc)
+	}
+
+	for _, bad := range []HealthCheck{
+		{},
+		{Command: []string{"CMD"}},
+		{Command: []string{"CMD-SHELL", "curl", "-f"}},
+		{Command: []string{"NONE", "curl"}},
+		{Command: []string{"CMD", "true"}, Interval: "30"},
+		{Command: []string{"CMD", "true"}, Timeout: "-5s"},
+		{Command: []string{"CMD", "true"}, StartPeriod: "1ns"},
+		{Command: []string{"CMD", "true"}, Retries: -1},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate for %v should have returned an error.", bad)
+		}
+	}
+}
+
+func Test_Resources(t *testing.T) {
+	var svc Service
+	if err := json.Unmarshal([]byte(`{"image":"foo","resources":{"memory":256,"memory_reservation":128,"cpu_quota":50000,"cpuset":"0-1,3","pids_limit":100,"ulimits":[{"name":"nofile","soft":1024,"hard":2048}],"shm_size":64}}`), &svc); err != nil {
+		t.Errorf("Unable to unmarshal service with resources: %v", err)
+	} else if svc.Resources == nil {
+		t.Errorf("Resources should have been unmarshalled.")
+	} else if err := svc.Resources.Validate(); err != nil {
+		t.Errorf("Validate for %v should not have returned an error: %v", svc.Resources, err)
+	} else if ul := svc.Resources.DockerUlimits(); len(ul) != 1 || ul[0].Name != "nofile" || ul[0].Soft != 1024 || ul[0].Hard != 2048 {
+		t.Errorf("Ulimits for %v are not correct: %v", svc.Resources, ul)
+	}
+
+	for _, bad := range []Resources{
+		{Memory: -1},
+		{Memory: 128, MemoryReservation: 256},
+		{CPUQuota: 10},
+		{CPUPeriod: 2000000},
+		{CPUSet: "0,"},
+		{CPUSet: "all"},
+		{Ulimits: []Ulimit{{Name: "files", Soft: 1, Hard: 1}}},
+		{Ulimits: []Ulimit{{Name: "nproc", Soft: 2, Hard: 1}}},
+		{Ulimits: []Ulimit{{Name: "nproc", Soft: 1, Hard: 1}, {Name: "nproc", Soft: 1, Hard: 2}}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate for %v should have returned an error.", bad)
+		}
+	}
+}
+
+func Test_ValidateSecurity(t *testing.T) {
+	svc := Service{
+		Image:       "foo",
+		CapDrop:     []string{"ALL"},
+		ReadOnly:    true,
+		SecurityOpt: []string{"no-new-privileges", "seccomp=unconfined", "apparmor:docker-default", "label=disable"},
+		User:        "1000:1000",
+		Tmpfs:       map[string]string{"/tmp": "size=64m", "/run": ""},
+	}
+	if err := svc.ValidateSecurity(); err != nil {
+		t.Errorf("ValidateSecurity for %v should not have returned an error: %v", svc, err)
+	}
+
+	for _, bad := range []Service{
+		{CapDrop: []string{""}},
+		{SecurityOpt: []string{"privileged"}},
+		{SecurityOpt: []string{"seccomp="}},
+		{User: ":1000"},
+		{User: "1000:"},
+		{User: "a:b:c"},
+		{Tmpfs: map[string]string{"tmp": ""}},
+	} {
+		if err := bad.ValidateSecurity(); err == nil {
+			t.Errorf("ValidateSecurity for %v should have returned an error.", bad)
+		}
+	}
+}
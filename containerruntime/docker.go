@@ -0,0 +1,104 @@
+package containerruntime
+
+import (
+	"errors"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/config"
+)
+
+const DOCKER_DEFAULT_ENDPOINT = "unix:///var/run/docker.sock"
+
+// The docker runtime is a thin layer over the docker client.
+type DockerRuntime struct {
+	client   *docker.Client
+	endpoint string
+}
+
+func NewDockerRuntime(endpoint string) (*DockerRuntime, error) {
+	if endpoint == "" {
+		endpoint = DOCKER_DEFAULT_ENDPOINT
+	}
+	client, err := docker.NewClient(endpoint)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create docker client from %v, error %v", endpoint, err))
+	}
+	return &DockerRuntime{client: client, endpoint: endpoint}, nil
+}
+
+func (r *DockerRuntime) Client() *docker.Client {
+	return r.client
+}
+
+func (r *DockerRuntime) Name() string {
+	return config.CONTAINER_RUNTIME_DOCKER
+}
+
+func (r *DockerRuntime) Endpoint() string {
+	return r.endpoint
+}
+
+func (r *DockerRuntime) CreateContainer(opts docker.CreateContainerOptions) (*docker.Container, error) {
+	return r.client.CreateContainer(opts)
+}
+
+func (r *DockerRuntime) StartContainer(id string) error {
+	// the host config argument is only there for backwards compatibility with old docker APIs
+	return r.client.StartContainer(id, nil)
+}
+
+func (r *DockerRuntime) StopContainer(id string, timeoutS uint) error {
+	return r.client.StopContainer(id, timeoutS)
+}
+
+func (r *DockerRuntime) KillContainer(id string) error {
+	return r.client.KillContainer(docker.KillContainerOptions{ID: id})
+}
+
+func (r *DockerRuntime) RemoveContainer(id string, removeVolumes bool) error {
+	return r.client.RemoveContainer(docker.RemoveContainerOptions{ID: id, RemoveVolumes: removeVolumes, Force: true})
+}
+
+func (r *DockerRuntime) InspectContainer(id string) (*docker.Container, error) {
+	return r.client.InspectContainer(id)
+}
+
+func (r *DockerRuntime) ListContainers(all bool, labels []string) ([]docker.APIContainers, error) {
+	return r.client.ListContainers(docker.ListContainersOptions{All: all, Filters: labelFilter(labels)})
+}
+
+func (r *DockerRuntime) CreateNetwork(opts docker.CreateNetworkOptions) (*docker.Network, error) {
+	return r.client.CreateNetwork(opts)
+}
+
+func (r *DockerRuntime) ListNetworks() ([]docker.Network, error) {
+	return r.client.ListNetworks()
+}
+
+func (r *DockerRuntime) NetworkInfo(id string) (*docker.Network, error) {
+	return r.client.NetworkInfo(id)
+}
+
+func (r *DockerRuntime) ConnectNetwork(networkId string, containerId string, endpoint *docker.EndpointConfig) error {
+	return r.client.ConnectNetwork(networkId, docker.NetworkConnectionOptions{Container: containerId, EndpointConfig: endpoint, Force: true})
+}
+
+func (r *DockerRuntime) DisconnectNetwork(networkId string, containerId string, force bool) error {
+	return r.client.DisconnectNetwork(networkId, docker.NetworkConnectionOptions{Container: containerId, Force: force})
+}
+
+func (r *DockerRuntime) RemoveNetwork(id string) error {
+	return r.client.RemoveNetwork(id)
+}
+
+func (r *DockerRuntime) RemoveVolume(name string) error {
+	return r.client.RemoveVolume(name)
+}
+
+func (r *DockerRuntime) PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
+	return r.client.PullImage(opts, auth)
+}
+
+func (r *DockerRuntime) InspectImage(name string) (*docker.Image, error) {
+	return r.client.InspectImage(name)
+}
@@ -0,0 +1,398 @@
+// +build unit
+
+package containerruntime
+
+import (
+	"errors"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The fake runtime keeps its containers, networks, volumes and images in memory, so that the code which manages
+// service containers can be unit tested without a container runtime on the machine. It returns the same errors as
+// the docker client for the cases the agent handles, e.g. a container that already exists or is not running.
+// It is only built with the unit tag, so that it is not part of the agent, and can still be used by the unit tests
+// of other packages.
+type FakeRuntime struct {
+	lock       sync.Mutex
+	nextId     int
+	containers map[string]*docker.Container
+	networks   map[string]*docker.Network
+	volumes    map[string]bool
+	images     map[string]*docker.Image
+	registry   map[string]*docker.Image
+	Pulls      []string // The images that were pulled, in the order they were pulled.
+}
+
+func NewFakeRuntime() *FakeRuntime {
+	return &FakeRuntime{
+		containers: make(map[string]*docker.Container),
+		networks:   make(map[string]*docker.Network),
+		volumes:    make(map[string]bool),
+		images:     make(map[string]*docker.Image),
+		registry:   make(map[string]*docker.Image),
+		Pulls:      make([]string, 0),
+	}
+}
+
+// Add an image that is already on the node.
+func (r *FakeRuntime) AddImage(name string, image *docker.Image) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.images[imageKey(name)] = image
+}
+
+// Add an image that can be pulled.
+func (r *FakeRuntime) AddRegistryImage(name string, image *docker.Image) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.registry[imageKey(name)] = image
+}
+
+func (r *FakeRuntime) Name() string {
+	return "fake"
+}
+
+func (r *FakeRuntime) Endpoint() string {
+	return ""
+}
+
+func (r *FakeRuntime) CreateContainer(opts docker.CreateContainerOptions) (*docker.Container, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, c := range r.containers {
+		if c.Name == "/"+opts.Name {
+			return nil, docker.ErrContainerAlreadyExists
+		}
+	}
+	if opts.Config == nil {
+		return nil, errors.New("container config is required")
+	} else if _, ok := r.findImage(opts.Config.Image); !ok {
+		return nil, docker.ErrNoSuchImage
+	}
+
+	c := &docker.Container{
+		ID:              r.newId(),
+		Name:            "/" + opts.Name,
+		Created:         time.Now(),
+		Config:          opts.Config,
+		HostConfig:      opts.HostConfig,
+		Image:           opts.Config.Image,
+		NetworkSettings: &docker.NetworkSettings{Networks: make(map[string]docker.ContainerNetwork)},
+	}
+	if opts.NetworkingConfig != nil {
+		for name, ep := range opts.NetworkingConfig.EndpointsConfig {
+			net, ok := r.findNetwork(name)
+			if !ok {
+				return nil, &docker.NoSuchNetwork{ID: name}
+			}
+			r.connect(net, c, ep)
+		}
+	}
+	if opts.HostConfig != nil {
+		for _, bind := range opts.HostConfig.Binds {
+			if source := strings.Split(bind, ":")[0]; !strings.HasPrefix(source, "/") {
+				r.volumes[source] = true
+			}
+		}
+	}
+	r.containers[c.ID] = c
+
+	return c, nil
+}
+
+func (r *FakeRuntime) StartContainer(id string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if c, ok := r.containers[id]; !ok {
+		return &docker.NoSuchContainer{ID: id}
+	} else if c.State.Running {
+		return &docker.ContainerAlreadyRunning{ID: id}
+	} else {
+		c.State = docker.State{Running: true, Status: "running", StartedAt: time.Now()}
+	}
+	return nil
+}
+
+func (r *FakeRuntime) StopContainer(id string, timeoutS uint) error {
+	return r.KillContainer(id)
+}
+
+func (r *FakeRuntime) KillContainer(id string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if c, ok := r.containers[id]; !ok {
+		return &docker.NoSuchContainer{ID: id}
+	} else if !c.State.Running {
+		return &docker.ContainerNotRunning{ID: id}
+	} else {
+		c.State = docker.State{Running: false, Status: "exited", StartedAt: c.State.StartedAt, FinishedAt: time.Now()}
+	}
+	return nil
+}
+
+func (r *FakeRuntime) RemoveContainer(id string, removeVolumes bool) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.containers[id]; !ok {
+		return &docker.NoSuchContainer{ID: id}
+	}
+	for _, net := range r.networks {
+		delete(net.Containers, id)
+	}
+	delete(r.containers, id)
+	return nil
+}
+
+func (r *FakeRuntime) InspectContainer(id string) (*docker.Container, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if c, ok := r.findContainer(id); !ok {
+		return nil, &docker.NoSuchContainer{ID: id}
+	} else {
+		copy := *c
+		return &copy, nil
+	}
+}
+
+func (r *FakeRuntime) ListContainers(all bool, labels []string) ([]docker.APIContainers, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	containers := make([]docker.APIContainers, 0)
+	for _, c := range r.containers {
+		if !all && !c.State.Running {
+			continue
+		} else if !hasLabels(c.Config.Labels, labels) {
+			continue
+		}
+
+		ac := docker.APIContainers{
+			ID:       c.ID,
+			Image:    c.Image,
+			Created:  c.Created.Unix(),
+			Names:    []string{c.Name},
+			Labels:   c.Config.Labels,
+			State:    "exited",
+			Status:   "Exited (0)",
+			Networks: docker.NetworkList{Networks: make(map[string]docker.ContainerNetwork)},
+		}
+		if c.State.Running {
+			ac.State = "running"
+			ac.Status = "Up"
+		}
+		for name, nw := range c.NetworkSettings.Networks {
+			ac.Networks.Networks[name] = nw
+		}
+		containers = append(containers, ac)
+	}
+	return containers, nil
+}
+
+func (r *FakeRuntime) CreateNetwork(opts docker.CreateNetworkOptions) (*docker.Network, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.findNetwork(opts.Name); ok && opts.CheckDuplicate {
+		return nil, docker.ErrNetworkAlreadyExists
+	}
+	net := &docker.Network{
+		ID:         r.newId(),
+		Name:       opts.Name,
+		Driver:     opts.Driver,
+		Internal:   opts.Internal,
+		Labels:     opts.Labels,
+		Containers: make(map[string]docker.Endpoint),
+	}
+	r.networks[net.ID] = net
+	copy := *net
+	return &copy, nil
+}
+
+func (r *FakeRuntime) ListNetworks() ([]docker.Network, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	networks := make([]docker.Network, 0, len(r.networks))
+	for _, net := range r.networks {
+		networks = append(networks, copyNetwork(net))
+	}
+	return networks, nil
+}
+
+func (r *FakeRuntime) NetworkInfo(id string) (*docker.Network, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if net, ok := r.findNetwork(id); !ok {
+		return nil, &docker.NoSuchNetwork{ID: id}
+	} else {
+		copy := copyNetwork(net)
+		return &copy, nil
+	}
+}
+
+func (r *FakeRuntime) ConnectNetwork(networkId string, containerId string, endpoint *docker.EndpointConfig) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	net, nok := r.findNetwork(networkId)
+	c, cok := r.findContainer(containerId)
+	if !nok || !cok {
+		return &docker.NoSuchNetworkOrContainer{NetworkID: networkId, ContainerID: containerId}
+	}
+	r.connect(net, c, endpoint)
+	return nil
+}
+
+func (r *FakeRuntime) DisconnectNetwork(networkId string, containerId string, force bool) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	net, nok := r.findNetwork(networkId)
+	c, cok := r.findContainer(containerId)
+	if !nok || !cok {
+		return &docker.NoSuchNetworkOrContainer{NetworkID: networkId, ContainerID: containerId}
+	}
+	delete(net.Containers, c.ID)
+	delete(c.NetworkSettings.Networks, net.Name)
+	return nil
+}
+
+func (r *FakeRuntime) RemoveNetwork(id string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if net, ok := r.findNetwork(id); !ok {
+		return &docker.NoSuchNetwork{ID: id}
+	} else if len(net.Containers) != 0 {
+		return errors.New(fmt.Sprintf("network %v has active endpoints", net.Name))
+	} else {
+		delete(r.networks, net.ID)
+	}
+	return nil
+}
+
+func (r *FakeRuntime) RemoveVolume(name string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.volumes[name] {
+		return docker.ErrNoSuchVolume
+	}
+	delete(r.volumes, name)
+	return nil
+}
+
+func (r *FakeRuntime) PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	name := opts.Repository
+	if opts.Tag != "" {
+		name = fmt.Sprintf("%v:%v", opts.Repository, opts.Tag)
+	}
+	image, ok := r.registry[imageKey(name)]
+	if !ok {
+		return errors.New(fmt.Sprintf("image %v not found in the registry", name))
+	}
+	r.images[imageKey(name)] = image
+	r.Pulls = append(r.Pulls, name)
+	return nil
+}
+
+func (r *FakeRuntime) InspectImage(name string) (*docker.Image, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if image, ok := r.findImage(name); !ok {
+		return nil, docker.ErrNoSuchImage
+	} else {
+		copy := *image
+		return &copy, nil
+	}
+}
+
+// The lock must be held when calling the functions below.
+
+func (r *FakeRuntime) newId() string {
+	r.nextId++
+	return fmt.Sprintf("%064x", r.nextId)
+}
+
+func (r *FakeRuntime) findContainer(idOrName string) (*docker.Container, bool) {
+	for _, c := range r.containers {
+		if c.ID == idOrName || c.Name == "/"+strings.TrimPrefix(idOrName, "/") {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+func (r *FakeRuntime) findNetwork(idOrName string) (*docker.Network, bool) {
+	for _, net := range r.networks {
+		if net.ID == idOrName || net.Name == idOrName {
+			return net, true
+		}
+	}
+	return nil, false
+}
+
+func (r *FakeRuntime) findImage(name string) (*docker.Image, bool) {
+	if image, ok := r.images[imageKey(name)]; ok {
+		return image, true
+	}
+	for _, image := range r.images {
+		if image.ID == name {
+			return image, true
+		}
+	}
+	return nil, false
+}
+
+func (r *FakeRuntime) connect(net *docker.Network, c *docker.Container, ep *docker.EndpointConfig) {
+	endpoint := docker.ContainerNetwork{NetworkID: net.ID}
+	if ep != nil {
+		endpoint.Aliases = ep.Aliases
+	}
+	net.Containers[c.ID] = docker.Endpoint{Name: strings.TrimPrefix(c.Name, "/")}
+	c.NetworkSettings.Networks[net.Name] = endpoint
+}
+
+func copyNetwork(net *docker.Network) docker.Network {
+	copy := *net
+	copy.Containers = make(map[string]docker.Endpoint, len(net.Containers))
+	for id, ep := range net.Containers {
+		copy.Containers[id] = ep
+	}
+	return copy
+}
+
+// An image name without a tag or digest is the latest tag.
+func imageKey(name string) string {
+	if strings.Contains(name, "@") || strings.LastIndex(name, ":") > strings.LastIndex(name, "/") {
+		return name
+	}
+	return name + ":latest"
+}
+
+// Returns true if the container labels have all of the labels in the filter, each in the form key or key=value.
+func hasLabels(containerLabels map[string]string, labels []string) bool {
+	for _, label := range labels {
+		parts := strings.SplitN(label, "=", 2)
+		if value, ok := containerLabels[parts[0]]; !ok {
+			return false
+		} else if len(parts) == 2 && value != parts[1] {
+			return false
+		}
+	}
+	return true
+}
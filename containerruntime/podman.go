@@ -0,0 +1,52 @@
+package containerruntime
+
+import (
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/cutil"
+	"strings"
+)
+
+const PODMAN_DEFAULT_ENDPOINT = "unix:///run/podman/podman.sock"
+
+// Podman is used through the docker compatible API of its service socket, so it is the docker runtime with a
+// different name. The only difference is in pulling images, see PullImage.
+type PodmanRuntime struct {
+	*DockerRuntime
+}
+
+func NewPodmanRuntime(endpoint string) (*PodmanRuntime, error) {
+	if endpoint == "" {
+		endpoint = PODMAN_DEFAULT_ENDPOINT
+	}
+	dr, err := NewDockerRuntime(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &PodmanRuntime{DockerRuntime: dr}, nil
+}
+
+func (r *PodmanRuntime) Name() string {
+	return config.CONTAINER_RUNTIME_PODMAN
+}
+
+// Docker pulls an image without a registry from docker hub, podman looks it up in the unqualified-search registries
+// of the node, which can be a different registry or can fail in short-name enforcing mode. The deployment was
+// written for docker, so the image is qualified with docker hub before it is pulled.
+func (r *PodmanRuntime) PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
+	opts.Repository = qualifyImageName(opts.Repository)
+	return r.DockerRuntime.PullImage(opts, auth)
+}
+
+// Returns the image name with the docker hub registry, and the library namespace of the official images, when it
+// does not have a registry.
+func qualifyImageName(image string) string {
+	domain, path, _, _ := cutil.ParseDockerImagePath(image)
+	if domain != "" || path == "" || strings.HasPrefix(path, "localhost/") {
+		return image
+	}
+	if !strings.Contains(path, "/") {
+		return "docker.io/library/" + image
+	}
+	return "docker.io/" + image
+}
@@ -0,0 +1,86 @@
+package containerruntime
+
+import (
+	"errors"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/config"
+)
+
+// The agent runs service containers with a container runtime. By default the runtime is docker, but a node that does
+// not have dockerd can be configured to use podman instead. This file contains the abstract interface representing
+// the container runtime used by the container and torrent workers.
+//
+// The containers, networks and images are described with the types of the docker client because the deployment
+// description, the persisted deployment config and the container labels are already built on them, and podman's
+// docker compatible API accepts them unchanged. A runtime that is not docker compatible has to map them onto its
+// own model.
+
+type Runtime interface {
+
+	// The name of the runtime implementation, and the endpoint it is connected to.
+	Name() string
+	Endpoint() string
+
+	// Container related functions. ListContainers returns the containers that have all of the labels, each label is
+	// in the form key or key=value.
+	CreateContainer(opts docker.CreateContainerOptions) (*docker.Container, error)
+	StartContainer(id string) error
+	StopContainer(id string, timeoutS uint) error
+	KillContainer(id string) error
+	RemoveContainer(id string, removeVolumes bool) error
+	InspectContainer(id string) (*docker.Container, error)
+	ListContainers(all bool, labels []string) ([]docker.APIContainers, error)
+
+	// Network related functions.
+	CreateNetwork(opts docker.CreateNetworkOptions) (*docker.Network, error)
+	ListNetworks() ([]docker.Network, error)
+	NetworkInfo(id string) (*docker.Network, error)
+	ConnectNetwork(networkId string, containerId string, endpoint *docker.EndpointConfig) error
+	DisconnectNetwork(networkId string, containerId string, force bool) error
+	RemoveNetwork(id string) error
+	RemoveVolume(name string) error
+
+	// Image related functions.
+	PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error
+	InspectImage(name string) (*docker.Image, error)
+}
+
+// A runtime with a docker compatible API also gives access to the docker client, for the functions that are not
+// part of the runtime interface, e.g. container logs, image load and image removal.
+type DockerCompatible interface {
+	Client() *docker.Client
+}
+
+// Returns the docker client of the runtime, or nil if the runtime is not docker compatible.
+func DockerClient(r Runtime) *docker.Client {
+	if dc, ok := r.(DockerCompatible); ok {
+		return dc.Client()
+	}
+	return nil
+}
+
+// Create the container runtime that is configured for the node.
+func NewRuntime(cfg *config.HorizonConfig) (Runtime, error) {
+
+	switch runtimeType := cfg.GetContainerRuntime(); runtimeType {
+	case config.CONTAINER_RUNTIME_DOCKER:
+		return NewDockerRuntime(cfg.Edge.DockerEndpoint)
+	case config.CONTAINER_RUNTIME_PODMAN:
+		endpoint := cfg.Edge.DockerEndpoint
+		if endpoint == "" {
+			endpoint = PODMAN_DEFAULT_ENDPOINT
+		}
+		return NewPodmanRuntime(endpoint)
+	default:
+		return nil, errors.New(fmt.Sprintf("container runtime %v is not supported", runtimeType))
+	}
+}
+
+// The labels in the filter form of the docker API.
+func labelFilter(labels []string) map[string][]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	return map[string][]string{"label": labels}
+}
@@ -0,0 +1,93 @@
+//go:build unit
+// +build unit
+
+package containerruntime
+
+import (
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/config"
+	"testing"
+)
+
+func Test_NewRuntime(t *testing.T) {
+
+	cfg := &config.HorizonConfig{Edge: config.Config{DockerEndpoint: "unix:///tmp/docker.sock"}}
+	if rt, err := NewRuntime(cfg); err != nil {
+		t.Errorf("NewRuntime returned an error: %v", err)
+	} else if rt.Name() != config.CONTAINER_RUNTIME_DOCKER || rt.Endpoint() != "unix:///tmp/docker.sock" || DockerClient(rt) == nil {
+		t.Errorf("NewRuntime should have returned the docker runtime, returned %v %v", rt.Name(), rt.Endpoint())
+	}
+
+	cfg.Edge = config.Config{ContainerRuntime: config.CONTAINER_RUNTIME_PODMAN}
+	if rt, err := NewRuntime(cfg); err != nil {
+		t.Errorf("NewRuntime returned an error: %v", err)
+	} else if rt.Name() != config.CONTAINER_RUNTIME_PODMAN || rt.Endpoint() != PODMAN_DEFAULT_ENDPOINT || DockerClient(rt) == nil {
+		t.Errorf("NewRuntime should have returned the podman runtime, returned %v %v", rt.Name(), rt.Endpoint())
+	}
+
+	cfg.Edge.ContainerRuntime = "containerd"
+	if _, err := NewRuntime(cfg); err == nil {
+		t.Errorf("NewRuntime should have rejected an unsupported runtime")
+	}
+
+	if DockerClient(NewFakeRuntime()) != nil {
+		t.Errorf("the fake runtime is not docker compatible")
+	}
+}
+
+func Test_qualifyImageName(t *testing.T) {
+
+	for image, expected := range map[string]string{
+		"busybox":                            "docker.io/library/busybox",
+		"busybox:1.31":                       "docker.io/library/busybox:1.31",
+		"myorg/gps:1.0.0":                    "docker.io/myorg/gps:1.0.0",
+		"docker.io/myorg/gps:1.0.0":          "docker.io/myorg/gps:1.0.0",
+		"mydomain.com:5000/myorg/gps@sha256": "mydomain.com:5000/myorg/gps@sha256",
+		"localhost/gps:1.0.0":                "localhost/gps:1.0.0",
+	} {
+		if qualified := qualifyImageName(image); qualified != expected {
+			t.Errorf("qualifyImageName(%v) returned %v, expected %v", image, qualified, expected)
+		}
+	}
+}
+
+func Test_FakeRuntime(t *testing.T) {
+
+	rt := NewFakeRuntime()
+	rt.AddRegistryImage("myorg/gps:1.0.0", &docker.Image{ID: "sha256:1234"})
+
+	opts := docker.CreateContainerOptions{Name: "ag1-gps", Config: &docker.Config{Image: "myorg/gps:1.0.0", Labels: map[string]string{"agreement_id": "ag1", "service_name": "gps"}}}
+	if _, err := rt.CreateContainer(opts); err != docker.ErrNoSuchImage {
+		t.Errorf("CreateContainer should need the image, returned %v", err)
+	} else if err := rt.PullImage(docker.PullImageOptions{Repository: "myorg/gps", Tag: "1.0.0"}, docker.AuthConfiguration{}); err != nil {
+		t.Errorf("PullImage returned an error: %v", err)
+	} else if err := rt.PullImage(docker.PullImageOptions{Repository: "myorg/cpu"}, docker.AuthConfiguration{}); err == nil {
+		t.Errorf("PullImage of an image that is not in the registry should fail")
+	}
+
+	c, err := rt.CreateContainer(opts)
+	if err != nil {
+		t.Fatalf("CreateContainer returned an error: %v", err)
+	}
+
+	// The container is only listed as running after it is started.
+	if cs, _ := rt.ListContainers(false, nil); len(cs) != 0 {
+		t.Errorf("a created container should not be running: %v", cs)
+	} else if err := rt.StartContainer(c.ID); err != nil {
+		t.Errorf("StartContainer returned an error: %v", err)
+	} else if cs, _ := rt.ListContainers(false, []string{"service_name=gps", "agreement_id"}); len(cs) != 1 || cs[0].Names[0] != "/ag1-gps" {
+		t.Errorf("the container should be listed by its labels: %v", cs)
+	} else if cs, _ := rt.ListContainers(true, []string{"service_name=cpu"}); len(cs) != 0 {
+		t.Errorf("the container should not match a different label value: %v", cs)
+	}
+
+	if err := rt.StopContainer(c.ID, 10); err != nil {
+		t.Errorf("StopContainer returned an error: %v", err)
+	} else if _, ok := rt.KillContainer(c.ID).(*docker.ContainerNotRunning); !ok {
+		t.Errorf("KillContainer of a stopped container should return ContainerNotRunning")
+	} else if err := rt.RemoveContainer(c.ID, true); err != nil {
+		t.Errorf("RemoveContainer returned an error: %v", err)
+	} else if _, ok := rt.RemoveContainer(c.ID, true).(*docker.NoSuchContainer); !ok {
+		t.Errorf("RemoveContainer of a removed container should return NoSuchContainer")
+	}
+}
@@ -1,70 +1,81 @@
 package eventlog
 
 import (
-	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/persistence"
+	"time"
 )
 
+// Save the event log, then push it to the subscribers and the export sinks.
+func saveEventLog(db persistence.EdgeDatabase, el *persistence.EventLog) error {
+	if err := persistence.SaveEventLog(db, el); err != nil {
+		return err
+	}
+	publish(*el)
+	export(*el)
+	return nil
+}
+
 // Save the eventlog into the db
-func LogEvent(db *bolt.DB, severity string, message string, event_code string, source_type string, source persistence.EventSourceInterface) error {
+func LogEvent(db persistence.EdgeDatabase, severity string, message string, event_code string, source_type string, source persistence.EventSourceInterface) error {
 	eventlog := persistence.NewEventLog(severity, message, event_code, source_type, source)
-	return persistence.SaveEventLog(db, eventlog)
+	return saveEventLog(db, eventlog)
 }
 
 // Save the agreement eventlog into the db
-func LogAgreementEvent(db *bolt.DB, severity string, message string, event_code string, ag persistence.EstablishedAgreement) error {
+func LogAgreementEvent(db persistence.EdgeDatabase, severity string, message string, event_code string, ag persistence.EstablishedAgreement) error {
 	source := persistence.NewAgreementEventSourceFromAg(ag)
 	eventlog := persistence.NewEventLog(severity, message, event_code, persistence.SRC_TYPE_AG, source)
-	return persistence.SaveEventLog(db, eventlog)
+	return saveEventLog(db, eventlog)
 }
 
 // Save the agreement eventlog into the db
-func LogAgreementEvent2(db *bolt.DB, severity, message, event_code, agreement_id string, workload persistence.WorkloadInfo, dependent_svcs persistence.ServiceSpecs, consumer_id, protocol string) error {
+func LogAgreementEvent2(db persistence.EdgeDatabase, severity, message, event_code, agreement_id string, workload persistence.WorkloadInfo, dependent_svcs persistence.ServiceSpecs, consumer_id, protocol string) error {
 	source := persistence.NewAgreementEventSource(agreement_id, workload, dependent_svcs, consumer_id, protocol)
 	eventlog := persistence.NewEventLog(severity, message, event_code, persistence.SRC_TYPE_AG, source)
-	return persistence.SaveEventLog(db, eventlog)
+	return saveEventLog(db, eventlog)
 }
 
 // Save the service eventlog into the db
-func LogServiceEvent(db *bolt.DB, severity string, message string, event_code string, msi persistence.MicroserviceInstance) error {
+func LogServiceEvent(db persistence.EdgeDatabase, severity string, message string, event_code string, msi persistence.MicroserviceInstance) error {
 	source := persistence.NewServiceEventSourceFromServiceInstance(msi)
 	eventlog := persistence.NewEventLog(severity, message, event_code, persistence.SRC_TYPE_SVC, source)
-	return persistence.SaveEventLog(db, eventlog)
+	return saveEventLog(db, eventlog)
 }
 
 // Save the service eventlog into the db
-func LogServiceEvent2(db *bolt.DB, severity, message, event_code, instance_id, service_url, org, version, arch string, agreement_ids []string) error {
+func LogServiceEvent2(db persistence.EdgeDatabase, severity, message, event_code, instance_id, service_url, org, version, arch string, agreement_ids []string) error {
 	source := persistence.NewServiceEventSource(instance_id, service_url, org, version, arch, agreement_ids)
 	eventlog := persistence.NewEventLog(severity, message, event_code, persistence.SRC_TYPE_SVC, source)
-	return persistence.SaveEventLog(db, eventlog)
+	return saveEventLog(db, eventlog)
 }
 
 // Save the service eventlog into the db
-func LogServiceEvent3(db *bolt.DB, severity string, message string, event_code string, msdef persistence.MicroserviceDefinition) error {
+func LogServiceEvent3(db persistence.EdgeDatabase, severity string, message string, event_code string, msdef persistence.MicroserviceDefinition) error {
 	source := persistence.NewServiceEventSourceFromServiceDef(msdef)
 	eventlog := persistence.NewEventLog(severity, message, event_code, persistence.SRC_TYPE_SVC, source)
-	return persistence.SaveEventLog(db, eventlog)
+	return saveEventLog(db, eventlog)
 }
 
 // Save the node eventlog into the db
-func LogNodeEvent(db *bolt.DB, severity, message, event_code, node_id, org, pattern, config_state string) error {
+func LogNodeEvent(db persistence.EdgeDatabase, severity, message, event_code, node_id, org, pattern, config_state string) error {
 	source := persistence.NewNodeEventSource(node_id, org, pattern, config_state)
 	eventlog := persistence.NewEventLog(severity, message, event_code, persistence.SRC_TYPE_NODE, source)
-	return persistence.SaveEventLog(db, eventlog)
+	return saveEventLog(db, eventlog)
 }
 
 // Save the database eventlog into the db
-func LogDatabaseEvent(db *bolt.DB, severity, message, event_code string) error {
+func LogDatabaseEvent(db persistence.EdgeDatabase, severity, message, event_code string) error {
 	source := persistence.NewDatabaseEventSource()
 	eventlog := persistence.NewEventLog(severity, message, event_code, persistence.SRC_TYPE_DB, source)
-	return persistence.SaveEventLog(db, eventlog)
+	return saveEventLog(db, eventlog)
 }
 
 // Save the database eventlog into the db
-func LogExchangeEvent(db *bolt.DB, severity, message, event_code, exchange_url string) error {
+func LogExchangeEvent(db persistence.EdgeDatabase, severity, message, event_code, exchange_url string) error {
 	source := persistence.NewExchangeEventSource(exchange_url)
 	eventlog := persistence.NewEventLog(severity, message, event_code, persistence.SRC_TYPE_EXCH, source)
-	return persistence.SaveEventLog(db, eventlog)
+	return saveEventLog(db, eventlog)
 }
 
 // Get event logs from the db.
@@ -84,6 +95,11 @@ func LogExchangeEvent(db *bolt.DB, severity, message, event_code, exchange_url s
 //		"message": [{"~", "agreement"}, {"~", "service"}],
 //		"agreement_id": [{"=", c47db9ec232ae4b32c98c08579efcc420aa7652e5fe23d04289c8315c17a04ab}]
 //   }
-func GetEventLogs(db *bolt.DB, all_logs bool, selectors map[string][]persistence.Selector) ([]persistence.EventLog, error) {
+func GetEventLogs(db persistence.EdgeDatabase, all_logs bool, selectors map[string][]persistence.Selector) ([]persistence.EventLog, error) {
 	return persistence.FindEventLogsWithSelectors(db, all_logs, selectors)
 }
+
+// Remove the event logs from the db that are outside of the retention limits.
+func PruneEventLogs(db persistence.EdgeDatabase, retention config.EventLogRetentionConfig) (*persistence.EventLogPruneStatus, error) {
+	return persistence.PruneEventLogs(db, retention, uint64(time.Now().Unix()))
+}
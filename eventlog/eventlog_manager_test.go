@@ -4,14 +4,12 @@ package eventlog
 
 import (
 	"flag"
-	"github.com/boltdb/bolt"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"os"
 	"path"
 	"testing"
-	"time"
 )
 
 func init() {
@@ -504,13 +502,13 @@ func Test_LogExchangeEvent(t *testing.T) {
 
 }
 
-func utsetup() (string, *bolt.DB, error) {
+func utsetup() (string, persistence.EdgeDatabase, error) {
 	dir, err := ioutil.TempDir("", "utdb-")
 	if err != nil {
 		return "", nil, err
 	}
 
-	db, err := bolt.Open(path.Join(dir, "anax-int.db"), 0600, &bolt.Options{Timeout: 10 * time.Second})
+	db, err := persistence.OpenBoltDatabase(path.Join(dir, "anax-int.db"))
 	if err != nil {
 		return dir, nil, err
 	}
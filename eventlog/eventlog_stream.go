@@ -0,0 +1,66 @@
+package eventlog
+
+import (
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/persistence"
+	"sync"
+)
+
+// Event logs are pushed to the subscribers as they are saved, so that the API can stream them to a client that is
+// following the event log. A subscriber that falls too far behind is dropped and its channel is closed, because
+// saving an event log must never wait for a client.
+
+const SUBSCRIBER_QUEUE_SIZE = 100
+
+type Subscription struct {
+	id   int
+	Logs chan persistence.EventLog
+}
+
+type subscriberList struct {
+	lock   sync.Mutex
+	nextId int
+	subs   map[int]*Subscription
+}
+
+var subscribers = subscriberList{subs: make(map[int]*Subscription)}
+
+// Start receiving the event logs saved from now on.
+func Subscribe() *Subscription {
+	subscribers.lock.Lock()
+	defer subscribers.lock.Unlock()
+
+	subscribers.nextId++
+	s := &Subscription{
+		id:   subscribers.nextId,
+		Logs: make(chan persistence.EventLog, SUBSCRIBER_QUEUE_SIZE),
+	}
+	subscribers.subs[s.id] = s
+	return s
+}
+
+// Stop receiving event logs. It is safe to call this for a subscription that has already been dropped.
+func Unsubscribe(s *Subscription) {
+	subscribers.lock.Lock()
+	defer subscribers.lock.Unlock()
+
+	if _, ok := subscribers.subs[s.id]; ok {
+		delete(subscribers.subs, s.id)
+		close(s.Logs)
+	}
+}
+
+func publish(el persistence.EventLog) {
+	subscribers.lock.Lock()
+	defer subscribers.lock.Unlock()
+
+	for id, s := range subscribers.subs {
+		select {
+		case s.Logs <- el:
+		default:
+			glog.Warningf("Event log subscriber %v is not keeping up, dropping it.", id)
+			delete(subscribers.subs, id)
+			close(s.Logs)
+		}
+	}
+}
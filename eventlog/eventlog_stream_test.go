@@ -0,0 +1,55 @@
+// +build unit
+
+package eventlog
+
+import (
+	"github.com/open-horizon/anax/persistence"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Subscribe(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	sub := Subscribe()
+
+	// Each saved event log is pushed with its record id.
+	if err := LogDatabaseEvent(db, persistence.SEVERITY_INFO, "first", persistence.EC_DATABASE_ERROR); err != nil {
+		t.Errorf("error saving event log: %v", err)
+	} else if err := LogExchangeEvent(db, persistence.SEVERITY_ERROR, "second", persistence.EC_EXCHANGE_ERROR, "http://exchange"); err != nil {
+		t.Errorf("error saving event log: %v", err)
+	}
+
+	el := <-sub.Logs
+	assert.Equal(t, "1", el.Id)
+	assert.Equal(t, "first", el.Message)
+	el = <-sub.Logs
+	assert.Equal(t, "2", el.Id)
+	assert.Equal(t, persistence.SRC_TYPE_EXCH, el.SourceType)
+
+	Unsubscribe(sub)
+	_, ok := <-sub.Logs
+	assert.False(t, ok, "The channel should be closed.")
+
+	// A subscriber that does not read is dropped instead of blocking the save.
+	slow := Subscribe()
+	for i := 0; i <= SUBSCRIBER_QUEUE_SIZE; i++ {
+		if err := LogDatabaseEvent(db, persistence.SEVERITY_INFO, "message", persistence.EC_DATABASE_ERROR); err != nil {
+			t.Errorf("error saving event log: %v", err)
+		}
+	}
+
+	count := 0
+	for range slow.Logs {
+		count++
+	}
+	assert.Equal(t, SUBSCRIBER_QUEUE_SIZE, count)
+
+	// Unsubscribing after being dropped is harmless.
+	Unsubscribe(slow)
+}
@@ -0,0 +1,345 @@
+package eventlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// Every event log that is saved is also forwarded to the export sinks in the Edge EventLogExport config. Each sink
+// has its own goroutine so that a slow or unreachable sink does not hold up the others, or the code that saved the
+// event log. When a sink can't be reached, its event logs are kept in a bounded queue that is saved on disk, so they
+// survive a restart and are sent in order when the sink comes back. A syslog sink over udp never fails to send, so
+// its event logs are never queued, and are lost while the syslog server is down.
+
+const EXPORT_QUEUE_DIR = "eventlog_export"
+const EXPORT_CHANNEL_SIZE = 100
+
+// The order of the severities, used to filter the event logs for a sink.
+var severityRank = map[string]int{
+	persistence.SEVERITY_INFO:  0,
+	persistence.SEVERITY_WARN:  1,
+	persistence.SEVERITY_ERROR: 2,
+}
+
+type sinkExporter struct {
+	sink        EventLogSink
+	minSeverity int
+	logs        chan persistence.EventLogRaw
+	queue       *exportQueue
+	retry       time.Duration
+}
+
+var exporters []*sinkExporter
+
+// Create the export sinks in the config and start forwarding the event logs to them. A sink that is not configured
+// correctly is skipped, the error for it is returned after the others have been started.
+func StartExport(cfg *config.HorizonConfig) error {
+
+	ec := cfg.Edge.EventLogExport
+	if len(ec.Sinks) == 0 {
+		return nil
+	}
+
+	queueDir := ec.QueueDir
+	if queueDir == "" {
+		queueDir = path.Join(cfg.Edge.DBPath, EXPORT_QUEUE_DIR)
+	}
+	if err := os.MkdirAll(queueDir, 0700); err != nil {
+		return errors.New(fmt.Sprintf("unable to create event log export queue directory %v, error: %v", queueDir, err))
+	}
+
+	errs := make([]string, 0)
+	names := make(map[string]bool)
+	for _, sc := range ec.Sinks {
+		if names[sc.Name] {
+			errs = append(errs, fmt.Sprintf("event log sink name %v is used more than once", sc.Name))
+			continue
+		}
+		names[sc.Name] = true
+
+		if se, err := newSinkExporter(sc, queueDir, ec.QueueMaxCount, ec.RetryIntervalS); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			exporters = append(exporters, se)
+			go se.run()
+			glog.V(3).Infof("Exporting event logs of severity %v and higher to %v sink %v", sc.MinSeverity, sc.Type, sc.Name)
+		}
+	}
+
+	if len(errs) != 0 {
+		return errors.New(fmt.Sprintf("unable to start event log export sinks: %v", errs))
+	}
+	return nil
+}
+
+func newSinkExporter(sc config.EventLogSinkConfig, queueDir string, queueMax int, retryS int) (*sinkExporter, error) {
+	minSeverity := 0
+	if sc.MinSeverity != "" {
+		if r, ok := severityRank[sc.MinSeverity]; !ok {
+			return nil, errors.New(fmt.Sprintf("event log sink %v has an invalid MinSeverity %v, must be %v, %v or %v", sc.Name, sc.MinSeverity, persistence.SEVERITY_INFO, persistence.SEVERITY_WARN, persistence.SEVERITY_ERROR))
+		} else {
+			minSeverity = r
+		}
+	}
+
+	sink, err := NewEventLogSink(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := newExportQueue(path.Join(queueDir, sc.Name+".queue"), queueMax)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sinkExporter{
+		sink:        sink,
+		minSeverity: minSeverity,
+		logs:        make(chan persistence.EventLogRaw, EXPORT_CHANNEL_SIZE),
+		queue:       queue,
+		retry:       time.Duration(retryS) * time.Second,
+	}, nil
+}
+
+// Called for every saved event log.
+func export(el persistence.EventLog) {
+	if len(exporters) == 0 {
+		return
+	}
+
+	// The sinks and the retry queue work on the serialized form of the event log.
+	var raw persistence.EventLogRaw
+	if serial, err := json.Marshal(el); err != nil {
+		glog.Errorf("Unable to serialize event log %v for export, error: %v", el.Id, err)
+		return
+	} else if err := json.Unmarshal(serial, &raw); err != nil {
+		glog.Errorf("Unable to deserialize event log %v for export, error: %v", el.Id, err)
+		return
+	}
+
+	for _, se := range exporters {
+		if severityRank[raw.Severity] < se.minSeverity {
+			continue
+		}
+		select {
+		case se.logs <- raw:
+		default:
+			// The sink is too far behind, so the event log goes straight to the retry queue.
+			se.queue.add(raw)
+		}
+	}
+}
+
+func (se *sinkExporter) run() {
+	ticker := time.NewTicker(se.retry)
+	defer ticker.Stop()
+
+	se.flush()
+	for {
+		select {
+		case el := <-se.logs:
+			se.send(el)
+		case <-ticker.C:
+			se.flush()
+		}
+	}
+}
+
+// Send an event log, keeping the order with the event logs that are already waiting to be retried.
+func (se *sinkExporter) send(el persistence.EventLogRaw) {
+	if se.queue.len() != 0 {
+		se.flush()
+	}
+	if se.queue.len() != 0 {
+		se.queue.add(el)
+	} else if err := se.sink.Write(el); err != nil {
+		glog.Warningf("Unable to export event log %v to sink %v, it will be retried, error: %v", el.Id, se.sink.Name(), err)
+		se.queue.add(el)
+	}
+}
+
+// Send the queued event logs, stopping at the first one that fails.
+func (se *sinkExporter) flush() {
+	sent := 0
+	for {
+		el, ok := se.queue.peek()
+		if !ok {
+			break
+		} else if err := se.sink.Write(el); err != nil {
+			glog.V(3).Infof("Unable to export %v queued event logs to sink %v, error: %v", se.queue.len(), se.sink.Name(), err)
+			break
+		}
+		se.queue.remove(el)
+		sent++
+	}
+	if sent != 0 {
+		glog.V(3).Infof("Exported %v queued event logs to sink %v", sent, se.sink.Name())
+	}
+}
+
+// ==================================================================================================================
+// The event logs waiting to be sent to a sink. The queue is saved as a journal with one json record per line, either
+// an event log added to the end of the queue or the number of event logs removed from the front, so that each change
+// is a small append to the file. The file is rewritten with just the event logs in the queue when it is loaded, when
+// the queue becomes empty, and when the journal has grown well past the size of the queue.
+
+const EXPORT_QUEUE_COMPACT_RECORDS = 100
+
+type exportQueueRecord struct {
+	Log     *persistence.EventLogRaw `json:"log,omitempty"`
+	Removed int                      `json:"removed,omitempty"`
+}
+
+type exportQueue struct {
+	lock    sync.Mutex
+	file    string
+	max     int
+	logs    []persistence.EventLogRaw
+	records int // the number of records in the file
+	dropped int
+}
+
+func newExportQueue(file string, max int) (*exportQueue, error) {
+	q := &exportQueue{
+		file: file,
+		max:  max,
+		logs: make([]persistence.EventLogRaw, 0),
+	}
+
+	if serial, err := ioutil.ReadFile(file); os.IsNotExist(err) {
+		return q, nil
+	} else if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read event log export queue %v, error: %v", file, err))
+	} else {
+		for ix, line := range bytes.Split(serial, []byte("\n")) {
+			var rec exportQueueRecord
+			if len(line) == 0 {
+				continue
+			} else if err := json.Unmarshal(line, &rec); err != nil {
+				// Only the last record can be partly written, by a crash in the middle of an append.
+				glog.Errorf("Unable to deserialize record %v of event log export queue %v, error: %v", ix, file, err)
+			} else if rec.Log != nil {
+				q.logs = append(q.logs, *rec.Log)
+			} else if rec.Removed >= len(q.logs) {
+				q.logs = q.logs[:0]
+			} else {
+				q.logs = q.logs[rec.Removed:]
+			}
+		}
+	}
+
+	if q.max > 0 && len(q.logs) > q.max {
+		q.logs = q.logs[len(q.logs)-q.max:]
+	}
+	q.compact()
+
+	glog.V(3).Infof("Loaded %v event logs from export queue %v", len(q.logs), file)
+	return q, nil
+}
+
+func (q *exportQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.logs)
+}
+
+// Add an event log to the end of the queue. When the queue is full the oldest event log is dropped.
+func (q *exportQueue) add(el persistence.EventLogRaw) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	records := []exportQueueRecord{exportQueueRecord{Log: &el}}
+	q.logs = append(q.logs, el)
+	if q.max > 0 && len(q.logs) > q.max {
+		drop := len(q.logs) - q.max
+		q.dropped += drop
+		glog.Warningf("Event log export queue %v is full, dropped the oldest event log, %v dropped so far.", q.file, q.dropped)
+		q.logs = q.logs[drop:]
+		records = append(records, exportQueueRecord{Removed: drop})
+	}
+	q.save(records...)
+}
+
+func (q *exportQueue) peek() (persistence.EventLogRaw, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.logs) == 0 {
+		return persistence.EventLogRaw{}, false
+	}
+	return q.logs[0], true
+}
+
+// Remove the event log from the front of the queue, unless it has already been dropped to make room.
+func (q *exportQueue) remove(el persistence.EventLogRaw) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.logs) != 0 && q.logs[0].Id == el.Id {
+		q.logs = q.logs[1:]
+		q.save(exportQueueRecord{Removed: 1})
+	}
+}
+
+// Append the records to the file, or rewrite it when the journal is mostly records of event logs that are gone. Called
+// with the lock held.
+func (q *exportQueue) save(records ...exportQueueRecord) {
+	if len(q.logs) == 0 || q.records+len(records) > 2*len(q.logs)+EXPORT_QUEUE_COMPACT_RECORDS {
+		q.compact()
+		return
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range records {
+		if serial, err := json.Marshal(rec); err != nil {
+			glog.Errorf("Unable to serialize event log export queue %v record, error: %v", q.file, err)
+			return
+		} else {
+			buf.Write(serial)
+			buf.WriteByte('\n')
+		}
+	}
+
+	if f, err := os.OpenFile(q.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err != nil {
+		glog.Errorf("Unable to open event log export queue %v, error: %v", q.file, err)
+	} else {
+		defer f.Close()
+		if _, err := f.Write(buf.Bytes()); err != nil {
+			glog.Errorf("Unable to write event log export queue %v, error: %v", q.file, err)
+		} else {
+			q.records += len(records)
+		}
+	}
+}
+
+// Rewrite the file with just the event logs in the queue. Called with the lock held.
+func (q *exportQueue) compact() {
+	var buf bytes.Buffer
+	for ix := range q.logs {
+		if serial, err := json.Marshal(exportQueueRecord{Log: &q.logs[ix]}); err != nil {
+			glog.Errorf("Unable to serialize event log export queue %v, error: %v", q.file, err)
+			return
+		} else {
+			buf.Write(serial)
+			buf.WriteByte('\n')
+		}
+	}
+
+	if err := ioutil.WriteFile(q.file+".tmp", buf.Bytes(), 0600); err != nil {
+		glog.Errorf("Unable to write event log export queue %v, error: %v", q.file, err)
+	} else if err := os.Rename(q.file+".tmp", q.file); err != nil {
+		glog.Errorf("Unable to save event log export queue %v, error: %v", q.file, err)
+	} else {
+		q.records = len(q.logs)
+	}
+}
@@ -0,0 +1,330 @@
+package eventlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// A place that event logs are exported to.
+type EventLogSink interface {
+	Name() string
+	Write(el persistence.EventLogRaw) error
+	Close() error
+}
+
+// Create the sink described by the config.
+func NewEventLogSink(sc config.EventLogSinkConfig) (EventLogSink, error) {
+	if sc.Name == "" {
+		return nil, errors.New(fmt.Sprintf("event log sink of type %v must have a name", sc.Type))
+	} else if strings.ContainsAny(sc.Name, `/\`) || sc.Name == "." || sc.Name == ".." {
+		// The name is used as the file name of the sink's retry queue.
+		return nil, errors.New(fmt.Sprintf("event log sink name %v must not contain a path separator", sc.Name))
+	}
+
+	switch sc.Type {
+	case config.EVENTLOG_SINK_SYSLOG:
+		return NewSyslogSink(sc)
+	case config.EVENTLOG_SINK_FILE:
+		return NewFileSink(sc)
+	case config.EVENTLOG_SINK_HTTP:
+		return NewHTTPSink(sc)
+	default:
+		return nil, errors.New(fmt.Sprintf("event log sink %v has an unsupported type %v, must be %v, %v or %v", sc.Name, sc.Type, config.EVENTLOG_SINK_SYSLOG, config.EVENTLOG_SINK_FILE, config.EVENTLOG_SINK_HTTP))
+	}
+}
+
+// ==================================================================================================================
+// Sends RFC5424 syslog messages over udp or tcp. The event code is the MSGID, the record id and source type are
+// structured data, and the message is the whole event log as json so that the event source is not lost. Over udp a
+// message is sent whether or not the server is listening, so use tcp when event logs must not be lost.
+
+const SYSLOG_APP_NAME = "anax"
+const SYSLOG_SD_ID = "eventlog@32473"
+
+type SyslogSink struct {
+	name     string
+	network  string
+	address  string
+	facility int
+	hostname string
+	conn     net.Conn
+}
+
+func NewSyslogSink(sc config.EventLogSinkConfig) (*SyslogSink, error) {
+	network := sc.Network
+	if network == "" {
+		network = "udp"
+	} else if network != "udp" && network != "tcp" {
+		return nil, errors.New(fmt.Sprintf("syslog event log sink %v has an unsupported network %v, must be udp or tcp", sc.Name, network))
+	}
+	if _, _, err := net.SplitHostPort(sc.Address); err != nil {
+		return nil, errors.New(fmt.Sprintf("syslog event log sink %v has an invalid address %v, error: %v", sc.Name, sc.Address, err))
+	}
+	facility := sc.Facility
+	if facility == 0 {
+		facility = 1
+	} else if facility < 0 || facility > 23 {
+		return nil, errors.New(fmt.Sprintf("syslog event log sink %v has an invalid facility %v, must be between 0 and 23", sc.Name, facility))
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &SyslogSink{
+		name:     sc.Name,
+		network:  network,
+		address:  sc.Address,
+		facility: facility,
+		hostname: hostname,
+	}, nil
+}
+
+func (s *SyslogSink) Name() string {
+	return s.name
+}
+
+func (s *SyslogSink) Write(el persistence.EventLogRaw) error {
+	msg, err := FormatSyslogMessage(el, s.facility, s.hostname)
+	if err != nil {
+		return err
+	}
+
+	if s.conn == nil {
+		if conn, err := net.DialTimeout(s.network, s.address, 10*time.Second); err != nil {
+			return errors.New(fmt.Sprintf("unable to connect to syslog server %v over %v, error: %v", s.address, s.network, err))
+		} else {
+			s.conn = conn
+		}
+	}
+
+	// A tcp stream needs the messages to be framed, RFC6587 octet counting is used.
+	if s.network == "tcp" {
+		msg = fmt.Sprintf("%v %v", len(msg), msg)
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := s.conn.Write([]byte(msg)); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return errors.New(fmt.Sprintf("unable to send to syslog server %v, error: %v", s.address, err))
+	}
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	if s.conn != nil {
+		err := s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Returns the event log as an RFC5424 message.
+func FormatSyslogMessage(el persistence.EventLogRaw, facility int, hostname string) (string, error) {
+
+	// The event log severities map onto the syslog err, warning and informational severities.
+	severity := 6
+	switch el.Severity {
+	case persistence.SEVERITY_ERROR:
+		severity = 3
+	case persistence.SEVERITY_WARN:
+		severity = 4
+	}
+
+	body, err := json.Marshal(el)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("unable to serialize event log %v, error: %v", el.Id, err))
+	}
+
+	timestamp := time.Unix(int64(el.Timestamp), 0).UTC().Format(time.RFC3339)
+
+	return fmt.Sprintf("<%v>1 %v %v %v %v %v [%v record_id=\"%v\" severity=\"%v\" source_type=\"%v\"] %s",
+		facility*8+severity, timestamp, syslogHeaderField(hostname, 255), SYSLOG_APP_NAME, os.Getpid(),
+		syslogHeaderField(el.EventCode, 32), SYSLOG_SD_ID, syslogParamValue(el.Id), syslogParamValue(el.Severity),
+		syslogParamValue(el.SourceType), body), nil
+}
+
+// Header fields are printable ascii without spaces, and have a maximum length.
+func syslogHeaderField(s string, max int) string {
+	f := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if f == "" {
+		return "-"
+	} else if len(f) > max {
+		return f[:max]
+	}
+	return f
+}
+
+// Structured data parameter values must escape '"', '\' and ']'.
+func syslogParamValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// ==================================================================================================================
+// Appends the event logs to a file, one json object per line. When the file reaches its maximum size it is renamed
+// with a .1 suffix, the older files are shifted up by one, and the oldest is removed.
+
+type FileSink struct {
+	name       string
+	file       string
+	maxSize    int64
+	maxBackups int
+}
+
+func NewFileSink(sc config.EventLogSinkConfig) (*FileSink, error) {
+	if sc.File == "" {
+		return nil, errors.New(fmt.Sprintf("file event log sink %v must have a File", sc.Name))
+	}
+	maxSize := sc.MaxSizeMB
+	if maxSize == 0 {
+		maxSize = 10
+	}
+	maxBackups := sc.MaxBackups
+	if maxBackups == 0 {
+		maxBackups = 5
+	}
+	if maxSize < 0 || maxBackups < 0 {
+		return nil, errors.New(fmt.Sprintf("file event log sink %v must not have a negative MaxSizeMB or MaxBackups", sc.Name))
+	}
+
+	return &FileSink{
+		name:       sc.Name,
+		file:       sc.File,
+		maxSize:    int64(maxSize) * 1024 * 1024,
+		maxBackups: maxBackups,
+	}, nil
+}
+
+func (s *FileSink) Name() string {
+	return s.name
+}
+
+func (s *FileSink) Write(el persistence.EventLogRaw) error {
+	line, err := json.Marshal(el)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to serialize event log %v, error: %v", el.Id, err))
+	}
+	line = append(line, '\n')
+
+	if fi, err := os.Stat(s.file); err == nil && fi.Size() > 0 && fi.Size()+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(s.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to open event log file %v, error: %v", s.file, err))
+	}
+	defer f.Close()
+
+	if _, err := f.Write(line); err != nil {
+		return errors.New(fmt.Sprintf("unable to write event log file %v, error: %v", s.file, err))
+	}
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if s.maxBackups == 0 {
+		return os.Remove(s.file)
+	}
+
+	os.Remove(fmt.Sprintf("%v.%v", s.file, s.maxBackups))
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%v.%v", s.file, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, fmt.Sprintf("%v.%v", s.file, i+1)); err != nil {
+				return errors.New(fmt.Sprintf("unable to rotate event log file %v, error: %v", from, err))
+			}
+		}
+	}
+	if err := os.Rename(s.file, s.file+".1"); err != nil {
+		return errors.New(fmt.Sprintf("unable to rotate event log file %v, error: %v", s.file, err))
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	return nil
+}
+
+// ==================================================================================================================
+// POSTs each event log as a json object. Any 2xx response means the event log was accepted.
+
+type HTTPSink struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewHTTPSink(sc config.EventLogSinkConfig) (*HTTPSink, error) {
+	if !strings.HasPrefix(sc.URL, "http://") && !strings.HasPrefix(sc.URL, "https://") {
+		return nil, errors.New(fmt.Sprintf("http event log sink %v has an invalid URL %v", sc.Name, sc.URL))
+	}
+	timeout := sc.TimeoutS
+	if timeout <= 0 {
+		timeout = 10
+	}
+
+	return &HTTPSink{
+		name:    sc.Name,
+		url:     sc.URL,
+		headers: sc.Headers,
+		client:  &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}, nil
+}
+
+func (s *HTTPSink) Name() string {
+	return s.name
+}
+
+func (s *HTTPSink) Write(el persistence.EventLogRaw) error {
+	body, err := json.Marshal(el)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to serialize event log %v, error: %v", el.Id, err))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to create request for %v, error: %v", s.url, err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to POST event log to %v, error: %v", s.url, err))
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New(fmt.Sprintf("POST event log to %v returned http code %v", s.url, resp.StatusCode))
+	}
+	return nil
+}
+
+func (s *HTTPSink) Close() error {
+	return nil
+}
@@ -0,0 +1,247 @@
+// +build unit
+
+package eventlog
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A sink that fails until it is told to work, and remembers what it was sent.
+type testSink struct {
+	up   bool
+	sent []string
+}
+
+func (s *testSink) Name() string {
+	return "test"
+}
+
+func (s *testSink) Write(el persistence.EventLogRaw) error {
+	if !s.up {
+		return errors.New("sink is down")
+	}
+	s.sent = append(s.sent, el.Id)
+	return nil
+}
+
+func (s *testSink) Close() error {
+	return nil
+}
+
+func testEventLog(id string, severity string) persistence.EventLogRaw {
+	return persistence.EventLogRaw{
+		EventLogBase: persistence.EventLogBase{
+			Id:         id,
+			Timestamp:  1536861598,
+			Severity:   severity,
+			Message:    "message " + id,
+			EventCode:  persistence.EC_AGREEMENT_REACHED,
+			SourceType: persistence.SRC_TYPE_AG,
+		},
+	}
+}
+
+func Test_FormatSyslogMessage(t *testing.T) {
+
+	el := testEventLog("12", persistence.SEVERITY_ERROR)
+	el.SourceType = `a"b]`
+
+	msg, err := FormatSyslogMessage(el, 1, "my node")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(msg, "<11>1 2018-09-13T17:59:58Z my_node anax "), msg)
+	assert.Contains(t, msg, " agreement_reached [eventlog@32473 record_id=\"12\" severity=\"error\" source_type=\"a\\\"b\\]\"] {")
+	assert.Contains(t, msg, `"message":"message 12"`)
+}
+
+func Test_SyslogSink_udp(t *testing.T) {
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink, err := NewEventLogSink(config.EventLogSinkConfig{Name: "siem", Type: config.EVENTLOG_SINK_SYSLOG, Address: conn.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	assert.Nil(t, sink.Write(testEventLog("1", persistence.SEVERITY_INFO)))
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(buf[:n]), "<14>1 "), string(buf[:n]))
+
+	_, err = NewEventLogSink(config.EventLogSinkConfig{Name: "siem", Type: config.EVENTLOG_SINK_SYSLOG, Network: "unix", Address: "localhost:514"})
+	assert.NotNil(t, err)
+}
+
+func Test_HTTPSink(t *testing.T) {
+
+	received := make([]persistence.EventLogRaw, 0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var el persistence.EventLogRaw
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &el)
+		received = append(received, el)
+		if el.Id == "2" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	sink, err := NewEventLogSink(config.EventLogSinkConfig{Name: "siem", Type: config.EVENTLOG_SINK_HTTP, URL: ts.URL, Headers: map[string]string{"Authorization": "Bearer token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, sink.Write(testEventLog("1", persistence.SEVERITY_INFO)))
+	assert.NotNil(t, sink.Write(testEventLog("2", persistence.SEVERITY_INFO)), "A 503 should be an error.")
+	assert.Equal(t, 2, len(received))
+	assert.Equal(t, "message 1", received[0].Message)
+}
+
+func Test_FileSink_rotate(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "utexport-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "events.json")
+	sink, err := NewFileSink(config.EventLogSinkConfig{Name: "local", Type: config.EVENTLOG_SINK_FILE, File: file, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Make each line fill the file.
+	sink.maxSize = 300
+	for _, id := range []string{"1", "2", "3", "4"} {
+		assert.Nil(t, sink.Write(testEventLog(id, persistence.SEVERITY_INFO)))
+	}
+
+	// The newest is in the file, the two before it are in the backups, and the oldest is gone.
+	for suffix, id := range map[string]string{"": "4", ".1": "3", ".2": "2"} {
+		content, err := ioutil.ReadFile(file + suffix)
+		assert.Nil(t, err)
+		var el persistence.EventLogRaw
+		assert.Nil(t, json.Unmarshal(content, &el))
+		assert.Equal(t, id, el.Id)
+		assert.True(t, strings.HasSuffix(string(content), "}\n"))
+	}
+	_, err = os.Stat(file + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_SinkExporter_retry(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "utexport-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	queueFile := path.Join(dir, "test.queue")
+	queue, err := newExportQueue(queueFile, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &testSink{}
+	se := &sinkExporter{sink: sink, minSeverity: severityRank[persistence.SEVERITY_WARN], logs: make(chan persistence.EventLogRaw, 10), queue: queue}
+
+	// The info event log is filtered out by export.
+	exporters = []*sinkExporter{se}
+	defer func() { exporters = nil }()
+	export(persistence.EventLog{EventLogBase: testEventLog("1", persistence.SEVERITY_INFO).EventLogBase, Source: persistence.NewDatabaseEventSource()})
+	export(persistence.EventLog{EventLogBase: testEventLog("2", persistence.SEVERITY_ERROR).EventLogBase, Source: persistence.NewDatabaseEventSource()})
+	assert.Equal(t, 1, len(se.logs))
+	assert.Equal(t, "2", (<-se.logs).Id)
+
+	// While the sink is down the event logs are queued, and the oldest are dropped when the queue is full.
+	for _, id := range []string{"2", "3", "4", "5"} {
+		se.send(testEventLog(id, persistence.SEVERITY_ERROR))
+	}
+	assert.Equal(t, 3, queue.len())
+	assert.Equal(t, 0, len(sink.sent))
+
+	// The queue is kept on disk, so it survives a restart.
+	reloaded, err := newExportQueue(queueFile, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, reloaded.len())
+	se.queue = reloaded
+
+	// When the sink comes back the queued event logs are sent first, in order.
+	sink.up = true
+	se.send(testEventLog("6", persistence.SEVERITY_ERROR))
+	assert.Equal(t, []string{"3", "4", "5", "6"}, sink.sent)
+	assert.Equal(t, 0, se.queue.len())
+}
+
+func Test_exportQueue_journal(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "utexport-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	queueFile := path.Join(dir, "test.queue")
+	queue, err := newExportQueue(queueFile, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := func() int {
+		serial, err := ioutil.ReadFile(queueFile)
+		assert.Nil(t, err)
+		return strings.Count(string(serial), "\n")
+	}
+
+	// Each change is appended to the file, dropping the oldest event log appends a removed record.
+	queue.add(testEventLog("1", persistence.SEVERITY_ERROR))
+	queue.add(testEventLog("2", persistence.SEVERITY_ERROR))
+	assert.Equal(t, 2, lines())
+	queue.add(testEventLog("3", persistence.SEVERITY_ERROR))
+	assert.Equal(t, 4, lines())
+	queue.remove(testEventLog("2", persistence.SEVERITY_ERROR))
+	assert.Equal(t, 5, lines())
+
+	// Replaying the journal gives the same queue, and the file is rewritten with just the queued event logs.
+	reloaded, err := newExportQueue(queueFile, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, reloaded.len())
+	el, ok := reloaded.peek()
+	assert.True(t, ok)
+	assert.Equal(t, "3", el.Id)
+	assert.Equal(t, 1, lines())
+
+	// The file is emptied when the queue is.
+	reloaded.remove(el)
+	assert.Equal(t, 0, lines())
+}
+
+func Test_NewEventLogSink_name(t *testing.T) {
+
+	for _, name := range []string{"../syslog", "a/b", `a\b`, ".", ".."} {
+		_, err := NewEventLogSink(config.EventLogSinkConfig{Name: name, Type: config.EVENTLOG_SINK_FILE, File: "/tmp/x"})
+		assert.NotNil(t, err, name)
+	}
+}
@@ -55,6 +55,7 @@ const (
 	CANCEL_MICROSERVICE EventId = "CANCEL_MICROSERVICE"
 	NEW_BC_CLIENT       EventId = "NEW_BC_CONTAINER"
 	IMAGE_LOAD_FAILED   EventId = "IMAGE_LOAD_FAILED"
+	IMAGE_NOT_VERIFIED  EventId = "IMAGE_NOT_VERIFIED"
 
 	// policy-related
 	NEW_POLICY     EventId = "NEW_POLICY"
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/events"
@@ -16,12 +15,12 @@ import (
 
 type ExchangeMessageWorker struct {
 	worker.BaseWorker // embedded field
-	db                *bolt.DB
+	db                persistence.EdgeDatabase
 	httpClient        *http.Client
 	pattern           string // device pattern
 }
 
-func NewExchangeMessageWorker(name string, cfg *config.HorizonConfig, db *bolt.DB) *ExchangeMessageWorker {
+func NewExchangeMessageWorker(name string, cfg *config.HorizonConfig, db persistence.EdgeDatabase) *ExchangeMessageWorker {
 
 	var ec *worker.BaseExchangeContext
 	pattern := ""
@@ -56,7 +55,7 @@ func (w *ExchangeMessageWorker) NewEvent(incoming events.Message) {
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
 		case events.UNCONFIGURE_COMPLETE:
-			w.Commands <- worker.NewTerminateCommand("shutdown")
+			w.Commands <- w.TraceCommand(incoming, worker.NewTerminateCommand("shutdown"))
 		}
 
 	default: //nothing
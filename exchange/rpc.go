@@ -10,6 +10,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/metrics"
 	"github.com/open-horizon/anax/policy"
 	"io/ioutil"
 	"net/http"
@@ -22,6 +23,12 @@ import (
 const PATTERN = "pattern"
 const SERVICE = "service"
 
+// Metrics describing the calls made to the exchange.
+var (
+	exchangeCallDuration = metrics.NewHistogramVec("anax_exchange_request_duration_seconds", "Time taken to invoke an exchange API.", nil, "method", "resource")
+	exchangeCallErrors   = metrics.NewCounterVec("anax_exchange_request_errors_total", "Number of exchange API invocations that failed, by type of error.", "method", "resource", "type")
+)
+
 // Helper functions for dealing with exchangeIds that are already prefixed with the org name and then "/".
 func GetOrg(id string) string {
 	if ix := strings.Index(id, "/"); ix < 0 {
@@ -74,10 +81,11 @@ type SearchExchangeMSRequest struct {
 	PropertiesToReturn []string       `json:"propertiesToReturn"`
 	StartIndex         int            `json:"startIndex"`
 	NumEntries         int            `json:"numEntries"`
+	ChangedSince       int64          `json:"changedSince,omitempty"`
 }
 
 func (a SearchExchangeMSRequest) String() string {
-	return fmt.Sprintf("Services: %v, SecondsStale: %v, PropertiesToReturn: %v, StartIndex: %v, NumEntries: %v", a.DesiredServices, a.SecondsStale, a.PropertiesToReturn, a.StartIndex, a.NumEntries)
+	return fmt.Sprintf("Services: %v, SecondsStale: %v, PropertiesToReturn: %v, StartIndex: %v, NumEntries: %v, ChangedSince: %v", a.DesiredServices, a.SecondsStale, a.PropertiesToReturn, a.StartIndex, a.NumEntries, a.ChangedSince)
 }
 
 type SearchResultDevice struct {
@@ -116,10 +124,11 @@ type SearchExchangePatternRequest struct {
 	SecondsStale int      `json:"secondsStale"`
 	StartIndex   int      `json:"startIndex"`
 	NumEntries   int      `json:"numEntries"`
+	ChangedSince int64    `json:"changedSince,omitempty"`
 }
 
 func (a SearchExchangePatternRequest) String() string {
-	return fmt.Sprintf("ServiceURL: %v, SecondsStale: %v, StartIndex: %v, NumEntries: %v", a.ServiceURL, a.SecondsStale, a.StartIndex, a.NumEntries)
+	return fmt.Sprintf("ServiceURL: %v, SecondsStale: %v, StartIndex: %v, NumEntries: %v, ChangedSince: %v", a.ServiceURL, a.SecondsStale, a.StartIndex, a.NumEntries, a.ChangedSince)
 }
 
 type SearchExchangePatternResponse struct {
@@ -637,6 +646,50 @@ func GetOrganization(httpClientFactory *config.HTTPClientFactory, org string, ex
 
 }
 
+// Functions and types for working with users in the exchange
+type User struct {
+	Password    string `json:"password"`
+	Admin       bool   `json:"admin"`
+	Email       string `json:"email"`
+	LastUpdated string `json:"lastUpdated"`
+}
+
+type GetUsersResponse struct {
+	Users     map[string]User `json:"users"`
+	LastIndex int             `json:"lastIndex"`
+}
+
+// Get the definition of a user, using the user's own credentials. This can be used to verify a user's credentials.
+func GetUser(httpClientFactory *config.HTTPClientFactory, org string, user string, exURL string, id string, token string) (*User, error) {
+
+	glog.V(3).Infof(rpclogString(fmt.Sprintf("getting user definition %v/%v", org, user)))
+
+	var resp interface{}
+	resp = new(GetUsersResponse)
+
+	targetURL := fmt.Sprintf("%vorgs/%v/users/%v", exURL, org, user)
+
+	for {
+		if err, tpErr := InvokeExchange(httpClientFactory.NewHTTPClient(nil), "GET", targetURL, id, token, nil, &resp); err != nil {
+			glog.Errorf(rpclogString(fmt.Sprintf(err.Error())))
+			return nil, err
+		} else if tpErr != nil {
+			glog.Warningf(rpclogString(fmt.Sprintf(tpErr.Error())))
+			time.Sleep(10 * time.Second)
+			continue
+		} else {
+			users := resp.(*GetUsersResponse).Users
+			if theUser, ok := users[fmt.Sprintf("%v/%v", org, user)]; !ok {
+				return nil, errors.New(fmt.Sprintf("user %v/%v not found", org, user))
+			} else {
+				glog.V(3).Infof(rpclogString(fmt.Sprintf("found user %v/%v", org, user)))
+				return &theUser, nil
+			}
+		}
+	}
+
+}
+
 // Function and types related to working with patterns
 
 type WorkloadPriority struct {
@@ -733,6 +786,13 @@ type NodeHealth struct {
 	CheckAgreementStatus int `json:"check_agreement_status,omitempty"`     // How often to check that the node agreement entry still exists in the exchange (in seconds)
 }
 
+type MaintenanceWindow struct {
+	Schedule string `json:"schedule"`           // Cron expression for when the window opens
+	Duration int    `json:"duration"`           // How long the window stays open (in seconds)
+	Timezone string `json:"timezone,omitempty"` // The timezone used to interpret the schedule, defaults to UTC
+	Blackout bool   `json:"blackout,omitempty"` // When true, disruptive actions are not permitted while the window is open
+}
+
 type Blockchain struct {
 	Type string `json:"type,omitempty"`         // The type of blockchain
 	Name string `json:"name,omitempty"`         // The name of the blockchain instance in the exchange,it is specific to the value of the type
@@ -754,16 +814,18 @@ type Pattern struct {
 	Public             bool                `json:"public"`
 	Services           []ServiceReference  `json:"services"`
 	AgreementProtocols []AgreementProtocol `json:"agreementProtocols"`
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
 }
 
 func (w Pattern) String() string {
-	return fmt.Sprintf("Owner: %v, Label: %v, Description: %v, Public: %v, Services: %v, AgreementProtocols: %v",
+	return fmt.Sprintf("Owner: %v, Label: %v, Description: %v, Public: %v, Services: %v, AgreementProtocols: %v, MaintenanceWindows: %v",
 		w.Owner,
 		w.Label,
 		w.Description,
 		w.Public,
 		w.Services,
-		w.AgreementProtocols)
+		w.AgreementProtocols,
+		w.MaintenanceWindows)
 }
 
 func (w Pattern) ShortString() string {
@@ -773,13 +835,14 @@ func (w Pattern) ShortString() string {
 		svc_a[i] = wl.ShortString()
 	}
 
-	return fmt.Sprintf("Owner: %v, Label: %v, Description: %v, Public: %v, Services: %v, AgreementProtocols: %v",
+	return fmt.Sprintf("Owner: %v, Label: %v, Description: %v, Public: %v, Services: %v, AgreementProtocols: %v, MaintenanceWindows: %v",
 		w.Owner,
 		w.Label,
 		w.Description,
 		w.Public,
 		svc_a,
-		w.AgreementProtocols)
+		w.AgreementProtocols,
+		w.MaintenanceWindows)
 }
 
 type GetPatternResponse struct {
@@ -935,6 +998,18 @@ func ConvertAgreementProtocol(p *Pattern, pol *policy.Policy) {
 	}
 }
 
+func ConvertMaintenanceWindows(p *Pattern, pol *policy.Policy) {
+	// Copy the pattern's maintenance windows into the policy
+	for _, mw := range p.MaintenanceWindows {
+		pol.MaintenanceWindows = append(pol.MaintenanceWindows, policy.MaintenanceWindow{
+			Schedule: mw.Schedule,
+			Duration: mw.Duration,
+			Timezone: mw.Timezone,
+			Blackout: mw.Blackout,
+		})
+	}
+}
+
 // Common conversion function calls
 func ConvertCommon(p *Pattern, patternId string, dv DataVerification, nodeh NodeHealth, pol *policy.Policy) {
 
@@ -944,6 +1019,8 @@ func ConvertCommon(p *Pattern, patternId string, dv DataVerification, nodeh Node
 
 	ConvertAgreementProtocol(p, pol)
 
+	ConvertMaintenanceWindows(p, pol)
+
 	// Indicate that this is a pattern based policy file. Manually created policy files should not use this field.
 	pol.PatternId = patternId
 
@@ -1020,7 +1097,19 @@ func GetNodeHealthStatus(httpClientFactory *config.HTTPClientFactory, pattern st
 
 // This function is used to invoke an exchange API
 // For GET, the given resp parameter will be untouched when http returns code 404.
-func InvokeExchange(httpClient *http.Client, method string, url string, user string, pw string, params interface{}, resp *interface{}) (error, error) {
+func InvokeExchange(httpClient *http.Client, method string, url string, user string, pw string, params interface{}, resp *interface{}) (reqErr error, tpErr error) {
+
+	// Record the latency and outcome of every call.
+	start := time.Now()
+	resource := exchangeResourceType(url)
+	defer func() {
+		exchangeCallDuration.ObserveSince(start, method, resource)
+		if reqErr != nil {
+			exchangeCallErrors.Inc(method, resource, "request")
+		} else if tpErr != nil {
+			exchangeCallErrors.Inc(method, resource, "transport")
+		}
+	}()
 
 	if len(method) == 0 {
 		return errors.New(fmt.Sprintf("Error invoking exchange, method name must be specified")), nil
@@ -1175,6 +1264,25 @@ func InvokeExchange(httpClient *http.Client, method string, url string, user str
 	}
 }
 
+// Reduce an exchange URL to the type of resource being invoked, so that it can be used as a metric label. For example,
+// <exchange>/orgs/myorg/nodes/mynode/agreements/123 becomes nodes/agreements.
+func exchangeResourceType(url string) string {
+	if ix := strings.Index(url, "?"); ix >= 0 {
+		url = url[:ix]
+	}
+	ix := strings.Index(url, "orgs/")
+	if ix < 0 {
+		return "other"
+	}
+	parts := strings.Split(strings.Trim(url[ix+len("orgs/"):], "/"), "/")
+	if len(parts) < 2 {
+		return "orgs"
+	} else if len(parts) < 4 {
+		return parts[1]
+	}
+	return parts[1] + "/" + parts[3]
+}
+
 func isTransportError(err error) bool {
 	l_error_string := strings.ToLower(err.Error())
 	if strings.Contains(l_error_string, "time") && strings.Contains(l_error_string, "out") {
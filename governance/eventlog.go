@@ -0,0 +1,22 @@
+package governance
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/eventlog"
+)
+
+// This function runs periodically in a separate process. It removes the event logs that are outside of the
+// retention limits configured for the node, so that the event log does not grow without bound.
+func (w *GovernanceWorker) governEventLogs() int {
+
+	glog.V(4).Infof(logString(fmt.Sprintf("governing event logs")))
+
+	if status, err := eventlog.PruneEventLogs(w.db, w.Config.Edge.EventLogRetention); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Error pruning event logs. %v", err)))
+	} else if status.Pruned != 0 {
+		glog.V(3).Infof(logString(fmt.Sprintf("pruned %v event logs, %v remaining", status.Pruned, status.Remaining)))
+	}
+
+	return 0
+}
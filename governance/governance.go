@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/abstractprotocol"
 	"github.com/open-horizon/anax/config"
@@ -45,10 +44,11 @@ const CONTAINER_GOVERNOR = "ContainerGovernor"
 const MICROSERVICE_GOVERNOR = "MicroserviceGovernor"
 const BC_GOVERNOR = "BlockchainGovernor"
 const SERVICE_CONFIGSTATE_GOVERNOR = "ServiceConfigStateGovernor"
+const EVENTLOG_GOVERNOR = "EventLogGovernor"
 
 type GovernanceWorker struct {
 	worker.BaseWorker   // embedded field
-	db                  *bolt.DB
+	db                  persistence.EdgeDatabase
 	devicePattern       string
 	pm                  *policy.PolicyManager
 	producerPH          map[string]producer.ProducerProtocolHandler
@@ -57,7 +57,7 @@ type GovernanceWorker struct {
 	lastSvcUpgradeCheck int64
 }
 
-func NewGovernanceWorker(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager) *GovernanceWorker {
+func NewGovernanceWorker(name string, cfg *config.HorizonConfig, db persistence.EdgeDatabase, pm *policy.PolicyManager) *GovernanceWorker {
 
 	var ec *worker.BaseExchangeContext
 	pattern := ""
@@ -96,7 +96,7 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 	case *events.EdgeConfigCompleteMessage:
 		// Start any services that run without needing an agreement.
 		cmd := w.NewStartAgreementLessServicesCommand()
-		w.Commands <- cmd
+		w.Commands <- w.TraceCommand(incoming, cmd)
 
 	case *events.WorkloadMessage:
 		msg, _ := incoming.(*events.WorkloadMessage)
@@ -106,20 +106,23 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 			glog.Infof(logString(fmt.Sprintf("Begun execution of containers according to agreement %v", msg.AgreementId)))
 
 			cmd := w.NewStartGovernExecutionCommand(msg.Deployment, msg.AgreementProtocol, msg.AgreementId)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		case events.EXECUTION_FAILED:
 			cmd := w.NewCleanupExecutionCommand(msg.AgreementProtocol, msg.AgreementId, w.producerPH[msg.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_CONTAINER_FAILURE), msg.Deployment)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		case events.IMAGE_LOAD_FAILED:
 			cmd := w.NewCleanupExecutionCommand(msg.AgreementProtocol, msg.AgreementId, w.producerPH[msg.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_WL_IMAGE_LOAD_FAILURE), msg.Deployment)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
+		case events.IMAGE_NOT_VERIFIED:
+			cmd := w.NewCleanupExecutionCommand(msg.AgreementProtocol, msg.AgreementId, w.producerPH[msg.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_DIGEST_MISMATCH), msg.Deployment)
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		case events.WORKLOAD_DESTROYED:
 			cmd := w.NewCleanupStatusCommand(msg.AgreementProtocol, msg.AgreementId, STATUS_WORKLOAD_DESTROYED)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 		cmd := w.NewReportDeviceStatusCommand()
-		w.Commands <- cmd
+		w.Commands <- w.TraceCommand(incoming, cmd)
 
 	case *events.TorrentMessage:
 		msg, _ := incoming.(*events.TorrentMessage)
@@ -168,7 +171,7 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 						persistence.EC_ERROR_IMAGE_LOADE,
 						ags[0])
 					cmd := w.NewCleanupExecutionCommand(lc.AgreementProtocol, lc.AgreementId, reason, nil)
-					w.Commands <- cmd
+					w.Commands <- w.TraceCommand(incoming, cmd)
 				}
 			}
 		case *events.ContainerLaunchContext:
@@ -189,7 +192,7 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 					persistence.EC_ERROR_IMAGE_LOADE,
 					"", lc.ServicePathElement.URL, "", lc.ServicePathElement.Version, "", lc.AgreementIds)
 				cmd := w.NewUpdateMicroserviceCommand(lc.Name, false, microservice.MS_IMAGE_FETCH_FAILED, microservice.DecodeReasonCode(microservice.MS_IMAGE_FETCH_FAILED))
-				w.Commands <- cmd
+				w.Commands <- w.TraceCommand(incoming, cmd)
 			}
 		}
 
@@ -198,49 +201,49 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.AGREEMENT_ENDED:
 			cmd := w.NewCleanupExecutionCommand(msg.AgreementProtocol, msg.AgreementId, msg.Reason, msg.Deployment)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 	case *events.ApiAgreementCancelationMessage:
 		msg, _ := incoming.(*events.ApiAgreementCancelationMessage)
 		switch msg.Event().Id {
 		case events.AGREEMENT_ENDED:
 			cmd := w.NewCleanupExecutionCommand(msg.AgreementProtocol, msg.AgreementId, w.producerPH[msg.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_USER_REQUESTED), msg.Deployment)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 	case *events.BlockchainClientInitializedMessage:
 		msg, _ := incoming.(*events.BlockchainClientInitializedMessage)
 		switch msg.Event().Id {
 		case events.BC_CLIENT_INITIALIZED:
 			cmd := producer.NewBCInitializedCommand(msg)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 	case *events.BlockchainClientStoppingMessage:
 		msg, _ := incoming.(*events.BlockchainClientStoppingMessage)
 		switch msg.Event().Id {
 		case events.BC_CLIENT_STOPPING:
 			cmd := producer.NewBCStoppingCommand(msg)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 	case *events.AccountFundedMessage:
 		msg, _ := incoming.(*events.AccountFundedMessage)
 		switch msg.Event().Id {
 		case events.ACCOUNT_FUNDED:
 			cmd := producer.NewBCWritableCommand(msg)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 	case *events.EthBlockchainEventMessage:
 		msg, _ := incoming.(*events.EthBlockchainEventMessage)
 		switch msg.Event().Id {
 		case events.BC_EVENT:
 			cmd := producer.NewBlockchainEventCommand(*msg)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 	case *events.ExchangeDeviceMessage:
 		msg, _ := incoming.(*events.ExchangeDeviceMessage)
 		switch msg.Event().Id {
 		case events.RECEIVED_EXCHANGE_DEV_MSG:
 			cmd := producer.NewExchangeMessageCommand(*msg)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 	case *events.ContainerMessage:
@@ -249,17 +252,20 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 			switch msg.Event().Id {
 			case events.EXECUTION_BEGUN:
 				cmd := w.NewUpdateMicroserviceCommand(msg.LaunchContext.Name, true, 0, "")
-				w.Commands <- cmd
+				w.Commands <- w.TraceCommand(incoming, cmd)
 			case events.EXECUTION_FAILED:
 				cmd := w.NewUpdateMicroserviceCommand(msg.LaunchContext.Name, false, microservice.MS_EXEC_FAILED, microservice.DecodeReasonCode(microservice.MS_EXEC_FAILED))
-				w.Commands <- cmd
+				w.Commands <- w.TraceCommand(incoming, cmd)
 			case events.IMAGE_LOAD_FAILED:
 				cmd := w.NewUpdateMicroserviceCommand(msg.LaunchContext.Name, false, microservice.MS_IMAGE_LOAD_FAILED, microservice.DecodeReasonCode(microservice.MS_IMAGE_LOAD_FAILED))
-				w.Commands <- cmd
+				w.Commands <- w.TraceCommand(incoming, cmd)
+			case events.IMAGE_NOT_VERIFIED:
+				cmd := w.NewUpdateMicroserviceCommand(msg.LaunchContext.Name, false, microservice.MS_IMAGE_NOT_VERIFIED, microservice.DecodeReasonCode(microservice.MS_IMAGE_NOT_VERIFIED))
+				w.Commands <- w.TraceCommand(incoming, cmd)
 			}
 
 			cmd := w.NewReportDeviceStatusCommand()
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 	case *events.MicroserviceContainersDestroyedMessage:
 		msg, _ := incoming.(*events.MicroserviceContainersDestroyedMessage)
@@ -267,23 +273,23 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.CONTAINER_DESTROYED:
 			cmd := w.NewUpdateMicroserviceCommand(msg.MsInstKey, false, 0, "")
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 		cmd := w.NewReportDeviceStatusCommand()
-		w.Commands <- cmd
+		w.Commands <- w.TraceCommand(incoming, cmd)
 
 	case *events.NodeShutdownMessage:
 
 		msg, _ := incoming.(*events.NodeShutdownMessage)
 		cmd := w.NewNodeShutdownCommand(msg)
-		w.Commands <- cmd
+		w.Commands <- w.TraceCommand(incoming, cmd)
 
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
 		case events.UNCONFIGURE_COMPLETE:
-			w.Commands <- worker.NewTerminateCommand("shutdown")
+			w.Commands <- w.TraceCommand(incoming, worker.NewTerminateCommand("shutdown"))
 		}
 
 	case *events.NodeHeartbeatStateChangeMessage:
@@ -291,7 +297,7 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.NODE_HEARTBEAT_RESTORED:
 			cmd := w.NewNodeHeartbeatRestoredCommand()
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 	case *events.ServiceConfigStateChangeMessage:
@@ -299,7 +305,7 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.SERVICE_SUSPENDED:
 			cmd := w.NewServiceSuspendedCommand(msg.ServiceConfigState)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 	default: //nothing
@@ -621,6 +627,11 @@ func (w *GovernanceWorker) Initialize() bool {
 		w.DispatchSubworker(SERVICE_CONFIGSTATE_GOVERNOR, w.governServiceConfigState, w.Config.Edge.ServiceConfigStateCheckIntervalS)
 	}
 
+	// Fire up the event log governor if the event logs have a retention policy.
+	if w.Config.Edge.EventLogRetention.IsEnabled() {
+		w.DispatchSubworker(EVENTLOG_GOVERNOR, w.governEventLogs, w.Config.Edge.EventLogRetention.PruneIntervalS)
+	}
+
 	return true
 
 }
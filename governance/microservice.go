@@ -291,6 +291,8 @@ func (w *GovernanceWorker) CleanupMicroservice(spec_ref string, version string,
 				ag_reason_code = w.producerPH[ag.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_MS_DOWNGRADE_REQUIRED)
 			case microservice.MS_IMAGE_FETCH_FAILED:
 				ag_reason_code = w.producerPH[ag.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_FETCH_FAILURE)
+			case microservice.MS_IMAGE_NOT_VERIFIED:
+				ag_reason_code = w.producerPH[ag.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_IMAGE_DIGEST_MISMATCH)
 			default:
 				ag_reason_code = w.producerPH[ag.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_MICROSERVICE_FAILURE)
 			}
@@ -760,6 +762,8 @@ func (w *GovernanceWorker) handleMicroserviceUpgrade(msdef_id string) {
 			glog.Errorf(logString(fmt.Sprintf("Error finding the new service definition to upgrade to for %v/%v version %v. %v", msdef.Org, msdef.SpecRef, msdef.Version, err)))
 		} else if new_msdef == nil {
 			glog.V(5).Infof(logString(fmt.Sprintf("No changes for service definition %v/%v, no need to upgrade.", msdef.Org, msdef.SpecRef)))
+		} else if w.deferMicroserviceUpgrade(msdef, new_msdef) {
+			glog.V(3).Infof(logString(fmt.Sprintf("Upgrade of service %v/%v version %v is deferred until %v.", msdef.Org, msdef.SpecRef, msdef.Version, time.Unix(int64(msdef.UpgradeDeferredUntil), 0))))
 		} else {
 			eventlog.LogServiceEvent2(w.db, persistence.SEVERITY_INFO,
 				fmt.Sprintf("Start upgrading service %v/%v from version %v to version %v.", msdef.Org, msdef.SpecRef, msdef.Version, new_msdef.Version),
@@ -795,6 +799,98 @@ func (w *GovernanceWorker) handleMicroserviceUpgrade(msdef_id string) {
 	}
 }
 
+// Check whether the maintenance windows allow the service to be upgraded now. If they don't, the time at which they will is
+// saved in the service definition, where it shows up in the service status, and true is returned. The upgrade is checked
+// again on the next service upgrade interval. When the windows cannot be evaluated the upgrade is deferred for a while and
+// the error is recorded in the event log.
+func (w *GovernanceWorker) deferMicroserviceUpgrade(msdef *persistence.MicroserviceDefinition, new_msdef *persistence.MicroserviceDefinition) bool {
+
+	until := uint64(0)
+	now := time.Now()
+	if windows, err := w.getMicroserviceMaintenanceWindows(msdef); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to get the maintenance windows for service %v/%v, not deferring the upgrade, error: %v", msdef.Org, msdef.SpecRef, err)))
+	} else if len(windows) == 0 {
+		// Nothing restricts the upgrade.
+	} else if next, err := windows.NextAllowed(now); err != nil {
+		until = uint64(now.Add(policy.MaintenanceWindowRetry).Unix())
+		eventlog.LogServiceEvent2(w.db, persistence.SEVERITY_ERROR,
+			fmt.Sprintf("Unable to evaluate the maintenance windows for service %v/%v, deferring the upgrade until %v, error: %v", msdef.Org, msdef.SpecRef, time.Unix(int64(until), 0), err),
+			persistence.EC_DEFER_UPGRADE_SERVICE,
+			"", msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch, []string{})
+		glog.Errorf(logString(fmt.Sprintf("unable to evaluate the maintenance windows for service %v/%v, deferring the upgrade, error: %v", msdef.Org, msdef.SpecRef, err)))
+	} else if next.After(now) {
+		until = uint64(next.Unix())
+	}
+
+	if until != msdef.UpgradeDeferredUntil {
+		if _, err := persistence.MSDefUpgradeDeferred(w.db, msdef.Id, until); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("error saving the upgrade deferral for service %v/%v version %v key %v. %v", msdef.Org, msdef.SpecRef, msdef.Version, msdef.Id, err)))
+		}
+		if until != 0 {
+			eventlog.LogServiceEvent2(w.db, persistence.SEVERITY_INFO,
+				fmt.Sprintf("Upgrade of service %v/%v from version %v to version %v is deferred until %v by the maintenance windows.", msdef.Org, msdef.SpecRef, msdef.Version, new_msdef.Version, time.Unix(int64(until), 0)),
+				persistence.EC_DEFER_UPGRADE_SERVICE,
+				"", msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch, []string{})
+		}
+		msdef.UpgradeDeferredUntil = until
+	}
+
+	return until != 0
+}
+
+// Get the maintenance windows that govern upgrading a service. The node's maintenance attribute for the service, or the
+// node wide one when the service does not have its own, is combined with the windows in the terms and conditions of the
+// agreements that are using the service.
+func (w *GovernanceWorker) getMicroserviceMaintenanceWindows(msdef *persistence.MicroserviceDefinition) (policy.MaintenanceWindowList, error) {
+
+	var common_windows, specific_windows interface{}
+	if attributes, err := persistence.FindApplicableAttributes(w.db, msdef.SpecRef, msdef.Org); err != nil {
+		return nil, fmt.Errorf("unable to get the service attributes from db, error %v", err)
+	} else {
+		for _, attr := range attributes {
+			if mw, ok := attr.(persistence.MaintenanceAttributes); !ok {
+				continue
+			} else if serviceSpecs := persistence.GetAttributeServiceSpecs(&attr); serviceSpecs == nil || len(*serviceSpecs) == 0 {
+				common_windows = mw.Windows
+			} else {
+				specific_windows = mw.Windows
+			}
+		}
+	}
+	if specific_windows != nil {
+		common_windows = specific_windows
+	}
+
+	windows, err := policy.ConvertToMaintenanceWindowList(common_windows)
+	if err != nil {
+		return nil, err
+	}
+
+	ms_insts, err := persistence.FindMicroserviceInstances(w.db, []persistence.MIFilter{persistence.AllInstancesMIFilter(msdef.SpecRef, msdef.Org, msdef.Version), persistence.UnarchivedMIFilter()})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the service instances from db, error %v", err)
+	}
+
+	for _, msi := range ms_insts {
+		if ags, err := w.FindEstablishedAgreementsWithIds(msi.AssociatedAgreements); err != nil {
+			return nil, fmt.Errorf("unable to retrieve agreements %v from database, error %v", msi.AssociatedAgreements, err)
+		} else {
+			for _, ag := range ags {
+				protocolHandler := w.producerPH[ag.AgreementProtocol].AgreementProtocolHandler("", "", "")
+				if proposal, err := protocolHandler.DemarshalProposal(ag.Proposal); err != nil {
+					return nil, fmt.Errorf("could not hydrate proposal, error: %v", err)
+				} else if tcPolicy, err := policy.DemarshalPolicy(proposal.TsAndCs()); err != nil {
+					return nil, fmt.Errorf("error demarshalling TsAndCs policy for agreement %v, error %v", ag.CurrentAgreementId, err)
+				} else {
+					(&windows).Concatenate(&tcPolicy.MaintenanceWindows)
+				}
+			}
+		}
+	}
+
+	return windows, nil
+}
+
 // get the service configuration state from the exchange, check if any of them are suspended.
 // if a service is suspended, cancel the agreements and remove the containers associated with it.
 func (w *GovernanceWorker) governServiceConfigState() int {
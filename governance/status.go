@@ -7,6 +7,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/container"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/helm"
@@ -22,14 +23,16 @@ type ContainerStatus struct {
 	Image   string `json:"image"`
 	Created int64  `json:"created"`
 	State   string `json:"state"`
+	Health  string `json:"health,omitempty"` // only set for containers with a health check
 }
 
 func (w ContainerStatus) String() string {
 	return fmt.Sprintf("Name: %v, "+
 		"Image: %v, "+
 		"Created: %v, "+
-		"State: %v",
-		w.Name, w.Image, w.Created, w.State)
+		"State: %v, "+
+		"Health: %v",
+		w.Name, w.Image, w.Created, w.State, w.Health)
 }
 
 type WorkloadStatus struct {
@@ -96,10 +99,10 @@ func (w *GovernanceWorker) ReportDeviceStatus() {
 
 	// get docker containers
 	containers := make([]docker.APIContainers, 0)
-	if client, err := docker.NewClient(w.Config.Edge.DockerEndpoint); err != nil {
-		glog.Errorf(logString(fmt.Sprintf("Failed to instantiate docker Client: %v", err)))
+	if rt, err := containerruntime.NewRuntime(w.Config); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Failed to instantiate %v container runtime: %v", w.Config.GetContainerRuntime(), err)))
 	} else {
-		containers, err = client.ListContainers(docker.ListContainersOptions{})
+		containers, err = rt.ListContainers(false, nil)
 		if err != nil {
 			glog.Errorf(logString(fmt.Sprintf("Unable to get list of running containers: %v", err)))
 		}
@@ -263,14 +266,15 @@ func GetContainerStatus(deployment string, key string, infrastructure bool, cont
 			container_status.Name = serviceName
 			container_status.Image = s_details.Image
 			container_status.State = "not started"
-			for _, container := range containers {
-				if _, ok := container.Labels[label]; ok {
-					cname := container.Names[0]
+			for _, c := range containers {
+				if _, ok := c.Labels[label]; ok {
+					cname := c.Names[0]
 					if cname == "/"+key+"-"+serviceName {
-						container_status.Name = container.Names[0]
-						container_status.Image = container.Image
-						container_status.Created = container.Created
-						container_status.State = container.State
+						container_status.Name = c.Names[0]
+						container_status.Image = c.Image
+						container_status.Created = c.Created
+						container_status.State = c.State
+						container_status.Health = container.ContainerHealth(&c)
 						break
 					}
 				}
@@ -3,7 +3,6 @@ package helm
 import (
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/events"
@@ -13,10 +12,10 @@ import (
 
 type HelmWorker struct {
 	worker.BaseWorker // embedded field
-	db                *bolt.DB
+	db                persistence.EdgeDatabase
 }
 
-func NewHelmWorker(name string, config *config.HorizonConfig, db *bolt.DB) *HelmWorker {
+func NewHelmWorker(name string, config *config.HorizonConfig, db persistence.EdgeDatabase) *HelmWorker {
 
 	worker := &HelmWorker{
 		BaseWorker: worker.NewBaseWorker(name, config, nil),
@@ -39,7 +38,7 @@ func (w *HelmWorker) NewEvent(incoming events.Message) {
 		msg, _ := incoming.(*events.AgreementReachedMessage)
 
 		fCmd := NewInstallCommand(msg.LaunchContext())
-		w.Commands <- fCmd
+		w.Commands <- w.TraceCommand(incoming, fCmd)
 
 	case *events.GovernanceWorkloadCancelationMessage:
 		msg, _ := incoming.(*events.GovernanceWorkloadCancelationMessage)
@@ -47,7 +46,7 @@ func (w *HelmWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.AGREEMENT_ENDED:
 			cmd := NewUnInstallCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 	case *events.GovernanceMaintenanceMessage:
@@ -56,14 +55,14 @@ func (w *HelmWorker) NewEvent(incoming events.Message) {
 		switch msg.Event().Id {
 		case events.CONTAINER_MAINTAIN:
 			cmd := NewMaintenanceCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
-			w.Commands <- cmd
+			w.Commands <- w.TraceCommand(incoming, cmd)
 		}
 
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
 		case events.UNCONFIGURE_COMPLETE:
-			w.Commands <- worker.NewTerminateCommand("shutdown")
+			w.Commands <- w.TraceCommand(incoming, worker.NewTerminateCommand("shutdown"))
 		}
 
 	default: //nothing
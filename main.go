@@ -3,7 +3,6 @@ package main
 import (
 	"flag"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/agreement"
 	"github.com/open-horizon/anax/agreementbot"
@@ -13,6 +12,7 @@ import (
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/governance"
 	"github.com/open-horizon/anax/helm"
@@ -23,11 +23,9 @@ import (
 	"github.com/open-horizon/anax/worker"
 	"os"
 	"os/signal"
-	"path"
 	"runtime"
 	"runtime/pprof"
 	"syscall"
-	"time"
 )
 
 // The core of anax is an event handling system that distributes events to workers, where the workers
@@ -57,18 +55,37 @@ func main() {
 	glog.V(2).Infof("Using config: %v", cfg)
 	glog.V(2).Infof("GOMAXPROCS: %v", runtime.GOMAXPROCS(-1))
 
+	// turn on event tracing if requested
+	worker.GetEventTracer().Configure(cfg.EventTrace.Enabled, cfg.EventTrace.BufferSize)
+
 	// open edge DB if necessary
-	var db *bolt.DB
+	var db persistence.EdgeDatabase
 	if len(cfg.Edge.DBPath) != 0 {
-		if err := os.MkdirAll(cfg.Edge.DBPath, 0700); err != nil {
-			panic(err)
+
+		// A database restore is staged by the API and applied before the database is opened. A restore that fails
+		// is logged, but the node keeps running on its current database.
+		restored, restoreErr := persistence.ApplyPendingRestore(cfg)
+		if restoreErr != nil {
+			glog.Errorf("Unable to restore the edge database: %v", restoreErr)
 		}
 
-		edgeDB, err := bolt.Open(path.Join(cfg.Edge.DBPath, "anax.db"), 0600, &bolt.Options{Timeout: 10 * time.Second})
+		edgeDB, err := persistence.InitDatabase(cfg)
 		if err != nil {
 			panic(err)
 		}
 		db = edgeDB
+		glog.V(2).Infof("Using %v edge database %v", db.Type(), db.Path())
+
+		// event logs are forwarded to the export sinks from here on
+		if err := eventlog.StartExport(cfg); err != nil {
+			glog.Errorf("Unable to export event logs: %v", err)
+		}
+
+		if restoreErr != nil {
+			eventlog.LogDatabaseEvent(db, persistence.SEVERITY_ERROR, fmt.Sprintf("Unable to restore the database from a backup, error: %v", restoreErr), persistence.EC_DATABASE_RESTORE_REJECTED)
+		} else if restored {
+			eventlog.LogDatabaseEvent(db, persistence.SEVERITY_INFO, "Restored the database from a backup.", persistence.EC_DATABASE_RESTORED)
+		}
 
 	}
 
@@ -104,11 +121,13 @@ func main() {
 		os.Exit(0)
 	}()
 
-	// The anax runtime might have been upgraded an restarted with an existing database. If so, the
-	// device object might need to be upgraded.
+	// If the existing device is using a pattern then we need to turn off agreement tracking when we create the policy manager.
+	// Any upgrade of the device object was done by the schema migrations when the database was initialized.
 	usingPattern := false
-	if usingPattern, err = persistence.MigrateExchangeDevice(db); err != nil {
-		panic(err)
+	if db != nil {
+		if dev, _ := persistence.FindExchangeDevice(db); dev != nil && dev.Pattern != "" {
+			usingPattern = true
+		}
 	}
 
 	// Get the device side policy manager started early so that all the workers can use it.
@@ -0,0 +1,9 @@
+// +build sqlite
+
+package main
+
+// The SQLite edge database requires cgo, so it is only linked into anax when built with the sqlite tag, see
+// the ANAX_SQLITE option in the Makefile.
+import (
+	_ "github.com/open-horizon/anax/persistence/sqlite"
+)
@@ -0,0 +1,423 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// This package provides a small, dependency free implementation of the Prometheus text exposition format. Anax
+// components declare their metrics at package scope using the constructors in this file, and the API listeners
+// (edge and agbot) serve the contents of the default registry on their /metrics path.
+
+const (
+	TYPE_COUNTER   = "counter"
+	TYPE_GAUGE     = "gauge"
+	TYPE_HISTOGRAM = "histogram"
+
+	CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// The default histogram buckets, in seconds. They cover everything from a fast in-memory command to a slow
+// exchange call.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// The registry that all metrics created by the constructors in this package are added to.
+var defaultRegistry = NewRegistry()
+
+func GetDefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// A metric family is a named metric with a fixed set of label names. Each unique combination of label values
+// is a separate series within the family.
+type family interface {
+	Name() string
+	Help() string
+	Type() string
+	write(buf *bytes.Buffer)
+}
+
+// A collector is called just before the registry is written out, so that metrics which are expensive to keep up to
+// date (e.g. counts of database records) can be computed on demand.
+type Collector func()
+
+type Registry struct {
+	families   map[string]family
+	collectors map[string]Collector
+	lock       sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families:   make(map[string]family),
+		collectors: make(map[string]Collector),
+	}
+}
+
+// Add a metric family to the registry. If a family with the same name is already registered, the existing family
+// is returned so that callers can safely register the same metric more than once.
+func (r *Registry) register(f family) family {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if existing, ok := r.families[f.Name()]; ok {
+		return existing
+	}
+	r.families[f.Name()] = f
+	return f
+}
+
+// Register a collector function under the given name. Registering a collector with a name that is already in use
+// replaces the previous collector.
+func (r *Registry) AddCollector(name string, c Collector) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.collectors[name] = c
+}
+
+func (r *Registry) RemoveCollector(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.collectors, name)
+}
+
+// Run the collectors and then write all metric families in the Prometheus text format. Families are written
+// in name order so that the output is stable.
+func (r *Registry) Write(buf *bytes.Buffer) {
+
+	r.lock.Lock()
+	collectors := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.lock.Unlock()
+
+	for _, c := range collectors {
+		c()
+	}
+
+	r.lock.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make([]family, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		families = append(families, r.families[name])
+	}
+	r.lock.Unlock()
+
+	for _, f := range families {
+		buf.WriteString(fmt.Sprintf("# HELP %v %v\n", f.Name(), escapeHelp(f.Help())))
+		buf.WriteString(fmt.Sprintf("# TYPE %v %v\n", f.Name(), f.Type()))
+		f.write(buf)
+	}
+}
+
+// An HTTP handler that serves the contents of the default registry. Both the node and the agbot API listeners
+// route their /metrics path here.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		buf := new(bytes.Buffer)
+		defaultRegistry.Write(buf)
+
+		w.Header().Set("Content-Type", CONTENT_TYPE)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			glog.Errorf(metricsLogString(fmt.Sprintf("unable to write metrics response, error: %v", err)))
+		}
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// ==========================================================================================
+// The label handling that is common to all metric types.
+type labeledFamily struct {
+	name   string
+	help   string
+	labels []string
+	lock   sync.Mutex
+}
+
+func (l *labeledFamily) Name() string {
+	return l.name
+}
+
+func (l *labeledFamily) Help() string {
+	return l.help
+}
+
+// Convert a list of label values into the key used to hold the series, verifying that the caller provided the
+// right number of values.
+func (l *labeledFamily) key(values []string) string {
+	if len(values) != len(l.labels) {
+		glog.Errorf(metricsLogString(fmt.Sprintf("metric %v expects %v label values, got %v", l.name, len(l.labels), values)))
+		fixed := make([]string, len(l.labels))
+		copy(fixed, values)
+		values = fixed
+	}
+	return strings.Join(values, "\xff")
+}
+
+// Format the label set for a series, with an optional extra label (used by histograms for the bucket bound).
+func (l *labeledFamily) formatLabels(key string, extraName string, extraValue string) string {
+	pairs := make([]string, 0, len(l.labels)+1)
+	if len(l.labels) != 0 {
+		for ix, v := range strings.Split(key, "\xff") {
+			pairs = append(pairs, fmt.Sprintf("%v=\"%v\"", l.labels[ix], escapeLabel(v)))
+		}
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%v=\"%v\"", extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ==========================================================================================
+// Counters only ever go up.
+type CounterVec struct {
+	labeledFamily
+	values map[string]float64
+}
+
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		labeledFamily: labeledFamily{name: name, help: help, labels: labels},
+		values:        make(map[string]float64),
+	}
+	return defaultRegistry.register(c).(*CounterVec)
+}
+
+func (c *CounterVec) Type() string {
+	return TYPE_COUNTER
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		glog.Errorf(metricsLogString(fmt.Sprintf("counter %v cannot be decreased by %v", c.name, v)))
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[c.key(labelValues)] += v
+}
+
+func (c *CounterVec) Get(labelValues ...string) float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.values[c.key(labelValues)]
+}
+
+func (c *CounterVec) write(buf *bytes.Buffer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, k := range sortedKeys(c.values) {
+		buf.WriteString(fmt.Sprintf("%v%v %v\n", c.name, c.formatLabels(k, "", ""), formatFloat(c.values[k])))
+	}
+}
+
+// ==========================================================================================
+// Gauges can be set to any value.
+type GaugeVec struct {
+	labeledFamily
+	values map[string]float64
+}
+
+func NewGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{
+		labeledFamily: labeledFamily{name: name, help: help, labels: labels},
+		values:        make(map[string]float64),
+	}
+	return defaultRegistry.register(g).(*GaugeVec)
+}
+
+func (g *GaugeVec) Type() string {
+	return TYPE_GAUGE
+}
+
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.values[g.key(labelValues)] = v
+}
+
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.values[g.key(labelValues)] += v
+}
+
+func (g *GaugeVec) Get(labelValues ...string) float64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.values[g.key(labelValues)]
+}
+
+// Remove all series from the gauge. Collectors use this before recomputing a gauge so that series which no longer
+// exist (e.g. a state with no agreements in it) disappear from the output.
+func (g *GaugeVec) Reset() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.values = make(map[string]float64)
+}
+
+func (g *GaugeVec) write(buf *bytes.Buffer) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for _, k := range sortedKeys(g.values) {
+		buf.WriteString(fmt.Sprintf("%v%v %v\n", g.name, g.formatLabels(k, "", ""), formatFloat(g.values[k])))
+	}
+}
+
+// ==========================================================================================
+// Histograms count observations into cumulative buckets.
+type histogramSeries struct {
+	counts []uint64 // one per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+type HistogramVec struct {
+	labeledFamily
+	buckets []float64
+	series  map[string]*histogramSeries
+}
+
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	b := make([]float64, len(buckets))
+	copy(b, buckets)
+	sort.Float64s(b)
+
+	h := &HistogramVec{
+		labeledFamily: labeledFamily{name: name, help: help, labels: labels},
+		buckets:       b,
+		series:        make(map[string]*histogramSeries),
+	}
+	return defaultRegistry.register(h).(*HistogramVec)
+}
+
+func (h *HistogramVec) Type() string {
+	return TYPE_HISTOGRAM
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	k := h.key(labelValues)
+	s, ok := h.series[k]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+	}
+
+	for ix, bound := range h.buckets {
+		if v <= bound {
+			s.counts[ix]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Observe the time that has elapsed since the input start time, in seconds.
+func (h *HistogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Return the number of observations and their sum for a series.
+func (h *HistogramVec) Get(labelValues ...string) (uint64, float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if s, ok := h.series[h.key(labelValues)]; ok {
+		return s.count, s.sum
+	}
+	return 0, 0
+}
+
+func (h *HistogramVec) write(buf *bytes.Buffer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := h.series[k]
+		cumulative := uint64(0)
+		for ix, bound := range h.buckets {
+			cumulative += s.counts[ix]
+			buf.WriteString(fmt.Sprintf("%v_bucket%v %v\n", h.name, h.formatLabels(k, "le", formatFloat(bound)), cumulative))
+		}
+		buf.WriteString(fmt.Sprintf("%v_bucket%v %v\n", h.name, h.formatLabels(k, "le", "+Inf"), s.count))
+		buf.WriteString(fmt.Sprintf("%v_sum%v %v\n", h.name, h.formatLabels(k, "", ""), formatFloat(s.sum)))
+		buf.WriteString(fmt.Sprintf("%v_count%v %v\n", h.name, h.formatLabels(k, "", ""), s.count))
+	}
+}
+
+// ==========================================================================================
+// Formatting utilities.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func escapeLabel(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	return strings.Replace(s, `"`, `\"`, -1)
+}
+
+func escapeHelp(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return strings.Replace(s, "\n", `\n`, -1)
+}
+
+var metricsLogString = func(v interface{}) string {
+	return fmt.Sprintf("Metrics: %v", v)
+}
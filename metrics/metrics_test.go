@@ -0,0 +1,106 @@
+// +build unit
+
+package metrics
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Counter(t *testing.T) {
+
+	c := NewCounterVec("test_counter_total", "A test counter.", "method")
+	c.Inc("GET")
+	c.Inc("GET")
+	c.Add(3, "POST")
+	c.Add(-1, "POST")
+
+	assert.Equal(t, float64(2), c.Get("GET"), "GET should have been counted twice")
+	assert.Equal(t, float64(3), c.Get("POST"), "Counters should not decrease")
+
+	// Registering the same name again returns the existing counter.
+	c2 := NewCounterVec("test_counter_total", "A test counter.", "method")
+	assert.Equal(t, float64(2), c2.Get("GET"), "The existing counter should have been returned")
+
+	buf := new(bytes.Buffer)
+	defaultRegistry.Write(buf)
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE test_counter_total counter\n")
+	assert.Contains(t, out, "test_counter_total{method=\"GET\"} 2\n")
+	assert.Contains(t, out, "test_counter_total{method=\"POST\"} 3\n")
+}
+
+func Test_Gauge_Collector(t *testing.T) {
+
+	g := NewGaugeVec("test_gauge", "A test gauge.", "state")
+	g.Set(5, "stale")
+
+	r := GetDefaultRegistry()
+	r.AddCollector("test", func() {
+		g.Reset()
+		g.Set(1, "active")
+		g.Set(2, "archived")
+	})
+	defer r.RemoveCollector("test")
+
+	buf := new(bytes.Buffer)
+	r.Write(buf)
+	out := buf.String()
+	assert.Contains(t, out, "test_gauge{state=\"active\"} 1\n")
+	assert.Contains(t, out, "test_gauge{state=\"archived\"} 2\n")
+	assert.NotContains(t, out, "stale", "Reset should have removed the stale series")
+}
+
+func Test_Histogram(t *testing.T) {
+
+	h := NewHistogramVec("test_duration_seconds", "A test histogram.", []float64{1, 0.1}, "worker")
+	h.Observe(0.05, "w1")
+	h.Observe(0.5, "w1")
+	h.Observe(5, "w1")
+
+	count, sum := h.Get("w1")
+	assert.Equal(t, uint64(3), count)
+	assert.Equal(t, 5.55, sum)
+
+	buf := new(bytes.Buffer)
+	defaultRegistry.Write(buf)
+	out := buf.String()
+	assert.Contains(t, out, "test_duration_seconds_bucket{worker=\"w1\",le=\"0.1\"} 1\n")
+	assert.Contains(t, out, "test_duration_seconds_bucket{worker=\"w1\",le=\"1\"} 2\n")
+	assert.Contains(t, out, "test_duration_seconds_bucket{worker=\"w1\",le=\"+Inf\"} 3\n")
+	assert.Contains(t, out, "test_duration_seconds_count{worker=\"w1\"} 3\n")
+}
+
+func Test_LabelEscaping(t *testing.T) {
+
+	g := NewGaugeVec("test_escape", "Escaping\nhelp.", "name")
+	g.Set(1, "a\"b\\c")
+
+	buf := new(bytes.Buffer)
+	defaultRegistry.Write(buf)
+	out := buf.String()
+	assert.Contains(t, out, "# HELP test_escape Escaping\\nhelp.\n")
+	assert.Contains(t, out, "test_escape{name=\"a\\\"b\\\\c\"} 1\n")
+}
+
+func Test_Handler(t *testing.T) {
+
+	NewCounterVec("test_handler_total", "A test counter.").Inc()
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	rr := httptest.NewRecorder()
+	Handler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, CONTENT_TYPE, rr.Header().Get("Content-Type"))
+	assert.True(t, strings.Contains(rr.Body.String(), "test_handler_total 1\n"))
+
+	req = httptest.NewRequest("POST", "/metrics", nil)
+	rr = httptest.NewRecorder()
+	Handler(rr, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/events"
@@ -30,6 +29,7 @@ const MS_DELETED_BY_UPGRADE_PROCESS = 205
 const MS_DELETED_FOR_AG_ENDED = 206
 const MS_IMAGE_FETCH_FAILED = 207
 const MS_DELETED_BY_DOWNGRADE_PROCESS = 208
+const MS_IMAGE_NOT_VERIFIED = 209
 
 func DecodeReasonCode(code uint64) string {
 	// microservice termiated deccription
@@ -43,6 +43,7 @@ func DecodeReasonCode(code uint64) string {
 		MS_DELETED_BY_DOWNGRADE_PROCESS: "Deleted by downgrading process",
 		MS_DELETED_FOR_AG_ENDED:         "Deleted for agreement ended",
 		MS_IMAGE_FETCH_FAILED:           "Image fetching failed",
+		MS_IMAGE_NOT_VERIFIED:           "Image digest verification failed",
 	}
 
 	if reasonString, ok := codeMeanings[code]; !ok {
@@ -134,7 +135,7 @@ func ConvertRequiredServicesToExchange(m *persistence.MicroserviceDefinition) *[
 }
 
 // check if the given msdef is eligible for a upgrade
-func MicroserviceReadyForUpgrade(msdef *persistence.MicroserviceDefinition, db *bolt.DB) bool {
+func MicroserviceReadyForUpgrade(msdef *persistence.MicroserviceDefinition, db persistence.EdgeDatabase) bool {
 	glog.V(5).Infof("Check if service %v/%v is available for a upgrade.", msdef.Org, msdef.SpecRef)
 
 	if msdef.Archived {
@@ -187,7 +188,7 @@ func MicroserviceReadyForUpgrade(msdef *persistence.MicroserviceDefinition, db *
 // This function gets the msdef with highest version within defined version range from the exchange and
 // compare the version and content with the current msdef and decide if it needs to upgrade.
 // It returns the new msdef if the old one needs to be upgraded, otherwide return nil.
-func GetUpgradeMicroserviceDef(getService exchange.ServiceResolverHandler, msdef *persistence.MicroserviceDefinition, db *bolt.DB) (*persistence.MicroserviceDefinition, error) {
+func GetUpgradeMicroserviceDef(getService exchange.ServiceResolverHandler, msdef *persistence.MicroserviceDefinition, db persistence.EdgeDatabase) (*persistence.MicroserviceDefinition, error) {
 	glog.V(3).Infof("Get new service def for upgrading service %v/%v version %v key %v", msdef.Org, msdef.SpecRef, msdef.Version, msdef.Id)
 
 	// convert the sensor version to a version expression
@@ -232,7 +233,7 @@ func GetUpgradeMicroserviceDef(getService exchange.ServiceResolverHandler, msdef
 }
 
 // Get a msdef with a lower version compared to the given msdef version and return the new microservice def.
-func GetRollbackMicroserviceDef(getService exchange.ServiceResolverHandler, msdef *persistence.MicroserviceDefinition, db *bolt.DB) (*persistence.MicroserviceDefinition, error) {
+func GetRollbackMicroserviceDef(getService exchange.ServiceResolverHandler, msdef *persistence.MicroserviceDefinition, db persistence.EdgeDatabase) (*persistence.MicroserviceDefinition, error) {
 	glog.V(3).Infof("Get next highest service def for rolling back service %v/%v version %v key %v", msdef.Org, msdef.SpecRef, msdef.Version, msdef.Id)
 
 	// convert the sensor version to a version expression
@@ -288,7 +289,7 @@ func RemoveMicroservicePolicy(spec_ref string, org string, version string, msdef
 }
 
 // Generate a new policy file for given ms and the register the microservice on the exchange.
-func GenMicroservicePolicy(msdef *persistence.MicroserviceDefinition, policyPath string, db *bolt.DB, e chan events.Message, deviceOrg string) error {
+func GenMicroservicePolicy(msdef *persistence.MicroserviceDefinition, policyPath string, db persistence.EdgeDatabase, e chan events.Message, deviceOrg string) error {
 	glog.V(3).Infof("Generate policy for the given service %v/%v version %v key %v", msdef.Org, msdef.SpecRef, msdef.Version, msdef.Id)
 
 	var haPartner []string
@@ -296,6 +297,7 @@ func GenMicroservicePolicy(msdef *persistence.MicroserviceDefinition, policyPath
 	var counterPartyProperties policy.RequiredProperty
 	var properties map[string]interface{}
 	var serviceAgreementProtocols []interface{}
+	var maintenanceWindows interface{}
 
 	props := make(map[string]interface{})
 
@@ -328,6 +330,9 @@ func GenMicroservicePolicy(msdef *persistence.MicroserviceDefinition, policyPath
 				agpl := attr.(persistence.AgreementProtocolAttributes).Protocols
 				serviceAgreementProtocols = agpl.([]interface{})
 
+			case persistence.MaintenanceAttributes:
+				maintenanceWindows = attr.(persistence.MaintenanceAttributes).Windows
+
 			default:
 				glog.V(4).Infof("Unhandled attr type (%T): %v", attr, attr)
 			}
@@ -374,7 +379,12 @@ func GenMicroservicePolicy(msdef *persistence.MicroserviceDefinition, policyPath
 			maxAgreements = 5 // hard coded 2 for now, will change to 0 later
 		}
 
-		if msg, err := policy.GeneratePolicy(msdef.SpecRef, msdef.Org, msdef.Name, msdef.Version, msdef.RequestedArch, &props, haPartner, meterPolicy, counterPartyProperties, *list, maxAgreements, policyPath, deviceOrg); err != nil {
+		windows, err := policy.ConvertToMaintenanceWindowList(maintenanceWindows)
+		if err != nil {
+			return fmt.Errorf("Error converting maintenance attribute %v to maintenance windows, error: %v", maintenanceWindows, err)
+		}
+
+		if msg, err := policy.GeneratePolicy(msdef.SpecRef, msdef.Org, msdef.Name, msdef.Version, msdef.RequestedArch, &props, haPartner, meterPolicy, counterPartyProperties, windows, *list, maxAgreements, policyPath, deviceOrg); err != nil {
 			return fmt.Errorf("Failed to generate policy for %v/%v version %v. Error: %v", msdef.Org, msdef.SpecRef, msdef.Version, err)
 		} else {
 			e <- msg
@@ -388,7 +398,7 @@ func GenMicroservicePolicy(msdef *persistence.MicroserviceDefinition, policyPath
 func UnregisterMicroserviceExchange(getExchangeDevice exchange.DeviceHandler,
 	putExchangeDevice exchange.PutDeviceHandler,
 	spec_ref string, org string,
-	device_id string, device_token string, db *bolt.DB) error {
+	device_id string, device_token string, db persistence.EdgeDatabase) error {
 
 	glog.V(3).Infof("Unregister service %v/%v from exchange for %v.", org, spec_ref, device_id)
 
@@ -4,7 +4,6 @@ package microservice
 
 import (
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/persistence"
@@ -14,7 +13,6 @@ import (
 	"os"
 	"path"
 	"testing"
-	"time"
 )
 
 func TestConvertToPersistent(t *testing.T) {
@@ -326,13 +324,13 @@ func getVariableDeviceHandler(mss []exchange.Microservice, ss []exchange.Microse
 	}
 }
 
-func setupDB() (string, *bolt.DB, error) {
+func setupDB() (string, persistence.EdgeDatabase, error) {
 	dir, err := ioutil.TempDir("", "container-")
 	if err != nil {
 		return "", nil, err
 	}
 
-	db, err := bolt.Open(path.Join(dir, "anax-int.db"), 0600, &bolt.Options{Timeout: 10 * time.Second})
+	db, err := persistence.OpenBoltDatabase(path.Join(dir, "anax-int.db"))
 	if err != nil {
 		return dir, nil, err
 	}
@@ -333,6 +333,42 @@ func (a AgreementProtocolAttributes) GetServiceSpecs() *ServiceSpecs {
 	return a.ServiceSpecs
 }
 
+type MaintenanceAttributes struct {
+	Meta         *AttributeMeta `json:"meta"`
+	ServiceSpecs *ServiceSpecs  `json:"service_specs"`
+	Windows      interface{}    `json:"windows"`
+}
+
+func (a MaintenanceAttributes) GetMeta() *AttributeMeta {
+	return a.Meta
+}
+
+func (a MaintenanceAttributes) GetGenericMappings() map[string]interface{} {
+	return map[string]interface{}{
+		"windows": a.Windows,
+	}
+}
+
+// TODO: duplicate this for the others too
+func (a MaintenanceAttributes) Update(other Attribute) error {
+	return fmt.Errorf("Update not implemented for type: %T", a)
+}
+
+func (a MaintenanceAttributes) String() string {
+	if a.ServiceSpecs == nil {
+		return fmt.Sprintf("Meta: %v, ServiceSpecs: %v, Windows: %v", a.Meta, nil, a.Windows)
+	} else {
+		return fmt.Sprintf("Meta: %v, ServiceSpecs: %v, Windows: %v", a.Meta, *a.ServiceSpecs, a.Windows)
+	}
+}
+
+func (a MaintenanceAttributes) GetServiceSpecs() *ServiceSpecs {
+	if a.ServiceSpecs == nil {
+		a.ServiceSpecs = new(ServiceSpecs)
+	}
+	return a.ServiceSpecs
+}
+
 type HTTPSBasicAuthAttributes struct {
 	Meta     *AttributeMeta `json:"meta"`
 	Url      string         `json:"url"`
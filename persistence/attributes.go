@@ -3,7 +3,6 @@ package persistence
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/satori/go.uuid"
@@ -248,6 +247,13 @@ func HydrateConcreteAttribute(v []byte) (Attribute, error) {
 		}
 		attr = agp
 
+	case "MaintenanceAttributes":
+		var mw MaintenanceAttributes
+		if err := json.Unmarshal(v, &mw); err != nil {
+			return nil, err
+		}
+		attr = mw
+
 	case "HTTPSBasicAuthAttributes":
 		var hba HTTPSBasicAuthAttributes
 		if err := json.Unmarshal(v, &hba); err != nil {
@@ -271,11 +277,11 @@ func HydrateConcreteAttribute(v []byte) (Attribute, error) {
 }
 
 // FindAttributeByKey is used to fetch a single attribute by its primary key
-func FindAttributeByKey(db *bolt.DB, id string) (*Attribute, error) {
+func FindAttributeByKey(db EdgeDatabase, id string) (*Attribute, error) {
 	var attr Attribute
-	var bucket *bolt.Bucket
+	var bucket EdgeBucket
 
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 		bucket = tx.Bucket([]byte(ATTRIBUTES))
 		if bucket != nil {
 
@@ -309,11 +315,11 @@ func FindAttributeByKey(db *bolt.DB, id string) (*Attribute, error) {
 // For an attribute, if the a.ServiceSpecs is empty, it will be included.
 // Otherwise, if an element in the attrubute's ServiceSpecs array equals to ServiceSpec{serviceUrl, org}
 // the attribute will be included.
-func FindApplicableAttributes(db *bolt.DB, serviceUrl string, org string) ([]Attribute, error) {
+func FindApplicableAttributes(db EdgeDatabase, serviceUrl string, org string) ([]Attribute, error) {
 
 	filteredAttrs := []Attribute{}
 
-	return filteredAttrs, db.View(func(tx *bolt.Tx) error {
+	return filteredAttrs, db.View(func(tx EdgeTx) error {
 		bucket := tx.Bucket([]byte(ATTRIBUTES))
 
 		if bucket == nil {
@@ -442,6 +448,9 @@ func AttributesToEnvvarMap(attributes []Attribute, envvars map[string]string, pr
 		case AgreementProtocolAttributes:
 			// Nothing to do
 
+		case MaintenanceAttributes:
+			// Nothing to do
+
 		default:
 			return nil, fmt.Errorf("Unhandled service attribute: %v", serv)
 		}
@@ -450,7 +459,7 @@ func AttributesToEnvvarMap(attributes []Attribute, envvars map[string]string, pr
 	return envvars, nil
 }
 
-func FindConflictingAttributes(db *bolt.DB, attribute *Attribute) (*Attribute, error) {
+func FindConflictingAttributes(db EdgeDatabase, attribute *Attribute) (*Attribute, error) {
 	var err error
 	var common []Attribute
 	serviceSpecs := GetAttributeServiceSpecs(attribute)
@@ -498,7 +507,7 @@ type ConflictingAttributeFound struct {
 func (e ConflictingAttributeFound) Error() string { return e.msg }
 
 // N.B. It's the caller's responsibility to ensure the attr.ServiceSpecs are deduplicated; use the ServiceSpecs.AddServiceSpec() function to keep the slice clean
-func SaveOrUpdateAttribute(db *bolt.DB, attr Attribute, id string, permitPartialOverwrite bool) (*Attribute, error) {
+func SaveOrUpdateAttribute(db EdgeDatabase, attr Attribute, id string, permitPartialOverwrite bool) (*Attribute, error) {
 	var ret *Attribute
 
 	if id == "" {
@@ -557,7 +566,7 @@ func SaveOrUpdateAttribute(db *bolt.DB, attr Attribute, id string, permitPartial
 		(*ret).GetMeta().Publishable = &pT
 	}
 
-	writeErr := db.Update(func(tx *bolt.Tx) error {
+	writeErr := db.Update(func(tx EdgeTx) error {
 		bucket, err := tx.CreateBucketIfNotExists([]byte(ATTRIBUTES))
 		if err != nil {
 			return err
@@ -572,7 +581,7 @@ func SaveOrUpdateAttribute(db *bolt.DB, attr Attribute, id string, permitPartial
 	return ret, writeErr
 }
 
-func DeleteAttribute(db *bolt.DB, id string) (*Attribute, error) {
+func DeleteAttribute(db EdgeDatabase, id string) (*Attribute, error) {
 
 	existing, err := FindAttributeByKey(db, id)
 	if err != nil {
@@ -583,7 +592,7 @@ func DeleteAttribute(db *bolt.DB, id string) (*Attribute, error) {
 		return nil, nil
 	}
 
-	delError := db.Update(func(tx *bolt.Tx) error {
+	delError := db.Update(func(tx EdgeTx) error {
 		bucket, err := tx.CreateBucketIfNotExists([]byte(ATTRIBUTES))
 		if err != nil {
 			return err
@@ -0,0 +1,237 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+// A backup of the edge database is always a bolt database file, regardless of the database implementation the node
+// is configured to use. That way a backup can be inspected with the bolt tools, and restored onto a node that uses a
+// different implementation.
+//
+// A restore is not done while anax is running. The backup is validated and staged in the DB path, and the next time
+// anax starts it validates the staged file again and swaps it in before the database is opened.
+
+const EDGE_DB_RESTORE_FILE = "anax-restore.db"
+const EDGE_DB_REJECTED_SUFFIX = ".rejected"
+const EDGE_DB_PRE_RESTORE_SUFFIX = ".pre-restore"
+
+// The error returned when the content of a backup is not a usable database.
+type InvalidBackupError struct {
+	msg string
+}
+
+func (e InvalidBackupError) Error() string {
+	return e.msg
+}
+
+func NewInvalidBackupError(msg string) *InvalidBackupError {
+	return &InvalidBackupError{
+		msg: msg,
+	}
+}
+
+// The location of the staged restore file.
+func RestoreFile(cfg *config.HorizonConfig) string {
+	return path.Join(cfg.Edge.DBPath, EDGE_DB_RESTORE_FILE)
+}
+
+// Write a consistent snapshot of the database to the writer. The number of bytes written is returned.
+func BackupDatabase(db EdgeDatabase, w io.Writer) (int64, error) {
+
+	// The bolt DB can write itself from within a read transaction, so the runtime is not blocked while the backup
+	// is streamed.
+	if bdb, ok := db.(*EdgeBoltDB); ok {
+		var n int64
+		err := bdb.db.View(func(tx *bolt.Tx) error {
+			var werr error
+			n, werr = tx.WriteTo(w)
+			return werr
+		})
+		return n, err
+	}
+
+	// Other implementations are copied into a temporary bolt database first.
+	dir, err := ioutil.TempDir("", "anax-backup-")
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("unable to create temporary directory for backup, error: %v", err))
+	}
+	defer os.RemoveAll(dir)
+
+	tmpFile := path.Join(dir, config.EDGE_BOLT_DB_FILE)
+	if tdb, err := OpenBoltDatabase(tmpFile); err != nil {
+		return 0, err
+	} else if _, err := CopyDatabase(db, tdb); err != nil {
+		tdb.Close()
+		return 0, errors.New(fmt.Sprintf("unable to copy %v database for backup, error: %v", db.Type(), err))
+	} else if err := tdb.Close(); err != nil {
+		return 0, errors.New(fmt.Sprintf("unable to close temporary backup database %v, error: %v", tmpFile, err))
+	}
+
+	f, err := os.Open(tmpFile)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("unable to open temporary backup database %v, error: %v", tmpFile, err))
+	}
+	defer f.Close()
+	return io.Copy(w, f)
+}
+
+// Check that the file is a bolt database that the runtime can use.
+func ValidateBackupFile(file string) (*FsckReport, error) {
+
+	// An empty file would be initialized as a new database by bolt.
+	if fi, err := os.Stat(file); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read backup file %v, error: %v", file, err))
+	} else if fi.Size() == 0 {
+		return nil, NewInvalidBackupError(fmt.Sprintf("backup file %v is empty", file))
+	}
+
+	db, err := OpenBoltDatabase(file)
+	if err != nil {
+		return nil, NewInvalidBackupError(fmt.Sprintf("backup file %v is not a database, error: %v", file, err))
+	}
+	defer db.Close()
+
+	// A backup taken by newer code cant be used until the runtime is upgraded.
+	if sv, err := GetSchemaVersion(db); err != nil {
+		return nil, NewInvalidBackupError(fmt.Sprintf("backup file %v has an unreadable schema version, error: %v", file, err))
+	} else if sv.Version > HIGHEST_DATABASE_VERSION {
+		return nil, NewInvalidBackupError(fmt.Sprintf("backup file %v is at schema version %v, which is newer than version %v supported by this runtime", file, sv.Version, HIGHEST_DATABASE_VERSION))
+	}
+
+	if report, err := CheckDatabase(db); err != nil {
+		return nil, NewInvalidBackupError(fmt.Sprintf("backup file %v could not be checked, error: %v", file, err))
+	} else if !report.IsUsable() {
+		return report, NewInvalidBackupError(fmt.Sprintf("backup file %v has %v structural errors and %v corrupt records", file, len(report.Structural), len(report.Corrupt)))
+	} else {
+		return report, nil
+	}
+}
+
+// Read a backup from the reader, validate it, and stage it to be restored the next time anax starts. A staged
+// restore that has not been applied yet is replaced.
+func StageRestore(cfg *config.HorizonConfig, r io.Reader) (*FsckReport, error) {
+
+	restoreFile := RestoreFile(cfg)
+	tmpFile := restoreFile + ".tmp"
+
+	if f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create restore file %v, error: %v", tmpFile, err))
+	} else if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmpFile)
+		return nil, errors.New(fmt.Sprintf("unable to write restore file %v, error: %v", tmpFile, err))
+	} else if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpFile)
+		return nil, errors.New(fmt.Sprintf("unable to sync restore file %v, error: %v", tmpFile, err))
+	} else {
+		f.Close()
+	}
+
+	report, err := ValidateBackupFile(tmpFile)
+	if err != nil {
+		os.Remove(tmpFile)
+		return report, err
+	}
+
+	if err := os.Rename(tmpFile, restoreFile); err != nil {
+		os.Remove(tmpFile)
+		return nil, errors.New(fmt.Sprintf("unable to stage restore file %v, error: %v", restoreFile, err))
+	}
+
+	glog.V(3).Infof("Staged database restore file %v", restoreFile)
+	return report, nil
+}
+
+// Called by the anax main before the database is opened. If a restore has been staged, it is validated again and
+// then replaces the current database, which is kept alongside it in case the restore needs to be undone. A staged
+// file that fails validation is renamed so that it is not tried again, and the current database is left alone.
+// Returns true if a restore was applied.
+func ApplyPendingRestore(cfg *config.HorizonConfig) (bool, error) {
+
+	restoreFile := RestoreFile(cfg)
+	if _, err := os.Stat(restoreFile); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.New(fmt.Sprintf("unable to read restore file %v, error: %v", restoreFile, err))
+	}
+
+	glog.V(1).Infof("Found database restore file %v", restoreFile)
+
+	if _, err := ValidateBackupFile(restoreFile); err != nil {
+		rejected := restoreFile + EDGE_DB_REJECTED_SUFFIX
+		if rerr := os.Rename(restoreFile, rejected); rerr != nil {
+			return false, errors.New(fmt.Sprintf("restore file %v is not valid: %v, and could not be renamed, error: %v", restoreFile, err, rerr))
+		}
+		return false, errors.New(fmt.Sprintf("restore file is not valid and has been renamed to %v, error: %v", rejected, err))
+	}
+
+	// Move the current database out of the way.
+	dbFile := cfg.GetEdgeDBFile()
+	saved := ""
+	if _, err := os.Stat(dbFile); err == nil {
+		saved = fmt.Sprintf("%v%v-%v", dbFile, EDGE_DB_PRE_RESTORE_SUFFIX, time.Now().Unix())
+		if err := os.Rename(dbFile, saved); err != nil {
+			return false, errors.New(fmt.Sprintf("unable to move current database %v to %v, error: %v", dbFile, saved, err))
+		}
+		glog.V(1).Infof("Saved current database as %v", saved)
+	}
+
+	if err := installRestoreFile(cfg, restoreFile, dbFile); err != nil {
+		// Put the current database back.
+		if saved != "" {
+			os.Remove(dbFile)
+			if rerr := os.Rename(saved, dbFile); rerr != nil {
+				return false, errors.New(fmt.Sprintf("%v, and the current database could not be put back from %v, error: %v", err, saved, rerr))
+			}
+		}
+		return false, err
+	}
+
+	glog.V(1).Infof("Restored database %v from %v", dbFile, restoreFile)
+	return true, nil
+}
+
+func installRestoreFile(cfg *config.HorizonConfig, restoreFile string, dbFile string) error {
+
+	dbType := cfg.GetEdgeDBType()
+	if dbType == config.EDGE_DB_TYPE_BOLT {
+		if err := os.Rename(restoreFile, dbFile); err != nil {
+			return errors.New(fmt.Sprintf("unable to move restore file %v to %v, error: %v", restoreFile, dbFile, err))
+		}
+		return nil
+	}
+
+	// The backup has to be copied into the configured database.
+	to, ok := EdgeDatabaseProviders[dbType]
+	if !ok {
+		return errors.New(fmt.Sprintf("edge database type %v is not supported, the anax runtime might need to be built with it.", dbType))
+	}
+
+	from, err := OpenBoltDatabase(restoreFile)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+
+	if err := to.Initialize(cfg); err != nil {
+		return err
+	}
+	count, err := CopyDatabase(from, to)
+	to.Close()
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to copy restore file %v into %v database, error: %v", restoreFile, dbType, err))
+	}
+
+	glog.V(3).Infof("Copied %v records from restore file %v into %v database", count, restoreFile, dbType)
+	return os.Remove(restoreFile)
+}
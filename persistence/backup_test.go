@@ -0,0 +1,165 @@
+// +build unit
+
+package persistence
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/open-horizon/anax/config"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+func Test_CheckDatabase_orphans(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	// One live agreement, one archived agreement and one service definition.
+	putRecord(t, db, E_AGREEMENTS+"-Basic", "ag1", EstablishedAgreement{CurrentAgreementId: "ag1"})
+	putRecord(t, db, E_AGREEMENTS+"-Basic", "ag2", EstablishedAgreement{CurrentAgreementId: "ag2", Archived: true})
+	putRecord(t, db, MICROSERVICE_DEFINITIONS, "def1", MicroserviceDefinition{Id: "def1"})
+
+	// A good instance, an instance pointing at an archived and a missing agreement, an instance pointing at a
+	// missing definition, and an archived instance that would otherwise be an orphan.
+	putRecord(t, db, MICROSERVICE_INSTANCES, "msi1", MicroserviceInstance{AssociatedAgreements: []string{"ag1"}, MicroserviceDefId: "def1"})
+	putRecord(t, db, MICROSERVICE_INSTANCES, "msi2", MicroserviceInstance{AssociatedAgreements: []string{"ag2", "ag3"}, MicroserviceDefId: "def1"})
+	putRecord(t, db, MICROSERVICE_INSTANCES, "msi3", MicroserviceInstance{MicroserviceDefId: "def2", AgreementLess: true})
+	putRecord(t, db, MICROSERVICE_INSTANCES, "msi4", MicroserviceInstance{AssociatedAgreements: []string{"ag3"}, Archived: true})
+
+	report, err := CheckDatabase(db)
+	assert.Nil(t, err)
+	assert.True(t, report.IsUsable(), "The database should be usable.")
+	assert.False(t, report.IsClean(), "The database should have orphans.")
+	assert.Equal(t, 7, report.Records)
+	assert.Equal(t, 0, len(report.Structural))
+	assert.Equal(t, 3, len(report.Orphans), "There should be 3 orphans.")
+
+	assert.Equal(t, "msi2", report.Orphans[0].Key)
+	assert.Contains(t, report.Orphans[0].Problem, "ag2 is archived")
+	assert.Equal(t, "msi2", report.Orphans[1].Key)
+	assert.Contains(t, report.Orphans[1].Problem, "ag3 does not exist")
+	assert.Equal(t, "msi3", report.Orphans[2].Key)
+	assert.Contains(t, report.Orphans[2].Problem, "def2 does not exist")
+
+	// A record that cant be decoded makes the database unusable.
+	if err := db.Update(func(tx EdgeTx) error {
+		b, _ := tx.CreateBucketIfNotExists([]byte(DEVICES))
+		return b.Put([]byte("bad"), []byte("{not json"))
+	}); err != nil {
+		t.Error(err)
+	}
+
+	report, err = CheckDatabase(db)
+	assert.Nil(t, err)
+	assert.False(t, report.IsUsable(), "The database should not be usable.")
+	assert.Equal(t, 1, len(report.Corrupt))
+	assert.Equal(t, DEVICES, report.Corrupt[0].Bucket)
+}
+
+func Test_BackupRestore(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	if err := SaveLastUnregistrationTime(db, 12345); err != nil {
+		t.Errorf("Error saving last unregistration time: %v", err)
+	}
+
+	var backup bytes.Buffer
+	n, err := BackupDatabase(db, &backup)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(backup.Len()), n)
+
+	cfg := &config.HorizonConfig{Edge: config.Config{DBPath: path.Join(dir, "node")}}
+	if err := os.MkdirAll(cfg.Edge.DBPath, 0700); err != nil {
+		t.Error(err)
+	}
+
+	// Content that is not a database is rejected and nothing is staged.
+	_, err = StageRestore(cfg, bytes.NewBufferString("not a database"))
+	assert.NotNil(t, err)
+	_, ok := err.(*InvalidBackupError)
+	assert.True(t, ok, "The error should be an invalid backup error.")
+	_, err = os.Stat(RestoreFile(cfg))
+	assert.True(t, os.IsNotExist(err), "Nothing should be staged.")
+
+	// Stage the good backup over an existing database, and restore it.
+	current, err := OpenBoltDatabase(cfg.GetEdgeDBFile())
+	if err != nil {
+		t.Error(err)
+	}
+	current.Close()
+
+	report, err := StageRestore(cfg, &backup)
+	assert.Nil(t, err)
+	assert.True(t, report.IsClean())
+
+	restored, err := ApplyPendingRestore(cfg)
+	assert.Nil(t, err)
+	assert.True(t, restored, "The database should be restored.")
+
+	saved, _ := filepath.Glob(cfg.GetEdgeDBFile() + EDGE_DB_PRE_RESTORE_SUFFIX + "-*")
+	assert.Equal(t, 1, len(saved), "The previous database should be kept.")
+
+	rdb, err := OpenBoltDatabase(cfg.GetEdgeDBFile())
+	if err != nil {
+		t.Error(err)
+	}
+	defer rdb.Close()
+	tl, err := GetLastUnregistrationTime(rdb)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(12345), tl)
+
+	// Nothing is left to restore.
+	restored, err = ApplyPendingRestore(cfg)
+	assert.Nil(t, err)
+	assert.False(t, restored)
+}
+
+func Test_ApplyPendingRestore_rejected(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "utdb-")
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	cfg := &config.HorizonConfig{Edge: config.Config{DBPath: dir}}
+	if f, err := os.Create(RestoreFile(cfg)); err != nil {
+		t.Error(err)
+	} else {
+		f.WriteString("damaged")
+		f.Close()
+	}
+
+	restored, err := ApplyPendingRestore(cfg)
+	assert.NotNil(t, err)
+	assert.False(t, restored)
+	_, err = os.Stat(RestoreFile(cfg) + EDGE_DB_REJECTED_SUFFIX)
+	assert.Nil(t, err, "The restore file should be renamed.")
+}
+
+func putRecord(t *testing.T, db EdgeDatabase, bucket string, key string, record interface{}) {
+	if err := db.Update(func(tx EdgeTx) error {
+		if b, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+			return err
+		} else if serial, err := json.Marshal(record); err != nil {
+			return err
+		} else {
+			return b.Put([]byte(key), serial)
+		}
+	}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,109 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/config"
+	"os"
+	"time"
+)
+
+// The bolt implementation of the edge database. Buckets and transactions map directly onto bolt's own, so this is
+// a thin wrapper around the bolt handle.
+type EdgeBoltDB struct {
+	db   *bolt.DB
+	path string
+}
+
+func init() {
+	RegisterEdgeDatabase(config.EDGE_DB_TYPE_BOLT, new(EdgeBoltDB))
+}
+
+// Open (or create) the bolt database in the configured DB path.
+func (db *EdgeBoltDB) Initialize(cfg *config.HorizonConfig) error {
+
+	if err := os.MkdirAll(cfg.Edge.DBPath, 0700); err != nil {
+		return errors.New(fmt.Sprintf("unable to create directory %v for bolt DB configuration, error: %v", cfg.Edge.DBPath, err))
+	}
+
+	return db.open(cfg.GetEdgeDBFile())
+}
+
+func (db *EdgeBoltDB) open(file string) error {
+	if bdb, err := bolt.Open(file, 0600, &bolt.Options{Timeout: 10 * time.Second}); err != nil {
+		return errors.New(fmt.Sprintf("unable to open bolt database %v, error: %v", file, err))
+	} else {
+		db.db = bdb
+		db.path = file
+	}
+	return nil
+}
+
+// Open a bolt database file directly, without going through the configuration. This is used by tools that work on
+// a database file, and by tests.
+func OpenBoltDatabase(file string) (EdgeDatabase, error) {
+	db := new(EdgeBoltDB)
+	if err := db.open(file); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *EdgeBoltDB) Close() error {
+	if db.db != nil {
+		return db.db.Close()
+	}
+	return nil
+}
+
+func (db *EdgeBoltDB) Type() string {
+	return config.EDGE_DB_TYPE_BOLT
+}
+
+func (db *EdgeBoltDB) Path() string {
+	return db.path
+}
+
+func (db *EdgeBoltDB) Update(fn func(EdgeTx) error) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return fn(&edgeBoltTx{tx: tx})
+	})
+}
+
+func (db *EdgeBoltDB) View(fn func(EdgeTx) error) error {
+	return db.db.View(func(tx *bolt.Tx) error {
+		return fn(&edgeBoltTx{tx: tx})
+	})
+}
+
+type edgeBoltTx struct {
+	tx *bolt.Tx
+}
+
+// A nil bolt bucket has to be returned as a nil interface, otherwise callers checking for a missing bucket would
+// see a non-nil value.
+func (t *edgeBoltTx) Bucket(name []byte) EdgeBucket {
+	if b := t.tx.Bucket(name); b != nil {
+		return b
+	}
+	return nil
+}
+
+func (t *edgeBoltTx) CreateBucketIfNotExists(name []byte) (EdgeBucket, error) {
+	if b, err := t.tx.CreateBucketIfNotExists(name); err != nil {
+		return nil, err
+	} else {
+		return b, nil
+	}
+}
+
+func (t *edgeBoltTx) DeleteBucket(name []byte) error {
+	return t.tx.DeleteBucket(name)
+}
+
+func (t *edgeBoltTx) ForEachBucket(fn func(name []byte, b EdgeBucket) error) error {
+	return t.tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		return fn(name, b)
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/persistence/sqlite"
+	"os"
+)
+
+// Copy the contents of an existing edge database into a new database of another type, e.g. from the bolt
+// anax.db into a SQLite database. The anax process must be stopped while the copy is made. To use the new
+// database, move it into the configured Edge DBPath and set the Edge DBType in the anax configuration file.
+func main() {
+	from := flag.String("from", "", "The edge database file to copy from.")
+	fromType := flag.String("from-type", config.EDGE_DB_TYPE_BOLT, "The type of the database to copy from, bolt or sqlite.")
+	to := flag.String("to", "", "The edge database file to create.")
+	toType := flag.String("to-type", config.EDGE_DB_TYPE_SQLITE, "The type of the database to create, bolt or sqlite.")
+
+	flag.Parse()
+
+	if *from == "" || *to == "" {
+		flag.Usage()
+		os.Exit(2)
+	} else if _, err := os.Stat(*from); err != nil {
+		fatal("unable to find database %v, error: %v", *from, err)
+	} else if _, err := os.Stat(*to); err == nil {
+		fatal("database %v already exists, the target of the copy must be a new database.", *to)
+	}
+
+	fromDB, err := open(*from, *fromType)
+	if err != nil {
+		fatal("%v", err)
+	}
+	defer fromDB.Close()
+
+	toDB, err := open(*to, *toType)
+	if err != nil {
+		fatal("%v", err)
+	}
+	defer toDB.Close()
+
+	if count, err := persistence.CopyDatabase(fromDB, toDB); err != nil {
+		toDB.Close()
+		os.Remove(*to)
+		fatal("unable to copy %v database %v to %v database %v, error: %v", fromDB.Type(), *from, toDB.Type(), *to, err)
+	} else {
+		fmt.Printf("Copied %v records from %v database %v to %v database %v.\n", count, fromDB.Type(), *from, toDB.Type(), *to)
+	}
+}
+
+func open(file string, dbType string) (persistence.EdgeDatabase, error) {
+	switch dbType {
+	case config.EDGE_DB_TYPE_BOLT:
+		return persistence.OpenBoltDatabase(file)
+	case config.EDGE_DB_TYPE_SQLITE:
+		return sqlite.OpenSqliteDatabase(file)
+	default:
+		return nil, fmt.Errorf("database type %v is not supported", dbType)
+	}
+}
+
+func fatal(msg string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Error: "+msg+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,113 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/config"
+)
+
+// The edge node can be configured to run with different databases. By default the bolt DB is used. An embedded
+// SQLite database can be used instead, which makes it possible to run ad-hoc SQL queries against the node's state
+// when debugging a device in the field. This file contains the abstract interface representing the database handle
+// used by the runtime to access the real database.
+//
+// All of the edge state is stored as JSON documents in named buckets, keyed by a string. So unlike the agbot's
+// database interface, which has a function for each operation, the edge interface is modeled on buckets of key/value
+// pairs accessed within a transaction. Each implementation is responsible for mapping buckets onto its own storage.
+
+type EdgeDatabase interface {
+
+	// Database related functions
+	Initialize(cfg *config.HorizonConfig) error
+	Close() error
+
+	// The name of the database implementation, and the location of the database file.
+	Type() string
+	Path() string
+
+	// Run the function in a read-write transaction. If the function returns an error, the transaction is rolled back.
+	Update(fn func(EdgeTx) error) error
+
+	// Run the function in a read-only transaction.
+	View(fn func(EdgeTx) error) error
+}
+
+type EdgeTx interface {
+
+	// Returns nil if the bucket does not exist.
+	Bucket(name []byte) EdgeBucket
+	CreateBucketIfNotExists(name []byte) (EdgeBucket, error)
+	DeleteBucket(name []byte) error
+
+	// Iterate the buckets in the database, used when copying a database.
+	ForEachBucket(fn func(name []byte, b EdgeBucket) error) error
+}
+
+type EdgeBucket interface {
+
+	// Returns nil if the key does not exist.
+	Get(key []byte) []byte
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+
+	// Iterate the key/value pairs in the bucket in key order. The bucket must not be modified by the function.
+	ForEach(fn func(k []byte, v []byte) error) error
+
+	// Returns an auto-incrementing integer for the bucket. Sequence and SetSequence get and set its current value.
+	NextSequence() (uint64, error)
+	Sequence() uint64
+	SetSequence(v uint64) error
+}
+
+// The registry is a mechanism that enables optional persistence implementations to be plugged into the runtime,
+// in the same way as the agbot database registry. The bolt implementation is part of this package, other
+// implementations register themselves when their package init() method is driven.
+type EdgeDatabaseProviderRegistry map[string]EdgeDatabase
+
+var EdgeDatabaseProviders = EdgeDatabaseProviderRegistry{}
+
+func RegisterEdgeDatabase(name string, db EdgeDatabase) {
+	EdgeDatabaseProviders[name] = db
+}
+
+// Initialize the underlying edge database depending on what is configured, and bring its schema up to date.
+func InitDatabase(cfg *config.HorizonConfig) (EdgeDatabase, error) {
+
+	dbType := cfg.GetEdgeDBType()
+	if dbObj, ok := EdgeDatabaseProviders[dbType]; !ok {
+		return nil, errors.New(fmt.Sprintf("edge database type %v is not supported, the anax runtime might need to be built with it.", dbType))
+	} else if err := dbObj.Initialize(cfg); err != nil {
+		return dbObj, err
+	} else {
+		return dbObj, MigrateDatabase(dbObj, cfg)
+	}
+
+}
+
+// Copy all the buckets and their contents from one database to another. This is used to migrate an existing
+// node from one database implementation to another. The target should be empty.
+func CopyDatabase(from EdgeDatabase, to EdgeDatabase) (int, error) {
+
+	count := 0
+	err := from.View(func(ftx EdgeTx) error {
+		return to.Update(func(ttx EdgeTx) error {
+			count = 0
+			return ftx.ForEachBucket(func(name []byte, fb EdgeBucket) error {
+				tb, err := ttx.CreateBucketIfNotExists(name)
+				if err != nil {
+					return errors.New(fmt.Sprintf("unable to create bucket %v in %v database, error: %v", string(name), to.Type(), err))
+				} else if err := tb.SetSequence(fb.Sequence()); err != nil {
+					return errors.New(fmt.Sprintf("unable to set the sequence of bucket %v in %v database, error: %v", string(name), to.Type(), err))
+				}
+				return fb.ForEach(func(k []byte, v []byte) error {
+					if err := tb.Put(k, v); err != nil {
+						return errors.New(fmt.Sprintf("unable to copy key %v in bucket %v to %v database, error: %v", string(k), string(name), to.Type(), err))
+					}
+					count++
+					return nil
+				})
+			})
+		})
+	})
+	return count, err
+}
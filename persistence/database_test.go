@@ -0,0 +1,74 @@
+// +build unit
+
+package persistence
+
+import (
+	"github.com/open-horizon/anax/config"
+	"github.com/stretchr/testify/assert"
+	"path"
+	"testing"
+)
+
+func Test_BoltDatabase_missing_bucket(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	// A missing bucket must be a nil interface so that callers can test for it.
+	err = db.View(func(tx EdgeTx) error {
+		assert.Nil(t, tx.Bucket([]byte("nobucket")), "The bucket should not exist.")
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, config.EDGE_DB_TYPE_BOLT, db.Type())
+}
+
+func Test_CopyDatabase(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	// Put some state into the source database.
+	sp := ServiceSpec{Url: "http://mycom.com", Org: "mycom"}
+	source := NewAgreementEventSource("agreement id 1", WorkloadInfo{"http://top1.com", "mycomp", "1.0.0", "amd64"}, []ServiceSpec{sp}, "agbot1", "basic")
+	for _, msg := range []string{"message 1", "message 2"} {
+		if err := SaveEventLog(db, NewEventLog(SEVERITY_INFO, msg, EC_START_NODE_CONFIG_REG, SRC_TYPE_AG, *source)); err != nil {
+			t.Errorf("Error saving event log: %v", err)
+		}
+	}
+	if err := SaveLastUnregistrationTime(db, 12345); err != nil {
+		t.Errorf("Error saving last unregistration time: %v", err)
+	}
+
+	to, err := OpenBoltDatabase(path.Join(dir, "copy.db"))
+	if err != nil {
+		t.Error(err)
+	}
+	defer to.Close()
+
+	count, err := CopyDatabase(db, to)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count, "There should be 3 records copied.")
+
+	// The copied state should be the same, and new records should not reuse the copied keys.
+	logs, err := FindAllEventLogs(to)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(logs), "There should be 2 event logs.")
+
+	tl, err := GetLastUnregistrationTime(to)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(12345), tl)
+
+	if err := SaveEventLog(to, NewEventLog(SEVERITY_INFO, "message 3", EC_START_NODE_CONFIG_REG, SRC_TYPE_AG, *source)); err != nil {
+		t.Errorf("Error saving event log: %v", err)
+	}
+	logs, err = FindAllEventLogs(to)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(logs), "There should be 3 event logs.")
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"strings"
 	"time"
@@ -101,7 +100,7 @@ func newExchangeDevice(id string, token string, name string, tokenLastValidTime
 }
 
 // a convenience function b/c we know there is really only one device
-func (e *ExchangeDevice) InvalidateExchangeToken(db *bolt.DB) (*ExchangeDevice, error) {
+func (e *ExchangeDevice) InvalidateExchangeToken(db EdgeDatabase) (*ExchangeDevice, error) {
 	exchDev, err := FindExchangeDevice(db)
 	if err != nil {
 		return nil, err
@@ -113,7 +112,7 @@ func (e *ExchangeDevice) InvalidateExchangeToken(db *bolt.DB) (*ExchangeDevice,
 	})
 }
 
-func (e *ExchangeDevice) SetExchangeDeviceToken(db *bolt.DB, deviceId string, token string) (*ExchangeDevice, error) {
+func (e *ExchangeDevice) SetExchangeDeviceToken(db EdgeDatabase, deviceId string, token string) (*ExchangeDevice, error) {
 	if deviceId == "" || token == "" {
 		return nil, errors.New("Argument null and mustn't be")
 	}
@@ -124,7 +123,7 @@ func (e *ExchangeDevice) SetExchangeDeviceToken(db *bolt.DB, deviceId string, to
 	})
 }
 
-func (e *ExchangeDevice) SetConfigstate(db *bolt.DB, deviceId string, state string) (*ExchangeDevice, error) {
+func (e *ExchangeDevice) SetConfigstate(db EdgeDatabase, deviceId string, state string) (*ExchangeDevice, error) {
 	if deviceId == "" || state == "" {
 		return nil, errors.New("Argument null and mustn't be")
 	}
@@ -140,7 +139,7 @@ func (e *ExchangeDevice) IsState(state string) bool {
 	return e.Config.State == state
 }
 
-func updateExchangeDevice(db *bolt.DB, self *ExchangeDevice, deviceId string, invalidateToken bool, fn func(d ExchangeDevice) *ExchangeDevice) (*ExchangeDevice, error) {
+func updateExchangeDevice(db EdgeDatabase, self *ExchangeDevice, deviceId string, invalidateToken bool, fn func(d ExchangeDevice) *ExchangeDevice) (*ExchangeDevice, error) {
 	if deviceId == "" {
 		return nil, fmt.Errorf("Illegal arguments specified.")
 	}
@@ -149,7 +148,7 @@ func updateExchangeDevice(db *bolt.DB, self *ExchangeDevice, deviceId string, in
 
 	var mod ExchangeDevice
 
-	return &mod, db.Update(func(tx *bolt.Tx) error {
+	return &mod, db.Update(func(tx EdgeTx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte(DEVICES))
 		if err != nil {
 			return err
@@ -201,7 +200,7 @@ func updateExchangeDevice(db *bolt.DB, self *ExchangeDevice, deviceId string, in
 }
 
 // always assumed the given token is valid at the time of call
-func SaveNewExchangeDevice(db *bolt.DB, id string, token string, name string, ha bool, organization string, pattern string, configstate string) (*ExchangeDevice, error) {
+func SaveNewExchangeDevice(db EdgeDatabase, id string, token string, name string, ha bool, organization string, pattern string, configstate string) (*ExchangeDevice, error) {
 
 	if id == "" || token == "" || name == "" || organization == "" || configstate == "" {
 		return nil, errors.New("Argument null and must not be")
@@ -209,7 +208,7 @@ func SaveNewExchangeDevice(db *bolt.DB, id string, token string, name string, ha
 
 	duplicate := false
 
-	dErr := db.View(func(tx *bolt.Tx) error {
+	dErr := db.View(func(tx EdgeTx) error {
 		bd := tx.Bucket([]byte(DEVICES))
 		if bd != nil {
 			duplicate = (bd.Get([]byte(name)) != nil)
@@ -231,7 +230,7 @@ func SaveNewExchangeDevice(db *bolt.DB, id string, token string, name string, ha
 		return nil, err
 	}
 
-	writeErr := db.Update(func(tx *bolt.Tx) error {
+	writeErr := db.Update(func(tx EdgeTx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte(DEVICES))
 		if err != nil {
 			return err
@@ -249,11 +248,11 @@ func SaveNewExchangeDevice(db *bolt.DB, id string, token string, name string, ha
 	return exDevice, writeErr
 }
 
-func FindExchangeDevice(db *bolt.DB) (*ExchangeDevice, error) {
+func FindExchangeDevice(db EdgeDatabase) (*ExchangeDevice, error) {
 
 	devices := make([]ExchangeDevice, 0)
 
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 		if b := tx.Bucket([]byte(DEVICES)); b != nil {
 			return b.ForEach(func(k, v []byte) error {
 				var dev ExchangeDevice
@@ -288,7 +287,7 @@ func FindExchangeDevice(db *bolt.DB) (*ExchangeDevice, error) {
 	}
 }
 
-func DeleteExchangeDevice(db *bolt.DB) error {
+func DeleteExchangeDevice(db EdgeDatabase) error {
 
 	if dev, err := FindExchangeDevice(db); err != nil {
 		return err
@@ -296,7 +295,7 @@ func DeleteExchangeDevice(db *bolt.DB) error {
 		return fmt.Errorf("could not find record for device")
 	} else {
 
-		return db.Update(func(tx *bolt.Tx) error {
+		return db.Update(func(tx EdgeTx) error {
 
 			if b, err := tx.CreateBucketIfNotExists([]byte(DEVICES)); err != nil {
 				return err
@@ -308,21 +307,3 @@ func DeleteExchangeDevice(db *bolt.DB) error {
 		})
 	}
 }
-
-// Migrate a device object if it is restarted ona newer level of code.
-func MigrateExchangeDevice(db *bolt.DB) (bool, error) {
-	usingPattern := false
-	// If the device object already exists, make sure its service or workload mode is set correctly. If not, set it.
-	// This code handles devices that upgrade to an anax runtime that supports service mode but the device is still
-	// using workloads.
-	if db != nil {
-		if dev, _ := FindExchangeDevice(db); dev != nil {
-
-			// If the existing device is using a pattern then we need to turn off agreement tracking when we create the policy manager.
-			if dev.Pattern != "" {
-				usingPattern = true
-			}
-		}
-	}
-	return usingPattern, nil
-}
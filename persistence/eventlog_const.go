@@ -25,6 +25,10 @@ const (
 	EC_API_USER_INPUT_ERROR = "api_user_input_error"
 	EC_EXCHANGE_ERROR       = "exchange_error"
 
+	// database backup and restore
+	EC_DATABASE_RESTORED         = "database_restored"
+	EC_DATABASE_RESTORE_REJECTED = "database_restore_rejected"
+
 	// node configuration/registration
 	EC_START_NODE_CONFIG_REG    = "start_node_configuration_registration"
 	EC_NODE_CONFIG_REG_COMPLETE = "node_configuration_registration_complete"
@@ -60,6 +64,7 @@ const (
 	EC_REJECT_PROPOSAL           = "reject_proposal"
 	EC_ERROR_IN_PROPOSAL         = "error_in_proposal"
 	EC_ERROR_PROCESSING_PROPOSAL = "error_processing_proposal"
+	EC_PREFLIGHT_CHECK_FAILED    = "preflight_check_failed"
 
 	EC_RECEIVED_REPLYACK_MESSAGE         = "received_replyack_message"
 	EC_IGNORE_REPLYACK_MESSAGE           = "ignore_replyack_message"
@@ -88,6 +93,8 @@ const (
 	EC_CONTAINER_STOPPED          = "container_stopped"
 	EC_ERROR_IN_DEPLOYMENT_CONFIG = "error_in_deployment_configuration"
 	EC_ERROR_START_CONTAINER      = "error_start_container"
+	EC_DEPLOYMENT_DENIED_BY_NODE  = "deployment_denied_by_node_policy"
+	EC_IMAGE_DIGEST_MISMATCH      = "image_digest_mismatch"
 
 	EC_IMAGE_LOADED                       = "image_loaded"
 	EC_ERROR_IMAGE_LOADE                  = "error_image_load"
@@ -122,6 +129,7 @@ const (
 	EC_START_UPGRADE_SERVICE    = "start_rollback_service"
 	EC_COMPLETE_UPGRADE_SERVICE = "complete_rollback_service"
 	EC_ERROR_UPGRADE_SERVICE    = "error_rollback_service"
+	EC_DEFER_UPGRADE_SERVICE    = "defer_upgrade_service"
 
 	EC_START_CLEANUP_SERVICE    = "start_cleanup_service"
 	EC_COMPLETE_CLEANUP_SERVICE = "complete_cleanup_service"
@@ -0,0 +1,194 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"sort"
+	"strconv"
+)
+
+// table stores the result of the last event log prune
+const EVENT_LOG_PRUNE = "event_log_prune"
+
+// reasons for pruning an event log
+const (
+	PRUNE_REASON_MAX_AGE            = "max_age"
+	PRUNE_REASON_MAX_COUNT          = "max_count"
+	PRUNE_REASON_SEVERITY_MAX_AGE   = "severity_max_age"
+	PRUNE_REASON_SEVERITY_MAX_COUNT = "severity_max_count"
+)
+
+type EventLogPruneStatus struct {
+	LastPruneTime    uint64         `json:"last_prune_time"`
+	Pruned           int            `json:"pruned"`             // the number of event logs removed by the last prune
+	ByReason         map[string]int `json:"by_reason"`          // the number removed by the last prune for each reason
+	BySeverity       map[string]int `json:"by_severity"`        // the number removed by the last prune for each severity
+	NewestPrunedTime uint64         `json:"newest_pruned_time"` // the timestamp of the newest event log removed by the last prune
+	Remaining        int            `json:"remaining"`          // the number of event logs left after the last prune
+	TotalPruned      uint64         `json:"total_pruned"`       // the number of event logs removed by all prunes
+}
+
+func (s EventLogPruneStatus) String() string {
+	return fmt.Sprintf("LastPruneTime: %v, "+
+		"Pruned: %v, "+
+		"ByReason: %v, "+
+		"BySeverity: %v, "+
+		"NewestPrunedTime: %v, "+
+		"Remaining: %v, "+
+		"TotalPruned: %v",
+		s.LastPruneTime, s.Pruned, s.ByReason, s.BySeverity, s.NewestPrunedTime, s.Remaining, s.TotalPruned)
+}
+
+func NewEventLogPruneStatus() *EventLogPruneStatus {
+	return &EventLogPruneStatus{
+		ByReason:   make(map[string]int),
+		BySeverity: make(map[string]int),
+	}
+}
+
+type pruneEntry struct {
+	key      string
+	id       uint64
+	base     EventLogBase
+	severity config.EventLogSeverityRetention
+}
+
+type pruneEntryByRecordIdDesc []pruneEntry
+
+func (s pruneEntryByRecordIdDesc) Len() int {
+	return len(s)
+}
+
+func (s pruneEntryByRecordIdDesc) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s pruneEntryByRecordIdDesc) Less(i, j int) bool {
+	return s[i].id > s[j].id
+}
+
+// Returns the result of the last prune. If the event logs have never been pruned, the status is empty.
+func GetEventLogPruneStatus(db EdgeDatabase) (*EventLogPruneStatus, error) {
+	status := NewEventLogPruneStatus()
+
+	readErr := db.View(func(tx EdgeTx) error {
+		if b := tx.Bucket([]byte(EVENT_LOG_PRUNE)); b != nil {
+			if v := b.Get([]byte(EVENT_LOG_PRUNE)); v != nil {
+				if err := json.Unmarshal(v, status); err != nil {
+					return fmt.Errorf("Unable to deserialize event log prune status: %v. Error: %v", string(v), err)
+				}
+			}
+		}
+		return nil
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return status, nil
+}
+
+// Remove the event logs that are outside of the retention limits. The limits are applied from the newest event log
+// to the oldest, so the count limits keep the most recent event logs. The result is saved so that it can be
+// retrieved later with GetEventLogPruneStatus.
+func PruneEventLogs(db EdgeDatabase, retention config.EventLogRetentionConfig, now uint64) (*EventLogPruneStatus, error) {
+
+	status := NewEventLogPruneStatus()
+	status.LastPruneTime = now
+
+	writeErr := db.Update(func(tx EdgeTx) error {
+
+		// Carry the running total over from the previous prune.
+		if b := tx.Bucket([]byte(EVENT_LOG_PRUNE)); b != nil {
+			if v := b.Get([]byte(EVENT_LOG_PRUNE)); v != nil {
+				var last EventLogPruneStatus
+				if err := json.Unmarshal(v, &last); err != nil {
+					glog.Errorf("Unable to deserialize event log prune status: %v. Error: %v", string(v), err)
+				} else {
+					status.TotalPruned = last.TotalPruned
+				}
+			}
+		}
+
+		b := tx.Bucket([]byte(EVENT_LOGS))
+		if b == nil {
+			return savePruneStatus(tx, status)
+		}
+
+		// Only the base part of the event log is needed to decide whether to keep it.
+		entries := make([]pruneEntry, 0, 100)
+		b.ForEach(func(k, v []byte) error {
+			var el EventLogBase
+			if id, err := strconv.ParseUint(string(k), 10, 64); err != nil {
+				glog.Errorf("Unable to convert event log key %v to a record id. Error: %v", string(k), err)
+			} else if err := json.Unmarshal(v, &el); err != nil {
+				glog.Errorf("Unable to deserialize event log db record: %v. Error: %v", v, err)
+			} else {
+				entries = append(entries, pruneEntry{key: string(k), id: id, base: el, severity: retention.Severity[el.Severity]})
+			}
+			return nil
+		})
+
+		// The keys are sequence numbers, so sorting on them puts the newest event logs first.
+		sort.Sort(pruneEntryByRecordIdDesc(entries))
+
+		kept := 0
+		keptBySeverity := make(map[string]int)
+		for _, e := range entries {
+			age := uint64(0)
+			if now > e.base.Timestamp {
+				age = now - e.base.Timestamp
+			}
+
+			reason := ""
+			if retention.MaxAgeS != 0 && age > retention.MaxAgeS {
+				reason = PRUNE_REASON_MAX_AGE
+			} else if e.severity.MaxAgeS != 0 && age > e.severity.MaxAgeS {
+				reason = PRUNE_REASON_SEVERITY_MAX_AGE
+			} else if e.severity.MaxCount != 0 && keptBySeverity[e.base.Severity] >= e.severity.MaxCount {
+				reason = PRUNE_REASON_SEVERITY_MAX_COUNT
+			} else if retention.MaxCount != 0 && kept >= retention.MaxCount {
+				reason = PRUNE_REASON_MAX_COUNT
+			}
+
+			if reason == "" {
+				kept++
+				keptBySeverity[e.base.Severity]++
+				continue
+			}
+
+			if err := b.Delete([]byte(e.key)); err != nil {
+				return fmt.Errorf("Unable to delete event log %v. Error: %v", e.key, err)
+			}
+			status.Pruned++
+			status.ByReason[reason]++
+			status.BySeverity[e.base.Severity]++
+			if e.base.Timestamp > status.NewestPrunedTime {
+				status.NewestPrunedTime = e.base.Timestamp
+			}
+		}
+
+		status.Remaining = kept
+		status.TotalPruned += uint64(status.Pruned)
+		return savePruneStatus(tx, status)
+	})
+
+	if writeErr != nil {
+		return nil, writeErr
+	}
+
+	glog.V(3).Infof("Pruned event logs: %v", status)
+	return status, nil
+}
+
+func savePruneStatus(tx EdgeTx, status *EventLogPruneStatus) error {
+	if bucket, err := tx.CreateBucketIfNotExists([]byte(EVENT_LOG_PRUNE)); err != nil {
+		return err
+	} else if serial, err := json.Marshal(status); err != nil {
+		return fmt.Errorf("Failed to serialize the event log prune status: %v. Error: %v", status, err)
+	} else {
+		return bucket.Put([]byte(EVENT_LOG_PRUNE), serial)
+	}
+}
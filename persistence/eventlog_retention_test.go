@@ -0,0 +1,81 @@
+// +build unit
+
+package persistence
+
+import (
+	"github.com/open-horizon/anax/config"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"testing"
+)
+
+func Test_PruneEventLogs(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	// Nothing to prune in an empty database.
+	status, err := PruneEventLogs(db, config.EventLogRetentionConfig{MaxCount: 5}, 10000)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, status.Pruned)
+
+	// 12 event logs, 100 seconds apart, alternating between info and error. The record ids go past 9 so that
+	// the newest event logs are not the last keys in the bucket.
+	source := NewDatabaseEventSource()
+	for i := 1; i <= 12; i++ {
+		severity := SEVERITY_ERROR
+		if i%2 == 1 {
+			severity = SEVERITY_INFO
+		}
+		el := NewEventLog(severity, "message", EC_DATABASE_ERROR, SRC_TYPE_DB, *source)
+		el.Timestamp = uint64(10000 - (13-i)*100)
+		if err := SaveEventLog(db, el); err != nil {
+			t.Errorf("Error saving event log: %v", err)
+		}
+	}
+
+	retention := config.EventLogRetentionConfig{
+		MaxAgeS:  1000,
+		MaxCount: 5,
+		Severity: map[string]config.EventLogSeverityRetention{
+			SEVERITY_INFO: config.EventLogSeverityRetention{MaxCount: 1},
+		},
+	}
+
+	// 1 and 2 are too old, only the newest info log (11) is kept, and the 5 newest of the rest are kept.
+	status, err = PruneEventLogs(db, retention, 10000)
+	assert.Nil(t, err)
+	assert.Equal(t, 7, status.Pruned)
+	assert.Equal(t, 5, status.Remaining)
+	assert.Equal(t, 2, status.ByReason[PRUNE_REASON_MAX_AGE])
+	assert.Equal(t, 4, status.ByReason[PRUNE_REASON_SEVERITY_MAX_COUNT])
+	assert.Equal(t, 1, status.ByReason[PRUNE_REASON_MAX_COUNT])
+	assert.Equal(t, 5, status.BySeverity[SEVERITY_INFO])
+	assert.Equal(t, 2, status.BySeverity[SEVERITY_ERROR])
+	assert.Equal(t, uint64(9600), status.NewestPrunedTime)
+	assert.Equal(t, uint64(7), status.TotalPruned)
+
+	logs, err := FindAllEventLogs(db)
+	assert.Nil(t, err)
+	ids := make([]string, 0, len(logs))
+	for _, el := range logs {
+		ids = append(ids, el.Id)
+	}
+	sort.Strings(ids)
+	assert.Equal(t, []string{"10", "11", "12", "6", "8"}, ids)
+
+	// Pruning again removes nothing, and the total is kept.
+	status, err = PruneEventLogs(db, retention, 10000)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, status.Pruned)
+	assert.Equal(t, 5, status.Remaining)
+	assert.Equal(t, uint64(7), status.TotalPruned)
+
+	saved, err := GetEventLogPruneStatus(db)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(10000), saved.LastPruneTime)
+	assert.Equal(t, uint64(7), saved.TotalPruned)
+}
@@ -3,7 +3,6 @@ package persistence
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"reflect"
 	"strconv"
@@ -150,8 +149,8 @@ func (w EventLog) Matches2(base_selectors map[string][]Selector, source_selector
 }
 
 // save the timestamp for the last unregistration into db.
-func SaveLastUnregistrationTime(db *bolt.DB, last_unreg_time uint64) error {
-	writeErr := db.Update(func(tx *bolt.Tx) error {
+func SaveLastUnregistrationTime(db EdgeDatabase, last_unreg_time uint64) error {
+	writeErr := db.Update(func(tx EdgeTx) error {
 		if bucket, err := tx.CreateBucketIfNotExists([]byte(LAST_UNREG)); err != nil {
 			return err
 		} else {
@@ -163,12 +162,12 @@ func SaveLastUnregistrationTime(db *bolt.DB, last_unreg_time uint64) error {
 }
 
 // Find the event log from the db
-func GetLastUnregistrationTime(db *bolt.DB) (uint64, error) {
+func GetLastUnregistrationTime(db EdgeDatabase) (uint64, error) {
 	var last_unreg uint64
 	last_unreg = 0
 
 	// fetch event logs
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 
 		if b := tx.Bucket([]byte(LAST_UNREG)); b != nil {
 			v := b.Get([]byte("lastunreg"))
@@ -190,8 +189,8 @@ func GetLastUnregistrationTime(db *bolt.DB) (uint64, error) {
 }
 
 // save the event log record into db.
-func SaveEventLog(db *bolt.DB, event_log *EventLog) error {
-	writeErr := db.Update(func(tx *bolt.Tx) error {
+func SaveEventLog(db EdgeDatabase, event_log *EventLog) error {
+	writeErr := db.Update(func(tx EdgeTx) error {
 		if bucket, err := tx.CreateBucketIfNotExists([]byte(EVENT_LOGS)); err != nil {
 			return err
 		} else if nextKey, err := bucket.NextSequence(); err != nil {
@@ -261,12 +260,12 @@ func getRealEventSource(source_type string, src *json.RawMessage) (*EventSourceI
 }
 
 // Find the event log from the db
-func FindEventLogWithKey(db *bolt.DB, key string) (*EventLog, error) {
+func FindEventLogWithKey(db EdgeDatabase, key string) (*EventLog, error) {
 	var pel *EventLog
 	pel = nil
 
 	// fetch event logs
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 
 		if b := tx.Bucket([]byte(EVENT_LOGS)); b != nil {
 			v := b.Get([]byte(key))
@@ -318,11 +317,11 @@ func SelectorFilter(selectors map[string][]Selector) EventLogFilter {
 }
 
 // find event logs from the db for the given filters
-func FindEventLogs(db *bolt.DB, filters []EventLogFilter) ([]EventLog, error) {
+func FindEventLogs(db EdgeDatabase, filters []EventLogFilter) ([]EventLog, error) {
 	evlogs := make([]EventLog, 0)
 
 	// fetch logs
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 
 		if b := tx.Bucket([]byte(EVENT_LOGS)); b != nil {
 			b.ForEach(func(k, v []byte) error {
@@ -366,7 +365,7 @@ func FindEventLogs(db *bolt.DB, filters []EventLogFilter) ([]EventLog, error) {
 
 // find event logs from the db for the given given selectors.
 // If all_logs is false, only the event logs for the current registration is returned.
-func FindEventLogsWithSelectors(db *bolt.DB, all_logs bool, selectors map[string][]Selector) ([]EventLog, error) {
+func FindEventLogsWithSelectors(db EdgeDatabase, all_logs bool, selectors map[string][]Selector) ([]EventLog, error) {
 	// separate base selectors from the source selectors
 	base_selectors, source_selectors := GroupSelectors(selectors)
 
@@ -382,7 +381,7 @@ func FindEventLogsWithSelectors(db *bolt.DB, all_logs bool, selectors map[string
 	}
 
 	// fetch logs
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 
 		if b := tx.Bucket([]byte(EVENT_LOGS)); b != nil {
 			b.ForEach(func(k, v []byte) error {
@@ -416,11 +415,11 @@ func FindEventLogsWithSelectors(db *bolt.DB, all_logs bool, selectors map[string
 }
 
 // find all event logs from the db
-func FindAllEventLogs(db *bolt.DB) ([]EventLog, error) {
+func FindAllEventLogs(db EdgeDatabase) ([]EventLog, error) {
 	evlogs := make([]EventLog, 0)
 
 	// fetch logs
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 
 		if b := tx.Bucket([]byte(EVENT_LOGS)); b != nil {
 			b.ForEach(func(k, v []byte) error {
@@ -0,0 +1,177 @@
+package persistence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The result of checking the consistency of the edge database. A database with structural errors or records that
+// cant be decoded is not usable. Orphaned records are records that refer to other records which are archived or
+// missing. The runtime can live with orphans, but they usually mean that the node was stopped in the middle of
+// changing its state, for example by a power cut.
+type FsckReport struct {
+	Database   string        `json:"database"`
+	Type       string        `json:"type"`
+	Version    int           `json:"schema_version"`
+	CheckTime  uint64        `json:"check_time"`
+	Buckets    int           `json:"buckets"`
+	Records    int           `json:"records"`
+	Structural []string      `json:"structural_errors"`
+	Corrupt    []FsckProblem `json:"corrupt_records"`
+	Orphans    []FsckProblem `json:"orphaned_records"`
+}
+
+type FsckProblem struct {
+	Bucket  string `json:"bucket"`
+	Key     string `json:"key,omitempty"`
+	Problem string `json:"problem"`
+}
+
+func (r FsckReport) String() string {
+	return fmt.Sprintf("Database: %v, "+
+		"Type: %v, "+
+		"Version: %v, "+
+		"CheckTime: %v, "+
+		"Buckets: %v, "+
+		"Records: %v, "+
+		"Structural: %v, "+
+		"Corrupt: %v, "+
+		"Orphans: %v",
+		r.Database, r.Type, r.Version, r.CheckTime, r.Buckets, r.Records, r.Structural, r.Corrupt, r.Orphans)
+}
+
+// Returns true if the database can be used by the runtime.
+func (r *FsckReport) IsUsable() bool {
+	return len(r.Structural) == 0 && len(r.Corrupt) == 0
+}
+
+// Returns true if no problems at all were found.
+func (r *FsckReport) IsClean() bool {
+	return r.IsUsable() && len(r.Orphans) == 0
+}
+
+func (r *FsckReport) addCorrupt(bucket string, key string, problem string) {
+	r.Corrupt = append(r.Corrupt, FsckProblem{Bucket: bucket, Key: key, Problem: problem})
+}
+
+func (r *FsckReport) addOrphan(bucket string, key string, problem string) {
+	r.Orphans = append(r.Orphans, FsckProblem{Bucket: bucket, Key: key, Problem: problem})
+}
+
+// Check the consistency of the edge database. The database is read in a single read-only transaction, so this can
+// be run while the runtime is using the database.
+func CheckDatabase(db EdgeDatabase) (*FsckReport, error) {
+
+	report := &FsckReport{
+		Database:   db.Path(),
+		Type:       db.Type(),
+		CheckTime:  uint64(time.Now().Unix()),
+		Structural: make([]string, 0),
+		Corrupt:    make([]FsckProblem, 0),
+		Orphans:    make([]FsckProblem, 0),
+	}
+
+	// The bolt DB can check its own page structure. Other implementations rely on their own tools for that.
+	if bdb, ok := db.(*EdgeBoltDB); ok {
+		if err := bdb.db.View(func(tx *bolt.Tx) error {
+			for err := range tx.Check() {
+				report.Structural = append(report.Structural, err.Error())
+			}
+			return nil
+		}); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to check the structure of database %v, error: %v", db.Path(), err))
+		}
+	}
+
+	// Decode the records in every bucket, remembering the keys that other records can refer to.
+	agreements := make(map[string]bool) // agreement id -> archived
+	msdefs := make(map[string]bool)
+	instances := make(map[string]MicroserviceInstance)
+
+	err := db.View(func(tx EdgeTx) error {
+		return tx.ForEachBucket(func(name []byte, b EdgeBucket) error {
+			report.Buckets++
+			bucket := string(name)
+			return b.ForEach(func(k []byte, v []byte) error {
+				report.Records++
+				key := string(k)
+
+				var err error
+				switch {
+				case bucket == METADATA && key == SCHEMA_VERSION:
+					var sv SchemaVersion
+					if err = json.Unmarshal(v, &sv); err == nil {
+						report.Version = sv.Version
+					}
+				case bucket == DEVICES:
+					var dev ExchangeDevice
+					err = json.Unmarshal(v, &dev)
+				case bucket == EVENT_LOGS:
+					var el EventLogRaw
+					err = json.Unmarshal(v, &el)
+				case bucket == MICROSERVICE_DEFINITIONS:
+					var msdef MicroserviceDefinition
+					if err = json.Unmarshal(v, &msdef); err == nil {
+						msdefs[key] = true
+					}
+				case bucket == MICROSERVICE_INSTANCES:
+					var msi MicroserviceInstance
+					if err = json.Unmarshal(v, &msi); err == nil {
+						instances[key] = msi
+					}
+				case strings.HasPrefix(bucket, E_AGREEMENTS+"-"):
+					var ag EstablishedAgreement
+					if err = json.Unmarshal(v, &ag); err == nil {
+						if archived, ok := agreements[ag.CurrentAgreementId]; !ok || archived {
+							agreements[ag.CurrentAgreementId] = ag.Archived
+						}
+					}
+				default:
+					if !json.Valid(v) {
+						err = errors.New("not a valid JSON document")
+					}
+				}
+
+				if err != nil {
+					report.addCorrupt(bucket, key, fmt.Sprintf("unable to decode record: %v", err))
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read database %v, error: %v", db.Path(), err))
+	}
+
+	// Look for service instances that refer to agreements or service definitions that are gone. Archived instances
+	// are history, so what they point at doesnt matter anymore.
+	keys := make([]string, 0, len(instances))
+	for key, _ := range instances {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		msi := instances[key]
+		if msi.Archived {
+			continue
+		}
+		for _, agId := range msi.AssociatedAgreements {
+			if archived, ok := agreements[agId]; !ok {
+				report.addOrphan(MICROSERVICE_INSTANCES, key, fmt.Sprintf("associated agreement %v does not exist", agId))
+			} else if archived {
+				report.addOrphan(MICROSERVICE_INSTANCES, key, fmt.Sprintf("associated agreement %v is archived", agId))
+			}
+		}
+		if msi.MicroserviceDefId != "" && !msdefs[msi.MicroserviceDefId] {
+			report.addOrphan(MICROSERVICE_INSTANCES, key, fmt.Sprintf("service definition %v does not exist", msi.MicroserviceDefId))
+		}
+	}
+
+	return report, nil
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/satori/go.uuid"
@@ -156,6 +155,7 @@ type MicroserviceDefinition struct {
 	UngradeFailureReason         uint64                `json:"upgrade_failure_reason"`
 	UngradeFailureDescription    string                `json:"upgrade_failure_description"`
 	UpgradeNewMsId               string                `json:"upgrade_new_ms_id"`
+	UpgradeDeferredUntil         uint64                `json:"upgrade_deferred_until"`
 	MetadataHash                 []byte                `json:"metadata_hash"` // the hash of the whole exchange.MicroserviceDefinition
 
 }
@@ -195,12 +195,13 @@ func (w MicroserviceDefinition) String() string {
 		"UngradeFailureReason: %v, "+
 		"UngradeFailureDescription: %v, "+
 		"UpgradeNewMsId: %v, "+
+		"UpgradeDeferredUntil: %v, "+
 		"MetadataHash: %v",
 		w.Id, w.Owner, w.Label, w.Description, w.SpecRef, w.Org, w.Version, w.Arch, w.Sharable, w.DownloadURL,
 		w.MatchHardware, w.UserInputs, w.Workloads, w.Public, w.RequiredServices, w.Deployment, w.DeploymentSignature, w.ImageStore, w.LastUpdated,
 		w.Archived, w.Name, w.RequestedArch, w.UpgradeVersionRange, w.AutoUpgrade, w.ActiveUpgrade,
 		w.UpgradeStartTime, w.UpgradeMsUnregisteredTime, w.UpgradeAgreementsClearedTime, w.UpgradeExecutionStartTime, w.UpgradeMsReregisteredTime,
-		w.UpgradeFailedTime, w.UngradeFailureReason, w.UngradeFailureDescription, w.UpgradeNewMsId, w.MetadataHash)
+		w.UpgradeFailedTime, w.UngradeFailureReason, w.UngradeFailureDescription, w.UpgradeNewMsId, w.UpgradeDeferredUntil, w.MetadataHash)
 }
 
 func (w MicroserviceDefinition) ShortString() string {
@@ -226,11 +227,12 @@ func (w MicroserviceDefinition) ShortString() string {
 		"UngradeFailureReason: %v, "+
 		"UngradeFailureDescription: %v, "+
 		"UpgradeNewMsId: %v, "+
+		"UpgradeDeferredUntil: %v, "+
 		"MetadataHash: %v",
 		w.Owner, w.Label, w.Description, w.SpecRef, w.Org, w.Version, w.Arch,
 		w.Archived, w.Name, w.RequestedArch, w.UpgradeVersionRange, w.AutoUpgrade, w.ActiveUpgrade,
 		w.UpgradeStartTime, w.UpgradeMsUnregisteredTime, w.UpgradeAgreementsClearedTime, w.UpgradeExecutionStartTime, w.UpgradeMsReregisteredTime,
-		w.UpgradeFailedTime, w.UngradeFailureReason, w.UngradeFailureDescription, w.UpgradeNewMsId, w.MetadataHash)
+		w.UpgradeFailedTime, w.UngradeFailureReason, w.UngradeFailureDescription, w.UpgradeNewMsId, w.UpgradeDeferredUntil, w.MetadataHash)
 }
 
 func (m *MicroserviceDefinition) HasDeployment() bool {
@@ -283,8 +285,8 @@ func (m *MicroserviceDefinition) HasRequiredServices() bool {
 }
 
 // save the microservice record. update if it already exists in the db
-func SaveOrUpdateMicroserviceDef(db *bolt.DB, msdef *MicroserviceDefinition) error {
-	writeErr := db.Update(func(tx *bolt.Tx) error {
+func SaveOrUpdateMicroserviceDef(db EdgeDatabase, msdef *MicroserviceDefinition) error {
+	writeErr := db.Update(func(tx EdgeTx) error {
 		if bucket, err := tx.CreateBucketIfNotExists([]byte(MICROSERVICE_DEFINITIONS)); err != nil {
 			return err
 		} else if nextKey, err := bucket.NextSequence(); err != nil {
@@ -307,17 +309,17 @@ func SaveOrUpdateMicroserviceDef(db *bolt.DB, msdef *MicroserviceDefinition) err
 }
 
 // find the unarchived microservice definitions for the given url and org
-func FindUnarchivedMicroserviceDefs(db *bolt.DB, url string, org string) ([]MicroserviceDefinition, error) {
+func FindUnarchivedMicroserviceDefs(db EdgeDatabase, url string, org string) ([]MicroserviceDefinition, error) {
 	return FindMicroserviceDefs(db, []MSFilter{UnarchivedMSFilter(), UrlOrgMSFilter(url, org)})
 }
 
 // find the microservice definition from the db
-func FindMicroserviceDefWithKey(db *bolt.DB, key string) (*MicroserviceDefinition, error) {
+func FindMicroserviceDefWithKey(db EdgeDatabase, key string) (*MicroserviceDefinition, error) {
 	var pms *MicroserviceDefinition
 	pms = nil
 
 	// fetch microservice definitions
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 
 		if b := tx.Bucket([]byte(MICROSERVICE_DEFINITIONS)); b != nil {
 			v := b.Get([]byte(key))
@@ -381,11 +383,11 @@ func UrlMSFilter(spec_url string) MSFilter {
 }
 
 // find the microservice instance from the db
-func FindMicroserviceDefs(db *bolt.DB, filters []MSFilter) ([]MicroserviceDefinition, error) {
+func FindMicroserviceDefs(db EdgeDatabase, filters []MSFilter) ([]MicroserviceDefinition, error) {
 	ms_defs := make([]MicroserviceDefinition, 0)
 
 	// fetch contracts
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 
 		if b := tx.Bucket([]byte(MICROSERVICE_DEFINITIONS)); b != nil {
 			b.ForEach(func(k, v []byte) error {
@@ -421,7 +423,7 @@ func FindMicroserviceDefs(db *bolt.DB, filters []MSFilter) ([]MicroserviceDefini
 }
 
 // set the msdef to archived
-func MsDefArchived(db *bolt.DB, key string) (*MicroserviceDefinition, error) {
+func MsDefArchived(db EdgeDatabase, key string) (*MicroserviceDefinition, error) {
 	return microserviceDefStateUpdate(db, key, func(c MicroserviceDefinition) *MicroserviceDefinition {
 		c.Archived = true
 		return &c
@@ -429,49 +431,49 @@ func MsDefArchived(db *bolt.DB, key string) (*MicroserviceDefinition, error) {
 }
 
 // set the msdef to un-archived
-func MsDefUnarchived(db *bolt.DB, key string) (*MicroserviceDefinition, error) {
+func MsDefUnarchived(db EdgeDatabase, key string) (*MicroserviceDefinition, error) {
 	return microserviceDefStateUpdate(db, key, func(c MicroserviceDefinition) *MicroserviceDefinition {
 		c.Archived = false
 		return &c
 	})
 }
 
-func MSDefUpgradeStarted(db *bolt.DB, key string) (*MicroserviceDefinition, error) {
+func MSDefUpgradeStarted(db EdgeDatabase, key string) (*MicroserviceDefinition, error) {
 	return microserviceDefStateUpdate(db, key, func(c MicroserviceDefinition) *MicroserviceDefinition {
 		c.UpgradeStartTime = uint64(time.Now().Unix())
 		return &c
 	})
 }
 
-func MSDefUpgradeMsUnregistered(db *bolt.DB, key string) (*MicroserviceDefinition, error) {
+func MSDefUpgradeMsUnregistered(db EdgeDatabase, key string) (*MicroserviceDefinition, error) {
 	return microserviceDefStateUpdate(db, key, func(c MicroserviceDefinition) *MicroserviceDefinition {
 		c.UpgradeMsUnregisteredTime = uint64(time.Now().Unix())
 		return &c
 	})
 }
 
-func MsDefUpgradeAgreementsCleared(db *bolt.DB, key string) (*MicroserviceDefinition, error) {
+func MsDefUpgradeAgreementsCleared(db EdgeDatabase, key string) (*MicroserviceDefinition, error) {
 	return microserviceDefStateUpdate(db, key, func(c MicroserviceDefinition) *MicroserviceDefinition {
 		c.UpgradeAgreementsClearedTime = uint64(time.Now().Unix())
 		return &c
 	})
 }
 
-func MSDefUpgradeExecutionStarted(db *bolt.DB, key string) (*MicroserviceDefinition, error) {
+func MSDefUpgradeExecutionStarted(db EdgeDatabase, key string) (*MicroserviceDefinition, error) {
 	return microserviceDefStateUpdate(db, key, func(c MicroserviceDefinition) *MicroserviceDefinition {
 		c.UpgradeExecutionStartTime = uint64(time.Now().Unix())
 		return &c
 	})
 }
 
-func MSDefUpgradeMsReregistered(db *bolt.DB, key string) (*MicroserviceDefinition, error) {
+func MSDefUpgradeMsReregistered(db EdgeDatabase, key string) (*MicroserviceDefinition, error) {
 	return microserviceDefStateUpdate(db, key, func(c MicroserviceDefinition) *MicroserviceDefinition {
 		c.UpgradeMsReregisteredTime = uint64(time.Now().Unix())
 		return &c
 	})
 }
 
-func MSDefUpgradeFailed(db *bolt.DB, key string, reason uint64, reasonString string) (*MicroserviceDefinition, error) {
+func MSDefUpgradeFailed(db EdgeDatabase, key string, reason uint64, reasonString string) (*MicroserviceDefinition, error) {
 	return microserviceDefStateUpdate(db, key, func(c MicroserviceDefinition) *MicroserviceDefinition {
 		c.UpgradeFailedTime = uint64(time.Now().Unix())
 		c.UngradeFailureReason = reason
@@ -480,14 +482,21 @@ func MSDefUpgradeFailed(db *bolt.DB, key string, reason uint64, reasonString str
 	})
 }
 
-func MSDefUpgradeNewMsId(db *bolt.DB, key string, new_id string) (*MicroserviceDefinition, error) {
+func MSDefUpgradeNewMsId(db EdgeDatabase, key string, new_id string) (*MicroserviceDefinition, error) {
 	return microserviceDefStateUpdate(db, key, func(c MicroserviceDefinition) *MicroserviceDefinition {
 		c.UpgradeNewMsId = new_id
 		return &c
 	})
 }
 
-func MSDefNewUpgradeVersionRange(db *bolt.DB, key string, version_range string) (*MicroserviceDefinition, error) {
+func MSDefUpgradeDeferred(db EdgeDatabase, key string, until uint64) (*MicroserviceDefinition, error) {
+	return microserviceDefStateUpdate(db, key, func(c MicroserviceDefinition) *MicroserviceDefinition {
+		c.UpgradeDeferredUntil = until
+		return &c
+	})
+}
+
+func MSDefNewUpgradeVersionRange(db EdgeDatabase, key string, version_range string) (*MicroserviceDefinition, error) {
 	return microserviceDefStateUpdate(db, key, func(c MicroserviceDefinition) *MicroserviceDefinition {
 		c.UpgradeVersionRange = version_range
 		return &c
@@ -495,7 +504,7 @@ func MSDefNewUpgradeVersionRange(db *bolt.DB, key string, version_range string)
 }
 
 // update the micorserive definition
-func microserviceDefStateUpdate(db *bolt.DB, key string, fn func(MicroserviceDefinition) *MicroserviceDefinition) (*MicroserviceDefinition, error) {
+func microserviceDefStateUpdate(db EdgeDatabase, key string, fn func(MicroserviceDefinition) *MicroserviceDefinition) (*MicroserviceDefinition, error) {
 
 	if ms, err := FindMicroserviceDefWithKey(db, key); err != nil {
 		return nil, err
@@ -509,8 +518,8 @@ func microserviceDefStateUpdate(db *bolt.DB, key string, fn func(MicroserviceDef
 }
 
 // does whole-member replacements of values that are legal to change
-func persistUpdatedMicroserviceDef(db *bolt.DB, key string, update *MicroserviceDefinition) error {
-	return db.Update(func(tx *bolt.Tx) error {
+func persistUpdatedMicroserviceDef(db EdgeDatabase, key string, update *MicroserviceDefinition) error {
+	return db.Update(func(tx EdgeTx) error {
 		if b, err := tx.CreateBucketIfNotExists([]byte(MICROSERVICE_DEFINITIONS)); err != nil {
 			return err
 		} else {
@@ -563,6 +572,10 @@ func persistUpdatedMicroserviceDef(db *bolt.DB, key string, update *Microservice
 					mod.UpgradeVersionRange = update.UpgradeVersionRange
 				}
 
+				if mod.UpgradeDeferredUntil != update.UpgradeDeferredUntil {
+					mod.UpgradeDeferredUntil = update.UpgradeDeferredUntil
+				}
+
 				if serialized, err := json.Marshal(mod); err != nil {
 					return fmt.Errorf("Failed to serialize contract record: %v. Error: %v", mod, err)
 				} else if err := b.Put([]byte(key), serialized); err != nil {
@@ -637,7 +650,7 @@ func (m MicroserviceInstance) GetKey() string {
 
 // Check if this microservice instance has a container dpeloyment.
 // If it does not, then there is no nothing to execute.
-func (m MicroserviceInstance) HasWorkload(db *bolt.DB) (bool, error) {
+func (m MicroserviceInstance) HasWorkload(db EdgeDatabase) (bool, error) {
 	if msdef, err := FindMicroserviceDefWithKey(db, m.MicroserviceDefId); err != nil {
 		return false, err
 	} else if msdef.HasDeployment() {
@@ -685,7 +698,7 @@ func (m *MicroserviceInstance) GetDirectParents() []ServiceInstancePathElement {
 }
 
 // create a new microservice instance and save it to db.
-func NewMicroserviceInstance(db *bolt.DB, ref_url string, org string, version string, msdef_id string, dependencyPath []ServiceInstancePathElement) (*MicroserviceInstance, error) {
+func NewMicroserviceInstance(db EdgeDatabase, ref_url string, org string, version string, msdef_id string, dependencyPath []ServiceInstancePathElement) (*MicroserviceInstance, error) {
 
 	if ref_url == "" || org == "" || version == "" {
 		return nil, errors.New("Microservice ref url id, org or version is empty, cannot persist")
@@ -748,12 +761,12 @@ func AgreementToMicroserviceInstance(ag EstablishedAgreement, msdef_id string) *
 }
 
 // find the microservice instance from the db
-func FindMicroserviceInstance(db *bolt.DB, url string, org string, version string, instance_id string) (*MicroserviceInstance, error) {
+func FindMicroserviceInstance(db EdgeDatabase, url string, org string, version string, instance_id string) (*MicroserviceInstance, error) {
 	var pms *MicroserviceInstance
 	pms = nil
 
 	// fetch microservice instances
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 
 		if b := tx.Bucket([]byte(MICROSERVICE_INSTANCES)); b != nil {
 			b.ForEach(func(k, v []byte) error {
@@ -784,12 +797,12 @@ func FindMicroserviceInstance(db *bolt.DB, url string, org string, version strin
 }
 
 // find the microservice instance from the db
-func FindMicroserviceInstanceWithKey(db *bolt.DB, key string) (*MicroserviceInstance, error) {
+func FindMicroserviceInstanceWithKey(db EdgeDatabase, key string) (*MicroserviceInstance, error) {
 	var pms *MicroserviceInstance
 	pms = nil
 
 	// fetch microservice instances
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 
 		if b := tx.Bucket([]byte(MICROSERVICE_INSTANCES)); b != nil {
 			v := b.Get([]byte(key))
@@ -845,11 +858,11 @@ func NotCleanedUpMIFilter() MIFilter {
 }
 
 // find the microservice instance from the db
-func FindMicroserviceInstances(db *bolt.DB, filters []MIFilter) ([]MicroserviceInstance, error) {
+func FindMicroserviceInstances(db EdgeDatabase, filters []MIFilter) ([]MicroserviceInstance, error) {
 	ms_instances := make([]MicroserviceInstance, 0)
 
 	// fetch contracts
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 
 		if b := tx.Bucket([]byte(MICROSERVICE_INSTANCES)); b != nil {
 			b.ForEach(func(k, v []byte) error {
@@ -885,7 +898,7 @@ func FindMicroserviceInstances(db *bolt.DB, filters []MIFilter) ([]MicroserviceI
 }
 
 // set microservice instance state to execution started or failed
-func UpdateMSInstanceExecutionState(db *bolt.DB, key string, started bool, failure_code uint, failure_desc string) (*MicroserviceInstance, error) {
+func UpdateMSInstanceExecutionState(db EdgeDatabase, key string, started bool, failure_code uint, failure_desc string) (*MicroserviceInstance, error) {
 	if started {
 		return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 			c.ExecutionStartTime = uint64(time.Now().Unix())
@@ -904,7 +917,7 @@ func UpdateMSInstanceExecutionState(db *bolt.DB, key string, started bool, failu
 }
 
 // add or delete an associated agreement id to/from the microservice instance in the db
-func UpdateMSInstanceAssociatedAgreements(db *bolt.DB, key string, add bool, agreement_id string) (*MicroserviceInstance, error) {
+func UpdateMSInstanceAssociatedAgreements(db EdgeDatabase, key string, add bool, agreement_id string) (*MicroserviceInstance, error) {
 	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 		if c.AssociatedAgreements == nil {
 			c.AssociatedAgreements = make([]string, 0)
@@ -928,14 +941,14 @@ func UpdateMSInstanceAssociatedAgreements(db *bolt.DB, key string, add bool, agr
 	})
 }
 
-func ArchiveMicroserviceInstance(db *bolt.DB, key string) (*MicroserviceInstance, error) {
+func ArchiveMicroserviceInstance(db EdgeDatabase, key string) (*MicroserviceInstance, error) {
 	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 		c.Archived = true
 		return &c
 	})
 }
 
-func MicroserviceInstanceCleanupStarted(db *bolt.DB, key string) (*MicroserviceInstance, error) {
+func MicroserviceInstanceCleanupStarted(db EdgeDatabase, key string) (*MicroserviceInstance, error) {
 	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 		c.CleanupStartTime = uint64(time.Now().Unix())
 		return &c
@@ -943,7 +956,7 @@ func MicroserviceInstanceCleanupStarted(db *bolt.DB, key string) (*MicroserviceI
 }
 
 // Add the given path to the ParentPath. It will not be added if there is duplicate path.
-func UpdateMSInstanceAddDependencyPath(db *bolt.DB, key string, dp *[]ServiceInstancePathElement) (*MicroserviceInstance, error) {
+func UpdateMSInstanceAddDependencyPath(db EdgeDatabase, key string, dp *[]ServiceInstancePathElement) (*MicroserviceInstance, error) {
 	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 		if dp != nil && len(*dp) != 0 {
 			found := false
@@ -963,7 +976,7 @@ func UpdateMSInstanceAddDependencyPath(db *bolt.DB, key string, dp *[]ServiceIns
 }
 
 // remove the given path to the ParentPath.
-func UpdateMSInstanceRemoveDependencyPath(db *bolt.DB, key string, dp *[]ServiceInstancePathElement) (*MicroserviceInstance, error) {
+func UpdateMSInstanceRemoveDependencyPath(db EdgeDatabase, key string, dp *[]ServiceInstancePathElement) (*MicroserviceInstance, error) {
 	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 		if dp != nil && len(*dp) != 0 {
 			new_pp := make([][]ServiceInstancePathElement, 0)
@@ -980,7 +993,7 @@ func UpdateMSInstanceRemoveDependencyPath(db *bolt.DB, key string, dp *[]Service
 }
 
 // Remove all the paths with the given top parent from the ParentPath
-func UpdateMSInstanceRemoveDependencyPath2(db *bolt.DB, key string, top_parent *ServiceInstancePathElement) (*MicroserviceInstance, error) {
+func UpdateMSInstanceRemoveDependencyPath2(db EdgeDatabase, key string, top_parent *ServiceInstancePathElement) (*MicroserviceInstance, error) {
 	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 		if top_parent != nil {
 			new_pp := make([][]ServiceInstancePathElement, 0)
@@ -995,7 +1008,7 @@ func UpdateMSInstanceRemoveDependencyPath2(db *bolt.DB, key string, top_parent *
 	})
 }
 
-func UpdateMSInstanceAgreementLess(db *bolt.DB, key string) (*MicroserviceInstance, error) {
+func UpdateMSInstanceAgreementLess(db EdgeDatabase, key string) (*MicroserviceInstance, error) {
 	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 		c.AgreementLess = true
 		return &c
@@ -1003,7 +1016,7 @@ func UpdateMSInstanceAgreementLess(db *bolt.DB, key string) (*MicroserviceInstan
 }
 
 // This function is call when the retry starts or retry is done. When it is done, this function resets the retry counts
-func UpdateMSInstanceRetryState(db *bolt.DB, key string, started bool, max_retries uint, max_retry_duration uint) (*MicroserviceInstance, error) {
+func UpdateMSInstanceRetryState(db EdgeDatabase, key string, started bool, max_retries uint, max_retry_duration uint) (*MicroserviceInstance, error) {
 	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 		if started {
 			c.RetryStartTime = uint64(time.Now().Unix())
@@ -1020,14 +1033,14 @@ func UpdateMSInstanceRetryState(db *bolt.DB, key string, started bool, max_retri
 	})
 }
 
-func UpdateMSInstanceCurrentRetryCount(db *bolt.DB, key string, current_retry uint) (*MicroserviceInstance, error) {
+func UpdateMSInstanceCurrentRetryCount(db EdgeDatabase, key string, current_retry uint) (*MicroserviceInstance, error) {
 	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 		c.CurrentRetryCount = current_retry
 		return &c
 	})
 }
 
-func ResetMsInstanceExecutionStatus(db *bolt.DB, key string) (*MicroserviceInstance, error) {
+func ResetMsInstanceExecutionStatus(db EdgeDatabase, key string) (*MicroserviceInstance, error) {
 	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 		c.ExecutionStartTime = 0
 		c.ExecutionFailureCode = 0
@@ -1038,7 +1051,7 @@ func ResetMsInstanceExecutionStatus(db *bolt.DB, key string) (*MicroserviceInsta
 }
 
 // update the micorserive instance
-func microserviceInstanceStateUpdate(db *bolt.DB, key string, fn func(MicroserviceInstance) *MicroserviceInstance) (*MicroserviceInstance, error) {
+func microserviceInstanceStateUpdate(db EdgeDatabase, key string, fn func(MicroserviceInstance) *MicroserviceInstance) (*MicroserviceInstance, error) {
 
 	if ms, err := FindMicroserviceInstanceWithKey(db, key); err != nil {
 		return nil, err
@@ -1052,8 +1065,8 @@ func microserviceInstanceStateUpdate(db *bolt.DB, key string, fn func(Microservi
 }
 
 // does whole-member replacements of values that are legal to change
-func persistUpdatedMicroserviceInstance(db *bolt.DB, key string, update *MicroserviceInstance) error {
-	return db.Update(func(tx *bolt.Tx) error {
+func persistUpdatedMicroserviceInstance(db EdgeDatabase, key string, update *MicroserviceInstance) error {
+	return db.Update(func(tx EdgeTx) error {
 		if b, err := tx.CreateBucketIfNotExists([]byte(MICROSERVICE_INSTANCES)); err != nil {
 			return err
 		} else {
@@ -1107,7 +1120,7 @@ func persistUpdatedMicroserviceInstance(db *bolt.DB, key string, update *Microse
 }
 
 // delete associated agreement id from all the microservice instances
-func DeleteAsscAgmtsFromMSInstances(db *bolt.DB, agreement_id string) error {
+func DeleteAsscAgmtsFromMSInstances(db EdgeDatabase, agreement_id string) error {
 	if ms_instances, err := FindMicroserviceInstances(db, []MIFilter{UnarchivedMIFilter()}); err != nil {
 		return fmt.Errorf("Error retrieving all service instances from database, error: %v", err)
 	} else if ms_instances != nil {
@@ -1128,7 +1141,7 @@ func DeleteAsscAgmtsFromMSInstances(db *bolt.DB, agreement_id string) error {
 }
 
 // delete a microservice instance from db. It will NOT return error if it does not exist in the db
-func DeleteMicroserviceInstance(db *bolt.DB, key string) (*MicroserviceInstance, error) {
+func DeleteMicroserviceInstance(db EdgeDatabase, key string) (*MicroserviceInstance, error) {
 
 	if key == "" {
 		return nil, errors.New("key is empty, cannot remove")
@@ -1138,7 +1151,7 @@ func DeleteMicroserviceInstance(db *bolt.DB, key string) (*MicroserviceInstance,
 		} else if ms == nil {
 			return nil, nil
 		} else {
-			return ms, db.Update(func(tx *bolt.Tx) error {
+			return ms, db.Update(func(tx EdgeTx) error {
 
 				if b, err := tx.CreateBucketIfNotExists([]byte(MICROSERVICE_INSTANCES)); err != nil {
 					return err
@@ -1198,8 +1211,8 @@ func createInstanceId() (string, error) {
 }
 
 // save the given microservice instance into the db
-func saveMicroserviceInstance(db *bolt.DB, new_inst *MicroserviceInstance) (*MicroserviceInstance, error) {
-	return new_inst, db.Update(func(tx *bolt.Tx) error {
+func saveMicroserviceInstance(db EdgeDatabase, new_inst *MicroserviceInstance) (*MicroserviceInstance, error) {
+	return new_inst, db.Update(func(tx EdgeTx) error {
 		if b, err := tx.CreateBucketIfNotExists([]byte(MICROSERVICE_INSTANCES)); err != nil {
 			return err
 		} else if bytes, err := json.Marshal(new_inst); err != nil {
@@ -3,12 +3,10 @@
 package persistence
 
 import (
-	"github.com/boltdb/bolt"
 	"io/ioutil"
 	"os"
 	"path"
 	"testing"
-	"time"
 )
 
 // Parent and child, simple case.
@@ -372,13 +370,13 @@ func Test_UpdateMSInstanceRemoveDependencyPath2(t *testing.T) {
 }
 
 // Utility functions needed by tests
-func utsetup() (string, *bolt.DB, error) {
+func utsetup() (string, EdgeDatabase, error) {
 	dir, err := ioutil.TempDir("", "utdb-")
 	if err != nil {
 		return "", nil, err
 	}
 
-	db, err := bolt.Open(path.Join(dir, "anax-ut.db"), 0600, &bolt.Options{Timeout: 10 * time.Second})
+	db, err := OpenBoltDatabase(path.Join(dir, "anax-ut.db"))
 	if err != nil {
 		return dir, nil, err
 	}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/cutil"
 	"time"
@@ -114,7 +113,7 @@ func (c EstablishedAgreement) String() string {
 
 }
 
-func NewEstablishedAgreement(db *bolt.DB, name string, agreementId string, consumerId string, proposal string, protocol string, protocolVersion int, dependentSvcs ServiceSpecs, signature string, address string, bcType string, bcName string, bcOrg string, wi *WorkloadInfo) (*EstablishedAgreement, error) {
+func NewEstablishedAgreement(db EdgeDatabase, name string, agreementId string, consumerId string, proposal string, protocol string, protocolVersion int, dependentSvcs ServiceSpecs, signature string, address string, bcType string, bcName string, bcOrg string, wi *WorkloadInfo) (*EstablishedAgreement, error) {
 
 	if name == "" || agreementId == "" || consumerId == "" || proposal == "" || protocol == "" || protocolVersion == 0 {
 		return nil, errors.New("Agreement id, consumer id, proposal, protocol, or protocol version are empty, cannot persist")
@@ -162,7 +161,7 @@ func NewEstablishedAgreement(db *bolt.DB, name string, agreementId string, consu
 		RunningWorkload:                 *wi,
 	}
 
-	return newAg, db.Update(func(tx *bolt.Tx) error {
+	return newAg, db.Update(func(tx EdgeTx) error {
 
 		if b, err := tx.CreateBucketIfNotExists([]byte(E_AGREEMENTS + "-" + protocol)); err != nil {
 			return err
@@ -197,7 +196,7 @@ func (a *EstablishedAgreement) GetDeploymentConfig() DeploymentConfig {
 	return nil
 }
 
-func ArchiveEstablishedAgreement(db *bolt.DB, agreementId string, protocol string) (*EstablishedAgreement, error) {
+func ArchiveEstablishedAgreement(db EdgeDatabase, agreementId string, protocol string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, agreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.Archived = true
 		c.CurrentDeployment = map[string]ServiceConfig{}
@@ -206,7 +205,7 @@ func ArchiveEstablishedAgreement(db *bolt.DB, agreementId string, protocol strin
 }
 
 // set agreement state to execution started
-func AgreementStateExecutionStarted(db *bolt.DB, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
+func AgreementStateExecutionStarted(db EdgeDatabase, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.AgreementExecutionStartTime = uint64(time.Now().Unix())
 		return &c
@@ -214,7 +213,7 @@ func AgreementStateExecutionStarted(db *bolt.DB, dbAgreementId string, protocol
 }
 
 // set agreement state to accepted, a positive reply is being sent
-func AgreementStateAccepted(db *bolt.DB, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
+func AgreementStateAccepted(db EdgeDatabase, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.AgreementAcceptedTime = uint64(time.Now().Unix())
 		return &c
@@ -222,7 +221,7 @@ func AgreementStateAccepted(db *bolt.DB, dbAgreementId string, protocol string)
 }
 
 // set the eth signature of the proposal
-func AgreementStateProposalSigned(db *bolt.DB, dbAgreementId string, protocol string, sig string) (*EstablishedAgreement, error) {
+func AgreementStateProposalSigned(db EdgeDatabase, dbAgreementId string, protocol string, sig string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.ProposalSig = sig
 		return &c
@@ -230,7 +229,7 @@ func AgreementStateProposalSigned(db *bolt.DB, dbAgreementId string, protocol st
 }
 
 // set the eth counterparty address when it is received from the consumer
-func AgreementStateBCDataReceived(db *bolt.DB, dbAgreementId string, protocol string, address string) (*EstablishedAgreement, error) {
+func AgreementStateBCDataReceived(db EdgeDatabase, dbAgreementId string, protocol string, address string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.CounterPartyAddress = address
 		return &c
@@ -238,7 +237,7 @@ func AgreementStateBCDataReceived(db *bolt.DB, dbAgreementId string, protocol st
 }
 
 // set the time when out agreement blockchain update message was Ack'd.
-func AgreementStateBCUpdateAcked(db *bolt.DB, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
+func AgreementStateBCUpdateAcked(db EdgeDatabase, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.AgreementBCUpdateAckTime = uint64(time.Now().Unix())
 		return &c
@@ -246,7 +245,7 @@ func AgreementStateBCUpdateAcked(db *bolt.DB, dbAgreementId string, protocol str
 }
 
 // set agreement state to finalized
-func AgreementStateFinalized(db *bolt.DB, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
+func AgreementStateFinalized(db EdgeDatabase, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.AgreementFinalizedTime = uint64(time.Now().Unix())
 		return &c
@@ -254,7 +253,7 @@ func AgreementStateFinalized(db *bolt.DB, dbAgreementId string, protocol string)
 }
 
 // set deployment config because execution is about to begin
-func AgreementDeploymentStarted(db *bolt.DB, dbAgreementId string, protocol string, deployment DeploymentConfig) (*EstablishedAgreement, error) {
+func AgreementDeploymentStarted(db EdgeDatabase, dbAgreementId string, protocol string, deployment DeploymentConfig) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		if pf, err := deployment.ToPersistentForm(); err != nil {
 			glog.Errorf("Unable to persist deployment config: (%T) %v", deployment, deployment)
@@ -270,7 +269,7 @@ func AgreementDeploymentStarted(db *bolt.DB, dbAgreementId string, protocol stri
 }
 
 // set agreement state to terminated
-func AgreementStateTerminated(db *bolt.DB, dbAgreementId string, reason uint64, reasonString string, protocol string) (*EstablishedAgreement, error) {
+func AgreementStateTerminated(db EdgeDatabase, dbAgreementId string, reason uint64, reasonString string, protocol string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.AgreementTerminatedTime = uint64(time.Now().Unix())
 		c.TerminatedReason = reason
@@ -280,7 +279,7 @@ func AgreementStateTerminated(db *bolt.DB, dbAgreementId string, reason uint64,
 }
 
 // reset agreement state to not-terminated so that we can retry the termination
-func AgreementStateForceTerminated(db *bolt.DB, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
+func AgreementStateForceTerminated(db EdgeDatabase, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.AgreementForceTerminatedTime = uint64(time.Now().Unix())
 		return &c
@@ -288,7 +287,7 @@ func AgreementStateForceTerminated(db *bolt.DB, dbAgreementId string, protocol s
 }
 
 // set agreement state to data received
-func AgreementStateDataReceived(db *bolt.DB, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
+func AgreementStateDataReceived(db EdgeDatabase, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.AgreementDataReceivedTime = uint64(time.Now().Unix())
 		return &c
@@ -296,7 +295,7 @@ func AgreementStateDataReceived(db *bolt.DB, dbAgreementId string, protocol stri
 }
 
 // set agreement state to agreement protocol terminated
-func AgreementStateAgreementProtocolTerminated(db *bolt.DB, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
+func AgreementStateAgreementProtocolTerminated(db EdgeDatabase, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.AgreementProtocolTerminatedTime = uint64(time.Now().Unix())
 		return &c
@@ -304,7 +303,7 @@ func AgreementStateAgreementProtocolTerminated(db *bolt.DB, dbAgreementId string
 }
 
 // set agreement state to workload terminated
-func AgreementStateWorkloadTerminated(db *bolt.DB, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
+func AgreementStateWorkloadTerminated(db EdgeDatabase, dbAgreementId string, protocol string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.WorkloadTerminatedTime = uint64(time.Now().Unix())
 		return &c
@@ -312,14 +311,14 @@ func AgreementStateWorkloadTerminated(db *bolt.DB, dbAgreementId string, protoco
 }
 
 // set agreement state to workload terminated
-func MeteringNotificationReceived(db *bolt.DB, dbAgreementId string, mn MeteringNotification, protocol string) (*EstablishedAgreement, error) {
+func MeteringNotificationReceived(db EdgeDatabase, dbAgreementId string, mn MeteringNotification, protocol string) (*EstablishedAgreement, error) {
 	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
 		c.MeteringNotificationMsg = mn
 		return &c
 	})
 }
 
-func DeleteEstablishedAgreement(db *bolt.DB, agreementId string, protocol string) error {
+func DeleteEstablishedAgreement(db EdgeDatabase, agreementId string, protocol string) error {
 
 	if agreementId == "" {
 		return errors.New("Agreement id empty, cannot remove")
@@ -335,7 +334,7 @@ func DeleteEstablishedAgreement(db *bolt.DB, agreementId string, protocol string
 			return fmt.Errorf("Expecting 1 records with id: %v, found %v", agreementId, agreements)
 		} else {
 
-			return db.Update(func(tx *bolt.Tx) error {
+			return db.Update(func(tx EdgeTx) error {
 
 				if b, err := tx.CreateBucketIfNotExists([]byte(E_AGREEMENTS + "-" + protocol)); err != nil {
 					return err
@@ -349,7 +348,7 @@ func DeleteEstablishedAgreement(db *bolt.DB, agreementId string, protocol string
 	}
 }
 
-func agreementStateUpdate(db *bolt.DB, dbAgreementId string, protocol string, fn func(EstablishedAgreement) *EstablishedAgreement) (*EstablishedAgreement, error) {
+func agreementStateUpdate(db EdgeDatabase, dbAgreementId string, protocol string, fn func(EstablishedAgreement) *EstablishedAgreement) (*EstablishedAgreement, error) {
 	filters := make([]EAFilter, 0)
 	filters = append(filters, UnarchivedEAFilter())
 	filters = append(filters, IdEAFilter(dbAgreementId))
@@ -368,8 +367,8 @@ func agreementStateUpdate(db *bolt.DB, dbAgreementId string, protocol string, fn
 }
 
 // does whole-member replacements of values that are legal to change during the course of a contract's life
-func persistUpdatedAgreement(db *bolt.DB, dbAgreementId string, protocol string, update *EstablishedAgreement) error {
-	return db.Update(func(tx *bolt.Tx) error {
+func persistUpdatedAgreement(db EdgeDatabase, dbAgreementId string, protocol string, update *EstablishedAgreement) error {
+	return db.Update(func(tx EdgeTx) error {
 		if b, err := tx.CreateBucketIfNotExists([]byte(E_AGREEMENTS + "-" + protocol)); err != nil {
 			return err
 		} else {
@@ -476,11 +475,11 @@ type SensorUrls struct {
 	SensorUrl []string `json:"sensor_url"`
 }
 
-func FindEstablishedAgreements(db *bolt.DB, protocol string, filters []EAFilter) ([]EstablishedAgreement, error) {
+func FindEstablishedAgreements(db EdgeDatabase, protocol string, filters []EAFilter) ([]EstablishedAgreement, error) {
 	agreements := make([]EstablishedAgreement, 0)
 
 	// fetch contracts
-	readErr := db.View(func(tx *bolt.Tx) error {
+	readErr := db.View(func(tx EdgeTx) error {
 
 		if b := tx.Bucket([]byte(E_AGREEMENTS + "-" + protocol)); b != nil {
 			b.ForEach(func(k, v []byte) error {
@@ -533,7 +532,7 @@ func FindEstablishedAgreements(db *bolt.DB, protocol string, filters []EAFilter)
 	}
 }
 
-func FindEstablishedAgreementsAllProtocols(db *bolt.DB, protocols []string, filters []EAFilter) ([]EstablishedAgreement, error) {
+func FindEstablishedAgreementsAllProtocols(db EdgeDatabase, protocols []string, filters []EAFilter) ([]EstablishedAgreement, error) {
 	agreements := make([]EstablishedAgreement, 0)
 	for _, protocol := range protocols {
 		if ags, err := FindEstablishedAgreements(db, protocol, filters); err != nil {
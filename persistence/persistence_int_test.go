@@ -4,15 +4,13 @@ package persistence
 
 import (
 	"fmt"
-	"github.com/boltdb/bolt"
 	"io/ioutil"
 	"os"
 	"reflect"
 	"testing"
-	"time"
 )
 
-var testDb *bolt.DB
+var testDb EdgeDatabase
 
 func TestMain(m *testing.M) {
 	testDbFile, err := ioutil.TempFile("", "anax_persistence_int_test.db")
@@ -22,7 +20,7 @@ func TestMain(m *testing.M) {
 	defer os.Remove(testDbFile.Name())
 
 	var dbErr error
-	testDb, dbErr = bolt.Open(testDbFile.Name(), 0600, &bolt.Options{Timeout: 10 * time.Second})
+	testDb, dbErr = OpenBoltDatabase(testDbFile.Name())
 	if dbErr != nil {
 		panic(err)
 	}
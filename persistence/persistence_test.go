@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"testing"
 	"time"
 )
@@ -82,7 +81,7 @@ func (c EstablishedAgreement_Old) String() string {
 
 }
 
-func NewEstablishedAgreement_Old(db *bolt.DB, name string, agreementId string, consumerId string, proposal string, protocol string, protocolVersion int, sensorUrl []string, signature string, address string, bcType string, bcName string, bcOrg string, wi *WorkloadInfo) (*EstablishedAgreement_Old, error) {
+func NewEstablishedAgreement_Old(db EdgeDatabase, name string, agreementId string, consumerId string, proposal string, protocol string, protocolVersion int, sensorUrl []string, signature string, address string, bcType string, bcName string, bcOrg string, wi *WorkloadInfo) (*EstablishedAgreement_Old, error) {
 
 	if name == "" || agreementId == "" || consumerId == "" || proposal == "" || protocol == "" || protocolVersion == 0 {
 		return nil, errors.New("Agreement id, consumer id, proposal, protocol, or protocol version are empty, cannot persist")
@@ -120,7 +119,7 @@ func NewEstablishedAgreement_Old(db *bolt.DB, name string, agreementId string, c
 		RunningWorkload:                 *wi,
 	}
 
-	return newAg, db.Update(func(tx *bolt.Tx) error {
+	return newAg, db.Update(func(tx EdgeTx) error {
 
 		if b, err := tx.CreateBucketIfNotExists([]byte(E_AGREEMENTS + "-" + protocol)); err != nil {
 			return err
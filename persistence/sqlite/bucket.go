@@ -0,0 +1,236 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/persistence"
+)
+
+// Constants for the SQL statements that implement the bucket abstraction.
+const BUCKET_EXISTS = `SELECT count(*) FROM buckets WHERE name = $1;`
+const BUCKET_CREATE = `INSERT OR IGNORE INTO buckets (name) VALUES ($1);`
+const BUCKET_DELETE = `DELETE FROM buckets WHERE name = $1;`
+const BUCKET_NAMES = `SELECT name FROM buckets ORDER BY name;`
+const BUCKET_SEQUENCE = `SELECT sequence FROM buckets WHERE name = $1;`
+const BUCKET_SET_SEQUENCE = `UPDATE buckets SET sequence = $2 WHERE name = $1;`
+const BUCKET_NEXT_SEQUENCE = `UPDATE buckets SET sequence = sequence + 1 WHERE name = $1;`
+
+const DOCUMENT_GET = `SELECT value FROM documents WHERE bucket = $1 AND key = $2;`
+const DOCUMENT_PUT = `INSERT OR REPLACE INTO documents (bucket, key, value) VALUES ($1, $2, $3);`
+const DOCUMENT_DELETE = `DELETE FROM documents WHERE bucket = $1 AND key = $2;`
+const DOCUMENT_ALL = `SELECT key, value FROM documents WHERE bucket = $1 ORDER BY key;`
+
+// Run the function in a read-write transaction. The transaction is rolled back if the function returns an error,
+// or if any of the bucket reads failed.
+func (db *EdgeSqliteDB) Update(fn func(persistence.EdgeTx) error) error {
+	if tx, err := db.db.Begin(); err != nil {
+		return errors.New(fmt.Sprintf("unable to start transaction, error: %v", err))
+	} else {
+		stx := &sqliteTx{tx: tx, writable: true}
+		if err := fn(stx); err != nil {
+			tx.Rollback()
+			return err
+		} else if stx.err != nil {
+			tx.Rollback()
+			return stx.err
+		}
+		return tx.Commit()
+	}
+}
+
+func (db *EdgeSqliteDB) View(fn func(persistence.EdgeTx) error) error {
+	if tx, err := db.db.Begin(); err != nil {
+		return errors.New(fmt.Sprintf("unable to start transaction, error: %v", err))
+	} else {
+		defer tx.Rollback()
+		stx := &sqliteTx{tx: tx, writable: false}
+		if err := fn(stx); err != nil {
+			return err
+		}
+		return stx.err
+	}
+}
+
+// The bucket interface has no error return on reads (because bolt cant fail a read), so read errors are saved in
+// the transaction and returned when the transaction ends.
+type sqliteTx struct {
+	tx       *sql.Tx
+	writable bool
+	err      error
+}
+
+func (t *sqliteTx) saveErr(err error) {
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+func (t *sqliteTx) checkWritable() error {
+	if !t.writable {
+		return errors.New("transaction is not writable")
+	}
+	return nil
+}
+
+func (t *sqliteTx) Bucket(name []byte) persistence.EdgeBucket {
+	var count int
+	if err := t.tx.QueryRow(BUCKET_EXISTS, string(name)).Scan(&count); err != nil {
+		t.saveErr(errors.New(fmt.Sprintf("unable to read bucket %v, error: %v", string(name), err)))
+		return nil
+	} else if count == 0 {
+		return nil
+	}
+	return &sqliteBucket{tx: t, name: string(name)}
+}
+
+func (t *sqliteTx) CreateBucketIfNotExists(name []byte) (persistence.EdgeBucket, error) {
+	if err := t.checkWritable(); err != nil {
+		return nil, err
+	} else if len(name) == 0 {
+		return nil, errors.New("bucket name required")
+	} else if _, err := t.tx.Exec(BUCKET_CREATE, string(name)); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create bucket %v, error: %v", string(name), err))
+	}
+	return &sqliteBucket{tx: t, name: string(name)}, nil
+}
+
+func (t *sqliteTx) DeleteBucket(name []byte) error {
+	if err := t.checkWritable(); err != nil {
+		return err
+	} else if res, err := t.tx.Exec(BUCKET_DELETE, string(name)); err != nil {
+		return errors.New(fmt.Sprintf("unable to delete bucket %v, error: %v", string(name), err))
+	} else if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return errors.New("bucket not found")
+	}
+	return nil
+}
+
+func (t *sqliteTx) ForEachBucket(fn func(name []byte, b persistence.EdgeBucket) error) error {
+
+	// Read all the names first so that the function is free to use the transaction.
+	names := make([]string, 0, 10)
+	if rows, err := t.tx.Query(BUCKET_NAMES); err != nil {
+		return errors.New(fmt.Sprintf("unable to read buckets, error: %v", err))
+	} else {
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return errors.New(fmt.Sprintf("unable to scan bucket name, error: %v", err))
+			}
+			names = append(names, name)
+		}
+		if err := rows.Err(); err != nil {
+			return errors.New(fmt.Sprintf("unable to read buckets, error: %v", err))
+		}
+	}
+
+	for _, name := range names {
+		if err := fn([]byte(name), &sqliteBucket{tx: t, name: name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type sqliteBucket struct {
+	tx   *sqliteTx
+	name string
+}
+
+func (b *sqliteBucket) Get(key []byte) []byte {
+	var value string
+	if err := b.tx.tx.QueryRow(DOCUMENT_GET, b.name, string(key)).Scan(&value); err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		b.tx.saveErr(errors.New(fmt.Sprintf("unable to read key %v in bucket %v, error: %v", string(key), b.name, err)))
+		return nil
+	}
+	return []byte(value)
+}
+
+func (b *sqliteBucket) Put(key []byte, value []byte) error {
+	if err := b.tx.checkWritable(); err != nil {
+		return err
+	} else if len(key) == 0 {
+		return errors.New("key required")
+	} else if _, err := b.tx.tx.Exec(DOCUMENT_PUT, b.name, string(key), string(value)); err != nil {
+		return errors.New(fmt.Sprintf("unable to write key %v in bucket %v, error: %v", string(key), b.name, err))
+	}
+	return nil
+}
+
+func (b *sqliteBucket) Delete(key []byte) error {
+	if err := b.tx.checkWritable(); err != nil {
+		return err
+	} else if _, err := b.tx.tx.Exec(DOCUMENT_DELETE, b.name, string(key)); err != nil {
+		return errors.New(fmt.Sprintf("unable to delete key %v in bucket %v, error: %v", string(key), b.name, err))
+	}
+	return nil
+}
+
+func (b *sqliteBucket) ForEach(fn func(k []byte, v []byte) error) error {
+
+	type document struct {
+		key   string
+		value string
+	}
+
+	// Read all the documents first so that the query is closed before the function runs.
+	docs := make([]document, 0, 10)
+	if rows, err := b.tx.tx.Query(DOCUMENT_ALL, b.name); err != nil {
+		return errors.New(fmt.Sprintf("unable to read bucket %v, error: %v", b.name, err))
+	} else {
+		defer rows.Close()
+		for rows.Next() {
+			var d document
+			if err := rows.Scan(&d.key, &d.value); err != nil {
+				return errors.New(fmt.Sprintf("unable to scan document in bucket %v, error: %v", b.name, err))
+			}
+			docs = append(docs, d)
+		}
+		if err := rows.Err(); err != nil {
+			return errors.New(fmt.Sprintf("unable to read bucket %v, error: %v", b.name, err))
+		}
+	}
+
+	for _, d := range docs {
+		if err := fn([]byte(d.key), []byte(d.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *sqliteBucket) NextSequence() (uint64, error) {
+	if err := b.tx.checkWritable(); err != nil {
+		return 0, err
+	} else if _, err := b.tx.tx.Exec(BUCKET_NEXT_SEQUENCE, b.name); err != nil {
+		return 0, errors.New(fmt.Sprintf("unable to increment sequence of bucket %v, error: %v", b.name, err))
+	}
+
+	var seq uint64
+	if err := b.tx.tx.QueryRow(BUCKET_SEQUENCE, b.name).Scan(&seq); err != nil {
+		return 0, errors.New(fmt.Sprintf("unable to read sequence of bucket %v, error: %v", b.name, err))
+	}
+	return seq, nil
+}
+
+func (b *sqliteBucket) Sequence() uint64 {
+	var seq uint64
+	if err := b.tx.tx.QueryRow(BUCKET_SEQUENCE, b.name).Scan(&seq); err != nil {
+		b.tx.saveErr(errors.New(fmt.Sprintf("unable to read sequence of bucket %v, error: %v", b.name, err)))
+		return 0
+	}
+	return seq
+}
+
+func (b *sqliteBucket) SetSequence(v uint64) error {
+	if err := b.tx.checkWritable(); err != nil {
+		return err
+	} else if _, err := b.tx.tx.Exec(BUCKET_SET_SEQUENCE, b.name, int64(v)); err != nil {
+		return errors.New(fmt.Sprintf("unable to set sequence of bucket %v, error: %v", b.name, err))
+	}
+	return nil
+}
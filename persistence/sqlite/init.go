@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"os"
+)
+
+func init() {
+	persistence.RegisterEdgeDatabase(config.EDGE_DB_TYPE_SQLITE, new(EdgeSqliteDB))
+}
+
+// The SQLite implementation of the edge database. Each bolt style bucket is a row in the buckets table, and each
+// key/value pair in a bucket is a row in the documents table. The values are the same JSON documents that would be
+// stored in bolt, so they can be queried with the SQLite JSON functions, for example:
+//
+//   SELECT key, json_extract(value, '$.agreement_execution_start_time') FROM documents WHERE bucket = 'established_agreements-Basic';
+//
+const BUCKETS_CREATE_TABLE = `CREATE TABLE IF NOT EXISTS buckets (
+	name TEXT PRIMARY KEY NOT NULL,
+	sequence INTEGER NOT NULL DEFAULT 0
+);`
+
+const DOCUMENTS_CREATE_TABLE = `CREATE TABLE IF NOT EXISTS documents (
+	bucket TEXT NOT NULL REFERENCES buckets(name) ON DELETE CASCADE,
+	key TEXT NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (bucket, key)
+);`
+
+type EdgeSqliteDB struct {
+	db   *sql.DB // A handle to the underlying database.
+	path string  // The database file.
+}
+
+func (db *EdgeSqliteDB) String() string {
+	return fmt.Sprintf("File: %v, DB Handle: %v", db.path, db.db)
+}
+
+// This function is called by the anax main to allow the configured database a chance to initialize itself. It is
+// called every time the agent starts, so the tables are only created if they dont already exist.
+func (db *EdgeSqliteDB) Initialize(cfg *config.HorizonConfig) error {
+
+	if err := os.MkdirAll(cfg.Edge.DBPath, 0700); err != nil {
+		return errors.New(fmt.Sprintf("unable to create directory %v for SQLite DB configuration, error: %v", cfg.Edge.DBPath, err))
+	}
+
+	return db.open(cfg.GetEdgeDBFile())
+}
+
+func (db *EdgeSqliteDB) open(file string) error {
+
+	glog.V(1).Infof("Opening SQLite database: %v", file)
+
+	// The busy timeout matches the timeout used when opening the bolt database.
+	if sdb, err := sql.Open("sqlite3", fmt.Sprintf("file:%v?_busy_timeout=10000&_foreign_keys=1", file)); err != nil {
+		return errors.New(fmt.Sprintf("unable to open SQLite database %v, error: %v", file, err))
+	} else if err := sdb.Ping(); err != nil {
+		return errors.New(fmt.Sprintf("unable to ping SQLite database %v, error: %v", file, err))
+	} else {
+
+		// Like bolt, only one transaction runs at a time. This also prevents a View and an Update from deadlocking
+		// each other inside SQLite.
+		sdb.SetMaxOpenConns(1)
+
+		db.db = sdb
+		db.path = file
+
+		glog.V(3).Infof("SQLite database tables initializing.")
+
+		if _, err := db.db.Exec(BUCKETS_CREATE_TABLE); err != nil {
+			return errors.New(fmt.Sprintf("unable to create buckets table, error: %v", err))
+		} else if _, err := db.db.Exec(DOCUMENTS_CREATE_TABLE); err != nil {
+			return errors.New(fmt.Sprintf("unable to create documents table, error: %v", err))
+		}
+	}
+	return nil
+}
+
+// Open a SQLite database file directly, without going through the configuration. This is used by tools that work on
+// a database file, and by tests.
+func OpenSqliteDatabase(file string) (persistence.EdgeDatabase, error) {
+	db := new(EdgeSqliteDB)
+	if err := db.open(file); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *EdgeSqliteDB) Close() error {
+	if db.db != nil {
+		return db.db.Close()
+	}
+	return nil
+}
+
+func (db *EdgeSqliteDB) Type() string {
+	return config.EDGE_DB_TYPE_SQLITE
+}
+
+func (db *EdgeSqliteDB) Path() string {
+	return db.path
+}
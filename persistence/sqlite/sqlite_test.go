@@ -0,0 +1,124 @@
+// +build unit,cgo
+
+package sqlite
+
+import (
+	"github.com/open-horizon/anax/persistence"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func Test_Sqlite_buckets(t *testing.T) {
+
+	dir, db := utsetup(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	err := db.Update(func(tx persistence.EdgeTx) error {
+		assert.Nil(t, tx.Bucket([]byte("b1")), "The bucket should not exist yet.")
+
+		b, err := tx.CreateBucketIfNotExists([]byte("b1"))
+		assert.Nil(t, err)
+		assert.Nil(t, b.Put([]byte("k2"), []byte("v2")))
+		assert.Nil(t, b.Put([]byte("k1"), []byte("v1")))
+		assert.Nil(t, b.Put([]byte("k3"), []byte("v3")))
+		assert.Nil(t, b.Delete([]byte("k3")))
+
+		seq, err := b.NextSequence()
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), seq)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	// A failed update is rolled back.
+	err = db.Update(func(tx persistence.EdgeTx) error {
+		tx.Bucket([]byte("b1")).Put([]byte("k4"), []byte("v4"))
+		return os.ErrInvalid
+	})
+	assert.Equal(t, os.ErrInvalid, err)
+
+	err = db.View(func(tx persistence.EdgeTx) error {
+		b := tx.Bucket([]byte("b1"))
+		assert.NotNil(t, b)
+		assert.Equal(t, []byte("v1"), b.Get([]byte("k1")))
+		assert.Nil(t, b.Get([]byte("k4")), "The rolled back key should not exist.")
+		assert.Equal(t, uint64(1), b.Sequence())
+
+		keys := make([]string, 0)
+		b.ForEach(func(k []byte, v []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+		assert.Equal(t, []string{"k1", "k2"}, keys, "The keys should be returned in order.")
+
+		assert.NotNil(t, b.Put([]byte("k5"), []byte("v5")), "A read-only transaction should not be writable.")
+		return nil
+	})
+	assert.Nil(t, err)
+
+	err = db.Update(func(tx persistence.EdgeTx) error {
+		assert.Nil(t, tx.DeleteBucket([]byte("b1")))
+		assert.NotNil(t, tx.DeleteBucket([]byte("b1")), "The bucket should already be deleted.")
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func Test_Sqlite_persistence(t *testing.T) {
+
+	dir, db := utsetup(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	// The edge persistence functions work unchanged on the SQLite database.
+	sp := persistence.ServiceSpec{Url: "http://mycom.com", Org: "mycom"}
+	source := persistence.NewAgreementEventSource("agreement id 1", persistence.WorkloadInfo{"http://top1.com", "mycomp", "1.0.0", "amd64"}, []persistence.ServiceSpec{sp}, "agbot1", "basic")
+	for _, msg := range []string{"message 1", "message 2"} {
+		if err := persistence.SaveEventLog(db, persistence.NewEventLog(persistence.SEVERITY_INFO, msg, persistence.EC_START_NODE_CONFIG_REG, persistence.SRC_TYPE_AG, *source)); err != nil {
+			t.Errorf("Error saving event log: %v", err)
+		}
+	}
+
+	logs, err := persistence.FindAllEventLogs(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(logs), "There should be 2 event logs.")
+	assert.Equal(t, "message 2", logs[1].Message)
+
+	// Copy the SQLite database to bolt and back again.
+	bdb, err := persistence.OpenBoltDatabase(path.Join(dir, "anax.db"))
+	assert.Nil(t, err)
+	defer bdb.Close()
+
+	count, err := persistence.CopyDatabase(db, bdb)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+
+	sdb, err := OpenSqliteDatabase(path.Join(dir, "copy.sqlite"))
+	assert.Nil(t, err)
+	defer sdb.Close()
+
+	count, err = persistence.CopyDatabase(bdb, sdb)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+
+	logs, err = persistence.FindAllEventLogs(sdb)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(logs), "There should be 2 event logs in the copy.")
+}
+
+func utsetup(t *testing.T) (string, persistence.EdgeDatabase) {
+	dir, err := ioutil.TempDir("", "utdb-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenSqliteDatabase(path.Join(dir, "anax.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, db
+}
@@ -0,0 +1,179 @@
+package persistence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"os"
+	"path"
+	"time"
+)
+
+// The edge database has a single schema version that is kept in the metadata bucket. The anax runtime
+// automatically upgrades the database when it starts, based on its own version and the version in the database.
+// A database that has no version was created before versioning was introduced, and is at version 0.
+//
+// To change the format of a record, add a new version constant, make it the highest version, and add a migration
+// to the migrations map below. A migration is a function that rewrites the records in a bucket from the previous
+// version's format to the new one. Each migration runs in its own transaction along with the update to the schema
+// version, so a database is never left half way between versions. Migrations are the only place where records are
+// upgraded, there is no other upgrade code run when the runtime starts.
+
+const METADATA = "metadata"
+const SCHEMA_VERSION = "schema_version"
+
+const HIGHEST_DATABASE_VERSION = v1
+const v1 = 1
+
+const EDGE_DB_PRE_MIGRATION_SUFFIX = ".pre-migration"
+
+type SchemaVersion struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	Updated     uint64 `json:"updated"`
+}
+
+func (s SchemaVersion) String() string {
+	return fmt.Sprintf("Version: %v, Description: %v, Updated: %v", s.Version, s.Description, s.Updated)
+}
+
+type SchemaUpdate struct {
+	migrate     func(tx EdgeTx) error // The function that converts the records for an update to the schema.
+	description string                // A description of the schema change.
+}
+
+var migrations = map[int]SchemaUpdate{
+	// The records written by unversioned runtimes, including the device object, are already in the v1 format.
+	v1: SchemaUpdate{
+		migrate:     nil,
+		description: "initial versioned schema",
+	},
+}
+
+// Returns the schema version of the database, or a version 0 record if the database is not versioned.
+func GetSchemaVersion(db EdgeDatabase) (*SchemaVersion, error) {
+	version := &SchemaVersion{}
+	err := db.View(func(tx EdgeTx) error {
+		var err error
+		version, err = readSchemaVersion(tx)
+		return err
+	})
+	return version, err
+}
+
+func readSchemaVersion(tx EdgeTx) (*SchemaVersion, error) {
+	version := &SchemaVersion{}
+	if b := tx.Bucket([]byte(METADATA)); b == nil {
+		return version, nil
+	} else if v := b.Get([]byte(SCHEMA_VERSION)); v == nil {
+		return version, nil
+	} else if err := json.Unmarshal(v, version); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to demarshal schema version %v, error: %v", string(v), err))
+	}
+	return version, nil
+}
+
+func writeSchemaVersion(tx EdgeTx, version int, description string) error {
+	sv := SchemaVersion{
+		Version:     version,
+		Description: description,
+		Updated:     uint64(time.Now().Unix()),
+	}
+	if b, err := tx.CreateBucketIfNotExists([]byte(METADATA)); err != nil {
+		return err
+	} else if serial, err := json.Marshal(sv); err != nil {
+		return errors.New(fmt.Sprintf("unable to serialize schema version %v, error: %v", sv, err))
+	} else {
+		return b.Put([]byte(SCHEMA_VERSION), serial)
+	}
+}
+
+// Returns true if the database has no buckets other than the metadata bucket.
+func isEmptyDatabase(tx EdgeTx) (bool, error) {
+	empty := true
+	err := tx.ForEachBucket(func(name []byte, b EdgeBucket) error {
+		if string(name) != METADATA {
+			empty = false
+		}
+		return nil
+	})
+	return empty, err
+}
+
+// Bring the database up to the schema version supported by this code. This is called by InitDatabase every time
+// the runtime starts.
+func MigrateDatabase(db EdgeDatabase, cfg *config.HorizonConfig) error {
+	return migrateDatabase(db, cfg.Edge.DBPath, HIGHEST_DATABASE_VERSION, migrations)
+}
+
+func migrateDatabase(db EdgeDatabase, backupDir string, highest int, updates map[int]SchemaUpdate) error {
+
+	var current *SchemaVersion
+	var empty bool
+	if err := db.View(func(tx EdgeTx) error {
+		var err error
+		if current, err = readSchemaVersion(tx); err != nil {
+			return err
+		}
+		empty, err = isEmptyDatabase(tx)
+		return err
+	}); err != nil {
+		return errors.New(fmt.Sprintf("unable to read schema version, error: %v", err))
+	}
+
+	glog.V(3).Infof("Edge database is at schema version %v, %v, as of %v.", current.Version, current.Description, current.Updated)
+
+	// The runtime cannot safely use a database written by newer code, it might not understand the records and
+	// it would not know how to convert them back.
+	if current.Version > highest {
+		return errors.New(fmt.Sprintf("edge database %v is at schema version %v, which is newer than version %v supported by this runtime. Upgrade the runtime, or restore the database from a backup taken before it was migrated.", db.Path(), current.Version, highest))
+	} else if current.Version == highest {
+		return nil
+	}
+
+	// A new database is created in the current format, so there is nothing to migrate.
+	if empty {
+		glog.V(3).Infof("Edge database is new, setting schema version %v.", highest)
+		return db.Update(func(tx EdgeTx) error {
+			return writeSchemaVersion(tx, highest, updates[highest].description)
+		})
+	}
+
+	// Save a copy of the database before changing it, so that the runtime can be downgraded.
+	backupFile := path.Join(backupDir, fmt.Sprintf("%v%v-v%v-%v", path.Base(db.Path()), EDGE_DB_PRE_MIGRATION_SUFFIX, current.Version, time.Now().Unix()))
+	if f, err := os.OpenFile(backupFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600); err != nil {
+		return errors.New(fmt.Sprintf("unable to create pre-migration backup %v, error: %v", backupFile, err))
+	} else if _, err := BackupDatabase(db, f); err != nil {
+		f.Close()
+		os.Remove(backupFile)
+		return errors.New(fmt.Sprintf("unable to write pre-migration backup %v, error: %v", backupFile, err))
+	} else if err := f.Close(); err != nil {
+		return errors.New(fmt.Sprintf("unable to close pre-migration backup %v, error: %v", backupFile, err))
+	}
+
+	glog.V(3).Infof("Edge database upgrading from schema version %v to %v, saved a backup in %v.", current.Version, highest, backupFile)
+
+	// Each new database version has it's own key in the migration map.
+	for v := current.Version + 1; v <= highest; v++ {
+		update, ok := updates[v]
+		if !ok {
+			return errors.New(fmt.Sprintf("no migration for edge database schema version %v", v))
+		}
+		if err := db.Update(func(tx EdgeTx) error {
+			if update.migrate != nil {
+				if err := update.migrate(tx); err != nil {
+					return err
+				}
+			}
+			return writeSchemaVersion(tx, v, update.description)
+		}); err != nil {
+			return errors.New(fmt.Sprintf("unable to migrate edge database to schema version %v, the database is left at version %v, error: %v", v, v-1, err))
+		}
+		glog.V(3).Infof("Edge database upgraded to schema version %v, %v", v, update.description)
+	}
+
+	glog.V(3).Infof("Edge database upgraded to schema version %v", highest)
+	return nil
+}
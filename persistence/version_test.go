@@ -0,0 +1,114 @@
+// +build unit
+
+package persistence
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func Test_MigrateDatabase_new(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	// A new database is stamped with the highest version without a backup.
+	err = migrateDatabase(db, dir, HIGHEST_DATABASE_VERSION, migrations)
+	assert.Nil(t, err)
+
+	sv, err := GetSchemaVersion(db)
+	assert.Nil(t, err)
+	assert.Equal(t, HIGHEST_DATABASE_VERSION, sv.Version)
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "*"+EDGE_DB_PRE_MIGRATION_SUFFIX+"*"))
+	assert.Equal(t, 0, len(backups), "There should be no pre-migration backup.")
+}
+
+func Test_MigrateDatabase_upgrade(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	// An unversioned database with a record in it.
+	if err := SaveLastUnregistrationTime(db, 12345); err != nil {
+		t.Errorf("Error saving last unregistration time: %v", err)
+	}
+
+	// Version 2 rewrites the record, version 3 fails.
+	updates := map[int]SchemaUpdate{
+		1: SchemaUpdate{description: "v1"},
+		2: SchemaUpdate{
+			description: "v2",
+			migrate: func(tx EdgeTx) error {
+				return tx.Bucket([]byte(LAST_UNREG)).Put([]byte("lastunreg"), []byte("67890"))
+			},
+		},
+		3: SchemaUpdate{
+			description: "v3",
+			migrate: func(tx EdgeTx) error {
+				return errors.New("migration failed")
+			},
+		},
+	}
+
+	err = migrateDatabase(db, dir, 2, updates)
+	assert.Nil(t, err)
+
+	sv, err := GetSchemaVersion(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, sv.Version)
+	assert.Equal(t, "v2", sv.Description)
+
+	tl, err := GetLastUnregistrationTime(db)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(67890), tl, "The record should be migrated.")
+
+	// The backup was taken before the migration.
+	backups, _ := filepath.Glob(filepath.Join(dir, "*"+EDGE_DB_PRE_MIGRATION_SUFFIX+"-v0-*"))
+	assert.Equal(t, 1, len(backups), "There should be a pre-migration backup.")
+
+	bdb, err := OpenBoltDatabase(backups[0])
+	if err != nil {
+		t.Error(err)
+	}
+	tl, err = GetLastUnregistrationTime(bdb)
+	bdb.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(12345), tl, "The backup should have the original record.")
+
+	// A failed migration leaves the database at the previous version.
+	err = migrateDatabase(db, dir, 3, updates)
+	assert.NotNil(t, err)
+
+	sv, err = GetSchemaVersion(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, sv.Version)
+}
+
+func Test_MigrateDatabase_newer(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	if err := db.Update(func(tx EdgeTx) error {
+		return writeSchemaVersion(tx, HIGHEST_DATABASE_VERSION+1, "from the future")
+	}); err != nil {
+		t.Error(err)
+	}
+
+	// The runtime must refuse a database written by newer code.
+	err = migrateDatabase(db, dir, HIGHEST_DATABASE_VERSION, migrations)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "newer than version")
+}
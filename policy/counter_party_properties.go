@@ -3,6 +3,8 @@ package policy
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 )
 
 // The purpose this file is to abstract the CounterPartyProperties field in the Policy struct
@@ -20,10 +22,23 @@ import (
 // _control_operator_    = {"and", "or", "not"}
 // _expression_          = _control_operator_: [_expression_] || property
 // _property_            = "name": _property_name_, "value": _property_value, "op": _comparison_operator_
-// _comparison_operator_ = {"<", "=", ">", "<=", ">=", "!="}
-// The "=" and "!=" comparison operators can be applied to strings and integers.
+// _comparison_operator_ = {"<", "=", ">", "<=", ">=", "!=", "in", "notin", "regex", "inrange"}
+// The "=" and "!=" comparison operators can be applied to strings, numbers and booleans.
+// The "<", ">", "<=" and ">=" comparison operators can only be applied to numbers.
+// The "in" and "notin" comparison operators take an array of strings, numbers or booleans. The property value
+// must be (or must not be) equal to one of them. When the property value is a list of strings, "in" is satisfied
+// if any of them is in the array and "notin" if none of them are.
+// The "regex" comparison operator takes a regular expression in Go (RE2) syntax that matches the property value,
+// which must be a string. Use ^ and $ to match the whole value.
+// The "inrange" comparison operator takes an OSGI version range, see version.go, that the property value must
+// be within, e.g. "[2.1,3.0)". A number property value like 2.5 is treated as the version "2.5".
 // If the "op" key is missing, then equal is assumed.
 //
+// The "not" control operator is satisfied when none of the expressions in its array are satisfied. A property
+// that is missing does not satisfy any comparison, including "!=" and "notin", so a node without a region property
+// satisfies {"not":[{"name":"region", "value":["cn","ru"], "op":"in"}]} but does not satisfy
+// {"name":"region", "value":["cn","ru"], "op":"notin"}.
+//
 // See the unit tests for examples of valid and invalid syntax
 //
 
@@ -48,6 +63,10 @@ const equalto = "="
 const lessthaneq = "<="
 const greaterthaneq = ">="
 const notequalto = "!="
+const in = "in"
+const notin = "notin"
+const regex = "regex"
+const inrange = "inrange"
 
 // This struct represents property value expressions to be satisfied
 type PropertyExpression struct {
@@ -145,6 +164,22 @@ func (self *RequiredProperty) satisfied(cop *map[string]interface{}, props *[]Pr
 
 	} else if controlOp == not {
 
+		propArray := (*cop)[controlOp].([]interface{})
+		for _, p := range propArray {
+			if prop := isPropertyExpression(p); prop != nil {
+				if propertyInArray(prop, props) {
+					return errors.New(fmt.Sprintf("Property %v with value %v and op %v is satisfied by %v, but it should not be\n", prop.Name, prop.Value, prop.Op, props))
+				}
+			} else if cop := isControlOp(p); cop != nil {
+				if err := self.satisfied(cop, props); err == nil {
+					return errors.New(fmt.Sprintf("Required Properties %v are satisfied by %v, but they should not be\n", *cop, props))
+				}
+			} else {
+				return errors.New(fmt.Sprintf("Control Operator contains an element that is not a Property and not a control operator %v\n", p))
+			}
+		}
+		return nil
+
 	}
 
 	return nil
@@ -192,15 +227,79 @@ func (self *RequiredProperty) verify(cop *map[string]interface{}) error {
 	}
 
 	propArray := (*cop)[controlOp].([]interface{})
+	if controlOp == not && len(propArray) == 0 {
+		return errors.New(fmt.Sprintf("RequiredProperty Object not valid, control operator %v has an empty array", controlOp))
+	}
+
 	for _, p := range propArray {
-		if prop := isPropertyExpression(p); prop != nil {
-			continue
-		} else if cop := isControlOp(p); cop != nil {
+		if cop := isControlOp(p); cop != nil {
 			if err := self.verify(cop); err != nil {
 				return err
 			}
+		} else if isMap(p) {
+			if err := verifyPropertyExpression(p.(map[string]interface{})); err != nil {
+				return err
+			}
 		} else {
-			return errors.New(fmt.Sprintf("Control Operator contains an element that is not a Property and not a control operator %v\n", p))
+			return errors.New(fmt.Sprintf("RequiredProperty Object not valid, control operator %v contains an element that is not a Property and not a control operator %v", controlOp, p))
+		}
+	}
+
+	return nil
+}
+
+// This function checks that a property expression has a name and a value, that the operator is supported and that
+// the value can be used with the operator. The value of the original comparison operators is not checked, so that
+// expressions which were valid before the set, regex and version range operators were added are still valid.
+func verifyPropertyExpression(exp map[string]interface{}) error {
+
+	name, ok := exp["name"]
+	if !ok {
+		// A map with 1 key and no name or value was meant to be a control operator.
+		if _, ok := exp["value"]; !ok && len(exp) == 1 {
+			return errors.New(fmt.Sprintf("RequiredProperty Object not valid, %v is not a supported control operator, must be one of %v", getKeys(exp)[0], controlOperators()))
+		}
+		return errors.New(fmt.Sprintf("RequiredProperty Object not valid, property expression %v has no name", exp))
+	} else if !isString(name) {
+		return errors.New(fmt.Sprintf("RequiredProperty Object not valid, property expression %v must have a name that is a string", exp))
+	}
+
+	value, ok := exp["value"]
+	if !ok {
+		return errors.New(fmt.Sprintf("RequiredProperty Object not valid, property %v has no value", name))
+	}
+
+	op := equalto
+	if o, ok := exp["op"]; ok {
+		if !isString(o) {
+			return errors.New(fmt.Sprintf("RequiredProperty Object not valid, property %v has an op %v that is not a string", name, o))
+		} else if _, ok := comparisonOperators()[o.(string)]; !ok {
+			return errors.New(fmt.Sprintf("RequiredProperty Object not valid, property %v has an unsupported op %v, must be one of %v", name, o, comparisonOperators()))
+		}
+		op = o.(string)
+	}
+
+	switch op {
+	case in, notin:
+		if !isArray(value) || len(value.([]interface{})) == 0 {
+			return errors.New(fmt.Sprintf("RequiredProperty Object not valid, property %v op %v requires a non-empty array of values, value is %v", name, op, value))
+		}
+		for _, v := range value.([]interface{}) {
+			if !isScalar(v) {
+				return errors.New(fmt.Sprintf("RequiredProperty Object not valid, property %v op %v requires an array of strings, numbers or booleans, %v is not one of these", name, op, v))
+			}
+		}
+	case regex:
+		if !isString(value) {
+			return errors.New(fmt.Sprintf("RequiredProperty Object not valid, property %v op %v requires a regular expression string, value is %v", name, op, value))
+		} else if _, err := regexp.Compile(value.(string)); err != nil {
+			return errors.New(fmt.Sprintf("RequiredProperty Object not valid, property %v has an invalid regular expression %v, error: %v", name, value, err))
+		}
+	case inrange:
+		if !isString(value) {
+			return errors.New(fmt.Sprintf("RequiredProperty Object not valid, property %v op %v requires a version range string, value is %v", name, op, value))
+		} else if _, err := Version_Expression_Factory(value.(string)); err != nil {
+			return errors.New(fmt.Sprintf("RequiredProperty Object not valid, property %v has an invalid version range %v, error: %v", name, value, err))
 		}
 	}
 
@@ -242,7 +341,7 @@ func (self *RequiredProperty) Merge(other *RequiredProperty) *RequiredProperty {
 //
 
 // A simple function used to extract the 1 and only key of the input map. Callers of this function
-/// must check that there is only 1 key in the map before calling.
+// / must check that there is only 1 key in the map before calling.
 func (self *RequiredProperty) getControlOperator(m *map[string]interface{}) string {
 	return getKeys(*m)[0]
 }
@@ -250,15 +349,13 @@ func (self *RequiredProperty) getControlOperator(m *map[string]interface{}) stri
 // Return a map of control operators so that it's easy to check if a string is equivalent to one
 // of the supported control operators.
 func controlOperators() map[string]int {
-	// return map[string]int {and:0, or:0, not:0}
-	return map[string]int{and: 0, or: 0}
+	return map[string]int{and: 0, or: 0, not: 0}
 }
 
 // Return a map of comparison operators so that it's easy to check if a string is equivalent to one
 // of the supported comparison operators.
 func comparisonOperators() map[string]int {
-	// return map[string]int {and:0, or:0, not:0}
-	return map[string]int{lessthan: 0, greaterthan: 0, equalto: 0, lessthaneq: 0, greaterthaneq: 0, notequalto: 0}
+	return map[string]int{lessthan: 0, greaterthan: 0, equalto: 0, lessthaneq: 0, greaterthaneq: 0, notequalto: 0, in: 0, notin: 0, regex: 0, inrange: 0}
 }
 
 // Return a map of comparison operators that only work on strings
@@ -286,18 +383,20 @@ func isPropertyExpression(x interface{}) *PropertyExpression {
 		return nil
 	} else {
 		asMap := x.(map[string]interface{})
-		if _, ok := asMap["name"]; !ok {
+		if name, ok := asMap["name"]; !ok || !isString(name) {
 			return nil
 		} else if _, ok := asMap["value"]; !ok {
 			return nil
 		} else {
 			p := new(PropertyExpression)
-			p.Name = asMap["name"].(string)
+			p.Name = name.(string)
 			p.Value = asMap["value"]
-			if _, ok := asMap["op"]; !ok {
+			if op, ok := asMap["op"]; !ok {
 				p.Op = "="
-			} else if _, ok := comparisonOperators()[asMap["op"].(string)]; ok {
-				p.Op = asMap["op"].(string)
+			} else if !isString(op) {
+				return nil
+			} else if _, ok := comparisonOperators()[op.(string)]; ok {
+				p.Op = op.(string)
 			} else {
 				return nil
 			}
@@ -315,7 +414,9 @@ func isControlOp(x interface{}) *map[string]interface{} {
 	} else {
 		asMap := x.(map[string]interface{})
 		keys := getKeys(asMap)
-		if _, ok := controlOperators()[keys[0]]; !ok {
+		if len(keys) != 1 {
+			return nil
+		} else if _, ok := controlOperators()[keys[0]]; !ok {
 			return nil
 		}
 		return &asMap
@@ -363,6 +464,12 @@ func isBoolean(x interface{}) bool {
 	}
 }
 
+// This function checks the type of the input interface object to see if it's a value that a property can be
+// compared with using "=" or "!=".
+func isScalar(x interface{}) bool {
+	return isString(x) || isFloat64(x) || isBoolean(x)
+}
+
 // This function extracts all the keys from the input map and returns them in a string array.
 func getKeys(m map[string]interface{}) []string {
 	keys := make([]string, 0, 10)
@@ -379,6 +486,19 @@ func propertyInArray(propexp *PropertyExpression, props *[]Property) bool {
 		if p.Name != propexp.Name {
 			// These are not the droids we're looking for
 			continue
+		} else if propexp.Op == in || propexp.Op == notin {
+			set, ok := propexp.Value.([]interface{})
+			return ok && valueInSet(p.Value, set) == (propexp.Op == in)
+		} else if propexp.Op == regex {
+			if !isString(p.Value) || !isString(propexp.Value) {
+				return false
+			} else if re, err := regexp.Compile(propexp.Value.(string)); err != nil {
+				return false
+			} else {
+				return re.MatchString(p.Value.(string))
+			}
+		} else if propexp.Op == inrange {
+			return versionInRange(p.Value, propexp.Value)
 		} else {
 			if isFloat64(p.Value) && isFloat64(propexp.Value) {
 				if propexp.Op == lessthan {
@@ -415,3 +535,47 @@ func propertyInArray(propexp *PropertyExpression, props *[]Property) bool {
 	}
 	return false
 }
+
+// This function returns true if the property value is equal to one of the values in the set. A property value that
+// is a list is in the set when any of its elements are.
+func valueInSet(value interface{}, set []interface{}) bool {
+	if list, ok := value.([]interface{}); ok {
+		for _, v := range list {
+			if valueInSet(v, set) {
+				return true
+			}
+		}
+		return false
+	} else if !isScalar(value) {
+		return false
+	}
+
+	for _, s := range set {
+		if isScalar(s) && s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// This function returns true if the property value is a version within the version range expression.
+func versionInRange(value interface{}, versionRange interface{}) bool {
+	version := ""
+	if isString(value) {
+		version = value.(string)
+	} else if isFloat64(value) {
+		version = strconv.FormatFloat(value.(float64), 'f', -1, 64)
+	} else {
+		return false
+	}
+
+	if !isString(versionRange) {
+		return false
+	} else if ve, err := Version_Expression_Factory(versionRange.(string)); err != nil {
+		return false
+	} else if ok, err := ve.Is_within_range(version); err != nil {
+		return false
+	} else {
+		return ok
+	}
+}
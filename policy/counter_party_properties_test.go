@@ -1,9 +1,11 @@
+//go:build unit
 // +build unit
 
 package policy
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -47,12 +49,12 @@ func Test_valid_simple1(t *testing.T) {
 		}
 	}
 
-	// simple_not := `{"not":[{"name":"prop1", "value":"val1"}]}`
-	// if rp = create_RP(simple_not, t); rp != nil {
-	//     if err := rp.IsValid(); err != nil {
-	//         t.Error(err)
-	//     }
-	// }
+	simple_not := `{"not":[{"name":"prop1", "value":"val1"}]}`
+	if rp = create_RP(simple_not, t); rp != nil {
+		if err := rp.IsValid(); err != nil {
+			t.Error(err)
+		}
+	}
 }
 
 // Test that invalid simple expressions as detected as invalid expressions.
@@ -686,6 +688,130 @@ func Test_merge4(t *testing.T) {
 	}
 }
 
+// Test that expressions using the set, regex and version range operators are valid.
+func Test_valid_operators1(t *testing.T) {
+
+	for _, exp := range []string{
+		`{"and":[{"name":"region", "value":["cn","ru"], "op":"in"}]}`,
+		`{"and":[{"name":"region", "value":["cn", 5, true], "op":"notin"}]}`,
+		`{"and":[{"name":"model", "value":"^rpi-[34]$", "op":"regex"}]}`,
+		`{"and":[{"name":"firmware", "value":"[2.1,3.0)", "op":"inrange"}]}`,
+		`{"and":[{"name":"firmware", "value":"2.1", "op":"inrange"}]}`,
+		`{"not":[{"or":[{"name":"prop1", "value":"val1"},{"not":[{"name":"prop2", "value":3, "op":">"}]}]}]}`,
+	} {
+		if rp := create_RP(exp, t); rp != nil {
+			if err := rp.IsValid(); err != nil {
+				t.Errorf("Error: %v should be valid, error: %v\n", exp, err)
+			}
+		}
+	}
+}
+
+// Test that expressions using the original comparison operators, which are not checked for the type of their value, are
+// still valid.
+func Test_valid_operators2(t *testing.T) {
+
+	for _, exp := range []string{
+		`{"and":[]}`,
+		`{"or":[{"name":"prop1", "value":"val1", "op":"<"}]}`,
+		`{"and":[{"name":"prop1", "value":"2.1", "op":">="}]}`,
+		`{"and":[{"name":"prop1", "value":["a","b"], "op":"="}]}`,
+		`{"and":[{"name":"prop1", "value":["a"]}]}`,
+		`{"and":[{"name":"prop1", "value":{"a":1}, "op":"!="}]}`,
+		`{"and":[{"name":"", "value":"val1"}]}`,
+	} {
+		if rp := create_RP(exp, t); rp != nil {
+			if err := rp.IsValid(); err != nil {
+				t.Errorf("Error: %v should be valid, error: %v\n", exp, err)
+			}
+		}
+	}
+}
+
+// Test that invalid expressions are detected, and that the error says what is wrong with them.
+func Test_invalid_operators1(t *testing.T) {
+
+	invalid := map[string]string{
+		`{"not":[]}`: "control operator not has an empty array",
+		`{"and":[{"nand":[{"name":"prop1", "value":"val1"}]}]}`:          "nand is not a supported control operator",
+		`{"and":[{"name":5, "value":"val1"}]}`:                           "must have a name that is a string",
+		`{"and":[{"name":"prop1"}]}`:                                     "property prop1 has no value",
+		`{"and":[{"name":"prop1", "value":"val1", "op":5}]}`:             "has an op 5 that is not a string",
+		`{"and":[{"name":"prop1", "value":"val1", "op":"~"}]}`:           "has an unsupported op ~",
+		`{"and":[{"name":"prop1", "value":[], "op":"in"}]}`:              "op in requires a non-empty array of values",
+		`{"and":[{"name":"prop1", "value":"cn", "op":"notin"}]}`:         "op notin requires a non-empty array of values",
+		`{"and":[{"name":"prop1", "value":[["cn"]], "op":"in"}]}`:        "requires an array of strings, numbers or booleans",
+		`{"and":[{"name":"prop1", "value":"rpi-(3", "op":"regex"}]}`:     "invalid regular expression rpi-(3",
+		`{"and":[{"name":"prop1", "value":3, "op":"regex"}]}`:            "op regex requires a regular expression string",
+		`{"and":[{"name":"prop1", "value":"[2.1,3.0", "op":"inrange"}]}`: "invalid version range [2.1,3.0",
+		`{"and":[{"name":"prop1", "value":2.1, "op":"inrange"}]}`:        "op inrange requires a version range string",
+		`{"or":[{}]}`:       "property expression map[] has no name",
+		`{"not":["prop1"]}`: "control operator not contains an element that is not a Property",
+	}
+
+	for exp, reason := range invalid {
+		if rp := create_RP(exp, t); rp != nil {
+			if err := rp.IsValid(); err == nil {
+				t.Errorf("Error: %v is an invalid RequiredProperty value, but it was not detected as invalid.\n", exp)
+			} else if !strings.Contains(err.Error(), reason) {
+				t.Errorf("Error: the error for %v should contain %v, is %v\n", exp, reason, err)
+			}
+		}
+	}
+}
+
+// Test the set, regex, version range and not operators against property lists.
+func Test_satisfy_operators1(t *testing.T) {
+
+	prop_list := `[{"name":"region", "value":"us"}, {"name":"firmware", "value":"2.4.1"}, {"name":"model", "value":"rpi-3"}, {"name":"cores", "value":4}, {"name":"sensors", "value":["gps","camera"]}]`
+
+	satisfied := []string{
+		`{"and":[{"name":"region", "value":["us","ca"], "op":"in"}]}`,
+		`{"and":[{"name":"region", "value":["cn","ru"], "op":"notin"}]}`,
+		`{"and":[{"name":"cores", "value":[2,4], "op":"in"}]}`,
+		`{"and":[{"name":"sensors", "value":["camera"], "op":"in"}]}`,
+		`{"and":[{"name":"sensors", "value":["lidar"], "op":"notin"}]}`,
+		`{"and":[{"name":"model", "value":"^rpi-[34]$", "op":"regex"}]}`,
+		`{"and":[{"name":"firmware", "value":"[2.1,3.0)", "op":"inrange"}]}`,
+		`{"and":[{"name":"cores", "value":"[4,8]", "op":"inrange"}]}`,
+		`{"not":[{"name":"region", "value":"us", "op":"!="}]}`,
+		`{"not":[{"name":"zone", "value":["cn","ru"], "op":"in"}]}`,
+		`{"not":[{"name":"region", "value":["cn","ru"], "op":"in"},{"and":[{"name":"cores", "value":8}]}]}`,
+		`{"and":[{"name":"firmware", "value":"[2.1,3.0)", "op":"inrange"},{"not":[{"name":"region", "value":["cn","ru"], "op":"in"}]}]}`,
+	}
+
+	not_satisfied := []string{
+		`{"and":[{"name":"region", "value":["cn","ru"], "op":"in"}]}`,
+		`{"and":[{"name":"region", "value":["us","ca"], "op":"notin"}]}`,
+		`{"and":[{"name":"zone", "value":["cn","ru"], "op":"notin"}]}`,
+		`{"and":[{"name":"cores", "value":["4"], "op":"in"}]}`,
+		`{"and":[{"name":"sensors", "value":["gps"], "op":"notin"}]}`,
+		`{"and":[{"name":"model", "value":"^rpi-[12]$", "op":"regex"}]}`,
+		`{"and":[{"name":"cores", "value":"4", "op":"regex"}]}`,
+		`{"and":[{"name":"firmware", "value":"[3.0,4.0)", "op":"inrange"}]}`,
+		`{"and":[{"name":"region", "value":"[1.0,2.0)", "op":"inrange"}]}`,
+		`{"not":[{"name":"region", "value":"us"}]}`,
+		`{"not":[{"name":"zone", "value":"eu"},{"or":[{"name":"cores", "value":4}]}]}`,
+	}
+
+	if pa := create_property_list(prop_list, t); pa != nil {
+		for _, exp := range satisfied {
+			if rp := create_RP(exp, t); rp != nil {
+				if err := rp.IsSatisfiedBy(*pa); err != nil {
+					t.Errorf("Error: %v should satisfy %v, error: %v\n", prop_list, exp, err)
+				}
+			}
+		}
+		for _, exp := range not_satisfied {
+			if rp := create_RP(exp, t); rp != nil {
+				if err := rp.IsSatisfiedBy(*pa); err == nil {
+					t.Errorf("Error: %v should not satisfy %v, but it did.\n", prop_list, exp)
+				}
+			}
+		}
+	}
+}
+
 // ================================================================================================================
 // Helper functions used by all tests
 //
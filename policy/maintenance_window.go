@@ -0,0 +1,304 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The purpose of this file is to abstract the operations on maintenance windows. A maintenance window
+// describes when disruptive actions (cancelling an agreement, upgrading a workload or service) are
+// permitted. The schedule is a 5 field cron expression (minute hour day-of-month month day-of-week)
+// giving the times at which the window opens, and the duration says how long it stays open. A window
+// marked as a blackout is the opposite, disruptive actions are not permitted while it is open.
+//
+// When there is more than 1 window in a list, disruptive actions are permitted when any maintenance
+// window is open and no blackout window is open. A blackout always wins, so the windows from a node can
+// be combined with the windows from a pattern or policy without either side losing its blackouts.
+
+const maxWindowSearch = 366 * 24 * time.Hour // How far into the future to look for an open window.
+
+const MaintenanceWindowRetry = time.Hour // How long to defer an action when its windows cannot be evaluated.
+
+type MaintenanceWindow struct {
+	Schedule string `json:"schedule"`           // Cron expression for when the window opens, e.g. "0 2 * * 6,0"
+	Duration int    `json:"duration"`           // How long the window stays open (in seconds)
+	Timezone string `json:"timezone,omitempty"` // IANA timezone name used to interpret the schedule, defaults to UTC
+	Blackout bool   `json:"blackout,omitempty"` // When true, disruptive actions are not permitted while the window is open
+}
+
+func (m MaintenanceWindow) String() string {
+	return fmt.Sprintf("Schedule: %v, Duration: %v, Timezone: %v, Blackout: %v", m.Schedule, m.Duration, m.Timezone, m.Blackout)
+}
+
+func (m MaintenanceWindow) IsSame(compare MaintenanceWindow) bool {
+	return m.Schedule == compare.Schedule && m.Duration == compare.Duration && m.Timezone == compare.Timezone && m.Blackout == compare.Blackout
+}
+
+func (m MaintenanceWindow) IsValid() error {
+	if m.Duration <= 0 {
+		return errors.New(fmt.Sprintf("maintenance window duration must be greater than 0, is %v", m.Duration))
+	} else if _, err := m.location(); err != nil {
+		return errors.New(fmt.Sprintf("maintenance window timezone %v is not valid, error: %v", m.Timezone, err))
+	} else if _, err := parseSchedule(m.Schedule); err != nil {
+		return errors.New(fmt.Sprintf("maintenance window schedule %v is not valid, error: %v", m.Schedule, err))
+	}
+	return nil
+}
+
+func (m MaintenanceWindow) location() (*time.Location, error) {
+	if m.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(m.Timezone)
+}
+
+// Returns true if the window is open at the given time, and if so, when it closes.
+func (m MaintenanceWindow) openAt(t time.Time) (bool, time.Time, error) {
+	sched, err := parseSchedule(m.Schedule)
+	if err != nil {
+		return false, t, err
+	}
+	loc, err := m.location()
+	if err != nil {
+		return false, t, err
+	}
+
+	// The window is open if it opened within the last duration seconds.
+	d := time.Duration(m.Duration) * time.Second
+	start, found := sched.next(t.Add(-d).Add(time.Second), loc, t)
+	if !found {
+		return false, t, nil
+	}
+	return true, start.Add(d), nil
+}
+
+// Returns the next time the window opens, at or after the given time.
+func (m MaintenanceWindow) nextOpen(t time.Time) (time.Time, bool, error) {
+	sched, err := parseSchedule(m.Schedule)
+	if err != nil {
+		return t, false, err
+	}
+	loc, err := m.location()
+	if err != nil {
+		return t, false, err
+	}
+	start, found := sched.next(t, loc, t.Add(maxWindowSearch))
+	return start, found, nil
+}
+
+type MaintenanceWindowList []MaintenanceWindow
+
+func (l MaintenanceWindowList) IsValid() error {
+	for _, w := range l {
+		if err := w.IsValid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Convert the windows held in an attribute, or any other generic form, into a list of maintenance windows.
+func ConvertToMaintenanceWindowList(list interface{}) (MaintenanceWindowList, error) {
+	newList := make(MaintenanceWindowList, 0, 5)
+	if list == nil {
+		return newList, nil
+	} else if bytes, err := json.Marshal(list); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to marshal maintenance windows %v, error: %v", list, err))
+	} else if err := json.Unmarshal(bytes, &newList); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to demarshal maintenance windows %v, error: %v", string(bytes), err))
+	}
+	return newList, nil
+}
+
+// Add the windows from the other list that are not already in this list.
+func (l *MaintenanceWindowList) Concatenate(other *MaintenanceWindowList) {
+	for _, ow := range *other {
+		found := false
+		for _, w := range *l {
+			if w.IsSame(ow) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*l = append(*l, ow)
+		}
+	}
+}
+
+// Returns the earliest time, at or after now, at which disruptive actions are permitted. If they are
+// permitted right now, now is returned. An error is returned if no maintenance window opens outside of
+// the blackouts within the search horizon, or if one of the windows is not valid.
+func (l MaintenanceWindowList) NextAllowed(now time.Time) (time.Time, error) {
+
+	t := now
+	limit := now.Add(maxWindowSearch)
+
+	for !t.After(limit) {
+
+		// Nothing is allowed until every blackout that is open has closed.
+		resume := t
+		windows := 0
+		for _, w := range l {
+			if !w.Blackout {
+				windows += 1
+			} else if open, end, err := w.openAt(t); err != nil {
+				return now, err
+			} else if open && end.After(resume) {
+				resume = end
+			}
+		}
+
+		if resume.After(t) {
+			t = resume
+			continue
+		} else if windows == 0 {
+			return t, nil
+		}
+
+		// Outside of the blackouts, any open maintenance window allows the action. Otherwise the search resumes
+		// from the earliest time one of them opens.
+		found := false
+		for _, w := range l {
+			if w.Blackout {
+				continue
+			} else if open, _, err := w.openAt(t); err != nil {
+				return now, err
+			} else if open {
+				return t, nil
+			} else if start, ok, err := w.nextOpen(t); err != nil {
+				return now, err
+			} else if ok && (!found || start.Before(resume)) {
+				resume = start
+				found = true
+			}
+		}
+
+		if !found {
+			break
+		}
+		t = resume
+	}
+
+	return now, errors.New(fmt.Sprintf("maintenance windows %v do not permit disruptive actions before %v", l, limit))
+}
+
+// A parsed cron schedule. Each field holds the set of values the corresponding time component can have.
+type cronSchedule struct {
+	minute  map[int]bool
+	hour    map[int]bool
+	dom     map[int]bool
+	month   map[int]bool
+	dow     map[int]bool
+	domStar bool
+	dowStar bool
+}
+
+func parseSchedule(s string) (*cronSchedule, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, errors.New(fmt.Sprintf("expected 5 fields (minute hour day-of-month month day-of-week), found %v", len(fields)))
+	}
+
+	cs := new(cronSchedule)
+	var err error
+	if cs.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, errors.New(fmt.Sprintf("minute field %v, %v", fields[0], err))
+	} else if cs.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, errors.New(fmt.Sprintf("hour field %v, %v", fields[1], err))
+	} else if cs.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, errors.New(fmt.Sprintf("day-of-month field %v, %v", fields[2], err))
+	} else if cs.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, errors.New(fmt.Sprintf("month field %v, %v", fields[3], err))
+	} else if cs.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, errors.New(fmt.Sprintf("day-of-week field %v, %v", fields[4], err))
+	}
+
+	// Sunday can be written as 0 or 7.
+	if cs.dow[7] {
+		cs.dow[0] = true
+	}
+	cs.domStar = strings.HasPrefix(fields[2], "*")
+	cs.dowStar = strings.HasPrefix(fields[4], "*")
+
+	return cs, nil
+}
+
+// Parse a single cron field. Supported forms are *, */step, n, n-m, n-m/step and comma separated lists of these.
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if ix := strings.Index(part, "/"); ix != -1 {
+			var err error
+			if step, err = strconv.Atoi(part[ix+1:]); err != nil || step <= 0 {
+				return nil, errors.New(fmt.Sprintf("step %v is not a positive integer", part[ix+1:]))
+			}
+			part = part[:ix]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, errors.New(fmt.Sprintf("%v is not an integer", bounds[0]))
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, errors.New(fmt.Sprintf("%v is not an integer", bounds[1]))
+				}
+			} else if step != 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return nil, errors.New(fmt.Sprintf("range %v-%v is outside %v-%v", lo, hi, min, max))
+		}
+		for v := lo; v <= hi; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Cron semantics: when both day fields are restricted, a day matches if either one does.
+func (cs *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := cs.dom[t.Day()]
+	dowMatch := cs.dow[int(t.Weekday())]
+	if cs.domStar || cs.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Returns the first time at or after from, and not after limit, that matches the schedule in the given location.
+func (cs *cronSchedule) next(from time.Time, loc *time.Location, limit time.Time) (time.Time, bool) {
+
+	// Schedules are minute granular, so round up to the next whole minute.
+	t := from.In(loc)
+	if t.Second() != 0 || t.Nanosecond() != 0 {
+		t = t.Truncate(time.Minute).Add(time.Minute)
+	}
+
+	for !t.After(limit) {
+		if !cs.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		} else if !cs.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		} else if !cs.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		} else if !cs.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+		} else {
+			return t, true
+		}
+	}
+	return limit, false
+}
@@ -0,0 +1,195 @@
+// +build unit
+
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_maintenance_window_isvalid(t *testing.T) {
+
+	good := []MaintenanceWindow{
+		{Schedule: "0 2 * * *", Duration: 3600},
+		{Schedule: "*/15 22-23,0-5 1-7 */2 0,6-7", Duration: 60, Timezone: "America/New_York"},
+		{Schedule: "30 6 * * 1-5", Duration: 57600, Timezone: "Europe/Berlin", Blackout: true},
+	}
+	for _, w := range good {
+		if err := w.IsValid(); err != nil {
+			t.Errorf("window %v should be valid, error: %v", w, err)
+		}
+	}
+
+	bad := []MaintenanceWindow{
+		{Schedule: "0 2 * *", Duration: 3600},
+		{Schedule: "60 2 * * *", Duration: 3600},
+		{Schedule: "0 24 * * *", Duration: 3600},
+		{Schedule: "0 2 0 * *", Duration: 3600},
+		{Schedule: "0 2 * 13 *", Duration: 3600},
+		{Schedule: "0 2 * * 8", Duration: 3600},
+		{Schedule: "0 5-2 * * *", Duration: 3600},
+		{Schedule: "*/0 2 * * *", Duration: 3600},
+		{Schedule: "a 2 * * *", Duration: 3600},
+		{Schedule: "0 2 * * *", Duration: 0},
+		{Schedule: "0 2 * * *", Duration: 3600, Timezone: "Nowhere/Special"},
+	}
+	for _, w := range bad {
+		if err := w.IsValid(); err == nil {
+			t.Errorf("window %v should not be valid", w)
+		}
+	}
+
+	l := MaintenanceWindowList{good[0], bad[0]}
+	if err := l.IsValid(); err == nil {
+		t.Errorf("list %v should not be valid", l)
+	}
+
+}
+
+func Test_maintenance_window_concatenate(t *testing.T) {
+
+	l1 := MaintenanceWindowList{{Schedule: "0 2 * * *", Duration: 3600}}
+	l2 := MaintenanceWindowList{{Schedule: "0 2 * * *", Duration: 3600}, {Schedule: "0 8 * * *", Duration: 3600, Blackout: true}}
+
+	(&l1).Concatenate(&l2)
+	if len(l1) != 2 {
+		t.Errorf("list should have 2 windows, is %v", l1)
+	}
+
+}
+
+func Test_maintenance_window_nextallowed_none(t *testing.T) {
+
+	now := time.Date(2018, time.June, 4, 12, 30, 15, 0, time.UTC)
+
+	l := MaintenanceWindowList{}
+	if next, err := l.NextAllowed(now); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !next.Equal(now) {
+		t.Errorf("should be allowed now, returned %v", next)
+	}
+
+}
+
+func Test_maintenance_window_nextallowed_window(t *testing.T) {
+
+	// A window every day from 02:00 to 04:00 UTC.
+	l := MaintenanceWindowList{{Schedule: "0 2 * * *", Duration: 7200}}
+
+	now := time.Date(2018, time.June, 4, 12, 30, 15, 0, time.UTC)
+	expected := time.Date(2018, time.June, 5, 2, 0, 0, 0, time.UTC)
+	if next, err := l.NextAllowed(now); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !next.Equal(expected) {
+		t.Errorf("next should be %v, is %v", expected, next)
+	}
+
+	// Inside the window.
+	now = time.Date(2018, time.June, 4, 3, 59, 59, 0, time.UTC)
+	if next, err := l.NextAllowed(now); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !next.Equal(now) {
+		t.Errorf("next should be %v, is %v", now, next)
+	}
+
+	// Just after the window closes.
+	now = time.Date(2018, time.June, 4, 4, 0, 0, 0, time.UTC)
+	expected = time.Date(2018, time.June, 5, 2, 0, 0, 0, time.UTC)
+	if next, err := l.NextAllowed(now); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !next.Equal(expected) {
+		t.Errorf("next should be %v, is %v", expected, next)
+	}
+
+}
+
+func Test_maintenance_window_nextallowed_timezone(t *testing.T) {
+
+	// A window on saturdays and sundays at 01:30 New York time.
+	l := MaintenanceWindowList{{Schedule: "30 1 * * 6,7", Duration: 1800, Timezone: "America/New_York"}}
+
+	// Monday June 4th 2018, New York is UTC-4 in June.
+	now := time.Date(2018, time.June, 4, 12, 0, 0, 0, time.UTC)
+	expected := time.Date(2018, time.June, 9, 5, 30, 0, 0, time.UTC)
+	if next, err := l.NextAllowed(now); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !next.Equal(expected) {
+		t.Errorf("next should be %v, is %v", expected, next)
+	}
+
+}
+
+func Test_maintenance_window_nextallowed_blackout(t *testing.T) {
+
+	// No disruptions between 06:00 and 22:00 UTC.
+	l := MaintenanceWindowList{{Schedule: "0 6 * * *", Duration: 16 * 3600, Blackout: true}}
+
+	now := time.Date(2018, time.June, 4, 12, 0, 0, 0, time.UTC)
+	expected := time.Date(2018, time.June, 4, 22, 0, 0, 0, time.UTC)
+	if next, err := l.NextAllowed(now); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !next.Equal(expected) {
+		t.Errorf("next should be %v, is %v", expected, next)
+	}
+
+	now = time.Date(2018, time.June, 4, 23, 0, 0, 0, time.UTC)
+	if next, err := l.NextAllowed(now); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !next.Equal(now) {
+		t.Errorf("next should be %v, is %v", now, next)
+	}
+
+}
+
+func Test_maintenance_window_nextallowed_combined(t *testing.T) {
+
+	// The node allows disruptions every night from 01:00 to 02:00, the pattern allows them from 04:00 to
+	// 06:00, and there is a blackout on the 5th of every month. The windows never overlap, either of them
+	// allows the action.
+	l := MaintenanceWindowList{
+		{Schedule: "0 1 * * *", Duration: 3600},
+		{Schedule: "0 4 * * *", Duration: 2 * 3600},
+		{Schedule: "0 0 5 * *", Duration: 24 * 3600, Blackout: true},
+	}
+
+	now := time.Date(2018, time.June, 4, 12, 0, 0, 0, time.UTC)
+	expected := time.Date(2018, time.June, 6, 1, 0, 0, 0, time.UTC)
+	if next, err := l.NextAllowed(now); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !next.Equal(expected) {
+		t.Errorf("next should be %v, is %v", expected, next)
+	}
+
+	// Inside the second window.
+	now = time.Date(2018, time.June, 6, 5, 0, 0, 0, time.UTC)
+	if next, err := l.NextAllowed(now); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !next.Equal(now) {
+		t.Errorf("next should be %v, is %v", now, next)
+	}
+
+	// A blackout wins over an open window.
+	now = time.Date(2018, time.July, 5, 1, 30, 0, 0, time.UTC)
+	expected = time.Date(2018, time.July, 6, 1, 0, 0, 0, time.UTC)
+	if next, err := l.NextAllowed(now); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !next.Equal(expected) {
+		t.Errorf("next should be %v, is %v", expected, next)
+	}
+
+}
+
+func Test_maintenance_window_nextallowed_never(t *testing.T) {
+
+	// The window is always inside the blackout.
+	l := MaintenanceWindowList{
+		{Schedule: "0 1 * * *", Duration: 3600},
+		{Schedule: "0 0 * * *", Duration: 24 * 3600, Blackout: true},
+	}
+
+	now := time.Date(2018, time.June, 4, 12, 0, 0, 0, time.UTC)
+	if _, err := l.NextAllowed(now); err == nil {
+		t.Errorf("expected an error")
+	}
+
+}
@@ -14,7 +14,7 @@ import (
 // can take any version.
 // maxAgreements: 0 means unlimited.
 
-func GeneratePolicy(sensorUrl string, sensorOrg string, sensorName string, sensorVersion string, arch string, props *map[string]interface{}, haPartners []string, meterPolicy Meter, counterPartyProperties RequiredProperty, agps []AgreementProtocol, maxAgreements int, filePath string, deviceOrg string) (*events.PolicyCreatedMessage, error) {
+func GeneratePolicy(sensorUrl string, sensorOrg string, sensorName string, sensorVersion string, arch string, props *map[string]interface{}, haPartners []string, meterPolicy Meter, counterPartyProperties RequiredProperty, windows MaintenanceWindowList, agps []AgreementProtocol, maxAgreements int, filePath string, deviceOrg string) (*events.PolicyCreatedMessage, error) {
 
 	glog.V(5).Infof("Generating policy for %v/%v", sensorOrg, sensorUrl)
 
@@ -51,6 +51,11 @@ func GeneratePolicy(sensorUrl string, sensorOrg string, sensorName string, senso
 		p.Add_CounterPartyProperties(&counterPartyProperties)
 	}
 
+	// Add the node's maintenance windows so that they end up in the terms and conditions of its agreements
+	if len(windows) != 0 {
+		p.MaintenanceWindows = windows
+	}
+
 	p.MaxAgreements = maxAgreements
 
 	// Store the policy on the filesystem
@@ -66,6 +66,8 @@ type Policy struct {
 	RequiredWorkload       string                `json:"requiredWorkload,omitempty"`       // Version 2.0
 	HAGroup                HighAvailabilityGroup `json:"ha_group,omitempty"`               // Version 2.0
 	NodeH                  NodeHealth            `json:"nodeHealth,omitempty"`             // Version 2.0
+	MaintenanceWindows     MaintenanceWindowList `json:"maintenanceWindows,omitempty"`     // Version 2.0
+	Paused                 bool                  `json:"paused,omitempty"`                 // No new agreements are made with a paused policy
 }
 
 // These functions are used to create Policy objects. You can create the base object
@@ -230,6 +232,8 @@ func Are_Compatible_Producers(producer_policy1 *Policy, producer_policy2 *Policy
 	merged_pol.CounterPartyProperties = *((&producer_policy1.CounterPartyProperties).Merge(&producer_policy2.CounterPartyProperties))
 	merged_pol.HAGroup = *((&producer_policy1.HAGroup).Merge(&producer_policy2.HAGroup))
 	merged_pol.MaxAgreements = cutil.Min(producer_policy1.MaxAgreements, producer_policy2.MaxAgreements)
+	(&merged_pol.MaintenanceWindows).Concatenate(&producer_policy1.MaintenanceWindows)
+	(&merged_pol.MaintenanceWindows).Concatenate(&producer_policy2.MaintenanceWindows)
 
 	return merged_pol, nil
 }
@@ -270,6 +274,8 @@ func Create_Terms_And_Conditions(producer_policy *Policy, consumer_policy *Polic
 		merged_pol.RequiredWorkload = producer_policy.RequiredWorkload
 		merged_pol.HAGroup = producer_policy.HAGroup
 		merged_pol.NodeH = consumer_policy.NodeH
+		(&merged_pol.MaintenanceWindows).Concatenate(&consumer_policy.MaintenanceWindows)
+		(&merged_pol.MaintenanceWindows).Concatenate(&producer_policy.MaintenanceWindows)
 
 		return merged_pol, nil
 	}
@@ -283,6 +289,11 @@ func (self *Policy) Is_Self_Consistent(keyFileNames []string,
 		return errors.New(fmt.Sprintf("Data Verification section is not valid, error: %v", err))
 	}
 
+	// Check validity of the maintenance windows
+	if err := self.MaintenanceWindows.IsValid(); err != nil {
+		return errors.New(fmt.Sprintf("MaintenanceWindows section of %v has error %v", self.Header.Name, err))
+	}
+
 	// Check validity of the agreement protocol list
 	for _, agp := range self.AgreementProtocols {
 		if err := agp.IsValid(); err != nil {
@@ -385,6 +396,8 @@ func (self *Policy) String() string {
 	res += fmt.Sprintf("CounterPartyProperties: %v\n", self.CounterPartyProperties)
 	res += fmt.Sprintf("Data Verification: %v\n", self.DataVerify)
 	res += fmt.Sprintf("Node Health: %v\n", self.NodeH)
+	res += fmt.Sprintf("Maintenance Windows: %v\n", self.MaintenanceWindows)
+	res += fmt.Sprintf("Paused: %v\n", self.Paused)
 
 	return res
 }
@@ -645,7 +645,9 @@ func (self *PolicyManager) GetAllAvailablePolicies(org string) []Policy {
 			keyName = cutil.FormOrgSpecUrl(pol.APISpecs[0].SpecRef, pol.APISpecs[0].Org)
 		}
 
-		if self.AgreementTracking && self.unlockedReachedMaxAgreements(pol, self.AgreementCounts[org][keyName].Count) {
+		if pol.Paused {
+			glog.V(3).Infof("Skipping policy %v, it is paused.", pol.Header.Name)
+		} else if self.AgreementTracking && self.unlockedReachedMaxAgreements(pol, self.AgreementCounts[org][keyName].Count) {
 			glog.V(3).Infof("Skipping policy %v, reached maximum of %v agreements.", pol.Header.Name, self.AgreementCounts[org][keyName].Count)
 		} else {
 			policies = append(policies, *pol)
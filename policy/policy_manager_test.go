@@ -381,6 +381,39 @@ func Test_add_policy(t *testing.T) {
 	}
 }
 
+func Test_paused_policy(t *testing.T) {
+	if pm, err := Initialize("./test/pffindtest/", make(map[string]string), nil, true, false); err != nil {
+		t.Error(err)
+	} else {
+
+		available := len(pm.GetAllAvailablePolicies("testorg"))
+
+		newPolicyContent := `{"header":{"name":"paused policy","version":"2.0"},"paused":true}`
+		newPolicy := new(Policy)
+		if err := json.Unmarshal([]byte(newPolicyContent), newPolicy); err != nil {
+			t.Errorf("Error demarshalling new policy: %v", err)
+		} else if err := pm.AddPolicy("testorg", newPolicy); err != nil {
+			t.Errorf("Error adding new policy: %v", err)
+		} else if pols := pm.GetAllAvailablePolicies("testorg"); len(pols) != available {
+			t.Errorf("Paused policy should not be available, have %v", pols)
+		} else if len(pm.GetAllPolicies("testorg")) != available+1 {
+			t.Errorf("Paused policy should still be in the policy manager")
+		}
+
+		// Resuming the policy does not change what it matches.
+		resumed := *newPolicy
+		resumed.Paused = false
+		if err := pm.MatchesMine("testorg", &resumed); err != nil {
+			t.Errorf("Resumed policy should match the paused policy, error %v", err)
+		}
+		pm.UpdatePolicy("testorg", &resumed)
+		if pols := pm.GetAllAvailablePolicies("testorg"); len(pols) != available+1 {
+			t.Errorf("Resumed policy should be available, have %v", pols)
+		}
+
+	}
+}
+
 func Test_MergeAllProducers1(t *testing.T) {
 
 	pa := `{"header":{"name":"ms1 policy","version": "2.0"},` +
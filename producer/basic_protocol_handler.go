@@ -3,7 +3,6 @@ package producer
 import (
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/abstractprotocol"
 	"github.com/open-horizon/anax/basicprotocol"
@@ -20,15 +19,16 @@ type BasicProtocolHandler struct {
 	agreementPH *basicprotocol.ProtocolHandler
 }
 
-func NewBasicProtocolHandler(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager, ec exchange.ExchangeContext) *BasicProtocolHandler {
+func NewBasicProtocolHandler(name string, cfg *config.HorizonConfig, db persistence.EdgeDatabase, pm *policy.PolicyManager, ec exchange.ExchangeContext) *BasicProtocolHandler {
 	if name == basicprotocol.PROTOCOL_NAME {
 		return &BasicProtocolHandler{
 			BaseProducerProtocolHandler: &BaseProducerProtocolHandler{
-				name:   name,
-				pm:     pm,
-				db:     db,
-				config: cfg,
-				ec:     ec,
+				name:      name,
+				pm:        pm,
+				db:        db,
+				config:    cfg,
+				ec:        ec,
+				preflight: newPreflightChecker(cfg, db),
 			},
 			agreementPH: basicprotocol.NewProtocolHandler(cfg.Collaborators.HTTPClientFactory.NewHTTPClient(nil), pm),
 		}
@@ -62,7 +62,7 @@ func (c *BasicProtocolHandler) AcceptCommand(cmd worker.Command) bool {
 
 func (c *BasicProtocolHandler) HandleProposalMessage(proposal abstractprotocol.Proposal, protocolMsg string, exchangeMsg *exchange.DeviceMessage) bool {
 
-	if handled, reply, tcPolicy := c.HandleProposal(c.agreementPH, proposal, protocolMsg, []map[string]string{}, exchangeMsg); handled {
+	if handled, reply, tcPolicy := c.HandleProposal(c.agreementPH, proposal, protocolMsg, []map[string]string{}, exchangeMsg, c.GetTerminationCode); handled {
 		if reply != nil {
 			c.PersistProposal(proposal, reply, tcPolicy, protocolMsg)
 		}
@@ -157,6 +157,14 @@ func (c *BasicProtocolHandler) GetTerminationCode(reason string) uint {
 		return basicprotocol.CANCEL_NODE_SHUTDOWN
 	case TERM_REASON_SERVICE_SUSPENDED:
 		return basicprotocol.CANCEL_SERVICE_SUSPENDED
+	case TERM_REASON_PREFLIGHT_DISK:
+		return basicprotocol.CANCEL_PREFLIGHT_DISK
+	case TERM_REASON_PREFLIGHT_MEMORY:
+		return basicprotocol.CANCEL_PREFLIGHT_MEMORY
+	case TERM_REASON_PREFLIGHT_DEVICE:
+		return basicprotocol.CANCEL_PREFLIGHT_DEVICE
+	case TERM_REASON_IMAGE_DIGEST_MISMATCH:
+		return basicprotocol.CANCEL_IMAGE_DIGEST_MISMATCH
 	default:
 		return 999
 	}
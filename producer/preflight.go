@@ -0,0 +1,203 @@
+package producer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"golang.org/x/sys/unix"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A proposal is only compatible with the node's policy, it says nothing about whether the node can actually run the
+// workload. The pre-flight checks look at the node's free disk, free memory and devices before the proposal is
+// accepted, so that the node rejects, with a reason the agbot understands, an agreement it could never deploy. A
+// check that can't be made, because the node can't read its own resources, does not reject the proposal.
+
+type preflightChecker struct {
+	config       *config.HorizonConfig
+	db           persistence.EdgeDatabase
+	freeDiskMB   func(dir string) (int64, error)
+	freeMemoryMB func() (int64, error)
+	dockerRoot   func() (string, error)
+	deviceExists func(path string) bool
+}
+
+func newPreflightChecker(cfg *config.HorizonConfig, db persistence.EdgeDatabase) *preflightChecker {
+	return &preflightChecker{
+		config:       cfg,
+		db:           db,
+		freeDiskMB:   freeDiskMB,
+		freeMemoryMB: freeMemoryMB,
+		dockerRoot: func() (string, error) {
+			return dockerRoot(cfg.Edge.DockerEndpoint)
+		},
+		deviceExists: func(path string) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		},
+	}
+}
+
+// Returns the termination reason and why the node can't run the workload in the terms and conditions, or an empty
+// reason when it can.
+func (p *preflightChecker) check(tcPolicy *policy.Policy) (string, string) {
+	if p.config.Edge.Preflight.Disabled {
+		return "", ""
+	}
+
+	// Both the service storage and the images need disk space.
+	dirs := make([]string, 0, 2)
+	if p.config.Edge.ServiceStorage != "" {
+		dirs = append(dirs, p.config.Edge.ServiceStorage)
+	}
+	if root, err := p.dockerRoot(); err != nil {
+		glog.Warningf(BPPHlogString("preflight", fmt.Sprintf("unable to check the docker root, %v", err)))
+	} else {
+		dirs = append(dirs, root)
+	}
+	for _, dir := range dirs {
+		if free, err := p.freeDiskMB(dir); err != nil {
+			glog.Warningf(BPPHlogString("preflight", fmt.Sprintf("unable to check the free disk space under %v, %v", dir, err)))
+		} else if free < p.config.Edge.Preflight.MinFreeDiskMB {
+			return TERM_REASON_PREFLIGHT_DISK, fmt.Sprintf("only %vMB of disk is free under %v, %vMB is needed", free, dir, p.config.Edge.Preflight.MinFreeDiskMB)
+		}
+	}
+
+	if needed := p.requiredRAM(tcPolicy); needed > 0 {
+		if free, err := p.freeMemoryMB(); err != nil {
+			glog.Warningf(BPPHlogString("preflight", fmt.Sprintf("unable to check the free memory, %v", err)))
+		} else if free < needed {
+			return TERM_REASON_PREFLIGHT_MEMORY, fmt.Sprintf("only %vMB of memory is free, the service needs %vMB", free, needed)
+		}
+	}
+
+	for _, dd := range p.deployments(tcPolicy) {
+		for name, service := range dd.Services {
+			for _, device := range service.Devices {
+				if host := strings.SplitN(device, ":", 2)[0]; !p.deviceExists(host) {
+					return TERM_REASON_PREFLIGHT_DEVICE, fmt.Sprintf("device %v that service %v needs does not exist", host, name)
+				}
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// The RAM, in MB, that the workload is registered to need. An attribute that is specific to the workload wins over
+// one for all services, and a workload without either needs the node's default.
+func (p *preflightChecker) requiredRAM(tcPolicy *policy.Policy) int64 {
+	if len(tcPolicy.Workloads) == 0 {
+		return 0
+	}
+
+	ram := p.config.Edge.DefaultServiceRegistrationRAM
+	attrs, err := persistence.FindApplicableAttributes(p.db, tcPolicy.Workloads[0].WorkloadURL, tcPolicy.Workloads[0].Org)
+	if err != nil {
+		glog.Warningf(BPPHlogString("preflight", fmt.Sprintf("unable to read the attributes of %v, %v", tcPolicy.Workloads[0].WorkloadURL, err)))
+		return ram
+	}
+
+	specific := false
+	for _, attr := range attrs {
+		compute, ok := attr.(persistence.ComputeAttributes)
+		if !ok {
+			continue
+		}
+		serviceSpecs := persistence.GetAttributeServiceSpecs(&attr)
+		if serviceSpecs != nil && len(*serviceSpecs) != 0 {
+			ram, specific = compute.RAM, true
+		} else if !specific {
+			ram = compute.RAM
+		}
+	}
+	return ram
+}
+
+// The deployments of the workload and of the dependent services that the node already has definitions for. A
+// deployment that can't be read is skipped, it is rejected when the service is started.
+func (p *preflightChecker) deployments(tcPolicy *policy.Policy) []*containermessage.DeploymentDescription {
+	deployments := make([]string, 0)
+	if len(tcPolicy.Workloads) != 0 && tcPolicy.Workloads[0].Deployment != "" {
+		deployments = append(deployments, tcPolicy.Workloads[0].Deployment)
+	}
+	for _, spec := range tcPolicy.APISpecs {
+		msdefs, err := persistence.FindMicroserviceDefs(p.db, []persistence.MSFilter{persistence.UnarchivedMSFilter(), persistence.UrlOrgMSFilter(spec.SpecRef, spec.Org)})
+		if err != nil {
+			glog.Warningf(BPPHlogString("preflight", fmt.Sprintf("unable to read the service definitions of %v, %v", spec.SpecRef, err)))
+			continue
+		}
+		for _, msdef := range msdefs {
+			if deployment, _, _ := msdef.GetDeployment(); deployment != "" {
+				deployments = append(deployments, deployment)
+			}
+		}
+	}
+
+	dds := make([]*containermessage.DeploymentDescription, 0, len(deployments))
+	for _, deployment := range deployments {
+		dd := new(containermessage.DeploymentDescription)
+		if err := json.Unmarshal([]byte(deployment), dd); err != nil {
+			glog.Warningf(BPPHlogString("preflight", fmt.Sprintf("unable to demarshal deployment %v, %v", deployment, err)))
+			continue
+		}
+		dds = append(dds, dd)
+	}
+	return dds
+}
+
+// The space, in MB, that is available to unprivileged users in the file system of the dir.
+func freeDiskMB(dir string) (int64, error) {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(dir, &fs); err != nil {
+		return 0, err
+	}
+	return int64(fs.Bavail) * int64(fs.Bsize) / (1024 * 1024), nil
+}
+
+// The memory, in MB, that can be used without swapping, from MemAvailable in /proc/meminfo.
+func freeMemoryMB() (int64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseMemAvailable(bufio.NewScanner(f))
+}
+
+func parseMemAvailable(scanner *bufio.Scanner) (int64, error) {
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("unable to parse MemAvailable %v, error %v", fields[1], err))
+		}
+		return kb / 1024, nil
+	}
+	return 0, errors.New("MemAvailable not found")
+}
+
+// The directory where docker keeps its images.
+func dockerRoot(endpoint string) (string, error) {
+	client, err := docker.NewClient(endpoint)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("unable to create docker client from %v, error %v", endpoint, err))
+	}
+	info, err := client.Info()
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("unable to get docker info, error %v", err))
+	}
+	return info.DockerRootDir, nil
+}
@@ -0,0 +1,119 @@
+// +build unit
+
+package producer
+
+import (
+	"bufio"
+	"errors"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func Test_parseMemAvailable(t *testing.T) {
+
+	meminfo := "MemTotal:        8038576 kB\nMemFree:          512000 kB\nMemAvailable:    2097152 kB\n"
+	if mb, err := parseMemAvailable(bufio.NewScanner(strings.NewReader(meminfo))); err != nil {
+		t.Errorf("parseMemAvailable returned an error: %v", err)
+	} else if mb != 2048 {
+		t.Errorf("parseMemAvailable returned %v, expected 2048", mb)
+	}
+
+	if _, err := parseMemAvailable(bufio.NewScanner(strings.NewReader("MemTotal: 8038576 kB\n"))); err == nil {
+		t.Errorf("parseMemAvailable should have returned an error without MemAvailable")
+	}
+}
+
+func Test_preflightChecker(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "preflight-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := persistence.OpenBoltDatabase(path.Join(dir, "anax-int.db"))
+	if err != nil {
+		t.Fatalf("unable to open db: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.HorizonConfig{Edge: config.Config{
+		ServiceStorage:                "/var/horizon/storage",
+		DefaultServiceRegistrationRAM: 128,
+		Preflight:                     config.PreflightConfig{MinFreeDiskMB: 500},
+	}}
+
+	freeDisk := map[string]int64{"/var/horizon/storage": 1000, "/var/lib/docker": 1000}
+	freeMemory := int64(1024)
+	devices := map[string]bool{"/dev/video0": true}
+	p := &preflightChecker{
+		config: cfg,
+		db:     db,
+		freeDiskMB: func(dir string) (int64, error) {
+			return freeDisk[dir], nil
+		},
+		freeMemoryMB: func() (int64, error) {
+			return freeMemory, nil
+		},
+		dockerRoot: func() (string, error) {
+			return "/var/lib/docker", nil
+		},
+		deviceExists: func(path string) bool {
+			return devices[path]
+		},
+	}
+
+	tcPolicy := &policy.Policy{Workloads: []policy.Workload{{
+		WorkloadURL: "camera",
+		Org:         "myorg",
+		Deployment:  `{"services":{"camera":{"image":"myorg/camera:1.0.0","devices":["/dev/video0:/dev/video0"]}}}`,
+	}}}
+
+	if reason, desc := p.check(tcPolicy); reason != "" {
+		t.Errorf("check should have passed: %v %v", reason, desc)
+	}
+
+	freeDisk["/var/lib/docker"] = 100
+	if reason, _ := p.check(tcPolicy); reason != TERM_REASON_PREFLIGHT_DISK {
+		t.Errorf("check should have failed for the docker root disk, failed with %v", reason)
+	}
+
+	// A file system that can't be checked does not reject the proposal.
+	p.dockerRoot = func() (string, error) { return "", errors.New("docker is not running") }
+	if reason, desc := p.check(tcPolicy); reason != "" {
+		t.Errorf("check should have passed: %v %v", reason, desc)
+	}
+
+	// The default RAM is needed when the workload has no compute attribute, and the workload's own attribute wins.
+	freeMemory = 100
+	if reason, _ := p.check(tcPolicy); reason != TERM_REASON_PREFLIGHT_MEMORY {
+		t.Errorf("check should have failed for memory, failed with %v", reason)
+	}
+	for _, attr := range []persistence.ComputeAttributes{
+		{Meta: &persistence.AttributeMeta{Id: "compute", Type: "ComputeAttributes"}, ServiceSpecs: &persistence.ServiceSpecs{}, RAM: 2048},
+		{Meta: &persistence.AttributeMeta{Id: "camera", Type: "ComputeAttributes"}, ServiceSpecs: &persistence.ServiceSpecs{{Url: "camera", Org: "myorg"}}, RAM: 64},
+	} {
+		if _, err := persistence.SaveOrUpdateAttribute(db, attr, "", false); err != nil {
+			t.Fatalf("unable to save attribute: %v", err)
+		}
+	}
+	if reason, desc := p.check(tcPolicy); reason != "" {
+		t.Errorf("check should have passed: %v %v", reason, desc)
+	}
+
+	delete(devices, "/dev/video0")
+	if reason, desc := p.check(tcPolicy); reason != TERM_REASON_PREFLIGHT_DEVICE || !strings.Contains(desc, "/dev/video0") {
+		t.Errorf("check should have failed for the device, failed with %v %v", reason, desc)
+	}
+
+	cfg.Edge.Preflight.Disabled = true
+	if reason, desc := p.check(tcPolicy); reason != "" {
+		t.Errorf("a disabled check should have passed: %v %v", reason, desc)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/abstractprotocol"
 	"github.com/open-horizon/anax/api"
@@ -19,7 +18,7 @@ import (
 	"time"
 )
 
-func CreateProducerPH(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager, ec exchange.ExchangeContext) ProducerProtocolHandler {
+func CreateProducerPH(name string, cfg *config.HorizonConfig, db persistence.EdgeDatabase, pm *policy.PolicyManager, ec exchange.ExchangeContext) ProducerProtocolHandler {
 	if handler := NewBasicProtocolHandler(name, cfg, db, pm, ec); handler != nil {
 		return handler
 	} // Add new producer side protocol handlers here
@@ -51,11 +50,12 @@ type ProducerProtocolHandler interface {
 }
 
 type BaseProducerProtocolHandler struct {
-	name   string
-	pm     *policy.PolicyManager
-	db     *bolt.DB
-	config *config.HorizonConfig
-	ec     exchange.ExchangeContext
+	name      string
+	pm        *policy.PolicyManager
+	db        persistence.EdgeDatabase
+	config    *config.HorizonConfig
+	ec        exchange.ExchangeContext
+	preflight *preflightChecker
 }
 
 func (w *BaseProducerProtocolHandler) GetSendMessage() func(mt interface{}, pay []byte) error {
@@ -134,7 +134,9 @@ func (w *BaseProducerProtocolHandler) serviceResolver(wURL string, wOrg string,
 	return asl, err
 }
 
-func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.ProtocolHandler, proposal abstractprotocol.Proposal, protocolMsg string, runningBCs []map[string]string, exchangeMsg *exchange.DeviceMessage) (bool, abstractprotocol.ProposalReply, *policy.Policy) {
+// The terminationCode function turns the reason for failing a pre-flight check into the protocol's reason code, which
+// is sent to the agbot in the negative reply.
+func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.ProtocolHandler, proposal abstractprotocol.Proposal, protocolMsg string, runningBCs []map[string]string, exchangeMsg *exchange.DeviceMessage, terminationCode func(reason string) uint) (bool, abstractprotocol.ProposalReply, *policy.Policy) {
 
 	handled := false
 
@@ -216,6 +218,26 @@ func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.Protoco
 		} else if messageTarget, err := exchange.CreateMessageTarget(exchangeMsg.AgbotId, nil, exchangeMsg.AgbotPubKey, ""); err != nil {
 			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("error creating message target: %v", err)))
 			err_log_event = fmt.Sprintf("Error creating message target: %v", err)
+		} else if reason, desc := w.preflight.check(tcPolicy); reason != "" {
+			handled = true
+			glog.Warningf(BPPHlogString(w.Name(), fmt.Sprintf("rejecting proposal %v, pre-flight check failed: %v", proposal.AgreementId(), desc)))
+			eventlog.LogAgreementEvent2(
+				w.db,
+				persistence.SEVERITY_WARN,
+				fmt.Sprintf("Node rejected the proposal for service %v/%v, pre-flight check failed: %v.", worg, wls, desc),
+				persistence.EC_PREFLIGHT_CHECK_FAILED,
+				proposal.AgreementId(),
+				persistence.WorkloadInfo{URL: wls, Org: worg, Version: wversion, Arch: warch},
+				ConvertToServiceSpecs(tcPolicy.APISpecs),
+				proposal.ConsumerId(),
+				proposal.Protocol())
+
+			// Tell the agbot why, so that it does not keep proposing the same workload to this node.
+			reply := abstractprotocol.NewProposalReply(ph.Name(), proposal.Version(), proposal.AgreementId(), w.ec.GetExchangeId())
+			reply.RejectProposal(terminationCode(reason))
+			if err := abstractprotocol.SendProtocolMessage(messageTarget, reply, w.sendMessage); err != nil {
+				glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("error sending negative reply for proposal %v, %v", proposal.AgreementId(), err)))
+			}
 		} else {
 			handled = true
 			if r, err := ph.DecideOnProposal(proposal, w.ec.GetExchangeId(), exchange.GetOrg(w.ec.GetExchangeId()), runningBCs, messageTarget, w.sendMessage); err != nil {
@@ -466,6 +488,10 @@ const TERM_REASON_IMAGE_FETCH_AUTH_FAILURE = "ImageFetchAuthorizationFailure"
 const TERM_REASON_IMAGE_SIG_VERIF_FAILURE = "ImageSignatureVerificationFailure"
 const TERM_REASON_NODE_SHUTDOWN = "NodeShutdown"
 const TERM_REASON_SERVICE_SUSPENDED = "ServiceSuspended"
+const TERM_REASON_PREFLIGHT_DISK = "PreflightDisk"
+const TERM_REASON_PREFLIGHT_MEMORY = "PreflightMemory"
+const TERM_REASON_PREFLIGHT_DEVICE = "PreflightDevice"
+const TERM_REASON_IMAGE_DIGEST_MISMATCH = "ImageDigestMismatch"
 
 // ==============================================================================================================
 type ExchangeMessageCommand struct {
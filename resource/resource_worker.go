@@ -3,7 +3,6 @@ package resource
 import (
 	// "errors"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/events"
@@ -13,12 +12,12 @@ import (
 
 type ResourceWorker struct {
 	worker.BaseWorker // embedded field
-	db                *bolt.DB
+	db                persistence.EdgeDatabase
 	rm                *ResourceManager
 	am                *AuthenticationManager
 }
 
-func NewResourceWorker(name string, config *config.HorizonConfig, db *bolt.DB, am *AuthenticationManager) *ResourceWorker {
+func NewResourceWorker(name string, config *config.HorizonConfig, db persistence.EdgeDatabase, am *AuthenticationManager) *ResourceWorker {
 
 	var ec *worker.BaseExchangeContext
 	dev, _ := persistence.FindExchangeDevice(db)
@@ -51,13 +50,13 @@ func (w *ResourceWorker) NewEvent(incoming events.Message) {
 	case *events.EdgeRegisteredExchangeMessage:
 		msg, _ := incoming.(*events.EdgeRegisteredExchangeMessage)
 		w.EC = worker.NewExchangeContext(fmt.Sprintf("%v/%v", msg.Org(), msg.DeviceId()), msg.Token(), w.Config.Edge.ExchangeURL, w.Config.Collaborators.HTTPClientFactory)
-		w.Commands <- NewNodeConfigCommand(msg)
+		w.Commands <- w.TraceCommand(incoming, NewNodeConfigCommand(msg))
 
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
 		case events.UNCONFIGURE_COMPLETE:
-			w.Commands <- NewNodeUnconfigCommand(msg)
+			w.Commands <- w.TraceCommand(incoming, NewNodeUnconfigCommand(msg))
 		}
 
 	default: //nothing
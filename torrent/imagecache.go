@@ -0,0 +1,530 @@
+package torrent
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/cutil"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Nodes that can't reach a registry load their images from a local cache of image archives. An archive is a tar
+// file, which may be gzipped, made by docker save, or an OCI image layout, either as a tar file or as a directory.
+// The cache is indexed from the metadata in each archive: the names of the images, the digest of each image config,
+// which docker uses as the image id, and for an OCI layout the digest of each image manifest, which is what an image
+// that is pinned by digest refers to. The metadata is hashed as it is read, so an archive whose contents don't match
+// its digests is not used. An image that is pinned by digest in the signed deployment is only loaded from an archive
+// that has that digest, and after the load docker must have the image that is in the archive. A docker save archive
+// has no manifest digest, so it only satisfies an image that is pinned by its image id. Docker does not record the
+// manifest digest of a loaded image, so a pinned image from the cache is found, and its containers are run, by its
+// image id. The images that the cache doesn't have are pulled from their registries.
+
+// Files in an archive that are larger than this are layers, they are skipped when the archive is indexed.
+const maxImageMetadataSize = 4 * 1024 * 1024
+
+const ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+const dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+
+// An image in an archive in the local image cache.
+type CachedImage struct {
+	File            string   `json:"file"`                       // the archive the image is in
+	RepoTags        []string `json:"repo_tags"`                  // the names that docker gives the image when it is loaded
+	ImageId         string   `json:"image_id"`                   // the digest of the image config, which docker uses as the image id
+	ManifestDigests []string `json:"manifest_digests,omitempty"` // the digests of the image's OCI manifests
+}
+
+func (c CachedImage) String() string {
+	return fmt.Sprintf("File: %v, RepoTags: %v, ImageId: %v, ManifestDigests: %v", c.File, c.RepoTags, c.ImageId, c.ManifestDigests)
+}
+
+// The error for a file that is not an image archive, or whose contents don't match its digests.
+type ImageArchiveError struct {
+	Msg string
+}
+
+func (e ImageArchiveError) Error() string {
+	return e.Msg
+}
+
+// The images in an archive, remembered until the archive changes. Reading an archive means reading all of its layers.
+type archiveIndex struct {
+	modTime time.Time
+	size    int64
+	images  []CachedImage
+}
+
+var imageCacheLock sync.Mutex
+var imageCacheIndex = make(map[string]*archiveIndex)
+
+// Returns the images in all of the archives in the cache directory. Hidden files are imports that are in progress.
+func listImageCache(dir string) ([]CachedImage, error) {
+
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read image cache %v, error %v", dir, err))
+	}
+
+	imageCacheLock.Lock()
+	defer imageCacheLock.Unlock()
+
+	images := make([]CachedImage, 0)
+	found := make(map[string]bool)
+	for _, fi := range entries {
+		if strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		file := path.Join(dir, fi.Name())
+		found[file] = true
+
+		if idx, ok := imageCacheIndex[file]; ok && idx.modTime.Equal(fi.ModTime()) && idx.size == fi.Size() {
+			images = append(images, idx.images...)
+			continue
+		}
+
+		archived, err := ReadImageArchive(file)
+		if err != nil {
+			glog.Warningf("Image cache skipping %v: %v", file, err)
+		} else {
+			glog.V(3).Infof("Image cache indexed %v: %v", file, archived)
+		}
+		imageCacheIndex[file] = &archiveIndex{modTime: fi.ModTime(), size: fi.Size(), images: archived}
+		images = append(images, archived...)
+	}
+
+	for file := range imageCacheIndex {
+		if path.Dir(file) == dir && !found[file] {
+			delete(imageCacheIndex, file)
+		}
+	}
+	return images, nil
+}
+
+// Returns the images in a docker save archive or an OCI image layout, after checking the metadata against its digests.
+func ReadImageArchive(file string) ([]CachedImage, error) {
+
+	metadata := make(map[string][]byte)
+	err := walkImageArchive(file, func(name string, size int64, r io.Reader) error {
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+		if size > maxImageMetadataSize || !(strings.HasPrefix(name, "blobs/") || (path.Ext(name) == ".json" && !strings.Contains(name, "/"))) {
+			return nil
+		}
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		metadata[name] = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]CachedImage, 0)
+	add := func(imageId string, repoTags []string, manifestDigest string) {
+		for i := range images {
+			if images[i].ImageId == imageId {
+				images[i].RepoTags = appendMissing(images[i].RepoTags, repoTags...)
+				if manifestDigest != "" {
+					images[i].ManifestDigests = appendMissing(images[i].ManifestDigests, manifestDigest)
+				}
+				return
+			}
+		}
+		image := CachedImage{File: file, RepoTags: appendMissing(nil, repoTags...), ImageId: imageId}
+		if manifestDigest != "" {
+			image.ManifestDigests = []string{manifestDigest}
+		}
+		images = append(images, image)
+	}
+
+	// A docker save archive lists its images in manifest.json. The config of each image is named by its digest.
+	if content, ok := metadata["manifest.json"]; ok {
+		var manifests []struct {
+			Config   string
+			RepoTags []string
+		}
+		if err := json.Unmarshal(content, &manifests); err != nil {
+			return nil, ImageArchiveError{fmt.Sprintf("unable to demarshal manifest.json in %v, error %v", file, err)}
+		}
+		for _, m := range manifests {
+			config, ok := metadata[path.Clean(m.Config)]
+			if !ok {
+				return nil, ImageArchiveError{fmt.Sprintf("image config %v is missing from %v", m.Config, file)}
+			}
+			imageId := sha256Digest(config)
+			if name := strings.TrimSuffix(path.Base(m.Config), ".json"); len(name) == 64 && "sha256:"+name != imageId {
+				return nil, ImageArchiveError{fmt.Sprintf("image config %v in %v has digest %v", m.Config, file, imageId)}
+			}
+			add(imageId, m.RepoTags, "")
+		}
+	}
+
+	// An OCI image layout lists its images in index.json, and every blob is named by its digest.
+	if content, ok := metadata["index.json"]; ok {
+		var index struct {
+			Manifests []struct {
+				MediaType   string            `json:"mediaType"`
+				Digest      string            `json:"digest"`
+				Annotations map[string]string `json:"annotations"`
+			} `json:"manifests"`
+		}
+		if err := json.Unmarshal(content, &index); err != nil {
+			return nil, ImageArchiveError{fmt.Sprintf("unable to demarshal index.json in %v, error %v", file, err)}
+		}
+		for _, desc := range index.Manifests {
+			if desc.MediaType != ociManifestMediaType && desc.MediaType != dockerManifestMediaType {
+				glog.V(3).Infof("Image cache skipping manifest %v of type %v in %v", desc.Digest, desc.MediaType, file)
+				continue
+			}
+
+			var manifest struct {
+				Config struct {
+					Digest string `json:"digest"`
+				} `json:"config"`
+			}
+			if content, err := ociBlob(metadata, desc.Digest); err != nil {
+				return nil, ImageArchiveError{fmt.Sprintf("manifest in %v: %v", file, err)}
+			} else if err := json.Unmarshal(content, &manifest); err != nil {
+				return nil, ImageArchiveError{fmt.Sprintf("unable to demarshal manifest %v in %v, error %v", desc.Digest, file, err)}
+			} else if _, err := ociBlob(metadata, manifest.Config.Digest); err != nil {
+				return nil, ImageArchiveError{fmt.Sprintf("image config in %v: %v", file, err)}
+			}
+
+			// The full image name is only in the containerd annotation, the OCI ref name is usually just the tag.
+			repoTags := make([]string, 0, 1)
+			if name := desc.Annotations["io.containerd.image.name"]; name != "" {
+				repoTags = append(repoTags, name)
+			} else if name := desc.Annotations["org.opencontainers.image.ref.name"]; strings.ContainsAny(name, "/:") {
+				repoTags = append(repoTags, name)
+			}
+			add(manifest.Config.Digest, repoTags, desc.Digest)
+		}
+	}
+
+	if len(images) == 0 {
+		return nil, ImageArchiveError{fmt.Sprintf("%v is not a docker save archive or an OCI image layout with images in it", file)}
+	}
+	return images, nil
+}
+
+// Returns the blob with the digest, after checking that the blob has that digest.
+func ociBlob(metadata map[string][]byte, digest string) ([]byte, error) {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return nil, errors.New(fmt.Sprintf("unsupported digest %v", digest))
+	} else if content, ok := metadata["blobs/sha256/"+strings.TrimPrefix(digest, "sha256:")]; !ok {
+		return nil, errors.New(fmt.Sprintf("blob %v is missing", digest))
+	} else if d := sha256Digest(content); d != digest {
+		return nil, errors.New(fmt.Sprintf("blob %v has digest %v", digest, d))
+	} else {
+		return content, nil
+	}
+}
+
+func sha256Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func appendMissing(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, l := range list {
+			found = found || l == v
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// Call fn for each regular file in an archive. An archive is a directory, a tar file or a gzipped tar file.
+func walkImageArchive(file string, fn func(name string, size int64, r io.Reader) error) error {
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		return err
+	} else if fi.IsDir() {
+		return filepath.Walk(file, func(p string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(file, p)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return fn(filepath.ToSlash(rel), info.Size(), f)
+		})
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return ImageArchiveError{fmt.Sprintf("unable to read %v, error %v", file, err)}
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return ImageArchiveError{fmt.Sprintf("unable to read %v as a tar file, error %v", file, err)}
+		} else if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		} else if err := fn(hdr.Name, hdr.Size, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// The docker API used to load the cached images.
+type imageLoader interface {
+	InspectImage(name string) (*docker.Image, error)
+	LoadImage(opts docker.LoadImageOptions) error
+}
+
+// Load the images in an archive into docker. A directory is sent to docker as a tar stream.
+func loadImageArchive(client imageLoader, file string) error {
+
+	var input io.ReadCloser
+	if fi, err := os.Stat(file); err != nil {
+		return err
+	} else if !fi.IsDir() {
+		if input, err = os.Open(file); err != nil {
+			return err
+		}
+	} else {
+		pr, pw := io.Pipe()
+		go func() {
+			tw := tar.NewWriter(pw)
+			err := filepath.Walk(file, func(p string, info os.FileInfo, err error) error {
+				if err != nil || !info.Mode().IsRegular() {
+					return err
+				}
+				rel, err := filepath.Rel(file, p)
+				if err != nil {
+					return err
+				}
+				hdr, err := tar.FileInfoHeader(info, "")
+				if err != nil {
+					return err
+				}
+				hdr.Name = filepath.ToSlash(rel)
+				if err := tw.WriteHeader(hdr); err != nil {
+					return err
+				}
+				f, err := os.Open(p)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				_, err = io.Copy(tw, f)
+				return err
+			})
+			if err == nil {
+				err = tw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		input = pr
+	}
+	defer input.Close()
+
+	glog.V(3).Infof("Doing docker load of image archive: %v", file)
+	if err := client.LoadImage(docker.LoadImageOptions{InputStream: input}); err != nil {
+		return errors.New(fmt.Sprintf("unable to load image archive %v, error %v", file, err))
+	}
+	return nil
+}
+
+// The name and tag, or the digest, that identify an image reference, without docker hub's default names.
+func imageRefKey(ref string) (string, string, string) {
+	domain, p, tag, digest := cutil.ParseDockerImagePath(ref)
+	if domain == "docker.io" || domain == "index.docker.io" {
+		domain = ""
+	}
+	if domain == "" {
+		p = strings.TrimPrefix(p, "library/")
+	} else {
+		p = domain + "/" + p
+	}
+	if tag == "" && digest == "" {
+		tag = "latest"
+	}
+	return p, tag, digest
+}
+
+// Find the cached image for an image in a deployment. An image that is pinned by digest must have that digest, any
+// other image is found by its name and tag.
+func findCachedImage(images []CachedImage, image string) *CachedImage {
+	name, tag, digest := imageRefKey(image)
+	for i, cached := range images {
+		if digest != "" {
+			if cached.ImageId == digest {
+				return &images[i]
+			}
+			for _, d := range cached.ManifestDigests {
+				if d == digest {
+					return &images[i]
+				}
+			}
+			continue
+		}
+		for _, repoTag := range cached.RepoTags {
+			if n, t, _ := imageRefKey(repoTag); n == name && t == tag {
+				return &images[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Load the images in the deployment that the cache has, and return the deployment of the services whose images still
+// have to be pulled.
+func loadFromImageCache(client *docker.Client, dir string, deploymentDesc *containermessage.DeploymentDescription) (*containermessage.DeploymentDescription, error) {
+
+	images, err := listImageCache(dir)
+	if err != nil {
+		return deploymentDesc, err
+	}
+
+	remaining := *deploymentDesc
+	remaining.Services = make(map[string]*containermessage.Service)
+	loaded := make(map[string]bool)
+	for name, service := range deploymentDesc.Services {
+		if cached := findCachedImage(images, service.Image); cached == nil {
+			remaining.Services[name] = service
+		} else if err := loadCachedImage(client, cached, service.Image, loaded); err != nil {
+			glog.Warningf("Unable to use the image cache for image %v of service %v, it will be pulled: %v", service.Image, name, err)
+			remaining.Services[name] = service
+		} else {
+			glog.V(3).Infof("Using image %v for service %v from image cache archive %v", service.Image, name, cached.File)
+		}
+	}
+	return &remaining, nil
+}
+
+// Returns the id of the image in the cache that an image pinned by digest refers to, or an empty string when the image
+// is not pinned or the cache does not have it. The digest was checked against the archive when the cache was indexed.
+func CachedImageId(dir string, image string) string {
+	if _, _, digest := imageRefKey(image); digest == "" || dir == "" {
+		return ""
+	} else if images, err := listImageCache(dir); err != nil {
+		glog.Warningf("Unable to use image cache %v: %v", dir, err)
+		return ""
+	} else if cached := findCachedImage(images, image); cached != nil {
+		return cached.ImageId
+	}
+	return ""
+}
+
+// Make sure that docker has the cached image under the name in the deployment, or under its image id when the image is
+// pinned by digest. The archive is not loaded when docker already has the image, and it is loaded only once for all the
+// images in it.
+func loadCachedImage(client imageLoader, cached *CachedImage, image string, loaded map[string]bool) error {
+
+	ref := image
+	if _, _, digest := imageRefKey(image); digest != "" {
+		ref = cached.ImageId
+	}
+
+	if img, err := client.InspectImage(ref); err == nil && img.ID == cached.ImageId {
+		return nil
+	}
+
+	if !loaded[cached.File] {
+		if err := loadImageArchive(client, cached.File); err != nil {
+			return err
+		}
+		loaded[cached.File] = true
+	}
+
+	if img, err := client.InspectImage(ref); err != nil {
+		return errors.New(fmt.Sprintf("docker does not know image %v after loading %v, error %v", ref, cached.File, err))
+	} else if img.ID != cached.ImageId {
+		return errors.New(fmt.Sprintf("image %v is %v after loading %v, expected %v", ref, img.ID, cached.File, cached.ImageId))
+	}
+	return nil
+}
+
+// Add an image archive to the cache and load its images into docker. The archive is written to a hidden file until
+// it has been checked, so that a partial archive is never used.
+func ImportImageArchive(client *docker.Client, dir string, archive io.Reader) ([]CachedImage, error) {
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create image cache %v, error %v", dir, err))
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".import-")
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create file in image cache %v, error %v", dir, err))
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), archive)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, errors.New(fmt.Sprintf("unable to write image archive to %v, error %v", tmp.Name(), err))
+	}
+
+	images, err := ReadImageArchive(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	// The archive is named by its digest, so importing it again replaces it.
+	file := path.Join(dir, hex.EncodeToString(hash.Sum(nil))[:24]+".tar")
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		os.Remove(tmp.Name())
+		return nil, errors.New(fmt.Sprintf("unable to move image archive to %v, error %v", file, err))
+	}
+	for i := range images {
+		images[i].File = file
+	}
+
+	if err := loadImageArchive(client, file); err != nil {
+		return images, err
+	}
+	for _, image := range images {
+		if _, err := client.InspectImage(image.ImageId); err != nil {
+			return images, errors.New(fmt.Sprintf("docker does not have image %v after loading %v, error %v", image.ImageId, file, err))
+		}
+	}
+	return images, nil
+}
@@ -0,0 +1,244 @@
+// +build unit
+
+package torrent
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Write the files to a tar file, gzipped when the name ends in .gz.
+func writeTestArchive(t *testing.T, file string, files map[string]string) {
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatalf("unable to create %v: %v", file, err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if strings.HasSuffix(file, ".gz") {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("unable to write %v: %v", name, err)
+		} else if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("unable to write %v: %v", name, err)
+		}
+	}
+}
+
+// The files of an OCI layout with one image, and the image id and manifest digest.
+func testOCILayout(name string) (map[string]string, string, string) {
+	config := `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`
+	configDigest := sha256Digest([]byte(config))
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":"%v"},"layers":[]}`, configDigest)
+	manifestDigest := sha256Digest([]byte(manifest))
+	index := fmt.Sprintf(`{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"%v","annotations":{"io.containerd.image.name":"%v","org.opencontainers.image.ref.name":"1.0.0"}}]}`, manifestDigest, name)
+
+	return map[string]string{
+		"oci-layout": `{"imageLayoutVersion":"1.0.0"}`,
+		"index.json": index,
+		"blobs/sha256/" + strings.TrimPrefix(configDigest, "sha256:"):   config,
+		"blobs/sha256/" + strings.TrimPrefix(manifestDigest, "sha256:"): manifest,
+	}, configDigest, manifestDigest
+}
+
+func Test_ReadImageArchive(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "imagecache-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A docker save archive.
+	config := `{"architecture":"arm64","os":"linux"}`
+	imageId := sha256Digest([]byte(config))
+	configName := strings.TrimPrefix(imageId, "sha256:") + ".json"
+	saved := path.Join(dir, "gps.tar.gz")
+	writeTestArchive(t, saved, map[string]string{
+		"manifest.json":    fmt.Sprintf(`[{"Config":"%v","RepoTags":["myorg/gps:1.0.0","myorg/gps:latest"],"Layers":["abc/layer.tar"]}]`, configName),
+		configName:         config,
+		"abc/layer.tar":    "layer",
+		"abc/json":         "{}",
+		"repositories":     "{}",
+		"./ignored/x.json": "{}",
+	})
+
+	images, err := ReadImageArchive(saved)
+	assert.Nil(t, err, "a docker save archive should be read")
+	assert.Equal(t, []CachedImage{{File: saved, RepoTags: []string{"myorg/gps:1.0.0", "myorg/gps:latest"}, ImageId: imageId}}, images)
+
+	// An OCI layout, as a tar file and as a directory.
+	files, configDigest, manifestDigest := testOCILayout("docker.io/myorg/camera:1.0.0")
+	oci := path.Join(dir, "camera.tar")
+	writeTestArchive(t, oci, files)
+	ociDir := path.Join(dir, "camera")
+	for name, content := range files {
+		os.MkdirAll(path.Dir(path.Join(ociDir, name)), 0700)
+		ioutil.WriteFile(path.Join(ociDir, name), []byte(content), 0600)
+	}
+
+	for _, archive := range []string{oci, ociDir} {
+		images, err = ReadImageArchive(archive)
+		assert.Nil(t, err, "an OCI layout should be read")
+		assert.Equal(t, []CachedImage{{File: archive, RepoTags: []string{"docker.io/myorg/camera:1.0.0"}, ImageId: configDigest, ManifestDigests: []string{manifestDigest}}}, images)
+	}
+
+	// An archive whose contents don't match their digests is rejected.
+	configBlob := "blobs/sha256/" + strings.TrimPrefix(configDigest, "sha256:")
+	files[configBlob] = strings.Replace(files[configBlob], "amd64", "s390x", 1)
+	writeTestArchive(t, oci, files)
+	_, err = ReadImageArchive(oci)
+	assert.IsType(t, ImageArchiveError{}, err, "a changed image config should be rejected")
+
+	notArchive := path.Join(dir, "README")
+	ioutil.WriteFile(notArchive, []byte("not an archive"), 0600)
+	_, err = ReadImageArchive(notArchive)
+	assert.IsType(t, ImageArchiveError{}, err, "a file that is not an archive should be rejected")
+}
+
+func Test_findCachedImage(t *testing.T) {
+
+	images := []CachedImage{
+		{File: "gps.tar", RepoTags: []string{"myorg/gps:1.0.0"}, ImageId: "sha256:1111"},
+		{File: "camera.tar", RepoTags: []string{"docker.io/library/camera:latest"}, ImageId: "sha256:2222", ManifestDigests: []string{"sha256:3333"}},
+		{File: "cpu.tar", RepoTags: []string{"registry.example.com:5000/myorg/cpu:2.0"}, ImageId: "sha256:4444"},
+	}
+
+	for image, file := range map[string]string{
+		"myorg/gps:1.0.0":                         "gps.tar",
+		"docker.io/myorg/gps:1.0.0":               "gps.tar",
+		"myorg/gps:1.0.1":                         "",
+		"camera":                                  "camera.tar",
+		"library/camera:latest":                   "camera.tar",
+		"camera@sha256:3333":                      "camera.tar",
+		"myorg/gps:1.0.0@sha256:1111":             "gps.tar",
+		"myorg/gps:1.0.0@sha256:9999":             "",
+		"registry.example.com:5000/myorg/cpu:2.0": "cpu.tar",
+		"myorg/cpu:2.0":                           "",
+	} {
+		if cached := findCachedImage(images, image); file == "" {
+			assert.Nil(t, cached, fmt.Sprintf("no cached image should be found for %v", image))
+		} else if assert.NotNil(t, cached, fmt.Sprintf("a cached image should be found for %v", image)) {
+			assert.Equal(t, file, cached.File, fmt.Sprintf("the wrong cached image was found for %v", image))
+		}
+	}
+}
+
+func Test_listImageCache(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "imagecache-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	images, err := listImageCache(path.Join(dir, "missing"))
+	assert.Nil(t, err, "a missing cache is empty")
+	assert.Empty(t, images)
+
+	files, configDigest, _ := testOCILayout("myorg/camera:1.0.0")
+	writeTestArchive(t, path.Join(dir, "camera.tar"), files)
+	writeTestArchive(t, path.Join(dir, ".import-123"), files)
+	ioutil.WriteFile(path.Join(dir, "README"), []byte("not an archive"), 0600)
+
+	images, err = listImageCache(dir)
+	assert.Nil(t, err)
+	if assert.Len(t, images, 1, "only the archive should be in the cache") {
+		assert.Equal(t, configDigest, images[0].ImageId)
+	}
+
+	os.Remove(path.Join(dir, "camera.tar"))
+	images, err = listImageCache(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, images, "a removed archive should be removed from the cache")
+	assert.NotContains(t, imageCacheIndex, path.Join(dir, "camera.tar"))
+}
+
+// A docker that loads the images in archives like the classic docker image store does: a loaded image gets its repo
+// tags, but no repo digests.
+type fakeImageLoader struct {
+	images map[string]*docker.Image // by name and by id
+	loads  int
+	loadFn func() []CachedImage // the images that docker finds in the archive
+}
+
+func (f *fakeImageLoader) InspectImage(name string) (*docker.Image, error) {
+	if image, ok := f.images[name]; ok {
+		return image, nil
+	}
+	return nil, docker.ErrNoSuchImage
+}
+
+func (f *fakeImageLoader) LoadImage(opts docker.LoadImageOptions) error {
+	ioutil.ReadAll(opts.InputStream)
+	f.loads++
+	for _, cached := range f.loadFn() {
+		image := &docker.Image{ID: cached.ImageId}
+		f.images[cached.ImageId] = image
+		for _, repoTag := range cached.RepoTags {
+			f.images[repoTag] = image
+		}
+	}
+	return nil
+}
+
+func Test_loadCachedImage(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "imagecache-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files, configDigest, manifestDigest := testOCILayout("myorg/camera:1.0.0")
+	archive := path.Join(dir, "camera.tar")
+	writeTestArchive(t, archive, files)
+	images, err := ReadImageArchive(archive)
+	if err != nil {
+		t.Fatalf("unable to read %v: %v", archive, err)
+	}
+
+	client := &fakeImageLoader{images: make(map[string]*docker.Image), loadFn: func() []CachedImage { return images }}
+
+	// An image pinned by its manifest digest is never known to docker by that digest, it is found by its image id.
+	pinned := "myorg/camera@" + manifestDigest
+	loaded := make(map[string]bool)
+	assert.Nil(t, loadCachedImage(client, &images[0], pinned, loaded), "a pinned image should be loaded from the cache")
+	assert.Equal(t, 1, client.loads)
+	assert.Equal(t, configDigest, CachedImageId(dir, pinned), "the cache should have the image id of the pinned image")
+
+	// Once docker has the image, the archive is not loaded again.
+	assert.Nil(t, loadCachedImage(client, &images[0], pinned, make(map[string]bool)))
+	assert.Nil(t, loadCachedImage(client, &images[0], "myorg/camera:1.0.0", make(map[string]bool)))
+	assert.Equal(t, 1, client.loads, "docker already has the image")
+
+	// An archive that does not give docker the image is an error, and it is only loaded once.
+	client = &fakeImageLoader{images: make(map[string]*docker.Image), loadFn: func() []CachedImage {
+		return []CachedImage{{RepoTags: []string{"myorg/camera:1.0.0"}, ImageId: "sha256:other"}}
+	}}
+	loaded = make(map[string]bool)
+	assert.NotNil(t, loadCachedImage(client, &images[0], pinned, loaded), "a pinned image should have its image id")
+	assert.NotNil(t, loadCachedImage(client, &images[0], "myorg/camera:1.0.0", loaded), "an image should have its image id")
+	assert.Equal(t, 1, client.loads, "the archive should only be loaded once")
+
+	// Images that are not pinned, or that the cache does not have, have no cached image id.
+	assert.Equal(t, "", CachedImageId(dir, "myorg/camera:1.0.0"))
+	assert.Equal(t, "", CachedImageId(dir, "myorg/camera@sha256:9999"))
+	assert.Equal(t, "", CachedImageId("", pinned))
+}
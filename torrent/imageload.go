@@ -25,6 +25,9 @@ func listImages(client *dockerclient.Client) ([]dockerclient.APIImages, error) {
 func SkipCheckFn(client *dockerclient.Client) func(repotag string) (bool, error) {
 
 	return func(repotag string) (bool, error) {
+		if client == nil {
+			return false, nil
+		}
 		repotagParts := strings.Split(repotag, ":")
 
 		if images, err := listImages(client); err != nil {
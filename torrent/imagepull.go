@@ -9,6 +9,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
 	"github.com/open-horizon/anax/cutil"
 	"os"
 	"time"
@@ -66,7 +67,7 @@ func authDockerFile(config config.Config, authConfigs map[string][]docker.AuthCo
 	return nil
 }
 
-func pullImageFromRepos(config config.Config, authConfigs map[string][]docker.AuthConfiguration, client *docker.Client, skipPartFetchFn *func(repotag string) (bool, error), deploymentDesc *containermessage.DeploymentDescription) error {
+func pullImageFromRepos(config config.Config, authConfigs map[string][]docker.AuthConfiguration, rt containerruntime.Runtime, skipPartFetchFn *func(repotag string) (bool, error), deploymentDesc *containermessage.DeploymentDescription) error {
 
 	// append docker auth from docker file
 	authDockerFile(config, authConfigs)
@@ -119,12 +120,12 @@ func pullImageFromRepos(config config.Config, authConfigs map[string][]docker.Au
 
 		var err error
 		if domain == "" {
-			err = pullSingleImageFromRepo(client, opts, docker.AuthConfiguration{})
+			err = pullSingleImageFromRepo(rt, opts, docker.AuthConfiguration{})
 		} else if auth_array, ok := authConfigs[domain]; !ok {
-			err = pullSingleImageFromRepo(client, opts, docker.AuthConfiguration{})
+			err = pullSingleImageFromRepo(rt, opts, docker.AuthConfiguration{})
 		} else {
 			for i, auth := range auth_array {
-				err = pullSingleImageFromRepo(client, opts, auth)
+				err = pullSingleImageFromRepo(rt, opts, auth)
 				if err == nil {
 					break
 				} else if i < len(auth_array)-1 {
@@ -144,13 +145,13 @@ func pullImageFromRepos(config config.Config, authConfigs map[string][]docker.Au
 }
 
 //  This function try maxPullAttempts times to pull the image from the repo. It exits out imediately if there is auth error.
-func pullSingleImageFromRepo(client *docker.Client, opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
+func pullSingleImageFromRepo(rt containerruntime.Runtime, opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
 	glog.V(5).Infof("Pulling image %v with auth name %v.", opts, auth.Username)
 
 	var pullAttempts int
 
 	for pullAttempts <= maxPullAttempts {
-		if err := client.PullImage(opts, auth); err == nil {
+		if err := rt.PullImage(opts, auth); err == nil {
 			return nil
 		} else {
 			pullAttempts++
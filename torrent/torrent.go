@@ -5,11 +5,11 @@ import (
 	"net/url"
 
 	"encoding/json"
-	"github.com/boltdb/bolt"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/worker"
@@ -21,22 +21,22 @@ import (
 
 type TorrentWorker struct {
 	worker.BaseWorker // embedded field
-	db                *bolt.DB
-	client            *docker.Client
+	db                persistence.EdgeDatabase
+	runtime           containerruntime.Runtime
 }
 
-func NewTorrentWorker(name string, config *config.HorizonConfig, db *bolt.DB) *TorrentWorker {
+func NewTorrentWorker(name string, config *config.HorizonConfig, db persistence.EdgeDatabase) *TorrentWorker {
 
-	cl, err := docker.NewClient(config.Edge.DockerEndpoint)
+	rt, err := containerruntime.NewRuntime(config)
 	if err != nil {
-		glog.Errorf("Failed to instantiate docker Client: %v", err)
-		panic("Unable to instantiate docker Client")
+		glog.Errorf("Failed to instantiate %v container runtime: %v", config.GetContainerRuntime(), err)
+		panic("Unable to instantiate container runtime")
 	}
 
 	worker := &TorrentWorker{
 		BaseWorker: worker.NewBaseWorker(name, config, nil),
 		db:         db,
-		client:     cl,
+		runtime:    rt,
 	}
 
 	worker.Start(worker, 0)
@@ -54,19 +54,19 @@ func (w *TorrentWorker) NewEvent(incoming events.Message) {
 		msg, _ := incoming.(*events.AgreementReachedMessage)
 
 		fCmd := w.NewFetchCommand(msg.LaunchContext())
-		w.Commands <- fCmd
+		w.Commands <- w.TraceCommand(incoming, fCmd)
 
 	case *events.LoadContainerMessage:
 		msg, _ := incoming.(*events.LoadContainerMessage)
 
 		fCmd := w.NewFetchCommand(msg.LaunchContext())
-		w.Commands <- fCmd
+		w.Commands <- w.TraceCommand(incoming, fCmd)
 
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
 		case events.UNCONFIGURE_COMPLETE:
-			w.Commands <- worker.NewTerminateCommand("shutdown")
+			w.Commands <- w.TraceCommand(incoming, worker.NewTerminateCommand("shutdown"))
 		}
 
 	default: //nothing
@@ -137,7 +137,7 @@ func AppendDockerAuth(dockerAuths map[string][]docker.AuthConfiguration, auth do
 }
 
 // append the auth attribute to the given auth maps
-func authAttributes(db *bolt.DB, httpAuthAttrs map[string]map[string]string, dockerAuthConfigurations map[string][]docker.AuthConfiguration) error {
+func authAttributes(db persistence.EdgeDatabase, httpAuthAttrs map[string]map[string]string, dockerAuthConfigurations map[string][]docker.AuthConfiguration) error {
 
 	// assemble credentials from attributes
 	attributes, err := persistence.FindApplicableAttributes(db, "", "")
@@ -172,7 +172,7 @@ func authExchange(imageAuths []events.ImageDockerAuth, dockerAuthConfigurations
 
 // Copy the given http auth to a new map and then add the default http auth (org/device_id:device_token) to the new map.
 // The given httpAuthAttrs is unchanged.
-func addDefaultHttpAuth(db *bolt.DB, pkgUrl string, httpAuthAttrs map[string]map[string]string) (map[string]map[string]string, error) {
+func addDefaultHttpAuth(db persistence.EdgeDatabase, pkgUrl string, httpAuthAttrs map[string]map[string]string) (map[string]map[string]string, error) {
 	// copy the given map to a new map
 	new_auth := make(map[string]map[string]string, 0)
 	for k, v := range httpAuthAttrs {
@@ -235,7 +235,7 @@ func processDeployment(cfg *config.HorizonConfig, containerConfig events.Contain
 	return pemFiles, &deploymentDesc, nil
 }
 
-func processFetch(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, pemFiles []string, deploymentDesc *containermessage.DeploymentDescription, torrentUrl url.URL, torrentSig string, imageDockerAuths []events.ImageDockerAuth) error {
+func processFetch(cfg *config.HorizonConfig, rt containerruntime.Runtime, db persistence.EdgeDatabase, pemFiles []string, deploymentDesc *containermessage.DeploymentDescription, torrentUrl url.URL, torrentSig string, imageDockerAuths []events.ImageDockerAuth) error {
 	httpAuthAttrs := make(map[string]map[string]string, 0)
 	dockerAuthConfigurations := make(map[string][]docker.AuthConfiguration, 0)
 
@@ -251,20 +251,37 @@ func processFetch(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB,
 		glog.Errorf("Failed to fetch authentication facts from the attributes before processing packages and / or Docker pulls: %v. Continuing anyway", err)
 	}
 
-	return fetchImage(cfg, client, db, pemFiles, deploymentDesc, torrentUrl, torrentSig, httpAuthAttrs, dockerAuthConfigurations)
+	return fetchImage(cfg, rt, db, pemFiles, deploymentDesc, torrentUrl, torrentSig, httpAuthAttrs, dockerAuthConfigurations)
 }
 
-func fetchImage(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, pemFiles []string, deploymentDesc *containermessage.DeploymentDescription, torrentUrl url.URL, torrentSig string, httpAuthAttrs map[string]map[string]string, dockerAuthConfigurations map[string][]docker.AuthConfiguration) error {
+func fetchImage(cfg *config.HorizonConfig, rt containerruntime.Runtime, db persistence.EdgeDatabase, pemFiles []string, deploymentDesc *containermessage.DeploymentDescription, torrentUrl url.URL, torrentSig string, httpAuthAttrs map[string]map[string]string, dockerAuthConfigurations map[string][]docker.AuthConfiguration) error {
 	// N.B. Using fetcherrors types even for docker pull errors
 	var fetchErr error
 
+	// Images are loaded from archives with the docker API, which a runtime might not have.
+	client := containerruntime.DockerClient(rt)
+
+	// The local image cache is preferred over the registries and the image server.
+	if dir := cfg.GetImageCacheDir(); dir != "" && client != nil {
+		if remaining, err := loadFromImageCache(client, dir, deploymentDesc); err != nil {
+			glog.Warningf("Unable to use image cache %v: %v", dir, err)
+		} else if len(remaining.Services) == 0 {
+			return nil
+		} else {
+			deploymentDesc = remaining
+		}
+	}
+
 	skipCheckFn := SkipCheckFn(client)
 	if torrentUrl.String() == "" && torrentSig == "" {
 		// using Docker pull (newer option, uses docker client to pull images from repos in image names in deployment description)
 		// Note: we don't want to make this a fallback option, it's a potential security vector
 		glog.V(3).Infof("Empty torrent URL '%v' and Signature '%v' provided in LaunchContext, using Docker pull mechanism to retrieve and load Docker images into local registry", torrentUrl.String(), torrentSig)
 
-		fetchErr = pullImageFromRepos(cfg.Edge, dockerAuthConfigurations, client, &skipCheckFn, deploymentDesc)
+		fetchErr = pullImageFromRepos(cfg.Edge, dockerAuthConfigurations, rt, &skipCheckFn, deploymentDesc)
+
+	} else if client == nil {
+		fetchErr = fmt.Errorf("Image packages from %v can not be loaded by the %v container runtime", torrentUrl.String(), rt.Name())
 
 	} else {
 		// using Pkg fetch and image load (traditional option, content of images is packaged completely, all content is checked for signature)
@@ -325,7 +342,7 @@ func fetchImage(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, p
 // 1) from the httpAuthAttrs
 // 2) from the env variable $HZN_ORG_ID/$HZN_DEVICE_ID:$HZN_DEVICE_TOKEN if httpAuthAttrs is not set for this image.
 // 3) try without auth if 2) fails.
-func ProcessImageFetch(cfg *config.HorizonConfig, client *docker.Client, containerConfig *events.ContainerConfig, httpAuthAttrs map[string]map[string]string, dockerAuthConfigurations map[string][]docker.AuthConfiguration, pemFiles []string) error {
+func ProcessImageFetch(cfg *config.HorizonConfig, rt containerruntime.Runtime, containerConfig *events.ContainerConfig, httpAuthAttrs map[string]map[string]string, dockerAuthConfigurations map[string][]docker.AuthConfiguration, pemFiles []string) error {
 
 	dockerAuthNew := make(map[string][]docker.AuthConfiguration, 0)
 
@@ -349,7 +366,7 @@ func ProcessImageFetch(cfg *config.HorizonConfig, client *docker.Client, contain
 		return fmt.Errorf("Error Unmarshalling deployment string %v, error: %v", containerConfig.Deployment, err)
 	}
 
-	return fetchImage(cfg, client, nil, pemFiles, &deploymentDesc, containerConfig.TorrentURL, containerConfig.TorrentSignature, httpAuthAttrs, dockerAuthNew)
+	return fetchImage(cfg, rt, nil, pemFiles, &deploymentDesc, containerConfig.TorrentURL, containerConfig.TorrentSignature, httpAuthAttrs, dockerAuthNew)
 }
 
 func (b *TorrentWorker) CommandHandler(command worker.Command) bool {
@@ -377,7 +394,7 @@ func (b *TorrentWorker) CommandHandler(command worker.Command) bool {
 				return true
 			}
 
-			if fetchErr := processFetch(b.Config, b.client, b.db, pemFiles, deploymentDesc, lc.ContainerConfig().TorrentURL, lc.ContainerConfig().TorrentSignature, lc.ContainerConfig().ImageDockerAuths); fetchErr != nil {
+			if fetchErr := processFetch(b.Config, b.runtime, b.db, pemFiles, deploymentDesc, lc.ContainerConfig().TorrentURL, lc.ContainerConfig().TorrentSignature, lc.ContainerConfig().ImageDockerAuths); fetchErr != nil {
 				var id events.EventId
 				switch fetchErr.(type) {
 				case fetcherrors.PkgMetaError, fetcherrors.PkgSourceError, fetcherrors.PkgPrecheckError:
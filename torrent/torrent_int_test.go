@@ -10,7 +10,6 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/persistence"
@@ -160,13 +159,13 @@ func tConfig(t *testing.T, dir string) *config.HorizonConfig {
 	return &cfg
 }
 
-func setup(t *testing.T) (string, *bolt.DB, error) {
+func setup(t *testing.T) (string, persistence.EdgeDatabase, error) {
 	dir, err := ioutil.TempDir("", "container-")
 	if err != nil {
 		return "", nil, err
 	}
 
-	db, err := bolt.Open(path.Join(dir, "anax-int.db"), 0600, &bolt.Options{Timeout: 10 * time.Second})
+	db, err := persistence.OpenBoltDatabase(path.Join(dir, "anax-int.db"))
 	if err != nil {
 		return dir, nil, err
 	}
@@ -227,7 +226,7 @@ func setup(t *testing.T) (string, *bolt.DB, error) {
 	return dir, db, nil
 }
 
-func tWorker(config *config.HorizonConfig, db *bolt.DB) *TorrentWorker {
+func tWorker(config *config.HorizonConfig, db persistence.EdgeDatabase) *TorrentWorker {
 	tw := NewTorrentWorker("tworker", config, db)
 	return tw
 }
@@ -0,0 +1,322 @@
+package worker
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/events"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// The event tracer is an optional (opt-in through the config file) subsystem that records how events flow
+// through the workers. Each event dispatched by ProcessEventMessages is given an id and a correlation id. Commands
+// created from the event (see BaseWorker.TraceCommand) carry the event's correlation id, and any event emitted by a worker
+// while it is handling a traced command inherits the correlation id of that command. In this way, a chain of
+// events and commands, e.g. AgreementReachedMessage through to LoadContainerMessage, can be followed across workers.
+// The most recent traces are kept in a bounded ring buffer.
+
+const DEFAULT_EVENT_TRACE_BUFFER_SIZE = 500
+
+// The record of a command that was created by a worker in response to an event.
+type CommandTrace struct {
+	Worker     string  `json:"worker"`
+	Command    string  `json:"command"`
+	Queued     int64   `json:"queued"`      // unix time in nanoseconds when the command was created
+	Started    int64   `json:"started"`     // unix time in nanoseconds when the worker started handling the command, 0 if still queued
+	Completed  int64   `json:"completed"`   // unix time in nanoseconds when the worker finished handling the command, 0 if not finished
+	DurationMs float64 `json:"duration_ms"` // the time spent handling the command
+}
+
+// The record of an event that was dispatched to the workers.
+type EventTrace struct {
+	EventId       string          `json:"event_id"`
+	CorrelationId string          `json:"correlation_id"`
+	ParentEventId string          `json:"parent_event_id,omitempty"` // the event that caused the command that emitted this event
+	Source        string          `json:"source,omitempty"`          // the worker that emitted the event, if known
+	SourceCommand string          `json:"source_command,omitempty"`  // the command being handled by the source worker when the event was emitted
+	MessageType   string          `json:"message_type"`
+	Event         string          `json:"event"`
+	Description   string          `json:"description"`
+	Time          int64           `json:"time"` // unix time in nanoseconds when the event was dispatched
+	Consumers     []string        `json:"consumers"`
+	Commands      []*CommandTrace `json:"commands"`
+	msg           events.Message  // the traced event
+	pending       []Command       // commands created from this event that have not been handled yet
+}
+
+// The trace information carried by a command while it is queued and being handled.
+type commandContext struct {
+	event   *EventTrace
+	command *CommandTrace
+	doneAt  uint64 // the tracer clock when the command was completed, zero while in progress
+}
+
+// The output of the tracer, as returned by the /status/events API.
+type EventTraceOutput struct {
+	Enabled  bool         `json:"enabled"`
+	Capacity int          `json:"capacity"`
+	Traces   []EventTrace `json:"traces"`
+}
+
+type EventTracer struct {
+	enabled  bool
+	capacity int
+	seq      uint64
+	clock    uint64                         // incremented each time a traced command is completed
+	traces   []*EventTrace                  // the ring buffer
+	next     int                            // the next slot to write in the ring buffer
+	byEvent  map[events.Message]*EventTrace // traces for events that are still in the ring buffer
+	commands map[Command]*commandContext    // commands that have been created but not yet handled
+	active   map[string]*commandContext     // the traced command that each worker is handling, or most recently handled
+	lock     sync.Mutex
+}
+
+var eventTracer = NewEventTracer()
+
+func GetEventTracer() *EventTracer {
+	return eventTracer
+}
+
+func NewEventTracer() *EventTracer {
+	return &EventTracer{
+		capacity: DEFAULT_EVENT_TRACE_BUFFER_SIZE,
+		traces:   make([]*EventTrace, 0),
+		byEvent:  make(map[events.Message]*EventTrace),
+		commands: make(map[Command]*commandContext),
+		active:   make(map[string]*commandContext),
+	}
+}
+
+// Turn tracing on or off and set the size of the ring buffer. Changing the configuration discards any existing traces.
+func (t *EventTracer) Configure(enabled bool, capacity int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if capacity <= 0 {
+		capacity = DEFAULT_EVENT_TRACE_BUFFER_SIZE
+	}
+	t.enabled = enabled
+	t.capacity = capacity
+	t.traces = make([]*EventTrace, 0, capacity)
+	t.next = 0
+	t.byEvent = make(map[events.Message]*EventTrace)
+	t.commands = make(map[Command]*commandContext)
+	t.active = make(map[string]*commandContext)
+}
+
+func (t *EventTracer) IsEnabled() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.enabled
+}
+
+// Only pointers can be safely used as map keys, which is what all the events and commands in anax are.
+func traceable(v interface{}) bool {
+	return v != nil && reflect.TypeOf(v).Kind() == reflect.Ptr
+}
+
+// Return the current value of the tracer clock. The message dispatcher calls this before it reads from a worker's
+// message queue so that it can tell whether a message was sent while the worker was still handling a command.
+func (t *EventTracer) mark() uint64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.clock
+}
+
+// Record that an event emitted by the source worker has been received by the dispatcher. Worker message queues are
+// unbuffered, so a message sent while the worker is handling a traced command is always received before the
+// command completes, i.e. before the clock moves past the mark taken just before the receive. Such an event inherits
+// the command's correlation id, otherwise the event starts a new correlation.
+func (t *EventTracer) eventReceived(source string, msg events.Message, mark uint64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.enabled || !traceable(msg) {
+		return
+	}
+
+	t.seq++
+	et := &EventTrace{
+		EventId:     fmt.Sprintf("e%v", t.seq),
+		Source:      source,
+		MessageType: commandTypeName(msg),
+		Event:       string(msg.Event().Id),
+		Description: msg.ShortString(),
+		Time:        time.Now().UnixNano(),
+		Consumers:   make([]string, 0),
+		Commands:    make([]*CommandTrace, 0),
+		msg:         msg,
+		pending:     make([]Command, 0),
+	}
+	et.CorrelationId = et.EventId
+
+	if ctx, ok := t.active[source]; ok && (ctx.doneAt == 0 || ctx.doneAt > mark) {
+		et.CorrelationId = ctx.event.CorrelationId
+		et.ParentEventId = ctx.event.EventId
+		et.SourceCommand = ctx.command.Command
+	}
+
+	// Add the trace to the ring buffer, evicting the oldest trace if the buffer is full.
+	if len(t.traces) < t.capacity {
+		t.traces = append(t.traces, et)
+	} else {
+		evicted := t.traces[t.next]
+		delete(t.byEvent, evicted.msg)
+		for _, c := range evicted.pending {
+			delete(t.commands, c)
+		}
+		t.traces[t.next] = et
+	}
+	t.next = (t.next + 1) % t.capacity
+	t.byEvent[msg] = et
+}
+
+// Record that a worker created a command from an event.
+func (t *EventTracer) commandCreated(workerName string, incoming events.Message, command Command) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.enabled || !traceable(incoming) || !traceable(command) {
+		return
+	}
+
+	et, ok := t.byEvent[incoming]
+	if !ok {
+		return
+	}
+
+	ct := &CommandTrace{
+		Worker:  workerName,
+		Command: commandTypeName(command),
+		Queued:  time.Now().UnixNano(),
+	}
+	et.Commands = append(et.Commands, ct)
+	et.pending = append(et.pending, command)
+
+	consumed := false
+	for _, c := range et.Consumers {
+		if c == workerName {
+			consumed = true
+			break
+		}
+	}
+	if !consumed {
+		et.Consumers = append(et.Consumers, workerName)
+	}
+
+	t.commands[command] = &commandContext{event: et, command: ct}
+}
+
+// Record that a worker has started to handle a command. Events emitted by the worker until the command is finished
+// will be correlated with the command.
+func (t *EventTracer) commandStarted(workerName string, command Command) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.enabled || !traceable(command) {
+		return
+	}
+
+	if ctx, ok := t.commands[command]; ok {
+		delete(t.commands, command)
+		for ix, c := range ctx.event.pending {
+			if c == command {
+				ctx.event.pending = append(ctx.event.pending[:ix], ctx.event.pending[ix+1:]...)
+				break
+			}
+		}
+		ctx.command.Started = time.Now().UnixNano()
+		t.active[workerName] = ctx
+	} else {
+		// The command was not created from a traced event, so events emitted while handling it are not correlated.
+		delete(t.active, workerName)
+	}
+}
+
+// Record that a worker has finished handling its current command.
+func (t *EventTracer) commandCompleted(workerName string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if ctx, ok := t.active[workerName]; ok && ctx.doneAt == 0 {
+		t.clock++
+		ctx.doneAt = t.clock
+		ctx.command.Completed = time.Now().UnixNano()
+		ctx.command.DurationMs = float64(ctx.command.Completed-ctx.command.Started) / float64(time.Millisecond)
+	}
+}
+
+// Filters used to select event traces.
+type EventTraceFilter func(EventTrace) bool
+
+func CorrelationIdETFilter(id string) EventTraceFilter {
+	return func(e EventTrace) bool { return e.CorrelationId == id }
+}
+
+func EventETFilter(event string) EventTraceFilter {
+	return func(e EventTrace) bool { return e.Event == event || e.MessageType == event }
+}
+
+func WorkerETFilter(name string) EventTraceFilter {
+	return func(e EventTrace) bool {
+		if e.Source == name {
+			return true
+		}
+		for _, c := range e.Consumers {
+			if c == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Return a copy of the traces in the ring buffer, oldest first, that pass all of the input filters. If limit
+// is greater than zero, only the most recent limit traces are returned.
+func (t *EventTracer) GetTraces(filters []EventTraceFilter, limit int) *EventTraceOutput {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	out := &EventTraceOutput{
+		Enabled:  t.enabled,
+		Capacity: t.capacity,
+		Traces:   make([]EventTrace, 0),
+	}
+
+	// When the buffer has wrapped, the oldest trace is in the next slot to be written.
+	start := 0
+	if len(t.traces) == t.capacity {
+		start = t.next
+	}
+
+	for i := 0; i < len(t.traces); i++ {
+		tr := t.traces[(start+i)%len(t.traces)]
+
+		// Make a deep copy so that the caller can serialize it without holding the lock.
+		cp := *tr
+		cp.msg = nil
+		cp.pending = nil
+		cp.Consumers = append([]string{}, tr.Consumers...)
+		cp.Commands = make([]*CommandTrace, 0, len(tr.Commands))
+		for _, c := range tr.Commands {
+			cc := *c
+			cp.Commands = append(cp.Commands, &cc)
+		}
+
+		include := true
+		for _, f := range filters {
+			if !f(cp) {
+				include = false
+				break
+			}
+		}
+		if include {
+			out.Traces = append(out.Traces, cp)
+		}
+	}
+
+	if limit > 0 && len(out.Traces) > limit {
+		out.Traces = out.Traces[len(out.Traces)-limit:]
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+// +build unit
+
+package worker
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_EventTrace_disabled(t *testing.T) {
+
+	tracer := NewEventTracer()
+
+	msg := NewTestMessage()
+	tracer.eventReceived("worker1", msg, tracer.mark())
+	tracer.commandCreated("worker2", msg, NewTestCommand1(msg))
+
+	out := tracer.GetTraces(nil, 0)
+	assert.False(t, out.Enabled, "Tracing should be off by default.")
+	assert.Equal(t, 0, len(out.Traces), "There should be no traces.")
+}
+
+func Test_EventTrace_correlation(t *testing.T) {
+
+	tracer := NewEventTracer()
+	tracer.Configure(true, 10)
+
+	// An event with no causing command starts a new correlation.
+	msg1 := NewTestMessage()
+	tracer.eventReceived("worker1", msg1, tracer.mark())
+
+	// worker2 creates a command from the event and starts to handle it.
+	cmd := NewTestCommand1(msg1)
+	tracer.commandCreated("worker2", msg1, cmd)
+	tracer.commandStarted("worker2", cmd)
+
+	// An event emitted by worker2 while it is handling the command inherits the correlation id.
+	msg2 := NewTestMessage()
+	tracer.eventReceived("worker2", msg2, tracer.mark())
+
+	// An event sent before the command completed is correlated even if the dispatcher records it afterwards.
+	msg3 := NewTestMessage()
+	mark := tracer.mark()
+	tracer.commandCompleted("worker2")
+	tracer.eventReceived("worker2", msg3, mark)
+
+	// An event emitted by worker2 after the command completed is not correlated.
+	msg4 := NewTestMessage()
+	tracer.eventReceived("worker2", msg4, tracer.mark())
+
+	out := tracer.GetTraces(nil, 0)
+	assert.True(t, out.Enabled, "Tracing should be on.")
+	assert.Equal(t, 4, len(out.Traces), "There should be 4 traces.")
+
+	e1, e2, e3, e4 := out.Traces[0], out.Traces[1], out.Traces[2], out.Traces[3]
+	assert.Equal(t, e1.EventId, e1.CorrelationId, "The first event should start its own correlation.")
+	assert.Equal(t, []string{"worker2"}, e1.Consumers, "worker2 should have consumed the first event.")
+	assert.Equal(t, 1, len(e1.Commands), "The first event should have 1 command.")
+	assert.Equal(t, "worker.TestCommand1", e1.Commands[0].Command)
+	assert.NotEqual(t, int64(0), e1.Commands[0].Started, "The command should be started.")
+	assert.NotEqual(t, int64(0), e1.Commands[0].Completed, "The command should be completed.")
+
+	assert.Equal(t, e1.CorrelationId, e2.CorrelationId, "The second event should be correlated with the first.")
+	assert.Equal(t, e1.EventId, e2.ParentEventId)
+	assert.Equal(t, "worker.TestCommand1", e2.SourceCommand)
+	assert.Equal(t, e1.CorrelationId, e3.CorrelationId, "The third event should be correlated with the first.")
+	assert.Equal(t, e4.EventId, e4.CorrelationId, "The fourth event should start its own correlation.")
+
+	// Filters and limit
+	assert.Equal(t, 3, len(tracer.GetTraces([]EventTraceFilter{CorrelationIdETFilter(e1.CorrelationId)}, 0).Traces))
+	assert.Equal(t, 2, len(tracer.GetTraces([]EventTraceFilter{CorrelationIdETFilter(e1.CorrelationId)}, 2).Traces))
+	assert.Equal(t, 4, len(tracer.GetTraces([]EventTraceFilter{WorkerETFilter("worker2")}, 0).Traces))
+	assert.Equal(t, 1, len(tracer.GetTraces([]EventTraceFilter{WorkerETFilter("worker1")}, 0).Traces))
+	assert.Equal(t, 4, len(tracer.GetTraces([]EventTraceFilter{EventETFilter("testid")}, 0).Traces))
+	assert.Equal(t, 0, len(tracer.GetTraces([]EventTraceFilter{EventETFilter("otherid")}, 0).Traces))
+}
+
+func Test_EventTrace_ring(t *testing.T) {
+
+	tracer := NewEventTracer()
+	tracer.Configure(true, 3)
+
+	// Leave a command queued from the first event, it should be forgotten when the event is evicted.
+	first := NewTestMessage()
+	tracer.eventReceived("worker1", first, tracer.mark())
+	queued := NewTestCommand1(first)
+	tracer.commandCreated("worker2", first, queued)
+
+	for i := 0; i < 4; i++ {
+		tracer.eventReceived("worker1", NewTestMessage(), tracer.mark())
+	}
+
+	out := tracer.GetTraces(nil, 0)
+	assert.Equal(t, 3, len(out.Traces), "The ring buffer should hold 3 traces.")
+	for i, tr := range out.Traces {
+		assert.Equal(t, fmt.Sprintf("e%v", i+3), tr.EventId, "The traces should be returned oldest first.")
+	}
+	assert.Equal(t, 3, len(tracer.byEvent), "Evicted events should be removed from the index.")
+	assert.Equal(t, 0, len(tracer.commands), "Commands of evicted events should be forgotten.")
+}
@@ -5,10 +5,19 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/metrics"
 	"runtime"
+	"strings"
 	"time"
 )
 
+// Metrics maintained by the worker framework on behalf of all workers.
+var (
+	eventQueueDepth   = metrics.NewGaugeVec("anax_worker_event_queue_depth", "Number of events collected by the dispatcher and not yet delivered to a worker.", "worker")
+	commandQueueDepth = metrics.NewGaugeVec("anax_worker_command_queue_depth", "Number of commands waiting in a worker's command queue.", "worker")
+	commandDuration   = metrics.NewHistogramVec("anax_worker_command_duration_seconds", "Time taken by a worker to handle a command.", nil, "worker", "command")
+)
+
 // The core of anax is an event handling system that distributes events to workers, where the workers
 // process events that they are about.
 
@@ -194,6 +203,11 @@ func (w *BaseWorker) GetName() string {
 	return w.Name
 }
 
+// Returns the number of commands waiting in the worker's command queue.
+func (w *BaseWorker) CommandQueueDepth() int {
+	return len(w.Commands)
+}
+
 func (w *BaseWorker) SetWorkerShuttingDown() {
 	w.ShuttingDown = true
 }
@@ -258,6 +272,13 @@ func (w *BaseWorker) HandleFrameworkCommands(command Command) (bool, bool) {
 
 // This function handles commands for the worker. Returns true when the worker should terminate.
 func (w *BaseWorker) internalCommandhandler(worker Worker, command Command) bool {
+	start := time.Now()
+	defer commandDuration.ObserveSince(start, w.GetName(), commandTypeName(command))
+	commandQueueDepth.Set(float64(len(w.Commands)), w.GetName())
+
+	eventTracer.commandStarted(w.GetName(), command)
+	defer eventTracer.commandCompleted(w.GetName())
+
 	glog.V(2).Infof(cdLogString(fmt.Sprintf("%v received command: %v", w.GetName(), command.ShortString())))
 	glog.V(5).Infof(cdLogString(fmt.Sprintf("%v received command: %v", w.GetName(), command)))
 
@@ -277,6 +298,18 @@ func (w *BaseWorker) internalCommandhandler(worker Worker, command Command) bool
 	return false
 }
 
+// Record that the input command was created by this worker from the incoming event, so that the command carries the
+// event's trace correlation id. Workers call this from their NewEvent function. The command is returned unchanged.
+func (w *BaseWorker) TraceCommand(incoming events.Message, command Command) Command {
+	eventTracer.commandCreated(w.GetName(), incoming, command)
+	return command
+}
+
+// Returns the type name of a command or message, without the pointer prefix, for use as a metric label.
+func commandTypeName(v interface{}) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", v), "*")
+}
+
 // This function kicks off the go routine that the worker's logic runs in.
 func (w *BaseWorker) Start(worker Worker, noWorkInterval int) {
 	go func() {
@@ -477,7 +510,9 @@ func (m *MessageHandlerRegistry) Contains(name string) bool {
 // out to each worker. Workers then receive messages and, for messages they care about, the worker pushes them out as commands
 // onto their own channels to operate on them.
 //
-func eventHandler(incoming events.Message, workers *MessageHandlerRegistry) (string, error) {
+// The backlog is the number of events still waiting in the dispatcher after this one, they are reported as waiting for
+// each worker until this event has been delivered to it.
+func eventHandler(incoming events.Message, workers *MessageHandlerRegistry, backlog int) (string, error) {
 	successMsg := "propagated event to all workers"
 
 	// If the message is the special worker stop message, then remove that worker from the dispatch pool.
@@ -492,7 +527,9 @@ func eventHandler(incoming events.Message, workers *MessageHandlerRegistry) (str
 	// Dispatch the message to all workers
 	for name, worker := range workers.Handlers {
 		glog.V(5).Infof(mdLogString(fmt.Sprintf("Delivering message to %v", name)))
+		eventQueueDepth.Set(float64(backlog+1), name)
 		(*worker).NewEvent(incoming)
+		eventQueueDepth.Set(float64(backlog), name)
 		glog.V(5).Infof(mdLogString(fmt.Sprintf("Delivered message to %v", name)))
 	}
 
@@ -504,9 +541,11 @@ func eventHandler(incoming events.Message, workers *MessageHandlerRegistry) (str
 //
 func mux(workers *MessageHandlerRegistry, muxed chan events.Message) chan events.Message {
 
-	for _, w := range workers.Handlers {
+	for name, w := range workers.Handlers {
+		mark := eventTracer.mark()
 		select {
 		case ev := <-(*w).Messages():
+			eventTracer.eventReceived(name, ev, mark)
 			muxed <- ev
 		default: // nothing
 		}
@@ -515,6 +554,18 @@ func mux(workers *MessageHandlerRegistry, muxed chan events.Message) chan events
 	return muxed
 }
 
+// Update the queue depth metrics of every worker. Every event collected by the dispatcher is waiting to be delivered to
+// every worker. The command queues are read here, on every pass of the dispatcher, so that a queue that is backing up is
+// reported even while its worker is busy with a long running command.
+func (workers *MessageHandlerRegistry) setQueueDepths(events int) {
+	for name, w := range workers.Handlers {
+		eventQueueDepth.Set(float64(events), name)
+		if cq, ok := (*w).(interface{ CommandQueueDepth() int }); ok {
+			commandQueueDepth.Set(float64(cq.CommandQueueDepth()), name)
+		}
+	}
+}
+
 func (workers *MessageHandlerRegistry) ProcessEventMessages() {
 
 	// 200 messages should be plenty. We will never get more than 1 message from every worker each time
@@ -532,6 +583,7 @@ func (workers *MessageHandlerRegistry) ProcessEventMessages() {
 
 		// Grab messages that are outbound from the workers.
 		messageStream = mux(workers, messageStream)
+		workers.setQueueDepths(len(messageStream))
 
 		// Process any new messages on the combined worker message queue.
 		done := false
@@ -542,7 +594,7 @@ func (workers *MessageHandlerRegistry) ProcessEventMessages() {
 				glog.V(5).Infof(mdLogString(fmt.Sprintf("Handling Message (%T): %v\n", msg, msg)))
 
 				// Push outbound messages into each worker.
-				if successMsg, err := eventHandler(msg, workers); err != nil {
+				if successMsg, err := eventHandler(msg, workers, len(messageStream)); err != nil {
 					// error! do some barfing and then continue
 					glog.Errorf(mdLogString(fmt.Sprintf("Error occurred handling message: %s, Error: %v\n", msg, err)))
 				} else {
@@ -532,3 +532,18 @@ func getBasicConfig() *config.HorizonConfig {
 var testLogString = func(v interface{}) string {
 	return fmt.Sprintf("TestWorker %v", v)
 }
+
+func Test_setQueueDepths(t *testing.T) {
+
+	mhr := NewMessageHandlerRegistry()
+	w := NewTestWorker("testqueues", getBasicConfig(), 0, 0)
+	mhr.Add(w)
+
+	// The commands waiting for the worker are reported without the worker handling a command.
+	w.Commands <- NewTestCommand1(&TestMessage{})
+	w.Commands <- NewTestCommand1(&TestMessage{})
+	mhr.setQueueDepths(3)
+
+	assert.Equal(t, float64(3), eventQueueDepth.Get("testqueues"), "There should be 3 events waiting for the worker.")
+	assert.Equal(t, float64(2), commandQueueDepth.Get("testqueues"), "There should be 2 commands waiting for the worker.")
+}